@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -21,7 +22,20 @@ import (
 
 	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
 	"github.com/sage-x-project/sage-multi-agent/internal/agentmux"
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/ethpool"
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/internal/msgdecode"
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/lifecycle"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 
 	// DID / Resolver
 	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
@@ -34,6 +48,7 @@ import (
 	"github.com/sage-x-project/sage/pkg/agent/transport"
 
 	// Keys
+	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
 	"github.com/sage-x-project/sage-a2a-go/pkg/server"
 	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
 	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
@@ -56,14 +71,40 @@ type MedicalAgent struct {
 	hsrv    *sagehttp.HTTPServer // handshake adapter
 	hpkeMu  sync.Mutex           // lazy enable lock
 
+	// pooled registry client (internal/ethpool), released on Shutdown
+	resolver        sagedid.Resolver
+	releaseResolver func()
+
 	mw      *server.DIDAuthMiddleware // from a2autil.BuildDIDMiddleware
 	openMux *http.ServeMux            // /status
 	protMux *http.ServeMux            // /process
 	handler http.Handler              // final handler
 	httpSrv *http.Server
+	lnMu    sync.Mutex
+	ln      net.Listener // bound listener (port 0 resolves here)
 
 	// [LLM] lazy client
 	llmClient llm.Client
+
+	// Signing client for fetching overflowed metadata from Root (overflow.go)
+	ovfMu     sync.Mutex
+	ovfClient *a2aclient.A2AClient
+
+	// audit trail (AUDIT_DIR) and response post-processing hooks
+	audit *audit.Logger
+	hooks *posthook.Chain
+
+	// FIELDENC_KEY_FILE: opens Root's field-level envelopes (fieldenc.go)
+	fieldKey *fieldenc.Key
+
+	// HPKE KID -> establishing DID (kid.go)
+	kids *kidbind.Book
+
+	// Request size limits, advertised in /status and enforced on every route
+	limits bodylimit.Limits
+
+	// What this agent handles, advertised in /status for Root's router
+	hint *selfid.RoutingHint
 }
 
 // NewMedicalAgent builds the agent (same signature as payment.NewPaymentAgent).
@@ -72,12 +113,24 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 		RequireSignature: requireSignature,
 		logger:           log.New(os.Stdout, "[medical] ", log.LstdFlags),
 	}
+	agent.audit = audit.FromEnv("medical", agent.logger)
+	agent.hooks = posthook.FromEnv("medical", agent.audit, agent.logger)
+	agent.fieldKey = loadFieldKey(agent.logger)
+	agent.kids = kidbind.NewBook(0)
+	agent.limits = bodylimit.FromEnv("MEDICAL")
+	hint, err := selfid.LoadRoutingHint("MEDICAL_ROUTING_HINT_FILE", routingHint)
+	if err != nil {
+		agent.logger.Printf("[medical] routing hint: %v (using the built-in one)", err)
+		bootreport.Warnf("routing hint: %v (using the built-in one)", err)
+	}
+	agent.hint = hint
 
 	// ===== DID middleware =====
 	if agent.RequireSignature {
 		mw, err := a2autil.BuildDIDMiddleware(true)
 		if err != nil {
 			agent.logger.Printf("[medical] DID middleware init failed: %v (running without verify)", err)
+			bootreport.Warnf("DID middleware init failed: %v (running without signature verification)", err)
 			agent.mw = nil
 		} else {
 			mw.SetErrorHandler(newCompactDIDErrorHandler(agent.logger))
@@ -91,21 +144,44 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 	// ===== Open mux: /status =====
 	open := http.NewServeMux()
 	open.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{
+		httpcache.WriteJSON(w, r, map[string]any{
 			"name":         "medical",
 			"type":         "medical",
+			"version":      selfid.Version,
 			"sage_enabled": agent.RequireSignature,
 			"hpke_ready":   agent.hpkeSrv != nil,
-			"time":         time.Now().Format(time.RFC3339),
+			"hpkeNotReady": kidbind.NotReadyCount(),
+			"addr":         agent.Addr(),
+			"limits":       agent.limits,
+			"routingHint":  agent.hint,
+		})
+	})
+	// Live counters, never revalidated
+	open.HandleFunc("/status/live", func(w http.ResponseWriter, r *http.Request) {
+		httpcache.WriteLive(w, map[string]any{
+			"requests": reqmetrics.Snapshot(),
+			"ethPool":  ethpool.Snapshot(),
+			"llmModel": llm.ModelStatus(),
+			"time":     time.Now().Format(time.RFC3339),
 		})
 	})
+	// Gateway-prefixed form, so Root's startup probe works through the gateway
+	open.Handle("/medical/status", http.StripPrefix("/medical", open))
+	open.Handle("/medical/status/live", http.StripPrefix("/medical", open))
+	agent.mountFlags(open)
 	agent.openMux = open
 
 	// ===== Protected mux: /process =====
 	protected := http.NewServeMux()
 	protected.HandleFunc("/medical/process", func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
+		// The DID middleware has verified the bytes as received; inflate only now
+		src, err := gzipx.RequestBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, release, _ := msgdecode.ReadBody(src)
+		defer release()
 		_ = r.Body.Close()
 
 		// Rehydrate minimal SecureMessage context from headers (data-mode)
@@ -122,7 +198,10 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 				return
 			}
 
-			kid := strings.TrimSpace(r.Header.Get("X-KID"))
+			kid, ok := agent.requestKID(w, r)
+			if !ok {
+				return
+			}
 
 			// --- Handshake (no KID) ---
 			if kid == "" {
@@ -130,20 +209,26 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 					http.Error(w, "hpke handshake disabled", http.StatusBadRequest)
 					return
 				}
-				r.Body = io.NopCloser(bytes.NewReader(body))
-				agent.hsrv.MessagesHandler().ServeHTTP(w, r)
+				r.Body = io.NopCloser(bytes.NewReader(bytes.Clone(body)))
+				agent.handshake(w, r)
 				return
 			}
 
 			// --- Data mode (has KID) ---
 			sess, ok := agent.hpkeMgr.GetByKeyID(kid)
 			if !ok {
-				if agent.hsrv != nil {
-					r.Body = io.NopCloser(bytes.NewReader(body))
-					agent.hsrv.MessagesHandler().ServeHTTP(w, r)
+				// A handshake sent with a stale X-KID is still served as one;
+				// ciphertext under an unknown kid gets 409 SESSION_NOT_READY
+				if agent.hsrv != nil && kidbind.LooksLikeHandshake(body) {
+					r.Body = io.NopCloser(bytes.NewReader(bytes.Clone(body)))
+					agent.handshake(w, r)
 					return
 				}
-				http.Error(w, "hpke session not found", http.StatusBadRequest)
+				agent.sessionNotReady(w, r, kid)
+				return
+			}
+			// The session must be used by the DID that established it
+			if !agent.checkKIDOwner(w, r, kid) {
 				return
 			}
 			pt, err := sess.Decrypt(body)
@@ -151,6 +236,9 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 				http.Error(w, "hpke decrypt failed", http.StatusBadRequest)
 				return
 			}
+			if !agent.checkPayload(w, r, pt) {
+				return
+			}
 			sm := &transport.SecureMessage{
 				ID:        mid,
 				ContextID: ctxID,
@@ -181,6 +269,9 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 		}
 
 		// --- Plain data-mode ---
+		if !agent.checkPayload(w, r, body) {
+			return
+		}
 		sm := &transport.SecureMessage{
 			ID:        mid,
 			ContextID: ctxID,
@@ -206,7 +297,7 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 	if agent.mw != nil {
 		wrapped := agent.mw.Wrap(protected)
 		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/status" {
+			if r.URL.Path == "/status" || r.URL.Path == "/medical/status" || r.URL.Path == "/status/live" || r.URL.Path == "/medical/status/live" || r.URL.Path == "/flags" || strings.HasPrefix(r.URL.Path, "/flags/") {
 				open.ServeHTTP(w, r)
 				return
 			}
@@ -215,10 +306,15 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 	} else {
 		root := http.NewServeMux()
 		root.Handle("/status", open)
+		root.Handle("/medical/status", open)
+		root.Handle("/status/live", open)
+		root.Handle("/medical/status/live", open)
+		root.Handle("/flags", open)
+		root.Handle("/flags/", open)
 		root.Handle("/process", protected)
 		h = root
 	}
-	agent.handler = h
+	agent.handler = reqmetrics.New("medical", agent.logger).Wrap(bodylimit.Handler(agent.limits, gzipx.Handler(h)))
 
 	// ===== Optional eager HPKE boot =====
 	_ = agent.ensureHPKE()
@@ -234,6 +330,8 @@ func NewMedicalAgent(requireSignature bool) (*MedicalAgent, error) {
 	return agent, nil
 }
 
+var _ lifecycle.Upstream = (*MedicalAgent)(nil)
+
 // Return the handler
 func (e *MedicalAgent) Handler() http.Handler { return e.handler }
 
@@ -242,17 +340,48 @@ func (e *MedicalAgent) Start(addr string) error {
 	if e.handler == nil {
 		return fmt.Errorf("handler not initialized")
 	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	e.lnMu.Lock()
+	e.ln = ln
 	e.httpSrv = &http.Server{Addr: addr, Handler: e.handler}
-	e.logger.Printf("[boot] medical on %s (requireSig=%v, hpke_ready=%v)", addr, e.RequireSignature, e.hpkeSrv != nil)
-	return e.httpSrv.ListenAndServe()
+	srv := e.httpSrv
+	e.lnMu.Unlock()
+	e.logger.Printf("[boot] medical on %s (requireSig=%v, hpke_ready=%v)", ln.Addr().String(), e.RequireSignature, e.hpkeSrv != nil)
+	return srv.Serve(ln)
+}
+
+// Addr returns the bound listener address ("" before Start). Useful with port 0.
+func (e *MedicalAgent) Addr() string {
+	e.lnMu.Lock()
+	defer e.lnMu.Unlock()
+	if e.ln == nil {
+		return ""
+	}
+	return e.ln.Addr().String()
 }
 
 // Shutdown server
 func (e *MedicalAgent) Shutdown(ctx context.Context) error {
-	if e.httpSrv == nil {
+	e.hpkeMu.Lock()
+	if e.releaseResolver != nil {
+		e.releaseResolver()
+		e.resolver, e.releaseResolver = nil, nil
+	}
+	e.hpkeMu.Unlock()
+
+	e.lnMu.Lock()
+	srv := e.httpSrv
+	e.lnMu.Unlock()
+	if srv == nil {
+		e.audit.Close()
 		return nil
 	}
-	return e.httpSrv.Shutdown(ctx)
+	err := srv.Shutdown(ctx)
+	e.audit.Close()
+	return err
 }
 
 // -------- Lazy HPKE enable --------
@@ -277,7 +406,7 @@ func (e *MedicalAgent) ensureHPKE() error {
 	if err != nil {
 		return fmt.Errorf("hpke signing key: %w", err)
 	}
-	resolver, err := buildResolver()
+	resolver, err := e.sharedResolver()
 	if err != nil {
 		return fmt.Errorf("hpke resolver: %w", err)
 	}
@@ -315,9 +444,10 @@ func (e *MedicalAgent) ensureHPKE() error {
 // -------- Application handler (LLM-driven medical info) --------
 
 // -------- Application handler (LLM-driven medical info with history) --------
-func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+func (e *MedicalAgent) handleApp(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+	ctx = llm.WithModelNote(llm.WithLangNote(ctx))
 	var in types.AgentMessage
-	if err := json.Unmarshal(msg.Payload, &in); err != nil {
+	if err := msgdecode.AgentMessage(msg.Payload, &in); err != nil {
 		return &transport.Response{
 			Success:   false,
 			MessageID: msg.ID,
@@ -325,6 +455,7 @@ func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 			Error:     fmt.Errorf("bad json: %w", err),
 		}, nil
 	}
+	e.openFields(ctx, &in)
 
 	// ===== Metadata collection (prefer fields set by Root) =====
 	lang := getMetaString(in.Metadata, "lang")
@@ -348,6 +479,7 @@ func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 
 	initialQ := getMetaString(in.Metadata, "medical.initial_question", "initial_question")
 	lastMsg := getMetaString(in.Metadata, "medical.last_message", "last_message")
+	e.resolveOverflow(ctx, in.Metadata, "medical.history")
 	history := getMetaStringSlice(in.Metadata, "medical.history", "history", "medical.transcript", "transcript")
 	histN := getMetaInt64(in.Metadata, "medical.history_len", "history_len")
 	if histN > 0 && len(history) > int(histN) {
@@ -364,6 +496,20 @@ func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 		}
 	}
 
+	// ===== Not a medical question: hand it back to Root before the LLM call =====
+	if flagHandback.On() {
+		if domain, reason, ok := classifyHandback(query); ok {
+			e.logger.Printf("[medical][handback] suggested=%s reason=%q", domain, reason)
+			b, _ := json.Marshal(handbackMessage(&in, lang, domain, reason, msg.Metadata["hpke"]))
+			return &transport.Response{
+				Success:   true,
+				MessageID: msg.ID,
+				TaskID:    msg.TaskID,
+				Data:      b,
+			}, nil
+		}
+	}
+
 	// ===== Build LLM prompt =====
 	sys := map[string]string{
 		"ko": "너는 의료 정보 도우미야. 진단/처방 없이, 안전하고 일반적인 의학 정보를 한 문장으로만 제공해. 응급 징후가 의심되면 전문의 진료를 권유해. 목록/코드블록/장황한 설명 금지.",
@@ -427,8 +573,10 @@ func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 
 	// ===== LLM call (fallback on failure) =====
 	text := ""
-	if e.llmClient != nil {
-		out, err := e.llmClient.Chat(ctx, sys, usr)
+	if e.llmClient != nil && !flagLLM.On() {
+		e.logger.Printf("[medical][llm] disabled by flag medical.llm (using fallback)")
+	} else if e.llmClient != nil {
+		out, err := llm.ChatLang(ctx, e.llmClient, lang, sys, usr)
 		e.logger.Println("[medical][llm]", usr, out)
 		if err != nil {
 			e.logger.Printf("[medical][llm] chat error: %v", err)
@@ -479,6 +627,12 @@ func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 			},
 		},
 	}
+	if llm.LangCorrected(ctx) {
+		out.Metadata["languageCorrected"] = true
+	}
+	if llm.ModelMismatched(ctx) {
+		out.Metadata["llmModelMismatch"] = true
+	}
 	b, _ := json.Marshal(out)
 
 	return &transport.Response{
@@ -539,24 +693,17 @@ func loadServerKEMFromEnv() (sagecrypto.KeyPair, error) {
 	return kp, nil
 }
 
-func buildResolver() (sagedid.Resolver, error) {
-	rpc := firstNonEmpty(os.Getenv("ETH_RPC_URL"), "http://127.0.0.1:8545")
-	contract := firstNonEmpty(os.Getenv("SAGE_REGISTRY_ADDRESS"), "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
-	priv := strings.TrimPrefix(strings.TrimSpace(os.Getenv("SAGE_EXTERNAL_KEY")), "0x")
-
-	cfgV4 := &sagedid.RegistryConfig{
-		RPCEndpoint:        rpc,
-		ContractAddress:    contract,
-		PrivateKey:         priv, // optional (read-only)
-		GasPrice:           0,
-		MaxRetries:         24,
-		ConfirmationBlocks: 0,
-	}
-	ethV4, err := dideth.NewEthereumClient(cfgV4)
-	if err != nil {
-		return nil, fmt.Errorf("HPKE: init resolver failed: %w", err)
+// sharedResolver returns the pooled registry client (shared with the DID
+// middleware), acquiring it once per agent. Caller holds hpkeMu.
+func (e *MedicalAgent) sharedResolver() (sagedid.Resolver, error) {
+	if e.resolver == nil {
+		r, release, err := a2autil.Shared(a2autil.KindEthereum, a2autil.RegistryConfigFromEnv(""), dideth.NewEthereumClient)
+		if err != nil {
+			return nil, fmt.Errorf("HPKE: init resolver failed: %w", err)
+		}
+		e.resolver, e.releaseResolver = r, release
 	}
-	return ethV4, nil
+	return e.resolver, nil
 }
 
 func loadDIDsFromKeys(path string) (map[string]string, error) {
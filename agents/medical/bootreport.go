@@ -0,0 +1,66 @@
+// Package medical - boot report.
+//
+// BootReport describes the agent as constructed for the startup banner and
+// boot report cmd/medical emits (internal/bootreport): signature enforcement,
+// HPKE and field encryption, the signing and KEM key files with their key
+// IDs, and the DID the keys file maps "medical" to. Init warnings (DID
+// middleware, field key, routing hint) are collected into it.
+package medical
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+)
+
+// BootReport builds the agent's boot report for the server on port. The
+// caller adds its own settings and emits it.
+func (e *MedicalAgent) BootReport(port int) *bootreport.Report {
+	rep := bootreport.New("medical").Port("http", port)
+	switch {
+	case !e.RequireSignature:
+		rep.Mode("signatures", "off")
+	case e.mw == nil:
+		rep.Mode("signatures", "required (not verifying: middleware failed)")
+	default:
+		rep.Mode("signatures", "required")
+	}
+	sign, kem := strings.TrimSpace(os.Getenv("MEDICAL_JWK_FILE")), strings.TrimSpace(os.Getenv("MEDICAL_KEM_JWK_FILE"))
+	switch {
+	case sign != "" && kem != "":
+		rep.Mode("hpke", "lazy")
+	default:
+		rep.Mode("hpke", "off (no keys)")
+	}
+	rep.Mode("field_encryption", "off")
+	if e.fieldKey != nil {
+		rep.Mode("field_encryption", "on")
+	}
+	rep.Mode("admin", "off")
+	if strings.TrimSpace(os.Getenv("MEDICAL_ADMIN_TOKEN")) != "" {
+		rep.Mode("admin", "on")
+	}
+	rep.Key("signing", sign)
+	rep.Key("kem", kem)
+
+	keys := strings.TrimSpace(os.Getenv("HPKE_KEYS_FILE"))
+	rep.Setting("hpke.keys", keys)
+	if keys != "" {
+		if m, err := loadDIDsFromKeys(keys); err != nil {
+			rep.Warn("HPKE_KEYS_FILE %s: %v", keys, err)
+		} else if did := m["medical"]; did != "" {
+			rep.DID("self", did)
+		} else {
+			rep.Warn("HPKE_KEYS_FILE %s has no row named medical", keys)
+		}
+	}
+	rep.Setting("llm.url", os.Getenv("LLM_BASE_URL"))
+	rep.Setting("llm.model", os.Getenv("LLM_MODEL"))
+	rep.Setting("llm.model_pin", os.Getenv("LLM_MODEL_PIN"))
+	rep.Setting("llm.model_pin_strict", os.Getenv("LLM_MODEL_PIN_STRICT"))
+	rep.Setting("llm.lang", os.Getenv("LLM_LANG_DEFAULT"))
+	rep.Setting("llm.timeout_ms", os.Getenv("LLM_TIMEOUT_MS"))
+	rep.Setting("llm.api_key", os.Getenv("LLM_API_KEY"))
+	return rep
+}
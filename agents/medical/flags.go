@@ -0,0 +1,32 @@
+// Package medical - runtime feature flags.
+//
+// GET /flags lists the flags this process knows; POST /flags/{name} toggles
+// one at runtime when it carries MEDICAL_ADMIN_TOKEN. MEDICAL_FLAGS_FILE persists
+// toggles across restarts.
+package medical
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+)
+
+var flagLLM = flags.Register(flags.Spec{
+	Name: "medical.llm", Env: "LLM_ENABLED", Default: true, Owner: "medical",
+	Description: "answer with the LLM (off = built-in general-information reply)",
+})
+
+var flagHandback = flags.Register(flags.Spec{
+	Name: "medical.handback", Env: "MEDICAL_HANDBACK", Default: true, Owner: "medical",
+	Description: "hand clearly non-medical questions back to Root (Type redirect) instead of answering",
+})
+
+func (e *MedicalAgent) mountFlags(open *http.ServeMux) {
+	if err := flags.Persist(os.Getenv("MEDICAL_FLAGS_FILE")); err != nil {
+		e.logger.Printf("[medical][flags] state file: %v (toggles kept in memory)", err)
+	}
+	h := flags.Handler(flags.TokenAuth("MEDICAL_ADMIN_TOKEN"), e.audit)
+	open.Handle("/flags", h)
+	open.Handle("/flags/", h)
+}
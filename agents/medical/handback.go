@@ -0,0 +1,96 @@
+// Package medical - domain hand-back.
+//
+// Root sometimes routes a request here that medical cannot help with, e.g. a
+// medication price question. Before the LLM call, a cheap keyword classifier
+// checks the question: when it carries purchase or planning cues and no
+// medical-question cue (symptom, dose, side effect, ...), the agent answers
+// Type "redirect" with metadata suggestedDomain and reason, and Root re-routes
+// (see agents/root/redirect.go). Ambiguous questions are answered normally.
+// Flag medical.handback (MEDICAL_HANDBACK) turns it off.
+package medical
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+var (
+	// Any of these keeps the question medical.
+	medicalCues = []string{
+		"symptom", "side effect", "dose", "overdose", "dosage", "pain", "ache", "headache", "hurt", "fever", "cough", "diagnos",
+		"treatment", "interact", "safe to take", "allerg", "prescri", "doctor", "clinic", "sick",
+		"증상", "두통", "부작용", "복용", "용량", "통증", "아프", "아파", "열이", "기침", "치료", "진료",
+		"처방", "먹어도", "병원", "알레르기",
+	}
+	paymentCues = []string{
+		"price", "cost", "how much", "buy", "purchase", "order", "pay for", "pay with", "payment", "checkout", "cheapest",
+		"discount", "shipping", "가격", "얼마", "구매", "결제", "주문", "사고 싶", "살래", "사줘",
+		"최저가", "할인", "배송",
+	}
+	planningCues = []string{
+		"itinerary", "schedule", "plan a trip", "trip", "travel", "book a flight", "agenda",
+		"일정", "여행", "출장", "스케줄", "계획 짜", "계획 세워",
+	}
+)
+
+// firstCue returns the first cue found in s. Latin cues must start a word
+// ("order" does not match "disorder"); they may be prefixes ("diagnos").
+func firstCue(s string, cues []string) string {
+	for _, c := range cues {
+		for i := 0; ; {
+			j := strings.Index(s[i:], c)
+			if j < 0 {
+				break
+			}
+			j += i
+			if j == 0 || !isASCIILetter(s[j-1]) || !isASCIILetter(c[0]) {
+				return c
+			}
+			i = j + 1
+		}
+	}
+	return ""
+}
+
+func isASCIILetter(b byte) bool { return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' }
+
+// classifyHandback decides whether query belongs to another domain. It
+// returns the suggested domain and a short reason, or ok=false to answer.
+func classifyHandback(query string) (domain, reason string, ok bool) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" || firstCue(q, medicalCues) != "" {
+		return "", "", false
+	}
+	pay, plan := firstCue(q, paymentCues), firstCue(q, planningCues)
+	switch {
+	case pay != "" && plan == "":
+		return "payment", "purchase/price request, not a medical question (matched " + pay + ")", true
+	case plan != "" && pay == "":
+		return "planning", "planning request, not a medical question (matched " + plan + ")", true
+	}
+	return "", "", false
+}
+
+// handbackMessage is the redirect answer for in.
+func handbackMessage(in *types.AgentMessage, lang, domain, reason string, hpke any) types.AgentMessage {
+	text := "This doesn't look like a medical question; handing it back for routing."
+	if lang == "ko" {
+		text = "의료 상담 요청이 아닌 것 같아 다른 담당으로 넘깁니다."
+	}
+	return types.AgentMessage{
+		ID:        in.ID + "-medical",
+		From:      "medical",
+		To:        in.From,
+		Type:      "redirect",
+		Content:   text,
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"agent":           "medical",
+			"hpke":            hpke,
+			"suggestedDomain": domain,
+			"reason":          reason,
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package medical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
+	"github.com/sage-x-project/sage-multi-agent/internal/overflow"
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+// resolveOverflow fetches values Root moved out of the metadata (key_ref tokens)
+// back from Root, signing the request with the medical DID key.
+func (e *MedicalAgent) resolveOverflow(ctx context.Context, meta map[string]any, keys ...string) {
+	base, _ := meta[overflow.MetaURL].(string)
+	if strings.TrimSpace(base) == "" {
+		return
+	}
+	fetch := func(ctx context.Context, token string) (json.RawMessage, error) {
+		doer, err := e.overflowDoer()
+		if err != nil {
+			return nil, err
+		}
+		return overflow.HTTPFetcher(doer, base)(ctx, token)
+	}
+	for _, k := range keys {
+		if _, err := overflow.Resolve(ctx, meta, k, fetch); err != nil {
+			e.logger.Printf("[medical][overflow] resolve %s: %v", k, err)
+		}
+	}
+}
+
+// overflowDoer lazily builds the signing client (MEDICAL_JWK_FILE + "medical" DID).
+func (e *MedicalAgent) overflowDoer() (overflow.Doer, error) {
+	e.ovfMu.Lock()
+	defer e.ovfMu.Unlock()
+	if e.ovfClient != nil {
+		return e.ovfClient, nil
+	}
+	kp, err := loadServerSigningKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	keysPath := firstNonEmpty(os.Getenv("HPKE_KEYS_FILE"), "merged_agent_keys.json")
+	nameToDID, err := loadDIDsFromKeys(keysPath)
+	if err != nil {
+		return nil, fmt.Errorf("load keys (%s): %w", keysPath, err)
+	}
+	didStr := strings.TrimSpace(nameToDID["medical"])
+	if didStr == "" {
+		return nil, fmt.Errorf("DID not found for name 'medical' in %s", keysPath)
+	}
+	e.ovfClient = a2aclient.NewA2AClient(sagedid.AgentDID(didStr), kp, http.DefaultClient)
+	return e.ovfClient, nil
+}
@@ -0,0 +1,39 @@
+// Package medical - response post-processing hooks.
+//
+// Every answer of the application handler passes the "medical" hook chain
+// (internal/posthook) before /process HPKE-encrypts or writes it, e.g. to
+// append a compliance disclaimer. A failed fail-closed hook replaces the
+// answer with a posthook_failed error.
+package medical
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// appHandler runs handleApp, stamps the field-encryption report (fieldenc.go)
+// and applies the hooks.
+func (e *MedicalAgent) appHandler(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+	ctx, rep := fieldenc.WithReport(ctx)
+	resp, err := e.handleApp(ctx, msg)
+	if err != nil || resp == nil || !resp.Success || (e.hooks.Len() == 0 && rep.Empty()) {
+		return resp, err
+	}
+	var out types.AgentMessage
+	if json.Unmarshal(resp.Data, &out) != nil {
+		return resp, nil
+	}
+	out.Metadata = rep.Stamp(out.Metadata)
+	if results, herr := e.hooks.Apply(ctx, msg.ContextID, &out); herr != nil {
+		out = posthook.FailureMessage(&out, results, herr)
+	}
+	if b, err := json.Marshal(out); err == nil {
+		resp.Data = b
+	}
+	return resp, nil
+}
@@ -0,0 +1,13 @@
+package medical
+
+import "github.com/sage-x-project/sage-multi-agent/internal/selfid"
+
+// routingHint is advertised in /status so Root's LLM router knows what this
+// agent handles; MEDICAL_ROUTING_HINT_FILE replaces it with a JSON file.
+var routingHint = selfid.RoutingHint{
+	Description: "Answers health questions: symptoms, conditions, medication and lifestyle or diet advice for a condition.",
+	Examples: map[string][]string{
+		"ko": {"당뇨가 있는데 뭘 먹으면 좋을까?", "두통이 사흘째 계속돼", "이부프로펜이랑 타이레놀 같이 먹어도 돼?"},
+		"en": {"What should I eat with type 2 diabetes?", "I've had a headache for three days", "Can I take ibuprofen with acetaminophen?"},
+	},
+}
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -23,9 +24,23 @@ import (
 	"time"
 
 	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
-	"github.com/sage-x-project/sage-multi-agent/internal/agentmux"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/ethpool"
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/internal/msgdecode"
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
 	"github.com/sage-x-project/sage-multi-agent/llm"
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/pkg/lifecycle"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 
 	// DID / Resolver
 	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
@@ -38,15 +53,35 @@ import (
 	"github.com/sage-x-project/sage/pkg/agent/transport"
 
 	// Keys
-	"github.com/sage-x-project/sage-a2a-go/pkg/server"
 	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
 	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
 )
 
 // -------- Public API --------
 
+// Behavior modes (one binary covers the former external-payment variants).
+const (
+	ModeFull       = "full"        // DID verify + HPKE + receipt
+	ModeVerifyOnly = "verify-only" // DID verify + Content-Digest, echo handler, no HPKE
+	ModeEcho       = "echo"        // no verify, no HPKE, echo handler
+)
+
+// NormalizeMode maps mode names (including legacy aliases) to a Mode* constant.
+func NormalizeMode(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "full", "hpke":
+		return ModeFull, true
+	case "verify-only", "verify", "verifyonly", "digest":
+		return ModeVerifyOnly, true
+	case "echo", "bare":
+		return ModeEcho, true
+	}
+	return "", false
+}
+
 type PaymentAgent struct {
 	RequireSignature bool // true = RFC9421 required, false = allow plaintext (no verify)
+	Mode             string
 
 	// internals
 	logger *log.Logger
@@ -57,31 +92,115 @@ type PaymentAgent struct {
 	hsrv    *sagehttp.HTTPServer // handshake adapter
 	hpkeMu  sync.Mutex           // lazy enable lock
 
-	mw      *server.DIDAuthMiddleware // from a2autil.BuildDIDMiddleware
+	// pooled registry client (internal/ethpool), released on Shutdown
+	resolver        sagedid.Resolver
+	releaseResolver func()
+
+	mw      didVerifier               // from buildDIDVerifier
 	openMux *http.ServeMux            // /status
 	protMux *http.ServeMux            // /process
 	handler http.Handler              // final handler
 	httpSrv *http.Server
+	lnMu    sync.Mutex
+	ln      net.Listener // bound listener (port 0 resolves here)
 
 	// [LLM] lazy client
 	llmClient llm.Client
+
+	// issued receipts by orderId (refunds) and their HTML documents
+	receipts    receiptStore
+	receiptDocs receiptDocStore
+
+	// answers by payment.idempotencyKey (replays, GET /lookup/{key})
+	charges idempotencyStore
+
+	// held authorizations awaiting capture (auth.go, GET /auths/{authId})
+	auths authStore
+
+	// resume tokens of outstanding needs-input requests
+	inputAsks inputAskStore
+
+	// audit trail (AUDIT_DIR) and response post-processing hooks
+	audit *audit.Logger
+	hooks *posthook.Chain
+
+	// FIELDENC_KEY_FILE: opens Root's field-level envelopes (fieldenc.go)
+	fieldKey *fieldenc.Key
+
+	// HPKE KID -> establishing DID (kid.go)
+	kids *kidbind.Book
+
+	// Request size limits, advertised in /status and enforced on every route
+	limits bodylimit.Limits
+
+	// What this agent handles, advertised in /status for Root's router
+	hint *selfid.RoutingHint
 }
 
-// NewPaymentAgent builds the agent.
+// didVerifier checks RFC 9421 signatures in front of the protected mux.
+type didVerifier interface {
+	Wrap(next http.Handler) http.Handler
+}
+
+// buildDIDVerifier builds the DID middleware; optional lets unsigned
+// requests through. Tests replace it to sign without a registry.
+var buildDIDVerifier = func(optional bool, logger *log.Logger) (didVerifier, error) {
+	mw, err := a2autil.BuildDIDMiddleware(optional)
+	if err != nil {
+		return nil, err
+	}
+	mw.SetErrorHandler(newCompactDIDErrorHandler(logger))
+	return mw, nil
+}
+
+// NewPaymentAgent builds the agent in full mode.
 func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
+	return NewPaymentAgentWithMode(ModeFull, requireSignature)
+}
+
+// NewPaymentAgentWithMode builds the agent with the given behavior mode.
+// Echo mode always disables signature verification. Verification is all
+// verify-only mode does, so it refuses requireSignature=false, rejects
+// unsigned requests and fails when the DID middleware cannot be built.
+func NewPaymentAgentWithMode(mode string, requireSignature bool) (*PaymentAgent, error) {
+	m, ok := NormalizeMode(mode)
+	if !ok {
+		return nil, fmt.Errorf("unknown payment mode %q (full|verify-only|echo)", mode)
+	}
+	if m == ModeEcho {
+		requireSignature = false
+	}
+	if m == ModeVerifyOnly && !requireSignature {
+		return nil, fmt.Errorf("payment mode %q verifies signatures and cannot run with requireSignature=false", m)
+	}
 	agent := &PaymentAgent{
 		RequireSignature: requireSignature,
+		Mode:             m,
 		logger:           log.New(os.Stdout, "[payment] ", log.LstdFlags),
 	}
+	agent.audit = audit.FromEnv("payment", agent.logger)
+	agent.hooks = posthook.FromEnv("payment", agent.audit, agent.logger)
+	agent.fieldKey = loadFieldKey(agent.logger)
+	agent.kids = kidbind.NewBook(0)
+	agent.limits = bodylimit.FromEnv("PAYMENT")
+	hint, err := selfid.LoadRoutingHint("PAYMENT_ROUTING_HINT_FILE", routingHint)
+	if err != nil {
+		agent.logger.Printf("[payment] routing hint: %v (using the built-in one)", err)
+		bootreport.Warnf("routing hint: %v (using the built-in one)", err)
+	}
+	agent.hint = hint
 
 	// ===== DID middleware =====
 	if agent.RequireSignature {
-		mw, err := a2autil.BuildDIDMiddleware(true)
+		mw, err := buildDIDVerifier(m != ModeVerifyOnly, agent.logger)
+		if err != nil && m == ModeVerifyOnly {
+			return nil, fmt.Errorf("DID middleware init failed in %s mode: %w", m, err)
+		}
 		if err != nil {
 			agent.logger.Printf("[payment] DID middleware init failed: %v (running without verify)", err)
+			bootreport.Warnf("DID middleware init failed: %v (running without signature verification)", err)
 			agent.mw = nil
 		} else {
-			mw.SetErrorHandler(newCompactDIDErrorHandler(agent.logger))
 			agent.mw = mw
 		}
 	} else {
@@ -91,22 +210,46 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 
 	// ===== Open mux: /status =====
 	open := http.NewServeMux()
-	open.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{
+	open.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		httpcache.WriteJSON(w, r, map[string]any{
 			"name":         "payment",
 			"type":         "payment",
+			"version":      selfid.Version,
+			"mode":         agent.Mode,
 			"sage_enabled": agent.RequireSignature,
 			"hpke_ready":   agent.hpkeSrv != nil,
-			"time":         time.Now().Format(time.RFC3339),
+			"hpkeNotReady": kidbind.NotReadyCount(),
+			"addr":         agent.Addr(),
+			"limits":       agent.limits,
+			"routingHint":  agent.hint,
+		})
+	})
+	// Live counters, never revalidated
+	open.HandleFunc("/status/live", func(w http.ResponseWriter, r *http.Request) {
+		httpcache.WriteLive(w, map[string]any{
+			"requests": reqmetrics.Snapshot(),
+			"ethPool":  ethpool.Snapshot(),
+			"llmModel": llm.ModelStatus(),
+			"time":     time.Now().Format(time.RFC3339),
 		})
 	})
+	// Gateway-prefixed form, so Root's startup probe works through the gateway
+	open.Handle("/payment/status", http.StripPrefix("/payment", open))
+	open.Handle("/payment/status/live", http.StripPrefix("/payment", open))
+	agent.mountFlags(open)
 	agent.openMux = open
 
 	// ===== Protected mux: /process =====
 	protected := http.NewServeMux()
-	protected.HandleFunc("/payment/process", func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
+	processH := func(w http.ResponseWriter, r *http.Request) {
+		// The DID middleware has verified the bytes as received; inflate only now
+		src, err := gzipx.RequestBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, release, _ := msgdecode.ReadBody(src)
+		defer release()
 		_ = r.Body.Close()
 
 		// Rehydrate minimal SecureMessage context from headers (data-mode)
@@ -117,13 +260,20 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 
 		// HPKE path?
 		if isHPKE(r) {
+			if agent.Mode != ModeFull {
+				http.Error(w, "hpke disabled", http.StatusBadRequest)
+				return
+			}
 			if err := agent.ensureHPKE(); err != nil {
 				agent.logger.Printf("[payment] ensureHPKE error: %v", err)
 				http.Error(w, "hpke disabled", http.StatusBadRequest)
 				return
 			}
 
-			kid := strings.TrimSpace(r.Header.Get("X-KID"))
+			kid, ok := agent.requestKID(w, r)
+			if !ok {
+				return
+			}
 
 			// --- Handshake (no KID) ---
 			if kid == "" {
@@ -131,21 +281,26 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 					http.Error(w, "hpke handshake disabled", http.StatusBadRequest)
 					return
 				}
-				r.Body = io.NopCloser(bytes.NewReader(body))
-				agent.hsrv.MessagesHandler().ServeHTTP(w, r)
+				r.Body = io.NopCloser(bytes.NewReader(bytes.Clone(body)))
+				agent.handshake(w, r)
 				return
 			}
 
 			// --- Data mode (has KID) ---
 			sess, ok := agent.hpkeMgr.GetByKeyID(kid)
 			if !ok {
-				// Try to treat as handshake if session vanished
-				if agent.hsrv != nil {
-					r.Body = io.NopCloser(bytes.NewReader(body))
-					agent.hsrv.MessagesHandler().ServeHTTP(w, r)
+				// A handshake sent with a stale X-KID is still served as one;
+				// ciphertext under an unknown kid gets 409 SESSION_NOT_READY
+				if agent.hsrv != nil && kidbind.LooksLikeHandshake(body) {
+					r.Body = io.NopCloser(bytes.NewReader(bytes.Clone(body)))
+					agent.handshake(w, r)
 					return
 				}
-				http.Error(w, "hpke session not found", http.StatusBadRequest)
+				agent.sessionNotReady(w, r, kid)
+				return
+			}
+			// The session must be used by the DID that established it
+			if !agent.checkKIDOwner(w, r, kid) {
 				return
 			}
 			pt, err := sess.Decrypt(body)
@@ -153,13 +308,16 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 				http.Error(w, "hpke decrypt failed", http.StatusBadRequest)
 				return
 			}
+			if !agent.checkPayload(w, r, pt) {
+				return
+			}
 			sm := &transport.SecureMessage{
 				ID:        mid,
 				ContextID: ctxID,
 				TaskID:    taskID,
 				Payload:   pt,
 				DID:       did,
-				Metadata:  map[string]string{"hpke": "true"},
+				Metadata:  map[string]string{"hpke": "true", "op": pathOp(r.URL.Path)},
 				Role:      "agent",
 			}
 
@@ -183,13 +341,16 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 		}
 
 		// --- Plain data-mode ---
+		if !agent.checkPayload(w, r, body) {
+			return
+		}
 		sm := &transport.SecureMessage{
 			ID:        mid,
 			ContextID: ctxID,
 			TaskID:    taskID,
 			Payload:   body,
 			DID:       did,
-			Metadata:  map[string]string{"hpke": "false"},
+			Metadata:  map[string]string{"hpke": "false", "op": pathOp(r.URL.Path)},
 			Role:      "agent",
 		}
 		resp, _ := agent.appHandler(r.Context(), sm)
@@ -200,15 +361,27 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write(resp.Data)
-	})
+	}
+	// Gateway-prefixed and direct paths reach the same handler; /simulate,
+	// /refund and /capture are Root's named operations (the op is taken from the path)
+	for _, p := range []string{"/process", "/simulate", "/refund", "/capture"} {
+		protected.HandleFunc(p, processH)
+		protected.HandleFunc("/payment"+p, processH)
+	}
+	protected.HandleFunc("/payment/receipts/", agent.serveReceiptDoc)
+	protected.HandleFunc("/lookup/", agent.serveLookup)
+	protected.HandleFunc("/payment/lookup/", agent.serveLookup)
+	protected.HandleFunc("/orders/", agent.serveOrder)
+	protected.HandleFunc("/payment/orders/", agent.serveOrder)
+	protected.HandleFunc("/auths/", agent.serveAuth)
+	protected.HandleFunc("/payment/auths/", agent.serveAuth)
 	agent.protMux = protected
-	agent.handler = agentmux.BuildAgentHandler("payment", open, protected, agent.mw)
 	// ===== Compose final handler =====
 	var h http.Handler = open
 	if agent.mw != nil {
 		wrapped := agent.mw.Wrap(protected)
 		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/status" {
+			if r.URL.Path == "/status" || r.URL.Path == "/payment/status" || r.URL.Path == "/status/live" || r.URL.Path == "/payment/status/live" || r.URL.Path == "/flags" || strings.HasPrefix(r.URL.Path, "/flags/") {
 				open.ServeHTTP(w, r)
 				return
 			}
@@ -217,13 +390,30 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 	} else {
 		root := http.NewServeMux()
 		root.Handle("/status", open)
+		root.Handle("/payment/status", open)
+		root.Handle("/status/live", open)
+		root.Handle("/payment/status/live", open)
+		root.Handle("/flags", open)
+		root.Handle("/flags/", open)
 		root.Handle("/process", protected)
+		root.Handle("/simulate", protected)
+		root.Handle("/refund", protected)
+		root.Handle("/capture", protected)
+		root.Handle("/payment/receipts/", protected)
+		root.Handle("/lookup/", protected)
+		root.Handle("/payment/lookup/", protected)
+		root.Handle("/orders/", protected)
+		root.Handle("/payment/orders/", protected)
+		root.Handle("/auths/", protected)
+		root.Handle("/payment/auths/", protected)
 		h = root
 	}
-	agent.handler = h
+	agent.handler = reqmetrics.New("payment", agent.logger).Wrap(bodylimit.Handler(agent.limits, gzipx.Handler(h)))
 
 	// ===== Optional eager HPKE boot =====
-	_ = agent.ensureHPKE()
+	if agent.Mode == ModeFull {
+		_ = agent.ensureHPKE()
+	}
 
 	// [LLM] lazy: only init when used
 	if c, err := llm.NewFromEnv(); err == nil {
@@ -235,6 +425,21 @@ func NewPaymentAgent(requireSignature bool) (*PaymentAgent, error) {
 	return agent, nil
 }
 
+// pathOp names the operation a protected path serves ("process" by default).
+func pathOp(p string) string {
+	switch strings.TrimPrefix(p, "/payment") {
+	case "/simulate":
+		return "simulate"
+	case "/refund":
+		return "refund"
+	case "/capture":
+		return "capture"
+	}
+	return "process"
+}
+
+var _ lifecycle.Upstream = (*PaymentAgent)(nil)
+
 // Return the handler
 func (e *PaymentAgent) Handler() http.Handler { return e.handler }
 
@@ -243,17 +448,48 @@ func (e *PaymentAgent) Start(addr string) error {
 	if e.handler == nil {
 		return fmt.Errorf("handler not initialized")
 	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	e.lnMu.Lock()
+	e.ln = ln
 	e.httpSrv = &http.Server{Addr: addr, Handler: e.handler}
-	e.logger.Printf("[boot] payment on %s (requireSig=%v, hpke_ready=%v)", addr, e.RequireSignature, e.hpkeSrv != nil)
-	return e.httpSrv.ListenAndServe()
+	srv := e.httpSrv
+	e.lnMu.Unlock()
+	e.logger.Printf("[boot] payment on %s (mode=%s requireSig=%v, hpke_ready=%v)", ln.Addr().String(), e.Mode, e.RequireSignature, e.hpkeSrv != nil)
+	return srv.Serve(ln)
+}
+
+// Addr returns the bound listener address ("" before Start). Useful with port 0.
+func (e *PaymentAgent) Addr() string {
+	e.lnMu.Lock()
+	defer e.lnMu.Unlock()
+	if e.ln == nil {
+		return ""
+	}
+	return e.ln.Addr().String()
 }
 
 // Shutdown server
 func (e *PaymentAgent) Shutdown(ctx context.Context) error {
-	if e.httpSrv == nil {
+	e.hpkeMu.Lock()
+	if e.releaseResolver != nil {
+		e.releaseResolver()
+		e.resolver, e.releaseResolver = nil, nil
+	}
+	e.hpkeMu.Unlock()
+
+	e.lnMu.Lock()
+	srv := e.httpSrv
+	e.lnMu.Unlock()
+	if srv == nil {
+		e.audit.Close()
 		return nil
 	}
-	return e.httpSrv.Shutdown(ctx)
+	err := srv.Shutdown(ctx)
+	e.audit.Close()
+	return err
 }
 
 // -------- Lazy HPKE enable --------
@@ -278,7 +514,7 @@ func (e *PaymentAgent) ensureHPKE() error {
 	if err != nil {
 		return fmt.Errorf("hpke signing key: %w", err)
 	}
-	resolver, err := buildResolver()
+	resolver, err := e.sharedResolver()
 	if err != nil {
 		return fmt.Errorf("hpke resolver: %w", err)
 	}
@@ -315,9 +551,10 @@ func (e *PaymentAgent) ensureHPKE() error {
 
 // -------- Application handler (extended with LLM) --------
 
-func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+func (e *PaymentAgent) handleApp(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+	ctx = llm.WithModelNote(llm.WithLangNote(ctx))
 	var in types.AgentMessage
-	if err := json.Unmarshal(msg.Payload, &in); err != nil {
+	if err := msgdecode.AgentMessage(msg.Payload, &in); err != nil {
 		return &transport.Response{
 			Success:   false,
 			MessageID: msg.ID,
@@ -325,6 +562,7 @@ func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 			Error:     fmt.Errorf("bad json: %w", err),
 		}, nil
 	}
+	e.openFields(ctx, &in)
 
 	// Extract slots passed by Root (fallback to naive parsing).
 	to := getMetaString(in.Metadata, "payment.to", "to", "recipient")
@@ -353,24 +591,114 @@ func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 	if lang != "ko" && lang != "en" {
 		lang = "ko"
 	}
+	// Receipt times are shown in the conversation's zone (Root forwards metadata "timezone")
+	loc, tzErr := tz.Resolve(getMetaString(in.Metadata, tz.MetaKey))
+	if tzErr != nil {
+		e.logger.Printf("[payment] ⚠️ %v; receipts use %s", tzErr, loc)
+	}
+
+	// The refund/capture/simulate operations imply what payment.op/payment.dryRun say
+	if op := msg.Metadata["op"]; (op == "refund" || op == "capture" || op == "simulate") && in.Metadata == nil {
+		in.Metadata = map[string]any{}
+	}
+	switch msg.Metadata["op"] {
+	case "refund", "capture":
+		in.Metadata["payment.op"] = msg.Metadata["op"]
+	case "simulate":
+		in.Metadata["payment.dryRun"] = true
+	}
+
+	// Refund of a previously issued receipt
+	if strings.EqualFold(getMetaString(in.Metadata, "payment.op"), "refund") {
+		return e.handleRefund(msg, &in, lang)
+	}
+
+	// Capture of a held authorization (auth.go)
+	if strings.EqualFold(getMetaString(in.Metadata, "payment.op"), "capture") {
+		return e.handleCapture(ctx, msg, &in, lang)
+	}
 
-	if e.llmClient == nil || useEcho {
+	// Dry run (Root compare mode): no receipt/order, echo what was received
+	if dry, _ := in.Metadata["payment.dryRun"].(bool); dry {
+		out := types.AgentMessage{
+			ID:        in.ID + "-dryrun",
+			From:      "payment",
+			To:        in.From,
+			Type:      "response",
+			Content:   fmt.Sprintf("DRY RUN: would pay %s to %s via %s", money.Format(lang, amount, money.KRW), firstNonEmpty(to, "-"), firstNonEmpty(method, "-")),
+			Timestamp: time.Now(),
+			Metadata: map[string]any{
+				"dryRun":   true,
+				"received": in.Content,
+			},
+		}
+		b, _ := json.Marshal(out)
+		return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+	}
+
+	// Same idempotency key as an earlier charge: answer it again, never charge twice
+	idemKey := getMetaString(in.Metadata, "payment.idempotencyKey")
+	if out, ok := e.replayCharge(idemKey, &in); ok {
+		b, _ := json.Marshal(out)
+		return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+	}
+
+	// Merchant needs the card's last 4 digits: ask Root instead of guessing
+	if resp := e.askCardLast4(msg, &in, lang, method, to); resp != nil {
+		return resp, nil
+	}
+
+	// Two-step flow: hold the amount now, charge on capture (auth.go)
+	if strings.EqualFold(getMetaString(in.Metadata, "payment.flow"), flowAuthCapture) && !useEcho {
+		return e.handleAuthorize(msg, &in, lang, loc, idemKey, to, method, item, memo, amount)
+	}
+
+	if e.llmClient == nil || useEcho || e.Mode != ModeFull {
 		out := types.AgentMessage{
 			ID:        in.ID + "-ok",
 			From:      "payment",
 			To:        in.From,
 			Type:      "response",
-			Content:   fmt.Sprintf("External payment processed at %s (echo): %s", time.Now().Format(time.RFC3339), strings.TrimSpace(in.Content)),
+			Content:   fmt.Sprintf("External payment processed at %s (echo): %s", tz.Local(time.Now(), loc), strings.TrimSpace(in.Content)),
 			Timestamp: time.Now(),
 		}
+		e.rememberCharge(idemKey, msg.DID, out)
 		b, _ := json.Marshal(out)
 		return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
 	}
 
     // === Generate one-line receipt with LLM (fallback to template on failure) ===
-    text := e.generateReceipt(ctx, lang, to, amount, method, item, memo)
+    now := time.Now()
+    text := e.generateReceipt(ctx, lang, now.In(loc), to, amount, method, item, memo)
     // === end ===
 
+	receipt := map[string]any{
+		"to":               to,
+		"amountKRW":        amount,
+		"method":           method,
+		"item":             item,
+		"memo":             memo,
+		"orderId":          newOrderID(),
+		"generatedAt":      now.UTC().Format(time.RFC3339),
+		"generatedAtLocal": tz.Local(now, loc),
+		"timezone":         loc.String(),
+		"amount":           money.Rendered(lang, amount, money.KRW),
+	}
+	// Re-quoted purchases: keep both the confirmed estimate and the charged quote
+	if est := getMetaInt64(in.Metadata, "payment.estimatedKRW"); est > 0 {
+		receipt["estimatedKRW"] = est
+	}
+	if q := getMetaInt64(in.Metadata, "payment.quotedKRW"); q > 0 {
+		receipt["quotedKRW"] = q
+	}
+	if l4 := getMetaString(in.Metadata, "payment.cardLast4"); last4Re.MatchString(l4) {
+		receipt["cardLast4"] = l4
+	}
+	e.receipts.put(receipt["orderId"].(string), amount, receipt)
+	if u := e.storeReceiptDoc(lang, firstNonEmpty(getMetaString(in.Metadata, "payment.payerDID"), msg.DID), receipt); u != "" {
+		receipt["receiptUrl"] = u
+	}
+
 	out := types.AgentMessage{
 		ID:        in.ID + "-receipt",
 		From:      "payment",
@@ -379,17 +707,16 @@ func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 		Content:   text,
 		Timestamp: time.Now(),
 		Metadata: map[string]any{
-			"receipt": map[string]any{
-				"to":          to,
-				"amountKRW":   amount,
-				"method":      method,
-				"item":        item,
-				"memo":        memo,
-				"orderId":     fmt.Sprintf("ORD-%04d", time.Now().Unix()%10000),
-				"generatedAt": time.Now().Format(time.RFC3339),
-			},
+			"receipt": receipt,
 		},
 	}
+	if llm.LangCorrected(ctx) {
+		out.Metadata["languageCorrected"] = true
+	}
+	if llm.ModelMismatched(ctx) {
+		out.Metadata["llmModelMismatch"] = true
+	}
+	e.rememberCharge(idemKey, msg.DID, out)
 	b, _ := json.Marshal(out)
 	return &transport.Response{
 		Success:   true,
@@ -401,37 +728,36 @@ func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMess
 
 // -------- LLM Receipt generator --------
 
-func (e *PaymentAgent) generateReceipt(ctx context.Context, lang, to string, amount int64, method, item, memo string) string {
+// generateReceipt writes the one-line receipt; at is the charge time in the
+// conversation's zone and is printed with its offset.
+func (e *PaymentAgent) generateReceipt(ctx context.Context, lang string, at time.Time, to string, amount int64, method, item, memo string) string {
 	// System prompt keeps it terse and single-line.
 	sys := map[string]string{
 		"ko": `너는 결제 영수증 생성기야.
 - 딱 한 줄로만 출력하고, 이모지/불릿/따옴표/코드블록/여분 공백/개행 없이.
 - 형식 예시(참고용): 영수증: 수신자=홍길동, 금액=1,250,000원, 방법=카드, 품목=iPhone 15 Pro, 메모=생일선물 · 2025-11-01T12:30:00Z
 - 필드가 비어있으면 생략.
-- KRW는 천단위 콤마와 "원"을 사용.
+- 금액은 입력의 amount 값을 그대로 사용.
 - 너무 장문 금지(140자 이내).`,
 		"en": `You generate a one-line payment receipt.
 - Exactly one line, no emojis/bullets/quotes/code blocks, no extra whitespace.
 - Example (for style only): Receipt: to=Alice, amount=₩1,250,000, method=card, item=iPhone 15 Pro, memo=birthday · 2025-11-01T12:30:00Z
 - Omit empty fields.
-- Use thousands separators and the KRW symbol or "₩".
+- Copy the amount exactly as given (including any "approx." part).
 - Keep it under ~140 chars.`,
 	}[lang]
 	// Normalize labels for method per language
 	mlabel := methodLabel(lang, method)
-	now := time.Now().UTC().Format(time.RFC3339)
-	amt := "₩" + withComma(amount)
-	if lang == "ko" {
-		amt = withComma(amount) + "원"
-	}
+	now := at.Format(time.RFC3339)
+	amt := money.Display(lang, amount, money.KRW)
 
 	usr := fmt.Sprintf(
 		"lang=%s\nto=%s\namount=%s\nmethod=%s\nitem=%s\nmemo=%s\ntimestamp=%s",
 		lang, strings.TrimSpace(to), amt, mlabel, strings.TrimSpace(item), strings.TrimSpace(memo), now,
 	)
 
-	if e.llmClient != nil {
-		if out, err := e.llmClient.Chat(ctx, sys, usr); err == nil {
+	if e.llmClient != nil && flagLLM.On() {
+		if out, err := llm.ChatLang(ctx, e.llmClient, lang, sys, usr); err == nil {
 			if s := strings.TrimSpace(out); s != "" && !strings.Contains(s, "\n") {
 				return s
 			}
@@ -551,24 +877,17 @@ func loadServerKEMFromEnv() (sagecrypto.KeyPair, error) {
 	return kp, nil
 }
 
-func buildResolver() (sagedid.Resolver, error) {
-	rpc := firstNonEmpty(os.Getenv("ETH_RPC_URL"), "http://127.0.0.1:8545")
-	contract := firstNonEmpty(os.Getenv("SAGE_REGISTRY_ADDRESS"), "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
-	priv := strings.TrimPrefix(strings.TrimSpace(os.Getenv("SAGE_EXTERNAL_KEY")), "0x")
-
-	cfgV4 := &sagedid.RegistryConfig{
-		RPCEndpoint:        rpc,
-		ContractAddress:    contract,
-		PrivateKey:         priv, // optional (read-only)
-		GasPrice:           0,
-		MaxRetries:         24,
-		ConfirmationBlocks: 0,
-	}
-	ethV4, err := dideth.NewEthereumClient(cfgV4)
-	if err != nil {
-		return nil, fmt.Errorf("HPKE: init resolver failed: %w", err)
+// sharedResolver returns the pooled registry client (shared with the DID
+// middleware), acquiring it once per agent. Caller holds hpkeMu.
+func (e *PaymentAgent) sharedResolver() (sagedid.Resolver, error) {
+	if e.resolver == nil {
+		r, release, err := a2autil.Shared(a2autil.KindEthereum, a2autil.RegistryConfigFromEnv(""), dideth.NewEthereumClient)
+		if err != nil {
+			return nil, fmt.Errorf("HPKE: init resolver failed: %w", err)
+		}
+		e.resolver, e.releaseResolver = r, release
 	}
-	return ethV4, nil
+	return e.resolver, nil
 }
 
 func loadDIDsFromKeys(path string) (map[string]string, error) {
@@ -655,27 +974,3 @@ func getMetaInt64(m map[string]any, keys ...string) int64 {
 	return 0
 }
 
-func withComma(n int64) string {
-	s := fmt.Sprintf("%d", n)
-	neg := ""
-	if n < 0 {
-		neg = "-"
-		s = s[1:]
-	}
-	out := ""
-	for i, c := range reverse(s) {
-		if i > 0 && i%3 == 0 {
-			out = "," + out
-		}
-		out = string(c) + out
-	}
-	return neg + out
-}
-
-func reverse(s string) []rune {
-	r := []rune(s)
-	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
-		r[i], r[j] = r[j], r[i]
-	}
-	return r
-}
@@ -0,0 +1,376 @@
+// Package payment - authorize now, capture later.
+//
+// With metadata payment.flow:"auth-capture" a payment places a hold instead of
+// charging: the answer carries metadata authorization {authId, status
+// "authorized", heldKRW, expiresAt} and no receipt. payment.op:"capture"
+// (Root's capture operation, POST /capture) with payment.authId turns the hold
+// into a receipt (with authId and heldKRW) and order for payment.captureKRW,
+// by default the whole hold. Rejections carry metadata {"error":{"code":...},
+// "httpStatus":...} as refunds do: 404 auth_not_found, 409
+// capture_exceeds_hold, already_captured, auth_expired, 400 auth_id_required.
+//
+// A hold lasts PAYMENT_AUTH_HOLD_SECONDS (default 900). When it runs out
+// uncaptured it is voided, logged and audited as payment/auth.expired; the
+// client is not notified, Root's ledger shows the state through GET
+// /auths/{authId} (also /payment/auths/{authId}):
+//
+//	{"type":"response","metadata":{"authorization":{"authId":…,"status":"expired","heldKRW":…,"expiresAt":…}}}
+//
+// Captured and voided holds are kept PAYMENT_AUTH_RETENTION_SECONDS (default
+// 86400) after they settle, then dropped; their lookup answers not_found.
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+	"github.com/sage-x-project/sage-multi-agent/llm"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// flowAuthCapture is the payment.flow value that places a hold.
+const flowAuthCapture = "auth-capture"
+
+// Authorization states.
+const (
+	authAuthorized = "authorized"
+	authCaptured   = "captured"
+	authExpired    = "expired"
+	authNotFound   = "not_found"
+)
+
+const (
+	captureAuthNotFound  = "auth_not_found"
+	captureExceedsHold   = "capture_exceeds_hold"
+	captureAlreadyDone   = "already_captured"
+	captureAuthExpired   = "auth_expired"
+	captureMissingAuthID = "auth_id_required"
+)
+
+// authHold: PAYMENT_AUTH_HOLD_SECONDS (default 900).
+func authHold() time.Duration {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PAYMENT_AUTH_HOLD_SECONDS"))); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// authRetention: PAYMENT_AUTH_RETENTION_SECONDS (default 86400).
+func authRetention() time.Duration {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PAYMENT_AUTH_RETENTION_SECONDS"))); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// authRecord is one hold and what became of it.
+type authRecord struct {
+	AuthID    string
+	HeldKRW   int64
+	To        string
+	Method    string
+	Item      string
+	Memo      string
+	CardLast4 string
+	Payer     string
+	Lang      string
+	Zone      *time.Location
+	Created   time.Time
+	ExpiresAt time.Time
+	Status    string
+
+	OrderID     string
+	CapturedKRW int64
+	CapturedAt  time.Time
+	VoidedAt    time.Time
+}
+
+type authStore struct {
+	mu sync.Mutex
+	m  map[string]*authRecord
+}
+
+func (s *authStore) put(rec *authRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*authRecord)
+	}
+	s.pruneLocked(rec.Created)
+	s.m[rec.AuthID] = rec
+}
+
+// pruneLocked drops holds settled (captured or voided) longer than the
+// retention window before now.
+func (s *authStore) pruneLocked(now time.Time) {
+	keep := authRetention()
+	for id, rec := range s.m {
+		settled := rec.CapturedAt
+		if rec.Status == authExpired {
+			settled = rec.VoidedAt
+		}
+		if rec.Status != authAuthorized && now.Sub(settled) > keep {
+			delete(s.m, id)
+		}
+	}
+}
+
+// get returns a copy of authID's record.
+func (s *authStore) get(authID string) (authRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.m[authID]
+	if !ok {
+		return authRecord{}, false
+	}
+	return *rec, true
+}
+
+// expire voids authID if it is still authorized at or after its expiry.
+func (s *authStore) expire(authID string, now time.Time) (authRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.m[authID]
+	if !ok || rec.Status != authAuthorized || now.Before(rec.ExpiresAt) {
+		return authRecord{}, false
+	}
+	rec.Status, rec.VoidedAt = authExpired, now
+	out := *rec
+	s.pruneLocked(now)
+	return out, true
+}
+
+// claim validates a capture of amount (<= 0: the whole hold) and marks the
+// hold captured under orderID. It returns the record and the captured amount.
+func (s *authStore) claim(authID string, amount int64, orderID string, now time.Time) (authRecord, int64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.m[authID]
+	if !ok {
+		return authRecord{}, 0, captureAuthNotFound
+	}
+	switch {
+	case rec.Status == authCaptured:
+		return *rec, 0, captureAlreadyDone
+	case rec.Status == authExpired || !now.Before(rec.ExpiresAt):
+		return *rec, 0, captureAuthExpired
+	}
+	if amount <= 0 {
+		amount = rec.HeldKRW
+	}
+	if amount > rec.HeldKRW {
+		return *rec, 0, captureExceedsHold
+	}
+	rec.Status, rec.OrderID, rec.CapturedKRW, rec.CapturedAt = authCaptured, orderID, amount, now
+	return *rec, amount, ""
+}
+
+// view renders rec as metadata authorization.
+func (rec authRecord) view() map[string]any {
+	v := map[string]any{
+		"authId":    rec.AuthID,
+		"status":    rec.Status,
+		"heldKRW":   rec.HeldKRW,
+		"createdAt": rec.Created.UTC().Format(time.RFC3339),
+		"expiresAt": rec.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	if rec.Zone != nil {
+		v["expiresAtLocal"] = tz.Local(rec.ExpiresAt, rec.Zone)
+	}
+	if rec.Status == authCaptured {
+		v["orderId"] = rec.OrderID
+		v["capturedKRW"] = rec.CapturedKRW
+		v["capturedAt"] = rec.CapturedAt.UTC().Format(time.RFC3339)
+	}
+	if !rec.VoidedAt.IsZero() {
+		v["voidedAt"] = rec.VoidedAt.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// handleAuthorize places a hold for a complete payment request.
+func (e *PaymentAgent) handleAuthorize(msg *transport.SecureMessage, in *types.AgentMessage, lang string, loc *time.Location, idemKey, to, method, item, memo string, amount int64) (*transport.Response, error) {
+	now := time.Now()
+	rec := &authRecord{
+		AuthID: "AUTH-" + strings.ToUpper(uuid.NewString()[:8]), HeldKRW: amount,
+		To: to, Method: method, Item: item, Memo: memo,
+		Payer: firstNonEmpty(getMetaString(in.Metadata, "payment.payerDID"), msg.DID),
+		Lang:  lang, Zone: loc, Created: now, ExpiresAt: now.Add(authHold()), Status: authAuthorized,
+	}
+	if l4 := getMetaString(in.Metadata, "payment.cardLast4"); last4Re.MatchString(l4) {
+		rec.CardLast4 = l4
+	}
+	e.auths.put(rec)
+	id := rec.AuthID
+	time.AfterFunc(time.Until(rec.ExpiresAt), func() { e.expireAuth(id) })
+	e.logger.Printf("[payment][auth] %s held %d KRW to=%q until %s", id, amount, to, rec.ExpiresAt.UTC().Format(time.RFC3339))
+
+	held := money.Format(lang, amount, money.KRW)
+	out := types.AgentMessage{
+		ID:   in.ID + "-auth",
+		From: "payment",
+		To:   in.From,
+		Type: "response",
+		Content: map[string]string{
+			"ko": fmt.Sprintf("결제 승인(보류) 완료: %s님께 %s (%s). %s까지 확정하지 않으면 자동 취소돼요. 승인번호 %s", firstNonEmpty(to, "-"), held, methodLabel(lang, method), tz.Local(rec.ExpiresAt, loc), id),
+			"en": fmt.Sprintf("Payment authorized (on hold): %s to %s via %s. It is voided unless captured by %s. Authorization %s", held, firstNonEmpty(to, "-"), methodLabel(lang, method), tz.Local(rec.ExpiresAt, loc), id),
+		}[lang],
+		Timestamp: now,
+		Metadata:  map[string]any{"authorization": rec.view()},
+	}
+	e.rememberCharge(idemKey, msg.DID, out)
+	b, _ := json.Marshal(out)
+	return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+}
+
+// expireAuth voids authID when its hold ran out uncaptured.
+func (e *PaymentAgent) expireAuth(authID string) {
+	rec, ok := e.auths.expire(authID, time.Now())
+	if !ok {
+		return
+	}
+	e.logger.Printf("[payment][auth] %s expired uncaptured; released %d KRW", authID, rec.HeldKRW)
+	e.audit.Emit(audit.Event{
+		Type: "payment", Action: "auth.expired", Outcome: "success", Actor: rec.Payer,
+		Detail: map[string]any{"authId": authID, "heldKRW": rec.HeldKRW, "expiresAt": rec.ExpiresAt.UTC().Format(time.RFC3339)},
+	})
+}
+
+func captureErrStatus(code string) int {
+	switch code {
+	case captureAuthNotFound:
+		return http.StatusNotFound
+	case captureMissingAuthID:
+		return http.StatusBadRequest
+	}
+	return http.StatusConflict
+}
+
+// handleCapture serves payment.op:"capture".
+func (e *PaymentAgent) handleCapture(ctx context.Context, msg *transport.SecureMessage, in *types.AgentMessage, lang string) (*transport.Response, error) {
+	authID := strings.TrimSpace(getMetaString(in.Metadata, "payment.authId", "authId"))
+	want := getMetaInt64(in.Metadata, "payment.captureKRW", "captureKRW")
+	now := time.Now()
+	orderID := newOrderID()
+
+	code := captureMissingAuthID
+	var (
+		rec    authRecord
+		amount int64
+	)
+	if authID != "" {
+		// A hold whose timer has not fired yet is voided here first
+		e.expireAuth(authID)
+		rec, amount, code = e.auths.claim(authID, want, orderID, now)
+	}
+
+	var out types.AgentMessage
+	if code != "" {
+		e.logger.Printf("[payment][capture] auth=%q rejected: %s", authID, code)
+		errMeta := map[string]any{"code": code, "authId": authID}
+		switch code {
+		case captureExceedsHold:
+			errMeta["heldKRW"], errMeta["requestedKRW"] = rec.HeldKRW, want
+		case captureAlreadyDone:
+			errMeta["orderId"] = rec.OrderID
+		case captureAuthExpired:
+			errMeta["expiresAt"] = rec.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		out = types.AgentMessage{
+			ID:        in.ID + "-capture-error",
+			From:      "payment",
+			To:        in.From,
+			Type:      "error",
+			Content:   fmt.Sprintf("capture rejected: %s (%s)", code, firstNonEmpty(authID, "-")),
+			Timestamp: now,
+			Metadata:  map[string]any{"error": errMeta, "httpStatus": captureErrStatus(code)},
+		}
+		b, _ := json.Marshal(out)
+		return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+	}
+
+	loc := rec.Zone
+	if loc == nil {
+		loc = time.UTC
+	}
+	receipt := map[string]any{
+		"to":               rec.To,
+		"amountKRW":        amount,
+		"method":           rec.Method,
+		"item":             rec.Item,
+		"memo":             rec.Memo,
+		"orderId":          orderID,
+		"authId":           authID,
+		"heldKRW":          rec.HeldKRW,
+		"generatedAt":      now.UTC().Format(time.RFC3339),
+		"generatedAtLocal": tz.Local(now, loc),
+		"timezone":         loc.String(),
+		"amount":           money.Rendered(lang, amount, money.KRW),
+	}
+	if rec.CardLast4 != "" {
+		receipt["cardLast4"] = rec.CardLast4
+	}
+	e.receipts.put(orderID, amount, receipt)
+	if u := e.storeReceiptDoc(lang, rec.Payer, receipt); u != "" {
+		receipt["receiptUrl"] = u
+	}
+	e.logger.Printf("[payment][capture] auth=%s captured %d of %d KRW as %s", authID, amount, rec.HeldKRW, orderID)
+
+	out = types.AgentMessage{
+		ID:        in.ID + "-receipt",
+		From:      "payment",
+		To:        in.From,
+		Type:      "response",
+		Content:   e.generateReceipt(ctx, lang, now.In(loc), rec.To, amount, rec.Method, rec.Item, rec.Memo),
+		Timestamp: now,
+		Metadata:  map[string]any{"receipt": receipt},
+	}
+	if llm.LangCorrected(ctx) {
+		out.Metadata["languageCorrected"] = true
+	}
+	if llm.ModelMismatched(ctx) {
+		out.Metadata["llmModelMismatch"] = true
+	}
+	b, _ := json.Marshal(out)
+	return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+}
+
+// serveAuth: GET /auths/{authId}.
+func (e *PaymentAgent) serveAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	authID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/payment"), "/auths/")
+	if authID == "" || strings.Contains(authID, "/") {
+		http.Error(w, "authorization id required", http.StatusBadRequest)
+		return
+	}
+	e.expireAuth(authID)
+	view := map[string]any{"authId": authID, "status": authNotFound}
+	if rec, ok := e.auths.get(authID); ok {
+		view = rec.view()
+	}
+	e.logger.Printf("[payment][auths] auth=%s status=%s", authID, view["status"])
+	out := types.AgentMessage{
+		ID: "auth-" + authID, From: "payment", Type: "response",
+		Content: "authorization " + authID + ": " + view["status"].(string), Timestamp: time.Now(),
+		Metadata: map[string]any{"authorization": view},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
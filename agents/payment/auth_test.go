@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func testHold(id string, krw int64, now time.Time) *authRecord {
+	return &authRecord{AuthID: id, HeldKRW: krw, Created: now, ExpiresAt: now.Add(time.Minute), Status: authAuthorized}
+}
+
+func TestAuthorizeCapture(t *testing.T) {
+	var s authStore
+	now := time.Now()
+	s.put(testHold("AUTH-1", 50000, now))
+
+	if _, _, code := s.claim("AUTH-1", 60000, "ORD-X", now); code != captureExceedsHold {
+		t.Fatalf("over the hold: code=%q", code)
+	}
+	rec, amt, code := s.claim("AUTH-1", 30000, "ORD-1", now)
+	if code != "" || amt != 30000 || rec.Status != authCaptured || rec.OrderID != "ORD-1" {
+		t.Fatalf("partial capture: rec=%+v amt=%d code=%q", rec, amt, code)
+	}
+	if rec, _, code := s.claim("AUTH-1", 0, "ORD-2", now); code != captureAlreadyDone || rec.OrderID != "ORD-1" {
+		t.Fatalf("second capture: code=%q order=%q", code, rec.OrderID)
+	}
+	if _, _, code := s.claim("AUTH-NOPE", 0, "ORD-3", now); code != captureAuthNotFound {
+		t.Fatalf("unknown hold: code=%q", code)
+	}
+}
+
+func TestFullCaptureDefault(t *testing.T) {
+	var s authStore
+	now := time.Now()
+	s.put(testHold("AUTH-2", 42000, now))
+	if _, amt, code := s.claim("AUTH-2", 0, "ORD-4", now); code != "" || amt != 42000 {
+		t.Fatalf("whole hold: amt=%d code=%q", amt, code)
+	}
+}
+
+func TestExpiryVoids(t *testing.T) {
+	var s authStore
+	now := time.Now()
+	s.put(testHold("AUTH-3", 10000, now))
+	if _, ok := s.expire("AUTH-3", now); ok {
+		t.Fatal("voided before expiry")
+	}
+	rec, ok := s.expire("AUTH-3", now.Add(time.Minute))
+	if !ok || rec.Status != authExpired || rec.VoidedAt.IsZero() {
+		t.Fatalf("expire: ok=%v rec=%+v", ok, rec)
+	}
+	// a capture that races the timer is refused as expired, not captured
+	s.put(testHold("AUTH-4", 10000, now))
+	if _, _, code := s.claim("AUTH-4", 0, "ORD-5", now.Add(2*time.Minute)); code != captureAuthExpired {
+		t.Fatalf("capture after expiry: code=%q", code)
+	}
+}
+
+func TestSettledHoldsPruned(t *testing.T) {
+	t.Setenv("PAYMENT_AUTH_RETENTION_SECONDS", "60")
+	var s authStore
+	now := time.Now()
+	old := now.Add(-2 * time.Minute)
+
+	captured := testHold("AUTH-C", 1000, old)
+	captured.Status, captured.CapturedAt = authCaptured, old
+	voided := testHold("AUTH-V", 1000, old)
+	voided.Status, voided.VoidedAt = authExpired, old
+	recent := testHold("AUTH-R", 1000, now)
+	recent.Status, recent.CapturedAt = authCaptured, now
+	held := testHold("AUTH-H", 1000, old) // still held: never pruned
+	for _, r := range []*authRecord{captured, voided, recent, held} {
+		s.put(r)
+	}
+	s.put(testHold("AUTH-N", 1000, now))
+
+	for id, want := range map[string]bool{"AUTH-C": false, "AUTH-V": false, "AUTH-R": true, "AUTH-H": true, "AUTH-N": true} {
+		if _, ok := s.get(id); ok != want {
+			t.Errorf("%s kept=%v, want %v", id, ok, want)
+		}
+	}
+}
+
+// A hold that runs out is voided, audited, and reported by GET /auths/{id}.
+func TestExpiredHoldLookup(t *testing.T) {
+	t.Setenv("PAYMENT_AUTH_HOLD_SECONDS", "1")
+	dir := t.TempDir()
+	e := &PaymentAgent{logger: log.New(io.Discard, "", 0), audit: audit.New("payment", dir, log.New(io.Discard, "", 0))}
+
+	in := &types.AgentMessage{ID: "m1", From: "root", Metadata: map[string]any{"payment.cardLast4": "4242"}}
+	resp, err := e.handleAuthorize(&transport.SecureMessage{ID: "m1", DID: "did:payer"}, in, "en", time.UTC, "", "shop", "card", "macbook", "", 50000)
+	if err != nil || !resp.Success {
+		t.Fatalf("authorize: %v %+v", err, resp)
+	}
+	var out types.AgentMessage
+	_ = json.Unmarshal(resp.Data, &out)
+	az, _ := out.Metadata["authorization"].(map[string]any)
+	id, _ := az["authId"].(string)
+	if id == "" || az["status"] != authAuthorized {
+		t.Fatalf("authorization metadata: %v", az)
+	}
+
+	lookup := func() string {
+		w := httptest.NewRecorder()
+		e.serveAuth(w, httptest.NewRequest(http.MethodGet, "/payment/auths/"+id, nil))
+		var m types.AgentMessage
+		_ = json.Unmarshal(w.Body.Bytes(), &m)
+		v, _ := m.Metadata["authorization"].(map[string]any)
+		s, _ := v["status"].(string)
+		return s
+	}
+	if st := lookup(); st != authAuthorized {
+		t.Fatalf("before expiry: %q", st)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if st := lookup(); st != authExpired {
+		t.Fatalf("after expiry: %q", st)
+	}
+
+	e.audit.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "payment-*.jsonl"))
+	var events []byte
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		events = append(events, b...)
+	}
+	if !strings.Contains(string(events), `"action":"auth.expired"`) || !strings.Contains(string(events), id) {
+		t.Fatalf("no auth.expired audit event for %s:\n%s", id, events)
+	}
+}
@@ -0,0 +1,43 @@
+// Package payment - field-level metadata encryption.
+//
+// Without HPKE, Root can seal selected metadata values (payment.shipping by
+// default) with a static key shared out of band (internal/fieldenc). With
+// FIELDENC_KEY_FILE set to the same key, handleApp opens them before reading
+// any slot; without it the envelopes stay in place, the slot reads as missing
+// and the answer's metadata fieldEnc.unreadable names it.
+package payment
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func loadFieldKey(logger *log.Logger) *fieldenc.Key {
+	k, err := fieldenc.KeyFromEnv("FIELDENC_KEY_FILE")
+	if err != nil {
+		logger.Printf("[payment][fieldenc] key not loaded, sealed fields stay unreadable: %v", err)
+		bootreport.Warnf("FIELDENC_KEY_FILE not loaded, sealed fields stay unreadable: %v", err)
+		return nil
+	}
+	if k != nil {
+		logger.Printf("[payment][fieldenc] field-level decryption enabled")
+	}
+	return k
+}
+
+// openFields opens in's sealed metadata values and notes the result on ctx's report.
+func (e *PaymentAgent) openFields(ctx context.Context, in *types.AgentMessage) {
+	opened, unreadable := e.fieldKey.Open(in.Metadata)
+	if len(opened) > 0 {
+		e.logger.Printf("[payment][fieldenc] opened %s", strings.Join(opened, ","))
+	}
+	if len(unreadable) > 0 {
+		e.logger.Printf("[payment][fieldenc][warn] cannot open %s (no or wrong FIELDENC_KEY_FILE); treated as missing", strings.Join(unreadable, ","))
+	}
+	fieldenc.ReportFrom(ctx).Note(opened, unreadable)
+}
@@ -0,0 +1,27 @@
+// Package payment - runtime feature flags.
+//
+// GET /flags lists the flags this process knows; POST /flags/{name} toggles
+// one at runtime when it carries PAYMENT_ADMIN_TOKEN. PAYMENT_FLAGS_FILE persists
+// toggles across restarts.
+package payment
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+)
+
+var flagLLM = flags.Register(flags.Spec{
+	Name: "payment.llm", Env: "LLM_ENABLED", Default: true, Owner: "payment",
+	Description: "compose receipt text with the LLM (off = template receipt)",
+})
+
+func (e *PaymentAgent) mountFlags(open *http.ServeMux) {
+	if err := flags.Persist(os.Getenv("PAYMENT_FLAGS_FILE")); err != nil {
+		e.logger.Printf("[payment][flags] state file: %v (toggles kept in memory)", err)
+	}
+	h := flags.Handler(flags.TokenAuth("PAYMENT_ADMIN_TOKEN"), e.audit)
+	open.Handle("/flags", h)
+	open.Handle("/flags/", h)
+}
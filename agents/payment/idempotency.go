@@ -0,0 +1,130 @@
+// Package payment - idempotent charges and status lookup.
+//
+// Root tags every charge with metadata payment.idempotencyKey. The agent keeps
+// the answer it gave for each key: a repeated request with the same key gets
+// the original answer back (metadata idempotentReplay:true) instead of a
+// second charge. GET /lookup/{key} (also /payment/lookup/{key}) tells Root
+// whether a charge for the key happened — Root calls it when it could not
+// read the charge response (e.g. the HPKE response failed to decrypt):
+//
+//	{"type":"response","metadata":{"lookup":{"key":…,"state":"executed","orderId":…,"receipt":{…}}}}
+//	{"type":"response","metadata":{"lookup":{"key":…,"state":"not_found"}}}
+package payment
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const (
+	lookupExecuted = "executed"
+	lookupNotFound = "not_found"
+)
+
+// keyedCharge is what the agent answered for one idempotency key.
+type keyedCharge struct {
+	OrderID  string
+	Receipt  map[string]any
+	Response types.AgentMessage
+	Owner    string // requester DID
+	At       time.Time
+}
+
+type idempotencyStore struct {
+	mu sync.Mutex
+	m  map[string]*keyedCharge
+}
+
+func (s *idempotencyStore) get(key string) (*keyedCharge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[key]
+	return c, ok
+}
+
+func (s *idempotencyStore) put(key string, c *keyedCharge) {
+	if strings.TrimSpace(key) == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*keyedCharge)
+	}
+	if _, ok := s.m[key]; !ok {
+		c.At = time.Now()
+		s.m[key] = c
+	}
+}
+
+// rememberCharge records out as the answer for key (no-op without a key).
+func (e *PaymentAgent) rememberCharge(key, owner string, out types.AgentMessage) {
+	c := &keyedCharge{Response: out, Owner: owner}
+	if rc, ok := out.Metadata["receipt"].(map[string]any); ok {
+		c.Receipt = rc
+		c.OrderID, _ = rc["orderId"].(string)
+	}
+	e.charges.put(key, c)
+}
+
+// replayCharge returns the recorded answer for key, marked as a replay.
+func (e *PaymentAgent) replayCharge(key string, in *types.AgentMessage) (types.AgentMessage, bool) {
+	c, ok := e.charges.get(strings.TrimSpace(key))
+	if !ok {
+		return types.AgentMessage{}, false
+	}
+	out := c.Response
+	out.ID = in.ID + "-replay"
+	out.To = in.From
+	meta := map[string]any{}
+	for k, v := range c.Response.Metadata {
+		meta[k] = v
+	}
+	meta["idempotentReplay"] = true
+	out.Metadata = meta
+	e.logger.Printf("[payment][idempotency] replay key=%s order=%s", key, c.OrderID)
+	return out, true
+}
+
+// serveLookup: GET /lookup/{key}.
+func (e *PaymentAgent) serveLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/payment"), "/lookup/")
+	if key == "" || strings.Contains(key, "/") {
+		http.Error(w, "idempotency key required", http.StatusBadRequest)
+		return
+	}
+	lookup := map[string]any{"key": key, "state": lookupNotFound}
+	if c, ok := e.charges.get(key); ok {
+		if signer := kidbind.SignerDID(r); c.Owner != "" && signer != "" && !strings.EqualFold(signer, c.Owner) {
+			e.logger.Printf("[payment][lookup] key=%s denied signer=%q", key, signer)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		lookup["state"] = lookupExecuted
+		if c.OrderID != "" {
+			lookup["orderId"] = c.OrderID
+		}
+		if c.Receipt != nil {
+			lookup["receipt"] = c.Receipt
+		}
+	}
+	e.logger.Printf("[payment][lookup] key=%s state=%s", key, lookup["state"])
+	out := types.AgentMessage{
+		ID: "lookup-" + key, From: "payment", Type: "response",
+		Content: "payment status: " + lookup["state"].(string), Timestamp: time.Now(),
+		Metadata: map[string]any{"lookup": lookup},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,82 @@
+// Package payment - HPKE KID validation and binding.
+//
+// X-KID is validated (internal/kidbind) before the session lookup, and every
+// handshake records the DID that created the session. A data-mode request
+// whose signer differs from that DID gets 403 KID_DID_MISMATCH and an audit
+// hpke/kid.mismatch event; one under a KID no handshake bound gets 403
+// KID_UNBOUND (hpke/kid.unbound) whatever the verification mode. With signature verification off the signer is
+// only claimed (X-SAGE-DID), so the mismatch is logged and audited but not
+// blocked (demo mode).
+//
+// Ciphertext under a KID with no session gets 409 SESSION_NOT_READY: the
+// session may not be committed yet when the sender's first request arrives,
+// and the sender retries and then handshakes again. Counted as hpkeNotReady
+// in /status.
+package payment
+
+import (
+	"net/http"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+)
+
+// requestKID validates X-KID; false means a 400 was written.
+func (e *PaymentAgent) requestKID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	kid, err := kidbind.Normalize(r.Header.Get("X-KID"))
+	if err != nil {
+		e.logger.Printf("[payment][hpke] rejected X-KID from %s: %v", kidbind.RequestDID(r), err)
+		kidbind.WriteError(w, http.StatusBadRequest, kidbind.CodeInvalid, err.Error())
+		return "", false
+	}
+	return kid, true
+}
+
+// handshake serves an HPKE handshake and binds the new KID to its signer.
+func (e *PaymentAgent) handshake(w http.ResponseWriter, r *http.Request) {
+	did := kidbind.RequestDID(r)
+	if kid := e.kids.Handshake(e.hsrv.MessagesHandler(), w, r, did); kid != "" {
+		e.logger.Printf("[payment][hpke] kid=%s bound to %s", kid, did)
+	}
+}
+
+// checkKIDOwner enforces the binding for a data-mode request; false means
+// the response was written.
+func (e *PaymentAgent) checkKIDOwner(w http.ResponseWriter, r *http.Request, kid string) bool {
+	did := kidbind.RequestDID(r)
+	bound, ok := e.kids.Check(kid, did)
+	if ok {
+		return true
+	}
+	if bound == "" {
+		e.audit.Emit(audit.Event{
+			Type: "hpke", Action: "kid.unbound", Outcome: "denied", Actor: did,
+			Detail: map[string]any{"kid": kid},
+		})
+		e.logger.Printf("⚠️ [payment][hpke] kid=%s has no handshake binding; request from %q rejected", kid, did)
+		kidbind.WriteError(w, http.StatusForbidden, kidbind.CodeUnbound, "hpke session was not established by a handshake seen by this agent; handshake again")
+		return false
+	}
+	enforced := e.mw != nil
+	outcome := "denied"
+	if !enforced {
+		outcome = "failure"
+	}
+	e.audit.Emit(audit.Event{
+		Type: "hpke", Action: "kid.mismatch", Outcome: outcome, Actor: did,
+		Detail: map[string]any{"kid": kid, "boundDID": bound, "enforced": enforced},
+	})
+	if !enforced {
+		e.logger.Printf("[payment][hpke][warn] kid=%s belongs to %s but was used by %q (signature verification off; not blocked)", kid, bound, did)
+		return true
+	}
+	e.logger.Printf("⚠️ [payment][hpke] kid=%s belongs to %s; request signed by %s rejected", kid, bound, did)
+	kidbind.WriteError(w, http.StatusForbidden, kidbind.CodeMismatch, "hpke session belongs to another DID")
+	return false
+}
+
+// sessionNotReady answers a data-mode request under kid, which has no session.
+func (e *PaymentAgent) sessionNotReady(w http.ResponseWriter, r *http.Request, kid string) {
+	e.logger.Printf("[payment][hpke] kid=%s from %s has no session (yet); answering %s", kid, kidbind.RequestDID(r), kidbind.CodeNotReady)
+	kidbind.WriteNotReady(w, kid)
+}
@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestNormalizeMode(t *testing.T) {
+	for in, want := range map[string]string{"": ModeFull, "FULL": ModeFull, "hpke": ModeFull, " Verify ": ModeVerifyOnly, "digest": ModeVerifyOnly, "bare": ModeEcho} {
+		if m, ok := NormalizeMode(in); !ok || m != want {
+			t.Errorf("NormalizeMode(%q) = %q %v, want %q", in, m, ok, want)
+		}
+	}
+	if _, ok := NormalizeMode("fast"); ok {
+		t.Error("unknown mode accepted")
+	}
+}
+
+// verify-only without signature checks would accept anything
+func TestVerifyOnlyRequiresSignature(t *testing.T) {
+	_, err := NewPaymentAgentWithMode("verify-only", false)
+	if err == nil || !strings.Contains(err.Error(), "requireSignature=false") {
+		t.Fatalf("verify-only with require=false: %v", err)
+	}
+}
+
+// fakeVerifier stands in for the DID middleware: Content-Digest must match
+// the body and Signature must be sha256(method, path, digest). Unsigned
+// requests pass only when the verifier is optional.
+type fakeVerifier struct {
+	optional bool
+	onErr    func(http.ResponseWriter, *http.Request, error)
+}
+
+func (f fakeVerifier) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Signature") == "" {
+			if !f.optional {
+				f.onErr(w, r, errors.New("missing signature"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		d := r.Header.Get("Content-Digest")
+		if d != a2autil.ComputeContentDigest(body) || r.Header.Get("Signature") != fakeSignature(r.Method, r.URL.Path, d) {
+			f.onErr(w, r, errors.New("signature verification failed"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func fakeSignature(method, path, digest string) string {
+	sum := sha256.Sum256([]byte(method + "\n" + path + "\n" + digest))
+	return "sig1=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// Each mode against a signed request, one whose body changed after signing,
+// and an unsigned one.
+func TestModeVerification(t *testing.T) {
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("PAYMENT_FLAGS_FILE", "")
+	t.Setenv("PAYMENT_JWK_FILE", "")
+	t.Setenv("PAYMENT_KEM_JWK_FILE", "")
+	t.Setenv("LLM_PROVIDER", "none")
+	saved := buildDIDVerifier
+	t.Cleanup(func() { buildDIDVerifier = saved })
+	buildDIDVerifier = func(optional bool, logger *log.Logger) (didVerifier, error) {
+		return fakeVerifier{optional: optional, onErr: newCompactDIDErrorHandler(logger)}, nil
+	}
+
+	body, _ := json.Marshal(types.AgentMessage{ID: "m1", From: "root", To: "payment", Type: "request", Content: "ping"})
+	request := func(kind string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if kind == "unsigned" {
+			return req
+		}
+		d := a2autil.ComputeContentDigest(body)
+		req.Header.Set("Content-Digest", d)
+		req.Header.Set("Signature-Input", `sig1=("@method" "@path" "content-digest")`)
+		req.Header.Set("Signature", fakeSignature(req.Method, req.URL.Path, d))
+		if kind == "tampered" {
+			req.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("ping"), []byte("pong"), 1)))
+		}
+		return req
+	}
+
+	cases := []struct {
+		mode                       string
+		signed, tampered, unsigned int
+	}{
+		{ModeFull, http.StatusOK, http.StatusUnauthorized, http.StatusOK},
+		{ModeVerifyOnly, http.StatusOK, http.StatusUnauthorized, http.StatusUnauthorized},
+		{ModeEcho, http.StatusOK, http.StatusOK, http.StatusOK},
+	}
+	for _, tc := range cases {
+		pa, err := NewPaymentAgentWithMode(tc.mode, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for kind, want := range map[string]int{"signed": tc.signed, "tampered": tc.tampered, "unsigned": tc.unsigned} {
+			t.Run(tc.mode+"/"+kind, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				pa.Handler().ServeHTTP(w, request(kind))
+				if w.Code != want {
+					t.Fatalf("status %d, want %d: %s", w.Code, want, w.Body)
+				}
+				switch {
+				case want == http.StatusUnauthorized && !strings.Contains(w.Body.String(), `"unauthorized"`):
+					t.Fatalf("refusal body %s", w.Body)
+				case want == http.StatusOK && !strings.Contains(w.Body.String(), "(echo)"):
+					t.Fatalf("response %s", w.Body)
+				}
+			})
+		}
+	}
+}
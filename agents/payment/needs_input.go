@@ -0,0 +1,137 @@
+// Package payment - needs-input requests (card last 4 digits).
+//
+// Merchants listed in PAYMENT_LAST4_MERCHANTS (comma-separated, matched
+// case-insensitively against payment.merchant or the recipient) require the
+// card's last 4 digits. A card payment for such a merchant without a valid
+// payment.cardLast4 is answered with Type "clarify" and
+// metadata.needsInput {requestedFields, resumeToken} instead of failing or
+// guessing. Root asks the user and re-sends the original payload with the
+// field and needsInput.resumeToken; issued tokens are kept for 10 minutes so
+// the resume can be correlated in logs.
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+const inputAskTTL = 10 * time.Minute
+
+var last4Re = regexp.MustCompile(`^\d{4}$`)
+
+type inputAskStore struct {
+	mu sync.Mutex
+	m  map[string]time.Time // resumeToken -> issued
+}
+
+func (s *inputAskStore) issue() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for k, at := range s.m {
+		if now.Sub(at) > inputAskTTL {
+			delete(s.m, k)
+		}
+	}
+	tok := "ni-" + uuid.NewString()
+	s.m[tok] = now
+	return tok
+}
+
+// resolve consumes tok; false when it was never issued or has expired.
+func (s *inputAskStore) resolve(tok string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at, ok := s.m[tok]
+	delete(s.m, tok)
+	return ok && time.Since(at) <= inputAskTTL
+}
+
+// last4Merchant reports whether merchant or recipient is in PAYMENT_LAST4_MERCHANTS.
+func last4Merchant(merchant, to string) bool {
+	merchant, to = strings.ToLower(strings.TrimSpace(merchant)), strings.ToLower(strings.TrimSpace(to))
+	for _, m := range strings.Split(os.Getenv("PAYMENT_LAST4_MERCHANTS"), ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if m != "" && (m == merchant || m == to) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCardMethod(method string) bool {
+	low := strings.ToLower(method)
+	return strings.Contains(low, "card") || strings.Contains(low, "카드") ||
+		strings.Contains(low, "credit") || strings.Contains(low, "debit")
+}
+
+// askCardLast4 returns a needs-input response when the merchant requires the
+// card's last 4 digits and the request does not carry them; nil otherwise.
+func (e *PaymentAgent) askCardLast4(msg *transport.SecureMessage, in *types.AgentMessage, lang, method, to string) *transport.Response {
+	if tok := getMetaString(in.Metadata, "needsInput.resumeToken"); tok != "" {
+		if e.inputAsks.resolve(tok) {
+			e.logger.Printf("[payment][needs-input] resume token=%s round=%d", tok, getMetaInt64(in.Metadata, "needsInput.round"))
+		} else {
+			e.logger.Printf("[payment][needs-input] unknown or expired resume token=%s", tok)
+		}
+	}
+	merchant := getMetaString(in.Metadata, "payment.merchant", "merchant")
+	if !isCardMethod(method) || !last4Merchant(merchant, to) {
+		return nil
+	}
+	last4 := strings.TrimSpace(getMetaString(in.Metadata, "payment.cardLast4", "cardLast4"))
+	if last4Re.MatchString(last4) {
+		return nil
+	}
+
+	name := firstNonEmpty(merchant, to)
+	reason := map[string]string{
+		"ko": "가맹점 카드 확인",
+		"en": "merchant card verification",
+	}[lang]
+	content := map[string]string{
+		"ko": fmt.Sprintf("%s 결제는 카드 확인이 필요해요. 결제할 카드 번호의 마지막 4자리를 알려 주세요.", name),
+		"en": fmt.Sprintf("%s requires card verification. Please tell me the last 4 digits of the card.", name),
+	}[lang]
+	if last4 != "" {
+		content = map[string]string{
+			"ko": "카드 뒷자리는 숫자 4자리여야 해요. ",
+			"en": "The card's last digits must be exactly 4 numbers. ",
+		}[lang] + content
+	}
+	tok := e.inputAsks.issue()
+	e.logger.Printf("[payment][needs-input] merchant=%q requires cardLast4; asking (token=%s)", name, tok)
+
+	out := types.AgentMessage{
+		ID:        in.ID + "-needinput",
+		From:      "payment",
+		To:        in.From,
+		Type:      "clarify",
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"needsInput": map[string]any{
+				"requestedFields": []map[string]any{
+					{"name": "payment.cardLast4", "type": "digits", "length": 4, "reason": reason},
+				},
+				"resumeToken": tok,
+			},
+		},
+	}
+	b, _ := json.Marshal(out)
+	return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}
+}
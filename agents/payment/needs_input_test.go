@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// A card payment to a listed merchant asks for the last 4 digits until they
+// are valid; the resume token is issued once and consumed by the resend.
+func TestAskCardLast4(t *testing.T) {
+	t.Setenv("PAYMENT_LAST4_MERCHANTS", "애플스토어, Coupang")
+	e := &PaymentAgent{logger: log.New(io.Discard, "", 0)}
+	ask := func(meta map[string]any, method, to string) *types.AgentMessage {
+		t.Helper()
+		in := &types.AgentMessage{ID: "m1", From: "root", Metadata: meta}
+		resp := e.askCardLast4(&transport.SecureMessage{ID: "m1"}, in, "ko", method, to)
+		if resp == nil {
+			return nil
+		}
+		var out types.AgentMessage
+		_ = json.Unmarshal(resp.Data, &out)
+		return &out
+	}
+
+	if ask(map[string]any{}, "kakaopay", "애플스토어") != nil || ask(map[string]any{}, "card", "쿠팡") != nil {
+		t.Fatal("asked outside a card payment to a listed merchant")
+	}
+	out := ask(map[string]any{"payment.merchant": "coupang"}, "신용카드", "")
+	if out == nil || out.Type != "clarify" {
+		t.Fatalf("no question: %+v", out)
+	}
+	ni, _ := out.Metadata["needsInput"].(map[string]any)
+	tok, _ := ni["resumeToken"].(string)
+	fields, _ := ni["requestedFields"].([]any)
+	if tok == "" || len(fields) != 1 || fields[0].(map[string]any)["name"] != "payment.cardLast4" {
+		t.Fatalf("needsInput %v", ni)
+	}
+	if out := ask(map[string]any{"payment.cardLast4": "12a4"}, "card", "애플스토어"); out == nil {
+		t.Fatal("malformed digits accepted")
+	}
+	if out := ask(map[string]any{"payment.cardLast4": "1234", "needsInput.resumeToken": tok}, "card", "애플스토어"); out != nil {
+		t.Fatalf("asked again with valid digits: %+v", out)
+	}
+	if e.inputAsks.resolve(tok) {
+		t.Fatal("resume token not consumed")
+	}
+}
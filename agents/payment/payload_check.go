@@ -0,0 +1,44 @@
+// Package payment - payload invariants after decryption.
+//
+// Every /process body, decrypted or plain, goes through internal/payloadcheck
+// before it is decoded: a double-encoded message, an encoded message inside
+// content or a gateway _gw_tamper artifact is logged with the hop trace and
+// audited as security/payload.invariant. With STRICT_PAYLOAD on it is
+// answered 400 instead of processed. When the request carries
+// X-SAGE-Payload-Trace the agent appends its fingerprint and echoes the trace
+// in the response.
+package payment
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+)
+
+// checkPayload runs the invariants on body; false means a 400 was written.
+func (e *PaymentAgent) checkPayload(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	trace := r.Header.Get(payloadcheck.TraceHeader)
+	if trace != "" {
+		trace = payloadcheck.AppendTrace(trace, "payment", payloadcheck.Fingerprint(body))
+		w.Header().Set(payloadcheck.TraceHeader, trace)
+	}
+	fs := payloadcheck.Inspect(body)
+	if len(fs) == 0 {
+		return true
+	}
+	strict := payloadcheck.Strict()
+	did := kidbind.RequestDID(r)
+	e.logger.Printf("⚠️ [payment][payload] invariant violated: %s from=%s hpke=%v strict=%v trace=%q", fs, did, isHPKE(r), strict, trace)
+	e.audit.Emit(audit.Event{
+		Type: "security", Action: "payload.invariant", Outcome: "failure", Actor: did,
+		Detail: map[string]any{"findings": fs, "hpke": isHPKE(r), "trace": trace, "rejected": strict},
+	})
+	if !strict {
+		return true
+	}
+	http.Error(w, "payload invariant violated: "+strings.Join(fs.Codes(), ","), http.StatusBadRequest)
+	return false
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+)
+
+// The agent appends its hop to an incoming trace and, only under
+// STRICT_PAYLOAD, refuses a body that breaks an invariant.
+func TestCheckPayload(t *testing.T) {
+	e := &PaymentAgent{logger: log.New(io.Discard, "", 0)}
+	sound := []byte(`{"id":"m1","from":"root","to":"payment","type":"request","content":"pay"}`)
+	encoded := []byte(`"{\"id\":\"m1\",\"from\":\"root\",\"type\":\"request\",\"content\":\"pay\"}"`)
+
+	check := func(body []byte, trace string) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(http.MethodPost, "/process", nil)
+		if trace != "" {
+			req.Header.Set(payloadcheck.TraceHeader, trace)
+		}
+		w := httptest.NewRecorder()
+		return w, e.checkPayload(w, req, body)
+	}
+
+	w, ok := check(sound, "root=0badf00d/72")
+	if want := "root=0badf00d/72, payment=" + payloadcheck.Fingerprint(sound); !ok || w.Header().Get(payloadcheck.TraceHeader) != want {
+		t.Fatalf("trace %q ok=%v", w.Header().Get(payloadcheck.TraceHeader), ok)
+	}
+	if w, _ := check(sound, ""); w.Header().Get(payloadcheck.TraceHeader) != "" {
+		t.Fatal("trace echoed without a request trace")
+	}
+
+	t.Setenv("STRICT_PAYLOAD", "")
+	if _, ok := check(encoded, ""); !ok {
+		t.Fatal("lenient mode rejected")
+	}
+	t.Setenv("STRICT_PAYLOAD", "true")
+	w, ok = check(encoded, "")
+	if ok || w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), payloadcheck.DoubleEncoded) {
+		t.Fatalf("strict: ok=%v %d %q", ok, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,41 @@
+// Package payment - response post-processing hooks.
+//
+// Every answer of the application handler passes the "payment" hook chain
+// (internal/posthook, POSTHOOKS_FILE or compiled-in registrations) before
+// /process HPKE-encrypts or writes it, e.g. to rewrite merchant names on
+// receipts to internal codes. When a fail-closed hook fails the answer is
+// replaced by a posthook_failed error; an idempotent replay runs the hooks
+// again.
+package payment
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// appHandler runs handleApp, stamps the field-encryption report (fieldenc.go)
+// and applies the hooks.
+func (e *PaymentAgent) appHandler(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
+	ctx, rep := fieldenc.WithReport(ctx)
+	resp, err := e.handleApp(ctx, msg)
+	if err != nil || resp == nil || !resp.Success || (e.hooks.Len() == 0 && rep.Empty()) {
+		return resp, err
+	}
+	var out types.AgentMessage
+	if json.Unmarshal(resp.Data, &out) != nil {
+		return resp, nil
+	}
+	out.Metadata = rep.Stamp(out.Metadata)
+	if results, herr := e.hooks.Apply(ctx, msg.ContextID, &out); herr != nil {
+		out = posthook.FailureMessage(&out, results, herr)
+	}
+	if b, err := json.Marshal(out); err == nil {
+		resp.Data = b
+	}
+	return resp, nil
+}
@@ -0,0 +1,248 @@
+// Package payment - downloadable HTML receipts.
+//
+// Each issued receipt is also rendered as a standalone HTML document (ko/en)
+// with the itemized charge, order ID, timestamps (in the receipt's
+// "timezone", with the UTC instant alongside) and the receipt's content
+// digest (sha-256 over the receipt JSON without its "digest" and "receiptUrl"
+// keys) printed as an integrity line that can be fed to a QR encoder.
+// Documents live in memory for PAYMENT_RECEIPT_TTL_SEC (default 3600) and
+// are served from GET /payment/receipts/{orderId}, which
+// requires an RFC 9421 signature from the payer DID: metadata
+// "payment.payerDID" when Root sends one, else the DID that signed the
+// payment request. The receipt metadata returned to Root carries receiptUrl;
+// PAYMENT_PUBLIC_URL sets the advertised base. No pure-Go PDF or QR renderer
+// is vendored, so the artifact is HTML only.
+package payment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+)
+
+type receiptDoc struct {
+	html     []byte
+	digest   string
+	ownerDID string
+	expires  time.Time
+}
+
+type receiptDocStore struct {
+	mu sync.Mutex
+	m  map[string]receiptDoc
+}
+
+func receiptDocTTL() time.Duration {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PAYMENT_RECEIPT_TTL_SEC"))); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return time.Hour
+}
+
+func (s *receiptDocStore) put(orderID string, d receiptDoc) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]receiptDoc)
+	}
+	for id, old := range s.m {
+		if now.After(old.expires) {
+			delete(s.m, id)
+		}
+	}
+	s.m[orderID] = d
+}
+
+func (s *receiptDocStore) get(orderID string) (receiptDoc, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.m[orderID]
+	if !ok || time.Now().After(d.expires) {
+		delete(s.m, orderID)
+		return receiptDoc{}, false
+	}
+	return d, true
+}
+
+// receiptDigest is the Content-Digest style sha-256 of the receipt JSON (keys sorted).
+func receiptDigest(receipt map[string]any) string {
+	b, _ := json.Marshal(receipt)
+	sum := sha256.Sum256(b)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+type receiptLine struct {
+	Label  string
+	Amount string
+}
+
+type receiptView struct {
+	Lang, Title, OrderLabel, OrderID string
+	IssuedLabel, Issued              string
+	ExpiresLabel, Expires            string
+	Fields                           []receiptLine
+	Lines                            []receiptLine
+	TotalLabel, Total                string
+	DigestLabel, Digest, QRText      string
+}
+
+var receiptLabels = map[string]map[string]string{
+	"ko": {
+		"title": "결제 영수증", "order": "주문번호", "issued": "발행 시각", "expires": "열람 기한",
+		"to": "수신자", "method": "결제수단", "memo": "메모", "item": "품목",
+		"estimated": "확정 당시 예상 금액", "quoted": "재견적 금액", "total": "결제 금액", "digest": "무결성 다이제스트",
+	},
+	"en": {
+		"title": "Payment receipt", "order": "Order ID", "issued": "Issued", "expires": "Available until",
+		"to": "Recipient", "method": "Method", "memo": "Memo", "item": "Item",
+		"estimated": "Estimate at confirmation", "quoted": "Re-quoted amount", "total": "Amount charged", "digest": "Integrity digest",
+	},
+}
+
+var receiptTmpl = template.Must(template.New("receipt").Parse(`<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.OrderID}}</title>
+<style>
+body{font-family:system-ui,-apple-system,"Noto Sans KR",sans-serif;max-width:640px;margin:2rem auto;color:#222}
+h1{font-size:1.4rem;border-bottom:2px solid #222;padding-bottom:.4rem}
+table{width:100%;border-collapse:collapse;margin:1rem 0}
+td{padding:.35rem .2rem;border-bottom:1px solid #ddd}
+td.amt{text-align:right;white-space:nowrap}
+tr.total td{font-weight:bold;border-top:2px solid #222}
+.meta td:first-child{color:#666;width:40%}
+.digest{font-family:ui-monospace,monospace;font-size:.8rem;word-break:break-all;background:#f5f5f5;padding:.5rem}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table class="meta">
+<tr><td>{{.OrderLabel}}</td><td>{{.OrderID}}</td></tr>
+<tr><td>{{.IssuedLabel}}</td><td>{{.Issued}}</td></tr>
+<tr><td>{{.ExpiresLabel}}</td><td>{{.Expires}}</td></tr>
+{{range .Fields}}<tr><td>{{.Label}}</td><td>{{.Amount}}</td></tr>
+{{end}}</table>
+<table>
+{{range .Lines}}<tr><td>{{.Label}}</td><td class="amt">{{.Amount}}</td></tr>
+{{end}}<tr class="total"><td>{{.TotalLabel}}</td><td class="amt">{{.Total}}</td></tr>
+</table>
+<p>{{.DigestLabel}}</p>
+<p class="digest" data-qr="{{.QRText}}">{{.Digest}}</p>
+</body>
+</html>
+`))
+
+// renderReceiptHTML renders receipt (the map returned to Root) in lang.
+func renderReceiptHTML(lang string, receipt map[string]any, digest string, issued, expires time.Time) ([]byte, error) {
+	if lang != "en" {
+		lang = "ko"
+	}
+	l := receiptLabels[lang]
+	str := func(k string) string { s, _ := receipt[k].(string); return strings.TrimSpace(s) }
+	num := func(k string) int64 {
+		switch v := receipt[k].(type) {
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+		return 0
+	}
+	orderID := str("orderId")
+	loc, _ := tz.Resolve(str("timezone"))
+	v := receiptView{
+		Lang: lang, Title: l["title"], OrderLabel: l["order"], OrderID: orderID,
+		IssuedLabel: l["issued"], Issued: tz.Dual(issued, loc),
+		ExpiresLabel: l["expires"], Expires: tz.Dual(expires, loc),
+		TotalLabel: l["total"], Total: money.Format(lang, num("amountKRW"), money.KRW),
+		DigestLabel: l["digest"], Digest: digest,
+		QRText: "sage-receipt:" + orderID + ":" + digest,
+	}
+	for _, k := range []string{"to", "method", "memo"} {
+		if s := str(k); s != "" {
+			v.Fields = append(v.Fields, receiptLine{Label: l[k], Amount: s})
+		}
+	}
+	v.Lines = append(v.Lines, receiptLine{Label: firstNonEmpty(str("item"), l["item"]), Amount: money.Format(lang, num("amountKRW"), money.KRW)})
+	if est := num("estimatedKRW"); est > 0 {
+		v.Lines = append(v.Lines, receiptLine{Label: l["estimated"], Amount: money.Format(lang, est, money.KRW)})
+	}
+	if q := num("quotedKRW"); q > 0 {
+		v.Lines = append(v.Lines, receiptLine{Label: l["quoted"], Amount: money.Format(lang, q, money.KRW)})
+	}
+	var buf bytes.Buffer
+	if err := receiptTmpl.Execute(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// publicBase is the advertised base URL for receipt links.
+func (e *PaymentAgent) publicBase() string {
+	if v := strings.TrimRight(strings.TrimSpace(os.Getenv("PAYMENT_PUBLIC_URL")), "/"); v != "" {
+		return v
+	}
+	_, port, err := net.SplitHostPort(e.Addr())
+	if err != nil {
+		return ""
+	}
+	return "http://localhost:" + port
+}
+
+// storeReceiptDoc renders and stores the document for receipt and returns its URL.
+func (e *PaymentAgent) storeReceiptDoc(lang, ownerDID string, receipt map[string]any) string {
+	orderID, _ := receipt["orderId"].(string)
+	digest := receiptDigest(receipt)
+	now := time.Now()
+	exp := now.Add(receiptDocTTL())
+	html, err := renderReceiptHTML(lang, receipt, digest, now, exp)
+	if err != nil {
+		e.logger.Printf("[payment][receipt-doc] order=%s render: %v", orderID, err)
+		return ""
+	}
+	e.receiptDocs.put(orderID, receiptDoc{html: html, digest: digest, ownerDID: ownerDID, expires: exp})
+	receipt["digest"] = digest
+	return e.publicBase() + "/payment/receipts/" + orderID
+}
+
+// serveReceiptDoc: GET /payment/receipts/{orderId}.
+func (e *PaymentAgent) serveReceiptDoc(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if e.mw == nil {
+		http.Error(w, "signature verification unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	orderID := strings.TrimPrefix(r.URL.Path, "/payment/receipts/")
+	d, ok := e.receiptDocs.get(orderID)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if signer := kidbind.SignerDID(r); signer == "" || !strings.EqualFold(signer, d.ownerDID) {
+		e.logger.Printf("[payment][receipt-doc] order=%s denied signer=%q", orderID, signer)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Receipt-Digest", d.digest)
+	_, _ = w.Write(d.html)
+}
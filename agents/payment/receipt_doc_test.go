@@ -0,0 +1,104 @@
+package payment
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func receiptGet(e *PaymentAgent, orderID, signer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/payment/receipts/"+orderID, nil)
+	if signer != "" {
+		req.Header.Set("Signature-Input", `sig1=("@method" "@path");created=1;keyid="`+signer+`"`)
+	}
+	w := httptest.NewRecorder()
+	e.serveReceiptDoc(w, req)
+	return w
+}
+
+// Only the payer DID gets the document, as private HTML with its digest.
+func TestReceiptDocOwner(t *testing.T) {
+	t.Setenv("PAYMENT_PUBLIC_URL", "https://pay.example/")
+	e := &PaymentAgent{logger: log.New(io.Discard, "", 0), mw: fakeVerifier{}}
+	receipt := map[string]any{"orderId": "ORD-1", "to": "Alice", "method": "card", "item": "MacBook", "amountKRW": int64(1250000), "timezone": "Asia/Seoul"}
+	url := e.storeReceiptDoc("en", "did:sage:test:alice", receipt)
+	if url != "https://pay.example/payment/receipts/ORD-1" {
+		t.Fatalf("url %q", url)
+	}
+	digest, _ := receipt["digest"].(string)
+	delete(receipt, "digest")
+	if digest == "" || digest != receiptDigest(receipt) {
+		t.Fatalf("digest %q does not cover the receipt without itself", digest)
+	}
+
+	w := receiptGet(e, "ORD-1", "did:sage:test:Alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("owner: %d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("content type %q", ct)
+	}
+	if w.Header().Get("Cache-Control") != "private, no-store" || w.Header().Get("X-Receipt-Digest") != digest {
+		t.Fatalf("headers %v", w.Header())
+	}
+	body := w.Body.String()
+	for _, s := range []string{`<html lang="en">`, "ORD-1", "₩1,250,000", "MacBook", `<p class="digest" data-qr="sage-receipt:ORD-1:sha-256=:`} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("document lacks %q:\n%s", s, body)
+		}
+	}
+
+	for signer, want := range map[string]int{"did:sage:test:mallory": http.StatusForbidden, "": http.StatusForbidden} {
+		if w := receiptGet(e, "ORD-1", signer); w.Code != want {
+			t.Fatalf("signer %q: %d", signer, w.Code)
+		}
+	}
+	if w := receiptGet(e, "ORD-404", "did:sage:test:alice"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown order: %d", w.Code)
+	}
+	e.mw = nil
+	if w := receiptGet(e, "ORD-1", "did:sage:test:alice"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without verification: %d", w.Code)
+	}
+}
+
+// Documents expire after PAYMENT_RECEIPT_TTL_SEC and are swept on the next put.
+func TestReceiptDocTTL(t *testing.T) {
+	for env, want := range map[string]time.Duration{"": time.Hour, "90": 90 * time.Second, "0": time.Hour, "x": time.Hour} {
+		t.Setenv("PAYMENT_RECEIPT_TTL_SEC", env)
+		if got := receiptDocTTL(); got != want {
+			t.Errorf("TTL %q: %v, want %v", env, got, want)
+		}
+	}
+
+	var s receiptDocStore
+	s.put("ORD-OLD", receiptDoc{ownerDID: "did:a", expires: time.Now().Add(-time.Second)})
+	s.put("ORD-NEW", receiptDoc{ownerDID: "did:a", expires: time.Now().Add(time.Minute)})
+	if _, ok := s.m["ORD-OLD"]; ok {
+		t.Fatal("expired document not swept")
+	}
+	if _, ok := s.get("ORD-NEW"); !ok {
+		t.Fatal("live document missing")
+	}
+	s.m["ORD-NEW"] = receiptDoc{expires: time.Now().Add(-time.Second)}
+	if _, ok := s.get("ORD-NEW"); ok || len(s.m) != 0 {
+		t.Fatalf("expired document served: %v", s.m)
+	}
+}
+
+func TestReceiptDocKorean(t *testing.T) {
+	at := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	html, err := renderReceiptHTML("ko", map[string]any{"orderId": "ORD-2", "amountKRW": float64(50000), "estimatedKRW": float64(48000), "timezone": "Asia/Seoul"}, "sha-256=:x:", at, at.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`<html lang="ko">`, "결제 영수증", "50,000원", "확정 당시 예상 금액", "48,000원", "2026-03-01"} {
+		if !strings.Contains(string(html), s) {
+			t.Fatalf("document lacks %q:\n%s", s, html)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Without an LLM the receipt line is the template, with the amount rendered
+// through internal/money for the reader's locale.
+func TestReceiptTemplate(t *testing.T) {
+	e := &PaymentAgent{}
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("KST", 9*3600))
+	cases := map[string]string{
+		"ko": "영수증: 수신자=홍길동, 금액=1,250,000원, 방법=카드, 품목=맥북, 메모=생일선물 · 2026-03-01T12:30:00+09:00",
+		"en": "Receipt: to=Alice, amount=₩1,250,000 (approx. $926), method=card, item=MacBook · 2026-03-01T12:30:00+09:00",
+	}
+	if got := e.generateReceipt(context.Background(), "ko", at, "홍길동", 1250000, "card", "맥북", "생일선물"); got != cases["ko"] {
+		t.Errorf("ko:\n got %q\nwant %q", got, cases["ko"])
+	}
+	if got := e.generateReceipt(context.Background(), "en", at, "Alice", 1250000, "CARD", "MacBook", ""); got != cases["en"] {
+		t.Errorf("en:\n got %q\nwant %q", got, cases["en"])
+	}
+}
+
+// A dry run states the amount in the same format the receipt would use.
+func TestDryRunAmount(t *testing.T) {
+	e := &PaymentAgent{}
+	for lang, want := range map[string]string{
+		"ko": "DRY RUN: would pay 300,000원 to 애플스토어 via card",
+		"en": "DRY RUN: would pay ₩300,000 to 애플스토어 via card",
+	} {
+		in, _ := json.Marshal(types.AgentMessage{ID: "m1", Content: "맥북", Metadata: map[string]any{
+			"payment.amountKRW": 300000, "payment.to": "애플스토어", "payment.method": "card", "payment.dryRun": true, "lang": lang,
+		}})
+		resp, err := e.handleApp(context.Background(), &transport.SecureMessage{ID: "m1", Payload: in, Metadata: map[string]string{}})
+		if err != nil || !resp.Success {
+			t.Fatalf("%s: %v %+v", lang, err, resp)
+		}
+		var out types.AgentMessage
+		_ = json.Unmarshal(resp.Data, &out)
+		if out.Content != want {
+			t.Errorf("%s: %q, want %q", lang, out.Content, want)
+		}
+	}
+}
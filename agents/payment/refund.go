@@ -0,0 +1,205 @@
+// Package payment - issued receipts and refunds.
+//
+// Every receipt the agent issues is kept in memory keyed by orderId so a later
+// refund (metadata payment.op:"refund" + payment.orderId) can be validated
+// against it. Refunds of unknown orders return 404, refunds of already
+// refunded orders or above the original amount return 409; all errors carry
+// metadata {"error":{"code":...},"httpStatus":...} for Root to map.
+//
+// GET /orders/{orderId} (also /payment/orders/{orderId}) returns the order
+// record — charged amount, receipt and refund state — for Root's
+// reconciliation ledger:
+//
+//	{"type":"response","metadata":{"order":{"orderId":…,"state":"found","amountKRW":…,"refunded":false,"receipt":{…}}}}
+//	{"type":"response","metadata":{"order":{"orderId":…,"state":"not_found"}}}
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+const (
+	refundOrderNotFound  = "order_not_found"
+	refundAlreadyDone    = "already_refunded"
+	refundExceedsOrigin  = "refund_exceeds_original"
+	refundMissingOrderID = "order_id_required"
+)
+
+type receiptRecord struct {
+	Receipt    map[string]any
+	AmountKRW  int64
+	RefundedAt time.Time
+	RefundID   string
+	RefundKRW  int64
+}
+
+type receiptStore struct {
+	mu sync.Mutex
+	m  map[string]*receiptRecord
+}
+
+func newOrderID() string {
+	return "ORD-" + strings.ToUpper(uuid.NewString()[:8])
+}
+
+func (s *receiptStore) put(orderID string, amount int64, receipt map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*receiptRecord)
+	}
+	s.m[orderID] = &receiptRecord{Receipt: receipt, AmountKRW: amount}
+}
+
+// refund validates and marks orderID refunded. amount <= 0 refunds the full amount.
+func (s *receiptStore) refund(orderID string, amount int64) (*receiptRecord, int64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.m[orderID]
+	if !ok {
+		return nil, 0, refundOrderNotFound
+	}
+	if !rec.RefundedAt.IsZero() {
+		return rec, 0, refundAlreadyDone
+	}
+	if amount <= 0 {
+		amount = rec.AmountKRW
+	}
+	if amount > rec.AmountKRW {
+		return rec, 0, refundExceedsOrigin
+	}
+	rec.RefundedAt = time.Now()
+	rec.RefundID = "RFD-" + strings.ToUpper(uuid.NewString()[:8])
+	rec.RefundKRW = amount
+	return rec, amount, ""
+}
+
+// get returns a copy of orderID's record.
+func (s *receiptStore) get(orderID string) (receiptRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.m[orderID]
+	if !ok {
+		return receiptRecord{}, false
+	}
+	return *rec, true
+}
+
+// serveOrder: GET /orders/{orderId}.
+func (e *PaymentAgent) serveOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orderID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/payment"), "/orders/")
+	if orderID == "" || strings.Contains(orderID, "/") {
+		http.Error(w, "order id required", http.StatusBadRequest)
+		return
+	}
+	order := map[string]any{"orderId": orderID, "state": "not_found"}
+	if rec, ok := e.receipts.get(orderID); ok {
+		order["state"] = "found"
+		order["amountKRW"] = rec.AmountKRW
+		order["receipt"] = rec.Receipt
+		order["refunded"] = !rec.RefundedAt.IsZero()
+		if !rec.RefundedAt.IsZero() {
+			order["refundId"] = rec.RefundID
+			order["refundKRW"] = rec.RefundKRW
+			order["refundedAt"] = rec.RefundedAt.UTC().Format(time.RFC3339)
+		}
+	}
+	e.logger.Printf("[payment][orders] order=%s state=%s", orderID, order["state"])
+	out := types.AgentMessage{
+		ID: "order-" + orderID, From: "payment", Type: "response",
+		Content: "order " + orderID + ": " + order["state"].(string), Timestamp: time.Now(),
+		Metadata: map[string]any{"order": order},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func refundErrStatus(code string) int {
+	switch code {
+	case refundOrderNotFound:
+		return http.StatusNotFound
+	case refundMissingOrderID:
+		return http.StatusBadRequest
+	}
+	return http.StatusConflict
+}
+
+// handleRefund serves payment.op:"refund".
+func (e *PaymentAgent) handleRefund(msg *transport.SecureMessage, in *types.AgentMessage, lang string) (*transport.Response, error) {
+	orderID := strings.TrimSpace(getMetaString(in.Metadata, "payment.orderId", "orderId"))
+	want := getMetaInt64(in.Metadata, "payment.refundKRW", "refundKRW")
+
+	code := refundMissingOrderID
+	var (
+		rec    *receiptRecord
+		amount int64
+	)
+	if orderID != "" {
+		rec, amount, code = e.receipts.refund(orderID, want)
+	}
+
+	var out types.AgentMessage
+	if code != "" {
+		e.logger.Printf("[payment][refund] order=%q rejected: %s", orderID, code)
+		errMeta := map[string]any{"code": code, "orderId": orderID}
+		if rec != nil && code == refundAlreadyDone {
+			errMeta["refundId"] = rec.RefundID
+			errMeta["refundedAt"] = rec.RefundedAt.UTC().Format(time.RFC3339)
+		}
+		if rec != nil && code == refundExceedsOrigin {
+			errMeta["originalKRW"] = rec.AmountKRW
+		}
+		out = types.AgentMessage{
+			ID:        in.ID + "-refund-error",
+			From:      "payment",
+			To:        in.From,
+			Type:      "error",
+			Content:   fmt.Sprintf("refund rejected: %s (%s)", code, firstNonEmpty(orderID, "-")),
+			Timestamp: time.Now(),
+			Metadata:  map[string]any{"error": errMeta, "httpStatus": refundErrStatus(code)},
+		}
+	} else {
+		e.logger.Printf("[payment][refund] order=%s refunded %d KRW (%s)", orderID, amount, rec.RefundID)
+		out = types.AgentMessage{
+			ID:   in.ID + "-refund",
+			From: "payment",
+			To:   in.From,
+			Type: "response",
+			Content: map[string]string{
+				"ko": fmt.Sprintf("주문 %s 환불 완료: %s", orderID, money.Format(lang, amount, money.KRW)),
+				"en": fmt.Sprintf("Refunded order %s: %s", orderID, money.Format(lang, amount, money.KRW)),
+			}[lang],
+			Timestamp: time.Now(),
+			Metadata: map[string]any{
+				"refund": map[string]any{
+					"refundId":    rec.RefundID,
+					"orderId":     orderID,
+					"amountKRW":   amount,
+					"originalKRW": rec.AmountKRW,
+					"partial":     amount < rec.AmountKRW,
+					"refundedAt":  rec.RefundedAt.UTC().Format(time.RFC3339),
+					"amount":      money.Rendered(lang, amount, money.KRW),
+				},
+			},
+		}
+	}
+	b, _ := json.Marshal(out)
+	return &transport.Response{Success: true, MessageID: msg.ID, TaskID: msg.TaskID, Data: b}, nil
+}
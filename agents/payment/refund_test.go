@@ -0,0 +1,82 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func refund(t *testing.T, e *PaymentAgent, meta map[string]any) types.AgentMessage {
+	t.Helper()
+	meta["payment.op"] = "refund"
+	meta["lang"] = "en"
+	in, _ := json.Marshal(types.AgentMessage{ID: "r1", From: "root", Metadata: meta})
+	resp, err := e.handleApp(context.Background(), &transport.SecureMessage{ID: "r1", Payload: in, Metadata: map[string]string{}})
+	if err != nil || !resp.Success {
+		t.Fatalf("refund: %v %+v", err, resp)
+	}
+	var out types.AgentMessage
+	if err := json.Unmarshal(resp.Data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// rejection returns the error code and HTTP status of a refused refund.
+func rejection(out types.AgentMessage) (string, float64) {
+	em, _ := out.Metadata["error"].(map[string]any)
+	code, _ := em["code"].(string)
+	status, _ := out.Metadata["httpStatus"].(float64)
+	return code, status
+}
+
+func TestRefund(t *testing.T) {
+	e := &PaymentAgent{logger: log.New(io.Discard, "", 0)}
+	e.receipts.put("ORD-FULL", 300000, map[string]any{"orderId": "ORD-FULL"})
+	e.receipts.put("ORD-PART", 100000, map[string]any{"orderId": "ORD-PART"})
+
+	if code, status := rejection(refund(t, e, map[string]any{"payment.orderId": "ORD-NOPE"})); code != refundOrderNotFound || status != http.StatusNotFound {
+		t.Fatalf("unknown order: %s %v", code, status)
+	}
+	if code, status := rejection(refund(t, e, map[string]any{})); code != refundMissingOrderID || status != http.StatusBadRequest {
+		t.Fatalf("no order id: %s %v", code, status)
+	}
+
+	out := refund(t, e, map[string]any{"payment.orderId": "ORD-FULL"})
+	rf, _ := out.Metadata["refund"].(map[string]any)
+	if out.Type != "response" || rf["amountKRW"] != float64(300000) || rf["partial"] != false || out.Content != "Refunded order ORD-FULL: ₩300,000" {
+		t.Fatalf("full refund: %+v", out)
+	}
+	again := refund(t, e, map[string]any{"payment.orderId": "ORD-FULL"})
+	if code, status := rejection(again); code != refundAlreadyDone || status != http.StatusConflict {
+		t.Fatalf("second refund: %s %v", code, status)
+	}
+	if em := again.Metadata["error"].(map[string]any); em["refundId"] != rf["refundId"] {
+		t.Fatalf("second refund names %v, first was %v", em["refundId"], rf["refundId"])
+	}
+
+	over := refund(t, e, map[string]any{"payment.orderId": "ORD-PART", "payment.refundKRW": 150000})
+	if code, status := rejection(over); code != refundExceedsOrigin || status != http.StatusConflict || over.Metadata["error"].(map[string]any)["originalKRW"] != float64(100000) {
+		t.Fatalf("over-refund: %+v", over)
+	}
+	part := refund(t, e, map[string]any{"payment.orderId": "ORD-PART", "payment.refundKRW": 40000})
+	if rf, _ := part.Metadata["refund"].(map[string]any); rf["amountKRW"] != float64(40000) || rf["partial"] != true {
+		t.Fatalf("partial refund: %+v", part)
+	}
+
+	// the order record shows the refund
+	w := httptest.NewRecorder()
+	e.serveOrder(w, httptest.NewRequest(http.MethodGet, "/payment/orders/ORD-PART", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"refunded":true`) || !strings.Contains(body, `"refundKRW":40000`) {
+		t.Fatalf("order record: %s", body)
+	}
+}
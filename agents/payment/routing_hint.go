@@ -0,0 +1,13 @@
+package payment
+
+import "github.com/sage-x-project/sage-multi-agent/internal/selfid"
+
+// routingHint is advertised in /status so Root's LLM router knows what this
+// agent handles; PAYMENT_ROUTING_HINT_FILE replaces it with a JSON file.
+var routingHint = selfid.RoutingHint{
+	Description: "Sends money and pays for purchases: transfers, card or wallet payments, refunds and receipts of earlier payments.",
+	Examples: map[string][]string{
+		"ko": {"엄마한테 5만원 보내줘", "아이폰 15 카드로 결제해줘", "지난번 결제 영수증 보여줘"},
+		"en": {"Send 50,000 won to my mom", "Buy an iPhone 15 with my card", "Show the receipt for my last payment"},
+	},
+}
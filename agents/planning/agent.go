@@ -14,7 +14,7 @@ import (
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
 	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 
 	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
 	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
@@ -38,6 +38,9 @@ type PlanningAgent struct {
 	httpClient *http.Client
 
 	hotels []Hotel
+
+	// Debug HTTP server (server.go)
+	srv server
 }
 
 type Hotel struct {
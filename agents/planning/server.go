@@ -0,0 +1,103 @@
+package planning
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/lifecycle"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+var _ lifecycle.Upstream = (*PlanningAgent)(nil)
+
+// server is the debug HTTP front of the agent (/status, /process).
+type server struct {
+	once    sync.Once
+	handler http.Handler
+
+	mu  sync.Mutex
+	ln  net.Listener // bound listener (port 0 resolves here)
+	srv *http.Server
+}
+
+// Handler serves /status and /process.
+func (pa *PlanningAgent) Handler() http.Handler {
+	pa.srv.once.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			httpcache.WriteJSON(w, r, map[string]any{
+				"name":    pa.Name,
+				"type":    "planning-debug",
+				"version": selfid.Version,
+				"addr":    pa.Addr(),
+			})
+		})
+		mux.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			var msg types.AgentMessage
+			src, err := gzipx.RequestBody(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := json.NewDecoder(src).Decode(&msg); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			out, err := pa.Process(r.Context(), msg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(out)
+		})
+		pa.srv.handler = reqmetrics.New("planning", nil).Wrap(gzipx.Handler(mux))
+	})
+	return pa.srv.handler
+}
+
+// Start binds addr (port 0 = ephemeral) and serves until Shutdown.
+func (pa *PlanningAgent) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	pa.srv.mu.Lock()
+	pa.srv.ln = ln
+	pa.srv.srv = &http.Server{Addr: addr, Handler: pa.Handler()}
+	srv := pa.srv.srv
+	pa.srv.mu.Unlock()
+	return srv.Serve(ln)
+}
+
+// Addr returns the bound listener address ("" before Start).
+func (pa *PlanningAgent) Addr() string {
+	pa.srv.mu.Lock()
+	defer pa.srv.mu.Unlock()
+	if pa.srv.ln == nil {
+		return ""
+	}
+	return pa.srv.ln.Addr().String()
+}
+
+// Shutdown stops the HTTP server.
+func (pa *PlanningAgent) Shutdown(ctx context.Context) error {
+	pa.srv.mu.Lock()
+	srv := pa.srv.srv
+	pa.srv.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,241 @@
+// Package root - turns the client abandoned.
+//
+// A browser that navigates away cancels its fetch and net/http cancels
+// req.Context(). Every LLM call and upstream send of a /process turn runs on
+// that context, so the work stops instead of finishing for nobody: the LLM
+// client gives up instead of waiting out its retry back-off, and the
+// transport aborts the upstream request. The turn is then recorded by how far
+// it got:
+//
+//   - extraction: nothing was sent upstream yet;
+//   - upstream: a send without side effects (medical, planning, a dry-run
+//     payment) was in flight;
+//   - dispatched: a payment send was in flight, so the charge may have
+//     happened;
+//   - response: the upstream had answered and Root was still phrasing or
+//     writing the result.
+//
+// For extraction and upstream the payment and medical contexts are restored
+// to what they were when the turn started, so no half-written stage
+// transition or claimed confirm token survives. A dispatched payment keeps
+// its context in the "sending" stage: audit payment.abandoned records the
+// idempotency key, and after ROOT_ABANDON_RECONCILE_MS (default 2000) Root
+// asks the payment agent's status operation as for an ambiguous HPKE answer
+// (payment_ambiguity.go). Executed closes the attempt with its receipt,
+// not_executed reopens the confirm, and unknown reopens it with the same
+// idempotency key, so a retry cannot charge twice.
+//
+// Counts by phase: sage_root_abandoned_requests_total on /metrics and
+// "abandoned" in /status.
+package root
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+// Phases of an abandoned turn.
+const (
+	abandonExtraction = "extraction"
+	abandonUpstream   = "upstream"
+	abandonDispatched = "dispatched"
+	abandonResponse   = "response"
+)
+
+var abandonPhases = []string{abandonExtraction, abandonUpstream, abandonDispatched, abandonResponse}
+
+const ctxAbandonKey ctxKey = "abandon"
+
+// abandonNote tracks how far one /process turn got.
+type abandonNote struct {
+	mu       sync.Mutex
+	inFlight string // phase of the send in progress
+	answered bool   // an upstream send returned
+}
+
+func withAbandonNote(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxAbandonKey, &abandonNote{})
+}
+
+func abandonNoteFrom(ctx context.Context) *abandonNote {
+	n, _ := ctx.Value(ctxAbandonKey).(*abandonNote)
+	return n
+}
+
+// begin marks a send as in flight; an outer, more specific phase (a payment
+// dispatch) is kept.
+func (n *abandonNote) begin(phase string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if n.inFlight == "" {
+		n.inFlight = phase
+	}
+	n.mu.Unlock()
+}
+
+// end marks the send as done unless it failed because the client left.
+func (n *abandonNote) end(ctx context.Context, err error) {
+	if n == nil || (err != nil && errors.Is(ctx.Err(), context.Canceled)) {
+		return
+	}
+	n.mu.Lock()
+	n.inFlight, n.answered = "", true
+	n.mu.Unlock()
+}
+
+func (n *abandonNote) phase() string {
+	if n == nil {
+		return abandonExtraction
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch {
+	case n.inFlight != "":
+		return n.inFlight
+	case n.answered:
+		return abandonResponse
+	}
+	return abandonExtraction
+}
+
+// abandonStats counts abandoned turns by phase.
+type abandonStats struct {
+	by map[string]*atomic.Int64
+}
+
+func newAbandonStats() *abandonStats {
+	s := &abandonStats{by: map[string]*atomic.Int64{}}
+	for _, p := range abandonPhases {
+		s.by[p] = new(atomic.Int64)
+	}
+	return s
+}
+
+func (s *abandonStats) snapshot() map[string]int64 {
+	out := make(map[string]int64, len(abandonPhases))
+	for _, p := range abandonPhases {
+		out[p] = s.by[p].Load()
+	}
+	return out
+}
+
+func (s *abandonStats) writePrometheus(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("# HELP sage_root_abandoned_requests_total /process turns whose client left, by how far they got.\n# TYPE sage_root_abandoned_requests_total counter\n")
+	for _, p := range abandonPhases {
+		b.WriteString(`sage_root_abandoned_requests_total{phase="` + p + `"} ` + strconv.FormatInt(s.by[p].Load(), 10) + "\n")
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+// settleIfAbandoned snapshots cid's payment and medical contexts and returns
+// the func, deferred by /process, that records the turn and restores them when
+// the client left before anything with side effects was dispatched.
+func (r *RootAgent) settleIfAbandoned(req *http.Request, cid string) func() {
+	pay := r.snapshotPayCtx(cid)
+	med, hadMed := r.medStore.Load(cid)
+	return func() {
+		if !errors.Is(req.Context().Err(), context.Canceled) {
+			return
+		}
+		phase := abandonNoteFrom(req.Context()).phase()
+		r.abandoned.by[phase].Add(1)
+		restored := phase == abandonExtraction || phase == abandonUpstream
+		if restored {
+			r.restorePayCtx(cid, pay)
+			if hadMed {
+				r.medStore.Store(cid, med)
+			} else {
+				r.medStore.Delete(cid)
+			}
+		}
+		r.logger.Printf("[root][abandon] cid=%s phase=%s contextRestored=%v", cid, phase, restored)
+		r.audit.Emit(audit.Event{
+			Type: "request", Action: "request.abandoned", Outcome: "failure", Actor: requesterOf(req), CID: cid,
+			Detail: map[string]any{"phase": phase, "contextRestored": restored},
+		})
+	}
+}
+
+func (r *RootAgent) snapshotPayCtx(cid string) *payCtx {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	c, ok := r.payContextStore.m[cid]
+	if !ok {
+		return nil
+	}
+	cp := *c
+	cp.Slots.Prov = c.Slots.Prov.clone()
+	return &cp
+}
+
+// restorePayCtx puts a snapshot back (nil: there was no context).
+func (r *RootAgent) restorePayCtx(cid string, snap *payCtx) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if snap == nil {
+		delete(r.payContextStore.m, cid)
+		return
+	}
+	r.payContextStore.set(cid, snap)
+}
+
+func abandonReconcileDelay() time.Duration {
+	return time.Duration(envInt("ROOT_ABANDON_RECONCILE_MS", 2000)) * time.Millisecond
+}
+
+// abandonDispatchedPayment handles a client that left while its payment send
+// was in flight: the outcome is looked up by idempotency key in the background.
+func (r *RootAgent) abandonDispatchedPayment(req *http.Request, cid, claimedFrom, key string) {
+	r.audit.Emit(audit.Event{
+		Type: "payment", Action: "payment.abandoned", Outcome: "failure", Actor: requesterOf(req), Target: "payment", CID: cid,
+		Detail: map[string]any{"key": key, "phase": abandonDispatched},
+	})
+	r.logger.Printf("[root][payment][abandon] cid=%s key=%s client left after dispatch; reconciling", cid, key)
+
+	err := r.Background(func(ctx context.Context) {
+		t := time.NewTimer(abandonReconcileDelay())
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		r.reconcileAbandonedPayment(ctx, cid, claimedFrom, key)
+	})
+	if err != nil {
+		// The attempt stays "sent" in the ledger, which looks it up by key
+		r.logger.Printf("[root][payment][abandon] cid=%s key=%s reconcile not scheduled: %v", cid, key, err)
+		r.releasePayToken(cid, claimedFrom)
+	}
+}
+
+func (r *RootAgent) reconcileAbandonedPayment(ctx context.Context, cid, claimedFrom, key string) {
+	state, orderID, receipt := r.checkPaymentStatus(ctx, cid, key)
+	switch state {
+	case payExecuted:
+		r.delPayCtx(cid)
+		if id, _ := receipt["orderId"].(string); id != "" {
+			r.rememberReceipt(cid, map[string]any{"receipt": receipt})
+		} else {
+			r.closeAttempt(cid, attemptCharged, orderID)
+		}
+	case payNotExecuted:
+		r.closeAttempt(cid, attemptRejected, "")
+		r.releasePayToken(cid, claimedFrom)
+	default:
+		// The idempotency key stays with the context: a retried confirm is deduplicated
+		r.releasePayToken(cid, claimedFrom)
+	}
+	r.logger.Printf("[root][payment][abandon] cid=%s key=%s reconciled state=%s order=%s", cid, key, state, orderID)
+}
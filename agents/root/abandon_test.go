@@ -0,0 +1,156 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// cancelingExtractor is scriptedExtractor that also cancels the turn (the
+// client closing its tab) when the utterance contains trigger.
+type cancelingExtractor struct {
+	scriptedExtractor
+	trigger string
+	cancel  context.CancelFunc
+}
+
+func (c *cancelingExtractor) Chat(ctx context.Context, system, user string) (string, error) {
+	if c.cancel != nil && strings.Contains(user, c.trigger) {
+		c.cancel()
+		return "", ctx.Err()
+	}
+	return c.scriptedExtractor.Chat(ctx, system, user)
+}
+
+// sendCanceled runs a /process turn with a context the test can cancel.
+func sendCanceled(r *RootAgent, ctx context.Context, cid, text string) {
+	b, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: text, ContextID: cid})
+	req := httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b)).WithContext(ctx)
+	r.mux.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func auditLines(t *testing.T, a *audit.Logger, dir, action string) []string {
+	t.Helper()
+	a.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "root-*.jsonl"))
+	var out []string
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		for _, l := range strings.Split(string(b), "\n") {
+			if strings.Contains(l, `"`+action+`"`) {
+				out = append(out, l)
+			}
+		}
+	}
+	return out
+}
+
+// A client that leaves during extraction leaves no trace in the payment
+// context: whatever the turn did is rolled back to its start.
+func TestAbandonBeforeDispatch(t *testing.T) {
+	dir := t.TempDir()
+	env := newForkEnv(t, 0)
+	env.r.audit = audit.New("root", dir, log.New(io.Discard, "", 0))
+	ext := &cancelingExtractor{scriptedExtractor: env.r.llmClient.(scriptedExtractor), trigger: "카드로"}
+	env.r.llmClient = ext
+
+	env.send(t, "c1", "맥북 사줘")
+	before := env.r.snapshotPayCtx("c1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ext.cancel = cancel
+	sendCanceled(env.r, ctx, "c1", "카드로, 애플스토어, 서울 강남구, 300만원")
+
+	after := env.r.snapshotPayCtx("c1")
+	if before == nil || after == nil || after.Stage != before.Stage || after.Token != "" || after.Slots.Method != "" {
+		t.Fatalf("context not restored: before %+v after %+v", before, after)
+	}
+	if got := env.r.abandoned.snapshot(); got[abandonExtraction] != 1 || got[abandonDispatched] != 0 {
+		t.Fatalf("abandoned %v", got)
+	}
+	select {
+	case m := <-env.charges:
+		t.Fatalf("charge sent: %v", m)
+	default:
+	}
+	evs := auditLines(t, env.r.audit, dir, "request.abandoned")
+	if len(evs) != 1 || !strings.Contains(evs[0], `"phase":"extraction"`) || !strings.Contains(evs[0], `"contextRestored":true`) {
+		t.Fatalf("audit %v", evs)
+	}
+}
+
+// A client that leaves while its charge is in flight keeps the "sending"
+// stage; the abandon is audited with the idempotency key and the status
+// lookup later reopens the confirm under that same key.
+func TestAbandonAfterDispatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	keys := make(chan string, 2)
+	pay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := strings.CutPrefix(r.URL.Path, "/lookup/"); ok {
+			keys <- key
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(types.AgentMessage{Type: "response", Metadata: map[string]any{"lookup": map[string]any{"state": "not_found"}}})
+			return
+		}
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		keys <- in.Metadata["payment.idempotencyKey"].(string)
+		cancel() // the client leaves while the charge is in flight
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer pay.Close()
+
+	env := newForkEnv(t, 0)
+	t.Setenv("PAYMENT_URL", pay.URL)
+	t.Setenv("ROOT_ABANDON_RECONCILE_MS", "10")
+	r := NewRootAgent("root", 0)
+	r.logger, r.llmClient = env.r.logger, env.r.llmClient
+	r.audit = audit.New("root", dir, log.New(io.Discard, "", 0))
+	env.r = r
+
+	env.send(t, "c1", "맥북 사줘")
+	preview := env.send(t, "c1", "카드로, 애플스토어, 서울 강남구, 300만원")
+	token := preview.Metadata["confirmToken"]
+	sendCanceled(r, ctx, "c1", "네")
+	sent := <-keys
+
+	if got := r.abandoned.snapshot(); got[abandonDispatched] != 1 || got[abandonExtraction] != 0 {
+		t.Fatalf("abandoned %v", got)
+	}
+	select {
+	case looked := <-keys:
+		if looked != sent {
+			t.Fatalf("lookup key %s, charge key %s", looked, sent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no status lookup after the abandon")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for stage, tok := r.getStageToken("c1"); stage != "await_confirm" || tok != token; stage, tok = r.getStageToken("c1") {
+		if time.Now().After(deadline) {
+			t.Fatalf("confirm not reopened: stage %q token %q", stage, tok)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	evs := auditLines(t, r.audit, dir, "payment.abandoned")
+	if len(evs) != 1 || !strings.Contains(evs[0], `"key":"`+sent+`"`) || !strings.Contains(evs[0], `"phase":"dispatched"`) {
+		t.Fatalf("audit %v", evs)
+	}
+}
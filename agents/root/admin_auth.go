@@ -0,0 +1,55 @@
+// Package root - admin token gate for operator-only endpoints.
+//
+// ROOT_ADMIN_TOKEN enables the gate; callers send it as
+// "Authorization: Bearer <token>" or "X-Admin-Token: <token>".
+// When the variable is unset, gated endpoints answer 403.
+package root
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func adminToken() string { return strings.TrimSpace(os.Getenv("ROOT_ADMIN_TOKEN")) }
+
+// hasAdminToken reports whether req carries the configured admin token.
+func hasAdminToken(req *http.Request) bool {
+	want := adminToken()
+	if want == "" {
+		return false
+	}
+	got := strings.TrimSpace(req.Header.Get("X-Admin-Token"))
+	if got == "" {
+		if a := req.Header.Get("Authorization"); len(a) > 7 && strings.EqualFold(a[:7], "bearer ") {
+			got = strings.TrimSpace(a[7:])
+		}
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// requireAdmin writes 403 and returns false unless req carries the admin token.
+func requireAdmin(w http.ResponseWriter, req *http.Request) bool {
+	if hasAdminToken(req) {
+		return true
+	}
+	msg := "admin token required"
+	if adminToken() == "" {
+		msg = "admin endpoints disabled (ROOT_ADMIN_TOKEN not set)"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": "forbidden", "message": msg})
+	return false
+}
+
+// requesterOf identifies who issued an admin request: "admin@<addr>" with the
+// admin token, otherwise the remote addr.
+func requesterOf(req *http.Request) string {
+	if hasAdminToken(req) {
+		return "admin@" + req.RemoteAddr
+	}
+	return req.RemoteAddr
+}
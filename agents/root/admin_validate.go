@@ -0,0 +1,145 @@
+// Package root - strict request body validation for admin endpoints.
+//
+// Every problem in a body is reported at once (trailing data, unknown
+// fields, missing required fields, wrong types, enum violations) in a single
+// 400 envelope:
+//
+//	{"error":"invalid_request","problems":[{"field":"enable","message":"unknown field"}]}
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// fieldSpec describes one accepted JSON field.
+type fieldSpec struct {
+	Kind     string   // "bool" | "string" | "object"
+	Required bool     // must be present
+	Enum     []string // allowed values for strings (case-insensitive); empty = any
+}
+
+type fieldProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+const maxAdminBody = 64 << 10
+
+// decodeAdminBody validates the request body against spec and, when valid,
+// decodes it into out with DisallowUnknownFields.
+func decodeAdminBody(req *http.Request, spec map[string]fieldSpec, out any) []fieldProblem {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxAdminBody+1))
+	if err != nil {
+		return []fieldProblem{{Field: "", Message: "failed to read body"}}
+	}
+	if len(body) > maxAdminBody {
+		return []fieldProblem{{Field: "", Message: fmt.Sprintf("body exceeds %d bytes", maxAdminBody)}}
+	}
+	var raw map[string]json.RawMessage
+	rd := json.NewDecoder(bytes.NewReader(body))
+	if err := rd.Decode(&raw); err != nil || raw == nil {
+		msg := "body must be a JSON object"
+		if err != nil {
+			msg += ": " + err.Error()
+		}
+		return []fieldProblem{{Field: "", Message: msg}}
+	}
+
+	var probs []fieldProblem
+	if _, err := rd.Token(); err != io.EOF {
+		probs = append(probs, fieldProblem{Field: "", Message: "unexpected data after the JSON object"})
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := spec[k]; !ok {
+			probs = append(probs, fieldProblem{Field: k, Message: "unknown field"})
+		}
+	}
+
+	names := make([]string, 0, len(spec))
+	for k := range spec {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fs := spec[name]
+		v, ok := raw[name]
+		if !ok || string(v) == "null" {
+			if fs.Required {
+				probs = append(probs, fieldProblem{Field: name, Message: "required"})
+			}
+			continue
+		}
+		switch fs.Kind {
+		case "bool":
+			var b bool
+			if json.Unmarshal(v, &b) != nil {
+				probs = append(probs, fieldProblem{Field: name, Message: "must be a boolean"})
+			}
+		case "object":
+			var o map[string]json.RawMessage
+			if json.Unmarshal(v, &o) != nil {
+				probs = append(probs, fieldProblem{Field: name, Message: "must be an object"})
+			}
+		case "string":
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				probs = append(probs, fieldProblem{Field: name, Message: "must be a string"})
+				continue
+			}
+			if len(fs.Enum) > 0 && strings.TrimSpace(s) != "" {
+				okEnum := false
+				for _, e := range fs.Enum {
+					if strings.EqualFold(strings.TrimSpace(s), e) {
+						okEnum = true
+						break
+					}
+				}
+				if !okEnum {
+					probs = append(probs, fieldProblem{Field: name, Message: "must be one of: " + strings.Join(fs.Enum, ", ")})
+				}
+			}
+		}
+	}
+	if len(probs) > 0 {
+		return probs
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return []fieldProblem{{Field: "", Message: err.Error()}}
+	}
+	return nil
+}
+
+func writeInvalidRequest(w http.ResponseWriter, probs []fieldProblem) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":    "invalid_request",
+		"problems": probs,
+	})
+}
+
+// knownTargets returns the routing table's agent names (sorted).
+func (r *RootAgent) knownTargets() []string {
+	r.extMu.RLock()
+	defer r.extMu.RUnlock()
+	out := make([]string, 0, len(r.extBase))
+	for k := range r.extBase {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
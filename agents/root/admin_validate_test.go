@@ -0,0 +1,87 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAdminBody(t *testing.T) {
+	spec := map[string]fieldSpec{
+		"enabled": {Kind: "bool", Required: true},
+		"target":  {Kind: "string", Enum: []string{"payment", "medical"}},
+		"extra":   {Kind: "object"},
+	}
+	cases := []struct {
+		name string
+		body string
+		want []fieldProblem // messages match by prefix
+	}{
+		{"valid", `{"enabled":true,"target":"Payment","extra":{"a":1}}`, nil},
+		{"unknown field", `{"enabled":true,"enable":true}`, []fieldProblem{{"enable", "unknown field"}}},
+		{"wrong type", `{"enabled":"yes"}`, []fieldProblem{{"enabled", "must be a boolean"}}},
+		{"missing required", `{"target":"payment"}`, []fieldProblem{{"enabled", "required"}}},
+		{"null is missing", `{"enabled":null}`, []fieldProblem{{"enabled", "required"}}},
+		{"enum", `{"enabled":true,"target":"planning"}`, []fieldProblem{{"target", "must be one of: payment, medical"}}},
+		{"trailing data", `{"enabled":true} {"enabled":false}`, []fieldProblem{{"", "unexpected data after the JSON object"}}},
+		{"every problem", `{"enabeld":true,"target":7,"extra":[]} x`, []fieldProblem{
+			{"", "unexpected data after the JSON object"},
+			{"enabeld", "unknown field"},
+			{"enabled", "required"},
+			{"extra", "must be an object"},
+			{"target", "must be a string"},
+		}},
+		{"not an object", `[true]`, []fieldProblem{{"", "body must be a JSON object: "}}},
+		{"null body", `null`, []fieldProblem{{"", "body must be a JSON object"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out struct {
+				Enabled bool           `json:"enabled"`
+				Target  string         `json:"target"`
+				Extra   map[string]any `json:"extra"`
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			probs := decodeAdminBody(req, spec, &out)
+			if len(probs) != len(tc.want) {
+				t.Fatalf("problems %+v, want %+v", probs, tc.want)
+			}
+			for i, p := range probs {
+				if p.Field != tc.want[i].Field || !strings.HasPrefix(p.Message, tc.want[i].Message) {
+					t.Fatalf("problem %d: %+v, want %+v", i, p, tc.want[i])
+				}
+			}
+			if tc.want == nil && (!out.Enabled || out.Target != "Payment" || out.Extra["a"] != float64(1)) {
+				t.Fatalf("decoded %+v", out)
+			}
+		})
+	}
+}
+
+func TestDecodeAdminBodyTooLarge(t *testing.T) {
+	body := `{"target":"` + strings.Repeat("x", maxAdminBody) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	probs := decodeAdminBody(req, map[string]fieldSpec{"target": {Kind: "string"}}, &struct{}{})
+	if len(probs) != 1 || !strings.Contains(probs[0].Message, "exceeds") {
+		t.Fatalf("problems %+v", probs)
+	}
+}
+
+// An admin endpoint answers with the whole problem list.
+func TestAdminEndpointInvalidRequest(t *testing.T) {
+	r := statusRoot(t)
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hpke/config", strings.NewReader(`{"enabeld":false,"target":1} {}`)))
+	var out struct {
+		Error    string         `json:"error"`
+		Problems []fieldProblem `json:"problems"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || w.Code != http.StatusBadRequest || out.Error != "invalid_request" {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if len(out.Problems) < 3 || out.Problems[0].Message != "unexpected data after the JSON object" {
+		t.Fatalf("problems %+v", out.Problems)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -30,7 +31,9 @@ import (
 	"github.com/sage-x-project/sage/pkg/agent/transport"
 
 	// DID & crypto
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/pkg/lifecycle"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	"github.com/sage-x-project/sage-multi-agent/websocket"
 	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
 	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
 	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
@@ -40,6 +43,25 @@ import (
 
 	// [LLM] light shim client
 	"github.com/sage-x-project/sage-multi-agent/llm"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+	"github.com/sage-x-project/sage-multi-agent/internal/async"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/ethpool"
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/i18n"
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+	"github.com/sage-x-project/sage-multi-agent/internal/logclip"
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	promptlimit "github.com/sage-x-project/sage-multi-agent/internal/prompt"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/internal/statefile"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
 )
 
 // ---- RootAgent ----
@@ -59,6 +81,9 @@ type RootAgent struct {
 	mux    *http.ServeMux
 	server *http.Server
 
+	lnMu sync.Mutex
+	ln   net.Listener // bound listener (port 0 resolves here)
+
 	logger *log.Logger
 
 	// Outbound signing & HTTP
@@ -69,14 +94,110 @@ type RootAgent struct {
 	a2a         *a2aclient.A2AClient
 
 	// External base URLs per agent (routing target)
+	extMu   sync.RWMutex
 	extBase map[string]string // key: "planning"|"medical"|"payment" -> base URL
+	direct  map[string]string // <AGENT>_DIRECT_URL fallbacks (see fallback.go)
+
+	// Primary/fallback route availability + degradation state (see fallback.go)
+	upstreams *upstreamHealth
 
 	// HPKE per-target state
 	hpkeStates sync.Map // key: target string -> *hpkeState
 	resolver   sagedid.Resolver
+	// lease on the pooled registry client (released on Shutdown)
+	releaseResolver func()
 
 	// [LLM] lazy-initialized NLG client
 	llmClient llm.Client
+
+	// Bounded executor for background work (callbacks, probes, retries)
+	bg *async.Pool
+	// route key -> struct{} while a delayed HPKE init retry is pending
+	hpkeRetrying sync.Map
+
+	// Inbound request gauges/histogram + slow-request log
+	reqm *reqmetrics.Tracker
+
+	// Audit/security event log (AUDIT_DIR; no-op when unset)
+	audit *audit.Logger
+
+	// Pinned upstream identities (server DID + KEM fingerprint) per HPKE target
+	pins *pinStore
+
+	// HPKE enable/disable history per target
+	hpkeHist *hpkeHistory
+
+	// Demo run grouping (audit tagging, summaries)
+	runs *runTracker
+
+	// Forwarded-metadata overflow (see overflow.go)
+	overflow     *overflowStore
+	overflowOnce sync.Once
+	overflowH    http.Handler
+
+	// /process handler, re-entered for domain redirects (see redirect.go)
+	processFn http.HandlerFunc
+
+	// JSON-RPC on a second listener (ROOT_RPC_ADDR, see rpc.go)
+	rpcServer *http.Server
+
+	// Circuit breakers per outbound target (see send_policy.go)
+	breakers sync.Map // target -> *resilience.CircuitBreaker
+
+	// Upstream response schemas + drift counters (see schema_drift.go)
+	drift *schemaDrift
+
+	// Security posture per /process response (see posture.go)
+	posture *postureStats
+
+	// Named upstream operations: target -> op -> method/path (see operations.go)
+	ops *opSet
+
+	// ROOT_HEADER_SELFCHECK: cross-check verification headers (see verified.go)
+	headerCheck *headerCheck
+
+	// Pinned/observed TLS certificate per upstream target (see conn_trace.go)
+	certs *certWatch
+
+	// Critical security alerts + signature failure counts (see alerts.go)
+	alerts   *alert.Dispatcher
+	sigFails *sigFailures
+
+	// Post-processing hooks on Root's own chat answers (see posthooks.go)
+	hooks *posthook.Chain
+
+	// Optional WebSocket log feed for HPKE state changes (see hpke_history.go)
+	logs websocket.LogBroadcaster
+
+	// One /process turn at a time per conversation (see conv_queue.go)
+	convq *convQueue
+
+	// Per-target keys for field-level metadata encryption (see fieldenc.go)
+	fieldEnc *fieldSealer
+
+	// Probe history per upstream for sparklines (see health_history.go)
+	health *healthHistory
+
+	// Request size limits advertised by each upstream (see payload_limits.go)
+	upLimits *upstreamLimits
+
+	// Routing hints advertised by each upstream (see routing_hints.go)
+	routeHints *routeHints
+
+	// /process turns whose client left, by phase (see abandon.go)
+	abandoned *abandonStats
+
+	// First HPKE requests that raced the agent's session creation (see hpke_race.go)
+	hpkeRaces *hpkeRaceStats
+
+	// Idle windows after which clarify states stop pinning routing (see await_expiry.go)
+	awaitWindows awaitWindows
+
+	// Results that missed their SLA budget in a multi-target request (see sla.go)
+	slaTasks slaTaskStore
+
+	// Per-conversation state (see conv_state.go)
+	convState
 }
 
 // hpkeState holds per-target HPKE session context.
@@ -84,6 +205,8 @@ type hpkeState struct {
 	cli  *hpke.Client
 	sMgr *session.Manager
 	kid  string
+
+	kemOwnership string // verified | pinned | unverified (kem_ownership.go)
 }
 
 // ---- Construction ----
@@ -107,18 +230,172 @@ func NewRootAgent(name string, port int) *RootAgent {
 		a2a:         nil,
 		sageEnabled: envBool("ROOT_SAGE_ENABLED", true),
 		extBase:     ext,
-	}
+		direct:      directURLsFromEnv("planning", "medical", "payment"),
+		upstreams:   newUpstreamHealth(),
+		health:      newHealthHistory(),
+		upLimits:    newUpstreamLimits(),
+		routeHints:  newRouteHints(),
+		abandoned:   newAbandonStats(),
+		hpkeRaces:   newHPKERaceStats(),
+	}
+	// Named per instance ("root.bg", "payment-root.bg", ...; the registry adds
+	// "#n" on a clash). The JSON-RPC listener (ROOT_RPC_ADDR) holds one worker
+	// for as long as it serves, so it gets one on top.
+	workers := envInt("ROOT_ASYNC_WORKERS", 4)
+	if strings.TrimSpace(os.Getenv("ROOT_RPC_ADDR")) != "" {
+		workers++
+	}
+	ra.bg = async.NewPool(name+".bg", workers, envInt("ROOT_ASYNC_QUEUE", 64), ra.logger)
+	ra.audit = audit.FromEnv("root", ra.logger)
+	ra.reqm = reqmetrics.New("root", ra.logger)
+	ra.pins = newPinStore(os.Getenv("ROOT_HPKE_PINS_FILE"))
+	ra.hpkeHist = newHPKEHistory()
+	ra.runs = newRunTracker()
+	ra.overflow = newOverflowStore()
+	ra.drift = newSchemaDrift(ra.logger)
+	ra.posture = newPostureStats()
+	ra.ops = loadUpstreamOps(ra.logger)
+	ra.headerCheck = newHeaderCheck(ra.logger)
+	ra.certs = newCertWatch()
+	ra.alerts = alert.FromEnv("root", ra.logger)
+	ra.sigFails = newSigFailures()
+	ra.hooks = posthook.FromEnv("root", ra.audit, ra.logger)
+	ra.convq = newConvQueue()
+	ra.awaitWindows = awaitWindowsFromEnv()
+	// Translation completeness of the template catalog (see i18n.go)
+	if err := i18n.Default.LogReport(ra.logger, "root"); err != nil {
+		ra.logger.Fatalf("[root][i18n] %v", err)
+	}
+	if _, err := tz.Resolve(os.Getenv(tz.EnvDefault)); err != nil {
+		ra.logger.Printf("[root][tz] ⚠️ %s: %v; conversations default to UTC", tz.EnvDefault, err)
+		bootreport.Warnf("%s: %v; conversations default to UTC", tz.EnvDefault, err)
+	}
+	ra.fieldEnc = newFieldSealer("planning", "medical", "payment")
+	for a, err := range ra.fieldEnc.errs {
+		ra.logger.Printf("[root][fieldenc][error] %s: %v (sends to %s will fail)", a, err, a)
+		bootreport.Warnf("field encryption for %s: %v (sends to %s will fail)", a, err, a)
+	}
+	ra.mountFlags()
 	// Lazy init: signing & resolver will be initialized on first use
 
 	ra.mountRoutes()
+	ra.mountRPC()
 	return ra
 }
 
+var _ lifecycle.Agent = (*RootAgent)(nil)
+
+// Start binds ":port" (0 = ephemeral) and serves until Shutdown.
 func (r *RootAgent) Start() error {
 	addr := fmt.Sprintf(":%d", r.port)
-	r.server = &http.Server{Addr: addr, Handler: r.mux}
-	r.logger.Printf("[root] listening on %s", addr)
-	return r.server.ListenAndServe()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := r.startRPC(); err != nil {
+		ln.Close()
+		return err
+	}
+	r.startUpstreamProbe()
+	r.startHealthProbe()
+	r.lnMu.Lock()
+	r.ln = ln
+	r.server = &http.Server{Addr: addr, Handler: r.reqm.Wrap(gzipx.Handler(r.mux))}
+	srv := r.server
+	r.lnMu.Unlock()
+	r.logger.Printf("[root] listening on %s", ln.Addr().String())
+	return srv.Serve(ln)
+}
+
+// Addr returns the bound listener address ("" before Start).
+func (r *RootAgent) Addr() string {
+	r.lnMu.Lock()
+	defer r.lnMu.Unlock()
+	if r.ln == nil {
+		return ""
+	}
+	return r.ln.Addr().String()
+}
+
+// boundPort returns the actual listening port (falls back to the configured one).
+func (r *RootAgent) boundPort() int {
+	if a := r.Addr(); a != "" {
+		if _, p, err := net.SplitHostPort(a); err == nil {
+			if n, err := strconv.Atoi(p); err == nil {
+				return n
+			}
+		}
+	}
+	return r.port
+}
+
+// SetExternalURL updates the routing target for agent at runtime
+// (e.g. when a harness discovers an upstream bound on an ephemeral port).
+func (r *RootAgent) SetExternalURL(agent, base string) {
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	r.extMu.Lock()
+	defer r.extMu.Unlock()
+	r.extBase[agent] = strings.TrimRight(strings.TrimSpace(base), "/")
+}
+
+// Shutdown stops the HTTP server and drains background work up to ctx deadline.
+func (r *RootAgent) Shutdown(ctx context.Context) error {
+	var srvErr error
+	r.lnMu.Lock()
+	srv := r.server
+	r.lnMu.Unlock()
+	if srv != nil {
+		srvErr = srv.Shutdown(ctx)
+	}
+	if r.rpcServer != nil {
+		_ = r.rpcServer.Shutdown(ctx)
+	}
+	r.stopUpstreamProbe()
+	r.stopHealthProbe()
+	if err := r.bg.Drain(ctx); err != nil {
+		r.logger.Printf("[root] background drain: %v", err)
+		if srvErr == nil {
+			srvErr = err
+		}
+	}
+	r.audit.Close()
+	r.alerts.Close()
+	if r.releaseResolver != nil {
+		r.releaseResolver()
+	}
+	return srvErr
+}
+
+// Background submits fn to Root's bounded executor. It never blocks; when the
+// queue is full the task is dropped and the error is returned to the caller.
+func (r *RootAgent) Background(fn func(ctx context.Context)) error {
+	return r.bg.Go(fn)
+}
+
+// scheduleHPKERetry retries a failed HPKE init off the request path after
+// ROOT_HPKE_RETRY_MS (default 5000), so the agent or registry has time to
+// recover first. At most one retry per route is pending; the requests in
+// between go out without a session as before.
+func (r *RootAgent) scheduleHPKERetry(rt extRoute) {
+	if _, pending := r.hpkeRetrying.LoadOrStore(rt.Key, struct{}{}); pending {
+		return
+	}
+	delay := time.Duration(envInt("ROOT_HPKE_RETRY_MS", 5000)) * time.Millisecond
+	time.AfterFunc(delay, func() {
+		err := r.Background(func(ctx context.Context) {
+			defer r.hpkeRetrying.Delete(rt.Key)
+			if r.IsHPKEEnabled(rt.Key) {
+				return
+			}
+			if err := r.enableHPKERoute(ctx, rt, hpkeKeysPath()); err != nil {
+				r.logger.Printf("[root] HPKE background retry failed target=%s: %v", rt.Key, err)
+			}
+		})
+		if err != nil {
+			r.hpkeRetrying.Delete(rt.Key)
+			r.logger.Printf("[root] HPKE background retry not scheduled target=%s: %v", rt.Key, err)
+		}
+	})
 }
 
 // ---- [LLM] ensure ----
@@ -157,18 +434,53 @@ func (r *RootAgent) Do(ctx context.Context, req *http.Request) (*http.Response,
 }
 
 func (r *RootAgent) initSigning() error {
+	kp, didStr, err := loadSigningKey()
+	if err != nil {
+		return err
+	}
+	r.myKey = kp
+	r.myDID = sagedid.AgentDID(didStr)
+	r.a2a = a2aclient.NewA2AClient(r.myDID, r.myKey, r.httpClient)
+	return nil
+}
+
+// signingKeyError says which step of loading ROOT_JWK_FILE failed
+// ("unset", "read", "import", "did"); troubleshoot.go turns it into a hint.
+type signingKeyError struct {
+	Step string
+	Path string
+	Err  error
+}
+
+func (e *signingKeyError) Error() string {
+	switch e.Step {
+	case "unset":
+		return "ROOT_JWK_FILE required for Root signing"
+	case "read":
+		return fmt.Sprintf("read ROOT_JWK_FILE: %v", e.Err)
+	case "import":
+		return fmt.Sprintf("import ROOT_JWK_FILE: %v", e.Err)
+	}
+	return "ROOT_DID not set and cannot derive from key"
+}
+
+func (e *signingKeyError) Unwrap() error { return e.Err }
+
+// loadSigningKey reads Root's key from ROOT_JWK_FILE and returns it with its
+// DID: ROOT_DID, else derived from the key.
+func loadSigningKey() (sagecrypto.KeyPair, string, error) {
 	jwk := strings.TrimSpace(os.Getenv("ROOT_JWK_FILE"))
 	if jwk == "" {
-		return fmt.Errorf("ROOT_JWK_FILE required for Root signing")
+		return nil, "", &signingKeyError{Step: "unset"}
 	}
 	raw, err := os.ReadFile(jwk)
 	if err != nil {
-		return fmt.Errorf("read ROOT_JWK_FILE: %w", err)
+		return nil, "", &signingKeyError{Step: "read", Path: jwk, Err: err}
 	}
 	imp := formats.NewJWKImporter()
 	kp, err := imp.Import(raw, sagecrypto.KeyFormatJWK)
 	if err != nil {
-		return fmt.Errorf("import ROOT_JWK_FILE: %w", err)
+		return nil, "", &signingKeyError{Step: "import", Path: jwk, Err: err}
 	}
 
 	didStr := strings.TrimSpace(os.Getenv("ROOT_DID"))
@@ -180,13 +492,10 @@ func (r *RootAgent) initSigning() error {
 		} else if id := strings.TrimSpace(kp.ID()); id != "" {
 			didStr = "did:sage:generated:" + id
 		} else {
-			return fmt.Errorf("ROOT_DID not set and cannot derive from key")
+			return nil, "", &signingKeyError{Step: "did", Path: jwk}
 		}
 	}
-	r.myKey = kp
-	r.myDID = sagedid.AgentDID(didStr)
-	r.a2a = a2aclient.NewA2AClient(r.myDID, r.myKey, r.httpClient)
-	return nil
+	return kp, didStr, nil
 }
 
 // ---- Resolver (for HPKE) ----
@@ -195,23 +504,13 @@ func (r *RootAgent) ensureResolver() error {
 	if r.resolver != nil {
 		return nil
 	}
-	rpc := firstNonEmpty(os.Getenv("ETH_RPC_URL"), "http://127.0.0.1:8545")
-	contract := firstNonEmpty(os.Getenv("SAGE_REGISTRY_ADDRESS"), "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
-	priv := strings.TrimPrefix(strings.TrimSpace(os.Getenv("SAGE_EXTERNAL_KEY")), "0x")
-
-	cfgV4 := &sagedid.RegistryConfig{
-		RPCEndpoint:        rpc,
-		ContractAddress:    contract,
-		PrivateKey:         priv, // optional for read-only
-		GasPrice:           0,
-		MaxRetries:         24,
-		ConfirmationBlocks: 0,
-	}
-	ethV4, err := dideth.NewEthereumClient(cfgV4)
+	// Shared with the DID middleware and every other consumer of the same RPC/contract
+	ethV4, release, err := a2autil.Shared(a2autil.KindEthereum, a2autil.RegistryConfigFromEnv(""), dideth.NewEthereumClient)
 	if err != nil {
 		return fmt.Errorf("HPKE: init resolver: %w", err)
 	}
 	r.resolver = ethV4
+	r.releaseResolver = release
 	return nil
 }
 
@@ -232,9 +531,10 @@ func (r *RootAgent) CurrentHPKEKID(target string) string {
 	return ""
 }
 
+// DisableHPKE drops the target's session (recorded as a forced system change).
+// Admin paths use disableHPKEBy so the requester and required-target check apply.
 func (r *RootAgent) DisableHPKE(target string) {
-	key := strings.ToLower(strings.TrimSpace(target))
-	r.hpkeStates.Delete(key)
+	_ = r.disableHPKEBy(target, "system", "", true)
 }
 
 func (r *RootAgent) EnableHPKE(ctx context.Context, target, keysFile string) error {
@@ -242,6 +542,13 @@ func (r *RootAgent) EnableHPKE(ctx context.Context, target, keysFile string) err
 	if target == "" {
 		target = "payment" // default
 	}
+	return r.enableHPKERoute(ctx, extRoute{Agent: target, Key: target, Base: r.externalURLFor(target)}, keysFile)
+}
+
+// enableHPKERoute handshakes with one concrete route of rt.Agent. Identity,
+// pins and KEM ownership are the agent's; the session is stored under rt.Key.
+func (r *RootAgent) enableHPKERoute(ctx context.Context, rt extRoute, keysFile string) error {
+	target := rt.Agent
 	if err := r.ensureResolver(); err != nil {
 		return err
 	}
@@ -268,14 +575,26 @@ func (r *RootAgent) EnableHPKE(ctx context.Context, target, keysFile string) err
 	if serverDID == "" {
 		return fmt.Errorf("HPKE: server DID alias not found (tried %q and \"external\")", serverAlias)
 	}
+	kemPub, err := r.resolveKEMPublic(ctx, serverDID)
+	if err != nil {
+		return err
+	}
+	kemFP := kemFingerprint(kemPub)
+	if err := r.checkPin(target, serverDID, kemFP); err != nil {
+		return err
+	}
+	kemState, err := r.verifyKEMOwnership(ctx, target, serverAlias, serverDID, kemPub)
+	if err != nil {
+		return err
+	}
 
 	// Handshake transport uses hpkeHandshake=true for SecureMessage path.
-	base := r.externalURLFor(target)
+	base := rt.Base
 	if base == "" {
 		return fmt.Errorf("HPKE: external URL not configured for %q", target)
 	}
 	// Handshake uses HPKE; emit A2A headers not strictly required, keep minimal
-	t := prototx.NewA2ATransport(r, base, true, true)
+	t := prototx.NewA2ATransport(r, base, true, true).WithAuthority(signedAuthorityFor(target))
 
 	sMgr := session.NewManager()
 	cli := hpke.NewClient(t, r.resolver, r.myKey, clientDID, hpke.DefaultInfoBuilder{}, sMgr)
@@ -291,8 +610,10 @@ func (r *RootAgent) EnableHPKE(ctx context.Context, target, keysFile string) err
 		return fmt.Errorf("HPKE Initialize returned empty kid")
 	}
 
-	r.hpkeStates.Store(target, &hpkeState{cli: cli, sMgr: sMgr, kid: kid})
-	r.logger.Printf("[root] HPKE initialized target=%s kid=%s clientDID=%s serverDID=%s", target, kid, clientDID, serverDID)
+	r.hpkeStates.Store(rt.Key, &hpkeState{cli: cli, sMgr: sMgr, kid: kid, kemOwnership: kemState})
+	r.pinIfFirst(target, serverDID, kemFP)
+	r.recordHPKEEnable(ctx, rt.Key, kid)
+	r.logger.Printf("[root] HPKE initialized target=%s base=%s kid=%s clientDID=%s serverDID=%s", rt.Key, base, kid, clientDID, serverDID)
 	return nil
 }
 
@@ -339,6 +660,8 @@ func (r *RootAgent) decryptIfHPKEResponse(target, kid string, data []byte) ([]by
 
 func (r *RootAgent) externalURLFor(agent string) string {
 	agent = strings.ToLower(strings.TrimSpace(agent))
+	r.extMu.RLock()
+	defer r.extMu.RUnlock()
 	if base, ok := r.extBase[agent]; ok {
 		return strings.TrimRight(base, "/")
 	}
@@ -411,14 +734,28 @@ func pickLang(r *http.Request, msg *types.AgentMessage) string {
 
 // ---- Outbound send (Root owns external I/O) ----
 
-func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.AgentMessage) (*types.AgentMessage, error) {
+func (r *RootAgent) sendExternal(ctx context.Context, agent, op string, msg *types.AgentMessage) (*types.AgentMessage, error) {
 	base := r.externalURLFor(agent)
 	if base == "" {
 		return nil, fmt.Errorf("no external URL configured for agent=%s", agent)
 	}
+	method, opPath, err := r.ops.resolve(agent, op, msg.Metadata)
+	if err != nil {
+		return nil, err
+	}
 
-	body, _ := json.Marshal(msg)
-
+	if run := r.runs.current(); run != "" {
+		if msg.Metadata == nil {
+			msg.Metadata = map[string]any{}
+		}
+		msg.Metadata["run"] = run
+	}
+	if loc, ok := ctx.Value(ctxTimezoneKey).(*time.Location); ok {
+		if msg.Metadata == nil {
+			msg.Metadata = map[string]any{}
+		}
+		msg.Metadata[tz.MetaKey] = loc.String()
+	}
 	useSAGE := r.sageEnabled
 	if v := ctx.Value(ctxUseSAGEKey); v != nil {
 		if b, ok := v.(bool); ok {
@@ -434,36 +771,96 @@ func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.A
 	if !useSAGE {
 		wantHPKE = false
 	}
+	if wantHPKE && (method == http.MethodGet || method == http.MethodDelete) {
+		r.logger.Printf("[root] HPKE skipped for %s %s (no request body)", method, opPath)
+		wantHPKE = false
+	}
+
+	// Size against the upstream's limits before any signing or encryption
+	body, pc := r.fitUpstreamLimits(agent, msg, wantHPKE)
+	if pc.tooLarge() {
+		r.logger.Printf("[root][limits] target=%s payload %dB (limit %dB) metadata %dB (limit %dB) advertised=%v: not sent",
+			agent, pc.Bytes, pc.Limits.MaxBodyBytes, pc.MetadataBytes, pc.Limits.MaxMetadataBytes, pc.Advertised)
+		return nil, &payloadTooLargeError{Check: pc}
+	}
 
 	if useSAGE && r.a2a == nil {
 		if err := r.initSigning(); err != nil {
 			return nil, err
 		}
 	}
-	if wantHPKE && !r.IsHPKEEnabled(agent) {
-		if err := r.EnableHPKE(ctx, agent, hpkeKeysPath()); err != nil {
-			r.logger.Printf("[root] HPKE init failed target=%s: %v", agent, err)
+
+	c := &outboundCall{op: op, method: method, path: opPath, msg: msg, body: body, useSAGE: useSAGE, wantHPKE: wantHPKE}
+	primary := extRoute{Agent: agent, Key: agent, Base: base}
+	out, err := r.sendVia(ctx, primary, c)
+	if err == nil {
+		notePayloadCheck(out, pc)
+	}
+	direct, ok := r.directRoute(agent)
+	if !ok {
+		return out, err
+	}
+	if err == nil || !isConnectErr(err) {
+		r.notePrimaryReachable(primary)
+		return out, err
+	}
+	return r.sendFallback(ctx, primary, direct, c, err)
+}
+
+// sendVia sends a prepared call to one concrete route (primary or direct
+// fallback, see fallback.go).
+func (r *RootAgent) sendVia(ctx context.Context, rt extRoute, c *outboundCall) (*types.AgentMessage, error) {
+	agent, base, msg, body := rt.Agent, rt.Base, c.msg, c.body
+	useSAGE, wantHPKE := c.useSAGE, c.wantHPKE
+
+	if wantHPKE && !r.IsHPKEEnabled(rt.Key) {
+		if err := r.enableHPKERoute(ctx, rt, hpkeKeysPath()); err != nil {
+			r.logger.Printf("[root] HPKE init failed target=%s: %v", rt.Key, err)
+			r.scheduleHPKERetry(rt)
 		}
 	}
 
+	// Invariants on the plaintext about to be signed or encrypted (payload_check.go)
+	if _, err := r.checkPayload(rt.Key, "request", msg.ContextID, body, ""); err != nil {
+		return nil, err
+	}
+	plain := body
+
 	var kid string
 	if wantHPKE {
-		if ct, k, used, err := r.encryptIfHPKE(agent, body); used {
+		if ct, k, used, err := r.encryptIfHPKE(rt.Key, body); used {
 			if err != nil {
 				return nil, fmt.Errorf("hpke: %w", err)
 			}
 			body = ct
 			kid = k
-			r.logger.Printf("[root] encrypt hpke target=%s kid=%s bytes=%d", agent, k, len(ct))
+			r.logger.Printf("[root] encrypt hpke target=%s kid=%s bytes=%d", rt.Key, k, len(ct))
 		} else {
 			r.logger.Printf("[root] HPKE requested but no session; sending plaintext (%d bytes)", len(body))
 		}
 	} else {
 		r.logger.Printf("[root] HPKE disabled by request (plaintext) bytes=%d", len(body))
 	}
+	var sealed []string
+	if kid == "" && c.method != http.MethodGet && c.method != http.MethodDelete {
+		sb, meta, keys, err := r.sealFields(agent, msg)
+		if err != nil {
+			return nil, err
+		}
+		if sb != nil {
+			if pc := r.checkSealedLimits(agent, sb, meta); pc.tooLarge() {
+				r.logger.Printf("[root][limits] target=%s sealed payload %dB (limit %dB) metadata %dB (limit %dB): not sent",
+					rt.Key, pc.Bytes, pc.Limits.MaxBodyBytes, pc.MetadataBytes, pc.Limits.MaxMetadataBytes)
+				return nil, &payloadTooLargeError{Check: pc}
+			}
+			body, plain, sealed = sb, sb, keys
+			r.logger.Printf("[root][fieldenc] target=%s sealed %s", rt.Key, strings.Join(keys, ","))
+		}
+	}
 
 	emitHeaders := useSAGE || wantHPKE
-	tx := prototx.NewA2ATransport(r, base, false, emitHeaders)
+	trace := payloadTraceFrom(ctx).start(rt.Key, plain)
+	tx := prototx.NewA2ATransport(r, base, false, emitHeaders).WithAuthority(signedAuthorityFor(agent)).WithGzip(gzipx.OutboundMinBytes()).WithOperation(c.method, c.path).WithHeader(payloadcheck.TraceHeader, trace)
 	sm := &transport.SecureMessage{
 		ID:       uuid.NewString(),
 		Payload:  body,
@@ -475,7 +872,13 @@ func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.A
 		sm.Metadata["hpke_kid"] = kid
 	}
 
-	resp, err := tx.Send(ctx, sm)
+	tctx, cc := withConnCapture(ctx)
+	resp, err := r.sendTransport(tctx, rt.Key, tx, sm)
+	if err == nil && kid != "" && sessionNotReady(resp) {
+		// First request under a new kid beat the agent's session (hpke_race.go)
+		resp, kid, err = r.awaitSession(tctx, rt, tx, sm, kid, plain, resp)
+	}
+	conn := r.observeConn(rt.Key, base, msg.ContextID, cc)
 	if err != nil {
 		return nil, fmt.Errorf("transport send: %w", err)
 	}
@@ -487,17 +890,39 @@ func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.A
 
 	if !resp.Success {
 		// If upstream rejected our RFC9421 signature, warn loudly (likely body/Content-Digest mutated by proxy).
+		tamper := isSigAuthFail || looksLikeContentDigestIssue(respLow)
+		postureFrom(ctx).noteUpstream(postureHop{Target: agent, Via: rt.Via, Signed: useSAGE, HPKE: kid != "", FieldEncrypted: len(sealed) > 0, Tamper: tamper, Conn: conn})
+		if tamper {
+			r.runs.noteTamper()
+			r.audit.Emit(audit.Event{
+				Type: "security", Action: "upstream.reject", Outcome: "failure", Target: agent,
+				Detail: map[string]any{
+					"upstream":      base,
+					"sigAuthFailed": isSigAuthFail,
+					"digestIssue":   looksLikeContentDigestIssue(respLow),
+					"hpke_kid":      kid,
+					"reason":        redact(respText, 240),
+					"conn":          conn.auditDetail(),
+				},
+			})
+			r.raiseAlert("tamper.suspected", alert.SeverityCritical, agent, msg.ContextID, "upstream rejected a signed request", map[string]any{
+				"upstream":      base,
+				"sigAuthFailed": isSigAuthFail,
+				"digestIssue":   looksLikeContentDigestIssue(respLow),
+			})
+		}
 		if isSigAuthFail {
+			r.noteSignatureFailure(string(r.myDID), agent, msg.ContextID)
 			r.logger.Printf("[root][alert][tamper] ⚠️ upstream rejected signature (agent=%s base=%s). "+
 				"Likely body or Content-Digest was rewritten by a proxy/gateway. "+
 				"Consider disabling gateway tamper mode or enabling HPKE end-to-end. details=%s",
-				agent, base, redact(respText, 240))
+				agent, base, logclip.Clip("root", respText))
 		} else if looksLikeContentDigestIssue(respLow) {
 			r.logger.Printf("[root][alert][tamper] ⚠️ upstream reported Content-Digest mismatch (agent=%s base=%s). details=%s",
-				agent, base, redact(respText, 240))
+				agent, base, logclip.Clip("root", respText))
 		}
 
-		reason := strings.TrimSpace(respText)
+		reason := upstreamErrorText(respText)
 		if reason == "" && resp.Error != nil {
 			reason = resp.Error.Error()
 		}
@@ -518,25 +943,31 @@ func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.A
 				"useSAGE":       useSAGE,
 				"hpkeEnabled":   wantHPKE,
 				"hpke_kid":      kid,
+				"transport":     transportErrorMeta(resp, nil),
 			},
 		}, nil
 	}
 
+	postureFrom(ctx).noteUpstream(postureHop{Target: agent, Via: rt.Via, Signed: useSAGE, HPKE: kid != "", FieldEncrypted: len(sealed) > 0, Accepted: true, Conn: conn})
 	if kid != "" {
-		if pt, _, derr := r.decryptIfHPKEResponse(agent, kid, resp.Data); derr != nil {
-			return &types.AgentMessage{
-				ID:        msg.ID + "-exterr",
-				From:      "external-" + agent,
-				To:        msg.From,
-				Type:      "error",
-				Content:   "external error: " + derr.Error(),
-				Timestamp: time.Now(),
-			}, nil
+		if pt, _, derr := r.decryptIfHPKEResponse(rt.Key, kid, resp.Data); derr != nil {
+			// The upstream accepted and processed the request; only its answer is unreadable
+			postureFrom(ctx).noteTamper()
+			r.audit.Emit(audit.Event{
+				Type: "security", Action: "hpke.response_decrypt_failed", Outcome: "failure", Target: agent,
+				Detail: map[string]any{"upstream": base, "op": c.op, "hpke_kid": kid, "reason": derr.Error(), "conn": conn.auditDetail()},
+			})
+			r.raiseAlert("tamper.suspected", alert.SeverityCritical, agent, msg.ContextID, "HPKE response failed to decrypt", map[string]any{
+				"upstream": base, "op": c.op, "hpke_kid": kid,
+			})
+			return nil, &hpkeResponseError{Agent: agent, KID: kid, Err: derr}
 		} else {
 			resp.Data = pt
 		}
 	}
 
+	resp.Data, _ = r.checkPayload(rt.Key, "response", msg.ContextID, resp.Data, trace)
+
 	var out types.AgentMessage
 	if err := json.Unmarshal(resp.Data, &out); err != nil {
 		out = types.AgentMessage{
@@ -548,6 +979,9 @@ func (r *RootAgent) sendExternal(ctx context.Context, agent string, msg *types.A
 			Timestamp: time.Now(),
 		}
 	}
+	if len(sealed) > 0 {
+		r.noteFieldEncReport(agent, &out)
+	}
 	return &out, nil
 }
 
@@ -630,22 +1064,72 @@ func extractPlanningSlots(msg *types.AgentMessage) (s planningSlots, missing []s
 
 // ---- HTTP handlers ----
 func (r *RootAgent) mountRoutes() {
-	// health
-	r.mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
+	// health: configuration and state that changes on real events (revalidated
+	// with ETag); the live counters are under /status/live
+	r.mux.HandleFunc("/status", func(w http.ResponseWriter, req *http.Request) {
 		resp := map[string]any{
-			"name": r.name,
-			"type": "root",
-			"port": r.port,
+			"name":    r.name,
+			"type":    "root",
+			"version": selfid.Version,
+			"port":    r.boundPort(),
+			"addr":    r.Addr(),
+			"limits": map[string]any{
+				"promptMaxBytes": promptlimit.MaxBytes(),
+				"promptUnit":     "utf8-bytes",
+			},
 			"ext": map[string]any{
 				"planning": r.externalURLFor("planning") != "",
 				"medical":  r.externalURLFor("medical") != "",
 				"payment":  r.externalURLFor("payment") != "",
 			},
+			"signedAuthority": map[string]any{
+				"planning": r.effectiveAuthority("planning"),
+				"medical":  r.effectiveAuthority("medical"),
+				"payment":  r.effectiveAuthority("payment"),
+			},
 			"sage_enabled": r.sageEnabled,
-			"time":         time.Now().Format(time.RFC3339),
+			"run":          r.runs.current(),
+			"operations":   r.ops.snapshot(),
+			"timezone":     tz.Default().String(),
 		}
-		_ = json.NewEncoder(w).Encode(resp)
+		httpcache.WriteJSON(w, req, resp)
+	})
+	// Live counters for dashboards; polled, never revalidated
+	r.mux.HandleFunc("/status/live", func(w http.ResponseWriter, req *http.Request) {
+		httpcache.WriteLive(w, map[string]any{
+			"async":             async.Snapshot(),
+			"requests":          r.reqm.Stats(),
+			"ethPool":           ethpool.Snapshot(),
+			"metricsPush":       reqmetrics.PushSnapshot(),
+			"extraction":        r.payExtractTimings.snapshot(),
+			"audit":             r.audit.Stats(),
+			"schemaDrift":       r.drift.snapshot(),
+			"posture":           r.posture.snapshot(),
+			"headerCheck":       r.headerCheck.snapshot(),
+			"alerts":            r.alerts.Stats(),
+			"stateFiles":        statefile.Snapshot(),
+			"conversationQueue": r.convq.snapshot(),
+			"healthHistory":     r.health.compact(time.Now()),
+			"awaitExpiry":       r.parkedFlowsStatus(r.awaitWindows),
+			"i18n":              i18n.Default.Status(),
+			"upstreamLimits":    r.upLimits.snapshot(),
+			"abandoned":         r.abandoned.snapshot(),
+			"hpkeRaces":         r.hpkeRaces.snapshot(),
+			"llmModel":          llm.ModelStatus(),
+			"time":              time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+	// Prometheus scrape endpoint (push mode: see reqmetrics.StartPushFromEnv)
+	r.mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		reqmetrics.Handler().ServeHTTP(w, req)
+		r.drift.writePrometheus(w)
+		r.posture.writePrometheus(w)
+		statefile.WritePrometheus(w)
+		r.convq.writePrometheus(w)
+		i18n.Default.WritePrometheus(w, "root")
+		r.abandoned.writePrometheus(w)
+		r.hpkeRaces.writePrometheus(w)
+		llm.WriteModelPrometheus(w, "root")
 	})
 
 	// Root-level SAGE toggle
@@ -657,42 +1141,28 @@ func (r *RootAgent) mountRoutes() {
 		var in struct {
 			Enabled bool `json:"enabled"`
 		}
-		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if probs := decodeAdminBody(req, map[string]fieldSpec{
+			"enabled": {Kind: "bool", Required: true},
+		}, &in); len(probs) > 0 {
+			writeInvalidRequest(w, probs)
 			return
 		}
 		r.sageEnabled = in.Enabled
-		_ = json.NewEncoder(w).Encode(map[string]any{"enabled": in.Enabled, "scope": "root"})
+		w.Header().Set("Content-Type", "application/json")
+		st := r.sageStatus()
+		st["enabled"] = in.Enabled
+		st["scope"] = "root"
+		_ = json.NewEncoder(w).Encode(st)
 	})
 
 	// SAGE status
 	r.mux.HandleFunc("/sage/status", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		resp := map[string]any{
-			"root": r.sageEnabled,
-			"ext": map[string]bool{
-				"planning": r.externalURLFor("planning") != "",
-				"medical":  r.externalURLFor("medical") != "",
-				"payment":  r.externalURLFor("payment") != "",
-			},
-			"hpke": map[string]any{
-				"planning": map[string]any{
-					"enabled": r.IsHPKEEnabled("planning"),
-					"kid":     r.CurrentHPKEKID("planning"),
-				},
-				"medical": map[string]any{
-					"enabled": r.IsHPKEEnabled("medical"),
-					"kid":     r.CurrentHPKEKID("medical"),
-				},
-				"payment": map[string]any{
-					"enabled": r.IsHPKEEnabled("payment"),
-					"kid":     r.CurrentHPKEKID("payment"),
-				},
-			},
-			"time": time.Now().Format(time.RFC3339),
-		}
-		_ = json.NewEncoder(w).Encode(resp)
+		httpcache.WriteJSON(w, req, r.sageStatus())
 	})
+	// Probe history per upstream (see health_history.go)
+	r.mux.HandleFunc("/health/history", r.handleHealthHistory)
+	// Guided diagnosis of common misconfigurations (see troubleshoot.go)
+	r.mux.HandleFunc("/troubleshoot", r.handleTroubleshoot)
 
 	// HPKE runtime toggle at Root (per target)
 	r.mux.HandleFunc("/hpke/config", func(w http.ResponseWriter, req *http.Request) {
@@ -704,91 +1174,334 @@ func (r *RootAgent) mountRoutes() {
 			Enabled  bool   `json:"enabled"`
 			Target   string `json:"target,omitempty"`
 			KeysFile string `json:"keysFile,omitempty"`
+			Force    bool   `json:"force,omitempty"`
+			Reason   string `json:"reason,omitempty"`
 		}
-		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if probs := decodeAdminBody(req, map[string]fieldSpec{
+			"enabled":  {Kind: "bool", Required: true},
+			"target":   {Kind: "string", Enum: r.knownTargets()},
+			"keysFile": {Kind: "string"},
+			"force":    {Kind: "bool"},
+			"reason":   {Kind: "string"},
+		}, &in); len(probs) > 0 {
+			writeInvalidRequest(w, probs)
 			return
 		}
 		target := strings.ToLower(strings.TrimSpace(in.Target))
 		if target == "" {
 			target = "payment"
 		}
+		who := requesterOf(req)
+		// Overriding ROOT_HPKE_REQUIRED or choosing the keys file Root reads is admin-only
+		if (in.Force || strings.TrimSpace(in.KeysFile) != "") && !requireAdmin(w, req) {
+			return
+		}
 		if !in.Enabled {
-			r.DisableHPKE(target)
+			if err := r.disableHPKEBy(target, who, strings.TrimSpace(in.Reason), in.Force); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"error":   "hpke_required",
+					"message": err.Error(),
+					"status":  r.hpkeStatus(target),
+				})
+				return
+			}
 		} else {
-			if err := r.EnableHPKE(req.Context(), target, strings.TrimSpace(in.KeysFile)); err != nil {
-				http.Error(w, "hpke init failed: "+err.Error(), http.StatusBadGateway)
+			ctxEn := context.WithValue(req.Context(), ctxRequesterKey, who)
+			if err := r.EnableHPKE(ctxEn, target, strings.TrimSpace(in.KeysFile)); err != nil {
+				if isKEMUnverified(err) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusConflict)
+					_ = json.NewEncoder(w).Encode(map[string]any{"error": kemproof.Code, "reason": err.Error()})
+					return
+				}
+				r.writeHPKEError(w, req, "enable", target, err, nil)
 				return
 			}
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{
-			"enabled": in.Enabled,
-			"target":  target,
-			"kid":     r.CurrentHPKEKID(target),
+		_ = json.NewEncoder(w).Encode(r.hpkeStatus(target))
+	})
+
+	// HPKE re-pin (admin): drop the target's pin and handshake again (pins the
+	// new identity). The current session stays in use until the new one is up,
+	// and is kept, with the old pin, when the handshake fails. The keys file is
+	// Root's own; callers cannot point it elsewhere.
+	r.mux.HandleFunc("/hpke/repin", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requireAdmin(w, req) {
+			return
+		}
+		var in struct {
+			Target string `json:"target"`
+		}
+		if probs := decodeAdminBody(req, map[string]fieldSpec{
+			"target": {Kind: "string", Required: true, Enum: r.knownTargets()},
+		}, &in); len(probs) > 0 {
+			writeInvalidRequest(w, probs)
+			return
+		}
+		target := strings.ToLower(strings.TrimSpace(in.Target))
+		old, had := r.pins.get(target)
+		r.pins.clear(target)
+		ctxEn := context.WithValue(req.Context(), ctxRequesterKey, requesterOf(req))
+		if err := r.EnableHPKE(ctxEn, target, ""); err != nil {
+			if had {
+				r.pins.set(old) // keep the previous pin when the new handshake fails
+			}
+			r.writeHPKEError(w, req, "pin.repin", target, err, map[string]any{"kept": r.CurrentHPKEKID(target)})
+			return
+		}
+		r.hpkeStates.Delete(directKey(target)) // the fallback route's session was made with the old identity
+		newPin, _ := r.pins.get(target)
+		r.logger.Printf("[root][hpke] re-pinned target=%s did=%s -> %s", target, old.ServerDID, newPin.ServerDID)
+		r.audit.Emit(audit.Event{
+			Type: "hpke", Action: "pin.repin", Outcome: "success", Actor: requesterOf(req), Target: target,
+			Detail: map[string]any{"oldDid": old.ServerDID, "oldKem": old.KEMFingerprint, "did": newPin.ServerDID, "kem": newPin.KEMFingerprint},
 		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.hpkeStatus(target))
+	})
+
+	// Key rotation: drop the target's HPKE session + pin (see key_rotation.go)
+	r.mux.HandleFunc("/admin/keys/invalidate", r.handleKeysInvalidate)
+
+	// Compare mode: same request with and without SAGE (admin)
+	r.mux.HandleFunc("/compare", r.handleCompare)
+	// Compare legs that missed the target's SLA budget (admin; see sla.go)
+	r.mux.HandleFunc("/tasks/", r.handleTask)
+
+	// Demo runs (admin): group telemetry of one presentation
+	r.mux.HandleFunc("/admin/run/start", r.handleRunStart)
+	r.mux.HandleFunc("/admin/run/stop", r.handleRunStop)
+	r.mux.HandleFunc("/admin/runs", r.handleRuns)
+
+	// Recent security alerts with per-sink delivery (admin; see alerts.go)
+	r.mux.HandleFunc("/admin/alerts", r.handleAlerts)
+
+	// Conversation forks (demo "what if" branches)
+	r.mux.HandleFunc("/conversations/", r.handleConversations)
+	r.mux.HandleFunc("/admin/forks", r.handleForks)
+
+	// Assembled LLM routing prompt and its hash (admin; see routing_hints.go)
+	r.mux.HandleFunc("/admin/routing/prompt", r.handleRoutingPrompt)
+
+	// Full content of clipped log lines ("details: dbg-…"); admin only
+	r.mux.HandleFunc("/admin/debug/", func(w http.ResponseWriter, req *http.Request) {
+		if !requireAdmin(w, req) {
+			return
+		}
+		e, ok := logclip.Lookup(strings.TrimPrefix(req.URL.Path, "/admin/debug/"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(e)
+	})
+
+	// Overflowed metadata values (DID-authenticated, target agent only)
+	r.mux.HandleFunc("/overflow/", r.handleOverflow)
+
+	// Audit export (NDJSON; admin). Query: from, to (RFC3339), type (comma-separated), run
+	r.mux.HandleFunc("/audit/export", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requireAdmin(w, req) {
+			return
+		}
+		var f audit.Filter
+		q := req.URL.Query()
+		if v := strings.TrimSpace(q.Get("from")); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "bad from (RFC3339)", http.StatusBadRequest)
+				return
+			}
+			f.From = t
+		}
+		if v := strings.TrimSpace(q.Get("to")); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "bad to (RFC3339)", http.StatusBadRequest)
+				return
+			}
+			f.To = t
+		}
+		f.Run = strings.TrimSpace(q.Get("run"))
+		if v := strings.TrimSpace(q.Get("type")); v != "" {
+			f.Types = map[string]bool{}
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					f.Types[t] = true
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if _, err := r.audit.Export(w, f); err != nil {
+			r.logger.Printf("[root][audit] export: %v", err)
+		}
 	})
 
 	// HPKE status (per target)
 	r.mux.HandleFunc("/hpke/status", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
 		target := strings.ToLower(strings.TrimSpace(req.URL.Query().Get("target")))
 		if target == "" {
 			target = "payment"
 		}
-		_ = json.NewEncoder(w).Encode(map[string]any{
-			"target":  target,
-			"enabled": r.IsHPKEEnabled(target),
-			"kid":     r.CurrentHPKEKID(target),
-		})
+		httpcache.WriteJSON(w, req, r.hpkeStatus(target))
 	})
 
 	// Main in-proc processing (full handler)
-	r.mux.HandleFunc("/process", func(w http.ResponseWriter, req *http.Request) {
+	r.processFn = func(w http.ResponseWriter, req *http.Request) {
 		// Method guard
 		if req.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Decode inbound message
+		// A re-run after an upstream redirect reuses the outer request's budget, notes and writer
+		redirTo, redirected := redirectTarget(req.Context())
+		if !redirected {
+			// Request start for per-domain SLA budgets
+			req = req.WithContext(context.WithValue(req.Context(), ctxReqStartKey, time.Now()))
+
+			// Language enforcement note; stamped into the response metadata on the way out
+			req = req.WithContext(llm.WithLangNote(req.Context()))
+			// LLM model pin mismatches (llm/modelpin.go); stamped as metadata llmModelMismatch
+			req = req.WithContext(llm.WithModelNote(req.Context()))
+			// Security posture trace; evaluated and stamped with the language note
+			req = req.WithContext(withPostureTrace(req))
+			// Domain redirects; stamped as metadata "routing"
+			req = req.WithContext(withRouting(req.Context()))
+			// Stale flows parked this turn; the answer offers to resume them
+			req = req.WithContext(withAwaitNote(req.Context()))
+			// How far the turn got, should the client leave (abandon.go)
+			req = req.WithContext(withAbandonNote(req.Context()))
+			// Hop fingerprints of upstream payloads, in debug mode (payload_check.go)
+			req = req.WithContext(withPayloadTrace(req))
+			lw := newLangStampWriter(w, req.Context())
+			lw.posture = r.posture
+			lw.check = r.headerCheck
+			defer lw.flush()
+			w = lw
+		}
+
+		// Decode inbound message (gzip request bodies are inflated here)
 		var msg types.AgentMessage
-		if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		src, err := gzipx.RequestBody(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(src).Decode(&msg); err != nil {
 			http.Error(w, "bad json", http.StatusBadRequest)
 			return
 		}
 		defer req.Body.Close()
+
+		// Inbound metadata policy: only allowlisted, bounded keys reach routing and flows
+		stripped, accepted := r.applyMetaPolicy(w, &msg)
+		if !accepted {
+			return
+		}
+		req = req.WithContext(withMetaStripped(req.Context(), stripped))
+
+		// Prompt size limit (bytes); callers may bypass the client API, so enforce again
+		if limit := promptlimit.MaxBytes(); promptlimit.Check(msg.Content, limit) != nil {
+			allow, _ := msg.Metadata[promptlimit.MetaAllowTruncation].(bool)
+			if !allow {
+				promptlimit.WriteTooLarge(w, promptlimit.Check(msg.Content, limit))
+				return
+			}
+			var cut int
+			msg.Content, cut = promptlimit.Truncate(msg.Content, limit)
+			prev, _ := pickIntFromMeta(msg.Metadata, "truncatedChars")
+			msg.Metadata["truncatedChars"] = prev + cut
+			r.logger.Printf("[root][limit] prompt truncated to %d bytes (removed %d chars)", limit, cut)
+		}
+
 		lang := pickLang(req, &msg)
 		cid := convIDFrom(req, &msg)
+		if !redirected {
+			// Same-conversation turns run one at a time (a redirect re-run already holds the slot)
+			release, ok := r.enterConversation(w, req, &msg, cid, lang)
+			if !ok {
+				return
+			}
+			defer release()
+			// Client gone mid-turn: record it, undo context changes made before any dispatch
+			defer r.settleIfAbandoned(req, cid)()
+		}
+		r.runs.touch(cid)
+		// Conversation time zone: header, metadata or the conversation's earlier setting (timezone.go)
+		req = req.WithContext(r.withTimezone(w, req, &msg, cid))
+		if !redirected {
+			routingFrom(req.Context()).setOrigin(&msg)
+		}
 
-		forcePayment := shouldForcePayment(cid, msg.Content)
+		// Rules/regex see the normalized text; msg.Content stays original for display/forwarding.
+		nmsg := msg
+		nmsg.Content = normalizeInput(msg.Content)
+		llmIn := llmInputVariant(msg.Content, nmsg.Content)
+
+		// Refunds of earlier receipts run their own intent/confirm stages
+		if r.handleRefund(w, req, &msg, nmsg.Content, cid, lang) {
+			return
+		}
+
+		// A held two-step payment waits for "결제 확정" / "capture" (auth_capture.go)
+		if r.handleCapture(w, req, &msg, nmsg.Content, cid, lang) {
+			return
+		}
+
+		// An external agent asked for more input mid-request: this turn answers it
+		if r.handleUpstreamAnswer(w, req, &msg, nmsg.Content, cid, lang) {
+			return
+		}
+
+		// Abandoned intakes stop pinning the conversation; "continue" brings them back
+		if !redirected && r.handleStaleAwaits(w, req, &msg, nmsg.Content, cid, lang) {
+			return
+		}
+
+		forcePayment := !redirected && r.shouldForcePayment(cid, nmsg.Content)
 
 		forceMedical := false
-		if hasMedCtx(cid) {
-			st := getMedCtx(cid)
+		if !redirected && r.hasMedCtx(cid) {
+			st := r.getMedCtx(cid)
 			if strings.TrimSpace(st.Await) != "" ||
 				strings.TrimSpace(st.Slots.Condition) != "" ||
 				strings.TrimSpace(st.Symptoms) != "" {
-				c := strings.ToLower(strings.TrimSpace(msg.Content))
+				c := strings.ToLower(strings.TrimSpace(nmsg.Content))
 				if !isPaymentActionIntent(c) && !isPlanningActionIntent(c) {
 					forceMedical = true
 				}
 			}
 		}
 		agent := ""
-		if forcePayment {
+		if redirected {
+			agent = redirTo
+		} else if forcePayment {
 			agent = "payment"
 		} else if forceMedical {
 			agent = "medical"
 		} else {
-			agent = r.pickAgent(&msg)
+			agent = r.pickAgent(&nmsg)
 			mode := strings.ToLower(strings.TrimSpace(os.Getenv("ROOT_INTENT_MODE")))
 			if mode == "" {
 				mode = "hybrid"
 			}
 			if mode == "llm" || (agent == "" && mode == "hybrid") {
-				if ro, ok := r.llmRoute(req.Context(), msg.Content); ok && ro.Domain != "" {
+				if ro, ok := r.llmRoute(req.Context(), llmIn); ok && ro.Domain != "" {
 					agent = ro.Domain
+					routingFrom(req.Context()).routed(ro.Domain, ro.PromptHash)
 					if msg.Metadata == nil {
 						msg.Metadata = map[string]any{}
 					}
@@ -798,6 +1511,13 @@ func (r *RootAgent) mountRoutes() {
 				}
 			}
 		}
+		// Declined purchase that still reached payment (LLM route / hint): chat unless slots were given
+		if agent == "payment" && !forcePayment && hasNegatedPaymentIntent(nmsg.Content) {
+			if _, ok := r.llmExtractPayment(req.Context(), lang, llmIn); !ok {
+				r.writeNegationAck(req.Context(), w, &msg, cid, lang)
+				return
+			}
+		}
 		// -------- CHAT MODE: no routing; answer with LLM directly --------
 		if agent == "" && !forcePayment {
 			r.ensureLLM()
@@ -807,7 +1527,7 @@ func (r *RootAgent) mountRoutes() {
 					"ko": "너는 간결한 한국어 어시스턴트야. 짧게 답해.",
 					"en": "You are a concise assistant. Reply briefly.",
 				}[lang]
-				if out, err := r.llmClient.Chat(req.Context(), sys, strings.TrimSpace(msg.Content)); err == nil {
+				if out, err := r.chatUser(req.Context(), lang, sys, strings.TrimSpace(msg.Content)); err == nil {
 					reply = strings.TrimSpace(out)
 				}
 			}
@@ -822,9 +1542,7 @@ func (r *RootAgent) mountRoutes() {
 				ID: msg.ID + "-chat", From: "root", To: msg.From, Type: "response", Content: reply,
 				Timestamp: time.Now(), Metadata: map[string]any{"lang": lang, "mode": "chat"},
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(out)
+			r.writeChat(req.Context(), w, cid, out)
 			return
 		}
 
@@ -834,13 +1552,28 @@ func (r *RootAgent) mountRoutes() {
 		case "payment":
 			{
 				// ---- Common: entry/stage logging ----
-				stage, token := getStageToken(cid)
-				r.logger.Printf("[root][payment][enter] cid=%s stage=%s token=%s lang=%s text=%q",
-					cid, stage, token, lang, strings.TrimSpace(msg.Content))
+				stage, token := r.getStageToken(cid)
+				turn := r.nextPayTurn(cid)
+				r.logger.Printf("[root][payment][enter] cid=%s stage=%s token=%s turn=%d lang=%s text=%q",
+					cid, stage, token, turn, lang, strings.TrimSpace(msg.Content))
+				seed := styleSeedFor(cid, turn)
+				req = req.WithContext(withStyleSeed(req.Context(), seed))
+
+				// ==== In-flight send: reject duplicate confirms ====
+				if stage == "sending" {
+					writePaymentInFlight(w, &msg, cid, lang)
+					return
+				}
+
+				// ==== Re-quotation confirmation ====
+				if stage == "await_requote" && token != "" {
+					r.handleRequoteAnswer(w, req, &msg, cid, lang, token)
+					return
+				}
 
 				// ==== Confirmation step handling ====
 				if stage == "await_confirm" && token != "" {
-					yes, no := parseYesNo(msg.Content)
+					yes, no := parseYesNo(nmsg.Content)
 					r.logger.Printf("[root][payment][confirm] parsed yes=%v no=%v", yes, no)
 
 					if !yes && !no {
@@ -854,22 +1587,18 @@ func (r *RootAgent) mountRoutes() {
 						}
 						if !yes && !no {
 							// Try additional slot extraction even in confirmation step
-							slots := getPayCtx(cid)
+							slots := r.getPayCtx(cid)
 							r.logger.Printf("[root][payment][confirm] before-merge slots: method=%q to=%q recipient=%q shipping=%q merchant=%q budget=%d amount=%d item=%q model=%q",
 								slots.Method, slots.To, slots.Recipient, slots.Shipping, slots.Merchant, slots.BudgetKRW, slots.AmountKRW, slots.Item, slots.Model)
 
-							if xo, ok := r.llmExtractPayment(req.Context(), lang, msg.Content); ok {
+							if xo, ok := r.llmExtractPayment(req.Context(), lang, llmIn); ok {
 
 								r.logger.Printf("[root][payment][confirm] xo: mode=%s method=%q to=%q shipping=%q merchant=%q amount=%d budget=%d item=%q model=%q",
 									xo.Fields.Mode, xo.Fields.Method, xo.Fields.To, xo.Fields.Shipping, xo.Fields.Merchant, xo.Fields.AmountKRW, xo.Fields.BudgetKRW, xo.Fields.Item, xo.Fields.Model)
 
-								// 🔧 Hotfix: merge both To and Recipient (prevents missing recipient)
-								slots = mergePaySlots(slots, paySlots{
-									Mode: xo.Fields.Mode, To: xo.Fields.To,
-									AmountKRW: xo.Fields.AmountKRW, BudgetKRW: xo.Fields.BudgetKRW,
-									Method: xo.Fields.Method, Item: xo.Fields.Item, Model: xo.Fields.Model,
-									Merchant: xo.Fields.Merchant, Shipping: xo.Fields.Shipping, CardLast4: xo.Fields.CardLast4,
-								})
+								xs := paySlotsFromXO(xo, llmIn)
+								xs.Prov.stamp(turn)
+								slots = mergePaySlots(slots, xs)
 								r.logger.Printf("[root][payment][confirm] after-merge slots: method=%q to=%q recipient=%q shipping=%q merchant=%q budget=%d amount=%d item=%q model=%q",
 									slots.Method, slots.To, slots.Recipient, slots.Shipping, slots.Merchant, slots.BudgetKRW, slots.AmountKRW, slots.Item, slots.Model)
 
@@ -878,13 +1607,13 @@ func (r *RootAgent) mountRoutes() {
 
 								if len(missing) == 0 {
 									preview := buildPaymentPreview(lang, slots)
-									putPayCtxFull(cid, slots, "await_confirm", token)
+									r.putPayCtxFull(cid, slots, "await_confirm", token)
 									r.logger.Printf("[root][payment][confirm] preview-ready; await_confirm token=%s", token)
 									out := types.AgentMessage{
 										ID: msg.ID + "-preview", From: "root", To: msg.From, Type: "confirm",
 										Content:   preview + "\n" + r.buildConfirmPromptLLM(req.Context(), lang, slots),
 										Timestamp: time.Now(),
-										Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment", "mode": slots.Mode, "confirmToken": token},
+										Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment", "mode": slots.Mode, "confirmToken": token, "provenance": slots.Prov.compact(), "styleSeed": seed},
 									}
 									w.Header().Set("Content-Type", "application/json")
 									w.WriteHeader(http.StatusOK)
@@ -893,12 +1622,12 @@ func (r *RootAgent) mountRoutes() {
 								}
 
 								q := r.askForMissingPaymentWithLLM(req.Context(), lang, slots, missing, msg.Content)
-								putPayCtxFull(cid, slots, "collect", "")
+								r.putPayCtxFull(cid, slots, "collect", "")
 								r.logger.Printf("[root][payment][confirm] ask-missing %v q=%q", missing, q)
 								out := types.AgentMessage{
 									ID: msg.ID + "-needinfo", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
 									Content: q, Timestamp: time.Now(),
-									Metadata: map[string]any{"await": "payment.slots", "missing": strings.Join(missing, ", "), "lang": lang, "domain": "payment", "mode": slots.Mode},
+									Metadata: map[string]any{"await": "payment.slots", "missing": strings.Join(missing, ", "), "lang": lang, "domain": "payment", "mode": slots.Mode, "provenance": slots.Prov.compact()},
 								}
 								w.Header().Set("Content-Type", "application/json")
 								w.WriteHeader(http.StatusOK)
@@ -910,122 +1639,31 @@ func (r *RootAgent) mountRoutes() {
 
 					if yes {
 						// 1) Load final slots
-						slots := getPayCtx(cid)
+						slots := r.getPayCtx(cid)
 						r.logger.Printf("[root][payment][send] YES; final slots: method=%q to=%q recipient=%q shipping=%q merchant=%q amount=%d budget=%d item=%q model=%q",
 							slots.Method, slots.To, slots.Recipient, slots.Shipping, slots.Merchant, slots.AmountKRW, slots.BudgetKRW, slots.Item, slots.Model)
 
-						// 2) Inject required metadata
-						if msg.Metadata == nil {
-							msg.Metadata = map[string]any{}
-						}
-						msg.Metadata["lang"] = lang
-
-						// amount: fall back to budget when explicit amount is missing
-						amt := slots.AmountKRW
-						if amt <= 0 && slots.BudgetKRW > 0 {
-							amt = slots.BudgetKRW
-							msg.Metadata["payment.amountIsEstimated"] = true
-						}
-						if amt > 0 {
-							msg.Metadata["payment.amountKRW"] = amt
-							msg.Metadata["amountKRW"] = amt // 호환 키
-						}
-
-						// recipient/method/item/merchant/shipping/card last4
-						if v := strings.TrimSpace(firstNonEmpty(slots.Recipient, slots.To)); v != "" {
-							msg.Metadata["payment.to"] = v
-							msg.Metadata["to"] = v // 호환 키
-							msg.Metadata["recipient"] = v
-						}
-						if v := strings.TrimSpace(slots.Method); v != "" {
-							msg.Metadata["payment.method"] = v
-							msg.Metadata["method"] = v
-						}
-						if v := firstNonEmpty(strings.TrimSpace(slots.Model), strings.TrimSpace(slots.Item)); v != "" {
-							msg.Metadata["payment.item"] = v
-							msg.Metadata["item"] = v
-						}
-						if v := strings.TrimSpace(slots.Merchant); v != "" {
-							msg.Metadata["payment.merchant"] = v
-						}
-						if v := strings.TrimSpace(slots.Shipping); v != "" {
-							msg.Metadata["payment.shipping"] = v
-						}
-						if v := strings.TrimSpace(slots.CardLast4); v != "" {
-							msg.Metadata["payment.cardLast4"] = v
-						}
-						r.logger.Printf("[root][payment][send] injected meta: amount=%d method=%q to/recipient=%q shipping=%q merchant=%q",
-							amt, slots.Method, firstNonEmpty(slots.Recipient, slots.To), slots.Shipping, slots.Merchant)
-
-						// 3) Handle per-request SAGE/HPKE headers
-						sageRaw := strings.TrimSpace(req.Header.Get("X-SAGE-Enabled"))
-						hpkeRaw := strings.TrimSpace(req.Header.Get("X-HPKE-Enabled"))
-						r.logger.Printf("[root][payment][send] headers SAGE=%q HPKE=%q", sageRaw, hpkeRaw)
-
-						if hpkeRaw != "" && strings.EqualFold(hpkeRaw, "true") {
-							if sageRaw != "" && !strings.EqualFold(sageRaw, "true") {
-								w.Header().Set("Content-Type", "application/json")
-								w.WriteHeader(http.StatusBadRequest)
-								_ = json.NewEncoder(w).Encode(map[string]any{
-									"error":   "bad_request",
-									"message": "HPKE requires SAGE to be enabled (X-SAGE-Enabled: true)",
-								})
-								return
-							}
-						}
-						ctx2 := req.Context()
-						if sageRaw != "" {
-							ctx2 = context.WithValue(ctx2, ctxUseSAGEKey, strings.EqualFold(sageRaw, "true"))
-						}
-						if hpkeRaw != "" {
-							ctx2 = context.WithValue(ctx2, ctxHPKERawKey, hpkeRaw)
+						// 2) Claim the confirm token (a racing second "yes" must not double-charge)
+						if !r.claimPayToken(cid, "await_confirm", token) {
+							r.logger.Printf("[root][payment][send] cid=%s token already claimed; ignoring duplicate confirm", cid)
+							writePaymentInFlight(w, &msg, cid, lang)
+							return
 						}
 
-						// 4) Send to external (actual payment)
-						r.logger.Printf("[root][payment][send] -> sendExternal(payment)")
-						outPtr, err := r.sendExternal(ctx2, "payment", &msg)
-						if err != nil {
-							r.logger.Printf("[root][payment][send][error] %v", err)
-							http.Error(w, "agent error: "+err.Error(), http.StatusBadGateway)
+						// 3) Re-quote estimated amounts against the pricing source (if configured)
+						q, paused := r.maybeRequote(w, req, &msg, cid, lang, slots)
+						if paused {
 							return
 						}
-						out := *outPtr
-						if strings.EqualFold(out.Type, "error") || strings.HasPrefix(strings.ToLower(out.Content), "external error:") {
-							r.logger.Printf("[root][payment][forward][ERR] cid=%s %s", cid, redact(out.Content, 240))
-							if looksLikeSigAuthFailure(strings.ToLower(out.Content)) || looksLikeContentDigestIssue(strings.ToLower(out.Content)) {
-								r.logger.Printf("[root][alert][tamper] ⚠️ cid=%s suspected tamper via gateway (payment). Check GW tamper mode/ATTACK_MESSAGE. details=%s",
-									cid, redact(out.Content, 240))
-							}
-						} else {
-							r.logger.Printf("[root][payment][forward] cid=%s -> external ok", cid)
-						}
-						// Clear context on success
-						if strings.EqualFold(out.Type, "response") && !strings.HasPrefix(strings.ToLower(out.Content), "external error:") {
-							r.logger.Printf("[root][payment][ctx] delPayCtx cid=%s", cid)
-							delPayCtx(cid)
-						}
 
-						// Response
-						status := http.StatusOK
-						if code, ok := httpStatusFromAgent(&out); ok {
-							status = code
-						}
-						w.Header().Set("Content-Type", "application/json")
-						if status/100 == 2 {
-							w.Header().Set("X-SAGE-Verified", "true")
-							w.Header().Set("X-SAGE-Signature-Valid", "true")
-						} else {
-							w.Header().Set("X-SAGE-Verified", "false")
-							w.Header().Set("X-SAGE-Signature-Valid", "false")
-						}
-						w.WriteHeader(status)
-						_ = json.NewEncoder(w).Encode(out)
+						// 4) Inject metadata, send, respond
+						r.forwardPayment(w, req, &msg, cid, lang, slots, q, "await_confirm")
 						return
 					}
 
 					if no {
 						r.logger.Printf("[root][payment][confirm] NO -> back to collect")
-						putPayCtxFull(cid, getPayCtx(cid), "collect", "")
+						r.putPayCtxFull(cid, r.getPayCtx(cid), "collect", "")
 						out := types.AgentMessage{
 							ID: msg.ID + "-cancel", ContextID: cid, From: "root", To: msg.From, Type: "response",
 							Content: map[string]string{
@@ -1044,9 +1682,9 @@ func (r *RootAgent) mountRoutes() {
 					r.logger.Printf("[root][payment][confirm] ambiguous -> ask confirm again")
 					out := types.AgentMessage{
 						ID: msg.ID + "-confirm", From: "root", To: msg.From, Type: "clarify",
-						Content:   r.buildConfirmPromptLLM(req.Context(), lang, getPayCtx(cid)),
+						Content:   r.buildConfirmPromptLLM(req.Context(), lang, r.getPayCtx(cid)),
 						Timestamp: time.Now(),
-						Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment"},
+						Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment", "styleSeed": seed},
 					}
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusOK)
@@ -1055,37 +1693,60 @@ func (r *RootAgent) mountRoutes() {
 				}
 
 				// ==== Collect stage ====
-				slots := getPayCtx(cid)
+				if r.handleClarifyAbandon(w, &msg, cid, "payment", lang) {
+					return
+				}
+				slots := r.getPayCtx(cid)
+
+				// Handoff: a medical conversation pivoting to a purchase carries the medication over
+				if stage == "" && !payCtxNotEmpty(slots) {
+					if carried, ok := r.carryFromMedical(cid, turn); ok {
+						slots = carried
+						r.logger.Printf("[root][payment][handoff] cid=%s carried from medical: %v", cid, carriedFields(slots))
+					}
+				} else if len(carriedFields(slots)) > 0 {
+					if _, no := parseYesNo(nmsg.Content); no {
+						r.logger.Printf("[root][payment][handoff] cid=%s carried slots rejected", cid)
+						slots = dropCarried(slots)
+					}
+				}
 				r.logger.Printf("[root][payment][collect] before-merge slots: method=%q to=%q recipient=%q shipping=%q merchant=%q budget=%d amount=%d item=%q model=%q",
 					slots.Method, slots.To, slots.Recipient, slots.Shipping, slots.Merchant, slots.BudgetKRW, slots.AmountKRW, slots.Item, slots.Model)
 
-				// LLM extraction → augment with manual extraction
-				if xo, ok := r.llmExtractPayment(req.Context(), lang, msg.Content); ok {
+				carried := slots
+
+				// Short clarify answers: rules first, LLM only if they filled nothing asked for
+				extStart, extractor := time.Now(), "llm"
+				if ruleFirstEligible(stage, nmsg.Content, slots) {
+					prev := computeMissingPayment(slots)
+					if cand := mergePaySlots(slots, rulePaySlots(&nmsg, turn)); filledAny(prev, cand) {
+						slots, extractor = cand, "rule"
+						r.logger.Printf("[root][payment][collect] rule-first hit; skipping LLM extractor")
+					}
+				}
 
-					r.logger.Printf("[root][payment][collect] xo: mode=%s method=%q to=%q shipping=%q merchant=%q amount=%d budget=%d item=%q model=%q",
-						xo.Fields.Mode, xo.Fields.Method, xo.Fields.To, xo.Fields.Shipping, xo.Fields.Merchant, xo.Fields.AmountKRW, xo.Fields.BudgetKRW, xo.Fields.Item, xo.Fields.Model)
+				// LLM extraction → augment with manual extraction (skipped on a rule-first hit)
+				if extractor == "llm" {
+					if xo, ok := r.llmExtractPayment(req.Context(), lang, llmIn); ok {
 
-					// 🔧 Hotfix: merge both To and Recipient
-					slots = mergePaySlots(slots, paySlots{
-						Mode: xo.Fields.Mode, To: xo.Fields.To,
-						AmountKRW: xo.Fields.AmountKRW, BudgetKRW: xo.Fields.BudgetKRW,
-						Method: xo.Fields.Method, Item: xo.Fields.Item, Model: xo.Fields.Model,
-						Merchant: xo.Fields.Merchant, Shipping: xo.Fields.Shipping,
-					})
-				} else {
-					if s2, _, _ := extractPaymentSlots(&msg); true {
-						if s2.To == "" && strings.TrimSpace(s2.Recipient) != "" {
-							s2.To = s2.Recipient
-						}
-						if s2.Recipient == "" && strings.TrimSpace(s2.To) != "" {
-							s2.Recipient = s2.To
-						}
-						slots = mergePaySlots(slots, s2)
+						r.logger.Printf("[root][payment][collect] xo: mode=%s method=%q to=%q shipping=%q merchant=%q amount=%d budget=%d item=%q model=%q",
+							xo.Fields.Mode, xo.Fields.Method, xo.Fields.To, xo.Fields.Shipping, xo.Fields.Merchant, xo.Fields.AmountKRW, xo.Fields.BudgetKRW, xo.Fields.Item, xo.Fields.Model)
+
+						xs := paySlotsFromXO(xo, llmIn)
+						xs.Prov.stamp(turn)
+						slots = mergePaySlots(slots, xs)
+					} else {
+						slots = mergePaySlots(slots, rulePaySlots(&nmsg, turn))
+						extractor = "rule-fallback"
 					}
 				}
+				slots = keepCarried(carried, slots)
+				extDur := time.Since(extStart)
+				r.payExtractTimings.observe(extractor, extDur)
 
 				if strings.TrimSpace(slots.Mode) == "" {
-					slots.Mode = classifyPaymentMode(msg.Content, slots)
+					slots.Mode = classifyPaymentMode(nmsg.Content, slots)
+					markProv(&slots.Prov, "mode", slotSource{Src: provRule, Turn: turn})
 				}
 				r.logger.Printf("[root][payment][collect] after-merge slots: method=%q to=%q recipient=%q shipping=%q merchant=%q budget=%d amount=%d item=%q model=%q",
 					slots.Method, slots.To, slots.Recipient, slots.Shipping, slots.Merchant, slots.BudgetKRW, slots.AmountKRW, slots.Item, slots.Model)
@@ -1094,14 +1755,21 @@ func (r *RootAgent) mountRoutes() {
 				r.logger.Printf("[root][payment][collect] missing=%v", missing)
 
 				if len(missing) > 0 {
+					if d := r.noteClarify(cid, "payment", paySlotsSig(slots)); d.limited {
+						r.putPayCtxFull(cid, slots, "collect", "")
+						r.writeClarifyLimit(w, req, &msg, cid, "payment", lang, paySummary(lang, slots), missing, d)
+						return
+					}
 					q := r.askForMissingPaymentWithLLM(req.Context(), lang, slots, missing, msg.Content)
-					putPayCtxFull(cid, slots, "collect", "")
+					q += carriedNote(lang, slots)
+					r.putPayCtxFull(cid, slots, "collect", "")
 					r.logger.Printf("[root][payment][collect] ask-missing %v q=%q", missing, q)
+					r.prewarmLLM()
 					out := types.AgentMessage{
 						ID: msg.ID + "-needinfo", From: "root", To: msg.From, Type: "clarify",
 						Content:   strings.TrimSpace(q),
 						Timestamp: time.Now(),
-						Metadata:  map[string]any{"await": "payment.slots", "missing": strings.Join(missing, ", "), "lang": lang, "domain": "payment", "mode": slots.Mode},
+						Metadata:  map[string]any{"await": "payment.slots", "missing": strings.Join(missing, ", "), "lang": lang, "domain": "payment", "mode": slots.Mode, "provenance": slots.Prov.compact(), "timings": turnTimings(req, extractor, extDur)},
 					}
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusOK)
@@ -1110,15 +1778,16 @@ func (r *RootAgent) mountRoutes() {
 				}
 
 				// ==== Preview + confirm ====
+				r.resetClarify(cid, "payment")
 				preview := buildPaymentPreview(lang, slots)
 				token2 := uuid.NewString()
-				putPayCtxFull(cid, slots, "await_confirm", token2)
+				r.putPayCtxFull(cid, slots, "await_confirm", token2)
 				r.logger.Printf("[root][payment][collect] preview-ready; await_confirm token=%s", token2)
 				out := types.AgentMessage{
 					ID: msg.ID + "-preview", ContextID: cid, From: "root", To: msg.From, Type: "confirm",
 					Content:   preview + "\n" + r.buildConfirmPromptLLM(req.Context(), lang, slots),
 					Timestamp: time.Now(),
-					Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment", "mode": slots.Mode, "confirmToken": token2},
+					Metadata:  map[string]any{"await": "payment.confirm", "lang": lang, "domain": "payment", "mode": slots.Mode, "confirmToken": token2, "provenance": slots.Prov.compact(), "styleSeed": seed, "timings": turnTimings(req, extractor, extDur)},
 				}
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusOK)
@@ -1132,9 +1801,12 @@ func (r *RootAgent) mountRoutes() {
 			cid := convIDFrom(req, &msg)
 
 			r.logger.Printf("[root][medical][enter] cid=%s lang=%s text=%q", cid, lang, strings.TrimSpace(msg.Content))
+			if r.handleClarifyAbandon(w, &msg, cid, "medical", lang) {
+				return
+			}
 
 			// Load context & accumulate history
-			st := getMedCtx(cid)
+			st := r.getMedCtx(cid)
 			utter := strings.TrimSpace(msg.Content)
 			if utter != "" {
 				st.Transcript = append(st.Transcript, utter)
@@ -1144,28 +1816,32 @@ func (r *RootAgent) mountRoutes() {
 			}
 
 			// 1) Merge local keyword extraction
-			cur := extractMedicalCore(&msg)
+			cur := extractMedicalCore(&nmsg)
 
 			// Await hint: if previous turn asked for "symptoms/condition", accept this input as-is
 			if st.Await == "symptoms" && strings.TrimSpace(cur.Symptoms) == "" && utter != "" {
 				cur.Symptoms = utter
+				markProv(&cur.Prov, "symptoms", slotSource{Src: provUser})
 			}
 			if st.Await == "condition" && strings.TrimSpace(cur.Slots.Condition) == "" && utter != "" {
 				cur.Slots.Condition = utter
+				markProv(&cur.Prov, "condition", slotSource{Src: provUser})
 			}
+			medTurn := len(st.Transcript)
+			cur.Prov.stamp(medTurn)
 
 			st = mergeMedCtx(st, cur)
-			putMedCtx(cid, st)
+			r.putMedCtx(cid, st)
 			r.logger.Printf("[root][medical][merge] cid=%s cond=%q symptoms.len=%d",
 				cid, st.Slots.Condition, len(strings.TrimSpace(st.Symptoms)))
 
 			// 2) Augment via LLM extraction
 			var xo medicalXO
-			if got, ok := r.llmExtractMedical(req.Context(), lang, utter); ok {
+			if got, ok := r.llmExtractMedical(req.Context(), lang, llmInputVariant(utter, nmsg.Content)); ok {
 				xo = got
 
 				// Fill only empty fields from LLM result (symptoms handled separately)
-				st = mergeMedCtx(st, medCtx{
+				lx := medCtx{
 					Slots: medicalSlots{
 						Condition:   xo.Fields.Condition,
 						Topic:       xo.Fields.Topic,
@@ -1175,8 +1851,11 @@ func (r *RootAgent) mountRoutes() {
 						Medications: xo.Fields.Medications,
 						Symptoms:    xo.Fields.Symptoms, // LLM이 준 증상 텍스트(있다면)
 					},
-				})
-				putMedCtx(cid, st)
+				}
+				tagMedCtx(&lx, provLLM)
+				lx.Prov.stamp(medTurn)
+				st = mergeMedCtx(st, lx)
+				r.putMedCtx(cid, st)
 
 				r.logger.Printf("[root][medical][llm-xo] cid=%s cond=%q topic=%q symptoms.len=%d missing=%v ask=%q",
 					cid, st.Slots.Condition, st.Slots.Topic, len(strings.TrimSpace(st.Symptoms)), xo.Missing, xo.Ask)
@@ -1209,6 +1888,9 @@ func (r *RootAgent) mountRoutes() {
 					msg.Metadata["medical.initial_question"] = v
 				}
 				msg.Metadata["medical.last_message"] = utter
+				if len(st.Prov) > 0 {
+					msg.Metadata["medical.provenance"] = st.Prov.compact()
+				}
 				if len(st.Transcript) > 0 {
 					msg.Metadata["medical.history"] = st.Transcript
 					msg.Metadata["medical.history_len"] = len(st.Transcript)
@@ -1246,38 +1928,40 @@ func (r *RootAgent) mountRoutes() {
 				}
 
 				// External send
-				outPtr, err := r.sendExternal(ctx2, "medical", &msg)
+				outPtr, err := r.sendWithSLA(ctx2, "medical", opProcess, &msg)
 				if err != nil {
 					r.logger.Printf("[root][medical][forward][err] cid=%s: %v", cid, err)
-					http.Error(w, "agent error: "+err.Error(), http.StatusBadGateway)
+					r.writeSendError(w, req, lang, "medical", err)
 					return
 				}
 				out := *outPtr
+				// Medical says the request is not medical: drop its context and re-route
+				if isRedirect(&out) {
+					r.delMedCtx(cid)
+					r.resetClarify(cid, "medical")
+					r.followRedirect(w, req, "medical", cid, &out)
+					return
+				}
 				if strings.EqualFold(out.Type, "error") || strings.HasPrefix(strings.ToLower(out.Content), "external error:") {
-					r.logger.Printf("[root][medical][forward][ERR] cid=%s %s", cid, redact(out.Content, 240))
+					r.logger.Printf("[root][medical][forward][ERR] cid=%s %s", cid, logclip.Clip("root", out.Content))
 					if looksLikeSigAuthFailure(strings.ToLower(out.Content)) || looksLikeContentDigestIssue(strings.ToLower(out.Content)) {
 						r.logger.Printf("[root][alert][tamper] ⚠️ cid=%s suspected tamper via gateway (medical). Check GW tamper mode/ATTACK_MESSAGE. details=%s",
-							cid, redact(out.Content, 240))
+							cid, logclip.Clip("root", out.Content))
 					}
 				} else {
 					r.logger.Printf("[root][medical][forward] cid=%s -> external ok", cid)
 				}
 				// If conversation continues, you can skip reset; here we just clear awaiting state.
 				st.Await = ""
-				putMedCtx(cid, st)
+				r.putMedCtx(cid, st)
+				r.resetClarify(cid, "medical")
 
 				status := http.StatusOK
 				if code, ok := httpStatusFromAgent(&out); ok {
 					status = code
 				}
 				w.Header().Set("Content-Type", "application/json")
-				if status/100 == 2 {
-					w.Header().Set("X-SAGE-Verified", "true")
-					w.Header().Set("X-SAGE-Signature-Valid", "true")
-				} else {
-					w.Header().Set("X-SAGE-Verified", "false")
-					w.Header().Set("X-SAGE-Signature-Valid", "false")
-				}
+				r.presentOut(req, lang, "medical", &out, status)
 				w.WriteHeader(status)
 				_ = json.NewEncoder(w).Encode(out)
 				return
@@ -1286,6 +1970,10 @@ func (r *RootAgent) mountRoutes() {
 			// 4) Missing → generate question (prefer LLM ask, else fallback rules)
 			{
 				missing := medicalMissing(st)
+				if d := r.noteClarify(cid, "medical", medSlotsSig(st)); d.limited {
+					r.writeClarifyLimit(w, req, &msg, cid, "medical", lang, medSummary(lang, st), missing, d)
+					return
+				}
 
 				ask := strings.TrimSpace(xo.Ask)
 				if ask == "" {
@@ -1319,7 +2007,7 @@ func (r *RootAgent) mountRoutes() {
 					}
 				}
 
-				putMedCtx(cid, st)
+				r.putMedCtx(cid, st)
 				r.logger.Printf("[root][medical][ask] cid=%s await=%s missing=%v q=%q", cid, st.Await, missing, ask)
 
 				clar := types.AgentMessage{
@@ -1331,9 +2019,10 @@ func (r *RootAgent) mountRoutes() {
 					Content:   ask,
 					Timestamp: time.Now(),
 					Metadata: map[string]any{
-						"await":   "medical." + st.Await,
-						"missing": strings.Join(missing, ", "),
-						"lang":    lang,
+						"await":      "medical." + st.Await,
+						"missing":    strings.Join(missing, ", "),
+						"lang":       lang,
+						"provenance": st.Prov.compact(),
 					},
 				}
 				w.Header().Set("Content-Type", "application/json")
@@ -1345,9 +2034,10 @@ func (r *RootAgent) mountRoutes() {
 		// ===== PLANNING =====
 		case "planning":
 			lang := pickLang(req, &msg)
+			planTurn := r.nextPlanTurn(cid)
 
 			// 1) Try LLM slot-extractor first
-			if xo, ok := r.llmExtractPlanning(req.Context(), lang, msg.Content); ok {
+			if xo, ok := r.llmExtractPlanning(req.Context(), lang, llmIn); ok {
 				if len(xo.Missing) > 0 {
 					clar := types.AgentMessage{
 						ID:        msg.ID + "-needinfo",
@@ -1372,6 +2062,7 @@ func (r *RootAgent) mountRoutes() {
 					return
 				}
 				fillMsgMetaFromPlanning(&msg, xo.Fields, lang)
+				msg.Metadata["planning.provenance"] = planningProv(xo.Fields, provLLM, planTurn).compact()
 			} else {
 				// 2) Fallback to rule-based extractor
 				slots, missing := extractPlanningSlots(&msg)
@@ -1410,8 +2101,12 @@ func (r *RootAgent) mountRoutes() {
 					msg.Metadata["planning.context"] = slots.Context
 				}
 				msg.Metadata["lang"] = lang
+				msg.Metadata["planning.provenance"] = planningProv(slots, provMetadata, planTurn).compact()
 			}
 
+			// "tomorrow" is the user's tomorrow, not the server's
+			dateTimeframe(req.Context(), &msg)
+
 			// If no external URL, summarize locally with LLM
 			if r.externalURLFor("planning") == "" {
 				r.ensureLLM()
@@ -1421,10 +2116,19 @@ func (r *RootAgent) mountRoutes() {
 						From:      "root",
 						To:        msg.From,
 						Type:      "response",
-						Content:   map[string]string{"ko": "계획 요약을 생성하려면 LLM 설정이 필요해요.", "en": "LLM is required to generate a planning summary."}[lang],
+						Content:   planFailedText["no_llm"][langOrDefault(lang)],
 						Timestamp: time.Now(),
-						Metadata:  map[string]any{"lang": lang, "mode": "planning", "domain": "planning"},
+						Metadata: map[string]any{
+							"lang": lang, "mode": "planning", "domain": "planning",
+							"planning.plan": planningPlan{
+								Goal:      strFrom(msg.Metadata, "planning.task", "task", "goal"),
+								Timeframe: strFrom(msg.Metadata, "planning.timeframe", "timeframe"),
+								Source:    "local",
+							}.asMeta(),
+							"planning.source": "local",
+						},
 					}
+					routingFrom(req.Context()).answeredBy("planning", "local", "fallback")
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusOK)
 					_ = json.NewEncoder(w).Encode(out)
@@ -1432,10 +2136,11 @@ func (r *RootAgent) mountRoutes() {
 				}
 				ps := planningSlots{
 					Task:      strFrom(msg.Metadata, "planning.task", "task", "goal"),
-					Timeframe: strFrom(msg.Metadata, "planning.timeframe", "timeframe"),
+					Timeframe: datedTimeframe(msg.Metadata),
 					Context:   strFrom(msg.Metadata, "planning.context", "context"),
 				}
-				answer := r.llmPlanningAnswer(req.Context(), lang, msg.Content, ps)
+				answer, plan := r.llmPlanningStructured(req.Context(), lang, msg.Content, ps)
+				routingFrom(req.Context()).answeredBy("planning", "local", plan.Origin)
 
 				out := types.AgentMessage{
 					ID:        msg.ID + "-planning",
@@ -1444,7 +2149,11 @@ func (r *RootAgent) mountRoutes() {
 					Type:      "response",
 					Content:   answer,
 					Timestamp: time.Now(),
-					Metadata:  map[string]any{"lang": lang, "mode": "planning", "domain": "planning"},
+					Metadata: map[string]any{
+						"lang": lang, "mode": "planning", "domain": "planning",
+						"planning.plan":   plan.asMeta(),
+						"planning.source": "local",
+					},
 				}
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusOK)
@@ -1476,33 +2185,83 @@ func (r *RootAgent) mountRoutes() {
 		}
 
 		// -------- External send through Root (signing/HPKE handled inside) --------
-		outPtr, err := r.sendExternal(ctx, agent, &msg)
+		outPtr, err := r.sendWithSLA(ctx, agent, opProcess, &msg)
 		if err != nil {
-			http.Error(w, "agent error: "+err.Error(), http.StatusBadGateway)
+			r.writeSendError(w, req, pickLang(req, &msg), agent, err)
 			return
 		}
 		out := *outPtr
+		if isRedirect(&out) {
+			r.followRedirect(w, req, agent, cid, &out)
+			return
+		}
+		if agent == "planning" {
+			normalizePlanningResponse(&out, planningSlots{
+				Task:      strFrom(msg.Metadata, "planning.task", "task", "goal"),
+				Timeframe: strFrom(msg.Metadata, "planning.timeframe", "timeframe"),
+			})
+			routingFrom(req.Context()).answeredBy("planning", "external", "")
+		}
 
 		status := http.StatusOK
 		if code, ok := httpStatusFromAgent(&out); ok {
 			status = code
 		}
 		w.Header().Set("Content-Type", "application/json")
-		if status/100 == 2 {
-			w.Header().Set("X-SAGE-Verified", "true")
-			w.Header().Set("X-SAGE-Signature-Valid", "true")
-		} else {
-			w.Header().Set("X-SAGE-Verified", "false")
-			w.Header().Set("X-SAGE-Signature-Valid", "false")
-		}
+		r.presentOut(req, pickLang(req, &msg), agent, &out, status)
 		w.WriteHeader(status)
 		_ = json.NewEncoder(w).Encode(out)
-	})
+	}
+	r.mux.HandleFunc("/process", r.processFn)
 
 }
 
 // ---- Status helpers ----
 
+// hpkeStatus is the full HPKE state for one target (GET /hpke/status, POST /hpke/config).
+func (r *RootAgent) hpkeStatus(target string) map[string]any {
+	st := map[string]any{
+		"target":   target,
+		"enabled":  r.IsHPKEEnabled(target),
+		"kid":      r.CurrentHPKEKID(target),
+		"pin":      r.pinFor(target),
+		"required": hpkeRequired(target),
+		"history":  r.hpkeHist.list(target),
+	}
+	if v, ok := r.hpkeStates.Load(target); ok {
+		st["kemOwnership"] = v.(*hpkeState).kemOwnership
+	}
+	if last, ok := r.hpkeHist.last(target); ok {
+		st["lastChange"] = last
+	} else {
+		st["lastChange"] = nil
+	}
+	return st
+}
+
+// sageStatus is the Root SAGE/HPKE overview (GET /sage/status, POST /toggle-sage).
+func (r *RootAgent) sageStatus() map[string]any {
+	ext := map[string]bool{}
+	hp := map[string]any{}
+	for _, t := range r.knownTargets() {
+		ext[t] = r.externalURLFor(t) != ""
+		hp[t] = map[string]any{
+			"enabled": r.IsHPKEEnabled(t),
+			"kid":     r.CurrentHPKEKID(t),
+		}
+	}
+	return map[string]any{
+		"root": r.sageEnabled,
+		"ext":  ext,
+		// primary vs direct fallback, for agents with <AGENT>_DIRECT_URL
+		"extRoutes": r.extRoutes(),
+		"hpke":      hp,
+		"fieldEnc":  r.fieldEnc.status(),
+		"pins":      r.pins.list(),
+		"tls":       r.certs.list(),
+	}
+}
+
 func httpStatusFromAgent(out *types.AgentMessage) (int, bool) {
 	if out.Metadata != nil {
 		if code, ok := pickIntFromMeta(out.Metadata, "httpStatus", "status"); ok {
@@ -1563,6 +2322,14 @@ func envOr(k, d string) string {
 	}
 	return d
 }
+func envInt(k string, d int) int {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return d
+}
 func envBool(k string, d bool) bool {
 	if v := strings.ToLower(strings.TrimSpace(os.Getenv(k))); v != "" {
 		return v == "1" || v == "true" || v == "on" || v == "yes"
@@ -1641,43 +2408,6 @@ func fillMsgMetaFromPlanning(msg *types.AgentMessage, s planningSlots, lang stri
 	msg.Metadata["lang"] = lang
 }
 
-// ---- [LLM] intent router ----
-
-func (r *RootAgent) llmPlanningAnswer(ctx context.Context, lang string, userText string, s planningSlots) string {
-	r.ensureLLM()
-	if r.llmClient == nil {
-		if lang == "ko" {
-			return "계획 요약을 생성하려면 LLM 설정이 필요해요."
-		}
-		return "LLM is required to generate a plan."
-	}
-
-	sys := map[string]string{
-		"ko": `너는 일정/계획 요약 도우미야.
-- 불릿 없이 4~6줄로 간결히.
-- "목표, 기간, 핵심 단계, 리스크/준비물" 순서로 정리.
-- 명령형 대신 제안형 어조.
-- 날짜/시간 표현은 모호하면 상대가 알아듣게 중립적으로.`,
-		"en": `You are a planning assistant.
-- 4~6 short lines, no bullets.
-- Cover: goal, timeframe, key steps, risks/prep.
-- Use suggestive tone, avoid hard commitments.`,
-	}[langOrDefault(lang)]
-
-	usr := fmt.Sprintf(
-		"Language=%s\nTask=%s\nTimeframe=%s\nContext=%s\nUserText=%s",
-		langOrDefault(lang), s.Task, s.Timeframe, s.Context, strings.TrimSpace(userText),
-	)
-	out, err := r.llmClient.Chat(ctx, sys, usr)
-	if err != nil || strings.TrimSpace(out) == "" {
-		if lang == "ko" {
-			return "요청하신 계획을 정리하지 못했어요. 핵심 목표/기간/제약을 한 번 더 알려주세요."
-		}
-		return "I couldn't generate the plan. Please share goal/timeframe/constraints again."
-	}
-	return strings.TrimSpace(out)
-}
-
 // ---- intent & cues ----
 
 // helper cues
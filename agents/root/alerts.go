@@ -0,0 +1,118 @@
+// Package root - critical security alerts.
+//
+// Alert-worthy events are classified here and handed to the internal/alert
+// dispatcher, which delivers them to the configured sinks (console by
+// default; webhook and SMTP via ALERT_* env, see internal/alert) with per-sink
+// rate limiting and deduplication:
+//
+//   - tamper.suspected (critical): an upstream rejected our signature or
+//     Content-Digest, or an HPKE response did not open;
+//   - hpke.pin_mismatch (critical): a handshake resolved to another identity;
+//   - signature.repeated_failures (critical): ROOT_ALERT_SIGFAIL_THRESHOLD
+//     (default 3) signature rejections for one signer DID and target within
+//     ROOT_ALERT_SIGFAIL_WINDOW_MS (default 300000);
+//   - tls.cert_changed (warning): an upstream's TLS certificate changed
+//     between calls (conn_trace.go);
+//   - breaker.open (critical): the circuit breaker opened for a required
+//     target (ROOT_ALERT_REQUIRED_TARGETS, default every configured target).
+//
+// GET /admin/alerts (admin) returns the last 100 alerts with their per-sink
+// delivery outcome.
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+)
+
+// raiseAlert hands one classified alert to the dispatcher.
+func (r *RootAgent) raiseAlert(kind string, sev alert.Severity, target, cid, title string, detail map[string]any) {
+	r.alerts.Raise(alert.Alert{Kind: kind, Severity: sev, Target: target, CID: cid, Title: title, Detail: detail})
+}
+
+// sigFailures counts signature rejections per signer DID + target inside a
+// sliding window.
+type sigFailures struct {
+	mu     sync.Mutex
+	window time.Duration
+	limit  int
+	m      map[string][]time.Time
+}
+
+func newSigFailures() *sigFailures {
+	return &sigFailures{
+		window: time.Duration(envInt("ROOT_ALERT_SIGFAIL_WINDOW_MS", 300000)) * time.Millisecond,
+		limit:  envInt("ROOT_ALERT_SIGFAIL_THRESHOLD", 3),
+		m:      map[string][]time.Time{},
+	}
+}
+
+// note records one failure and returns the count inside the window when it
+// reaches the threshold (0 otherwise).
+func (s *sigFailures) note(did, target string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := did + "|" + target
+	keep := s.m[k][:0]
+	for _, t := range s.m[k] {
+		if now.Sub(t) < s.window {
+			keep = append(keep, t)
+		}
+	}
+	keep = append(keep, now)
+	s.m[k] = keep
+	if s.limit > 0 && len(keep) >= s.limit {
+		return len(keep)
+	}
+	return 0
+}
+
+// noteSignatureFailure counts an upstream rejection of our signature and
+// alerts once the signer DID keeps failing against target.
+func (r *RootAgent) noteSignatureFailure(did, target, cid string) {
+	n := r.sigFails.note(did, target, time.Now())
+	if n == 0 {
+		return
+	}
+	r.alerts.Raise(alert.Alert{
+		Kind: "signature.repeated_failures", Severity: alert.SeverityCritical, Target: target, CID: cid,
+		Title:     "repeated signature failures from one DID",
+		DedupeKey: "signature.repeated_failures|" + did + "|" + target,
+		Detail:    map[string]any{"did": did, "failures": n, "window": r.sigFails.window.String()},
+	})
+}
+
+// alertRequiredTarget: ROOT_ALERT_REQUIRED_TARGETS, or any target with an
+// external URL.
+func (r *RootAgent) alertRequiredTarget(target string) bool {
+	v := strings.TrimSpace(os.Getenv("ROOT_ALERT_REQUIRED_TARGETS"))
+	if v == "" {
+		return r.externalURLFor(target) != ""
+	}
+	for _, t := range strings.Split(v, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), target) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAlerts: GET /admin/alerts.
+func (r *RootAgent) handleAlerts(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{"alerts": r.alerts.History(), "stats": r.alerts.Stats()})
+}
@@ -0,0 +1,222 @@
+// Package root - two-step payments: authorize on confirm, capture later.
+//
+// With ROOT_PAYMENT_FLOW=auth-capture the payment confirm asks the payment
+// agent for a hold (metadata payment.flow:"auth-capture") instead of a
+// charge. The hold is relayed with await "payment.capture" and kept per
+// conversation; "결제 확정" / "capture" / "finalize" (or "yes" while no other
+// payment is being confirmed) sends the payment agent's capture operation,
+// optionally for less than the hold ("3만원만 확정"). The capture's receipt is
+// recorded like any other, so refunds and the ledger work unchanged. With
+// ROOT_PAYMENT_AUTO_CAPTURE=true Root captures right after the hold; there is
+// no fulfilment signal in this tree to wait for.
+//
+// Holds expire at the payment agent (PAYMENT_AUTH_HOLD_SECONDS). Capturing an
+// expired hold is answered with the agent's rejection and the hold is
+// dropped. The ledger lists a held attempt as authorized and an expired one
+// as voided (left out of the totals like not_charged).
+package root
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const flowAuthCapture = "auth-capture"
+
+// Hold states as the payment agent reports them (GET /auths/{authId}).
+const (
+	authHeld     = "authorized"
+	authCaptured = "captured"
+	authExpired  = "expired"
+	authNotFound = "not_found"
+)
+
+// heldAuth is a hold waiting for capture.
+type heldAuth struct {
+	AuthID    string
+	HeldKRW   int64
+	ExpiresAt time.Time
+}
+
+// authCaptureFlow: ROOT_PAYMENT_FLOW=auth-capture.
+func authCaptureFlow() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("ROOT_PAYMENT_FLOW")), flowAuthCapture)
+}
+
+func isCaptureIntent(c string) bool {
+	return containsAny(strings.ToLower(c), "capture", "finalize", "확정")
+}
+
+// holdAuthorization records the hold in out's authorization metadata and
+// reports whether out was one. An auto-captured hold is answered here
+// (written); otherwise out is marked to await the capture and left for the
+// caller to write.
+func (r *RootAgent) holdAuthorization(w http.ResponseWriter, req *http.Request, cid, lang string, out *types.AgentMessage) (held, written bool) {
+	az, _ := out.Metadata["authorization"].(map[string]any)
+	id := strFrom(az, "authId")
+	if id == "" {
+		return false, false
+	}
+	amt, _ := pickIntFromMeta(az, "heldKRW")
+	exp, _ := time.Parse(time.RFC3339, strFrom(az, "expiresAt"))
+	h := heldAuth{AuthID: id, HeldKRW: int64(amt), ExpiresAt: exp}
+	r.authorizeAttempt(cid, h)
+	r.logger.Printf("[root][payment][auth] cid=%s auth=%s held=%d expires=%s", cid, id, h.HeldKRW, strFrom(az, "expiresAt"))
+
+	if envBool("ROOT_PAYMENT_AUTO_CAPTURE", false) {
+		q := &types.AgentMessage{ID: out.ID, ContextID: cid, From: "root", To: "payment", Type: "request", Timestamp: time.Now()}
+		r.captureAuth(w, req, q, cid, lang, h, 0)
+		return true, true
+	}
+	r.authPendings.Store(cid, h)
+	out.Metadata["await"] = "payment.capture"
+	out.Metadata["authId"] = id
+	return true, false
+}
+
+// handleCapture captures cid's pending hold when the turn asks for it.
+// Returns false when no hold is pending or the message is about something else.
+func (r *RootAgent) handleCapture(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, text, cid, lang string) bool {
+	v, ok := r.authPendings.Load(cid)
+	if !ok {
+		return false
+	}
+	yes, _ := parseYesNo(text)
+	if stage, _ := r.getStageToken(cid); !isCaptureIntent(text) && !(yes && stage == "") {
+		return false
+	}
+	if !r.authPendings.CompareAndDelete(cid, v) {
+		writePaymentInFlight(w, msg, cid, lang)
+		return true
+	}
+	r.captureAuth(w, req, msg, cid, lang, v.(heldAuth), money.ParseKRW(text))
+	return true
+}
+
+// captureErrorText maps the payment agent's capture error code to a user-facing message.
+func captureErrorText(lang, code string, h heldAuth) string {
+	held := money.Format(lang, h.HeldKRW, money.KRW)
+	msgs := map[string]map[string]string{
+		"auth_not_found": {
+			"ko": "승인 " + h.AuthID + "을(를) 찾을 수 없어요. 다시 결제해 주세요.",
+			"en": "Authorization " + h.AuthID + " was not found. Please pay again.",
+		},
+		"auth_expired": {
+			"ko": "승인 " + h.AuthID + "의 보류 기간이 지나 자동 취소되었어요. 청구된 금액은 없어요.",
+			"en": "Authorization " + h.AuthID + " expired and was voided. Nothing was charged.",
+		},
+		"already_captured": {
+			"ko": "승인 " + h.AuthID + "은(는) 이미 확정되었어요.",
+			"en": "Authorization " + h.AuthID + " has already been captured.",
+		},
+		"capture_exceeds_hold": {
+			"ko": "확정 금액이 승인(보류) 금액 " + held + "보다 커요. 그 이하로 확정해 주세요.",
+			"en": "The capture exceeds the held " + held + ". Capture that amount or less.",
+		},
+	}
+	if m, ok := msgs[code]; ok {
+		return m[langOrDefault(lang)]
+	}
+	return map[string]string{
+		"ko": "결제를 확정하지 못했어요. 잠시 후 다시 시도해 주세요.",
+		"en": "The payment could not be captured. Please try again later.",
+	}[langOrDefault(lang)]
+}
+
+// captureAuth sends h to the payment capture operation (keeping
+// payment.op:"capture" for upstreams that only serve /process) for amount
+// (0: the whole hold) and writes the result.
+func (r *RootAgent) captureAuth(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang string, h heldAuth, amount int64) {
+	fwd := *msg
+	fwd.Metadata = map[string]any{
+		"lang":           lang,
+		"payment.op":     "capture",
+		"payment.authId": h.AuthID,
+	}
+	if amount > 0 {
+		fwd.Metadata["payment.captureKRW"] = amount
+	}
+
+	ctx := req.Context()
+	if v := strings.TrimSpace(req.Header.Get("X-SAGE-Enabled")); v != "" {
+		ctx = context.WithValue(ctx, ctxUseSAGEKey, strings.EqualFold(v, "true"))
+	}
+	if v := strings.TrimSpace(req.Header.Get("X-HPKE-Enabled")); v != "" {
+		ctx = context.WithValue(ctx, ctxHPKERawKey, v)
+	}
+
+	r.logger.Printf("[root][payment][capture][send] cid=%s auth=%s amount=%d", cid, h.AuthID, amount)
+	outPtr, err := r.sendWithSLA(ctx, "payment", "capture", &fwd)
+	if err != nil {
+		r.logger.Printf("[root][payment][capture][send][error] %v", err)
+		r.authPendings.Store(cid, h) // still held: the user can ask again
+		r.writeSendError(w, req, lang, "payment", err)
+		return
+	}
+	out := *outPtr
+	status := http.StatusOK
+	if code, ok := httpStatusFromAgent(&out); ok {
+		status = code
+	}
+	em, _ := out.Metadata["error"].(map[string]any)
+	code, _ := em["code"].(string)
+	switch {
+	case strings.EqualFold(out.Type, "error") && (status == http.StatusNotFound || status == http.StatusConflict):
+		// Structured rejection: keep the code, replace the text
+		r.logger.Printf("[root][payment][capture] cid=%s auth=%s rejected code=%q status=%d", cid, h.AuthID, code, status)
+		if out.Metadata == nil {
+			out.Metadata = map[string]any{}
+		}
+		switch code {
+		case "capture_exceeds_hold":
+			// Still held: a smaller capture can follow
+			r.authPendings.Store(cid, h)
+			out.Metadata["await"] = "payment.capture"
+		case "auth_expired", "auth_not_found":
+			r.settleAttempt(cid, h.AuthID, attemptVoided, "")
+		case "already_captured":
+			r.settleAttempt(cid, h.AuthID, attemptCharged, strFrom(em, "orderId"))
+		}
+		out.Content = captureErrorText(lang, code, h)
+		out.Metadata["domain"] = "payment"
+	case status/100 == 2 && !isErrorOut(&out):
+		if id := r.addReceipt(cid, out.Metadata); id != "" {
+			r.settleAttempt(cid, h.AuthID, attemptCharged, id)
+		}
+		r.presentOut(req, lang, "payment", &out, status)
+	default:
+		r.authPendings.Store(cid, h)
+		r.presentOut(req, lang, "payment", &out, status)
+	}
+	writeRootMsg(w, status, out)
+}
+
+// fetchAuth asks the payment agent's auth operation for authID.
+func (r *RootAgent) fetchAuth(ctx context.Context, cid, authID string) (*ledgerAuth, error) {
+	q := &types.AgentMessage{
+		ID: "auth-" + authID, ContextID: cid, From: "root", To: "payment", Type: "request",
+		Timestamp: time.Now(), Metadata: map[string]any{"payment.authId": authID},
+	}
+	out, err := r.sendExternal(ctx, "payment", "auth", q)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorOut(out) {
+		return nil, &ledgerFetchError{out.Content}
+	}
+	az, _ := out.Metadata["authorization"].(map[string]any)
+	a := &ledgerAuth{AuthID: authID, Status: firstNonEmpty(strFrom(az, "status"), authNotFound), OrderID: strFrom(az, "orderId"), ExpiresAt: strFrom(az, "expiresAt"), Source: "auth"}
+	if n, ok := pickIntFromMeta(az, "heldKRW"); ok {
+		a.HeldKRW = int64(n)
+	}
+	if n, ok := pickIntFromMeta(az, "capturedKRW"); ok {
+		a.CapturedKRW = int64(n)
+	}
+	return a, nil
+}
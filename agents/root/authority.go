@@ -0,0 +1,35 @@
+// Package root - signed @authority per target.
+//
+// RFC 9421 signatures that cover @authority only verify if the upstream sees
+// the same Host that Root signed. The model is: Root signs the authority the
+// upstream will verify against, and the gateway forwards the Host it received
+// unchanged (cmd/gateway -preserve-host, on by default).
+//
+// By default that authority is the host of the target's external URL (the
+// gateway, when one is in the path). ROOT_SIGNED_AUTHORITY_PAYMENT /
+// _MEDICAL / _PLANNING override it per target. Use that when the upstream
+// is configured to accept only its own authority, e.g. "payment.internal:19083"
+// behind a gateway at localhost:5500.
+package root
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// signedAuthorityFor returns the configured @authority for target ("" = URL host).
+func signedAuthorityFor(target string) string {
+	return strings.TrimSpace(os.Getenv("ROOT_SIGNED_AUTHORITY_" + strings.ToUpper(strings.TrimSpace(target))))
+}
+
+// effectiveAuthority is the authority Root signs for target, for status output.
+func (r *RootAgent) effectiveAuthority(target string) string {
+	if a := signedAuthorityFor(target); a != "" {
+		return a
+	}
+	if u, err := url.Parse(r.externalURLFor(target)); err == nil {
+		return u.Host
+	}
+	return ""
+}
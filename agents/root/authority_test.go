@@ -0,0 +1,20 @@
+package root
+
+import "testing"
+
+// Root signs the target URL's host unless ROOT_SIGNED_AUTHORITY_<AGENT> pins
+// the upstream's own authority.
+func TestEffectiveAuthority(t *testing.T) {
+	t.Setenv("ROOT_SIGNED_AUTHORITY_PAYMENT", " payment.internal:19083 ")
+	t.Setenv("ROOT_SIGNED_AUTHORITY_MEDICAL", "")
+	r := statusRoot(t)
+	r.SetExternalURL("payment", "http://localhost:5500/payment")
+	r.SetExternalURL("medical", "http://localhost:5500/medical")
+
+	if got := r.effectiveAuthority("payment"); got != "payment.internal:19083" {
+		t.Fatalf("payment authority %q", got)
+	}
+	if got := r.effectiveAuthority("medical"); got != "localhost:5500" {
+		t.Fatalf("medical authority %q", got)
+	}
+}
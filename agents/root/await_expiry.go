@@ -0,0 +1,398 @@
+// Package root - expiry of abandoned clarify states.
+//
+// A payment or medical context pins the conversation to its domain
+// (shouldForcePayment, forceMedical), which is right while the user is
+// answering Root's questions and wrong a day later: "book a flight to Jeju"
+// should not be read as the missing symptom of yesterday's intake. Each
+// context therefore goes stale once it has been idle longer than its stage's
+// window:
+//
+//	ROOT_AWAIT_STALE_CONFIRM_MS  payment await_confirm/await_requote (default 600000)
+//	ROOT_AWAIT_STALE_COLLECT_MS  payment collect / partial slots      (default 1800000)
+//	ROOT_AWAIT_STALE_MEDICAL_MS  medical intake                       (default 1800000)
+//
+// (0 disables expiry for that stage; sending/await_upstream never expire).
+// The first message after the window is routed fresh: the stale context,
+// with its confirm token and clarify depth, is parked and the answer
+// mentions it ("say 'continue' to resume", metadata pendingFlows). Replying
+// "continue"/"계속" (optionally naming the domain) within
+// ROOT_AWAIT_RESUME_GRACE_MS (default 7200000) restores it exactly and
+// re-asks where it stopped; after the grace period it is purged.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const ctxAwaitNoteKey ctxKey = "awaitNote"
+
+// awaitNow is the clock for staleness and grace; tests replace it.
+var awaitNow = time.Now
+
+type awaitWindows struct {
+	confirm, collect, medical, grace time.Duration
+}
+
+func awaitWindowsFromEnv() awaitWindows {
+	ms := func(k string, def int) time.Duration { return time.Duration(envInt(k, def)) * time.Millisecond }
+	return awaitWindows{
+		confirm: ms("ROOT_AWAIT_STALE_CONFIRM_MS", 600000),
+		collect: ms("ROOT_AWAIT_STALE_COLLECT_MS", 1800000),
+		medical: ms("ROOT_AWAIT_STALE_MEDICAL_MS", 1800000),
+		grace:   ms("ROOT_AWAIT_RESUME_GRACE_MS", 7200000),
+	}
+}
+
+// payment is the idle window for a payment stage; 0 = never stale.
+func (aw awaitWindows) payment(stage string) time.Duration {
+	switch stage {
+	case "await_confirm", "await_requote":
+		return aw.confirm
+	case "sending", "await_upstream":
+		return 0
+	}
+	return aw.collect
+}
+
+// parkedFlow is a stale context set aside for resume.
+type parkedFlow struct {
+	Domain   string
+	Stage    string
+	IdleFrom time.Time // last update of the context
+	ParkedAt time.Time
+	PurgeAt  time.Time // end of the resume grace period
+	pay      *payCtx
+	med      *medCtx
+	clarify  any // clarifyDepths entry, if any
+}
+
+// parkedStore holds the flows parkStaleFlows set aside.
+type parkedStore struct {
+	mu      sync.Mutex
+	m       map[string]map[string]*parkedFlow // cid -> domain -> flow
+	parked  int64
+	resumed int64
+	purged  int64
+}
+
+// set stores cid's flows; the caller holds s.mu.
+func (s *parkedStore) set(cid string, byDomain map[string]*parkedFlow) {
+	if s.m == nil {
+		s.m = map[string]map[string]*parkedFlow{}
+	}
+	s.m[cid] = byDomain
+}
+
+// parkStaleFlows moves cid's contexts that have been idle past their window
+// into the parked store and returns them.
+func (r *RootAgent) parkStaleFlows(cid string, aw awaitWindows, now time.Time) []*parkedFlow {
+	var out []*parkedFlow
+
+	r.payContextStore.mu.Lock()
+	if c, ok := r.payContextStore.m[cid]; ok {
+		active := payCtxNotEmpty(c.Slots) || c.Stage == "collect" || c.Stage == "await_confirm" || c.Stage == "await_requote"
+		if win := aw.payment(c.Stage); active && win > 0 && !c.UpdatedAt.IsZero() && now.Sub(c.UpdatedAt) > win {
+			delete(r.payContextStore.m, cid)
+			out = append(out, &parkedFlow{Domain: "payment", Stage: firstNonEmpty(c.Stage, "collect"), IdleFrom: c.UpdatedAt, pay: c})
+		}
+	}
+	r.payContextStore.mu.Unlock()
+
+	if r.hasMedCtx(cid) {
+		st := r.getMedCtx(cid)
+		active := strings.TrimSpace(st.Await) != "" || strings.TrimSpace(st.Slots.Condition) != "" || strings.TrimSpace(st.Symptoms) != ""
+		if active && aw.medical > 0 && !st.UpdatedAt.IsZero() && now.Sub(st.UpdatedAt) > aw.medical {
+			r.delMedCtx(cid)
+			out = append(out, &parkedFlow{Domain: "medical", Stage: firstNonEmpty(st.Await, "collect"), IdleFrom: st.UpdatedAt, med: &st})
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	byDomain := r.parkedFlows.m[cid]
+	if byDomain == nil {
+		byDomain = map[string]*parkedFlow{}
+		r.parkedFlows.set(cid, byDomain)
+	}
+	for _, p := range out {
+		p.ParkedAt, p.PurgeAt = now, now.Add(aw.grace)
+		if v, ok := r.clarifyDepths.LoadAndDelete(clarifyKey(cid, p.Domain)); ok {
+			p.clarify = v
+		}
+		byDomain[p.Domain] = p // a newer stale flow replaces an older parked one
+		r.parkedFlows.parked++
+	}
+	return out
+}
+
+// purgeParkedFlows drops parked flows whose grace period has passed.
+func (r *RootAgent) purgeParkedFlows(now time.Time) (purged []string) {
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	for cid, byDomain := range r.parkedFlows.m {
+		for d, p := range byDomain {
+			if now.After(p.PurgeAt) {
+				delete(byDomain, d)
+				r.parkedFlows.purged++
+				purged = append(purged, cid+"/"+d)
+			}
+		}
+		if len(byDomain) == 0 {
+			delete(r.parkedFlows.m, cid)
+		}
+	}
+	sort.Strings(purged)
+	return purged
+}
+
+// takeParkedFlow removes and returns cid's parked flow for domain ("" = the
+// most recently active one).
+func (r *RootAgent) takeParkedFlow(cid, domain string) *parkedFlow {
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	byDomain := r.parkedFlows.m[cid]
+	var p *parkedFlow
+	if domain != "" {
+		p = byDomain[domain]
+	} else {
+		for _, c := range byDomain {
+			if p == nil || c.IdleFrom.After(p.IdleFrom) {
+				p = c
+			}
+		}
+	}
+	if p == nil {
+		return nil
+	}
+	delete(byDomain, p.Domain)
+	if len(byDomain) == 0 {
+		delete(r.parkedFlows.m, cid)
+	}
+	r.parkedFlows.resumed++
+	return p
+}
+
+// hasParkedFlows reports whether cid has anything to resume.
+func (r *RootAgent) hasParkedFlows(cid string) bool {
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	return len(r.parkedFlows.m[cid]) > 0
+}
+
+// restore puts p's state back as it was when it was parked; the idle clock
+// restarts.
+func (r *RootAgent) restoreParked(cid string, p *parkedFlow) {
+	switch p.Domain {
+	case "payment":
+		c := *p.pay
+		c.UpdatedAt = awaitNow()
+		r.payContextStore.mu.Lock()
+		r.payContextStore.set(cid, &c)
+		r.payContextStore.mu.Unlock()
+	case "medical":
+		r.putMedCtx(cid, *p.med)
+	}
+	if p.clarify != nil {
+		r.clarifyDepths.Store(clarifyKey(cid, p.Domain), p.clarify)
+	}
+}
+
+// parkedView lists cid's parked flows for GET /conversations/{cid}.
+func (r *RootAgent) parkedView(cid string) []map[string]any {
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	var out []map[string]any
+	for _, p := range r.parkedFlows.m[cid] {
+		out = append(out, map[string]any{
+			"domain": p.Domain, "stage": p.Stage, "idleSince": p.IdleFrom.UTC(), "parkedAt": p.ParkedAt.UTC(),
+			"purgeAt": p.PurgeAt.UTC(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["domain"].(string) < out[j]["domain"].(string) })
+	return out
+}
+
+func (r *RootAgent) parkedFlowsStatus(aw awaitWindows) map[string]any {
+	r.parkedFlows.mu.Lock()
+	defer r.parkedFlows.mu.Unlock()
+	n := 0
+	for _, byDomain := range r.parkedFlows.m {
+		n += len(byDomain)
+	}
+	return map[string]any{
+		"windowsMs": map[string]int64{
+			"confirm": aw.confirm.Milliseconds(), "collect": aw.collect.Milliseconds(),
+			"medical": aw.medical.Milliseconds(), "resumeGrace": aw.grace.Milliseconds(),
+		},
+		"parked": n, "parkedTotal": r.parkedFlows.parked, "resumedTotal": r.parkedFlows.resumed, "purgedTotal": r.parkedFlows.purged,
+	}
+}
+
+// resumeRequest parses "continue", "계속", "continue medical", "결제 이어서"...
+// It returns the named domain ("" = any) and whether text is a resume reply.
+func resumeRequest(text string) (string, bool) {
+	t := strings.ToLower(strings.TrimSpace(text))
+	t = strings.TrimRight(t, ".!?~ ")
+	fields := strings.Fields(t)
+	if len(fields) == 0 || len(fields) > 3 {
+		return "", false
+	}
+	resume, domain := false, ""
+	for _, f := range fields {
+		switch f {
+		case "continue", "resume", "계속", "계속해", "계속해줘", "계속하기", "이어서", "이어서해줘", "재개":
+			resume = true
+		case "해줘", "해", "please":
+		case "payment", "결제":
+			if domain != "" && domain != "payment" {
+				return "", false
+			}
+			domain = "payment"
+		case "medical", "의료", "진료", "상담":
+			if domain != "" && domain != "medical" {
+				return "", false
+			}
+			domain = "medical"
+		default:
+			return "", false
+		}
+	}
+	return domain, resume
+}
+
+// awaitNote carries the flows parked in this turn to the response writer.
+type awaitNote struct {
+	mu     sync.Mutex
+	lang   string
+	parked []*parkedFlow
+}
+
+func withAwaitNote(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxAwaitNoteKey, &awaitNote{})
+}
+
+func awaitNoteFrom(ctx context.Context) *awaitNote {
+	n, _ := ctx.Value(ctxAwaitNoteKey).(*awaitNote)
+	return n
+}
+
+func (n *awaitNote) add(lang string, ps []*parkedFlow) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	n.lang = lang
+	n.parked = append(n.parked, ps...)
+	n.mu.Unlock()
+}
+
+// any reports whether flows were parked in this turn.
+func (n *awaitNote) any() bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.parked) > 0
+}
+
+// stamp adds the resume offer to a response body (content and metadata
+// pendingFlows); m is the decoded AgentMessage.
+func (n *awaitNote) stamp(m map[string]any, meta map[string]any) bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.parked) == 0 {
+		return false
+	}
+	var flows []map[string]any
+	var what []string
+	for _, p := range n.parked {
+		flows = append(flows, map[string]any{"domain": p.Domain, "stage": p.Stage, "idleSince": p.IdleFrom.UTC()})
+		what = append(what, tr(n.lang, "root.await.domain."+p.Domain))
+	}
+	meta["pendingFlows"] = flows
+	note := tr(n.lang, "root.await.pending_one", what[0])
+	if len(what) > 1 {
+		note = tr(n.lang, "root.await.pending_many", strings.Join(what, tr(n.lang, "root.await.list_sep")))
+	}
+	if c, ok := m["content"].(string); ok {
+		m["content"] = strings.TrimRight(c, "\n") + "\n\n" + note
+	}
+	return true
+}
+
+// handleStaleAwaits runs before sticky routing: it parks contexts that went
+// stale, purges expired parked ones and answers a resume reply. It reports
+// whether the response was written.
+func (r *RootAgent) handleStaleAwaits(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, text, cid, lang string) bool {
+	aw := r.awaitWindows
+	now := awaitNow()
+	for _, k := range r.purgeParkedFlows(now) {
+		r.logger.Printf("[root][await] parked flow %s purged after %s", k, aw.grace)
+	}
+	if domain, ok := resumeRequest(text); ok && r.hasParkedFlows(cid) {
+		if p := r.takeParkedFlow(cid, domain); p != nil {
+			r.restoreParked(cid, p)
+			r.logger.Printf("[root][await] cid=%s resumed %s stage=%s idle=%s", cid, p.Domain, p.Stage, now.Sub(p.IdleFrom).Round(time.Second))
+			r.audit.Emit(audit.Event{Type: p.Domain, Action: "await.resume", Outcome: "success", CID: cid, Detail: map[string]any{"stage": p.Stage}})
+			r.writeResumed(w, msg, cid, lang, p)
+			return true
+		}
+	}
+	if parked := r.parkStaleFlows(cid, aw, now); len(parked) > 0 {
+		for _, p := range parked {
+			r.logger.Printf("[root][await] cid=%s %s stage=%s idle %s; parked, routing fresh", cid, p.Domain, p.Stage, now.Sub(p.IdleFrom).Round(time.Second))
+			r.audit.Emit(audit.Event{Type: p.Domain, Action: "await.park", Outcome: "success", CID: cid, Detail: map[string]any{"stage": p.Stage}})
+		}
+		awaitNoteFrom(req.Context()).add(lang, parked)
+	}
+	return false
+}
+
+// writeResumed answers a resume reply with where the flow stopped.
+func (r *RootAgent) writeResumed(w http.ResponseWriter, msg *types.AgentMessage, cid, lang string, p *parkedFlow) {
+	meta := map[string]any{"domain": p.Domain, "lang": lang, "resumed": true, "stage": p.Stage}
+	var text string
+	switch p.Domain {
+	case "payment":
+		s := p.pay.Slots
+		switch p.Stage {
+		case "await_confirm", "await_requote":
+			meta["await"] = map[string]string{"await_confirm": "payment.confirm", "await_requote": "payment.requote"}[p.Stage]
+			meta["confirmToken"] = p.pay.Token
+			text = tr(lang, "root.await.resume.payment_confirm", buildPaymentPreview(lang, s))
+		default:
+			missing := computeMissingPayment(s)
+			meta["await"] = "payment.collect"
+			meta["missing"] = strings.Join(missing, ", ")
+			text = tr(lang, "root.await.resume.payment_collect", firstNonEmpty(paySummary(lang, s), "-"), strings.Join(missing, ", "))
+		}
+	case "medical":
+		missing := medicalMissing(*p.med)
+		meta["await"] = "medical." + p.med.Await
+		meta["missing"] = strings.Join(missing, ", ")
+		text = tr(lang, "root.await.resume.medical", firstNonEmpty(medSummary(lang, *p.med), "-"), strings.Join(missing, ", "))
+	}
+	out := types.AgentMessage{
+		ID: msg.ID + "-resume", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
+		Content: text, Timestamp: time.Now(), Metadata: meta,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+}
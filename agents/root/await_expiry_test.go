@@ -0,0 +1,107 @@
+package root
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeAwaitClock drives awaitNow; advance moves it forward.
+func fakeAwaitClock(t *testing.T) (advance func(time.Duration)) {
+	t.Helper()
+	now := time.Now()
+	prev := awaitNow
+	awaitNow = func() time.Time { return now }
+	t.Cleanup(func() { awaitNow = prev })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// previewed leaves cid at payment await_confirm and returns its token.
+func previewed(t *testing.T, env *forkEnv, cid string) any {
+	t.Helper()
+	env.send(t, cid, "맥북 사줘")
+	out := env.send(t, cid, "카드로, 애플스토어, 서울 강남구, 300만원")
+	if out.Metadata["await"] != "payment.confirm" || out.Metadata["confirmToken"] == nil {
+		t.Fatalf("preview: %+v", out.Metadata)
+	}
+	return out.Metadata["confirmToken"]
+}
+
+// Inside its window a pending confirm still owns the conversation.
+func TestAwaitWithinWindow(t *testing.T) {
+	advance := fakeAwaitClock(t)
+	env := newForkEnv(t, 0)
+	previewed(t, env, "c1")
+	advance(9 * time.Minute)
+	env.send(t, "c1", "네")
+	env.charge(t)
+}
+
+// A stale confirm no longer captures the next message: it is parked, the turn
+// is routed fresh with a resume offer, and "계속" restores the same preview
+// and token, which then confirms.
+func TestAwaitStaleResume(t *testing.T) {
+	advance := fakeAwaitClock(t)
+	env := newForkEnv(t, 0)
+	token := previewed(t, env, "c1")
+	advance(11 * time.Minute)
+
+	out := env.send(t, "c1", "안녕하세요")
+	flows, _ := out.Metadata["pendingFlows"].([]any)
+	if out.Metadata["domain"] == "payment" || len(flows) != 1 || flows[0].(map[string]any)["stage"] != "await_confirm" {
+		t.Fatalf("fresh turn: %+v", out.Metadata)
+	}
+	if len(env.r.parkedView("c1")) != 1 {
+		t.Fatal("flow not parked")
+	}
+
+	out = env.send(t, "c1", "계속")
+	if out.Metadata["resumed"] != true || out.Metadata["await"] != "payment.confirm" || out.Metadata["confirmToken"] != token {
+		t.Fatalf("resume: %+v", out.Metadata)
+	}
+	env.send(t, "c1", "네")
+	env.charge(t)
+	if st := env.r.parkedFlowsStatus(env.r.awaitWindows); st["parkedTotal"] != int64(1) || st["resumedTotal"] != int64(1) || st["parked"] != 0 {
+		t.Fatalf("status %v", st)
+	}
+}
+
+// Past the grace period the parked flow is purged and "계속" is an ordinary
+// message.
+func TestAwaitPurgedAfterGrace(t *testing.T) {
+	t.Setenv("ROOT_AWAIT_RESUME_GRACE_MS", "60000")
+	advance := fakeAwaitClock(t)
+	env := newForkEnv(t, 0)
+	previewed(t, env, "c1")
+	advance(11 * time.Minute)
+	env.send(t, "c1", "안녕하세요")
+	advance(2 * time.Minute)
+
+	out := env.send(t, "c1", "계속")
+	if out.Metadata["resumed"] == true || out.Metadata["confirmToken"] != nil {
+		t.Fatalf("resumed after grace: %+v", out.Metadata)
+	}
+	if st := env.r.parkedFlowsStatus(env.r.awaitWindows); st["purgedTotal"] != int64(1) || st["parked"] != 0 {
+		t.Fatalf("status %v", st)
+	}
+}
+
+func TestResumeRequest(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		domain string
+		ok     bool
+	}{
+		{"계속", "", true},
+		{"Continue!", "", true},
+		{"결제 이어서 해줘", "payment", true},
+		{"continue medical", "medical", true},
+		{"continue payment medical", "", false},
+		{"계속 비가 와요", "", false},
+		{"결제", "payment", false},
+		{"", "", false},
+	} {
+		if d, ok := resumeRequest(tc.in); d != tc.domain || ok != tc.ok {
+			t.Errorf("resumeRequest(%q) = %q, %v", tc.in, d, ok)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package root - boot report.
+//
+// BootReport describes Root as configured, for the startup banner and the
+// boot report cmd/root emits (internal/bootreport): port, DID and signing
+// key, whether outbound signing and HPKE are on, the upstream and direct
+// fallback URLs, and the LLM, routing and time zone settings. Root's init
+// warnings (no signing key while signing is on, an invalid DEFAULT_TIMEZONE,
+// broken field encryption keys, missing translations) are collected into it.
+//
+// With BOOT_REPORT_BASELINE naming a known-good report (one component's, or
+// the system summary scripts/06_start_all.sh writes), the troubleshoot battery
+// gains a "boot baseline" check that lists what changed since.
+package root
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+	"github.com/sage-x-project/sage-multi-agent/internal/troubleshoot"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+)
+
+// bootDiffShown bounds the changes quoted in the baseline check's evidence.
+const bootDiffShown = 8
+
+// BootReport builds Root's boot report. The caller emits it.
+func (r *RootAgent) BootReport() *bootreport.Report {
+	rep := bootreport.New("root").Port("http", r.port)
+
+	_, did, err := loadSigningKey()
+	switch {
+	case err == nil:
+		rep.DID("self", did)
+	case r.sageEnabled:
+		rep.Warn("signing key: %v (signed sends will fail)", err)
+	}
+	rep.Key("signing", os.Getenv("ROOT_JWK_FILE"))
+
+	rep.Mode("signing", onOff(r.sageEnabled))
+	if targets := r.requiredHPKETargets(); len(targets) > 0 {
+		rep.Mode("hpke", "on ("+strings.Join(targets, ",")+")")
+	} else {
+		rep.Mode("hpke", "off")
+	}
+	rep.Mode("admin", onOff(adminToken() != ""))
+	rep.Mode("strict_payload", onOff(payloadcheck.Strict()))
+
+	for _, t := range r.knownTargets() {
+		rep.Upstream(t, r.externalURLFor(t))
+		if d, ok := r.directRoute(t); ok {
+			rep.Upstream(d.Key, d.Base)
+		}
+	}
+
+	rep.Setting("hpke.keys", hpkeKeysPath())
+	rep.Setting("timezone", tz.Default().String())
+	rep.Setting("intent_mode", firstNonEmpty(strings.ToLower(strings.TrimSpace(os.Getenv("ROOT_INTENT_MODE"))), "hybrid"))
+	rep.Setting("llm.enabled", fmt.Sprint(envBool("LLM_ENABLED", true)))
+	rep.Setting("llm.url", os.Getenv("LLM_BASE_URL"))
+	rep.Setting("llm.model", os.Getenv("LLM_MODEL"))
+	rep.Setting("llm.model_pin", os.Getenv("LLM_MODEL_PIN"))
+	rep.Setting("llm.model_pin_strict", os.Getenv("LLM_MODEL_PIN_STRICT"))
+	rep.Setting("llm.lang", os.Getenv("LLM_LANG_DEFAULT"))
+	rep.Setting("llm.timeout_ms", os.Getenv("LLM_TIMEOUT_MS"))
+	rep.Setting("llm.api_key", os.Getenv("LLM_API_KEY"))
+	return rep
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// checkBootBaseline compares Root's boot report with the known-good one at path.
+func (r *RootAgent) checkBootBaseline(path string) troubleshoot.Result {
+	good, err := bootreport.ReadFile(path)
+	if err != nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: err.Error(),
+			Hint:     "BOOT_REPORT_BASELINE must name a boot report saved from a known-good launch (BOOT_REPORT_FILE, or logs/boot/system.json from scripts/06_start_all.sh)",
+		}
+	}
+	var want []bootreport.Report
+	for _, rep := range good {
+		if rep.Component == "root" {
+			want = append(want, rep)
+		}
+	}
+	if len(want) == 0 {
+		return troubleshoot.Result{Status: troubleshoot.Skip, Evidence: "no root report in " + path}
+	}
+	cur := bootreport.Last()
+	if cur == nil {
+		cur = r.BootReport().Finish()
+	}
+	changes := bootreport.Diff(want, []bootreport.Report{*cur})
+	if len(changes) == 0 {
+		return troubleshoot.Result{Status: troubleshoot.OK, Evidence: "matches " + path}
+	}
+	shown := make([]string, 0, bootDiffShown)
+	for _, c := range changes[:min(len(changes), bootDiffShown)] {
+		shown = append(shown, c.String())
+	}
+	ev := fmt.Sprintf("%d change(s) since %s: %s", len(changes), path, strings.Join(shown, "; "))
+	if n := len(changes) - len(shown); n > 0 {
+		ev += fmt.Sprintf(" (+%d more)", n)
+	}
+	return troubleshoot.Result{
+		Status:   troubleshoot.Warn,
+		Evidence: ev,
+		Hint:     "if the change is intended, save the current report as the new baseline; otherwise restore the setting (full list: go run ./cmd/bootreport diff " + path + " <current report>)",
+	}
+}
@@ -0,0 +1,169 @@
+// Package root - clarify-loop depth limit.
+//
+// Every clarify turn records a fingerprint of the domain's required slots.
+// When ROOT_CLARIFY_MAX_DEPTH (default 4, 0 disables) consecutive clarify
+// turns pass without the fingerprint changing, Root stops asking: it
+// summarizes what it has and what is still missing, offers the structured
+// form (metadata "form": the metadata key that fills each missing slot) as
+// the only way forward and marks the response clarifyLimitReached:true.
+// Replying "cancel"/"취소" at that point clears the domain context. Any slot
+// progress resets the counter.
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func clarifyMaxDepth() int { return envInt("ROOT_CLARIFY_MAX_DEPTH", 4) }
+
+type clarifyDepth struct {
+	sig     string
+	stalls  int // clarify turns since the required slots last changed
+	limited bool
+}
+
+func clarifyKey(cid, domain string) string { return cid + "|" + domain }
+
+// noteClarify records a clarify turn whose required slots fingerprint to sig.
+func (r *RootAgent) noteClarify(cid, domain, sig string) clarifyDepth {
+	d := clarifyDepth{sig: sig}
+	if v, ok := r.clarifyDepths.Load(clarifyKey(cid, domain)); ok && v.(clarifyDepth).sig == sig {
+		d.stalls = v.(clarifyDepth).stalls + 1
+	}
+	max := clarifyMaxDepth()
+	d.limited = max > 0 && d.stalls >= max
+	r.clarifyDepths.Store(clarifyKey(cid, domain), d)
+	return d
+}
+
+func (r *RootAgent) resetClarify(cid, domain string) { r.clarifyDepths.Delete(clarifyKey(cid, domain)) }
+
+func (r *RootAgent) clarifyLimited(cid, domain string) bool {
+	v, ok := r.clarifyDepths.Load(clarifyKey(cid, domain))
+	return ok && v.(clarifyDepth).limited
+}
+
+// paySlotsSig fingerprints the slots computeMissingPayment looks at.
+func paySlotsSig(s paySlots) string {
+	return strings.Join([]string{s.Method, firstNonEmpty(s.Recipient, s.To), s.Shipping,
+		fmt.Sprint(s.AmountKRW), fmt.Sprint(s.BudgetKRW)}, "\x1f")
+}
+
+func medSlotsSig(st medCtx) string { return st.Slots.Condition + "\x1f" + st.Symptoms }
+
+// clarifyFormKeys maps a missing slot to the structured metadata key that fills it.
+var clarifyFormKeys = map[string]map[string]string{
+	"payment": {"method": "payment.method", "recipient": "payment.to", "shipping": "payment.shipping", "budgetKRW": "payment.budgetKRW"},
+	"medical": {"condition": "medical.condition", "symptoms": "medical.symptoms"},
+}
+
+// clarifyForm describes the structured way to supply the missing slots.
+func clarifyForm(domain string, missing []string) []map[string]string {
+	var out []map[string]string
+	for _, m := range missing {
+		name, _, _ := strings.Cut(m, "(") // medicalMissing: "condition(질환)"
+		if key, ok := clarifyFormKeys[domain][name]; ok {
+			out = append(out, map[string]string{"field": name, "metadataKey": key})
+		}
+	}
+	return out
+}
+
+func isAbandonReply(s string) bool {
+	t := strings.ToLower(strings.TrimSpace(s))
+	for _, w := range []string{"cancel", "stop", "quit", "never mind", "nevermind", "취소", "그만", "안 할래", "안할래"} {
+		if strings.Contains(t, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func paySummary(lang string, s paySlots) string {
+	var have []string
+	add := func(ko, en, v string) {
+		if strings.TrimSpace(v) != "" {
+			have = append(have, map[string]string{"ko": ko, "en": en}[langOrDefault(lang)]+"="+v)
+		}
+	}
+	add("상품", "item", firstNonEmpty(s.Item, s.Model))
+	add("수신자", "recipient", firstNonEmpty(s.Recipient, s.To))
+	add("결제수단", "method", s.Method)
+	add("배송지", "shipping", s.Shipping)
+	if s.AmountKRW > 0 {
+		add("금액", "amount", money.Display(lang, s.AmountKRW, money.KRW))
+	} else if s.BudgetKRW > 0 {
+		add("예산", "budget", money.Display(lang, s.BudgetKRW, money.KRW))
+	}
+	return strings.Join(have, ", ")
+}
+
+func medSummary(lang string, st medCtx) string {
+	var have []string
+	if v := strings.TrimSpace(st.Slots.Condition); v != "" {
+		have = append(have, map[string]string{"ko": "질환", "en": "condition"}[langOrDefault(lang)]+"="+v)
+	}
+	if v := strings.TrimSpace(st.Symptoms); v != "" {
+		have = append(have, map[string]string{"ko": "증상", "en": "symptoms"}[langOrDefault(lang)]+"="+redact(v, 60))
+	}
+	return strings.Join(have, ", ")
+}
+
+// writeClarifyLimit answers in place of another clarify question once the limit is reached.
+func (r *RootAgent) writeClarifyLimit(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, domain, lang, summary string, missing []string, d clarifyDepth) {
+	r.logger.Printf("[root][%s][clarify-limit] cid=%s stalls=%d missing=%v", domain, cid, d.stalls, missing)
+	r.audit.Emit(audit.Event{
+		Type: domain, Action: "clarify.limit", Outcome: "denied", Actor: requesterOf(req), CID: cid,
+		Detail: map[string]any{"stalls": d.stalls, "max": clarifyMaxDepth(), "missing": missing},
+	})
+	if summary == "" {
+		summary = tr(lang, "root.clarify.nothing_yet")
+	}
+	text := tr(lang, "root.clarify.limit", summary, strings.Join(missing, ", "))
+	out := types.AgentMessage{
+		ID: msg.ID + "-clarify-limit", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
+		Content: text, Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"await": domain + ".form", "domain": domain, "lang": lang,
+			"missing": strings.Join(missing, ", "), "form": clarifyForm(domain, missing),
+			"clarifyLimitReached": true, "clarifyDepth": d.stalls,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// handleClarifyAbandon clears the domain context when the user gives up after
+// the limit was reached. It reports whether the response was written.
+func (r *RootAgent) handleClarifyAbandon(w http.ResponseWriter, msg *types.AgentMessage, cid, domain, lang string) bool {
+	if !r.clarifyLimited(cid, domain) || !isAbandonReply(msg.Content) {
+		return false
+	}
+	switch domain {
+	case "payment":
+		r.delPayCtx(cid)
+	case "medical":
+		r.delMedCtx(cid)
+	}
+	r.resetClarify(cid, domain)
+	r.logger.Printf("[root][%s][clarify-limit] cid=%s abandoned; context cleared", domain, cid)
+	out := types.AgentMessage{
+		ID: msg.ID + "-abandon", ContextID: cid, From: "root", To: msg.From, Type: "response",
+		Content:   tr(lang, "root.clarify.abandoned"),
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"domain": domain, "lang": lang, "abandoned": true},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+	return true
+}
@@ -0,0 +1,67 @@
+package root
+
+import (
+	"testing"
+)
+
+// Replies that fill no required slot count toward ROOT_CLARIFY_MAX_DEPTH; the
+// turn that reaches it offers the form instead of another question.
+func TestClarifyLimitNoProgress(t *testing.T) {
+	t.Setenv("ROOT_CLARIFY_MAX_DEPTH", "2")
+	env := newForkEnv(t, 0)
+	const cid = "ctx-clarify-stall"
+	if out := env.send(t, cid, "맥북 사줘"); out.Type != "clarify" || out.Metadata["clarifyLimitReached"] != nil {
+		t.Fatalf("first question: %+v", out)
+	}
+	if out := env.send(t, cid, "글쎄요"); out.Metadata["clarifyLimitReached"] != nil {
+		t.Fatalf("limited after one stall: %+v", out)
+	}
+	out := env.send(t, cid, "잘 모르겠어요")
+	if out.Metadata["clarifyLimitReached"] != true || out.Metadata["await"] != "payment.form" || out.Metadata["clarifyDepth"] != float64(2) {
+		t.Fatalf("limit: %+v", out)
+	}
+	form, _ := out.Metadata["form"].([]any)
+	if len(form) == 0 {
+		t.Fatalf("no form offered: %+v", out.Metadata)
+	}
+
+	// giving up at the limit clears the payment context
+	if out := env.send(t, cid, "취소"); out.Metadata["abandoned"] != true {
+		t.Fatalf("abandon: %+v", out)
+	}
+	if s := env.r.getPayCtx(cid); payCtxNotEmpty(s) || env.r.clarifyLimited(cid, "payment") {
+		t.Fatalf("context after abandon: %+v", s)
+	}
+}
+
+// A reply that changes any required slot resets the counter.
+func TestClarifyLimitProgressResets(t *testing.T) {
+	t.Setenv("ROOT_CLARIFY_MAX_DEPTH", "2")
+	env := newForkEnv(t, 0)
+	env.r.llmClient = scriptedExtractor{
+		"맥북":  `{"fields":{"mode":"purchase","item":"맥북"}}`,
+		"카드로": `{"fields":{"method":"card"}}`,
+	}
+	const cid = "ctx-clarify-progress"
+	env.send(t, cid, "맥북 사줘")
+	env.send(t, cid, "글쎄요")
+	if out := env.send(t, cid, "카드로 할게요"); out.Metadata["clarifyLimitReached"] != nil {
+		t.Fatalf("progress hit the limit: %+v", out)
+	}
+	if out := env.send(t, cid, "글쎄요"); out.Type != "clarify" || out.Metadata["clarifyLimitReached"] != nil {
+		t.Fatalf("counter not reset: %+v", out)
+	}
+	if out := env.send(t, cid, "모르겠어요"); out.Metadata["clarifyLimitReached"] != true {
+		t.Fatalf("limit after reset: %+v", out)
+	}
+}
+
+func TestClarifyLimitDisabled(t *testing.T) {
+	t.Setenv("ROOT_CLARIFY_MAX_DEPTH", "0")
+	r := statusRoot(t)
+	for i := 0; i < 10; i++ {
+		if d := r.noteClarify("c", "medical", "same"); d.limited {
+			t.Fatalf("limited at turn %d with the limit disabled", i)
+		}
+	}
+}
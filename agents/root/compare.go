@@ -0,0 +1,264 @@
+// Package root - compare mode: one request, with and without SAGE.
+//
+// POST /compare (admin token) sends the same message to one upstream twice in
+// parallel — signed (optionally HPKE) and plaintext — each in a throwaway
+// conversation context, and reports the differences. Payment always runs as a
+// dry run through its simulate operation, so compare never charges anything.
+//
+// With a budget for the target (ROOT_SLA_MS_<TARGET>, sla.go) the answer does
+// not wait past it: a leg still running is returned pending with a task ID
+// and the diff is left out ("partial": true).
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+type compareLeg struct {
+	Leg           string `json:"leg"` // "sage" | "plain"
+	SAGE          bool   `json:"sage"`
+	HPKE          bool   `json:"hpke"`
+	ContextID     string `json:"contextId"`
+	Status        int    `json:"status"`
+	SigVerified   string `json:"signature"` // "verified" | "rejected" | "not_signed"
+	Tampered      bool   `json:"tampered"`
+	TamperSuspect bool   `json:"tamperSuspect,omitempty"` // upstream reported signature/digest failure
+	Injected      string `json:"injected,omitempty"`      // text added in transit (when the upstream echoes what it received)
+	Content       string `json:"content"`
+	ElapsedMs     int64  `json:"elapsedMs"`
+	Error         string `json:"error,omitempty"`
+	Pending       bool   `json:"pending,omitempty"`
+	TaskID        string `json:"taskId,omitempty"` // GET /tasks/{id} serves the leg once it lands
+}
+
+// status is the leg's SLA status: done or failed.
+func (l compareLeg) status() string {
+	if l.Error != "" || l.Status/100 != 2 {
+		return "failed"
+	}
+	return "done"
+}
+
+// legSlot hands a leg's result either to the waiting request or, once the
+// request answered without it, to its task.
+type legSlot struct {
+	mu     sync.Mutex
+	leg    compareLeg
+	done   bool
+	taskID string
+}
+
+func (s *legSlot) finish(tasks *slaTaskStore, l compareLeg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leg, s.done = l, true
+	if s.taskID != "" {
+		l.TaskID = s.taskID
+		tasks.finish(s.taskID, l.status(), l)
+	}
+}
+
+// runCompareLeg sends a private copy of msg with the leg's SAGE/HPKE toggles.
+func (r *RootAgent) runCompareLeg(ctx context.Context, target string, msg types.AgentMessage, sage, hpkeOn bool) compareLeg {
+	leg := compareLeg{Leg: "plain", SAGE: sage, HPKE: sage && hpkeOn}
+	if sage {
+		leg.Leg = "sage"
+	}
+
+	// deep copy so legs never share metadata maps
+	var m types.AgentMessage
+	b, _ := json.Marshal(msg)
+	_ = json.Unmarshal(b, &m)
+	if m.Metadata == nil {
+		m.Metadata = map[string]any{}
+	}
+	leg.ContextID = "compare-" + uuid.NewString()
+	m.ContextID = leg.ContextID
+	m.Metadata["compare"] = leg.Leg
+	if target == "payment" {
+		m.Metadata["payment.dryRun"] = true
+	}
+
+	ctx = context.WithValue(ctx, ctxUseSAGEKey, sage)
+	ctx = context.WithValue(ctx, ctxHPKERawKey, map[bool]string{true: "true", false: "false"}[leg.HPKE])
+
+	start := time.Now()
+	op := opProcess
+	if target == "payment" {
+		op = "simulate"
+	}
+	out, err := r.sendExternal(ctx, target, op, &m)
+	leg.ElapsedMs = time.Since(start).Milliseconds()
+	if err != nil {
+		leg.Status = http.StatusBadGateway
+		leg.Error = err.Error()
+		leg.SigVerified = map[bool]string{true: "rejected", false: "not_signed"}[sage]
+		return leg
+	}
+
+	leg.Status = http.StatusOK
+	if code, ok := httpStatusFromAgent(out); ok {
+		leg.Status = code
+	}
+	leg.Content = out.Content
+	sigFail, _ := out.Metadata["sigAuthFailed"].(bool)
+	leg.TamperSuspect, _ = out.Metadata["tamperSuspect"].(bool)
+	switch {
+	case !sage:
+		leg.SigVerified = "not_signed"
+	case sigFail || leg.Status/100 != 2:
+		leg.SigVerified = "rejected"
+	default:
+		leg.SigVerified = "verified"
+	}
+	if got, ok := out.Metadata["received"].(string); ok && got != msg.Content {
+		leg.Tampered = true
+		leg.Injected = strings.TrimSpace(strings.TrimPrefix(got, msg.Content))
+	}
+	if leg.TamperSuspect {
+		leg.Tampered = true
+	}
+	return leg
+}
+
+func (r *RootAgent) handleCompare(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	var in struct {
+		Message types.AgentMessage `json:"message"`
+		Target  string             `json:"target"`
+		HPKE    bool               `json:"hpke,omitempty"`
+	}
+	if probs := decodeAdminBody(req, map[string]fieldSpec{
+		"message": {Kind: "object", Required: true},
+		"target":  {Kind: "string", Required: true, Enum: r.knownTargets()},
+		"hpke":    {Kind: "bool"},
+	}, &in); len(probs) > 0 {
+		writeInvalidRequest(w, probs)
+		return
+	}
+	target := strings.ToLower(strings.TrimSpace(in.Target))
+	if r.externalURLFor(target) == "" {
+		http.Error(w, "no external URL configured for "+target, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Both legs in parallel on the background pool; a leg it has no room for
+	// runs inline. Under a budget the legs outlive the request (up to the task
+	// TTL) so a pending one can still finish.
+	start := time.Now()
+	budget := slaFor(target)
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if budget > 0 {
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), slaTaskTTL())
+	}
+	var slots [2]legSlot
+	var wg sync.WaitGroup
+	for i, sage := range []bool{true, false} {
+		wg.Add(1)
+		leg := func(context.Context) {
+			defer wg.Done()
+			slots[i].finish(&r.slaTasks, r.runCompareLeg(ctx, target, in.Message, sage, in.HPKE))
+		}
+		if err := r.Background(leg); err != nil {
+			leg(ctx)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		cancel()
+		close(done)
+	}()
+	if budget > 0 {
+		t := time.NewTimer(budget - time.Since(start))
+		select {
+		case <-done:
+		case <-t.C:
+		}
+		t.Stop()
+	} else {
+		<-done
+	}
+
+	var legs [2]compareLeg
+	domains := make([]map[string]any, 0, 2)
+	partial := false
+	for i, sage := range []bool{true, false} {
+		s := &slots[i]
+		s.mu.Lock()
+		if s.done {
+			legs[i] = s.leg
+		} else {
+			name := map[bool]string{true: "sage", false: "plain"}[sage]
+			s.taskID = r.slaTasks.open(target, name, start)
+			legs[i] = compareLeg{Leg: name, SAGE: sage, HPKE: sage && in.HPKE, Pending: true, TaskID: s.taskID,
+				ElapsedMs: time.Since(start).Milliseconds()}
+			partial = true
+		}
+		s.mu.Unlock()
+		d := map[string]any{"domain": target, "leg": legs[i].Leg, "status": "pending", "elapsedMs": legs[i].ElapsedMs}
+		if legs[i].Pending {
+			d["taskId"] = legs[i].TaskID
+		} else {
+			d["status"] = legs[i].status()
+		}
+		domains = append(domains, d)
+	}
+
+	signed, plain := legs[0], legs[1]
+	r.logger.Printf("[root][compare] target=%s sage{status=%d sig=%s tampered=%v pending=%v} plain{status=%d tampered=%v pending=%v}",
+		target, signed.Status, signed.SigVerified, signed.Tampered, signed.Pending, plain.Status, plain.Tampered, plain.Pending)
+
+	out := map[string]any{
+		"target":  target,
+		"dryRun":  target == "payment",
+		"legs":    []compareLeg{signed, plain},
+		"domains": domains,
+		"partial": partial,
+	}
+	if !partial {
+		out["diff"] = map[string]any{
+			"statusEqual":       signed.Status == plain.Status,
+			"contentEqual":      signed.Content == plain.Content,
+			"contentLen":        map[string]int{"sage": len(signed.Content), "plain": len(plain.Content)},
+			"commonPrefixBytes": commonPrefixLen(signed.Content, plain.Content),
+			"tamperedLegs":      tamperedLegs(signed, plain),
+			"elapsedMs":         map[string]int64{"sage": signed.ElapsedMs, "plain": plain.ElapsedMs},
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func tamperedLegs(legs ...compareLeg) []string {
+	out := []string{}
+	for _, l := range legs {
+		if l.Tampered {
+			out = append(out, l.Leg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+// Package root - connection-level metadata for upstream calls.
+//
+// Every outbound call runs under an httptrace.ClientTrace that records the
+// connection it used: TLS version, cipher suite, ALPN, the peer's leaf
+// certificate (subject + SHA-256 fingerprint) and whether the connection was
+// reused. Plain HTTP targets record transport "cleartext". The summary goes
+// into the request's security timeline (metadata security.timeline, one entry
+// per hop) and into the audit events of that call.
+//
+// Per target, the first fingerprint seen is kept as the pin and the latest as
+// observed (/sage/status → tls). When the fingerprint changes between calls,
+// Root logs a warning, emits audit tls.cert_changed and raises the alert of the
+// same name: a different certificate for the same target may be a MITM.
+// Legitimate rotations show up the same way; the pin is informational only
+// and no call is refused.
+package root
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+const (
+	transportTLS       = "tls"
+	transportCleartext = "cleartext"
+)
+
+// connSummary is the compact per-call record.
+type connSummary struct {
+	Transport       string `json:"transport"`
+	TLSVersion      string `json:"tlsVersion,omitempty"`
+	CipherSuite     string `json:"cipherSuite,omitempty"`
+	ALPN            string `json:"alpn,omitempty"`
+	ServerName      string `json:"serverName,omitempty"`
+	PeerSubject     string `json:"peerSubject,omitempty"`
+	PeerFingerprint string `json:"peerFingerprint,omitempty"`
+	Reused          bool   `json:"reused"`
+}
+
+// auditDetail is the summary as an audit detail value.
+func (c *connSummary) auditDetail() map[string]any {
+	if c == nil {
+		return nil
+	}
+	m := map[string]any{"transport": c.Transport, "reused": c.Reused}
+	if c.Transport == transportTLS {
+		m["tlsVersion"] = c.TLSVersion
+		m["cipherSuite"] = c.CipherSuite
+		m["alpn"] = c.ALPN
+		m["peerSubject"] = c.PeerSubject
+		m["peerFingerprint"] = c.PeerFingerprint
+	}
+	return m
+}
+
+// connCapture receives the trace callbacks of one call (retries overwrite).
+type connCapture struct {
+	mu     sync.Mutex
+	got    bool
+	reused bool
+	state  *tls.ConnectionState
+}
+
+// withConnCapture attaches a ClientTrace recording the connection to ctx.
+func withConnCapture(ctx context.Context) (context.Context, *connCapture) {
+	cc := &connCapture{}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			cc.mu.Lock()
+			defer cc.mu.Unlock()
+			cc.got, cc.reused, cc.state = true, info.Reused, nil
+			if tc, ok := info.Conn.(*tls.Conn); ok {
+				st := tc.ConnectionState()
+				cc.state = &st
+			}
+		},
+		TLSHandshakeDone: func(st tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			cc.mu.Lock()
+			cc.state = &st
+			cc.mu.Unlock()
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), cc
+}
+
+// summary returns nil when no connection was obtained.
+func (cc *connCapture) summary() *connSummary {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if !cc.got {
+		return nil
+	}
+	c := &connSummary{Transport: transportCleartext, Reused: cc.reused}
+	if st := cc.state; st != nil && st.HandshakeComplete {
+		c.Transport = transportTLS
+		c.TLSVersion = tls.VersionName(st.Version)
+		c.CipherSuite = tls.CipherSuiteName(st.CipherSuite)
+		c.ALPN = st.NegotiatedProtocol
+		c.ServerName = st.ServerName
+		if len(st.PeerCertificates) > 0 {
+			leaf := st.PeerCertificates[0]
+			sum := sha256.Sum256(leaf.Raw)
+			c.PeerSubject = leaf.Subject.String()
+			c.PeerFingerprint = "sha256:" + hex.EncodeToString(sum[:])
+		}
+	}
+	return c
+}
+
+// certObservation is the per-target TLS view in /sage/status.
+type certObservation struct {
+	Target          string    `json:"target"`
+	Transport       string    `json:"transport"`
+	Pinned          string    `json:"pinnedFingerprint,omitempty"`
+	PinnedSubject   string    `json:"pinnedSubject,omitempty"`
+	Observed        string    `json:"observedFingerprint,omitempty"`
+	ObservedSubject string    `json:"observedSubject,omitempty"`
+	TLSVersion      string    `json:"tlsVersion,omitempty"`
+	Changes         int       `json:"fingerprintChanges"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+}
+
+type certWatch struct {
+	mu sync.Mutex
+	m  map[string]*certObservation
+}
+
+func newCertWatch() *certWatch {
+	return &certWatch{m: map[string]*certObservation{}}
+}
+
+// observe records c for target and returns the previous fingerprint when it
+// differs from c's.
+func (cw *certWatch) observe(target string, c *connSummary, now time.Time) (prev string, changed bool) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	o, ok := cw.m[target]
+	if !ok {
+		o = &certObservation{Target: target, FirstSeen: now}
+		cw.m[target] = o
+	}
+	o.Transport, o.LastSeen = c.Transport, now
+	if c.PeerFingerprint == "" {
+		return "", false
+	}
+	if o.Pinned == "" {
+		o.Pinned, o.PinnedSubject = c.PeerFingerprint, c.PeerSubject
+	}
+	prev = o.Observed
+	o.Observed, o.ObservedSubject, o.TLSVersion = c.PeerFingerprint, c.PeerSubject, c.TLSVersion
+	if prev != "" && prev != c.PeerFingerprint {
+		o.Changes++
+		return prev, true
+	}
+	return "", false
+}
+
+func (cw *certWatch) list() []certObservation {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	out := make([]certObservation, 0, len(cw.m))
+	for _, o := range cw.m {
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+	return out
+}
+
+// observeConn summarizes the call's connection and warns on a certificate
+// change. It returns nil when the call never got a connection.
+func (r *RootAgent) observeConn(agent, base, cid string, cc *connCapture) *connSummary {
+	c := cc.summary()
+	if c == nil {
+		return nil
+	}
+	prev, changed := r.certs.observe(agent, c, time.Now().UTC())
+	if changed {
+		r.logger.Printf("[root][alert][tls] ⚠️ peer certificate changed target=%s base=%s prev=%s now=%s subject=%q",
+			agent, base, prev, c.PeerFingerprint, c.PeerSubject)
+		r.audit.Emit(audit.Event{
+			Type: "security", Action: "tls.cert_changed", Outcome: "failure", Target: agent, CID: cid,
+			Detail: map[string]any{"upstream": base, "previous": prev, "conn": c.auditDetail()},
+		})
+		r.raiseAlert("tls.cert_changed", alert.SeverityWarning, agent, cid, "upstream TLS certificate changed between calls", map[string]any{
+			"upstream": base, "previous": prev, "current": c.PeerFingerprint, "subject": c.PeerSubject,
+		})
+	}
+	return c
+}
@@ -0,0 +1,108 @@
+package root
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+)
+
+// call makes one GET to srv through its client under a connection capture.
+func call(t *testing.T, srv *httptest.Server, c *http.Client) *connCapture {
+	t.Helper()
+	ctx, cc := withConnCapture(t.Context())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return cc
+}
+
+// rotatedServer serves h with a freshly generated certificate instead of
+// httptest's shared one.
+func rotatedServer(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{Organization: []string{"Rotated Co"}},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)}, KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TLS details and reuse are captured; a second server with another
+// certificate under the same target raises tls.cert_changed but is not refused.
+func TestConnCaptureCertChanged(t *testing.T) {
+	r := statusRoot(t)
+	r.alerts = alert.New("root", log.New(io.Discard, "", 0))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+	first := httptest.NewUnstartedServer(ok)
+	first.EnableHTTP2 = true
+	first.StartTLS()
+	defer first.Close()
+
+	c1 := r.observeConn("payment", first.URL, "c1", call(t, first, first.Client()))
+	if c1.Transport != transportTLS || c1.TLSVersion != "TLS 1.3" || c1.ALPN != "h2" || c1.Reused || !strings.HasPrefix(c1.PeerFingerprint, "sha256:") {
+		t.Fatalf("first call %+v", c1)
+	}
+	c2 := r.observeConn("payment", first.URL, "c1", call(t, first, first.Client()))
+	if !c2.Reused || c2.PeerFingerprint != c1.PeerFingerprint {
+		t.Fatalf("second call %+v", c2)
+	}
+
+	second := rotatedServer(t, ok)
+	c3 := r.observeConn("payment", second.URL, "c2", call(t, second, second.Client()))
+	if c3 == nil || c3.PeerFingerprint == c1.PeerFingerprint || c3.PeerSubject != "O=Rotated Co" {
+		t.Fatalf("rotated call %+v", c3)
+	}
+	obs := r.certs.list()
+	if len(obs) != 1 || obs[0].Pinned != c1.PeerFingerprint || obs[0].Observed != c3.PeerFingerprint || obs[0].Changes != 1 {
+		t.Fatalf("status %+v", obs)
+	}
+
+	r.alerts.Close()
+	h := r.alerts.History()
+	if len(h) != 1 || h[0].Alert.Kind != "tls.cert_changed" || h[0].Alert.Severity != alert.SeverityWarning || h[0].Alert.Detail["previous"] != c1.PeerFingerprint {
+		t.Fatalf("alerts %+v", h)
+	}
+}
+
+// Plain HTTP is recorded as cleartext, and a call that never connected has no summary.
+func TestConnCaptureCleartext(t *testing.T) {
+	r := statusRoot(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+	c := r.observeConn("medical", srv.URL, "", call(t, srv, srv.Client()))
+	if c.Transport != transportCleartext || c.PeerFingerprint != "" || c.auditDetail()["tlsVersion"] != nil {
+		t.Fatalf("cleartext %+v", c)
+	}
+	if _, cc := withConnCapture(t.Context()); r.observeConn("medical", srv.URL, "", cc) != nil {
+		t.Fatal("summary without a connection")
+	}
+}
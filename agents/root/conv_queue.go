@@ -0,0 +1,267 @@
+// Package root - per-conversation request serialization.
+//
+// Stage machines (payment confirm/re-quote, medical clarify, refunds) read and
+// write per-cid state without their own locking, so two /process calls for
+// the same cid racing through them could issue two previews with different
+// tokens or apply a confirm to half-merged slots. /process therefore holds a
+// per-cid slot for the whole turn: requests for one conversation run one at a
+// time in arrival order, different conversations (and /status, /metrics, ...)
+// stay fully parallel.
+//
+// At most ROOT_CONV_QUEUE_DEPTH (default 4) requests wait behind the running
+// one; the next gets 409 conversation_busy with its would-be position and an
+// ETA (also Retry-After). A waiter gives up with the same 409 after
+// ROOT_CONV_QUEUE_WAIT_MS (default 30000), and silently when its client
+// disconnects. The slot is released by a deferred call, so panics and client
+// disconnects during processing release it too. Redirect re-runs (redirect.go)
+// already hold the slot.
+//
+// Wait time is exported as sage_root_conv_queue_wait_seconds; /status shows
+// the queue under "conversationQueue".
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// convSlot serializes one conversation. sem (capacity 1) is held by the
+// running request; blocked senders queue on it in FIFO order.
+type convSlot struct {
+	sem     chan struct{}
+	waiting int // queued behind the holder
+	refs    int // holder + waiters; the slot is dropped at 0
+}
+
+// convWaitBuckets are the histogram bounds in seconds.
+var convWaitBuckets = []float64{0.005, 0.025, 0.1, 0.5, 1, 2.5, 5, 10, 30}
+
+type convQueue struct {
+	mu    sync.Mutex
+	slots map[string]*convSlot
+
+	depth   int
+	maxWait time.Duration
+
+	avgHoldNs atomic.Int64 // EWMA of how long a turn holds the slot (ETA)
+
+	waited   atomic.Int64 // requests that had to queue
+	rejected atomic.Int64 // 409 busy (queue full or wait timeout)
+	gaveUp   atomic.Int64 // clients that disconnected while queued
+
+	histMu  sync.Mutex
+	buckets []int64
+	sum     float64
+	count   int64
+}
+
+func newConvQueue() *convQueue {
+	return &convQueue{
+		slots:   map[string]*convSlot{},
+		depth:   envInt("ROOT_CONV_QUEUE_DEPTH", 4),
+		maxWait: time.Duration(envInt("ROOT_CONV_QUEUE_WAIT_MS", 30000)) * time.Millisecond,
+		buckets: make([]int64, len(convWaitBuckets)),
+	}
+}
+
+// convBusy is why a request did not get the slot.
+type convBusy struct {
+	Position int           // place in the queue it would have had (1 = next)
+	ETA      time.Duration // estimated wait
+	TimedOut bool          // waited ROOT_CONV_QUEUE_WAIT_MS without getting it
+}
+
+// acquire waits for cid's slot. It returns a release func, or a *convBusy
+// when the queue is full or the wait timed out, or ctx's error when the
+// client went away.
+func (q *convQueue) acquire(ctx context.Context, cid string) (func(), *convBusy, error) {
+	q.mu.Lock()
+	s := q.slots[cid]
+	if s == nil {
+		s = &convSlot{sem: make(chan struct{}, 1)}
+		q.slots[cid] = s
+	}
+	// Fast path: nobody holds it
+	select {
+	case s.sem <- struct{}{}:
+		s.refs++
+		q.mu.Unlock()
+		return q.releaser(cid, s, time.Now()), nil, nil
+	default:
+	}
+	if s.waiting >= q.depth {
+		busy := &convBusy{Position: s.waiting + 1, ETA: q.eta(s.waiting + 1)}
+		q.mu.Unlock()
+		q.rejected.Add(1)
+		return nil, busy, nil
+	}
+	s.waiting++
+	s.refs++
+	pos := s.waiting
+	q.mu.Unlock()
+	q.waited.Add(1)
+
+	start := time.Now()
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	leave := func() {
+		q.mu.Lock()
+		s.waiting--
+		q.dropLocked(cid, s)
+		q.mu.Unlock()
+	}
+	select {
+	case s.sem <- struct{}{}:
+		q.mu.Lock()
+		s.waiting--
+		q.mu.Unlock()
+		q.observeWait(time.Since(start))
+		return q.releaser(cid, s, time.Now()), nil, nil
+	case <-timer.C:
+		leave()
+		q.rejected.Add(1)
+		q.observeWait(time.Since(start))
+		return nil, &convBusy{Position: pos, ETA: q.eta(pos), TimedOut: true}, nil
+	case <-ctx.Done():
+		leave()
+		q.gaveUp.Add(1)
+		q.observeWait(time.Since(start))
+		return nil, nil, ctx.Err()
+	}
+}
+
+// releaser frees the slot once; it also feeds the hold-time EWMA.
+func (q *convQueue) releaser(cid string, s *convSlot, since time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			held := time.Since(since).Nanoseconds()
+			if prev := q.avgHoldNs.Load(); prev == 0 {
+				q.avgHoldNs.Store(held)
+			} else {
+				q.avgHoldNs.Store(prev + (held-prev)/5)
+			}
+			<-s.sem
+			q.mu.Lock()
+			q.dropLocked(cid, s)
+			q.mu.Unlock()
+		})
+	}
+}
+
+func (q *convQueue) dropLocked(cid string, s *convSlot) {
+	s.refs--
+	if s.refs == 0 && q.slots[cid] == s {
+		delete(q.slots, cid)
+	}
+}
+
+// eta estimates the wait for queue position pos (the running turn plus pos-1
+// turns ahead of it, each at the average hold time).
+func (q *convQueue) eta(pos int) time.Duration {
+	avg := time.Duration(q.avgHoldNs.Load())
+	if avg <= 0 {
+		avg = time.Second
+	}
+	return time.Duration(pos) * avg
+}
+
+func (q *convQueue) observeWait(d time.Duration) {
+	sec := d.Seconds()
+	q.histMu.Lock()
+	defer q.histMu.Unlock()
+	for i, b := range convWaitBuckets {
+		if sec <= b {
+			q.buckets[i]++
+		}
+	}
+	q.sum += sec
+	q.count++
+}
+
+func (q *convQueue) snapshot() map[string]any {
+	q.mu.Lock()
+	active, waiting := len(q.slots), 0
+	for _, s := range q.slots {
+		waiting += s.waiting
+	}
+	q.mu.Unlock()
+	q.histMu.Lock()
+	avgWait := 0.0
+	if q.count > 0 {
+		avgWait = q.sum / float64(q.count) * 1000
+	}
+	q.histMu.Unlock()
+	return map[string]any{
+		"maxDepth":      q.depth,
+		"maxWaitMs":     q.maxWait.Milliseconds(),
+		"conversations": active,
+		"waiting":       waiting,
+		"queued":        q.waited.Load(),
+		"rejected":      q.rejected.Load(),
+		"gaveUp":        q.gaveUp.Load(),
+		"avgWaitMs":     math.Round(avgWait*10) / 10,
+		"avgTurnMs":     time.Duration(q.avgHoldNs.Load()).Milliseconds(),
+	}
+}
+
+// writePrometheus appends the wait histogram and counters to a /metrics body.
+func (q *convQueue) writePrometheus(w io.Writer) {
+	var b strings.Builder
+	q.histMu.Lock()
+	b.WriteString("# HELP sage_root_conv_queue_wait_seconds Time /process requests waited for their conversation's slot.\n# TYPE sage_root_conv_queue_wait_seconds histogram\n")
+	for i, le := range convWaitBuckets {
+		b.WriteString(`sage_root_conv_queue_wait_seconds_bucket{le="` + strconv.FormatFloat(le, 'g', -1, 64) + `"} ` + strconv.FormatInt(q.buckets[i], 10) + "\n")
+	}
+	b.WriteString(`sage_root_conv_queue_wait_seconds_bucket{le="+Inf"} ` + strconv.FormatInt(q.count, 10) + "\n")
+	b.WriteString("sage_root_conv_queue_wait_seconds_sum " + strconv.FormatFloat(q.sum, 'f', 6, 64) + "\n")
+	b.WriteString("sage_root_conv_queue_wait_seconds_count " + strconv.FormatInt(q.count, 10) + "\n")
+	q.histMu.Unlock()
+	b.WriteString("# HELP sage_root_conv_queue_rejected_total /process requests answered 409 conversation_busy.\n# TYPE sage_root_conv_queue_rejected_total counter\n")
+	b.WriteString("sage_root_conv_queue_rejected_total " + strconv.FormatInt(q.rejected.Load(), 10) + "\n")
+	_, _ = io.WriteString(w, b.String())
+}
+
+// enterConversation takes cid's slot for a /process turn. ok=false means the
+// response was written (409) or the client is gone.
+func (r *RootAgent) enterConversation(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang string) (func(), bool) {
+	release, busy, err := r.convq.acquire(req.Context(), cid)
+	if err != nil {
+		r.logger.Printf("[root][queue] cid=%s client left while queued: %v", cid, err)
+		return nil, false
+	}
+	if busy != nil {
+		r.logger.Printf("[root][queue] cid=%s busy position=%d eta=%s timedOut=%v", cid, busy.Position, busy.ETA, busy.TimedOut)
+		writeConversationBusy(w, msg, cid, lang, busy)
+		return nil, false
+	}
+	return release, true
+}
+
+// writeConversationBusy answers 409 conversation_busy.
+func writeConversationBusy(w http.ResponseWriter, msg *types.AgentMessage, cid, lang string, busy *convBusy) {
+	etaMs := busy.ETA.Milliseconds()
+	out := types.AgentMessage{
+		ID: msg.ID + "-busy", ContextID: cid, From: "root", To: msg.From, Type: "error",
+		Content:   tr(lang, "root.conversation_busy", int(math.Ceil(busy.ETA.Seconds()))),
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"lang": lang, "error": "conversation_busy", "httpStatus": http.StatusConflict,
+			"position": busy.Position, "etaMs": etaMs, "timedOut": busy.TimedOut,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(busy.ETA.Seconds())))))
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(out)
+}
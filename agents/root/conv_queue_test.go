@@ -0,0 +1,153 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func testQueue(depth int, wait time.Duration) *convQueue {
+	q := newConvQueue()
+	q.depth, q.maxWait = depth, wait
+	return q
+}
+
+// waitQueued blocks until n requests are queued on cid.
+func waitQueued(t *testing.T, q *convQueue, cid string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		s := q.slots[cid]
+		got := s != nil && s.waiting == n
+		q.mu.Unlock()
+		if got {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("never saw %d queued on %s", n, cid)
+}
+
+// Waiters on one conversation run one at a time in arrival order; other
+// conversations are not held up.
+func TestConvQueueFIFO(t *testing.T) {
+	q := testQueue(4, time.Second)
+	release, busy, err := q.acquire(context.Background(), "c1")
+	if busy != nil || err != nil {
+		t.Fatalf("first acquire: %+v %v", busy, err)
+	}
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rel, busy, err := q.acquire(context.Background(), "c1")
+			if busy != nil || err != nil {
+				t.Errorf("waiter %d: %+v %v", i, busy, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			rel()
+		}()
+		waitQueued(t, q, "c1", i)
+	}
+
+	other, busy, _ := q.acquire(context.Background(), "c2")
+	if busy != nil || other == nil {
+		t.Fatal("another conversation waited on c1")
+	}
+	other()
+
+	release()
+	wg.Wait()
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+		t.Fatalf("order %v", order)
+	}
+	if len(q.slots) != 0 {
+		t.Fatalf("slots left behind: %v", q.slots)
+	}
+}
+
+// Past the queue depth the request is turned away at once with its would-be
+// position; a waiter that runs out of time gets the same answer, timed out.
+func TestConvQueueBusy(t *testing.T) {
+	q := testQueue(1, 30*time.Millisecond)
+	q.avgHoldNs.Store(int64(200 * time.Millisecond))
+	release, _, _ := q.acquire(context.Background(), "c1")
+	defer release()
+
+	done := make(chan *convBusy)
+	go func() {
+		_, busy, _ := q.acquire(context.Background(), "c1")
+		done <- busy
+	}()
+	waitQueued(t, q, "c1", 1)
+
+	_, busy, err := q.acquire(context.Background(), "c1")
+	if err != nil || busy == nil || busy.TimedOut || busy.Position != 2 || busy.ETA != 400*time.Millisecond {
+		t.Fatalf("full queue: %+v %v", busy, err)
+	}
+	if b := <-done; b == nil || !b.TimedOut || b.Position != 1 {
+		t.Fatalf("wait timeout: %+v", b)
+	}
+	if got := q.snapshot(); got["rejected"] != int64(2) || got["waiting"] != 0 {
+		t.Fatalf("snapshot %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	writeConversationBusy(rec, &types.AgentMessage{ID: "m1", From: "client"}, "c1", "en", busy)
+	var out types.AgentMessage
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") != "1" ||
+		out.Metadata["error"] != "conversation_busy" || out.Metadata["position"] != float64(2) || out.Metadata["etaMs"] != float64(400) {
+		t.Fatalf("409: %d %q %+v", rec.Code, rec.Header().Get("Retry-After"), out.Metadata)
+	}
+}
+
+// A turn that panics still frees the slot through its deferred release, and a
+// client that disconnects while queued leaves the queue.
+func TestConvQueueRelease(t *testing.T) {
+	q := testQueue(4, time.Second)
+	func() {
+		defer func() { _ = recover() }()
+		release, _, _ := q.acquire(context.Background(), "c1")
+		defer release()
+		panic("handler blew up")
+	}()
+	release, busy, _ := q.acquire(context.Background(), "c1")
+	if busy != nil || release == nil {
+		t.Fatal("slot still held after panic")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gone := make(chan error)
+	go func() {
+		_, _, err := q.acquire(ctx, "c1")
+		gone <- err
+	}()
+	waitQueued(t, q, "c1", 1)
+	cancel()
+	if err := <-gone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("disconnect: %v", err)
+	}
+	waitQueued(t, q, "c1", 0)
+	release()
+	release() // idempotent
+	if len(q.slots) != 0 || q.gaveUp.Load() != 1 {
+		t.Fatalf("slots %v gaveUp %d", q.slots, q.gaveUp.Load())
+	}
+}
@@ -0,0 +1,37 @@
+package root
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// convState is what Root remembers about its conversations between turns.
+// Each RootAgent owns one, so several agents in one process (tests, an
+// in-process harness) never see each other's conversations. The zero value
+// is ready to use.
+type convState struct {
+	payContextStore payStore
+	medStore        sync.Map // cid -> medCtx
+	parkedFlows     parkedStore
+	clarifyDepths   sync.Map // cid|domain -> clarifyDepth
+	planTurns       sync.Map // cid -> *atomic.Int64 (planning turns, slot provenance)
+	upstreamAsks    sync.Map // cid -> *upstreamAsk
+	authPendings    sync.Map // cid -> heldAuth
+	convTimezones   sync.Map // cid -> IANA name
+	forkBook        forkStore
+	reminderBook    reminderStore
+
+	// payment ledger (ledger.go)
+	payAttempts sync.Map // cid -> []payAttempt (oldest first)
+	attemptMu   sync.Mutex
+	ledgerSeen  sync.Map // cid|attempt|status -> struct{} (audited)
+
+	// receipts and refunds (refund_flow.go)
+	receiptHistory sync.Map // cid -> []receiptRef (oldest first)
+	receiptMu      sync.Mutex
+	refundPendings sync.Map // cid -> refundPending
+	refundAskOrder sync.Map // cid -> struct{} (asked for an order number)
+
+	payExtractTimings extractTimings
+	lastLLMWarm       atomic.Int64 // unix nanos
+}
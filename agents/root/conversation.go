@@ -0,0 +1,151 @@
+// Package root - conversation state view.
+//
+// GET /conversations/{cid} (admin) returns what Root currently holds for one
+// conversation: the payment stage (with the confirm token while a confirm or
+// re-quote is pending), collected payment and medical slots, a pending refund
+// confirmation, a parked upstream question, stale flows parked for resume
+// (await_expiry.go), the receipts of earlier payments and reminders
+// (reminders.go). Times are UTC; receipts and reminders also carry the local
+// time in the conversation's time zone (timezone.go). It is read-only; the RPC
+// method GetConversation serves the same view (rpc.go).
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+)
+
+type convPayment struct {
+	Stage        string            `json:"stage,omitempty"`
+	ConfirmToken string            `json:"confirmToken,omitempty"`
+	Slots        map[string]any    `json:"slots,omitempty"`
+	Provenance   map[string]string `json:"provenance,omitempty"`
+}
+
+type convMedical struct {
+	Await      string `json:"await,omitempty"`
+	Condition  string `json:"condition,omitempty"`
+	Topic      string `json:"topic,omitempty"`
+	Symptoms   string `json:"symptoms,omitempty"`
+	Turns      int    `json:"turns"`
+	FirstQ     string `json:"initialQuestion,omitempty"`
+	Medication string `json:"medications,omitempty"`
+}
+
+type convReceipt struct {
+	OrderID   string    `json:"orderId"`
+	AmountKRW int64     `json:"amountKRW"`
+	Item      string    `json:"item,omitempty"`
+	At        time.Time `json:"at"`
+	AtLocal   string    `json:"atLocal"`
+	Refunded  bool      `json:"refunded"`
+}
+
+type conversationView struct {
+	ContextID     string           `json:"contextId"`
+	Timezone      string           `json:"timezone"`
+	Payment       *convPayment     `json:"payment,omitempty"`
+	Medical       *convMedical     `json:"medical,omitempty"`
+	RefundPending map[string]any   `json:"refundPending,omitempty"`
+	AuthPending   map[string]any   `json:"authPending,omitempty"`
+	UpstreamAsk   map[string]any   `json:"upstreamAsk,omitempty"`
+	Parked        []map[string]any `json:"parked,omitempty"`
+	Receipts      []convReceipt    `json:"receipts"`
+	Reminders     []reminderView   `json:"reminders,omitempty"`
+	Known         bool             `json:"known"`
+}
+
+// viewConversation snapshots the in-memory state kept for cid.
+func (r *RootAgent) viewConversation(cid string) conversationView {
+	loc := r.convTimezone(cid)
+	v := conversationView{ContextID: cid, Timezone: loc.String(), Receipts: []convReceipt{}}
+
+	stage, token := r.getStageToken(cid)
+	slots := r.getPayCtx(cid)
+	if stage != "" || payCtxNotEmpty(slots) {
+		p := &convPayment{Stage: stage, Slots: paySlotsView(slots), Provenance: slots.Prov.compact()}
+		if stage == "await_confirm" || stage == "await_requote" {
+			p.ConfirmToken = token
+		}
+		v.Payment = p
+	}
+	if r.hasMedCtx(cid) {
+		st := r.getMedCtx(cid)
+		v.Medical = &convMedical{
+			Await: st.Await, Condition: st.Slots.Condition, Topic: st.Slots.Topic, Symptoms: st.Symptoms,
+			Turns: len(st.Transcript), FirstQ: st.FirstQ, Medication: st.Slots.Medications,
+		}
+	}
+	if x, ok := r.refundPendings.Load(cid); ok {
+		p := x.(refundPending)
+		v.RefundPending = map[string]any{"orderId": p.Receipt.OrderID, "amountKRW": p.Receipt.AmountKRW, "confirmToken": p.Token}
+	}
+	if x, ok := r.authPendings.Load(cid); ok {
+		h := x.(heldAuth)
+		v.AuthPending = map[string]any{"authId": h.AuthID, "heldKRW": h.HeldKRW, "expiresAt": h.ExpiresAt.UTC(), "expiresAtLocal": tz.Local(h.ExpiresAt, loc)}
+	}
+	if x, ok := r.upstreamAsks.Load(cid); ok {
+		a := x.(*upstreamAsk)
+		v.UpstreamAsk = map[string]any{"target": a.Target, "question": a.Question, "rounds": a.Rounds, "at": a.At}
+	}
+	v.Parked = r.parkedView(cid)
+	for _, rc := range r.receiptsOf(cid) {
+		v.Receipts = append(v.Receipts, convReceipt{
+			OrderID: rc.OrderID, AmountKRW: rc.AmountKRW, Item: rc.Item,
+			At: rc.At.UTC(), AtLocal: tz.Local(rc.At, loc), Refunded: rc.Refunded,
+		})
+	}
+	v.Reminders = r.remindersOf(cid)
+	v.Known = v.Payment != nil || v.Medical != nil || v.RefundPending != nil || v.AuthPending != nil || v.UpstreamAsk != nil || len(v.Receipts) > 0 || len(v.Parked) > 0 || len(v.Reminders) > 0
+	return v
+}
+
+// paySlotsView lists the filled payment slots.
+func paySlotsView(s paySlots) map[string]any {
+	m := map[string]any{}
+	put := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			m[k] = v
+		}
+	}
+	put("mode", s.Mode)
+	put("recipient", firstNonEmpty(s.Recipient, s.To))
+	put("method", s.Method)
+	put("item", s.Item)
+	put("model", s.Model)
+	put("merchant", s.Merchant)
+	put("shipping", s.Shipping)
+	put("cardLast4", s.CardLast4)
+	if s.AmountKRW > 0 {
+		m["amountKRW"] = s.AmountKRW
+	}
+	if s.BudgetKRW > 0 {
+		m["budgetKRW"] = s.BudgetKRW
+	}
+	return m
+}
+
+// handleConversation serves GET /conversations/{cid}; 404 when Root holds
+// nothing for it.
+func (r *RootAgent) handleConversation(w http.ResponseWriter, req *http.Request, cid string) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	v := r.viewConversation(cid)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !v.Known {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": "unknown conversation", "cid": cid})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,227 @@
+// Package root - user-facing error presentation.
+//
+// End users get a localized, generic message per error class; the full detail
+// (upstream URL, transport error, upstream body) goes to the audit log and to a
+// "debug" metadata block that is only included for operators (admin token or
+// X-SAGE-Debug: true).
+package root
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+)
+
+// Error classes (metadata error.code)
+const (
+	errClassUnreachable  = "upstream_unreachable"
+	errClassVerification = "verification_failed"
+	errClassInvalid      = "invalid_request"
+	errClassUpstream     = "upstream_error"
+	errClassConfig       = "config_error"
+
+	// The upstream processed the request but its HPKE response did not open
+	errClassHPKEResponse = "HPKE_RESPONSE_DECRYPT_FAILED"
+)
+
+var userErrorText = map[string]map[string]string{
+	errClassUnreachable: {
+		"ko": "지금은 해당 서비스에 연결할 수 없어요. 잠시 후 다시 시도해 주세요.",
+		"en": "The service is unreachable right now. Please try again shortly.",
+	},
+	errClassVerification: {
+		"ko": "요청의 무결성 검증에 실패해 처리하지 않았어요.",
+		"en": "The request failed integrity verification and was not processed.",
+	},
+	errClassInvalid: {
+		"ko": "요청을 처리할 수 없어요. 입력을 확인해 주세요.",
+		"en": "The request could not be processed. Please check your input.",
+	},
+	errClassUpstream: {
+		"ko": "처리 중 문제가 발생했어요. 잠시 후 다시 시도해 주세요.",
+		"en": "Something went wrong while processing. Please try again shortly.",
+	},
+	errClassConfig: {
+		"ko": "이 요청은 현재 설정으로 처리할 수 없어요. 운영자에게 문의해 주세요.",
+		"en": "This request cannot be handled with the current configuration. Please contact the operator.",
+	},
+	errClassHPKEResponse: {
+		"ko": "요청은 전달되었지만 응답의 무결성을 확인하지 못했어요. 요청이 이미 처리되었을 수 있어요.",
+		"en": "The request was delivered but its response could not be verified. It may already have been processed.",
+	},
+}
+
+func userErrorMessage(lang, class string) string {
+	m, ok := userErrorText[class]
+	if !ok {
+		m = userErrorText[errClassUpstream]
+	}
+	return m[langOrDefault(lang)]
+}
+
+// wantsDebug: operator detail only with the admin token or X-SAGE-Debug: true.
+func wantsDebug(req *http.Request) bool {
+	return hasAdminToken(req) || strings.EqualFold(strings.TrimSpace(req.Header.Get("X-SAGE-Debug")), "true")
+}
+
+// classifySendErr maps a transport-level send error to an error class. Typed
+// transport errors decide first; text matching covers the rest (hpke, signing).
+func classifySendErr(err error) string {
+	var ce *opConfigError
+	if errors.As(err, &ce) {
+		return errClassConfig
+	}
+	var he *hpkeResponseError
+	if errors.As(err, &he) {
+		return errClassHPKEResponse
+	}
+	if isPayloadInvariantErr(err) {
+		return errClassVerification
+	}
+	switch prototx.ErrorKind(err) {
+	case "connect", "timeout":
+		return errClassUnreachable
+	case "http_status", "body_read":
+		return errClassUpstream
+	}
+	low := strings.ToLower(err.Error())
+	switch {
+	case containsAny(low, "connection refused", "no such host", "dial tcp", "i/o timeout", "no external url", "eof"):
+		return errClassUnreachable
+	case containsAny(low, "hpke", "signature", "digest", "verify"):
+		return errClassVerification
+	default:
+		return errClassUpstream
+	}
+}
+
+// classifyUpstreamOut maps an upstream error response to an error class.
+func classifyUpstreamOut(out *types.AgentMessage, status int) string {
+	low := strings.ToLower(out.Content)
+	sigFail, _ := out.Metadata["sigAuthFailed"].(bool)
+	tamper, _ := out.Metadata["tamperSuspect"].(bool)
+	switch {
+	case sigFail || tamper || looksLikeSigAuthFailure(low) || looksLikeContentDigestIssue(low):
+		return errClassVerification
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return errClassInvalid
+	default:
+		return errClassUpstream
+	}
+}
+
+// isErrorOut reports whether an upstream result is an error.
+func isErrorOut(out *types.AgentMessage) bool {
+	return strings.EqualFold(out.Type, "error") || strings.HasPrefix(strings.ToLower(out.Content), "external error:")
+}
+
+// internalErrorMetaKeys are stripped from user-facing responses (moved to debug).
+var internalErrorMetaKeys = []string{"upstream", "hpke_kid", "useSAGE", "hpkeEnabled"}
+
+// presentOut rewrites an upstream error result for the end user in place.
+// Call it after status/log decisions, right before encoding.
+func (r *RootAgent) presentOut(req *http.Request, lang, agent string, out *types.AgentMessage, status int) {
+	noteMetaStripped(req, out)
+	if !isErrorOut(out) {
+		r.checkResponseSchema(agent, out)
+		return
+	}
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	class := classifyUpstreamOut(out, status)
+	r.runs.noteError()
+	debug := map[string]any{"detail": out.Content, "status": status}
+	for _, k := range internalErrorMetaKeys {
+		if v, ok := out.Metadata[k]; ok {
+			debug[k] = v
+			delete(out.Metadata, k)
+		}
+	}
+	r.audit.Emit(audit.Event{
+		Type: "error", Action: "upstream.error", Outcome: "failure", Actor: requesterOf(req), Target: agent,
+		CID: out.ContextID, Detail: map[string]any{"class": class, "debug": debug},
+	})
+	out.Content = userErrorMessage(lang, class)
+	out.Metadata["error"] = map[string]any{"code": class}
+	if wantsDebug(req) {
+		out.Metadata["debug"] = debug
+	}
+}
+
+// writeSendError maps a send error to an HTTP response: SLA overruns become a
+// phase-attributed 504, payloads over the upstream's limits a 413
+// (payload_limits.go); everything else is a generic, localized 502.
+func (r *RootAgent) writeSendError(w http.ResponseWriter, req *http.Request, lang, agent string, err error) {
+	var se *slaError
+	if errors.As(err, &se) {
+		writeSLAError(w, se)
+		return
+	}
+	var pe *payloadTooLargeError
+	if errors.As(err, &pe) {
+		r.writePayloadTooLarge(w, req, lang, agent, pe)
+		return
+	}
+	class := classifySendErr(err)
+	r.runs.noteError()
+	r.audit.Emit(audit.Event{
+		Type: "error", Action: "send.error", Outcome: "failure", Actor: requesterOf(req), Target: agent,
+		Detail: map[string]any{"class": class, "detail": err.Error(), "upstream": r.externalURLFor(agent)},
+	})
+	out := types.AgentMessage{
+		ID: "root-error", From: "root", To: "client", Type: "error",
+		Content:   userErrorMessage(lang, class),
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"error": map[string]any{"code": class}, "domain": agent, "lang": lang},
+	}
+	if wantsDebug(req) {
+		out.Metadata["debug"] = map[string]any{"detail": err.Error(), "upstream": r.externalURLFor(agent)}
+	}
+	code := http.StatusBadGateway
+	if class == errClassConfig {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// writeHPKEError answers a failed HPKE handshake started from an admin
+// endpoint (enable, repin). The caller gets the error class and the target's
+// status; the error itself goes to the audit log (action, with extra detail)
+// and to "debug" for operators.
+func (r *RootAgent) writeHPKEError(w http.ResponseWriter, req *http.Request, action, target string, err error, extra map[string]any) {
+	class := classifySendErr(err)
+	if containsAny(strings.ToLower(err.Error()), "load keys", "not configured", "alias not found", "initsigning") {
+		class = errClassConfig
+	}
+	detail := map[string]any{"class": class, "reason": err.Error()}
+	for k, v := range extra {
+		detail[k] = v
+	}
+	r.audit.Emit(audit.Event{
+		Type: "hpke", Action: action, Outcome: "failure", Actor: requesterOf(req), Target: target, Detail: detail,
+	})
+	out := map[string]any{
+		"error":   class,
+		"message": userErrorMessage("", class),
+		"status":  r.hpkeStatus(target),
+	}
+	if wantsDebug(req) {
+		out["debug"] = map[string]any{"detail": err.Error(), "upstream": r.externalURLFor(target)}
+	}
+	code := http.StatusBadGateway
+	if class == errClassConfig {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(out)
+}
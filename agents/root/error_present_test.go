@@ -0,0 +1,56 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A failed HPKE handshake from /hpke/config or /hpke/repin answers with the
+// error class and a generic message; the reason is operator detail only.
+func TestHPKEErrorPresented(t *testing.T) {
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	t.Setenv("PAYMENT_URL", "http://127.0.0.1:1")
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+
+	// no admin token: no debug block, no raw error
+	b, _ := json.Marshal(map[string]any{"enabled": true, "target": "payment"})
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hpke/config", bytes.NewReader(b)))
+	out := hpkeErrorBody(t, w.Code, w.Body.String())
+	if out["debug"] != nil || strings.Contains(w.Body.String(), "HPKE:") {
+		t.Fatalf("internal error leaked: %s", w.Body)
+	}
+
+	// operators get the reason under debug
+	w = adminCall(r, http.MethodPost, "/hpke/repin", map[string]any{"target": "payment"})
+	out = hpkeErrorBody(t, w.Code, w.Body.String())
+	if d, _ := out["debug"].(map[string]any); d == nil || d["detail"] == "" {
+		t.Fatalf("admin debug: %s", w.Body)
+	}
+}
+
+func hpkeErrorBody(t *testing.T, code int, body string) map[string]any {
+	t.Helper()
+	if code < 500 {
+		t.Fatalf("status %d: %s", code, body)
+	}
+	var out map[string]any
+	if err := json.NewDecoder(strings.NewReader(body)).Decode(&out); err != nil {
+		t.Fatalf("not JSON: %q", body)
+	}
+	class, _ := out["error"].(string)
+	if _, ok := userErrorText[class]; !ok || out["message"] != userErrorMessage("", class) || out["status"] == nil {
+		t.Fatalf("presented error: %s", body)
+	}
+	return out
+}
@@ -0,0 +1,308 @@
+// Package root - direct fallback when the gateway is down.
+//
+// PAYMENT_URL / MEDICAL_URL default to the gateway (:5500/<agent>). In partial
+// dev setups the gateway is often not running while the agents are, and every
+// call fails to connect. <AGENT>_DIRECT_URL (PAYMENT_DIRECT_URL,
+// MEDICAL_DIRECT_URL, PLANNING_DIRECT_URL) names the agent's own address, e.g.
+// http://localhost:19083. When a send to the configured URL fails at the
+// connection level (ErrConnect after the connect retries, or its breaker is
+// open) Root sends the same call to the direct URL and marks the answer with
+// metadata routedVia "direct-fallback". Other failures (timeouts, HTTP errors)
+// are returned as-is: the request may have reached the agent.
+//
+// The configured URL is always tried first; while it is down its breaker
+// (send_policy.go) makes that attempt fail fast. Switching to the fallback and
+// back is logged and audited once per transition (routing/upstream.degraded,
+// routing/upstream.recovered) and raises a warning alert, since the gateway's
+// observation did not apply to those calls. Each fallback hop adds the posture
+// factor gateway.bypassed and "via" in security.timeline.
+//
+// The direct route is its own target for HPKE (session key "<agent>@direct",
+// a separate handshake), the breaker and TLS observation; server identity,
+// pins and KEM ownership are the agent's.
+//
+// When any direct URL is set, a prober checks GET /status on both URLs every
+// ROOT_UPSTREAM_PROBE_MS (default 15000, 0 = off). An address that answers as
+// another component counts as down. When the configured URL answers again
+// its breaker is reset so the next call prefers it. GET /sage/status lists
+// both routes under "extRoutes"; the probe results also feed the health
+// history (health_history.go).
+package root
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	"github.com/sage-x-project/sage-multi-agent/resilience"
+)
+
+const routedViaFallback = "direct-fallback"
+
+// extRoute is one concrete address of an upstream agent.
+type extRoute struct {
+	Agent string // payment | medical | planning
+	Key   string // HPKE session / breaker / TLS key: agent or directKey(agent)
+	Base  string
+	Via   string // "" or routedViaFallback
+}
+
+// outboundCall is a send prepared once and replayable on another route.
+type outboundCall struct {
+	op, method, path string
+	msg              *types.AgentMessage
+	body             []byte // plaintext; encrypted per route
+	useSAGE          bool
+	wantHPKE         bool
+}
+
+func directKey(agent string) string { return agent + "@direct" }
+
+// directURLsFromEnv reads <AGENT>_DIRECT_URL for each agent.
+func directURLsFromEnv(agents ...string) map[string]string {
+	out := map[string]string{}
+	for _, a := range agents {
+		if v := strings.TrimRight(strings.TrimSpace(os.Getenv(strings.ToUpper(a)+"_DIRECT_URL")), "/"); v != "" {
+			out[a] = v
+		}
+	}
+	return out
+}
+
+// SetDirectURL sets (or with "" clears) the direct fallback for agent.
+func (r *RootAgent) SetDirectURL(agent, base string) {
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	r.extMu.Lock()
+	defer r.extMu.Unlock()
+	if base == "" {
+		delete(r.direct, agent)
+		return
+	}
+	r.direct[agent] = base
+}
+
+// directRoute is agent's fallback route, if one is configured and differs
+// from the primary URL.
+func (r *RootAgent) directRoute(agent string) (extRoute, bool) {
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	r.extMu.RLock()
+	base, primary := r.direct[agent], r.extBase[agent]
+	r.extMu.RUnlock()
+	if base == "" || base == strings.TrimRight(primary, "/") {
+		return extRoute{}, false
+	}
+	return extRoute{Agent: agent, Key: directKey(agent), Base: base, Via: routedViaFallback}, true
+}
+
+// directTargets lists the agents that have a fallback route.
+func (r *RootAgent) directTargets() []string {
+	r.extMu.RLock()
+	defer r.extMu.RUnlock()
+	out := make([]string, 0, len(r.direct))
+	for k := range r.direct {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// sendFallback resends c to the direct route after the primary failed to
+// connect with primaryErr.
+func (r *RootAgent) sendFallback(ctx context.Context, primary, direct extRoute, c *outboundCall, primaryErr error) (*types.AgentMessage, error) {
+	r.upstreams.note(primary.Key, primary.Base, false, "send", primaryErr)
+	r.logger.Printf("[root][fallback] %s unreachable at %s (%v); retrying direct %s",
+		primary.Agent, primary.Base, primaryErr, direct.Base)
+
+	out, err := r.sendVia(ctx, direct, c)
+	if err != nil {
+		r.upstreams.note(direct.Key, direct.Base, !isConnectErr(err), "send", err)
+		return nil, fmt.Errorf("%w (direct fallback %s: %v)", primaryErr, direct.Base, err)
+	}
+	r.upstreams.note(direct.Key, direct.Base, true, "send", nil)
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	out.Metadata["routedVia"] = routedViaFallback
+	if r.upstreams.setDegraded(primary.Agent, true) {
+		r.logger.Printf("[root][fallback][warn] ⚠️ %s degraded: gateway route %s is down, serving via %s (gateway bypassed)",
+			primary.Agent, primary.Base, direct.Base)
+		r.audit.Emit(audit.Event{
+			Type: "routing", Action: "upstream.degraded", Outcome: "failure", Target: primary.Agent, CID: c.msg.ContextID,
+			Detail: map[string]any{"primary": primary.Base, "direct": direct.Base, "reason": primaryErr.Error()},
+		})
+		r.raiseAlert("gateway.bypassed", alert.SeverityWarning, primary.Agent, c.msg.ContextID, "upstream served via direct fallback; gateway bypassed", map[string]any{
+			"primary": primary.Base, "direct": direct.Base,
+		})
+	}
+	return out, nil
+}
+
+// notePrimaryReachable records that the primary answered (or at least
+// accepted the connection) and ends a degradation.
+func (r *RootAgent) notePrimaryReachable(primary extRoute) {
+	r.upstreams.note(primary.Key, primary.Base, true, "send", nil)
+	if r.upstreams.setDegraded(primary.Agent, false) {
+		r.logger.Printf("[root][fallback] %s recovered: back on %s", primary.Agent, primary.Base)
+		r.audit.Emit(audit.Event{
+			Type: "routing", Action: "upstream.recovered", Outcome: "success", Target: primary.Agent,
+			Detail: map[string]any{"primary": primary.Base},
+		})
+	}
+}
+
+// ---- availability ----
+
+// routeHealth is the last known availability of one route.
+type routeHealth struct {
+	URL       string    `json:"url"`
+	Up        *bool     `json:"up"` // nil: not checked yet
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
+	Source    string    `json:"source,omitempty"` // probe | send
+	Error     string    `json:"error,omitempty"`
+}
+
+// upstreamHealth tracks route availability and which agents are degraded.
+type upstreamHealth struct {
+	mu        sync.Mutex
+	routes    map[string]*routeHealth // route key -> health
+	degraded  map[string]bool         // agent -> served via fallback
+	fallbacks map[string]int64        // agent -> calls served via fallback
+	stop      chan struct{}
+}
+
+func newUpstreamHealth() *upstreamHealth {
+	return &upstreamHealth{routes: map[string]*routeHealth{}, degraded: map[string]bool{}, fallbacks: map[string]int64{}}
+}
+
+func (uh *upstreamHealth) note(key, url string, up bool, source string, err error) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	h := &routeHealth{URL: url, Up: &up, CheckedAt: time.Now().UTC(), Source: source}
+	if err != nil {
+		h.Error = err.Error()
+	}
+	uh.routes[key] = h
+}
+
+// setDegraded sets agent's state and reports whether it changed. Each
+// degraded=true call counts one fallback.
+func (uh *upstreamHealth) setDegraded(agent string, degraded bool) bool {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if degraded {
+		uh.fallbacks[agent]++
+	}
+	changed := uh.degraded[agent] != degraded
+	uh.degraded[agent] = degraded
+	return changed
+}
+
+func (uh *upstreamHealth) route(key string) *routeHealth {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if h, ok := uh.routes[key]; ok {
+		c := *h
+		return &c
+	}
+	return nil
+}
+
+// extRoutes is the /sage/status view: primary vs fallback per agent that has
+// a direct URL.
+func (r *RootAgent) extRoutes() map[string]any {
+	out := map[string]any{}
+	for _, agent := range r.directTargets() {
+		primary := r.upstreams.route(agent)
+		if primary == nil {
+			primary = &routeHealth{URL: r.externalURLFor(agent)}
+		}
+		direct := r.upstreams.route(directKey(agent))
+		if direct == nil {
+			d, _ := r.directRoute(agent)
+			direct = &routeHealth{URL: d.Base}
+		}
+		r.upstreams.mu.Lock()
+		active, n := "primary", r.upstreams.fallbacks[agent]
+		if r.upstreams.degraded[agent] {
+			active = routedViaFallback
+		}
+		r.upstreams.mu.Unlock()
+		out[agent] = map[string]any{"primary": primary, "fallback": direct, "active": active, "fallbackCalls": n}
+	}
+	return out
+}
+
+// startUpstreamProbe runs the availability prober (see the file comment).
+func (r *RootAgent) startUpstreamProbe() {
+	iv := envInt("ROOT_UPSTREAM_PROBE_MS", 15000)
+	if iv <= 0 || len(r.directTargets()) == 0 {
+		return
+	}
+	uh := r.upstreams
+	uh.mu.Lock()
+	if uh.stop != nil {
+		uh.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	uh.stop = stop
+	uh.mu.Unlock()
+
+	r.probeUpstreams(context.Background())
+	r.bg.Every(time.Duration(iv)*time.Millisecond, stop, r.probeUpstreams)
+}
+
+// probing reports whether the prober is running.
+func (uh *upstreamHealth) probing() bool {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return uh.stop != nil
+}
+
+func (r *RootAgent) stopUpstreamProbe() {
+	uh := r.upstreams
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if uh.stop != nil {
+		close(uh.stop)
+		uh.stop = nil
+	}
+}
+
+// probeUpstreams checks both routes of every agent with a direct URL.
+func (r *RootAgent) probeUpstreams(ctx context.Context) {
+	for _, agent := range r.directTargets() {
+		direct, ok := r.directRoute(agent)
+		if !ok {
+			continue
+		}
+		primary := extRoute{Agent: agent, Key: agent, Base: r.externalURLFor(agent)}
+		for _, rt := range []extRoute{primary, direct} {
+			if rt.Base == "" {
+				continue
+			}
+			prev := r.upstreams.route(rt.Key)
+			err := r.probeOnce(ctx, agent, rt.Key, rt.Base)
+			r.upstreams.note(rt.Key, rt.Base, err == nil, "probe", err)
+			wasUp := prev == nil || prev.Up == nil || *prev.Up
+			switch {
+			case err != nil && wasUp:
+				r.logger.Printf("[root][fallback] probe %s %s down: %v", rt.Key, rt.Base, err)
+			case err == nil && !wasUp:
+				r.logger.Printf("[root][fallback] probe %s %s up again", rt.Key, rt.Base)
+			}
+			if cb := r.breakerFor(rt.Key); err == nil && rt.Via == "" && cb.GetState() == resilience.StateOpen {
+				// Let the next call go to the primary instead of waiting out the breaker.
+				cb.Reset()
+			}
+		}
+	}
+}
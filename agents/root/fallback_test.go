@@ -0,0 +1,154 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	"github.com/sage-x-project/sage-multi-agent/resilience"
+)
+
+// fakeAgent answers /status as name and /process with a response naming it.
+func fakeAgent(t *testing.T, name string, status int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/status" {
+			_, _ = w.Write([]byte(`{"name":"payment","type":"payment"}`))
+			return
+		}
+		calls.Add(1)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "a1", From: "payment", Type: "response", Content: "from " + name})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// refusedURL is an address nothing listens on.
+func refusedURL(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return "http://" + addr
+}
+
+func fallbackRoot(t *testing.T, primary, direct string) *RootAgent {
+	t.Helper()
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_CB_FAILURES", "2")
+	t.Setenv("ROOT_CB_RESET_MS", "60000")
+	t.Setenv("PAYMENT_URL", primary)
+	t.Setenv("PAYMENT_DIRECT_URL", direct)
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+	return r
+}
+
+func (r *RootAgent) setPrimary(agent, base string) {
+	r.extMu.Lock()
+	r.extBase[agent] = base
+	r.extMu.Unlock()
+}
+
+func send(t *testing.T, r *RootAgent) (*types.AgentMessage, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return r.sendExternal(ctx, "payment", opProcess, &types.AgentMessage{ID: "m1", From: "root", To: "payment", Type: "request", Content: "pay"})
+}
+
+func degraded(r *RootAgent) (bool, int64) {
+	r.upstreams.mu.Lock()
+	defer r.upstreams.mu.Unlock()
+	return r.upstreams.degraded["payment"], r.upstreams.fallbacks["payment"]
+}
+
+func TestFallbackHarness(t *testing.T) {
+	direct, directCalls := fakeAgent(t, "direct", http.StatusOK)
+	r := fallbackRoot(t, refusedURL(t), direct.URL)
+
+	// primary refusing connections: served by the direct route and marked
+	out, err := send(t, r)
+	if err != nil || out.Content != "from direct" || out.Metadata["routedVia"] != routedViaFallback {
+		t.Fatalf("primary down: out=%+v err=%v", out, err)
+	}
+	if d, n := degraded(r); !d || n != 1 {
+		t.Fatalf("degraded=%v fallbacks=%d after the first fallback", d, n)
+	}
+
+	// breaker open: the primary fails fast and the direct route still serves
+	for i := 0; i < 2; i++ {
+		if _, err := send(t, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := r.breakerFor("payment").GetState(); st != resilience.StateOpen {
+		t.Fatalf("primary breaker %s, want open", st)
+	}
+	if out, err := send(t, r); err != nil || out.Metadata["routedVia"] != routedViaFallback {
+		t.Fatalf("breaker open: out=%+v err=%v", out, err)
+	}
+	if n := directCalls.Load(); n != 4 {
+		t.Fatalf("direct route served %d calls, want 4", n)
+	}
+
+	// primary recovering: the probe resets its breaker and calls go back to it
+	primary, primaryCalls := fakeAgent(t, "primary", http.StatusOK)
+	r.setPrimary("payment", primary.URL)
+	r.probeUpstreams(context.Background())
+	if st := r.breakerFor("payment").GetState(); st == resilience.StateOpen {
+		t.Fatal("probe did not reset the primary breaker")
+	}
+	out, err = send(t, r)
+	if err != nil || out.Content != "from primary" || out.Metadata["routedVia"] != nil {
+		t.Fatalf("primary back: out=%+v err=%v", out, err)
+	}
+	if d, _ := degraded(r); d || primaryCalls.Load() != 1 {
+		t.Fatalf("degraded=%v primary calls=%d after recovery", d, primaryCalls.Load())
+	}
+	routes := r.extRoutes()["payment"].(map[string]any)
+	if routes["active"] != "primary" || routes["fallbackCalls"] != int64(4) {
+		t.Fatalf("extRoutes = %v", routes)
+	}
+}
+
+func TestFallbackBothDown(t *testing.T) {
+	r := fallbackRoot(t, refusedURL(t), refusedURL(t))
+	_, err := send(t, r)
+	if err == nil || !strings.Contains(err.Error(), "direct fallback") {
+		t.Fatalf("both down: %v", err)
+	}
+	if d, _ := degraded(r); d {
+		t.Fatal("marked degraded without a fallback answer")
+	}
+}
+
+// An upstream that answered (here with a 500) may have acted on the request:
+// it is not retried on the direct route.
+func TestFallbackNotForHTTPErrors(t *testing.T) {
+	primary, _ := fakeAgent(t, "primary", http.StatusInternalServerError)
+	direct, directCalls := fakeAgent(t, "direct", http.StatusOK)
+	r := fallbackRoot(t, primary.URL, direct.URL)
+
+	out, _ := send(t, r)
+	if directCalls.Load() != 0 || (out != nil && out.Metadata["routedVia"] != nil) {
+		t.Fatalf("HTTP error went to the fallback: out=%+v direct calls=%d", out, directCalls.Load())
+	}
+}
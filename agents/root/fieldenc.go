@@ -0,0 +1,94 @@
+// Package root - field-level encryption of sensitive metadata.
+//
+// A hop that goes out without HPKE (not requested, or no session because the
+// upstream has no KEM key) can still hide selected metadata values from the
+// gateway: with <AGENT>_FIELDENC_KEY_FILE set (PAYMENT_FIELDENC_KEY_FILE,
+// MEDICAL_FIELDENC_KEY_FILE) the FIELDENC_FIELDS keys (default
+// payment.shipping, medical.symptoms, medical.history) are replaced by
+// {"enc":"v1","data":...} envelopes (internal/fieldenc) before the body is
+// signed, so the Content-Digest covers the sealed form. HPKE hops are not
+// sealed twice. Sealing grows each value (nonce, tag, base64), so the sealed
+// body is sized against the upstream's limits again (payload_limits.go) and
+// refused like any oversized payload when it no longer fits.
+//
+// The key is a static secret shared out of band with that agent — no
+// forward secrecy, and everything else in the message stays readable — so
+// the posture keeps such hops apart from HPKE: factor upstream.field_encrypted
+// and "fieldEncrypted" in security.timeline; the level is not raised. A key
+// file that is set but unreadable fails sends to that agent instead of
+// falling back to cleartext.
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// fieldSealer holds the per-target keys.
+type fieldSealer struct {
+	fields []string
+	keys   map[string]*fieldenc.Key
+	errs   map[string]error // key file set but not loadable
+}
+
+func newFieldSealer(agents ...string) *fieldSealer {
+	fs := &fieldSealer{fields: fieldenc.FieldsFromEnv(), keys: map[string]*fieldenc.Key{}, errs: map[string]error{}}
+	for _, a := range agents {
+		k, err := fieldenc.KeyFromEnv(strings.ToUpper(a) + "_FIELDENC_KEY_FILE")
+		switch {
+		case err != nil:
+			fs.errs[a] = err
+		case k != nil:
+			fs.keys[a] = k
+		}
+	}
+	return fs
+}
+
+// sealFields returns msg's body and metadata with the configured fields
+// sealed for agent, and the sealed keys; a nil body means there was nothing
+// to seal.
+func (r *RootAgent) sealFields(agent string, msg *types.AgentMessage) ([]byte, map[string]any, []string, error) {
+	if err := r.fieldEnc.errs[agent]; err != nil {
+		return nil, nil, nil, fmt.Errorf("fieldenc: %w", err)
+	}
+	k := r.fieldEnc.keys[agent]
+	if k == nil || len(msg.Metadata) == 0 {
+		return nil, nil, nil, nil
+	}
+	meta, sealed, err := k.Seal(msg.Metadata, r.fieldEnc.fields)
+	if err != nil || len(sealed) == 0 {
+		return nil, nil, nil, err
+	}
+	cp := *msg
+	cp.Metadata = meta
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return body, meta, sealed, nil
+}
+
+// noteFieldEncReport logs sealed values the upstream could not open.
+func (r *RootAgent) noteFieldEncReport(agent string, out *types.AgentMessage) {
+	fe, _ := out.Metadata["fieldEnc"].(map[string]any)
+	if u, ok := fe["unreadable"].([]any); ok && len(u) > 0 {
+		r.logger.Printf("[root][fieldenc][warn] %s could not open %v; check its FIELDENC_KEY_FILE", agent, u)
+	}
+}
+
+// status is the /sage/status view.
+func (fs *fieldSealer) status() map[string]any {
+	targets := map[string]string{}
+	for a := range fs.keys {
+		targets[a] = "key"
+	}
+	for a, err := range fs.errs {
+		targets[a] = "error: " + err.Error()
+	}
+	return map[string]any{"fields": fs.fields, "targets": targets}
+}
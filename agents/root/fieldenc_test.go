@@ -0,0 +1,95 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+	"github.com/sage-x-project/sage-multi-agent/internal/fieldenc"
+	"github.com/sage-x-project/sage-multi-agent/internal/overflow"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// sealingRoot sends to an upstream that records the bodies it gets, with a
+// field encryption key for payment.
+func sealingRoot(t *testing.T) (*RootAgent, *fieldenc.Key, *[][]byte, *atomic.Int64) {
+	t.Helper()
+	raw := bytes.Repeat([]byte{9}, fieldenc.KeySize)
+	keyFile := filepath.Join(t.TempDir(), "payment.key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(raw)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PAYMENT_FIELDENC_KEY_FILE", keyFile)
+	t.Setenv("ROOT_META_MAX_BYTES", "0")
+	var bodies [][]byte
+	var calls atomic.Int64
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			return
+		}
+		calls.Add(1)
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, b)
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "a1", From: "payment", Type: "response", Content: "ok"})
+	}))
+	t.Cleanup(up.Close)
+	r := fallbackRoot(t, up.URL, "")
+	k, _ := fieldenc.NewKey(raw)
+	return r, k, &bodies, &calls
+}
+
+func shippingMessage() *types.AgentMessage {
+	return &types.AgentMessage{ID: "m1", From: "root", To: "payment", Type: "request", Content: "pay",
+		Metadata: map[string]any{"lang": "ko", "payment.shipping": strings.Repeat("서울시 강남구 ", 20)}}
+}
+
+func TestSealedFieldsSent(t *testing.T) {
+	r, k, bodies, _ := sealingRoot(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.sendExternal(ctx, "payment", opProcess, shippingMessage()); err != nil {
+		t.Fatal(err)
+	}
+	var got types.AgentMessage
+	if len(*bodies) != 1 || json.Unmarshal((*bodies)[0], &got) != nil {
+		t.Fatalf("upstream got %q", *bodies)
+	}
+	if !fieldenc.IsEnvelope(got.Metadata["payment.shipping"]) || bytes.Contains((*bodies)[0], []byte("강남구")) {
+		t.Fatalf("shipping not sealed: %s", (*bodies)[0])
+	}
+	if opened, _ := k.Open(got.Metadata); len(opened) != 1 || got.Metadata["payment.shipping"] != shippingMessage().Metadata["payment.shipping"] {
+		t.Fatalf("upstream could not open: %v", got.Metadata)
+	}
+}
+
+// A message that fits as plaintext but not once sealed is refused before it
+// is sent, like any oversized payload.
+func TestSealedFieldsRecheckLimits(t *testing.T) {
+	r, _, _, calls := sealingRoot(t)
+	msg := shippingMessage()
+	plainMeta := overflow.Size(msg.Metadata)
+	r.upLimits.note("payment", &bodylimit.Limits{MaxMetadataBytes: plainMeta + 16})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.sendExternal(ctx, "payment", opProcess, msg)
+	var pe *payloadTooLargeError
+	if !errors.As(err, &pe) || pe.Check.MetadataBytes <= plainMeta+16 {
+		t.Fatalf("err %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatal("oversized sealed message was sent")
+	}
+}
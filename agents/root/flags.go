@@ -0,0 +1,33 @@
+// Package root - runtime feature flags.
+//
+// Experimental Root behaviors are read through internal/flags so operators can
+// flip them with POST /flags/{name} (ROOT_ADMIN_TOKEN) instead of restarting.
+// ROOT_FLAGS_FILE persists toggles across restarts.
+package root
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+)
+
+var (
+	flagKEMStrict = flags.Register(flags.Spec{
+		Name: "root.hpke_kem_strict", Env: "HPKE_KEM_STRICT", Default: false, Owner: "root",
+		Description: "refuse HPKE to targets without a valid KEM ownership proof",
+	})
+	flagRuleFirst = flags.Register(flags.Spec{
+		Name: "root.rule_first", Env: "ROOT_RULE_FIRST", Default: true, Owner: "root",
+		Description: "run rule-based extraction before the LLM for short payment clarify answers",
+	})
+)
+
+func (r *RootAgent) mountFlags() {
+	if err := flags.Persist(os.Getenv("ROOT_FLAGS_FILE")); err != nil {
+		r.logger.Printf("[root][flags] state file: %v (toggles kept in memory)", err)
+	}
+	h := flags.Handler(func(req *http.Request) bool { return hasAdminToken(req) }, r.audit)
+	r.mux.Handle("/flags", h)
+	r.mux.Handle("/flags/", h)
+}
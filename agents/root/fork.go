@@ -0,0 +1,203 @@
+// Package root - conversation forking for "what if" demos.
+//
+// POST /conversations/{cid}/fork (admin) copies a conversation's payment and
+// medical contexts (slots, stage, provenance, transcript), its clarify
+// counters and its time zone into a new cid, so the same setup can be
+// finished two ways. The
+// copy never inherits anything that could replay the original's payment:
+// confirm tokens are reissued, the idempotency key is not carried (the
+// payment agent would dedupe the fork's charge against the original's), an
+// in-flight "sending" stage (or a parked
+// upstream needs-input request) falls back to await_confirm, and the
+// receipt/refund linkage stays with the original.
+// HPKE sessions and pins are per target, not per conversation, so both
+// branches share them. ROOT_FORK_MAX (default 5) caps forks per conversation.
+// GET /admin/forks lists fork records ("forked from ctx-abc at turn 7").
+package root
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+const maxForkRecords = 200
+
+var errForkLimit = errors.New("fork limit reached (ROOT_FORK_MAX)")
+
+func forkMax() int { return envInt("ROOT_FORK_MAX", 5) }
+
+type forkRecord struct {
+	CID     string    `json:"cid"`
+	Parent  string    `json:"parent"`
+	AtTurn  int       `json:"atTurn"`
+	Copied  []string  `json:"copied"`
+	Actor   string    `json:"actor,omitempty"`
+	At      time.Time `json:"at"`
+	Summary string    `json:"summary"`
+}
+
+type forkStore struct {
+	mu       sync.Mutex
+	recs     []forkRecord // oldest first
+	children map[string]int
+}
+
+// conversationTurn is the furthest domain turn reached in cid.
+func (r *RootAgent) conversationTurn(cid string) int {
+	turn := 0
+	r.payContextStore.mu.Lock()
+	if c, ok := r.payContextStore.m[cid]; ok {
+		turn = c.Turn
+	}
+	r.payContextStore.mu.Unlock()
+	if n := len(r.getMedCtx(cid).Transcript); n > turn {
+		turn = n
+	}
+	return turn
+}
+
+// forkConversation copies cid's state into a new conversation and returns its record.
+func (r *RootAgent) forkConversation(parent, actor string) (forkRecord, error) {
+	r.forkBook.mu.Lock()
+	if r.forkBook.children == nil {
+		r.forkBook.children = map[string]int{}
+	}
+	if max := forkMax(); max > 0 && r.forkBook.children[parent] >= max {
+		r.forkBook.mu.Unlock()
+		return forkRecord{}, fmt.Errorf("conversation %s already has %d forks: %w", parent, max, errForkLimit)
+	}
+	r.forkBook.children[parent]++ // reserved; released below when there is nothing to copy
+	r.forkBook.mu.Unlock()
+
+	child := parent + "-fork-" + uuid.NewString()[:8]
+	rec := forkRecord{CID: child, Parent: parent, AtTurn: r.conversationTurn(parent), Actor: actor, At: time.Now().UTC()}
+
+	r.payContextStore.mu.Lock()
+	if c, ok := r.payContextStore.m[parent]; ok {
+		cp := *c
+		cp.Slots.Prov = c.Slots.Prov.clone()
+		if cp.Stage == "sending" || cp.Stage == "await_upstream" {
+			cp.Stage = "await_confirm"
+		}
+		if cp.Token != "" {
+			cp.Token = uuid.NewString()
+		}
+		cp.IdemKey = "" // the fork's confirm is a new charge, not a retry of the parent's
+		cp.UpdatedAt = time.Now()
+		r.payContextStore.set(child, &cp)
+		rec.Copied = append(rec.Copied, fmt.Sprintf("payment(stage=%s, slots=%d)", blankOr(cp.Stage, "-"), len(payFieldValues(cp.Slots))))
+	}
+	r.payContextStore.mu.Unlock()
+
+	if v, ok := r.medStore.Load(parent); ok {
+		st := v.(medCtx)
+		st.Transcript = append([]string(nil), st.Transcript...)
+		st.Prov = st.Prov.clone()
+		r.putMedCtx(child, st)
+		rec.Copied = append(rec.Copied, fmt.Sprintf("medical(transcript=%d)", len(st.Transcript)))
+	}
+
+	r.forkBook.mu.Lock()
+	defer r.forkBook.mu.Unlock()
+	if len(rec.Copied) == 0 {
+		r.forkBook.children[parent]--
+		return forkRecord{}, fmt.Errorf("conversation %s has no state to fork", parent)
+	}
+	for _, d := range []string{"payment", "medical"} {
+		if v, ok := r.clarifyDepths.Load(clarifyKey(parent, d)); ok {
+			r.clarifyDepths.Store(clarifyKey(child, d), v)
+		}
+	}
+	if v, ok := r.convTimezones.Load(parent); ok {
+		r.convTimezones.Store(child, v)
+	}
+	rec.Summary = fmt.Sprintf("forked from %s at turn %d", parent, rec.AtTurn)
+	r.forkBook.recs = append(r.forkBook.recs, rec)
+	if len(r.forkBook.recs) > maxForkRecords {
+		r.forkBook.recs = r.forkBook.recs[len(r.forkBook.recs)-maxForkRecords:]
+	}
+	return rec, nil
+}
+
+// handleConversations serves POST /conversations/{cid}/fork,
+// GET /conversations/{cid}/ledger (ledger.go), /conversations/{cid}/reminders
+// (reminders.go) and GET /conversations/{cid} (conversation.go).
+func (r *RootAgent) handleConversations(w http.ResponseWriter, req *http.Request) {
+	rest := strings.TrimPrefix(req.URL.Path, "/conversations/")
+	parent, action, _ := strings.Cut(rest, "/")
+	if parent != "" && action == "ledger" {
+		r.handleLedger(w, req, parent)
+		return
+	}
+	if parent != "" && action == "reminders" {
+		r.handleReminders(w, req, parent)
+		return
+	}
+	if parent != "" && action == "" && !strings.HasSuffix(rest, "/") {
+		r.handleConversation(w, req, parent)
+		return
+	}
+	if parent == "" || action != "fork" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	rec, err := r.forkConversation(parent, requesterOf(req))
+	if err != nil {
+		r.audit.Emit(audit.Event{Type: "conversation", Action: "conversation.fork", Outcome: "denied", Actor: requesterOf(req), CID: parent, Detail: map[string]any{"error": err.Error()}})
+		status := http.StatusNotFound
+		if errors.Is(err, errForkLimit) {
+			status = http.StatusTooManyRequests
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "cid": parent})
+		return
+	}
+	r.runs.touch(rec.CID)
+	r.audit.Emit(audit.Event{
+		Type: "conversation", Action: "conversation.fork", Outcome: "success", Actor: rec.Actor, CID: rec.CID, Target: parent,
+		Detail: map[string]any{"atTurn": rec.AtTurn, "copied": rec.Copied},
+	})
+	r.logger.Printf("[root][fork] %s -> %s at turn %d copied=%v", parent, rec.CID, rec.AtTurn, rec.Copied)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rec)
+}
+
+// handleForks serves GET /admin/forks[?cid=] (records for one parent or child).
+func (r *RootAgent) handleForks(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	cid := strings.TrimSpace(req.URL.Query().Get("cid"))
+	r.forkBook.mu.Lock()
+	out := make([]forkRecord, 0, len(r.forkBook.recs))
+	for _, rec := range r.forkBook.recs {
+		if cid == "" || rec.CID == cid || rec.Parent == cid {
+			out = append(out, rec)
+		}
+	}
+	r.forkBook.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"forks": out, "max": forkMax()})
+}
@@ -0,0 +1,169 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// scriptedExtractor answers the payment extraction prompt with the fields
+// scripted for the first key found in the utterance; other prompts fail, so
+// Root uses its templates.
+type scriptedExtractor map[string]string
+
+func (m scriptedExtractor) Chat(_ context.Context, system, user string) (string, error) {
+	if !strings.Contains(system, `"fields":{"mode"`) {
+		return "", errors.New("not scripted")
+	}
+	for k, v := range m {
+		if strings.Contains(user, k) {
+			return v, nil
+		}
+	}
+	return `{"fields":{}}`, nil
+}
+
+// forkEnv is Root with a payment agent that records each charge's metadata
+// and fails the first failFirst of them.
+type forkEnv struct {
+	r       *RootAgent
+	charges chan map[string]any
+}
+
+func newForkEnv(t *testing.T, failFirst int64) *forkEnv {
+	t.Helper()
+	env := &forkEnv{charges: make(chan map[string]any, 8)}
+	var n atomic.Int64
+	pay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		env.charges <- in.Metadata
+		if n.Add(1) <= failFirst {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "p1", From: "payment", Type: "response", Content: "결제 완료"})
+	}))
+	t.Cleanup(pay.Close)
+
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_ADMIN_TOKEN", "fork-test")
+	t.Setenv("PAYMENT_URL", pay.URL)
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	env.r = NewRootAgent("root", 0)
+	env.r.logger = log.New(io.Discard, "", 0)
+	env.r.llmClient = scriptedExtractor{
+		"맥북":    `{"fields":{"mode":"purchase","item":"맥북"}}`,
+		"카드로":   `{"fields":{"item":"맥북","method":"card","to":"애플스토어","shipping":"서울 강남구","budgetKRW":3000000}}`,
+		"카카오페이": `{"fields":{"item":"맥북","method":"kakaopay","to":"쿠팡","shipping":"부산 해운대구","budgetKRW":2500000}}`,
+	}
+	return env
+}
+
+func (env *forkEnv) send(t *testing.T, cid, text string) types.AgentMessage {
+	t.Helper()
+	b, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: text, ContextID: cid})
+	w := httptest.NewRecorder()
+	env.r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b)))
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return out
+}
+
+func (env *forkEnv) fork(t *testing.T, cid string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/conversations/"+cid+"/fork", nil)
+	req.Header.Set("X-Admin-Token", "fork-test")
+	w := httptest.NewRecorder()
+	env.r.mux.ServeHTTP(w, req)
+	var rec forkRecord
+	if err := json.NewDecoder(w.Body).Decode(&rec); err != nil || rec.CID == "" {
+		t.Fatalf("fork: %d %v", w.Code, err)
+	}
+	return rec.CID
+}
+
+func (env *forkEnv) charge(t *testing.T) map[string]any {
+	t.Helper()
+	select {
+	case m := <-env.charges:
+		return m
+	default:
+		t.Fatal("no charge sent")
+		return nil
+	}
+}
+
+// A parent left at await_confirm after a failed send keeps its idempotency
+// key for the retry; a fork of it charges under a key of its own.
+func TestForkConfirmNewIdempotencyKey(t *testing.T) {
+	env := newForkEnv(t, 1)
+	env.send(t, "ctx-p", "맥북 사줘")
+	if out := env.send(t, "ctx-p", "카드로, 애플스토어, 서울 강남구, 300만원"); out.Metadata["await"] != "payment.confirm" {
+		t.Fatalf("preview: %+v", out)
+	}
+	env.send(t, "ctx-p", "네") // fails upstream; the stage goes back to await_confirm
+	failed := env.charge(t)["payment.idempotencyKey"]
+
+	child := env.fork(t, "ctx-p")
+	env.send(t, child, "네")
+	forked := env.charge(t)["payment.idempotencyKey"]
+	env.send(t, "ctx-p", "네")
+	retried := env.charge(t)["payment.idempotencyKey"]
+
+	if failed == nil || retried != failed {
+		t.Fatalf("parent retry key %v, first attempt %v", retried, failed)
+	}
+	if forked == nil || forked == failed {
+		t.Fatalf("fork reused the parent's key %v", forked)
+	}
+}
+
+// Forked mid-collect, both branches finish with their own answers and
+// neither sees the other's slots, confirm or charge.
+func TestForkMidCollectIndependent(t *testing.T) {
+	env := newForkEnv(t, 0)
+	env.send(t, "ctx-p", "맥북 사줘")
+	child := env.fork(t, "ctx-p")
+
+	for cid, answer := range map[string]string{"ctx-p": "카드로, 애플스토어, 서울 강남구, 300만원", child: "카카오페이, 쿠팡, 부산 해운대구, 250만원"} {
+		if out := env.send(t, cid, answer); out.Metadata["await"] != "payment.confirm" {
+			t.Fatalf("%s preview: %+v", cid, out)
+		}
+	}
+	if p, c := env.r.getPayCtx("ctx-p"), env.r.getPayCtx(child); p.Method != "card" || c.Method != "kakaopay" || p.Shipping == c.Shipping {
+		t.Fatalf("slots crossed: parent %+v fork %+v", p, c)
+	}
+
+	env.send(t, child, "네")
+	fc := env.charge(t)
+	env.send(t, "ctx-p", "네")
+	pc := env.charge(t)
+	if fc["payment.method"] != "kakaopay" || fc["payment.amountKRW"] != 2500000.0 || fc["payment.to"] != "쿠팡" {
+		t.Fatalf("fork charge: %v", fc)
+	}
+	if pc["payment.method"] != "card" || pc["payment.amountKRW"] != 3000000.0 || pc["payment.to"] != "애플스토어" {
+		t.Fatalf("parent charge: %v", pc)
+	}
+	if fc["payment.idempotencyKey"] == pc["payment.idempotencyKey"] {
+		t.Fatalf("branches share key %v", fc["payment.idempotencyKey"])
+	}
+	select {
+	case extra := <-env.charges:
+		t.Fatalf("unexpected charge %v", extra)
+	default:
+	}
+}
@@ -0,0 +1,111 @@
+// Package root - cross-domain handoff (medical -> payment).
+//
+// When an active medical conversation pivots to a purchase, compatible slots
+// are carried into the payment slots with provenance "carried-from-medical".
+// The first clarify/preview mentions them and the user can reject them. Sensitive
+// medical details never cross over: handoffDenylist keys are stripped from the
+// payment metadata sent upstream.
+package root
+
+import (
+	"strings"
+)
+
+// handoffDenylist lists medical fields that must never reach the payment domain.
+var handoffDenylist = []string{"symptoms", "condition", "topic", "audience", "age", "duration", "history", "initial_question", "last_message"}
+
+// carryFromMedical builds payment slots from the conversation's medical context.
+// Only the medication name is carried (as the item to buy).
+func (r *RootAgent) carryFromMedical(cid string, turn int) (paySlots, bool) {
+	if !r.hasMedCtx(cid) {
+		return paySlots{}, false
+	}
+	st := r.getMedCtx(cid)
+	med := strings.TrimSpace(st.Slots.Medications)
+	if med == "" {
+		return paySlots{}, false
+	}
+	s := paySlots{Item: med}
+	markProv(&s.Prov, "item", slotSource{Src: provCarried + "medical", Turn: turn})
+	return s, true
+}
+
+// carriedFields lists the slot fields whose value came from another domain.
+func carriedFields(s paySlots) []string {
+	var out []string
+	for k, v := range s.Prov {
+		if strings.HasPrefix(v.Src, provCarried) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// dropCarried clears carried values (user rejected the assumptions).
+func dropCarried(s paySlots) paySlots {
+	for _, k := range carriedFields(s) {
+		switch k {
+		case "item":
+			s.Item = ""
+		case "model":
+			s.Model = ""
+		case "to":
+			s.To = ""
+		case "recipient":
+			s.Recipient = ""
+		case "shipping":
+			s.Shipping = ""
+		}
+		delete(s.Prov, k)
+	}
+	return s
+}
+
+// keepCarried restores carried values that a rule guess overwrote on this
+// turn ("약국에서 주문해줘" is not a new item); LLM and explicit values still win.
+func keepCarried(prev, next paySlots) paySlots {
+	fields := payFieldValues(prev)
+	for _, k := range carriedFields(prev) {
+		if next.Prov[k].Src != provRule {
+			continue
+		}
+		switch k {
+		case "item":
+			next.Item = fields[k]
+		case "model":
+			next.Model = fields[k]
+		case "to":
+			next.To = fields[k]
+		case "recipient":
+			next.Recipient = fields[k]
+		case "shipping":
+			next.Shipping = fields[k]
+		}
+		next.Prov = next.Prov.clone()
+		next.Prov[k] = prev.Prov[k]
+	}
+	return next
+}
+
+// carriedNote is appended to the first clarify so the user sees the assumption.
+func carriedNote(lang string, s paySlots) string {
+	if len(carriedFields(s)) == 0 || strings.TrimSpace(s.Item) == "" {
+		return ""
+	}
+	return map[string]string{
+		"ko": "\n(상담 내용에서 상품을 '" + s.Item + "'(으)로 가져왔어요. 아니라면 '아니'라고 말해 주세요.)",
+		"en": "\n(I carried over '" + s.Item + "' from our medical conversation as the item. Say 'no' if that's wrong.)",
+	}[langOrDefault(lang)]
+}
+
+// stripHandoffDenied removes medical details from outbound payment metadata.
+func stripHandoffDenied(meta map[string]any) {
+	for k := range meta {
+		if strings.HasPrefix(k, "medical.") {
+			delete(meta, k)
+		}
+	}
+	for _, k := range handoffDenylist {
+		delete(meta, k)
+	}
+}
@@ -0,0 +1,100 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// medicalThenPay is a fork env whose conversation already discussed a
+// medication, with the extractor scripted for the purchase that follows.
+func medicalThenPay(t *testing.T, cid string) *forkEnv {
+	t.Helper()
+	env := newForkEnv(t, 0)
+	env.r.llmClient = scriptedExtractor{
+		"주문":  `{"fields":{"mode":"purchase"}}`,
+		"카드로": `{"fields":{"method":"card","to":"온누리약국","shipping":"서울 강남구","budgetKRW":10000}}`,
+	}
+	env.r.putMedCtx(cid, medCtx{
+		Slots:    medicalSlots{Medications: "타이레놀", Condition: "편두통"},
+		Symptoms: "아침마다 두통",
+	})
+	return env
+}
+
+func sendWithMeta(t *testing.T, env *forkEnv, cid, text string, meta map[string]any) types.AgentMessage {
+	t.Helper()
+	b, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: text, ContextID: cid, Metadata: meta})
+	w := httptest.NewRecorder()
+	env.r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b)))
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return out
+}
+
+// Pivoting from medical to a purchase carries the medication over as the
+// item, says so in the first clarify, and charges with it.
+func TestHandoffCarriesMedication(t *testing.T) {
+	env := medicalThenPay(t, "ctx-h")
+	out := env.send(t, "ctx-h", "약국에서 주문해줘")
+	if out.Metadata["await"] != "payment.slots" || !strings.Contains(out.Content, "타이레놀") {
+		t.Fatalf("first clarify: %+v", out)
+	}
+	if prov, _ := out.Metadata["provenance"].(map[string]any); prov["item"] != "carried-from-medical@1" {
+		t.Fatalf("item provenance %v", out.Metadata["provenance"])
+	}
+
+	if out := env.send(t, "ctx-h", "카드로, 온누리약국, 서울 강남구, 만원"); out.Metadata["await"] != "payment.confirm" {
+		t.Fatalf("preview: %+v", out)
+	}
+	env.send(t, "ctx-h", "네")
+	if item := env.charge(t)["payment.item"]; item != "타이레놀" {
+		t.Fatalf("charged item %v", item)
+	}
+}
+
+// "아니" after the carried note drops the carried item; Root asks for it again.
+func TestHandoffRejected(t *testing.T) {
+	env := medicalThenPay(t, "ctx-h")
+	env.send(t, "ctx-h", "약국에서 주문해줘")
+	out := env.send(t, "ctx-h", "아니")
+	if s := env.r.getPayCtx("ctx-h"); s.Item != "" || len(carriedFields(s)) != 0 {
+		t.Fatalf("carried slots kept after rejection: %+v", s)
+	}
+	if out.Metadata["await"] != "payment.slots" || strings.Contains(out.Content, "타이레놀") {
+		t.Fatalf("after rejection: %+v", out)
+	}
+}
+
+// Symptoms and conditions never reach the payment agent, whether they sit in
+// the medical context or arrive as caller metadata.
+func TestHandoffDenylist(t *testing.T) {
+	env := medicalThenPay(t, "ctx-h")
+	env.send(t, "ctx-h", "약국에서 주문해줘")
+	env.send(t, "ctx-h", "카드로, 온누리약국, 서울 강남구, 만원")
+	sendWithMeta(t, env, "ctx-h", "네", map[string]any{
+		"medical.condition": "편두통", "symptoms": "아침마다 두통", "condition": "편두통", "age": "34",
+	})
+	meta := env.charge(t)
+	for k, v := range meta {
+		if strings.HasPrefix(k, "medical.") {
+			t.Fatalf("medical key %s sent upstream", k)
+		}
+		if s, _ := v.(string); strings.Contains(s, "두통") {
+			t.Fatalf("symptom leaked in %s=%q", k, s)
+		}
+	}
+	for _, k := range handoffDenylist {
+		if _, ok := meta[k]; ok {
+			t.Fatalf("denylisted %s sent upstream", k)
+		}
+	}
+	if meta["payment.item"] != "타이레놀" {
+		t.Fatalf("carried item lost: %v", meta["payment.item"])
+	}
+}
@@ -0,0 +1,332 @@
+// Package root - upstream health history for dashboard sparklines.
+//
+// Root probes GET /status on every configured upstream every
+// ROOT_HEALTH_PROBE_MS (default 10000, 0 = off); agents that also have a
+// direct fallback are covered by the upstream prober (fallback.go), which
+// records into the same history, for both routes. Targets are picked up on
+// every tick, so one added at runtime (SetExternalURL/SetDirectURL) starts
+// accumulating on the next one.
+//
+// Per target Root keeps the last ROOT_HEALTH_HISTORY_PROBES (default 120)
+// probe results and per-minute aggregates (count, errors, latency sum/max)
+// for the last ROOT_HEALTH_HISTORY_MINUTES (default 60). Both are fixed-size
+// rings updated when a probe is recorded, so queries only copy, and at most
+// ROOT_HEALTH_HISTORY_TARGETS (default 16) targets are tracked: memory is
+// bounded by targets × (probes + minutes).
+//
+// GET /health/history?target=payment&window=15m returns the probes and
+// minutes inside the window (all targets when target is omitted), uncached;
+// /status/live carries a compact form under "healthHistory".
+package root
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+)
+
+// healthPoint is one probe result.
+type healthPoint struct {
+	At        time.Time `json:"t"`
+	Up        bool      `json:"up"`
+	LatencyMs int64     `json:"ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// healthMinute aggregates the probes of one wall-clock minute.
+type healthMinute struct {
+	Minute int64 // unix minute; 0 = empty slot
+	Count  int64
+	Errors int64
+	SumMs  int64
+	MaxMs  int64
+}
+
+// minuteView is a healthMinute as served.
+type minuteView struct {
+	At        time.Time `json:"t"`
+	Count     int64     `json:"count"`
+	Errors    int64     `json:"errors"`
+	ErrorRate float64   `json:"errorRate"`
+	AvgMs     int64     `json:"avgMs"`
+	MaxMs     int64     `json:"maxMs"`
+}
+
+// targetHistory holds the two rings of one target.
+type targetHistory struct {
+	url     string
+	points  []healthPoint // ring, len == cap once full
+	next    int
+	minutes []healthMinute // indexed by unix minute % len
+}
+
+type healthHistory struct {
+	mu         sync.Mutex
+	targets    map[string]*targetHistory
+	maxPoints  int
+	maxMinutes int
+	maxTargets int
+	dropped    int64 // records for targets beyond maxTargets
+	stop       chan struct{}
+}
+
+func newHealthHistory() *healthHistory {
+	return &healthHistory{
+		targets:    map[string]*targetHistory{},
+		maxPoints:  max(1, envInt("ROOT_HEALTH_HISTORY_PROBES", 120)),
+		maxMinutes: max(1, envInt("ROOT_HEALTH_HISTORY_MINUTES", 60)),
+		maxTargets: max(1, envInt("ROOT_HEALTH_HISTORY_TARGETS", 16)),
+	}
+}
+
+// record stores one probe result and folds it into its minute.
+func (hh *healthHistory) record(target, url string, at time.Time, up bool, latency time.Duration, err error) {
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	th := hh.targets[target]
+	if th == nil {
+		if len(hh.targets) >= hh.maxTargets {
+			hh.dropped++
+			return
+		}
+		th = &targetHistory{points: make([]healthPoint, 0, hh.maxPoints), minutes: make([]healthMinute, hh.maxMinutes)}
+		hh.targets[target] = th
+	}
+	th.url = url
+
+	p := healthPoint{At: at.UTC(), Up: up, LatencyMs: latency.Milliseconds()}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if len(th.points) < hh.maxPoints {
+		th.points = append(th.points, p)
+	} else {
+		th.points[th.next] = p
+		th.next = (th.next + 1) % hh.maxPoints
+	}
+
+	minute := at.Unix() / 60
+	m := &th.minutes[int(minute%int64(len(th.minutes)))]
+	if m.Minute != minute {
+		*m = healthMinute{Minute: minute}
+	}
+	m.Count++
+	m.SumMs += p.LatencyMs
+	if p.LatencyMs > m.MaxMs {
+		m.MaxMs = p.LatencyMs
+	}
+	if !up {
+		m.Errors++
+	}
+}
+
+// ordered returns th's probes oldest first.
+func (th *targetHistory) ordered() []healthPoint {
+	out := make([]healthPoint, 0, len(th.points))
+	out = append(out, th.points[th.next:]...)
+	return append(out, th.points[:th.next]...)
+}
+
+// minuteViews returns the minutes since `since`, oldest first; minutes
+// without probes are omitted.
+func (th *targetHistory) minuteViews(since time.Time) []minuteView {
+	from := since.Unix() / 60
+	var out []minuteView
+	for _, m := range th.minutes {
+		if m.Minute == 0 || m.Minute < from {
+			continue
+		}
+		v := minuteView{At: time.Unix(m.Minute*60, 0).UTC(), Count: m.Count, Errors: m.Errors, MaxMs: m.MaxMs}
+		if m.Count > 0 {
+			v.ErrorRate = float64(m.Errors) / float64(m.Count)
+			v.AvgMs = m.SumMs / m.Count
+		}
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// view is the /health/history answer for one target.
+func (hh *healthHistory) view(target string, now time.Time, window time.Duration) (map[string]any, bool) {
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	th := hh.targets[target]
+	if th == nil {
+		return nil, false
+	}
+	since := now.Add(-window)
+	var pts []healthPoint
+	up := 0
+	for _, p := range th.ordered() {
+		if p.At.Before(since) {
+			continue
+		}
+		pts = append(pts, p)
+		if p.Up {
+			up++
+		}
+	}
+	out := map[string]any{"target": target, "url": th.url, "probes": pts, "minutes": th.minuteViews(since)}
+	if len(pts) > 0 {
+		out["uptime"] = float64(up) / float64(len(pts))
+	}
+	return out, true
+}
+
+// names lists the tracked targets.
+func (hh *healthHistory) names() []string {
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	out := make([]string, 0, len(hh.targets))
+	for t := range hh.targets {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// compact is the /status form: the last probes as a "+-" string and the last
+// 15 minutes' average latency and error counts.
+func (hh *healthHistory) compact(now time.Time) map[string]any {
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	targets := map[string]any{}
+	for name, th := range hh.targets {
+		var sb strings.Builder
+		pts := th.ordered()
+		if len(pts) > 30 {
+			pts = pts[len(pts)-30:]
+		}
+		for _, p := range pts {
+			if p.Up {
+				sb.WriteByte('+')
+			} else {
+				sb.WriteByte('-')
+			}
+		}
+		mv := th.minuteViews(now.Add(-15 * time.Minute))
+		avg := make([]int64, len(mv))
+		errs := make([]int64, len(mv))
+		for i, m := range mv {
+			avg[i], errs[i] = m.AvgMs, m.Errors
+		}
+		targets[name] = map[string]any{"probes": sb.String(), "avgMs": avg, "errors": errs}
+	}
+	return map[string]any{
+		"retention": map[string]any{"probes": hh.maxPoints, "minutes": hh.maxMinutes, "targets": hh.maxTargets},
+		"dropped":   hh.dropped,
+		"targets":   targets,
+	}
+}
+
+// handleHealthHistory serves GET /health/history?target=&window=.
+func (r *RootAgent) handleHealthHistory(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	window := 15 * time.Minute
+	if v := strings.TrimSpace(req.URL.Query().Get("window")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "bad window (e.g. 15m)", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	if limit := time.Duration(r.health.maxMinutes) * time.Minute; window > limit {
+		window = limit
+	}
+	now := time.Now()
+	targets := r.health.names()
+	if t := strings.ToLower(strings.TrimSpace(req.URL.Query().Get("target"))); t != "" {
+		targets = []string{t}
+	}
+	out := []map[string]any{}
+	for _, t := range targets {
+		if v, ok := r.health.view(t, now, window); ok {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 && req.URL.Query().Get("target") != "" {
+		http.Error(w, fmt.Sprintf("no history for target %q", targets[0]), http.StatusNotFound)
+		return
+	}
+	httpcache.WriteLive(w, map[string]any{"window": window.String(), "targets": out, "time": now.UTC().Format(time.RFC3339)})
+}
+
+// startHealthProbe runs the probe loop (see the file comment).
+func (r *RootAgent) startHealthProbe() {
+	iv := envInt("ROOT_HEALTH_PROBE_MS", 10000)
+	if iv <= 0 {
+		return
+	}
+	hh := r.health
+	hh.mu.Lock()
+	if hh.stop != nil {
+		hh.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	hh.stop = stop
+	hh.mu.Unlock()
+
+	if err := r.Background(r.probeHealth); err != nil {
+		r.logger.Printf("[root][health] first probe not scheduled: %v", err)
+	}
+	r.bg.Every(time.Duration(iv)*time.Millisecond, stop, r.probeHealth)
+}
+
+func (r *RootAgent) stopHealthProbe() {
+	hh := r.health
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	if hh.stop != nil {
+		close(hh.stop)
+		hh.stop = nil
+	}
+}
+
+// probeHealth probes every configured upstream not covered by the upstream
+// prober.
+func (r *RootAgent) probeHealth(ctx context.Context) {
+	covered := map[string]bool{}
+	if r.upstreams.probing() {
+		for _, a := range r.directTargets() {
+			covered[a] = true
+		}
+	}
+	for _, agent := range r.knownTargets() {
+		base := r.externalURLFor(agent)
+		if base == "" || covered[agent] {
+			continue
+		}
+		r.probeOnce(ctx, agent, agent, base)
+	}
+}
+
+// probeOnce probes base as agent and records the result under key. A
+// component answering as someone else counts as down.
+func (r *RootAgent) probeOnce(ctx context.Context, agent, key, base string) error {
+	pctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	id, err := selfid.Probe(pctx, base)
+	if err == nil && !id.Is(agent) {
+		err = fmt.Errorf("%w: answers as %s", selfid.ErrMismatch, id)
+	}
+	if err == nil {
+		r.upLimits.note(key, id.Limits)
+	}
+	r.routeHints.note(agent, key, id.RoutingHint, err == nil)
+	r.health.record(key, base, start, err == nil, time.Since(start), err)
+	return err
+}
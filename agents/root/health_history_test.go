@@ -0,0 +1,125 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func smallHistory(t *testing.T) *healthHistory {
+	t.Helper()
+	t.Setenv("ROOT_HEALTH_HISTORY_PROBES", "5")
+	t.Setenv("ROOT_HEALTH_HISTORY_MINUTES", "3")
+	t.Setenv("ROOT_HEALTH_HISTORY_TARGETS", "2")
+	return newHealthHistory()
+}
+
+// Probes and minutes are rings: old entries are overwritten, not kept.
+func TestHealthHistoryRetention(t *testing.T) {
+	hh := smallHistory(t)
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ { // two probes a minute for six minutes
+		hh.record("payment", "http://pay", t0.Add(time.Duration(i)*30*time.Second), true, time.Duration(i)*time.Millisecond, nil)
+	}
+	th := hh.targets["payment"]
+	pts := th.ordered()
+	if len(pts) != 5 || cap(th.points) != 5 || pts[0].LatencyMs != 7 || pts[4].LatencyMs != 11 {
+		t.Fatalf("probes: %+v", pts)
+	}
+	mv := th.minuteViews(time.Time{})
+	if len(mv) != 3 || !mv[0].At.Equal(t0.Add(3*time.Minute)) || !mv[2].At.Equal(t0.Add(5*time.Minute)) {
+		t.Fatalf("minutes: %+v", mv)
+	}
+
+	// beyond the target cap, records are counted and dropped
+	hh.record("medical", "http://med", t0, true, 0, nil)
+	hh.record("planning", "http://plan", t0, true, 0, nil)
+	if len(hh.targets) != 2 || hh.dropped != 1 {
+		t.Fatalf("targets %v dropped %d", hh.names(), hh.dropped)
+	}
+}
+
+// Aggregates are folded at write time and match the synthetic sequence.
+func TestHealthHistoryAggregates(t *testing.T) {
+	hh := smallHistory(t)
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	seq := []struct {
+		off time.Duration
+		up  bool
+		ms  int
+	}{{0, true, 10}, {10 * time.Second, false, 50}, {20 * time.Second, true, 30}, {70 * time.Second, false, 5}}
+	for _, p := range seq {
+		var err error
+		if !p.up {
+			err = errors.New("down")
+		}
+		hh.record("payment", "http://pay", t0.Add(p.off), p.up, time.Duration(p.ms)*time.Millisecond, err)
+	}
+	v, ok := hh.view("payment", t0.Add(2*time.Minute), 3*time.Minute)
+	if !ok {
+		t.Fatal("no view")
+	}
+	mv := v["minutes"].([]minuteView)
+	want := []minuteView{
+		{At: t0, Count: 3, Errors: 1, ErrorRate: 1.0 / 3, AvgMs: 30, MaxMs: 50},
+		{At: t0.Add(time.Minute), Count: 1, Errors: 1, ErrorRate: 1, AvgMs: 5, MaxMs: 5},
+	}
+	if len(mv) != len(want) {
+		t.Fatalf("minutes: %+v", mv)
+	}
+	for i := range want {
+		if mv[i] != want[i] {
+			t.Fatalf("minute %d: %+v, want %+v", i, mv[i], want[i])
+		}
+	}
+	if v["uptime"] != 0.5 || len(v["probes"].([]healthPoint)) != 4 {
+		t.Fatalf("view: %v", v)
+	}
+	c := hh.compact(t0.Add(2 * time.Minute))["targets"].(map[string]any)["payment"].(map[string]any)
+	if c["probes"] != "+-+-" {
+		t.Fatalf("compact: %v", c)
+	}
+}
+
+// A target configured after start is probed, and served, from the next tick.
+func TestHealthHistoryRuntimeTarget(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": "payment", "type": "payment"})
+	}))
+	defer up.Close()
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	t.Setenv("MEDICAL_DIRECT_URL", "")
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+	for _, a := range r.knownTargets() {
+		r.SetExternalURL(a, "")
+	}
+
+	r.probeHealth(context.Background())
+	if n := r.health.names(); len(n) != 0 {
+		t.Fatalf("history before configuring: %v", n)
+	}
+	r.SetExternalURL("payment", up.URL)
+	r.probeHealth(context.Background())
+
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/history?target=payment&window=5m", nil))
+	var out struct {
+		Targets []struct {
+			Target string        `json:"target"`
+			Probes []healthPoint `json:"probes"`
+		} `json:"targets"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" || len(out.Targets) != 1 || len(out.Targets[0].Probes) != 1 || !out.Targets[0].Probes[0].Up {
+		t.Fatalf("history: %d %v %+v", w.Code, w.Header(), out)
+	}
+}
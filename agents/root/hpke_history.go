@@ -0,0 +1,148 @@
+// Package root - HPKE enable/disable history per target.
+//
+// Disabling HPKE downgrades the target to plaintext, so it is recorded (who,
+// when, previous kid) instead of silently dropping the session. Targets listed
+// in ROOT_HPKE_REQUIRED (comma-separated) refuse a disable unless forced;
+// forcing (POST /hpke/config force=true) needs the admin token. Every change
+// is also published as a WebSocket status message ("hpke.change") when a log
+// broadcaster is attached (SetLogBroadcaster).
+package root
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/websocket"
+)
+
+const (
+	ctxRequesterKey ctxKey = "requester"
+
+	maxHPKEHistory = 20
+)
+
+var errHPKERequired = errors.New("HPKE is required for this target; pass force=true to disable")
+
+type hpkeChange struct {
+	Action    string    `json:"action"` // "enable" | "disable"
+	At        time.Time `json:"at"`
+	Requester string    `json:"requester"`
+	Kid       string    `json:"kid,omitempty"`     // kid after the change (enable)
+	PrevKid   string    `json:"prevKid,omitempty"` // kid dropped (disable) / last kid before re-enable
+	Forced    bool      `json:"forced,omitempty"`
+	Resumed   string    `json:"resumed,omitempty"` // enable: "new_handshake"
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type hpkeHistory struct {
+	mu sync.Mutex
+	m  map[string][]hpkeChange
+}
+
+func newHPKEHistory() *hpkeHistory { return &hpkeHistory{m: map[string][]hpkeChange{}} }
+
+func (h *hpkeHistory) add(target string, c hpkeChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := append(h.m[target], c)
+	if len(l) > maxHPKEHistory {
+		l = l[len(l)-maxHPKEHistory:]
+	}
+	h.m[target] = l
+}
+
+func (h *hpkeHistory) list(target string) []hpkeChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]hpkeChange{}, h.m[target]...)
+}
+
+func (h *hpkeHistory) last(target string) (hpkeChange, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.m[target]
+	if len(l) == 0 {
+		return hpkeChange{}, false
+	}
+	return l[len(l)-1], true
+}
+
+// hpkeChangeEvent is the WebSocket status payload for one HPKE change.
+type hpkeChangeEvent struct {
+	Event  string     `json:"event"` // "hpke.change"
+	Target string     `json:"target"`
+	Change hpkeChange `json:"change"`
+}
+
+// SetLogBroadcaster attaches the WebSocket log feed HPKE changes are
+// published to. Call before Start.
+func (r *RootAgent) SetLogBroadcaster(b websocket.LogBroadcaster) { r.logs = b }
+
+// addHPKEChange records c in the history and publishes it.
+func (r *RootAgent) addHPKEChange(target string, c hpkeChange) {
+	r.hpkeHist.add(target, c)
+	if r.logs != nil {
+		r.logs.BroadcastStatus(hpkeChangeEvent{Event: "hpke.change", Target: target, Change: c})
+	}
+}
+
+// hpkeRequired reports whether target is listed in ROOT_HPKE_REQUIRED.
+func hpkeRequired(target string) bool {
+	for _, t := range strings.Split(os.Getenv("ROOT_HPKE_REQUIRED"), ",") {
+		if strings.EqualFold(strings.TrimSpace(t), target) {
+			return true
+		}
+	}
+	return false
+}
+
+func requesterFrom(ctx context.Context) string {
+	if s, ok := ctx.Value(ctxRequesterKey).(string); ok && s != "" {
+		return s
+	}
+	return "system"
+}
+
+// disableHPKEBy drops the target's session and records who did it.
+func (r *RootAgent) disableHPKEBy(target, requester, reason string, force bool) error {
+	target = strings.ToLower(strings.TrimSpace(target))
+	if hpkeRequired(target) && !force {
+		r.logger.Printf("[root][hpke] refused disable target=%s requester=%s (required)", target, requester)
+		r.audit.Emit(audit.Event{
+			Type: "hpke", Action: "disable", Outcome: "denied", Actor: requester, Target: target,
+			Detail: map[string]any{"reason": "required"},
+		})
+		return errHPKERequired
+	}
+	prev := r.CurrentHPKEKID(target)
+	r.hpkeStates.Delete(target)
+	r.hpkeStates.Delete(directKey(target)) // the fallback route's session goes with it
+	r.addHPKEChange(target, hpkeChange{
+		Action: "disable", At: time.Now().UTC(), Requester: requester,
+		PrevKid: prev, Forced: force && hpkeRequired(target), Reason: reason,
+	})
+	r.logger.Printf("[root][hpke] disabled target=%s prevKid=%s requester=%s reason=%s", target, prev, requester, reason)
+	r.audit.Emit(audit.Event{
+		Type: "hpke", Action: "disable", Outcome: "success", Actor: requester, Target: target,
+		Detail: map[string]any{"prevKid": prev, "forced": force, "reason": reason},
+	})
+	return nil
+}
+
+// recordHPKEEnable notes a successful (re-)enable.
+func (r *RootAgent) recordHPKEEnable(ctx context.Context, target, kid string) {
+	c := hpkeChange{Action: "enable", At: time.Now().UTC(), Requester: requesterFrom(ctx), Kid: kid, Resumed: "new_handshake"}
+	if last, ok := r.hpkeHist.last(target); ok && last.Action == "disable" {
+		c.PrevKid = last.PrevKid
+	}
+	r.addHPKEChange(target, c)
+	r.audit.Emit(audit.Event{
+		Type: "hpke", Action: "enable", Outcome: "success", Actor: c.Requester, Target: target,
+		Detail: map[string]any{"kid": kid, "prevKid": c.PrevKid, "resumed": c.Resumed},
+	})
+}
@@ -0,0 +1,114 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/websocket"
+)
+
+// Each HPKE change is published to the attached log feed as "hpke.change".
+func TestHPKEChangeBroadcast(t *testing.T) {
+	r := statusRoot(t)
+	logs := websocket.NewInProcLogServer()
+	defer logs.Stop()
+	r.SetLogBroadcaster(logs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed := logs.Subscribe(ctx)
+
+	r.hpkeStates.Store("payment", &hpkeState{kid: "kid-1"})
+	b, _ := json.Marshal(map[string]any{"enabled": false, "target": "payment", "reason": "maintenance"})
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hpke/config", bytes.NewReader(b)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable: %d %s", w.Code, w.Body)
+	}
+	r.recordHPKEEnable(context.Background(), "payment", "kid-2")
+
+	evs, err := websocket.Collect(feed, 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hpkeChange{{Action: "disable", PrevKid: "kid-1", Reason: "maintenance"}, {Action: "enable", Kid: "kid-2", PrevKid: "kid-1"}}
+	for i, ev := range evs {
+		got, ok := ev.Status.(hpkeChangeEvent)
+		if !ok || got.Event != "hpke.change" || got.Target != "payment" {
+			t.Fatalf("event %d: %#v", i, ev.Status)
+		}
+		c := got.Change
+		if c.Action != want[i].Action || c.Kid != want[i].Kid || c.PrevKid != want[i].PrevKid || c.Reason != want[i].Reason {
+			t.Fatalf("change %d: %+v, want %+v", i, c, want[i])
+		}
+	}
+}
+
+// A target in ROOT_HPKE_REQUIRED keeps its session unless an admin forces the
+// disable; the forced change is recorded as such.
+func TestHPKEDisableRequired(t *testing.T) {
+	t.Setenv("ROOT_HPKE_REQUIRED", "medical, payment")
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	r := statusRoot(t)
+	r.hpkeStates.Store("payment", &hpkeState{kid: "kid-1"})
+	post := func(body map[string]any, admin bool) *httptest.ResponseRecorder {
+		if admin {
+			return adminCall(r, http.MethodPost, "/hpke/config", body)
+		}
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hpke/config", bytes.NewReader(b)))
+		return w
+	}
+
+	w := post(map[string]any{"enabled": false, "target": "payment"}, true)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"hpke_required"`) || !r.IsHPKEEnabled("payment") {
+		t.Fatalf("unforced disable: %d %s", w.Code, w.Body)
+	}
+	w = post(map[string]any{"enabled": false, "target": "payment", "force": true}, false)
+	if w.Code != http.StatusForbidden || !r.IsHPKEEnabled("payment") {
+		t.Fatalf("force without admin: %d %s", w.Code, w.Body)
+	}
+	if h := r.hpkeHist.list("payment"); len(h) != 0 {
+		t.Fatalf("refused disables recorded: %+v", h)
+	}
+
+	w = post(map[string]any{"enabled": false, "target": "payment", "force": true, "reason": "incident"}, true)
+	if w.Code != http.StatusOK || r.IsHPKEEnabled("payment") {
+		t.Fatalf("forced disable: %d %s", w.Code, w.Body)
+	}
+	c, _ := r.hpkeHist.last("payment")
+	if c.Action != "disable" || !c.Forced || c.PrevKid != "kid-1" || c.Reason != "incident" || !strings.HasPrefix(c.Requester, "admin@") {
+		t.Fatalf("recorded %+v", c)
+	}
+
+	// not required: no force needed, and force is not recorded
+	if err := r.disableHPKEBy("planning", "test", "", true); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := r.hpkeHist.last("planning"); c.Forced {
+		t.Fatalf("optional target recorded as forced: %+v", c)
+	}
+}
+
+// The history keeps the latest maxHPKEHistory changes per target.
+func TestHPKEHistoryBounded(t *testing.T) {
+	h := newHPKEHistory()
+	for i := 0; i < maxHPKEHistory+5; i++ {
+		h.add("payment", hpkeChange{Action: "enable", Kid: fmt.Sprintf("kid-%d", i)})
+	}
+	h.add("medical", hpkeChange{Action: "disable"})
+	l := h.list("payment")
+	if len(l) != maxHPKEHistory || l[0].Kid != "kid-5" || l[len(l)-1].Kid != fmt.Sprintf("kid-%d", maxHPKEHistory+4) {
+		t.Fatalf("history: %d entries, first %s", len(l), l[0].Kid)
+	}
+	if len(h.list("medical")) != 1 {
+		t.Fatal("targets share a history")
+	}
+}
@@ -0,0 +1,232 @@
+// Package root - HPKE server identity pinning.
+//
+// The first successful handshake for a target pins the server DID and the
+// fingerprint of the X25519 KEM key the resolver returns for it (the key the
+// handshake encrypts to). Later handshakes must resolve to the same identity;
+// a mismatch, or a key that cannot be resolved, is refused until an explicit
+// POST /hpke/repin (admin). Pins are kept in memory and, when ROOT_HPKE_PINS_FILE is
+// set, persisted there (internal/statefile: atomic, checksummed, with
+// backups) so restarts keep them.
+package root
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/statefile"
+
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+type hpkePin struct {
+	Target         string    `json:"target"`
+	ServerDID      string    `json:"serverDid"`
+	KEMFingerprint string    `json:"kemFingerprint,omitempty"`
+	PinnedAt       time.Time `json:"pinnedAt"`
+}
+
+type pinStore struct {
+	mu   sync.Mutex
+	path string
+	m    map[string]hpkePin
+}
+
+func newPinStore(path string) *pinStore {
+	ps := &pinStore{path: strings.TrimSpace(path), m: map[string]hpkePin{}}
+	if ps.path != "" {
+		var rows []hpkePin
+		if _, err := statefile.LoadJSON(ps.path, &rows, statefile.Options{}); err != nil {
+			log.Printf("[root][hpke] pins file: %v (starting without pins)", err)
+		}
+		for _, p := range rows {
+			ps.m[p.Target] = p
+		}
+	}
+	return ps
+}
+
+func (ps *pinStore) get(target string) (hpkePin, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.m[target]
+	return p, ok
+}
+
+func (ps *pinStore) set(p hpkePin) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.m[p.Target] = p
+	ps.saveLocked()
+}
+
+func (ps *pinStore) clear(target string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.m, target)
+	ps.saveLocked()
+}
+
+func (ps *pinStore) list() []hpkePin {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	out := make([]hpkePin, 0, len(ps.m))
+	for _, p := range ps.m {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+	return out
+}
+
+func (ps *pinStore) saveLocked() {
+	if ps.path == "" {
+		return
+	}
+	rows := make([]hpkePin, 0, len(ps.m))
+	for _, p := range ps.m {
+		rows = append(rows, p)
+	}
+	if err := statefile.SaveJSON(ps.path, rows, statefile.Options{}); err != nil {
+		log.Printf("[root][hpke] pins file: %v", err)
+	}
+}
+
+// kemPublicRow is one entry of the public KEM key file written by tools/keygen.
+type kemPublicRow struct {
+	Name         string `json:"name"`
+	DID          string `json:"did"`
+	Address      string `json:"address,omitempty"`
+	X25519Public string `json:"x25519Public"`
+}
+
+// kemPublicPath: HPKE_KEM_PUBLIC_FILE or keys/kem/kem_all_keys.json.
+func kemPublicPath() string {
+	return firstNonEmpty(strings.TrimSpace(os.Getenv("HPKE_KEM_PUBLIC_FILE")), "keys/kem/kem_all_keys.json")
+}
+
+// loadKEMPublicRows accepts {"agents":[...]} or a top-level array.
+func loadKEMPublicRows(path string) ([]kemPublicRow, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var arr []kemPublicRow
+	if err := json.Unmarshal(b, &arr); err == nil {
+		return arr, nil
+	}
+	var w struct {
+		Agents []kemPublicRow `json:"agents"`
+	}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return nil, fmt.Errorf("kem json not recognized: %s", path)
+	}
+	return w.Agents, nil
+}
+
+// kemPublicFor returns the public KEM row for serverDID (or alias).
+func kemPublicFor(alias, serverDID string) (kemPublicRow, bool) {
+	rows, err := loadKEMPublicRows(kemPublicPath())
+	if err != nil {
+		return kemPublicRow{}, false
+	}
+	for _, r := range rows {
+		if (serverDID != "" && strings.EqualFold(strings.TrimSpace(r.DID), serverDID)) || r.Name == alias {
+			return r, true
+		}
+	}
+	return kemPublicRow{}, false
+}
+
+// kemKeyResolver is the resolver side of the HPKE client's key lookup.
+type kemKeyResolver interface {
+	ResolveKEMKey(ctx context.Context, did sagedid.AgentDID) (interface{}, error)
+}
+
+// resolveKEMPublic returns the hex X25519 key the resolver publishes for
+// serverDID: the key hpke.Client encrypts the handshake to.
+func (r *RootAgent) resolveKEMPublic(ctx context.Context, serverDID string) (string, error) {
+	kr, ok := r.resolver.(kemKeyResolver)
+	if !ok {
+		return "", fmt.Errorf("HPKE: resolver cannot resolve KEM keys")
+	}
+	k, err := kr.ResolveKEMKey(ctx, sagedid.AgentDID(serverDID))
+	if err != nil {
+		return "", fmt.Errorf("HPKE: resolve KEM key for %s: %w", serverDID, err)
+	}
+	var raw []byte
+	switch v := k.(type) {
+	case interface{ Bytes() []byte }: // *ecdh.PublicKey
+		raw = v.Bytes()
+	case []byte:
+		raw = v
+	}
+	if len(raw) == 0 {
+		return "", fmt.Errorf("HPKE: unsupported KEM key %T for %s", k, serverDID)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func kemFingerprint(pubHex string) string {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(pubHex), "0x"))
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// checkPin verifies serverDID/fingerprint against the target's pin. A pinned
+// target needs a fingerprint on both sides: a missing one is a mismatch (pins
+// made without one must be re-pinned).
+func (r *RootAgent) checkPin(target, serverDID, fp string) error {
+	p, ok := r.pins.get(target)
+	if !ok {
+		return nil
+	}
+	if p.ServerDID != serverDID || fp == "" || p.KEMFingerprint != fp {
+		r.logger.Printf("[root][alert][hpke] ⚠️ PIN MISMATCH target=%s pinned={did:%s kem:%s} resolved={did:%s kem:%s}; refusing handshake (POST /hpke/repin to accept)",
+			target, p.ServerDID, p.KEMFingerprint, serverDID, fp)
+		r.audit.Emit(audit.Event{
+			Type: "hpke", Action: "pin.mismatch", Outcome: "denied", Target: target,
+			Detail: map[string]any{
+				"pinnedDid": p.ServerDID, "pinnedKem": p.KEMFingerprint,
+				"resolvedDid": serverDID, "resolvedKem": fp,
+			},
+		})
+		r.raiseAlert("hpke.pin_mismatch", alert.SeverityCritical, target, "", "HPKE server identity does not match pin", map[string]any{
+			"pinnedDid": p.ServerDID, "resolvedDid": serverDID, "pinnedKem": p.KEMFingerprint, "resolvedKem": fp,
+		})
+		return fmt.Errorf("HPKE: server identity for %q does not match pin (pinned %s, resolved %s)", target, p.ServerDID, serverDID)
+	}
+	return nil
+}
+
+// pinIfFirst records the pin after the first successful handshake.
+func (r *RootAgent) pinIfFirst(target, serverDID, fp string) {
+	if _, ok := r.pins.get(target); ok {
+		return
+	}
+	r.pins.set(hpkePin{Target: target, ServerDID: serverDID, KEMFingerprint: fp, PinnedAt: time.Now().UTC()})
+	r.audit.Emit(audit.Event{
+		Type: "hpke", Action: "pin.set", Outcome: "success", Target: target,
+		Detail: map[string]any{"did": serverDID, "kem": fp},
+	})
+}
+
+// pinFor returns the target's pin or nil (for status JSON).
+func (r *RootAgent) pinFor(target string) any {
+	if p, ok := r.pins.get(target); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package root
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+// kemResolver publishes one X25519 key per DID; the rest of the resolver is
+// never reached by these tests.
+type kemResolver struct {
+	sagedid.Resolver
+	keys map[string][]byte
+}
+
+func (k kemResolver) ResolveKEMKey(_ context.Context, did sagedid.AgentDID) (interface{}, error) {
+	if b, ok := k.keys[string(did)]; ok {
+		return b, nil
+	}
+	return nil, errors.New("not registered")
+}
+
+// testKey is an ed25519 signer standing in for Root's JWK.
+type testKey struct {
+	sagecrypto.KeyPair
+	priv ed25519.PrivateKey
+}
+
+func newTestKey() testKey {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	return testKey{priv: priv}
+}
+
+func (k testKey) ID() string                    { return "test" }
+func (k testKey) PublicKey() crypto.PublicKey   { return k.priv.Public() }
+func (k testKey) PrivateKey() crypto.PrivateKey { return k.priv }
+func (k testKey) Sign(m []byte) ([]byte, error) { return ed25519.Sign(k.priv, m), nil }
+
+func writeKeys(t *testing.T, path, paymentDID string) {
+	t.Helper()
+	body := `[{"name":"root","did":"did:sage:test:root"},{"name":"payment","did":"` + paymentDID + `"}]`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pinnedRoot is a Root whose payment pin is did:sage:test:pay-a with key A;
+// the resolver also knows pay-b (key B).
+func pinnedRoot(t *testing.T) (*RootAgent, *kemResolver) {
+	t.Helper()
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	t.Setenv("ROOT_HPKE_PINS_FILE", "")
+	t.Setenv("HPKE_KEM_PROOFS_FILE", filepath.Join(t.TempDir(), "none.json"))
+	t.Setenv("PAYMENT_URL", "http://127.0.0.1:1")
+	r := statusRoot(t)
+	res := &kemResolver{keys: map[string][]byte{
+		"did:sage:test:pay-a": bytesOf(0xA1),
+		"did:sage:test:pay-b": bytesOf(0xB2),
+	}}
+	r.resolver = res
+	r.myKey, r.myDID = newTestKey(), "did:sage:test:root"
+	r.pinIfFirst("payment", "did:sage:test:pay-a", kemFingerprint(hex.EncodeToString(res.keys["did:sage:test:pay-a"])))
+	return r, res
+}
+
+func bytesOf(b byte) []byte {
+	out := make([]byte, 32)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
+
+// A keys file that names a different payment DID, or a registry that now
+// publishes a different KEM key, changes the fingerprint; the handshake is
+// refused before it starts and the pin stays.
+func TestHPKEPinMismatchRefused(t *testing.T) {
+	r, res := pinnedRoot(t)
+	pinned, _ := r.pins.get("payment")
+	dir := t.TempDir()
+	same, swapped := filepath.Join(dir, "same.json"), filepath.Join(dir, "swapped.json")
+	writeKeys(t, same, "did:sage:test:pay-a")
+	writeKeys(t, swapped, "did:sage:test:pay-b")
+
+	enable := func(keysFile string) string {
+		t.Helper()
+		w := adminCall(r, http.MethodPost, "/hpke/config", map[string]any{"enabled": true, "target": "payment", "keysFile": keysFile})
+		if w.Code == http.StatusOK {
+			t.Fatalf("enable succeeded: %s", w.Body)
+		}
+		return w.Body.String()
+	}
+
+	if body := enable(swapped); !strings.Contains(body, "does not match pin") {
+		t.Fatalf("swapped keys file: %s", body)
+	}
+	if err := r.checkPin("payment", "did:sage:test:pay-a", kemFingerprint(hex.EncodeToString(bytesOf(0xA1)))); err != nil {
+		t.Fatalf("pinned identity refused: %v", err)
+	}
+
+	res.keys["did:sage:test:pay-a"] = bytesOf(0xC3) // same DID, new KEM key
+	if body := enable(same); !strings.Contains(body, "does not match pin") {
+		t.Fatalf("rotated KEM key: %s", body)
+	}
+	if got, _ := r.pins.get("payment"); got != pinned || r.IsHPKEEnabled("payment") {
+		t.Fatalf("pin %+v, session %v", got, r.IsHPKEEnabled("payment"))
+	}
+}
+
+// /hpke/repin is admin-only, uses Root's own keys file, gets past the old pin
+// and restores it when the new handshake fails.
+func TestHPKERepin(t *testing.T) {
+	r, _ := pinnedRoot(t)
+	pinned, _ := r.pins.get("payment")
+	dir := t.TempDir()
+	writeKeys(t, filepath.Join(dir, "merged_agent_keys.json"), "did:sage:test:pay-b")
+	t.Chdir(dir)
+
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hpke/repin", strings.NewReader(`{"target":"payment"}`)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("repin without token: %d %s", w.Code, w.Body)
+	}
+	w = adminCall(r, http.MethodPost, "/hpke/repin", map[string]any{"target": "payment", "keysFile": "/tmp/x.json"})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"keysFile"`) {
+		t.Fatalf("repin with keysFile: %d %s", w.Code, w.Body)
+	}
+
+	w = adminCall(r, http.MethodPost, "/hpke/repin", map[string]any{"target": "payment"})
+	if w.Code == http.StatusOK || !strings.Contains(w.Body.String(), "HPKE Initialize") {
+		t.Fatalf("repin did not reach the handshake: %d %s", w.Code, w.Body)
+	}
+	if got, _ := r.pins.get("payment"); got != pinned {
+		t.Fatalf("pin after failed repin: %+v", got)
+	}
+}
+
+// Pins survive a restart through ROOT_HPKE_PINS_FILE.
+func TestHPKEPinsPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins.json")
+	ps := newPinStore(path)
+	ps.set(hpkePin{Target: "payment", ServerDID: "did:sage:test:pay-a", KEMFingerprint: "sha256:aa"})
+	ps.set(hpkePin{Target: "medical", ServerDID: "did:sage:test:med"})
+	ps.clear("medical")
+
+	got := newPinStore(path).list()
+	if len(got) != 1 || got[0].ServerDID != "did:sage:test:pay-a" || got[0].KEMFingerprint != "sha256:aa" {
+		t.Fatalf("reloaded pins %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+// Package root - first HPKE request racing the agent's session creation.
+//
+// enableHPKERoute returns as soon as the handshake is answered, and the first
+// data request under the new kid can reach the agent before it has committed
+// the session. Agents answer such a request 409 SESSION_NOT_READY
+// (internal/kidbind) instead of mistaking the ciphertext for a handshake.
+// sendVia then resends the same ciphertext up to ROOT_HPKE_NOT_READY_RETRIES
+// times (default 3), waiting ROOT_HPKE_NOT_READY_BACKOFF_MS (default 50,
+// doubling) before each. A kid still unknown after that (agent restarted,
+// another replica behind the gateway) is given up: Root handshakes again,
+// re-encrypts the request under the new kid and sends it once more.
+//
+// Outcomes, on /metrics as sage_root_hpke_session_not_ready_total and in
+// /status as "hpkeRaces":
+//
+//   - retried: the session appeared during the retries;
+//   - rehandshake: the request went through under a new kid;
+//   - failed: the re-handshake failed or the new kid was not ready either;
+//     the 409 is answered as any other upstream error.
+package root
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+)
+
+// Outcomes of a SESSION_NOT_READY answer.
+const (
+	raceRetried     = "retried"
+	raceRehandshake = "rehandshake"
+	raceFailed      = "failed"
+)
+
+var raceOutcomes = []string{raceRetried, raceRehandshake, raceFailed}
+
+// hpkeRaceStats counts SESSION_NOT_READY answers by outcome.
+type hpkeRaceStats struct {
+	by map[string]*atomic.Int64
+}
+
+func newHPKERaceStats() *hpkeRaceStats {
+	s := &hpkeRaceStats{by: map[string]*atomic.Int64{}}
+	for _, o := range raceOutcomes {
+		s.by[o] = new(atomic.Int64)
+	}
+	return s
+}
+
+func (s *hpkeRaceStats) snapshot() map[string]int64 {
+	out := make(map[string]int64, len(raceOutcomes))
+	for _, o := range raceOutcomes {
+		out[o] = s.by[o].Load()
+	}
+	return out
+}
+
+func (s *hpkeRaceStats) writePrometheus(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("# HELP sage_root_hpke_session_not_ready_total First HPKE requests that reached an agent before its session, by outcome.\n# TYPE sage_root_hpke_session_not_ready_total counter\n")
+	for _, o := range raceOutcomes {
+		b.WriteString(`sage_root_hpke_session_not_ready_total{outcome="` + o + `"} ` + strconv.FormatInt(s.by[o].Load(), 10) + "\n")
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+// sessionNotReady reports whether resp is an agent's 409 SESSION_NOT_READY.
+func sessionNotReady(resp *transport.Response) bool {
+	var he prototx.ErrHTTPStatus
+	if resp == nil || resp.Success || !errors.As(resp.Error, &he) || he.Code != 409 {
+		return false
+	}
+	return strings.Contains(string(he.Body), kidbind.CodeNotReady) || strings.Contains(string(resp.Data), kidbind.CodeNotReady)
+}
+
+// awaitSession handles a SESSION_NOT_READY answer to sm, sent under kid:
+// bounded resends, then one re-handshake with plain re-encrypted. It returns
+// the last answer and the kid it was sent under.
+func (r *RootAgent) awaitSession(ctx context.Context, rt extRoute, tx *prototx.A2ATransport, sm *transport.SecureMessage, kid string, plain []byte, resp *transport.Response) (*transport.Response, string, error) {
+	retries := envInt("ROOT_HPKE_NOT_READY_RETRIES", 3)
+	wait := time.Duration(envInt("ROOT_HPKE_NOT_READY_BACKOFF_MS", 50)) * time.Millisecond
+	r.logger.Printf("[root][hpke] target=%s kid=%s not ready upstream; retrying up to %d times", rt.Key, kid, retries)
+
+	for i := 0; i < retries; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, kid, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+		var err error
+		if resp, err = r.sendTransport(ctx, rt.Key, tx, sm); err != nil {
+			return nil, kid, err
+		}
+		if !sessionNotReady(resp) {
+			r.hpkeRaces.by[raceRetried].Add(1)
+			r.logger.Printf("[root][hpke] target=%s kid=%s ready after %d retries", rt.Key, kid, i+1)
+			return resp, kid, nil
+		}
+	}
+
+	r.logger.Printf("[root][hpke] ⚠️ target=%s kid=%s still unknown after %d retries; handshaking again", rt.Key, kid, retries)
+	outcome, newKid := raceFailed, ""
+	defer func() {
+		r.hpkeRaces.by[outcome].Add(1)
+		result := "failure"
+		if outcome == raceRehandshake {
+			result = "success"
+		}
+		r.audit.Emit(audit.Event{
+			Type: "hpke", Action: "session.not_ready", Outcome: result, Target: rt.Key,
+			Detail: map[string]any{"kid": kid, "newKid": newKid, "retries": retries},
+		})
+	}()
+	if err := r.enableHPKERoute(ctx, rt, hpkeKeysPath()); err != nil {
+		r.logger.Printf("[root][hpke] re-handshake failed target=%s: %v", rt.Key, err)
+		return resp, kid, nil
+	}
+	ct, k, used, err := r.encryptIfHPKE(rt.Key, plain)
+	if !used || err != nil {
+		r.logger.Printf("[root][hpke] re-encrypt failed target=%s: %v", rt.Key, err)
+		return resp, kid, nil
+	}
+	newKid = k
+	resend := *sm
+	resend.ID = uuid.NewString()
+	resend.Payload = ct
+	resend.Metadata = map[string]string{}
+	for mk, mv := range sm.Metadata {
+		resend.Metadata[mk] = mv
+	}
+	resend.Metadata["hpke_kid"] = k
+	next, err := r.sendTransport(ctx, rt.Key, tx, &resend)
+	if err != nil {
+		return nil, k, err
+	}
+	if !sessionNotReady(next) {
+		outcome = raceRehandshake
+	}
+	return next, k, nil
+}
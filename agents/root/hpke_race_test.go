@@ -0,0 +1,120 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+)
+
+// notReadyDoer answers 409 SESSION_NOT_READY to the first notReady requests,
+// then 200.
+type notReadyDoer struct {
+	notReady int
+	calls    int
+}
+
+func (d *notReadyDoer) Do(context.Context, *http.Request) (*http.Response, error) {
+	d.calls++
+	if d.calls <= d.notReady {
+		body := `{"error":"` + kidbind.CodeNotReady + `","reason":"no hpke session yet"}`
+		return &http.Response{StatusCode: http.StatusConflict, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"id":"a1","type":"response"}`))}, nil
+}
+
+// raceSend sends one ciphertext through d and, as sendVia does, hands a
+// SESSION_NOT_READY answer to awaitSession.
+func raceSend(t *testing.T, r *RootAgent, d *notReadyDoer) (*transport.Response, string, error) {
+	t.Helper()
+	tx := prototx.NewA2ATransport(d, "http://payment", false, false)
+	sm := &transport.SecureMessage{ID: "m1", Payload: []byte("ciphertext"), Metadata: map[string]string{"hpke_kid": "kid-1"}}
+	resp, err := r.sendTransport(context.Background(), "payment", tx, sm)
+	if err != nil || !sessionNotReady(resp) {
+		t.Fatalf("first answer: %+v %v", resp, err)
+	}
+	rt := extRoute{Agent: "payment", Key: "payment", Base: "http://payment"}
+	return r.awaitSession(context.Background(), rt, tx, sm, "kid-1", []byte(`{"content":"pay"}`), resp)
+}
+
+// A session that appears while Root retries needs no new handshake.
+func TestAwaitSessionRetried(t *testing.T) {
+	t.Setenv("ROOT_HPKE_NOT_READY_BACKOFF_MS", "1")
+	r := statusRoot(t)
+	d := &notReadyDoer{notReady: 2}
+	resp, kid, err := raceSend(t, r, d)
+	if err != nil || resp == nil || !resp.Success || kid != "kid-1" || d.calls != 3 {
+		t.Fatalf("resp %+v kid %s err %v after %d sends", resp, kid, err, d.calls)
+	}
+	if got := r.hpkeRaces.snapshot(); got[raceRetried] != 1 || got[raceRehandshake]+got[raceFailed] != 0 {
+		t.Fatalf("races %v", got)
+	}
+}
+
+// Retries are bounded; past them Root escalates to a new handshake. This tree
+// has no keys for the agent, so the handshake fails, the 409 stands and the
+// escalation is counted and audited as failed.
+func TestAwaitSessionEscalates(t *testing.T) {
+	t.Setenv("ROOT_HPKE_NOT_READY_BACKOFF_MS", "1")
+	t.Setenv("ROOT_HPKE_NOT_READY_RETRIES", "2")
+	t.Setenv("HPKE_KEYS", filepath.Join(t.TempDir(), "missing.json"))
+	dir := t.TempDir()
+	r := statusRoot(t)
+	r.audit = audit.New("root", dir, log.New(io.Discard, "", 0))
+	d := &notReadyDoer{notReady: 100}
+
+	resp, kid, err := raceSend(t, r, d)
+	if err != nil || !sessionNotReady(resp) || kid != "kid-1" {
+		t.Fatalf("resp %+v kid %s err %v", resp, kid, err)
+	}
+	if d.calls != 1+2 {
+		t.Fatalf("%d sends, want the first plus 2 retries", d.calls)
+	}
+	if got := r.hpkeRaces.snapshot(); got[raceFailed] != 1 || got[raceRetried] != 0 {
+		t.Fatalf("races %v", got)
+	}
+	r.audit.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "root-*.jsonl"))
+	var ev string
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		ev += string(b)
+	}
+	if !strings.Contains(ev, `"session.not_ready"`) || !strings.Contains(ev, `"retries":2`) || !strings.Contains(ev, `"kid":"kid-1"`) {
+		t.Fatalf("audit %s", ev)
+	}
+}
+
+// A caller that leaves during the back-off stops the retries.
+func TestAwaitSessionCanceled(t *testing.T) {
+	t.Setenv("ROOT_HPKE_NOT_READY_BACKOFF_MS", "10000")
+	r := statusRoot(t)
+	d := &notReadyDoer{notReady: 100}
+	tx := prototx.NewA2ATransport(d, "http://payment", false, false)
+	sm := &transport.SecureMessage{ID: "m1", Payload: []byte("ciphertext"), Metadata: map[string]string{}}
+	resp, _ := r.sendTransport(context.Background(), "payment", tx, sm)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := r.awaitSession(ctx, extRoute{Agent: "payment", Key: "payment"}, tx, sm, "kid-1", nil, resp); !errors.Is(err, context.Canceled) || d.calls != 1 {
+		t.Fatalf("err %v after %d sends", err, d.calls)
+	}
+}
+
+func TestSessionNotReady(t *testing.T) {
+	other := &transport.Response{Error: prototx.ErrHTTPStatus{Code: http.StatusConflict, Body: []byte(`{"error":"KID_DID_MISMATCH"}`)}}
+	ready := &transport.Response{Error: prototx.ErrHTTPStatus{Code: http.StatusConflict, Body: []byte(`{"error":"SESSION_NOT_READY"}`)}}
+	if sessionNotReady(nil) || sessionNotReady(other) || !sessionNotReady(ready) || sessionNotReady(&transport.Response{Success: true}) {
+		t.Fatal("sessionNotReady misclassifies")
+	}
+}
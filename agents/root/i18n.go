@@ -0,0 +1,99 @@
+// Package root - message catalog for Root's templated answers.
+//
+// Root's fixed, parameterized answers (conversation busy, clarify limit,
+// ambiguous payment, stale-flow offers and resumes, payload too large) are
+// rendered from the internal/i18n catalog instead of inline {"ko","en"} maps,
+// so a missing or mis-formatted translation is reported at boot (NewRootAgent
+// logs the completeness per language; I18N_STRICT=1 makes it fatal) and
+// fallbacks to English are counted in /metrics. /status shows the catalog under "i18n".
+// Free-form LLM answers are not templates and stay outside the catalog.
+package root
+
+import "github.com/sage-x-project/sage-multi-agent/internal/i18n"
+
+// tr renders a catalog key in the conversation's language.
+func tr(lang, key string, args ...any) string {
+	return i18n.T(langOrDefault(lang), key, args...)
+}
+
+func init() {
+	for key, byLang := range rootMessages {
+		i18n.Register(key, byLang)
+	}
+}
+
+var rootMessages = map[string]map[string]string{
+	// conv_queue.go
+	"root.conversation_busy": {
+		"ko": "이 대화의 이전 요청을 아직 처리하고 있어요. 약 %d초 후에 다시 시도해 주세요.",
+		"en": "An earlier request in this conversation is still being processed. Please retry in about %ds.",
+	},
+
+	// clarify_limit.go
+	"root.clarify.limit": {
+		"ko": "같은 질문이 반복되고 있어 더 묻지 않을게요.\n- 지금까지 받은 정보: %s\n- 아직 필요한 정보: %s\n아래 양식(metadata \"form\"의 키)으로 남은 항목을 보내 주시거나, \"취소\"라고 하시면 처음부터 다시 시작할게요.",
+		"en": "We seem to be going in circles, so I'll stop asking.\n- What I have: %s\n- Still missing: %s\nSend the missing items with the structured form (the keys in metadata \"form\"), or say \"cancel\" to start over.",
+	},
+	"root.clarify.nothing_yet": {
+		"ko": "(아직 없음)",
+		"en": "(nothing yet)",
+	},
+	"root.clarify.abandoned": {
+		"ko": "알겠어요, 진행 중이던 내용을 지웠어요. 필요하면 처음부터 다시 말씀해 주세요.",
+		"en": "Okay, I've cleared what we had. Start again whenever you're ready.",
+	},
+
+	// payment_ambiguity.go
+	"root.payment.ambiguous.executed": {
+		"ko": "결제 응답을 안전하게 확인하지 못해 결제 상태를 따로 조회했어요. 결제는 처리되었어요 (주문번호 %s).",
+		"en": "We could not verify the payment response, so we checked the payment status: the payment went through (order %s).",
+	},
+	"root.payment.ambiguous.not_executed": {
+		"ko": "결제 응답을 확인하지 못해 결제 상태를 조회했어요. 결제는 처리되지 않았으니 다시 확인해 주시면 진행할게요.",
+		"en": "We could not verify the payment response and checked the payment status: the payment was not made. Confirm again to retry.",
+	},
+	"root.payment.ambiguous.unknown": {
+		"ko": "결제 응답을 확인하지 못했고, 결제 여부도 아직 확인할 수 없어요. 다시 확인해 주셔도 중복 결제되지 않아요 (참조 %s).",
+		"en": "We could not verify the payment response and cannot tell yet whether the payment went through. Confirming again will not charge twice (reference %s).",
+	},
+
+	// payload_limits.go
+	"root.payload.too_large": {
+		"ko": "요청이 너무 커서 %s 서비스로 보내지 않았어요 (%d KiB, 허용 %d KiB). 내용을 줄여서 다시 시도해 주세요.",
+		"en": "This request is too large for the %s service and was not sent (%d KiB; it accepts up to %d KiB). Please shorten it and try again.",
+	},
+
+	// await_expiry.go
+	"root.await.domain.payment": {
+		"ko": "결제",
+		"en": "payment",
+	},
+	"root.await.domain.medical": {
+		"ko": "의료 상담 접수",
+		"en": "medical intake",
+	},
+	"root.await.pending_one": {
+		"ko": "참고로 이전에 진행하던 %s이(가) 남아 있어요. 이어서 하려면 \"계속\"이라고 말해 주세요.",
+		"en": "We also have an unfinished %s from earlier; say \"continue\" to resume it.",
+	},
+	"root.await.pending_many": {
+		"ko": "참고로 이전에 진행하던 %s이(가) 남아 있어요. \"결제 계속\" 또는 \"의료 계속\"이라고 하면 이어서 할 수 있어요.",
+		"en": "We also have an unfinished %s from earlier; say \"continue payment\" or \"continue medical\" to resume.",
+	},
+	"root.await.list_sep": {
+		"ko": ", ",
+		"en": " and ",
+	},
+	"root.await.resume.payment_confirm": {
+		"ko": "이전 결제를 이어서 진행할게요.\n%s",
+		"en": "Resuming your earlier payment.\n%s",
+	},
+	"root.await.resume.payment_collect": {
+		"ko": "이전 결제를 이어서 진행할게요. 지금까지: %s. 아직 필요한 정보: %s",
+		"en": "Resuming your earlier payment. So far: %s. Still needed: %s",
+	},
+	"root.await.resume.medical": {
+		"ko": "이전 의료 상담 접수를 이어서 할게요. 지금까지: %s. 아직 필요한 정보: %s",
+		"en": "Resuming your earlier medical intake. So far: %s. Still needed: %s",
+	},
+}
@@ -0,0 +1,61 @@
+package root
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/i18n"
+)
+
+// Every Root template renders in every language Root answers in: no key
+// falls back to English and none prints a %! formatting error.
+func TestRootMessagesRenderEverywhere(t *testing.T) {
+	t.Setenv("I18N_STRICT", "1")
+	c := i18n.New()
+	for key, byLang := range rootMessages {
+		c.Register(key, byLang)
+	}
+	for _, rep := range c.Check() {
+		if len(rep.Missing) > 0 || len(rep.Errors) > 0 {
+			t.Errorf("%s: missing %v, errors %v", rep.Lang, rep.Missing, rep.Errors)
+		}
+	}
+	for key := range rootMessages {
+		for _, lang := range []string{"ko", "en"} {
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Errorf("%s/%s: %v", lang, key, p)
+					}
+				}()
+				if s := c.T(lang, key, stubArgs(rootMessages[key]["en"])...); strings.Contains(s, "%!") {
+					t.Errorf("%s/%s renders as %q", lang, key, s)
+				}
+			}()
+		}
+	}
+	if n := c.Status()["fallbacks"]; n != int64(0) {
+		t.Fatalf("fallbacks: %v", n)
+	}
+}
+
+// stubArgs returns one argument per verb of tmpl: an int for %d, a string
+// otherwise.
+func stubArgs(tmpl string) []any {
+	var args []any
+	for i := 0; i < len(tmpl)-1; i++ {
+		if tmpl[i] != '%' {
+			continue
+		}
+		i++
+		if tmpl[i] == '%' {
+			continue
+		}
+		if tmpl[i] == 'd' {
+			args = append(args, 7)
+		} else {
+			args = append(args, "x")
+		}
+	}
+	return args
+}
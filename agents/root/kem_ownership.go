@@ -0,0 +1,107 @@
+// Package root - KEM key ownership verification before HPKE handshakes.
+//
+// Before a session is initialized, the server's X25519 KEM key is checked
+// against the ownership proof produced at registration
+// (HPKE_KEM_PROOFS_FILE, default keys/kem/kem_ownership.json): the signature
+// must recover to the DID's registered owner, cover the KEM key the resolver
+// returns for the DID (the one the handshake encrypts to), and name the
+// configured registry. The local public KEM file is not consulted. Without a proof, a pinned KEM fingerprint
+// (see hpke_pins.go) is the minimum accepted evidence. Anything else is
+// KEM_OWNERSHIP_UNVERIFIED: refused when HPKE_KEM_STRICT=true, logged otherwise.
+package root
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+// KEM ownership states (hpkeState.kemOwnership)
+const (
+	kemVerified   = "verified"
+	kemPinned     = "pinned"
+	kemUnverified = "unverified"
+)
+
+// kemProofsPath: HPKE_KEM_PROOFS_FILE or keys/kem/kem_ownership.json.
+func kemProofsPath() string {
+	return firstNonEmpty(strings.TrimSpace(os.Getenv("HPKE_KEM_PROOFS_FILE")), "keys/kem/kem_ownership.json")
+}
+
+func hpkeKEMStrict() bool { return flagKEMStrict.On() }
+
+// checkKEMProof validates a proof against the resolved KEM key, the DID's
+// registered owner and the configured registry.
+func checkKEMProof(p kemproof.Proof, kemPub, owner, serverDID string) error {
+	if err := kemproof.Check(p, kemPub, owner); err != nil {
+		return err
+	}
+	if reg := strings.TrimSpace(os.Getenv("SAGE_REGISTRY_ADDRESS")); reg != "" && !strings.EqualFold(reg, strings.TrimSpace(p.Registry)) {
+		return &kemproof.Error{DID: serverDID, Reason: "proof was issued for a different registry"}
+	}
+	return nil
+}
+
+// registeredOwner returns the owner address the registry holds for serverDID.
+func (r *RootAgent) registeredOwner(ctx context.Context, serverDID string) (string, error) {
+	md, err := r.resolver.Resolve(ctx, sagedid.AgentDID(serverDID))
+	if err != nil {
+		return "", err
+	}
+	if md == nil {
+		return "", errors.New("not registered")
+	}
+	return md.Owner, nil
+}
+
+// verifyKEMOwnership returns the ownership state for the target's server,
+// whose resolved KEM key is kemPub. The error is non-nil only when the
+// handshake must be refused (strict mode).
+func (r *RootAgent) verifyKEMOwnership(ctx context.Context, target, alias, serverDID, kemPub string) (string, error) {
+	var verr error
+	state := kemUnverified
+	proofs, _ := kemproof.Load(kemProofsPath())
+	if p, ok := kemproof.Find(proofs, serverDID, alias); ok {
+		owner, err := r.registeredOwner(ctx, serverDID)
+		if err != nil {
+			verr = &kemproof.Error{DID: serverDID, Reason: "resolve registered owner: " + err.Error()}
+		} else if verr = checkKEMProof(p, kemPub, owner, serverDID); verr == nil {
+			state = kemVerified
+		}
+	} else if pin, ok := r.pins.get(target); ok && pin.KEMFingerprint != "" {
+		state = kemPinned // checkPin already matched the fingerprint
+	} else {
+		verr = &kemproof.Error{DID: serverDID, Reason: "no ownership proof and no pinned KEM fingerprint"}
+	}
+
+	if verr == nil {
+		r.logger.Printf("[root][hpke] KEM ownership %s target=%s did=%s", state, target, serverDID)
+		return state, nil
+	}
+	strict := hpkeKEMStrict()
+	outcome := "failure"
+	if strict {
+		outcome = "denied"
+	}
+	r.logger.Printf("[root][alert][hpke] ⚠️ %v (target=%s strict=%v)", verr, target, strict)
+	r.audit.Emit(audit.Event{
+		Type: "hpke", Action: "kem.ownership", Outcome: outcome, Target: target,
+		Detail: map[string]any{"code": kemproof.Code, "did": serverDID, "reason": verr.Error(), "strict": strict},
+	})
+	if strict {
+		return state, verr
+	}
+	return state, nil
+}
+
+// isKEMUnverified reports whether err is a KEM ownership refusal.
+func isKEMUnverified(err error) bool {
+	var ke *kemproof.Error
+	return errors.As(err, &ke)
+}
@@ -0,0 +1,79 @@
+// Package root - invalidation after an agent key rotation.
+//
+// POST /admin/keys/invalidate {target} (admin) is called by keytool rotate once
+// the target's new key is in the key files. It drops what Root resolved for
+// the old key: the HPKE pin and the HPKE session (server DID + KEM key looked
+// up in the registry). When HPKE was on for the target, Root handshakes again
+// right away, which re-reads the key files, resolves the new DID and pins it.
+// If the agent is not serving the new key yet, the re-handshake is retried in
+// the background and the response says so; the session stays off until then.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+func (r *RootAgent) handleKeysInvalidate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	var in struct {
+		Target string `json:"target"`
+		Reason string `json:"reason,omitempty"`
+	}
+	if probs := decodeAdminBody(req, map[string]fieldSpec{
+		"target": {Kind: "string", Required: true, Enum: r.knownTargets()},
+		"reason": {Kind: "string"},
+	}, &in); len(probs) > 0 {
+		writeInvalidRequest(w, probs)
+		return
+	}
+	target := strings.ToLower(strings.TrimSpace(in.Target))
+	who := requesterOf(req)
+	reason := firstNonEmpty(strings.TrimSpace(in.Reason), "key rotation")
+
+	wasOn := r.IsHPKEEnabled(target)
+	old, had := r.pins.get(target)
+	r.pins.clear(target)
+	_ = r.disableHPKEBy(target, who, reason, true)
+
+	res := map[string]any{"target": target, "pinCleared": had, "hpkeWasEnabled": wasOn}
+	if wasOn || hpkeRequired(target) {
+		ctxEn := context.WithValue(req.Context(), ctxRequesterKey, who)
+		if err := r.EnableHPKE(ctxEn, target, hpkeKeysPath()); err != nil {
+			res["hpkeReenabled"] = false
+			res["hpkeError"] = err.Error()
+			if qerr := r.Background(func(bctx context.Context) {
+				if r.IsHPKEEnabled(target) {
+					return
+				}
+				if err := r.EnableHPKE(context.WithValue(bctx, ctxRequesterKey, who), target, hpkeKeysPath()); err != nil {
+					r.logger.Printf("[root][keys] HPKE re-handshake retry failed target=%s: %v", target, err)
+				}
+			}); qerr == nil {
+				res["hpkeRetryScheduled"] = true
+			}
+		} else {
+			res["hpkeReenabled"] = true
+		}
+	}
+	newPin, _ := r.pins.get(target)
+	res["status"] = r.hpkeStatus(target)
+
+	r.logger.Printf("[root][keys] invalidated target=%s oldDid=%s newDid=%s requester=%s", target, old.ServerDID, newPin.ServerDID, who)
+	r.audit.Emit(audit.Event{
+		Type: "security", Action: "keys.invalidate", Outcome: "success", Actor: who, Target: target,
+		Detail: map[string]any{"reason": reason, "oldDid": old.ServerDID, "did": newPin.ServerDID, "hpkeReenabled": res["hpkeReenabled"]},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
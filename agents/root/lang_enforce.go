@@ -0,0 +1,119 @@
+// Package root - language enforcement on user-facing LLM text.
+//
+// Chat replies, clarify questions, the confirm prompt, plan summaries and
+// medical answers go through chatUser, which checks that the model answered
+// in the conversation's language and otherwise re-asks once, then translates,
+// then lets the caller fall back to its template (llm.ChatLang). Extractors,
+// the router and other machine-read calls keep using Chat directly. When any
+// call in a /process turn needed intervention, the JSON response carries
+// metadata languageCorrected:true, and a call answered by a model other than
+// the pinned one (llm/modelpin.go) llmModelMismatch:true. The same writer
+// stamps the request's security posture (posture.go) and the verification
+// headers (verified.go).
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+	"github.com/sage-x-project/sage-multi-agent/llm"
+)
+
+// chatUser is llm.ChatLang for text shown to the user in lang.
+func (r *RootAgent) chatUser(ctx context.Context, lang, sys, usr string) (string, error) {
+	return llm.ChatLang(ctx, r.llmClient, langOrDefault(lang), sys, usr)
+}
+
+// langStampWriter buffers a /process response so languageCorrected, the
+// security posture, any domain redirect and the offer to resume a stale flow
+// can be added to its metadata once the turn is done.
+type langStampWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	status  int
+	buf     bytes.Buffer
+	posture *postureStats
+	check   *headerCheck
+}
+
+func newLangStampWriter(w http.ResponseWriter, ctx context.Context) *langStampWriter {
+	return &langStampWriter{ResponseWriter: w, ctx: ctx}
+}
+
+func (lw *langStampWriter) WriteHeader(code int) {
+	if lw.status == 0 {
+		lw.status = code
+	}
+}
+
+func (lw *langStampWriter) Write(b []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	return lw.buf.Write(b)
+}
+
+// flush writes the buffered response, stamping metadata when needed.
+func (lw *langStampWriter) flush() {
+	body := lw.buf.Bytes()
+	corrected := llm.LangCorrected(lw.ctx)
+	modelMismatch := llm.ModelMismatched(lw.ctx)
+	var posture map[string]any
+	if pt := postureFrom(lw.ctx); pt != nil {
+		posture = pt.inputs().posture()
+		lw.Header().Set("X-SAGE-Posture", posture["level"].(string))
+		lw.posture.observe(posture["level"].(string))
+		verified, sigValid := pt.inputs().verificationHeaders()
+		lw.check.verify(lw.Header(), pt, verified, sigValid)
+		lw.Header().Set(hdrVerified, verified)
+		lw.Header().Set(hdrSignatureValid, sigValid)
+	}
+	if trace := payloadTraceFrom(lw.ctx).value(); trace != "" {
+		lw.Header().Set(payloadcheck.TraceHeader, trace)
+	}
+	routing := routingFrom(lw.ctx).meta()
+	pending := awaitNoteFrom(lw.ctx)
+	if corrected || modelMismatch || posture != nil || routing != nil || pending.any() {
+		var m map[string]any
+		if json.Unmarshal(body, &m) == nil && m != nil {
+			meta, _ := m["metadata"].(map[string]any)
+			if meta == nil {
+				meta = map[string]any{}
+			}
+			if corrected {
+				meta["languageCorrected"] = true
+			}
+			if modelMismatch {
+				meta["llmModelMismatch"] = true
+			}
+			if routing != nil {
+				meta["routing"] = routing
+			}
+			pending.stamp(m, meta)
+			if posture != nil {
+				sec, _ := meta["security"].(map[string]any)
+				if sec == nil {
+					sec = map[string]any{}
+				}
+				sec["posture"] = posture
+				if tl := postureFrom(lw.ctx).timeline(); len(tl) > 0 {
+					sec["timeline"] = tl
+				}
+				meta["security"] = sec
+			}
+			m["metadata"] = meta
+			if b, err := json.Marshal(m); err == nil {
+				body = append(b, '\n')
+				lw.Header().Del("Content-Length")
+			}
+		}
+	}
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	lw.ResponseWriter.WriteHeader(lw.status)
+	_, _ = lw.ResponseWriter.Write(body)
+}
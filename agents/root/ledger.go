@@ -0,0 +1,524 @@
+// Package root - payment reconciliation ledger.
+//
+// GET /conversations/{cid}/ledger (admin) ties a conversation's payment
+// artifacts together per purchase attempt:
+//
+//   - preview: what the user confirmed (amount after any re-quote, item,
+//     recipient, method), recorded by forwardPayment;
+//   - receipt: the payment agent's receipt as stored with the response, or
+//     fetched through the "status" operation by idempotency key when the
+//     response never arrived (ambiguous HPKE failures);
+//   - order: the payment agent's order record via the "order" operation
+//     (GET /orders/{orderId}), including its refund state;
+//   - refund: refunds made in this conversation;
+//   - auth: the hold of a two-step payment (auth_capture.go), fetched through
+//     the "auth" operation (GET /auths/{authId}).
+//
+// Each attempt gets a status: refunded, missing_order (no order ID, or the
+// order agent does not know it), amount_mismatch (preview, receipt and order
+// amounts disagree) or matched; attempts the payment agent rejected are
+// listed as not_charged and left out of the totals. A hold not captured yet is
+// authorized (its amount totalled as heldKRW, not charged); one that expired
+// uncaptured is voided and left out like not_charged. A capture may be for
+// less than the hold: the preview is checked against the hold and the receipt
+// against the order. Conversations from before attempts were recorded only
+// have receipts; their entries lack a preview and are reconciled on what is
+// there. ?fetch=false skips the upstream lookups.
+// A mismatch or missing order emits audit payment.reconcile_mismatch once per
+// attempt and status.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Reconciliation statuses.
+const (
+	ledgerMatched        = "matched"
+	ledgerAmountMismatch = "amount_mismatch"
+	ledgerMissingOrder   = "missing_order"
+	ledgerRefunded       = "refunded"
+	ledgerNotCharged     = "not_charged"
+	ledgerAuthorized     = "authorized"
+	ledgerVoided         = "voided"
+)
+
+// Attempt outcomes as Root saw them.
+const (
+	attemptSent       = "sent" // no readable answer yet (in flight or ambiguous)
+	attemptCharged    = "charged"
+	attemptRejected   = "rejected"
+	attemptAuthorized = "authorized" // held, awaiting capture
+	attemptVoided     = "voided"     // hold expired uncaptured
+)
+
+// payAttempt is one confirmed purchase, keyed by its idempotency key.
+type payAttempt struct {
+	ID           string    `json:"id"`
+	PreviewKRW   int64     `json:"amountKRW"`
+	EstimatedKRW int64     `json:"estimatedKRW,omitempty"`
+	Item         string    `json:"item,omitempty"`
+	To           string    `json:"to,omitempty"`
+	Method       string    `json:"method,omitempty"`
+	ConfirmedAt  time.Time `json:"confirmedAt"`
+	Outcome      string    `json:"-"`
+	OrderID      string    `json:"-"`
+	Hold         *heldAuth `json:"-"` // two-step payments
+}
+
+// noteAttemptSent records (or re-opens, on a retried confirm) the attempt.
+func (r *RootAgent) noteAttemptSent(cid, key string, amt int64, slots paySlots, q *payQuote) {
+	a := payAttempt{
+		ID: key, PreviewKRW: amt, Item: firstNonEmpty(slots.Model, slots.Item), To: firstNonEmpty(slots.Recipient, slots.To),
+		Method: slots.Method, ConfirmedAt: time.Now().UTC(), Outcome: attemptSent,
+	}
+	if q != nil {
+		a.EstimatedKRW = q.EstimatedKRW
+	}
+	r.attemptMu.Lock()
+	defer r.attemptMu.Unlock()
+	var list []payAttempt
+	if v, ok := r.payAttempts.Load(cid); ok {
+		list = append(list, v.([]payAttempt)...)
+	}
+	for i := range list {
+		if list[i].ID == key {
+			list[i] = a
+			r.payAttempts.Store(cid, list)
+			return
+		}
+	}
+	r.payAttempts.Store(cid, append(list, a))
+}
+
+// closeAttempt sets the outcome of the latest open attempt and returns its ID.
+func (r *RootAgent) closeAttempt(cid, outcome, orderID string) string {
+	r.attemptMu.Lock()
+	defer r.attemptMu.Unlock()
+	v, ok := r.payAttempts.Load(cid)
+	if !ok {
+		return ""
+	}
+	list := append([]payAttempt(nil), v.([]payAttempt)...)
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Outcome == attemptSent {
+			list[i].Outcome, list[i].OrderID = outcome, orderID
+			r.payAttempts.Store(cid, list)
+			return list[i].ID
+		}
+	}
+	return ""
+}
+
+// authorizeAttempt marks the latest open attempt as held under h.
+func (r *RootAgent) authorizeAttempt(cid string, h heldAuth) {
+	r.attemptMu.Lock()
+	defer r.attemptMu.Unlock()
+	v, ok := r.payAttempts.Load(cid)
+	if !ok {
+		return
+	}
+	list := append([]payAttempt(nil), v.([]payAttempt)...)
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Outcome == attemptSent {
+			list[i].Outcome, list[i].Hold = attemptAuthorized, &h
+			r.payAttempts.Store(cid, list)
+			return
+		}
+	}
+}
+
+// settleAttempt sets the outcome of the attempt held under authID.
+func (r *RootAgent) settleAttempt(cid, authID, outcome, orderID string) {
+	r.attemptMu.Lock()
+	defer r.attemptMu.Unlock()
+	v, ok := r.payAttempts.Load(cid)
+	if !ok {
+		return
+	}
+	list := append([]payAttempt(nil), v.([]payAttempt)...)
+	for i := range list {
+		if list[i].Hold != nil && list[i].Hold.AuthID == authID {
+			list[i].Outcome, list[i].OrderID = outcome, orderID
+			r.payAttempts.Store(cid, list)
+			return
+		}
+	}
+}
+
+func (r *RootAgent) attemptsOf(cid string) []payAttempt {
+	r.attemptMu.Lock()
+	defer r.attemptMu.Unlock()
+	if v, ok := r.payAttempts.Load(cid); ok {
+		return append([]payAttempt(nil), v.([]payAttempt)...)
+	}
+	return nil
+}
+
+func (r *RootAgent) receiptsOf(cid string) []receiptRef {
+	r.receiptMu.Lock()
+	defer r.receiptMu.Unlock()
+	if v, ok := r.receiptHistory.Load(cid); ok {
+		return append([]receiptRef(nil), v.([]receiptRef)...)
+	}
+	return nil
+}
+
+// ledgerReceipt is the receipt side of an entry.
+type ledgerReceipt struct {
+	OrderID   string `json:"orderId"`
+	AmountKRW int64  `json:"amountKRW"`
+	Source    string `json:"source"` // stored | lookup
+}
+
+// orderRecord is the order agent's view (GET /orders/{orderId}).
+type orderRecord struct {
+	Found     bool   `json:"found"`
+	AmountKRW int64  `json:"amountKRW,omitempty"`
+	Refunded  bool   `json:"refunded"`
+	RefundKRW int64  `json:"refundKRW,omitempty"`
+	RefundID  string `json:"refundId,omitempty"`
+}
+
+type ledgerRefund struct {
+	AmountKRW int64  `json:"amountKRW"`
+	RefundID  string `json:"refundId,omitempty"`
+	Source    string `json:"source"` // conversation | order
+}
+
+// ledgerAuth is the hold of a two-step payment.
+type ledgerAuth struct {
+	AuthID      string `json:"authId"`
+	Status      string `json:"status"` // authorized | captured | expired | not_found
+	HeldKRW     int64  `json:"heldKRW"`
+	CapturedKRW int64  `json:"capturedKRW,omitempty"`
+	OrderID     string `json:"orderId,omitempty"`
+	ExpiresAt   string `json:"expiresAt,omitempty"`
+	Source      string `json:"source"` // conversation | auth
+}
+
+type ledgerEntry struct {
+	Attempt string         `json:"attempt"`
+	Status  string         `json:"status"`
+	Issues  []string       `json:"issues,omitempty"`
+	Missing []string       `json:"missing,omitempty"`
+	Preview *payAttempt    `json:"preview,omitempty"`
+	Receipt *ledgerReceipt `json:"receipt,omitempty"`
+	Order   *orderRecord   `json:"order,omitempty"`
+	Refund  *ledgerRefund  `json:"refund,omitempty"`
+	Auth    *ledgerAuth    `json:"auth,omitempty"`
+}
+
+type ledgerTotals struct {
+	Attempts    int            `json:"attempts"` // excluding not_charged
+	ByStatus    map[string]int `json:"byStatus"`
+	PreviewKRW  int64          `json:"previewKRW"`
+	ChargedKRW  int64          `json:"chargedKRW"`
+	RefundedKRW int64          `json:"refundedKRW"`
+	HeldKRW     int64          `json:"heldKRW,omitempty"` // authorized, not captured
+	NetKRW      int64          `json:"netKRW"`
+	Reconciled  bool           `json:"reconciled"`
+}
+
+type ledger struct {
+	CID     string        `json:"cid"`
+	Entries []ledgerEntry `json:"entries"`
+	Totals  ledgerTotals  `json:"totals"`
+	Fetched bool          `json:"fetched"`
+	Errors  []string      `json:"errors,omitempty"`
+	At      time.Time     `json:"generatedAt"`
+}
+
+// ledgerSource fetches upstream artifacts; nil fields skip the lookup.
+type ledgerSource struct {
+	order  func(orderID string) (*orderRecord, error)
+	charge func(key string) (orderID string, amount int64, err error) // "" when not executed
+	auth   func(authID string) (*ledgerAuth, error)
+}
+
+// buildLedger assembles and reconciles cid's entries from what Root recorded
+// plus whatever src can fetch.
+func buildLedger(cid string, attempts []payAttempt, receipts []receiptRef, src ledgerSource) ledger {
+	lg := ledger{CID: cid, Entries: []ledgerEntry{}, At: time.Now().UTC(), Fetched: src.order != nil || src.charge != nil || src.auth != nil}
+	byOrder := map[string]int{}
+	for i, rc := range receipts {
+		byOrder[strings.ToUpper(rc.OrderID)] = i
+	}
+	used := map[int]bool{}
+
+	for i := range attempts {
+		a := attempts[i]
+		e := ledgerEntry{Attempt: a.ID, Preview: &a}
+		if a.Outcome == attemptRejected {
+			e.Status = ledgerNotCharged
+			lg.Entries = append(lg.Entries, e)
+			continue
+		}
+		orderID := a.OrderID
+		if a.Hold != nil {
+			e.Auth = authOf(a)
+			if src.auth != nil {
+				if au, err := src.auth(a.Hold.AuthID); err != nil {
+					lg.Errors = append(lg.Errors, "auth "+a.Hold.AuthID+": "+err.Error())
+				} else {
+					e.Auth = au
+				}
+			}
+			// Captured upstream but the answer never reached Root
+			if orderID == "" && e.Auth.OrderID != "" {
+				orderID = e.Auth.OrderID
+				e.Receipt = &ledgerReceipt{OrderID: orderID, AmountKRW: e.Auth.CapturedKRW, Source: "lookup"}
+			}
+		}
+		if orderID == "" && a.Outcome == attemptSent && src.charge != nil {
+			id, amt, err := src.charge(a.ID)
+			if err != nil {
+				lg.Errors = append(lg.Errors, "status "+a.ID+": "+err.Error())
+			} else if id != "" {
+				orderID = id
+				e.Receipt = &ledgerReceipt{OrderID: id, AmountKRW: amt, Source: "lookup"}
+			}
+		}
+		if j, ok := byOrder[strings.ToUpper(orderID)]; ok && orderID != "" {
+			used[j] = true
+			e.Receipt = &ledgerReceipt{OrderID: receipts[j].OrderID, AmountKRW: receipts[j].AmountKRW, Source: "stored"}
+			e.Refund = refundOf(receipts[j])
+		}
+		lg.Entries = append(lg.Entries, e)
+	}
+	// Receipts without a recorded attempt (legacy conversations)
+	for j, rc := range receipts {
+		if used[j] {
+			continue
+		}
+		lg.Entries = append(lg.Entries, ledgerEntry{
+			Attempt: "receipt:" + rc.OrderID,
+			Receipt: &ledgerReceipt{OrderID: rc.OrderID, AmountKRW: rc.AmountKRW, Source: "stored"},
+			Refund:  refundOf(rc),
+		})
+	}
+
+	lg.Totals.ByStatus = map[string]int{}
+	lg.Totals.Reconciled = true
+	for i := range lg.Entries {
+		e := &lg.Entries[i]
+		if e.Status == ledgerNotCharged {
+			lg.Totals.ByStatus[e.Status]++
+			continue
+		}
+		if e.Receipt != nil && src.order != nil {
+			o, err := src.order(e.Receipt.OrderID)
+			if err != nil {
+				lg.Errors = append(lg.Errors, "order "+e.Receipt.OrderID+": "+err.Error())
+			} else {
+				e.Order = o
+			}
+		}
+		reconcileEntry(e)
+		if e.Status == ledgerVoided {
+			lg.Totals.ByStatus[e.Status]++
+			continue
+		}
+		lg.Totals.Attempts++
+		lg.Totals.ByStatus[e.Status]++
+		if e.Preview != nil {
+			lg.Totals.PreviewKRW += e.Preview.PreviewKRW
+		}
+		if e.Receipt != nil {
+			lg.Totals.ChargedKRW += e.Receipt.AmountKRW
+		}
+		if e.Refund != nil {
+			lg.Totals.RefundedKRW += e.Refund.AmountKRW
+		}
+		if e.Status == ledgerAuthorized {
+			lg.Totals.HeldKRW += e.Auth.HeldKRW
+		}
+		if e.Status == ledgerAmountMismatch || e.Status == ledgerMissingOrder {
+			lg.Totals.Reconciled = false
+		}
+	}
+	lg.Totals.NetKRW = lg.Totals.ChargedKRW - lg.Totals.RefundedKRW
+	return lg
+}
+
+// authOf is the hold as Root recorded it.
+func authOf(a payAttempt) *ledgerAuth {
+	st := authHeld
+	switch a.Outcome {
+	case attemptCharged:
+		st = authCaptured
+	case attemptVoided:
+		st = authExpired
+	}
+	au := &ledgerAuth{AuthID: a.Hold.AuthID, Status: st, HeldKRW: a.Hold.HeldKRW, OrderID: a.OrderID, Source: "conversation"}
+	if !a.Hold.ExpiresAt.IsZero() {
+		au.ExpiresAt = a.Hold.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return au
+}
+
+func refundOf(rc receiptRef) *ledgerRefund {
+	if !rc.Refunded {
+		return nil
+	}
+	amt := rc.RefundKRW
+	if amt <= 0 {
+		amt = rc.AmountKRW // refunded before amounts were recorded: full refund
+	}
+	return &ledgerRefund{AmountKRW: amt, RefundID: rc.RefundID, Source: "conversation"}
+}
+
+// reconcileEntry sets e's status from the artifacts it has.
+func reconcileEntry(e *ledgerEntry) {
+	// Holds not captured: nothing was charged
+	if e.Auth != nil && e.Receipt == nil && (e.Auth.Status == authHeld || e.Auth.Status == authExpired) {
+		if e.Auth.Status == authExpired {
+			e.Status = ledgerVoided
+			return
+		}
+		e.Status = ledgerAuthorized
+		if e.Preview != nil && e.Auth.HeldKRW > 0 && e.Preview.PreviewKRW != e.Auth.HeldKRW {
+			e.Issues = append(e.Issues, "hold amount differs")
+			e.Status = ledgerAmountMismatch
+		}
+		return
+	}
+	if e.Preview == nil {
+		e.Missing = append(e.Missing, "preview")
+	}
+	if e.Receipt == nil {
+		e.Missing = append(e.Missing, "receipt")
+	}
+	if e.Receipt != nil && e.Order == nil {
+		e.Missing = append(e.Missing, "order")
+	}
+	if e.Refund == nil && e.Order != nil && e.Order.Refunded {
+		e.Refund = &ledgerRefund{AmountKRW: e.Order.RefundKRW, RefundID: e.Order.RefundID, Source: "order"}
+	}
+
+	// Compare every amount that is present against the first one
+	var ref int64
+	check := func(name string, v int64) {
+		if v <= 0 {
+			return
+		}
+		if ref == 0 {
+			ref = v
+		} else if v != ref {
+			e.Issues = append(e.Issues, name+" amount differs")
+		}
+	}
+	if e.Preview != nil {
+		check("preview", e.Preview.PreviewKRW)
+	}
+	if e.Auth != nil && e.Auth.HeldKRW > 0 {
+		check("hold", e.Auth.HeldKRW)
+		if e.Receipt != nil && e.Receipt.AmountKRW > e.Auth.HeldKRW {
+			e.Issues = append(e.Issues, "capture exceeds hold")
+		}
+		ref = 0 // a capture may be partial: receipt and order are compared with each other
+	}
+	if e.Receipt != nil {
+		check("receipt", e.Receipt.AmountKRW)
+	}
+	if e.Order != nil && e.Order.Found {
+		check("order", e.Order.AmountKRW)
+	}
+
+	switch {
+	case e.Refund != nil:
+		e.Status = ledgerRefunded
+	case e.Receipt == nil || e.Receipt.OrderID == "":
+		e.Status = ledgerMissingOrder
+		e.Issues = append(e.Issues, "no order for this attempt")
+	case e.Order != nil && !e.Order.Found:
+		e.Status = ledgerMissingOrder
+		e.Issues = append(e.Issues, "order agent has no record of "+e.Receipt.OrderID)
+	case len(e.Issues) > 0:
+		e.Status = ledgerAmountMismatch
+	default:
+		e.Status = ledgerMatched
+	}
+}
+
+// fetchOrder asks the payment agent's order operation for orderID.
+func (r *RootAgent) fetchOrder(ctx context.Context, cid, orderID string) (*orderRecord, error) {
+	q := &types.AgentMessage{
+		ID: "order-" + orderID, ContextID: cid, From: "root", To: "payment", Type: "request",
+		Timestamp: time.Now(), Metadata: map[string]any{"payment.orderId": orderID},
+	}
+	out, err := r.sendExternal(ctx, "payment", "order", q)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorOut(out) {
+		return nil, &ledgerFetchError{out.Content}
+	}
+	o, _ := out.Metadata["order"].(map[string]any)
+	st, _ := o["state"].(string)
+	rec := &orderRecord{Found: st == "found"}
+	if amt, ok := pickIntFromMeta(o, "amountKRW"); ok {
+		rec.AmountKRW = int64(amt)
+	}
+	rec.Refunded, _ = o["refunded"].(bool)
+	if amt, ok := pickIntFromMeta(o, "refundKRW"); ok {
+		rec.RefundKRW = int64(amt)
+	}
+	rec.RefundID, _ = o["refundId"].(string)
+	return rec, nil
+}
+
+type ledgerFetchError struct{ msg string }
+
+func (e *ledgerFetchError) Error() string { return e.msg }
+
+// handleLedger: GET /conversations/{cid}/ledger.
+func (r *RootAgent) handleLedger(w http.ResponseWriter, req *http.Request, cid string) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	var src ledgerSource
+	if !strings.EqualFold(req.URL.Query().Get("fetch"), "false") && r.externalURLFor("payment") != "" {
+		ctx, cancel := context.WithTimeout(req.Context(), 15*time.Second)
+		defer cancel()
+		src.order = func(orderID string) (*orderRecord, error) { return r.fetchOrder(ctx, cid, orderID) }
+		src.auth = func(authID string) (*ledgerAuth, error) { return r.fetchAuth(ctx, cid, authID) }
+		src.charge = func(key string) (string, int64, error) {
+			state, orderID, receipt := r.checkPaymentStatus(ctx, cid, key)
+			if state == payUnknown {
+				return "", 0, &ledgerFetchError{"status lookup failed"}
+			}
+			amt, _ := pickIntFromMeta(receipt, "amountKRW")
+			return orderID, int64(amt), nil
+		}
+	}
+	lg := buildLedger(cid, r.attemptsOf(cid), r.receiptsOf(cid), src)
+	for _, e := range lg.Entries {
+		if e.Status != ledgerAmountMismatch && e.Status != ledgerMissingOrder {
+			continue
+		}
+		if _, dup := r.ledgerSeen.LoadOrStore(cid+"|"+e.Attempt+"|"+e.Status, struct{}{}); dup {
+			continue
+		}
+		d := map[string]any{"attempt": e.Attempt, "status": e.Status, "issues": e.Issues, "missing": e.Missing}
+		if e.Receipt != nil {
+			d["orderId"] = e.Receipt.OrderID
+		}
+		r.audit.Emit(audit.Event{Type: "payment", Action: "payment.reconcile_mismatch", Outcome: "failure", Actor: requesterOf(req), Target: "payment", CID: cid, Detail: d})
+		r.logger.Printf("[root][ledger] cid=%s attempt=%s status=%s issues=%v", cid, e.Attempt, e.Status, e.Issues)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(lg)
+}
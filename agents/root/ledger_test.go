@@ -0,0 +1,145 @@
+package root
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// One matched purchase, one mismatch, one refund, one the payment agent
+// rejected, and a legacy receipt recorded before attempts were.
+func TestLedgerReconcile(t *testing.T) {
+	attempts := []payAttempt{
+		{ID: "ok", PreviewKRW: 50000, Outcome: attemptCharged, OrderID: "O-1"},
+		{ID: "diff", PreviewKRW: 30000, Outcome: attemptCharged, OrderID: "O-2"},
+		{ID: "back", PreviewKRW: 20000, Outcome: attemptCharged, OrderID: "O-3"},
+		{ID: "no", PreviewKRW: 90000, Outcome: attemptRejected},
+		// the answer never arrived and the status lookup found nothing
+		{ID: "lost", PreviewKRW: 15000, Outcome: attemptSent},
+	}
+	receipts := []receiptRef{
+		{OrderID: "O-1", AmountKRW: 50000},
+		{OrderID: "O-2", AmountKRW: 30000},
+		{OrderID: "O-3", AmountKRW: 20000, Refunded: true},
+		{OrderID: "O-0", AmountKRW: 7000},
+	}
+	orders := map[string]int64{"O-1": 50000, "O-2": 33000, "O-3": 20000, "O-0": 7000}
+	src := ledgerSource{
+		order: func(id string) (*orderRecord, error) {
+			amt, ok := orders[id]
+			return &orderRecord{Found: ok, AmountKRW: amt}, nil
+		},
+		charge: func(key string) (string, int64, error) { return "", 0, nil },
+	}
+
+	lg := buildLedger("cid-2", attempts, receipts, src)
+	got := map[string]string{}
+	for _, e := range lg.Entries {
+		got[e.Attempt] = e.Status
+	}
+	want := map[string]string{
+		"ok":          ledgerMatched,
+		"diff":        ledgerAmountMismatch,
+		"back":        ledgerRefunded,
+		"no":          ledgerNotCharged,
+		"lost":        ledgerMissingOrder,
+		"receipt:O-0": ledgerMatched,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	tt := lg.Totals
+	if tt.Attempts != 5 || tt.PreviewKRW != 115000 || tt.ChargedKRW != 107000 || tt.RefundedKRW != 20000 || tt.NetKRW != 87000 || tt.Reconciled {
+		t.Fatalf("totals = %+v", tt)
+	}
+	for _, e := range lg.Entries {
+		if e.Attempt == "receipt:O-0" && (len(e.Missing) != 1 || e.Missing[0] != "preview") {
+			t.Fatalf("legacy entry missing = %v", e.Missing)
+		}
+	}
+
+	// without upstream lookups the stored artifacts still reconcile
+	lg = buildLedger("cid-2", attempts[:1], receipts[:1], ledgerSource{})
+	if e := lg.Entries[0]; e.Status != ledgerMatched || lg.Fetched || !lg.Totals.Reconciled {
+		t.Fatalf("offline: %+v fetched=%v totals=%+v", e, lg.Fetched, lg.Totals)
+	}
+}
+
+func TestLedgerHolds(t *testing.T) {
+	hold := func(id string, krw int64) *heldAuth {
+		return &heldAuth{AuthID: id, HeldKRW: krw, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	attempts := []payAttempt{
+		{ID: "held", PreviewKRW: 50000, Outcome: attemptAuthorized, Hold: hold("AUTH-1", 50000)},
+		{ID: "void", PreviewKRW: 30000, Outcome: attemptVoided, Hold: hold("AUTH-2", 30000)},
+		// captured for less than the hold
+		{ID: "part", PreviewKRW: 40000, Outcome: attemptCharged, OrderID: "O-3", Hold: hold("AUTH-3", 40000)},
+		// captured upstream, but the answer never reached Root
+		{ID: "late", PreviewKRW: 20000, Outcome: attemptAuthorized, Hold: hold("AUTH-4", 20000)},
+		{ID: "over", PreviewKRW: 10000, Outcome: attemptCharged, OrderID: "O-5", Hold: hold("AUTH-5", 10000)},
+	}
+	receipts := []receiptRef{
+		{OrderID: "O-3", AmountKRW: 30000},
+		{OrderID: "O-5", AmountKRW: 12000},
+	}
+	orders := map[string]int64{"O-3": 30000, "O-4": 20000, "O-5": 12000}
+	src := ledgerSource{
+		order: func(id string) (*orderRecord, error) {
+			amt, ok := orders[id]
+			return &orderRecord{Found: ok, AmountKRW: amt}, nil
+		},
+		auth: func(id string) (*ledgerAuth, error) {
+			switch id {
+			case "AUTH-4":
+				return &ledgerAuth{AuthID: id, Status: authCaptured, HeldKRW: 20000, CapturedKRW: 20000, OrderID: "O-4", Source: "auth"}, nil
+			case "AUTH-2":
+				return &ledgerAuth{AuthID: id, Status: authExpired, HeldKRW: 30000, Source: "auth"}, nil
+			}
+			return nil, &ledgerFetchError{"not asked"}
+		},
+	}
+
+	lg := buildLedger("cid-1", attempts, receipts, src)
+	got := map[string]string{}
+	for _, e := range lg.Entries {
+		got[e.Attempt] = e.Status
+	}
+	want := map[string]string{
+		"held": ledgerAuthorized,
+		"void": ledgerVoided,
+		"part": ledgerMatched,
+		"late": ledgerMatched,
+		"over": ledgerAmountMismatch,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	tt := lg.Totals
+	if tt.HeldKRW != 50000 || tt.Attempts != 4 || tt.ChargedKRW != 62000 || tt.Reconciled {
+		t.Fatalf("totals = %+v, want held 50000, 4 attempts, charged 62000, not reconciled", tt)
+	}
+	if tt.ByStatus[ledgerVoided] != 1 {
+		t.Fatalf("voided not counted: %v", tt.ByStatus)
+	}
+}
+
+func TestAttemptHoldLifecycle(t *testing.T) {
+	const cid = "cid-hold-lifecycle"
+	r := &RootAgent{}
+
+	r.noteAttemptSent(cid, "k1", 50000, paySlots{Item: "맥북", To: "shop", Method: "card"}, nil)
+	r.authorizeAttempt(cid, heldAuth{AuthID: "AUTH-9", HeldKRW: 50000})
+	if a := r.attemptsOf(cid); len(a) != 1 || a[0].Outcome != attemptAuthorized || a[0].Hold == nil {
+		t.Fatalf("after authorize: %+v", a)
+	}
+	// a second purchase does not touch the held one
+	r.noteAttemptSent(cid, "k2", 10000, paySlots{Method: "card"}, nil)
+	if id := r.closeAttempt(cid, attemptCharged, "O-2"); id != "k2" {
+		t.Fatalf("closeAttempt closed %q, want k2", id)
+	}
+	r.settleAttempt(cid, "AUTH-9", attemptCharged, "O-9")
+	a := r.attemptsOf(cid)
+	if a[0].Outcome != attemptCharged || a[0].OrderID != "O-9" || a[1].OrderID != "O-2" {
+		t.Fatalf("after settle: %+v", a)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/llm"
 )
 
 /* ------------------------- PAYMENT ------------------------- */
@@ -28,6 +30,9 @@ type llmPaymentExtract struct {
 		BudgetKRW int64  `json:"budgetKRW"`
 		CardLast4 string `json:"cardLast4"`
 	} `json:"fields"`
+
+	// Prov: field -> "llm" | "rule" (which extractor produced the value)
+	Prov map[string]string `json:"-"`
 }
 
 // llmExtractPayment.go (replacement)
@@ -52,12 +57,15 @@ func (r *RootAgent) llmExtractPayment(ctx context.Context, lang, text string) (*
 If "recipient" key is used, copy it to "to". Amounts are integers in KRW.`,
 		}[langOrDefault(lang)]
 
-		if out, err := r.llmClient.Chat(ctx, sys, strings.TrimSpace(text)); err == nil && strings.TrimSpace(out) != "" {
+		if out, err := r.llmClient.Chat(llm.WithPurpose(ctx, llm.PurposeExtraction), sys, strings.TrimSpace(text)); err == nil && strings.TrimSpace(out) != "" {
 			js := extractFirstJSONObject(out)
 			if js != "" {
                 // Allow recipient -> to normalization
 				js = strings.ReplaceAll(js, `"recipient"`, `"to"`)
 				_ = json.Unmarshal([]byte(js), xo)
+				for k := range payFieldValues(paySlotsFromFields(xo)) {
+					xo.markProv(k, provLLM)
+				}
 			} else {
 				r.logger.Printf("[llm][slots][warn] no json found")
 			}
@@ -70,30 +78,36 @@ If "recipient" key is used, copy it to "to". Amounts are integers in KRW.`,
 	if strings.TrimSpace(xo.Fields.Method) == "" {
 		if m := pickMethod(text); m != "" {
 			xo.Fields.Method = m
+			xo.markProv("method", provRule)
 		}
 	}
 	if strings.TrimSpace(xo.Fields.Shipping) == "" {
 		if a := pickAddress(text); a != "" {
 			xo.Fields.Shipping = a
+			xo.markProv("shipping", provRule)
 		}
 	}
 	if strings.TrimSpace(xo.Fields.To) == "" {
 		if n := pickRecipient(text); n != "" {
 			xo.Fields.To = n
+			xo.markProv("to", provRule)
 		}
 	}
 	if strings.TrimSpace(xo.Fields.Merchant) == "" {
 		if m := pickMerchant(text); m != "" {
 			xo.Fields.Merchant = m
+			xo.markProv("merchant", provRule)
 		}
 	}
 	if strings.TrimSpace(xo.Fields.Item) == "" || strings.TrimSpace(xo.Fields.Model) == "" {
 		if it, md := pickItemAndModel(text, xo.Fields.Merchant, xo.Fields.Shipping); it != "" {
 			if xo.Fields.Item == "" {
 				xo.Fields.Item = it
+				xo.markProv("item", provRule)
 			}
 			if xo.Fields.Model == "" {
 				xo.Fields.Model = md
+				xo.markProv("model", provRule)
 			}
 		}
 	}
@@ -102,8 +116,10 @@ If "recipient" key is used, copy it to "to". Amounts are integers in KRW.`,
 		if n := parseKRWFromText(text); n > 0 {
 			if looksLikeTransfer(text) {
 				xo.Fields.AmountKRW = n
+				xo.markProv("amountKRW", provRule)
 			} else {
 				xo.Fields.BudgetKRW = n
+				xo.markProv("budgetKRW", provRule)
 			}
 		}
 	}
@@ -114,6 +130,7 @@ If "recipient" key is used, copy it to "to". Amounts are integers in KRW.`,
 		if xo.Fields.Mode == "" {
 			xo.Fields.Mode = "buy"
 		}
+		xo.markProv("mode", provRule)
 	}
 
     // Fail if nothing extracted
@@ -128,6 +145,23 @@ If "recipient" key is used, copy it to "to". Amounts are integers in KRW.`,
 	return xo, true
 }
 
+func (xo *llmPaymentExtract) markProv(field, src string) {
+	if xo.Prov == nil {
+		xo.Prov = map[string]string{}
+	}
+	xo.Prov[field] = src
+}
+
+// paySlotsFromFields copies the extracted values (no provenance).
+func paySlotsFromFields(xo *llmPaymentExtract) paySlots {
+	return paySlots{
+		Mode: xo.Fields.Mode, To: xo.Fields.To,
+		AmountKRW: xo.Fields.AmountKRW, BudgetKRW: xo.Fields.BudgetKRW,
+		Method: xo.Fields.Method, Item: xo.Fields.Item, Model: xo.Fields.Model,
+		Merchant: xo.Fields.Merchant, Shipping: xo.Fields.Shipping, CardLast4: xo.Fields.CardLast4,
+	}
+}
+
 /* ------------------------- MEDICAL ------------------------- */
 
 // llmExtractMedical: extract medicalSlots from input and generate a one-sentence ask if needed
@@ -165,7 +199,7 @@ func (r *RootAgent) llmExtractMedical(ctx context.Context, lang, text string) (m
 If informational query (diet/exercise/management), "topic" should reflect that. Ask is ONE sentence.`,
 	}[langOrDefault(lang)]
 
-	out, err := r.llmClient.Chat(ctx, sys, strings.TrimSpace(text))
+	out, err := r.llmClient.Chat(llm.WithPurpose(ctx, llm.PurposeExtraction), sys, strings.TrimSpace(text))
 	if err != nil || strings.TrimSpace(out) == "" {
 		return zero, false
 	}
@@ -416,32 +450,6 @@ func normalizeMethod(m string) string {
 	}
 }
 
-// Examples: 150 x 10k KRW (1.5M), 1.5 x 10k KRW, 150,000 KRW → integer KRW
-var reKRW = regexp.MustCompile(`(?i)(\d[\d,\.]*)\s*(만원|만|원|krw)`)
-
-func parseKRW(s string) int64 {
-	m := reKRW.FindStringSubmatch(strings.ReplaceAll(s, " ", ""))
-	if len(m) < 3 {
-		return 0
-	}
-	num := strings.ReplaceAll(m[1], ",", "")
-	factor := int64(1)
-	unit := strings.ToLower(m[2])
-	if unit == "만원" || unit == "만" {
-		factor = 10000
-	}
-    // Support decimals (e.g., 1.5 x 10k KRW)
-	if strings.Contains(num, ".") {
-		if f, err := strconv.ParseFloat(num, 64); err == nil {
-			return int64(f * float64(factor))
-		}
-	}
-	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
-		return n * factor
-	}
-	return 0
-}
-
 var knownMerchants = []string{"쿠팡", "네이버", "11번가", "지마켓", "G마켓", "옥션", "위메프", "SSG", "이마트", "하이마트", "애플스토어", "Apple Store"}
 
 func inferMerchant(s string) string {
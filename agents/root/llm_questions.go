@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
 	"github.com/sage-x-project/sage-multi-agent/llm"
 )
 
@@ -59,7 +60,7 @@ func (r *RootAgent) askForMissingPaymentWithLLM(
 		known = append(known, kv{"recipient", s.To})
 	}
 	if s.BudgetKRW > 0 {
-		known = append(known, kv{"budgetKRW", formatKRW(s.BudgetKRW)})
+		known = append(known, kv{"budgetKRW", money.Group(s.BudgetKRW)})
 	}
 
 	sys := map[string]string{
@@ -113,7 +114,7 @@ Rules:
 
 	out := ""
 	if r.llmClient != nil {
-		if s, err := r.llmClient.Chat(ctx, sys, b.String()); err == nil {
+		if s, err := r.chatUser(ctx, lang, sys, b.String()); err == nil {
 			out = s
 		} else {
 			log.Println("[llm][err]", err)
@@ -147,7 +148,7 @@ func (r *RootAgent) llmConfirmIntent(ctx context.Context, lang, user string) str
 		"ko": "분류기: 아래 입력에 대해 '예' 또는 '아니오' 또는 '애매' 중 하나만 정확히 출력해.",
 		"en": "Classifier: output exactly one of yes/no/unclear for the input below.",
 	}[lang]
-	out, err := r.llmClient.Chat(ctx, sys, strings.TrimSpace(user))
+	out, err := r.llmClient.Chat(llm.WithPurpose(ctx, llm.PurposeExtraction), sys, strings.TrimSpace(user))
 	if err != nil {
 		return "unclear"
 	}
@@ -183,7 +184,7 @@ func (r *RootAgent) askForMissingMedicalWithLLM(ctx context.Context, lang string
 	}[langOrDefault(lang)]
 	usr := "Missing=" + strings.Join(missing, ", ") + "\nUserText=" + strings.TrimSpace(userText)
 
-	out, err := r.llmClient.Chat(ctx, sys, usr)
+	out, err := r.chatUser(ctx, lang, sys, usr)
 	if err != nil || strings.TrimSpace(out) == "" {
 		if langOrDefault(lang) == "ko" {
 			return "의료 정보를 제공하려면 " + strings.Join(missing, ", ") + "를 알려주세요."
@@ -220,7 +221,7 @@ func (r *RootAgent) askForMissingPlanningWithLLM(ctx context.Context, lang strin
 		lang, strings.Join(missing, ", "), strings.TrimSpace(userText),
 	)
 
-	if out, err := r.llmClient.Chat(ctx, sys, usr); err == nil && strings.TrimSpace(out) != "" {
+	if out, err := r.chatUser(ctx, lang, sys, usr); err == nil && strings.TrimSpace(out) != "" {
 		return strings.TrimSpace(out)
 	}
 	return fallback
@@ -251,7 +252,7 @@ func (r *RootAgent) llmMedicalAnswer(ctx context.Context, lang string, userText
 		lang, s.Condition, s.Topic, s.Audience, s.Duration, s.Age, s.Medications, strings.TrimSpace(userText),
 	)
 
-	out, err := r.llmClient.Chat(ctx, sys, usr)
+	out, err := r.chatUser(ctx, lang, sys, usr)
 	if err != nil || strings.TrimSpace(out) == "" {
 		if lang == "ko" {
 			return "의료 정보 제공용 답변을 생성하지 못했어요. 증상이 지속되거나 심해지면 의료진과 상의하세요."
@@ -271,22 +272,23 @@ func GeneratePaymentReceipt(ctx context.Context, c llm.Client, lang, to string,
 		"ko": "당신은 결제 에이전트입니다. 한 줄로 자연스럽게 결제 완료 문장을 출력하세요. 금액은 천단위 콤마, 수신자/결제수단이 있으면 포함, 간단한 가짜 주문번호(예: ORD-5F3A)를 넣으세요. 코드블록 금지.",
 	}[lang]
 
+	// Amount is pre-rendered for the display locale; the LLM must copy it verbatim
+	amt := money.Display(lang, amountKRW, money.KRW)
 	usr := fmt.Sprintf(
-		"Recipient: %s\nAmount (KRW): %d\nMethod: %s\nItem: %s\nMemo: %s\nStyle: concise, friendly.",
-		strings.TrimSpace(to), amountKRW, strings.TrimSpace(method), strings.TrimSpace(item), strings.TrimSpace(memo),
+		"Recipient: %s\nAmount (copy exactly): %s\nMethod: %s\nItem: %s\nMemo: %s\nStyle: concise, friendly.",
+		strings.TrimSpace(to), amt, strings.TrimSpace(method), strings.TrimSpace(item), strings.TrimSpace(memo),
 	)
 
 	if c != nil {
-		if out, err := c.Chat(ctx, sys, usr); err == nil && strings.TrimSpace(out) != "" {
+		if out, err := llm.ChatLang(ctx, c, langOrDefault(lang), sys, usr); err == nil && strings.TrimSpace(out) != "" {
 			return strings.TrimSpace(out)
 		}
 	}
 
 	// Fallback if LLM disabled/unavailable
-	amt := formatKRW(amountKRW)
 	orderID := fmt.Sprintf("ORD-%04d", time.Now().Unix()%10000)
 	if lang == "en" {
-		parts := []string{"Payment completed", fmt.Sprintf("%s KRW", amt)}
+		parts := []string{"Payment completed", amt}
 		if strings.TrimSpace(item) != "" {
 			parts = append(parts, fmt.Sprintf("(item: %s)", strings.TrimSpace(item)))
 		}
@@ -302,7 +304,7 @@ func GeneratePaymentReceipt(ctx context.Context, c llm.Client, lang, to string,
 		parts = append(parts, fmt.Sprintf("[%s]", orderID))
 		return strings.Join(parts, " ") + " ✅"
 	}
-	parts := []string{"결제가 완료되었습니다", fmt.Sprintf("%s원", amt)}
+	parts := []string{"결제가 완료되었습니다", amt}
 	if strings.TrimSpace(item) != "" {
 		parts = append(parts, fmt.Sprintf("(상품: %s)", strings.TrimSpace(item)))
 	}
@@ -319,24 +321,6 @@ func GeneratePaymentReceipt(ctx context.Context, c llm.Client, lang, to string,
 	return strings.Join(parts, " ") + " ✅"
 }
 
-// formatKRW formats an integer in KRW with thousand separators.
-func formatKRW(n int64) string {
-	s := fmt.Sprintf("%d", n)
-	neg := ""
-	if n < 0 {
-		neg = "-"
-		s = s[1:]
-	}
-	out := make([]byte, 0, len(s)+len(s)/3)
-	for i, c := range s {
-		if i > 0 && (len(s)-i)%3 == 0 {
-			out = append(out, ',')
-		}
-		out = append(out, byte(c))
-	}
-	return neg + string(out)
-}
-
 func compact(s string, limit int) string {
 	s = strings.TrimSpace(s)
 	if len([]rune(s)) <= limit {
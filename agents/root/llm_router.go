@@ -12,8 +12,9 @@ import (
 )
 
 type routeOut struct {
-	Domain string // "payment" | "medical" | "planning" | ""
-	Lang   string // "ko" | "en"
+	Domain     string // "payment" | "medical" | "planning" | any advertised target | ""
+	Lang       string // "ko" | "en"
+	PromptHash string // set when the LLM decided (routing_hints.go)
 }
 
 var routerJSONRe = regexp.MustCompile(`(?s)\{.*\}`)
@@ -38,12 +39,11 @@ func (r *RootAgent) llmRoute(ctx context.Context, text string) (routeOut, bool)
 		return routeOut{}, false
 	}
 
-	sys := `You are an intent classifier.
-Return a single JSON object with fields: domain in ["payment","medical","planning","chat"], lang in ["ko","en"].
-Pick the most likely domain.`
+	// Domains come from the healthy, configured targets and their hints (routing_hints.go)
+	rp := r.routePrompt()
 	pr := map[string]any{"text": text}
 	jb, _ := json.Marshal(pr)
-	out, err := r.llmClient.Chat(ctx, sys, string(jb))
+	out, err := r.llmClient.Chat(llm.WithPurpose(ctx, llm.PurposeRouting), rp.Text, string(jb))
 	if err != nil || strings.TrimSpace(out) == "" {
 		return routeOut{}, false
 	}
@@ -58,8 +58,8 @@ Pick the most likely domain.`
 	if lg != "ko" && lg != "en" {
 		lg = llm.DetectLang(text)
 	}
-	if m.Domain == "payment" || m.Domain == "medical" || m.Domain == "planning" || m.Domain == "" {
-		return routeOut{Domain: m.Domain, Lang: lg}, true
+	if m.Domain == "" || rp.has(m.Domain) {
+		return routeOut{Domain: m.Domain, Lang: lg, PromptHash: rp.Hash}, true
 	}
 	return routeOut{}, false
 }
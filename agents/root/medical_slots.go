@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"strings"
 
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 )
 
 // Unified medical slots (single source of truth).
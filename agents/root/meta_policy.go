@@ -0,0 +1,238 @@
+// Package root - inbound metadata policy.
+//
+// Client-supplied msg.Metadata is filtered on /process before routing or any
+// flow reads it. Only allowlisted keys (domain, lang, scenario and the
+// structured payment/medical/planning fast-path keys) are accepted, each with
+// a type check; values are bounded in size and nesting depth. Keys Root sets
+// itself (run, compare, payment.op, payment.provenance, …) and the reserved
+// "root." namespace are never accepted from a client.
+//
+// ROOT_META_POLICY selects what happens to offending keys:
+//   - warn:   log them and keep them (reserved keys are still stripped)
+//   - strip:  log, note and remove them (default)
+//   - reject: 400 {"error":"metadata_rejected"}
+//
+// Stripped keys are reported in the X-Root-Metadata-Stripped response header
+// and as metadata "metadataStripped" on upstream results.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// rootMetaNamespace prefixes keys only Root may set.
+const rootMetaNamespace = "root."
+
+const ctxMetaStrippedKey ctxKey = "metaStripped"
+
+type metaKind int
+
+const (
+	metaString metaKind = iota
+	metaNumber          // JSON number or numeric string
+	metaBool
+)
+
+// clientMetaKeys is the allowlist of client-settable metadata keys.
+var clientMetaKeys = map[string]metaKind{
+	"domain": metaString, "lang": metaString, "scenario": metaString,
+	"sageEnabled": metaBool, "hpkeEnabled": metaBool,
+	"allowTruncation": metaBool, "truncatedChars": metaNumber,
+	"timezone": metaString,
+
+	// payment fast path (extractPaymentSlots)
+	"payment.mode": metaString, "payment.to": metaString, "to": metaString, "recipient": metaString,
+	"payment.method": metaString, "method": metaString,
+	"payment.item": metaString, "item": metaString, "제품": metaString, "상품": metaString,
+	"payment.model": metaString, "model": metaString, "옵션": metaString,
+	"payment.merchant": metaString, "merchant": metaString, "store": metaString,
+	"payment.shipping": metaString, "shipping": metaString,
+	"payment.cardLast4": metaString, "cardLast4": metaString,
+	"payment.note": metaString, "note": metaString, "memo": metaString,
+	"payment.amountKRW": metaNumber, "amountKRW": metaNumber, "amount": metaNumber,
+	"payment.budgetKRW": metaNumber, "budgetKRW": metaNumber, "budget": metaNumber,
+
+	// medical fast path (extractMedicalCore)
+	"medical.condition": metaString, "condition": metaString,
+	"medical.symptoms": metaString, "symptoms": metaString,
+
+	// planning fast path
+	"planning.task": metaString, "task": metaString, "goal": metaString,
+	"planning.timeframe": metaString, "timeframe": metaString,
+	"planning.context": metaString, "context": metaString,
+}
+
+// systemMetaKeys are set by Root (or its transport) later in the pipeline; a
+// client copy would be a spoof and is dropped in every mode.
+var systemMetaKeys = map[string]bool{
+	"run": true, "compare": true, "styleSeed": true, "hpke_kid": true,
+	"payment.op": true, "payment.idempotencyKey": true, "payment.orderId": true, "payment.refundKRW": true, "payment.dryRun": true,
+	"payment.flow": true, "payment.authId": true, "payment.captureKRW": true,
+	"payment.provenance": true, "payment.estimatedKRW": true, "payment.quotedKRW": true, "payment.amountIsEstimated": true,
+	"medical.provenance": true, "planning.provenance": true, "medical.history": true, "medical.history_len": true,
+	"overflow.url": true,
+}
+
+func isSystemMetaKey(k string) bool {
+	return systemMetaKeys[k] || strings.HasPrefix(k, rootMetaNamespace) || strings.HasSuffix(k, "_ref")
+}
+
+// metaPolicyMode: ROOT_META_POLICY = warn | strip | reject (default strip).
+func metaPolicyMode() string {
+	switch m := strings.ToLower(strings.TrimSpace(os.Getenv("ROOT_META_POLICY"))); m {
+	case "warn", "reject":
+		return m
+	}
+	return "strip"
+}
+
+type metaViolation struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"` // "system" | "not_allowed" | "type" | "size" | "depth" | "value"
+}
+
+func metaValueBytes(v any) int {
+	if s, ok := v.(string); ok {
+		return len(s)
+	}
+	b, _ := json.Marshal(v)
+	return len(b)
+}
+
+func metaDepth(v any) int {
+	switch t := v.(type) {
+	case map[string]any:
+		d := 0
+		for _, x := range t {
+			if n := metaDepth(x); n > d {
+				d = n
+			}
+		}
+		return d + 1
+	case []any:
+		d := 0
+		for _, x := range t {
+			if n := metaDepth(x); n > d {
+				d = n
+			}
+		}
+		return d + 1
+	}
+	return 0
+}
+
+func metaTypeOK(kind metaKind, v any) bool {
+	switch kind {
+	case metaNumber:
+		switch t := v.(type) {
+		case float64, int, int64:
+			return true
+		case string:
+			_, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(t), ",", ""), 10, 64)
+			return err == nil
+		}
+		return false
+	case metaBool:
+		_, ok := v.(bool)
+		return ok
+	}
+	_, ok := v.(string)
+	return ok
+}
+
+// checkInboundMeta lists the policy violations in meta (sorted by key).
+func checkInboundMeta(meta map[string]any) []metaViolation {
+	maxBytes := envInt("ROOT_META_MAX_VALUE_BYTES", 4096)
+	maxDepth := envInt("ROOT_META_MAX_DEPTH", 2)
+	var out []metaViolation
+	for k, v := range meta {
+		reason := ""
+		kind, allowed := clientMetaKeys[k]
+		switch {
+		case isSystemMetaKey(k):
+			reason = "system"
+		case !allowed:
+			reason = "not_allowed"
+		case metaDepth(v) > maxDepth:
+			reason = "depth"
+		case metaValueBytes(v) > maxBytes:
+			reason = "size"
+		case !metaTypeOK(kind, v):
+			reason = "type"
+		case k == "domain":
+			switch strings.ToLower(strings.TrimSpace(v.(string))) {
+			case "payment", "medical", "planning", "chat":
+			default:
+				reason = "value"
+			}
+		}
+		if reason != "" {
+			out = append(out, metaViolation{Key: k, Reason: reason})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// applyMetaPolicy filters msg.Metadata in place. It returns the keys removed
+// and false when the request was rejected (the 400 is already written).
+func (r *RootAgent) applyMetaPolicy(w http.ResponseWriter, msg *types.AgentMessage) ([]string, bool) {
+	if len(msg.Metadata) == 0 {
+		return nil, true
+	}
+	vs := checkInboundMeta(msg.Metadata)
+	if len(vs) == 0 {
+		return nil, true
+	}
+	mode := metaPolicyMode()
+	r.logger.Printf("[root][meta][%s] inbound metadata violations: %v", mode, vs)
+	if mode == "reject" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":      "metadata_rejected",
+			"message":    "metadata contains keys or values that are not accepted",
+			"violations": vs,
+		})
+		return nil, false
+	}
+	var stripped []string
+	for _, v := range vs {
+		if mode == "warn" && v.Reason != "system" {
+			continue
+		}
+		delete(msg.Metadata, v.Key)
+		stripped = append(stripped, v.Key)
+	}
+	if len(stripped) > 0 {
+		w.Header().Set("X-Root-Metadata-Stripped", strings.Join(stripped, ","))
+	}
+	return stripped, true
+}
+
+func withMetaStripped(ctx context.Context, keys []string) context.Context {
+	if len(keys) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxMetaStrippedKey, keys)
+}
+
+// noteMetaStripped adds the stripped inbound keys to an upstream result.
+func noteMetaStripped(req *http.Request, out *types.AgentMessage) {
+	keys, _ := req.Context().Value(ctxMetaStrippedKey).([]string)
+	if len(keys) == 0 {
+		return
+	}
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	out.Metadata["metadataStripped"] = keys
+}
@@ -0,0 +1,115 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestCheckInboundMeta(t *testing.T) {
+	t.Setenv("ROOT_META_MAX_VALUE_BYTES", "64")
+	t.Setenv("ROOT_META_MAX_DEPTH", "2")
+	cases := []struct {
+		name   string
+		key    string
+		value  any
+		reason string // "" = accepted
+	}{
+		{"allowlisted string", "payment.to", "김철수", ""},
+		{"numeric string", "payment.amountKRW", "1,250,000", ""},
+		{"number", "budgetKRW", float64(50000), ""},
+		{"bool", "sageEnabled", true, ""},
+		{"domain", "domain", "Payment", ""},
+		{"unknown key", "debug", "1", "not_allowed"},
+		{"wrong type", "sageEnabled", "true", "type"},
+		{"not a number", "payment.amountKRW", "lots", "type"},
+		{"object for a string", "lang", map[string]any{"a": "b"}, "type"},
+		{"too deep", "lang", map[string]any{"a": map[string]any{"b": []any{1}}}, "depth"},
+		{"too large", "payment.note", strings.Repeat("x", 65), "size"},
+		{"unknown domain", "domain", "admin", "value"},
+		{"system key", "payment.op", "refund", "system"},
+		{"idempotency key", "payment.idempotencyKey", "k", "system"},
+		{"root namespace", "root.trace", "x", "system"},
+		{"reference", "medical.history_ref", "x", "system"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			vs := checkInboundMeta(map[string]any{tc.key: tc.value})
+			var got string
+			if len(vs) == 1 {
+				got = vs[0].Reason
+			}
+			if len(vs) > 1 || got != tc.reason {
+				t.Fatalf("violations %+v, want %q", vs, tc.reason)
+			}
+		})
+	}
+}
+
+func TestMetaPolicyModes(t *testing.T) {
+	meta := func() map[string]any {
+		return map[string]any{"lang": "en", "payment.op": "refund", "debug": "1", "payment.to": "Alice"}
+	}
+	cases := []struct {
+		mode     string
+		accepted bool
+		stripped []string
+		kept     []string
+	}{
+		{"warn", true, []string{"payment.op"}, []string{"debug", "lang", "payment.to"}},
+		{"strip", true, []string{"debug", "payment.op"}, []string{"lang", "payment.to"}},
+		{"", true, []string{"debug", "payment.op"}, []string{"lang", "payment.to"}},
+		{"reject", false, nil, nil},
+	}
+	for _, tc := range cases {
+		t.Run("mode="+tc.mode, func(t *testing.T) {
+			t.Setenv("ROOT_META_POLICY", tc.mode)
+			r := statusRoot(t)
+			msg := &types.AgentMessage{Metadata: meta()}
+			w := httptest.NewRecorder()
+			stripped, ok := r.applyMetaPolicy(w, msg)
+			if ok != tc.accepted || !reflect.DeepEqual(stripped, tc.stripped) {
+				t.Fatalf("accepted=%v stripped=%v", ok, stripped)
+			}
+			if !ok {
+				var body struct {
+					Error      string          `json:"error"`
+					Violations []metaViolation `json:"violations"`
+				}
+				_ = json.Unmarshal(w.Body.Bytes(), &body)
+				if w.Code != http.StatusBadRequest || body.Error != "metadata_rejected" || len(body.Violations) != 2 {
+					t.Fatalf("reject: %d %s", w.Code, w.Body)
+				}
+				return
+			}
+			if got := w.Header().Get("X-Root-Metadata-Stripped"); got != strings.Join(tc.stripped, ",") {
+				t.Fatalf("header %q", got)
+			}
+			for _, k := range tc.kept {
+				if _, ok := msg.Metadata[k]; !ok {
+					t.Fatalf("%s removed", k)
+				}
+			}
+			if len(msg.Metadata) != len(tc.kept) {
+				t.Fatalf("metadata %v", msg.Metadata)
+			}
+		})
+	}
+}
+
+// A spoofed system key never reaches the flows: reject mode refuses it at /process.
+func TestMetaPolicyProcess(t *testing.T) {
+	t.Setenv("ROOT_META_POLICY", "reject")
+	r := statusRoot(t)
+	body, _ := json.Marshal(types.AgentMessage{ID: "m1", From: "user", Content: "refund", Metadata: map[string]any{"payment.op": "refund", "payment.orderId": "ORD-1"}})
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(string(body))))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"metadata_rejected"`) {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+}
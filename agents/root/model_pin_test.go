@@ -0,0 +1,42 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/llm"
+)
+
+// With a strict pin, a swapped model's extraction is discarded: the turn goes
+// on with the rule-based slots and says an unpinned model answered.
+func TestModelPinStrictFallsBack(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model":   "qwen2.5:7b",
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": `{"fields":{"item":"아이패드","method":"card"}}`}}},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("LLM_MODEL_PIN", "gemma3:4b")
+	t.Setenv("LLM_MODEL_PIN_STRICT", "true")
+	env := newForkEnv(t, 0)
+	env.r.llmClient = &llm.OpenAIClient{BaseURL: srv.URL + "/v1", Model: "gemma3:4b", HTTP: srv.Client()}
+
+	out := env.send(t, "c1", "맥북 사줘")
+	if calls == 0 || out.Metadata["llmModelMismatch"] != true || out.Metadata["domain"] != "payment" {
+		t.Fatalf("%d LLM calls, %+v", calls, out.Metadata)
+	}
+	if s := env.r.getPayCtx("c1"); s.Item == "아이패드" || s.Method != "" {
+		t.Fatalf("discarded answer was used: %+v", s)
+	}
+
+	t.Setenv("LLM_MODEL_PIN", "qwen2.5:7b")
+	if out := env.send(t, "c2", "맥북 사줘"); out.Metadata["llmModelMismatch"] != nil {
+		t.Fatalf("matching pin still flagged: %+v", out.Metadata)
+	}
+}
@@ -0,0 +1,368 @@
+// Package root - upstream needs-input broker.
+//
+// An external agent that needs more information mid-processing answers with
+// Type "clarify" and a structured metadata.needsInput block:
+//
+//	{"requestedFields": [{"name": "payment.cardLast4", "type": "digits", "length": 4, "reason": "…"}],
+//	 "resumeToken": "…"}
+//
+// Root parks the forwarded message and its target per conversation, relays the
+// agent's question to the user, and on the answer re-sends the original
+// payload with each answered field set as a metadata key (the field name) plus
+// needsInput.resumeToken and needsInput.round so the agent can correlate. An
+// agent may ask at most ROOT_UPSTREAM_CLARIFY_MAX (default 2) times per
+// request; a further ask ends the request. "취소"/"cancel" drops the pending
+// request and leaves the payment slots for editing.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// inputField is one field an upstream agent asked for.
+type inputField struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"` // "digits" | "number" | "string"
+	Length int    `json:"length,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// upstreamAsk is a parked upstream request waiting for the user's answer.
+type upstreamAsk struct {
+	Target      string
+	Msg         types.AgentMessage // payload as forwarded (metadata cloned)
+	Fields      []inputField
+	ResumeToken string
+	Question    string
+	Rounds      int    // upstream clarifications so far for this request
+	ClaimedFrom string // payment stage to restore when the request fails
+	SageRaw     string // per-request X-SAGE-Enabled / X-HPKE-Enabled of the original send
+	HPKERaw     string
+	At          time.Time
+}
+
+var (
+	digitRunRe  = regexp.MustCompile(`\d+`)
+	numberRunRe = regexp.MustCompile(`\d[\d,]*`)
+)
+
+// upstreamClarifyMax: ROOT_UPSTREAM_CLARIFY_MAX (default 2).
+func upstreamClarifyMax() int { return envInt("ROOT_UPSTREAM_CLARIFY_MAX", 2) }
+
+// needsInputOf returns the requested fields and resume token of a needs-input
+// clarify response.
+func needsInputOf(out *types.AgentMessage) ([]inputField, string, bool) {
+	if out == nil || !strings.EqualFold(out.Type, "clarify") || out.Metadata == nil {
+		return nil, "", false
+	}
+	raw, ok := out.Metadata["needsInput"]
+	if !ok {
+		return nil, "", false
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, "", false
+	}
+	var ni struct {
+		RequestedFields []inputField `json:"requestedFields"`
+		ResumeToken     string       `json:"resumeToken"`
+	}
+	if json.Unmarshal(b, &ni) != nil {
+		return nil, "", false
+	}
+	fields := ni.RequestedFields[:0]
+	for _, f := range ni.RequestedFields {
+		if f.Name = strings.TrimSpace(f.Name); f.Name != "" {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, "", false
+	}
+	return fields, strings.TrimSpace(ni.ResumeToken), true
+}
+
+// parkUpstreamAsk handles an upstream needs-input answer: within the loop
+// bound it stores ask (Target/Msg/Rounds/ClaimedFrom/headers set by the caller)
+// and relays the question; past it, it ends the request. Returns false when
+// out is not a needs-input response.
+func (r *RootAgent) parkUpstreamAsk(w http.ResponseWriter, req *http.Request, user *types.AgentMessage, cid, lang string, out *types.AgentMessage, ask *upstreamAsk) bool {
+	fields, tok, ok := needsInputOf(out)
+	if !ok {
+		return false
+	}
+	if ask.Rounds >= upstreamClarifyMax() {
+		r.logger.Printf("[root][needs-input] cid=%s target=%s asked again after %d rounds; giving up", cid, ask.Target, ask.Rounds)
+		r.upstreamAsks.Delete(cid)
+		if ask.Target == "payment" {
+			r.releasePayToken(cid, ask.ClaimedFrom)
+		}
+		r.audit.Emit(audit.Event{
+			Type: "conversation", Action: "upstream.needs_input", Outcome: "failure", Actor: requesterOf(req), Target: ask.Target,
+			CID: cid, Detail: map[string]any{"rounds": ask.Rounds, "reason": "loop_bound"},
+		})
+		res := types.AgentMessage{
+			ID: user.ID + "-needinput", ContextID: cid, From: "root", To: user.From, Type: "error",
+			Content: map[string]string{
+				"ko": "에이전트가 추가 정보를 계속 요청해서 요청을 중단했어요. 다시 확인하거나 내용을 바꿔 주세요.",
+				"en": "The agent kept asking for more information, so I stopped this request. Confirm again or change the details.",
+			}[langOrDefault(lang)],
+			Timestamp: time.Now(),
+			Metadata: map[string]any{
+				"lang": lang, "domain": ask.Target,
+				"error": map[string]any{"code": "upstream_clarify_limit"}, "rounds": ask.Rounds,
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		_ = json.NewEncoder(w).Encode(res)
+		return true
+	}
+
+	if tok == "" {
+		tok = uuid.NewString()
+	}
+	ask.Msg.Metadata = maps.Clone(ask.Msg.Metadata)
+	ask.Fields, ask.ResumeToken, ask.At = fields, tok, time.Now()
+	ask.Rounds++
+	ask.Question = strings.TrimSpace(out.Content)
+	if ask.Question == "" {
+		ask.Question = fieldsPrompt(lang, fields)
+	}
+	r.upstreamAsks.Store(cid, ask)
+	if ask.Target == "payment" {
+		r.releasePayToken(cid, "await_upstream")
+	}
+	r.audit.Emit(audit.Event{
+		Type: "conversation", Action: "upstream.needs_input", Outcome: "pending", Actor: requesterOf(req), Target: ask.Target,
+		CID: cid, Detail: map[string]any{"fields": fieldNames(fields), "round": ask.Rounds},
+	})
+	r.logger.Printf("[root][needs-input] cid=%s target=%s round=%d fields=%v", cid, ask.Target, ask.Rounds, fieldNames(fields))
+	r.writeUpstreamQuestion(w, user, cid, lang, ask, "")
+	return true
+}
+
+// writeUpstreamQuestion relays the parked question (with an optional hint line).
+func (r *RootAgent) writeUpstreamQuestion(w http.ResponseWriter, user *types.AgentMessage, cid, lang string, ask *upstreamAsk, hint string) {
+	content := ask.Question
+	if hint != "" {
+		content = hint + "\n" + content
+	}
+	out := types.AgentMessage{
+		ID: user.ID + "-needinput", ContextID: cid, From: "root", To: user.From, Type: "clarify",
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"await": "upstream.input", "lang": lang, "domain": ask.Target,
+			"requestedFields": ask.Fields, "round": ask.Rounds,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// handleUpstreamAnswer resumes a parked upstream request with the user's answer.
+// Returns false when nothing is pending for cid.
+func (r *RootAgent) handleUpstreamAnswer(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, text, cid, lang string) bool {
+	v, ok := r.upstreamAsks.Load(cid)
+	if !ok {
+		return false
+	}
+	ask := v.(*upstreamAsk)
+
+	if containsAny(strings.ToLower(text), "취소", "그만", "cancel", "stop") {
+		r.upstreamAsks.Delete(cid)
+		if ask.Target == "payment" {
+			r.putPayCtxFull(cid, r.getPayCtx(cid), "collect", "")
+		}
+		r.logger.Printf("[root][needs-input] cid=%s cancelled by user", cid)
+		out := types.AgentMessage{
+			ID: msg.ID + "-cancel", ContextID: cid, From: "root", To: msg.From, Type: "response",
+			Content: map[string]string{
+				"ko": "요청을 취소했어요. 무엇을 바꿀까요?",
+				"en": "Cancelled the request. What should I change?",
+			}[langOrDefault(lang)],
+			Timestamp: time.Now(),
+			Metadata:  map[string]any{"lang": lang, "domain": ask.Target, "cancelled": true},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+		return true
+	}
+
+	vals, missing := parseInputAnswers(ask.Fields, text)
+	if len(missing) > 0 {
+		hint := map[string]string{
+			"ko": "답변에서 필요한 값을 찾지 못했어요: ",
+			"en": "I couldn't find the requested value in your answer: ",
+		}[langOrDefault(lang)] + fieldsPrompt(lang, missing)
+		r.writeUpstreamQuestion(w, msg, cid, lang, ask, hint)
+		return true
+	}
+
+	// Payment: await_upstream -> sending (a racing second answer must not double-charge)
+	if ask.Target == "payment" {
+		_, tok := r.getStageToken(cid)
+		if !r.claimPayToken(cid, "await_upstream", tok) {
+			writePaymentInFlight(w, msg, cid, lang)
+			return true
+		}
+	}
+	r.upstreamAsks.Delete(cid)
+
+	resumed := ask.Msg
+	resumed.ID = fmt.Sprintf("%s-resume%d", ask.Msg.ID, ask.Rounds)
+	resumed.Timestamp = time.Now()
+	resumed.Metadata = maps.Clone(ask.Msg.Metadata)
+	if resumed.Metadata == nil {
+		resumed.Metadata = map[string]any{}
+	}
+	for k, val := range vals {
+		resumed.Metadata[k] = val
+	}
+	resumed.Metadata["needsInput.resumeToken"] = ask.ResumeToken
+	resumed.Metadata["needsInput.round"] = ask.Rounds
+
+	ctx := req.Context()
+	sageRaw := firstNonEmpty(strings.TrimSpace(req.Header.Get("X-SAGE-Enabled")), ask.SageRaw)
+	hpkeRaw := firstNonEmpty(strings.TrimSpace(req.Header.Get("X-HPKE-Enabled")), ask.HPKERaw)
+	if sageRaw != "" {
+		ctx = context.WithValue(ctx, ctxUseSAGEKey, strings.EqualFold(sageRaw, "true"))
+	}
+	if hpkeRaw != "" {
+		ctx = context.WithValue(ctx, ctxHPKERawKey, hpkeRaw)
+	}
+
+	r.logger.Printf("[root][needs-input] cid=%s resume target=%s round=%d fields=%v", cid, ask.Target, ask.Rounds, fieldNames(ask.Fields))
+	outPtr, err := r.sendWithSLA(ctx, ask.Target, opProcess, &resumed)
+	if err != nil {
+		r.logger.Printf("[root][needs-input][error] cid=%s %v", cid, err)
+		var he *hpkeResponseError
+		if ask.Target == "payment" && errors.As(err, &he) {
+			key, _ := resumed.Metadata["payment.idempotencyKey"].(string)
+			if r.writeAmbiguousPayment(w, req, cid, lang, "await_upstream", firstNonEmpty(key, r.payIdempotencyKey(cid)), he) != payExecuted {
+				r.upstreamAsks.Store(cid, ask)
+			}
+			return true
+		}
+		// Keep the question open so the user can answer again
+		r.upstreamAsks.Store(cid, ask)
+		if ask.Target == "payment" {
+			r.releasePayToken(cid, "await_upstream")
+		}
+		r.writeSendError(w, req, lang, ask.Target, err)
+		return true
+	}
+	next := &upstreamAsk{
+		Target: ask.Target, Msg: resumed, Rounds: ask.Rounds, ClaimedFrom: ask.ClaimedFrom,
+		SageRaw: ask.SageRaw, HPKERaw: ask.HPKERaw,
+	}
+	if r.parkUpstreamAsk(w, req, msg, cid, lang, outPtr, next) {
+		return true
+	}
+	r.audit.Emit(audit.Event{
+		Type: "conversation", Action: "upstream.needs_input", Outcome: "success", Actor: requesterOf(req), Target: ask.Target,
+		CID: cid, Detail: map[string]any{"rounds": ask.Rounds},
+	})
+	if ask.Target == "payment" {
+		r.writePaymentResult(w, req, cid, lang, ask.ClaimedFrom, *outPtr)
+		return true
+	}
+	out := *outPtr
+	status := http.StatusOK
+	if code, ok := httpStatusFromAgent(&out); ok {
+		status = code
+	}
+	r.presentOut(req, lang, ask.Target, &out, status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+	return true
+}
+
+// parseInputAnswers pulls each requested field out of the user's free-text
+// answer. Digit/number fields consume matches in order; a single string field
+// takes the whole answer.
+func parseInputAnswers(fields []inputField, text string) (map[string]any, []inputField) {
+	text = strings.TrimSpace(text)
+	vals := map[string]any{}
+	var missing []inputField
+	used := map[int]bool{}
+	digits := digitRunRe.FindAllStringIndex(text, -1)
+	for _, f := range fields {
+		switch strings.ToLower(f.Type) {
+		case "digits":
+			found := false
+			for i, loc := range digits {
+				run := text[loc[0]:loc[1]]
+				if used[i] || (f.Length > 0 && len(run) != f.Length) {
+					continue
+				}
+				used[i], found = true, true
+				vals[f.Name] = run
+				break
+			}
+			if !found {
+				missing = append(missing, f)
+			}
+		case "number":
+			run := numberRunRe.FindString(text)
+			n, err := strconv.ParseInt(strings.ReplaceAll(run, ",", ""), 10, 64)
+			if err != nil {
+				missing = append(missing, f)
+				continue
+			}
+			vals[f.Name] = n
+		default:
+			if text == "" || len(fields) > 1 {
+				missing = append(missing, f)
+				continue
+			}
+			vals[f.Name] = text
+		}
+	}
+	return vals, missing
+}
+
+// fieldsPrompt lists the requested fields with their reasons.
+func fieldsPrompt(lang string, fields []inputField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		label := f.Name
+		if i := strings.LastIndexByte(label, '.'); i >= 0 {
+			label = label[i+1:]
+		}
+		if f.Type == "digits" && f.Length > 0 {
+			label += map[string]string{"ko": fmt.Sprintf("(숫자 %d자리)", f.Length), "en": fmt.Sprintf(" (%d digits)", f.Length)}[langOrDefault(lang)]
+		}
+		if f.Reason != "" {
+			label += " - " + f.Reason
+		}
+		parts = append(parts, label)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func fieldNames(fields []inputField) []string {
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, f.Name)
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// needsInputEnv is Root with a payment agent that asks for payment.cardLast4
+// (up to always times when always > 0, else until it is given) and records
+// every payload it receives.
+type needsInputEnv struct {
+	r     *RootAgent
+	sends chan map[string]any
+}
+
+func newNeedsInputEnv(t *testing.T, always int) *needsInputEnv {
+	t.Helper()
+	env := &needsInputEnv{sends: make(chan map[string]any, 8)}
+	asked := 0
+	pay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		env.sends <- in.Metadata
+		out := types.AgentMessage{ID: in.ID + "-r", From: "payment", Type: "response", Content: "결제 완료",
+			Metadata: map[string]any{"orderId": "ORD-NI"}}
+		if _, has := in.Metadata["payment.cardLast4"]; always > 0 || !has {
+			asked++
+			out.Type, out.Content = "clarify", "카드 뒷자리 4자리를 알려 주세요."
+			out.Metadata = map[string]any{"needsInput": map[string]any{
+				"requestedFields": []map[string]any{{"name": "payment.cardLast4", "type": "digits", "length": 4}},
+				"resumeToken":     "ni-tok",
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(pay.Close)
+
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_UPSTREAM_CLARIFY_MAX", "2")
+	t.Setenv("PAYMENT_URL", pay.URL)
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	env.r = NewRootAgent("root", 0)
+	env.r.logger = log.New(io.Discard, "", 0)
+	env.r.llmClient = scriptedExtractor{
+		"맥북":  `{"fields":{"mode":"purchase","item":"맥북"}}`,
+		"카드로": `{"fields":{"item":"맥북","method":"card","to":"애플스토어","shipping":"서울 강남구","budgetKRW":3000000}}`,
+	}
+	return env
+}
+
+func (env *needsInputEnv) send(t *testing.T, cid, text string) (int, types.AgentMessage) {
+	t.Helper()
+	b, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: text, ContextID: cid})
+	w := httptest.NewRecorder()
+	env.r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b)))
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return w.Code, out
+}
+
+// confirm walks the conversation to the payment agent's first answer.
+func (env *needsInputEnv) confirm(t *testing.T, cid string) types.AgentMessage {
+	t.Helper()
+	env.send(t, cid, "맥북 사줘")
+	if _, out := env.send(t, cid, "카드로, 애플스토어, 서울 강남구, 300만원"); out.Metadata["await"] != "payment.confirm" {
+		t.Fatalf("preview: %+v", out)
+	}
+	_, out := env.send(t, cid, "네")
+	<-env.sends
+	return out
+}
+
+// The parked request is re-sent with the answered field, the resume token and
+// the round; an answer without the field asks again without sending.
+func TestNeedsInputResume(t *testing.T) {
+	env := newNeedsInputEnv(t, 0)
+	const cid = "ctx-ni-resume"
+	out := env.confirm(t, cid)
+	if out.Type != "clarify" || out.Metadata["await"] != "upstream.input" || out.Metadata["round"] != float64(1) {
+		t.Fatalf("relayed question: %+v", out)
+	}
+	if stage, _ := env.r.getStageToken(cid); stage != "await_upstream" {
+		t.Fatalf("stage %q", stage)
+	}
+
+	if _, out := env.send(t, cid, "잘 모르겠어요"); out.Metadata["await"] != "upstream.input" || len(env.sends) != 0 {
+		t.Fatalf("answer without digits: %+v (sent %d)", out, len(env.sends))
+	}
+	code, out := env.send(t, cid, "끝자리는 1234예요")
+	if code != http.StatusOK || out.Type == "clarify" || out.Type == "error" {
+		t.Fatalf("resume: %d %+v", code, out)
+	}
+	got := <-env.sends
+	if got["payment.cardLast4"] != "1234" || got["needsInput.resumeToken"] != "ni-tok" || got["needsInput.round"] != float64(1) {
+		t.Fatalf("resumed payload %v", got)
+	}
+	if got["payment.method"] != "card" || got["payment.to"] != "애플스토어" {
+		t.Fatalf("original payload lost: %v", got)
+	}
+	if _, pending := env.r.upstreamAsks.Load(cid); pending {
+		t.Fatal("request still parked")
+	}
+}
+
+// An agent that keeps asking is cut off after ROOT_UPSTREAM_CLARIFY_MAX rounds.
+func TestNeedsInputLoopBound(t *testing.T) {
+	env := newNeedsInputEnv(t, 1)
+	const cid = "ctx-ni-loop"
+	if out := env.confirm(t, cid); out.Metadata["round"] != float64(1) {
+		t.Fatalf("round 1: %+v", out)
+	}
+	if _, out := env.send(t, cid, "1234"); out.Metadata["round"] != float64(2) {
+		t.Fatalf("round 2: %+v", out)
+	}
+	<-env.sends
+	code, out := env.send(t, cid, "5678")
+	<-env.sends
+	errMeta, _ := out.Metadata["error"].(map[string]any)
+	if code != http.StatusBadGateway || errMeta["code"] != "upstream_clarify_limit" || out.Metadata["rounds"] != float64(2) {
+		t.Fatalf("loop bound: %d %+v", code, out)
+	}
+	if _, pending := env.r.upstreamAsks.Load(cid); pending {
+		t.Fatal("request still parked after the limit")
+	}
+}
+
+// "취소" drops the parked request and leaves the slots for editing.
+func TestNeedsInputCancel(t *testing.T) {
+	env := newNeedsInputEnv(t, 0)
+	const cid = "ctx-ni-cancel"
+	env.confirm(t, cid)
+	if _, out := env.send(t, cid, "취소"); out.Metadata["cancelled"] != true {
+		t.Fatalf("cancel: %+v", out)
+	}
+	if stage, _ := env.r.getStageToken(cid); stage != "collect" || env.r.getPayCtx(cid).Method != "card" {
+		t.Fatalf("after cancel: stage %q slots %+v", stage, env.r.getPayCtx(cid))
+	}
+	if len(env.sends) != 0 {
+		t.Fatal("cancel re-sent the request")
+	}
+}
+
+func TestParseInputAnswers(t *testing.T) {
+	fields := []inputField{{Name: "a", Type: "digits", Length: 4}, {Name: "b", Type: "digits", Length: 2}}
+	vals, missing := parseInputAnswers(fields, "카드 1234, 유효기간 12")
+	if len(missing) != 0 || vals["a"] != "1234" || vals["b"] != "12" {
+		t.Fatalf("vals %v missing %v", vals, missing)
+	}
+	if vals, _ := parseInputAnswers([]inputField{{Name: "n", Type: "number"}}, "12,000원"); vals["n"] != int64(12000) {
+		t.Fatalf("number field %v", vals)
+	}
+	if _, missing := parseInputAnswers(fields[:1], "123"); len(missing) != 1 {
+		t.Fatalf("3 digits accepted for a 4-digit field")
+	}
+	if vals, _ := parseInputAnswers([]inputField{{Name: "s", Type: "string"}}, " 서울 "); vals["s"] != "서울" {
+		t.Fatalf("string field %v", vals)
+	}
+}
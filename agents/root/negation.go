@@ -0,0 +1,72 @@
+// Package root - negated purchase/payment intent.
+//
+// The keyword matchers fire on the verb alone, so "주문하지 마" and "I don't
+// want to buy it yet" used to start a purchase the user had just declined.
+// isPaymentActionIntent now drops its match when the triggering verb is
+// negated (Korean -지 마/말고/안 할래/생각 없어, English don't/not going
+// to/cancel the idea of ...), which leaves the turn to the LLM router or chat.
+// If the turn still lands in payment and the LLM extractor finds nothing
+// actionable, Root answers in chat with a short acknowledgement instead.
+package root
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+var (
+	// 주문하지 마, 사지 말고, 보내지 않을래, 결제하진 않을 거야
+	koNegStemRe = regexp.MustCompile(`(주문|구매|구입|결제|송금|이체|지불|보내|사|시키)\s*(하)?\s*(지\s*(마|말|않)|진\s*않)`)
+	// 안 살래, 안 사요, 안 보낼래, 안 시킬래
+	koNegAnRe = regexp.MustCompile(`안\s*(살|사|보낼|보내|시킬|시켜)(래|요|고|게|거|$|\s|[.!?~])`)
+	// 주문 안 할래, 결제는 안 해
+	koNegNounRe = regexp.MustCompile(`(주문|구매|구입|결제|송금|이체|지불)\s*(은|는|도)?\s*안\s*(하|할|해|함)`)
+	// 주문 말고 추천만
+	koNegInsteadRe = regexp.MustCompile(`(주문|구매|구입|결제|송금|이체)\s*(은|는)?\s*말고`)
+	// 살 생각 없어, 주문할 필요 없어, 구매 계획은 없어
+	koNegNoneRe = regexp.MustCompile(`(주문|구매|구입|결제|송금|이체|살|사)\S*\s*(생각|필요|계획|마음)\s*(은|는|이|가|도)?\s*없`)
+
+	// don't order, not going to buy, no need to pay, cancel the idea of buying
+	enNegRe = regexp.MustCompile(`\b(don'?t|dont|do not|doesn'?t|not|never|won'?t|will not|no need to|hold off( on)?|cancel the idea of|without)\b(\s+[a-z']+){0,3}?\s+(order|buy|purchas|pay|send|transfer|check\s?out)`)
+	// "don't forget to pay" is a reminder, not a refusal
+	enNegExceptRe = regexp.MustCompile(`\b(don'?t|dont|do not|never) forget\b`)
+	koNegExceptRe = regexp.MustCompile(`(잊지|까먹지)\s*(마|말)`)
+)
+
+// hasNegatedPaymentIntent reports whether a purchase/payment verb in c is negated.
+func hasNegatedPaymentIntent(c string) bool {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if c == "" {
+		return false
+	}
+	c = strings.ReplaceAll(c, "’", "'")
+	if enNegExceptRe.MatchString(c) || koNegExceptRe.MatchString(c) {
+		return false
+	}
+	for _, re := range []*regexp.Regexp{koNegStemRe, koNegAnRe, koNegNounRe, koNegInsteadRe, koNegNoneRe, enNegRe} {
+		if re.MatchString(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNegationAck answers a declined purchase in chat mode.
+func (r *RootAgent) writeNegationAck(ctx context.Context, w http.ResponseWriter, msg *types.AgentMessage, cid, lang string) {
+	r.logger.Printf("[root][intent][negation] cid=%s payment declined; answering in chat text=%q", cid, strings.TrimSpace(msg.Content))
+	out := types.AgentMessage{
+		ID: msg.ID + "-chat", From: "root", To: msg.From, Type: "response",
+		Content: map[string]string{
+			"ko": "알겠어요, 주문이나 결제는 진행하지 않을게요. 다른 도움이 필요하면 말씀해 주세요.",
+			"en": "Got it, I won't order or pay for anything. Let me know if you need something else.",
+		}[langOrDefault(lang)],
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"lang": lang, "mode": "chat", "negatedIntent": "payment"},
+	}
+	r.writeChat(ctx, w, cid, out)
+}
@@ -0,0 +1,100 @@
+package root
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Negation corpus: declined purchases must not be routed into the payment flow.
+var negatedPhrasings = []string{
+	// ko
+	"주문하지 마",
+	"아직 주문하지 마세요",
+	"그거 사지 마",
+	"결제하지 말아줘",
+	"송금하지 마",
+	"이체하지 말고 기다려",
+	"보내지 마",
+	"구매하지 않을래",
+	"결제하진 않을 거야",
+	"안 살래",
+	"오늘은 안 사요",
+	"돈 안 보낼래",
+	"안 시킬래",
+	"주문 안 할래",
+	"결제는 안 해",
+	"구매 말고 추천만 해줘",
+	"주문 말고 가격만 알려줘",
+	"살 생각 없어",
+	"주문할 필요 없어",
+	"구매 계획은 없어요",
+	// en
+	"don't order anything",
+	"I don't want to buy it yet",
+	"please do not purchase that",
+	"dont pay for it",
+	"I'm not going to buy the iphone",
+	"not ready to order yet",
+	"never send money to him",
+	"I won't pay 50000 won by card",
+	"we will not transfer anything today",
+	"no need to order, just looking",
+	"hold off on buying the macbook",
+	"cancel the idea of buying airpods",
+	"I don’t want to purchase this",
+	"don't send it yet",
+	"do not checkout",
+	"she doesn't want to order",
+	"just browsing without buying",
+	"I do not want to pay now",
+	"let's not order the laptop",
+	"I never buy online, what is the price",
+}
+
+// Positive requests the negation check must leave alone.
+var paymentRequests = []string{
+	"아이폰 주문해줘",
+	"맥북 사줘",
+	"엄마한테 5만원 보내줘",
+	"카드로 결제해",
+	"지금 이체해줘",
+	"에어팟 구매해줘",
+	"주문하는 거 잊지 말고 해줘",
+	"결제 까먹지 말고 해줘",
+	"order an iphone",
+	"please buy the macbook",
+	"send 50000 won to mom",
+	"pay with kakaopay",
+	"transfer 100 usdc to alice",
+	"don't forget to pay the rent",
+	"make an order for airpods",
+	"please order it now",
+}
+
+func TestNegatedPhrasingsNotRouted(t *testing.T) {
+	if len(negatedPhrasings) != 40 {
+		t.Fatalf("corpus has %d phrasings, want 20 per language", len(negatedPhrasings))
+	}
+	r := &RootAgent{}
+	for _, s := range negatedPhrasings {
+		if !hasNegatedPaymentIntent(s) {
+			t.Errorf("%q: negation not detected", s)
+		}
+		if got := r.pickAgent(&types.AgentMessage{Content: s}); got == "payment" {
+			t.Errorf("%q routed to payment", s)
+		}
+	}
+}
+
+func TestPaymentRequestsStillRouted(t *testing.T) {
+	r := &RootAgent{}
+	for _, s := range paymentRequests {
+		if hasNegatedPaymentIntent(s) {
+			t.Errorf("%q: read as negated", s)
+		}
+		if got := r.pickAgent(&types.AgentMessage{Content: s}); got != "payment" {
+			t.Errorf("%q routed to %q, want payment", s, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package root - input normalization for intent detection and rule-based slot extraction.
+//
+// The original text is kept for display/forwarding; only keyword matchers and
+// regexes see the normalized form. LLM extractors get both (see llmInputVariant).
+package root
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// romanNumerals maps Unicode roman numeral code points (Ⅰ..Ⅻ, ⅰ..ⅻ) to digits.
+var romanNumerals = map[rune]string{
+	'Ⅰ': "1", 'Ⅱ': "2", 'Ⅲ': "3", 'Ⅳ': "4", 'Ⅴ': "5", 'Ⅵ': "6",
+	'Ⅶ': "7", 'Ⅷ': "8", 'Ⅸ': "9", 'Ⅹ': "10", 'Ⅺ': "11", 'Ⅻ': "12",
+	'ⅰ': "1", 'ⅱ': "2", 'ⅲ': "3", 'ⅳ': "4", 'ⅴ': "5", 'ⅵ': "6",
+	'ⅶ': "7", 'ⅷ': "8", 'ⅸ': "9", 'ⅹ': "10", 'ⅺ': "11", 'ⅻ': "12",
+}
+
+// productAlias lists brand/product spellings. The canonical form depends on the
+// input script: Hangul input -> ko, otherwise -> en, so rules keyed on either work.
+type productAlias struct {
+	ko, en  string
+	aliases []string // lower-case spellings
+}
+
+// aliasRes matches each productAliases entry's spellings case-insensitively.
+// Matching runs on the text itself: lower-casing first can change byte
+// lengths (e.g. "Ⱥ" -> "ⱥ"), so indexes into a lowered copy do not fit it.
+var aliasRes = func() []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(productAliases))
+	for i, pa := range productAliases {
+		q := make([]string, len(pa.aliases))
+		for j, a := range pa.aliases {
+			q[j] = regexp.QuoteMeta(a)
+		}
+		res[i] = regexp.MustCompile(`(?i)` + strings.Join(q, "|"))
+	}
+	return res
+}()
+
+var productAliases = []productAlias{
+	{ko: "아이폰", en: "iphone", aliases: []string{"아이폰", "iphone"}},
+	{ko: "아이패드", en: "ipad", aliases: []string{"아이패드", "ipad"}},
+	{ko: "맥북", en: "macbook", aliases: []string{"맥북", "macbook", "맥 북"}},
+	{ko: "에어팟", en: "airpods", aliases: []string{"에어팟", "airpods", "airpod"}},
+	{ko: "갤럭시", en: "galaxy", aliases: []string{"갤럭시", "galaxy"}},
+	{ko: "카카오페이", en: "kakaopay", aliases: []string{"카카오페이", "kakaopay", "카카오 페이", "kakao pay"}},
+	{ko: "네이버페이", en: "naverpay", aliases: []string{"네이버페이", "naverpay", "네이버 페이", "naver pay"}},
+	{ko: "토스", en: "toss", aliases: []string{"토스페이", "tosspay", "toss pay", "토스"}},
+}
+
+// model suffixes written either way around a model number (16pro / 16 프로)
+var modelSuffix = map[string]string{
+	"pro": "프로", "max": "맥스", "plus": "플러스", "ultra": "울트라", "mini": "미니", "air": "에어",
+}
+
+var (
+	reHangulDigit = regexp.MustCompile(`(\p{Hangul})(\d)`)
+	reLatinDigit  = regexp.MustCompile(`(?i)\b(iphone|ipad|galaxy|macbook|airpods)(\d)`)
+	reDigitSuffix = regexp.MustCompile(`(?i)(\d)(pro|max|plus|ultra|mini|air|프로|맥스|플러스|울트라|미니|에어)`)
+	reSpacedEnSfx = regexp.MustCompile(`(?i)(\d) (pro|max|plus|ultra|mini|air)\b`)
+	reMultiSpace  = regexp.MustCompile(`[ \t]+`)
+)
+
+// foldWidth applies the compatibility mappings we rely on (subset of NFKC):
+// full-width ASCII -> half-width, ideographic space -> space, roman numerals -> digits.
+func foldWidth(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			b.WriteRune(r - 0xFEE0)
+		case r == 0x3000:
+			b.WriteByte(' ')
+		default:
+			if d, ok := romanNumerals[r]; ok {
+				b.WriteString(d)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func hasHangul(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Hangul, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeInput returns the form keyword matchers and regexes should see.
+func normalizeInput(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return s
+	}
+	out := foldWidth(s)
+	ko := hasHangul(out)
+
+	// brand/product aliases -> canonical spelling for the input script (every occurrence)
+	for i, pa := range productAliases {
+		canon := pa.en
+		if ko {
+			canon = pa.ko
+		}
+		out = aliasRes[i].ReplaceAllLiteralString(out, canon)
+	}
+
+	// spacing around model numbers: "아이폰16프로" / "iphone16pro" -> "아이폰 16 프로" / "iphone 16 pro"
+	out = reHangulDigit.ReplaceAllString(out, "$1 $2")
+	out = reLatinDigit.ReplaceAllString(out, "$1 $2")
+	out = reDigitSuffix.ReplaceAllStringFunc(out, func(m string) string {
+		i := 0
+		for i < len(m) && m[i] >= '0' && m[i] <= '9' {
+			i++
+		}
+		suf := strings.ToLower(m[i:])
+		if ko {
+			if k, ok := modelSuffix[suf]; ok {
+				suf = k
+			}
+		} else {
+			for en, k := range modelSuffix {
+				if suf == k {
+					suf = en
+				}
+			}
+		}
+		return m[:i] + " " + suf
+	})
+	if ko {
+		// "16 pro" in Hangul text -> "16 프로"
+		out = reSpacedEnSfx.ReplaceAllStringFunc(out, func(m string) string {
+			return m[:1] + " " + modelSuffix[strings.ToLower(m[2:])]
+		})
+	}
+	out = reMultiSpace.ReplaceAllString(out, " ")
+	return strings.TrimSpace(out)
+}
+
+// llmInputVariant gives LLM extractors the original text plus the normalized
+// variant when they differ.
+func llmInputVariant(orig, norm string) string {
+	if strings.TrimSpace(orig) == strings.TrimSpace(norm) {
+		return orig
+	}
+	return orig + "\n(normalized: " + norm + ")"
+}
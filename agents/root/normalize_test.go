@@ -0,0 +1,69 @@
+package root
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestNormalizeInput(t *testing.T) {
+	cases := []struct{ in, want string }{
+		// full-width digits/latin, ideographic space
+		{"아이폰１６프로　１５０만원", "아이폰 16 프로 150만원"},
+		{"ＩＰＨＯＮＥ１６ＰＲＯ", "iphone 16 pro"},
+		// roman numerals
+		{"갤럭시 Ⅻ", "갤럭시 12"},
+		// spacing around model numbers, either script
+		{"아이폰16pro 사줘", "아이폰 16 프로 사줘"},
+		{"iphone16pro", "iphone 16 pro"},
+		{"아이폰 16 pro", "아이폰 16 프로"},
+		// aliases follow the input script
+		{"iPhone 16 사줘", "아이폰 16 사줘"},
+		{"buy a MacBook and AirPods", "buy a macbook and airpods"},
+		{"맥북이랑 airpods", "맥북이랑 에어팟"},
+		{"카카오 페이로 결제", "카카오페이로 결제"},
+		{"pay with Kakao Pay", "pay with kakaopay"},
+		{"토스페이로 보내줘", "토스로 보내줘"},
+		// every occurrence, not just the first
+		{"iphone 하나, iPhone 하나 더", "아이폰 하나, 아이폰 하나 더"},
+		// case folding that changes byte length must not shift indexes
+		{"사줘 ȺȺȺiphone", "사줘 ȺȺȺ아이폰"},
+		{"ȺȺȺiphone 사줘", "ȺȺȺ아이폰 사줘"},
+		{"İİ macbook İ", "İİ macbook İ"},
+		{"ǅ 맥북 ǅ", "ǅ 맥북 ǅ"},
+		// untouched
+		{"   ", "   "},
+		{"hello", "hello"},
+	}
+	for _, c := range cases {
+		got := normalizeInput(c.in)
+		if got != c.want {
+			t.Errorf("normalizeInput(%q) = %q, want %q", c.in, got, c.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("normalizeInput(%q) = %q: invalid UTF-8", c.in, got)
+		}
+	}
+}
+
+// Extraction corpus: inputs the rule-based extractor only parses after normalization.
+func TestExtractPaymentSlotsNormalized(t *testing.T) {
+	cases := []struct {
+		in     string
+		amount int64
+		method string
+		item   string
+	}{
+		{"카카오 페이로 １５０만원", 1500000, "kakaopay", ""},
+		{"ＩＰＨＯＮＥ16pro 사줘 ５０만원 카드로 신용카드", 500000, "card", "아이폰"},
+		{"네이버 페이로 맥 북 ２００만원", 2000000, "naverpay", "맥북"},
+		{"ȺȺȺiphone 토스페이로 ３０만원", 300000, "toss", "아이폰"},
+	}
+	for _, c := range cases {
+		s, _, _ := extractPaymentSlots(&types.AgentMessage{Content: normalizeInput(c.in)})
+		if s.AmountKRW != c.amount || s.Method != c.method || s.Item != c.item {
+			t.Errorf("%q: amount=%d method=%q item=%q, want %d %q %q", c.in, s.AmountKRW, s.Method, s.Item, c.amount, c.method, c.item)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package root - named upstream operations.
+//
+// Root reaches an external agent through a named operation instead of a fixed
+// POST /process. configs/upstream_operations.json (ROOT_UPSTREAM_OPERATIONS
+// overrides the path; the built-in copy below is used when the file is
+// missing) maps target → operation → {path, method}. Target keys are exact
+// names or glob patterns ("*", "ext-*"): an exact entry wins, then the
+// matching globs, most specific first. Paths may carry {param} or
+// {param:regex} placeholders filled from the outgoing message's metadata; a
+// value must fully match its regex (default: one safe path segment).
+//
+// sendExternal signs and HPKE-wraps whatever method and path the operation
+// resolves to, so the signature's @method/@path are the operation's. An
+// unknown operation or a missing/invalid parameter is an opConfigError and
+// fails before anything is sent.
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// opProcess is the default operation (POST /process).
+const opProcess = "process"
+
+const defaultParamPattern = `[A-Za-z0-9._~-]+`
+
+var opParamRe = regexp.MustCompile(`\{([A-Za-z0-9_.]+)(?::([^{}]+))?\}`)
+
+// upstreamOp is one configured operation.
+type upstreamOp struct {
+	Path   string `json:"path"`
+	Method string `json:"method,omitempty"` // default POST
+
+	params map[string]*regexp.Regexp
+}
+
+type opSet struct {
+	Version string                           `json:"version"`
+	Targets map[string]map[string]upstreamOp `json:"targets"`
+	Source  string                           `json:"-"`
+
+	globs []string // non-exact target keys, most specific first
+}
+
+// builtinOps mirrors configs/upstream_operations.json. Use compiledBuiltinOps:
+// compile rewrites the Targets maps in place, so the set is compiled once and
+// then only read.
+var builtinOps = opSet{
+	Version: "2026-10-15.2",
+	Targets: map[string]map[string]upstreamOp{
+		"*": {
+			opProcess: {Path: "/process", Method: http.MethodPost},
+		},
+		"payment": {
+			"simulate": {Path: "/simulate", Method: http.MethodPost},
+			"refund":   {Path: "/refund", Method: http.MethodPost},
+			"status":   {Path: "/lookup/{payment.idempotencyKey}", Method: http.MethodGet},
+			"order":    {Path: "/orders/{payment.orderId}", Method: http.MethodGet},
+			"capture":  {Path: "/capture", Method: http.MethodPost},
+			"auth":     {Path: "/auths/{payment.authId}", Method: http.MethodGet},
+		},
+	},
+	Source: "builtin",
+}
+
+// opConfigError reports an operation that cannot be resolved from config.
+type opConfigError struct {
+	Target, Op, Reason string
+}
+
+func (e *opConfigError) Error() string {
+	return fmt.Sprintf("upstream operation %s.%s: %s", e.Target, e.Op, e.Reason)
+}
+
+func isGlob(key string) bool { return strings.ContainsAny(key, "*?[") }
+
+// compile validates every entry and prepares the placeholders and glob order.
+func (s *opSet) compile() error {
+	s.globs = s.globs[:0]
+	for target, ops := range s.Targets {
+		if isGlob(target) {
+			if _, err := path.Match(target, ""); err != nil {
+				return fmt.Errorf("target %q: %v", target, err)
+			}
+			s.globs = append(s.globs, target)
+		}
+		for name, op := range ops {
+			op.Method = strings.ToUpper(strings.TrimSpace(op.Method))
+			if op.Method == "" {
+				op.Method = http.MethodPost
+			}
+			switch op.Method {
+			case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				return fmt.Errorf("%s.%s: unsupported method %q", target, name, op.Method)
+			}
+			if !strings.HasPrefix(op.Path, "/") {
+				return fmt.Errorf("%s.%s: path %q must start with /", target, name, op.Path)
+			}
+			op.params = map[string]*regexp.Regexp{}
+			for _, m := range opParamRe.FindAllStringSubmatch(op.Path, -1) {
+				pat := firstNonEmpty(m[2], defaultParamPattern)
+				re, err := regexp.Compile("^(?:" + pat + ")$")
+				if err != nil {
+					return fmt.Errorf("%s.%s: parameter %s: %v", target, name, m[1], err)
+				}
+				op.params[m[0]] = re
+			}
+			ops[name] = op
+		}
+	}
+	sort.Slice(s.globs, func(i, j int) bool {
+		if len(s.globs[i]) != len(s.globs[j]) {
+			return len(s.globs[i]) > len(s.globs[j])
+		}
+		return s.globs[i] < s.globs[j]
+	})
+	return nil
+}
+
+var builtinOpsOnce sync.Once
+
+// compiledBuiltinOps returns builtinOps, compiled on first use.
+func compiledBuiltinOps() *opSet {
+	builtinOpsOnce.Do(func() {
+		if err := builtinOps.compile(); err != nil {
+			panic("root: built-in upstream operations: " + err.Error())
+		}
+	})
+	return &builtinOps
+}
+
+func loadUpstreamOps(logger *log.Logger) *opSet {
+	builtin := compiledBuiltinOps()
+	p := firstNonEmpty(strings.TrimSpace(os.Getenv("ROOT_UPSTREAM_OPERATIONS")), "configs/upstream_operations.json")
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return builtin
+	}
+	var set opSet
+	if err = json.Unmarshal(b, &set); err == nil && len(set.Targets) == 0 {
+		err = fmt.Errorf("no targets")
+	}
+	if err == nil {
+		err = set.compile()
+	}
+	if err != nil {
+		logger.Printf("[root][ops] ignoring %s (using built-in operations): %v", p, err)
+		return builtin
+	}
+	set.Source = p
+	logger.Printf("[root][ops] loaded %s version=%s targets=%d", p, set.Version, len(set.Targets))
+	return &set
+}
+
+// lookup finds op for target: the exact entry first, then matching globs.
+func (s *opSet) lookup(target, op string) (upstreamOp, bool) {
+	if o, ok := s.Targets[target][op]; ok {
+		return o, true
+	}
+	for _, g := range s.globs {
+		if ok, _ := path.Match(g, target); ok {
+			if o, ok := s.Targets[g][op]; ok {
+				return o, true
+			}
+		}
+	}
+	return upstreamOp{}, false
+}
+
+// resolve returns the method and path for op on target, with placeholders
+// filled from meta.
+func (s *opSet) resolve(target, op string, meta map[string]any) (method, p string, err error) {
+	op = firstNonEmpty(strings.TrimSpace(op), opProcess)
+	o, ok := s.lookup(target, op)
+	if !ok {
+		return "", "", &opConfigError{Target: target, Op: op, Reason: "not configured (" + s.Source + ")"}
+	}
+	p = o.Path
+	for ph, re := range o.params {
+		name := opParamRe.FindStringSubmatch(ph)[1]
+		val := ""
+		if v, ok := metaPath(meta, name); ok && v != nil {
+			val = strings.TrimSpace(fmt.Sprint(v))
+		}
+		if val == "" {
+			return "", "", &opConfigError{Target: target, Op: op, Reason: "missing path parameter " + name}
+		}
+		if !re.MatchString(val) {
+			return "", "", &opConfigError{Target: target, Op: op, Reason: fmt.Sprintf("path parameter %s=%q does not match %s", name, val, re)}
+		}
+		p = strings.ReplaceAll(p, ph, url.PathEscape(val))
+	}
+	return o.Method, p, nil
+}
+
+// snapshot lists the configured operations for /status.
+func (s *opSet) snapshot() map[string]any {
+	targets := map[string]any{}
+	for t, ops := range s.Targets {
+		m := map[string]string{}
+		for name, o := range ops {
+			m[name] = o.Method + " " + o.Path
+		}
+		targets[t] = m
+	}
+	return map[string]any{"version": s.Version, "source": s.Source, "targets": targets}
+}
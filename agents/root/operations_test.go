@@ -0,0 +1,89 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Agents constructed concurrently share the built-in operations; loading
+// them must not write to the shared maps.
+func TestBuiltinOpsConcurrentLoad(t *testing.T) {
+	t.Setenv("ROOT_UPSTREAM_OPERATIONS", t.TempDir()+"/missing.json")
+	logger := log.New(io.Discard, "", 0)
+	var wg sync.WaitGroup
+	sets := make([]*opSet, 16)
+	for i := range sets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sets[i] = loadUpstreamOps(logger)
+		}(i)
+	}
+	wg.Wait()
+	for _, s := range sets {
+		if s.Source != "builtin" {
+			t.Fatalf("source %q", s.Source)
+		}
+		m, p, err := s.resolve("payment", "status", map[string]any{"payment": map[string]any{"idempotencyKey": "idem-1"}})
+		if err != nil || m != http.MethodGet || p != "/lookup/idem-1" {
+			t.Fatalf("status op: %s %s %v", m, p, err)
+		}
+	}
+}
+
+// sendExternal sends to the resolved operation: the method and path the
+// upstream sees are the ones the signer covers.
+func TestSendExternalOperationPath(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		got = append(got, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{Type: "response", Content: "ok"})
+	}))
+	defer up.Close()
+
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_UPSTREAM_OPERATIONS", t.TempDir()+"/missing.json")
+	t.Setenv("PAYMENT_URL", up.URL)
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+
+	meta := map[string]any{"payment": map[string]any{"idempotencyKey": "idem-1", "orderId": "ord-7"}}
+	for _, op := range []string{"", "refund", "simulate", "status", "order"} {
+		msg := &types.AgentMessage{ID: "m-" + op, From: "root", To: "payment", Type: "request", Content: "x", Metadata: meta}
+		if _, err := r.sendExternal(context.Background(), "payment", op, msg); err != nil {
+			t.Fatalf("op %q: %v", op, err)
+		}
+	}
+	want := "POST /process,POST /refund,POST /simulate,GET /lookup/idem-1,GET /orders/ord-7"
+	if s := strings.Join(got, ","); s != want {
+		t.Fatalf("upstream saw %s\nwant %s", s, want)
+	}
+
+	// Unknown operations and bad parameters fail before anything is sent
+	msg := &types.AgentMessage{ID: "m-bad", Metadata: map[string]any{"payment": map[string]any{"idempotencyKey": "../x"}}}
+	for _, op := range []string{"nope", "status"} {
+		var ce *opConfigError
+		if _, err := r.sendExternal(context.Background(), "payment", op, msg); !errors.As(err, &ce) {
+			t.Fatalf("op %q: %v", op, err)
+		}
+	}
+	if len(got) != 5 {
+		t.Fatalf("sent %d requests", len(got))
+	}
+}
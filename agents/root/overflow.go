@@ -0,0 +1,177 @@
+// Package root - forwarded-metadata budget with an overflow side channel.
+//
+// sendExternal keeps the forwarded metadata under ROOT_META_MAX_BYTES (default
+// 16 KiB; 0 disables). The largest values (transcripts, histories, traces) are
+// parked here for ROOT_OVERFLOW_TTL_SEC (default 600) and replaced by
+// "<key>_ref" tokens. The store holds at most ROOT_OVERFLOW_MAX_BYTES (default
+// 32 MiB) of values; past that the oldest are dropped first, and a fetch of a
+// dropped token is a 404 like an expired one. The upstream fetches them from
+// GET /overflow/{token}, which requires an RFC 9421 signature from the DID of
+// the agent the value was forwarded to. ROOT_PUBLIC_URL sets the advertised
+// base (default localhost).
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/kidbind"
+	"github.com/sage-x-project/sage-multi-agent/internal/overflow"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+type overflowEntry struct {
+	target  string
+	key     string
+	raw     json.RawMessage
+	expires time.Time
+}
+
+// overflowStore keeps entries in insertion order, which is also expiry order
+// (one TTL for all), so expiry and eviction only ever look at the front.
+type overflowStore struct {
+	mu    sync.Mutex
+	m     map[string]overflowEntry
+	order []string // tokens, oldest first
+	bytes int
+	max   int
+}
+
+func newOverflowStore() *overflowStore {
+	return &overflowStore{m: map[string]overflowEntry{}, max: envInt("ROOT_OVERFLOW_MAX_BYTES", 32<<20)}
+}
+
+func (s *overflowStore) put(target, key string, raw json.RawMessage) string {
+	ttl := time.Duration(envInt("ROOT_OVERFLOW_TTL_SEC", 600)) * time.Second
+	token := "ovf-" + uuid.NewString()
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.order) > 0 && now.After(s.m[s.order[0]].expires) {
+		s.dropOldestLocked()
+	}
+	for len(s.order) > 0 && s.max > 0 && s.bytes+len(raw) > s.max {
+		s.dropOldestLocked()
+	}
+	s.m[token] = overflowEntry{target: target, key: key, raw: raw, expires: now.Add(ttl)}
+	s.order = append(s.order, token)
+	s.bytes += len(raw)
+	return token
+}
+
+func (s *overflowStore) dropOldestLocked() {
+	token := s.order[0]
+	s.order = s.order[1:]
+	s.bytes -= len(s.m[token].raw)
+	delete(s.m, token)
+}
+
+func (s *overflowStore) get(token string) (overflowEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[token]
+	if !ok || time.Now().After(e.expires) {
+		return overflowEntry{}, false
+	}
+	return e, true
+}
+
+func (r *RootAgent) publicBase() string {
+	if v := strings.TrimRight(strings.TrimSpace(envOr("ROOT_PUBLIC_URL", "")), "/"); v != "" {
+		return v
+	}
+	return "http://localhost:" + strconv.Itoa(r.boundPort())
+}
+
+// capForwardMeta shrinks msg.Metadata to limit bytes before it is sent to
+// agent (the budget, or less when the upstream accepts less; see
+// payload_limits.go) and returns the moved keys.
+func (r *RootAgent) capForwardMeta(agent string, msg *types.AgentMessage, limit int) []string {
+	if limit <= 0 || msg.Metadata == nil {
+		return nil
+	}
+	before := overflow.Size(msg.Metadata)
+	moved := overflow.Shrink(msg.Metadata, limit, func(key string, raw json.RawMessage) string {
+		return r.overflow.put(agent, key, raw)
+	})
+	if len(moved) == 0 {
+		return nil
+	}
+	msg.Metadata[overflow.MetaURL] = r.publicBase() + "/overflow/"
+	r.logger.Printf("[root][overflow] target=%s metadata %dB -> %dB (cap %dB) moved=%v",
+		agent, before, overflow.Size(msg.Metadata), limit, moved)
+	return moved
+}
+
+// targetDID is the DID registered for target in the agent keys file.
+func targetDID(target string) string {
+	m, err := loadDIDsFromKeys(firstNonEmpty(envOr("HPKE_KEYS_FILE", ""), "merged_agent_keys.json"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(firstNonEmpty(m[target], m["external"]))
+}
+
+func (r *RootAgent) overflowAuth() http.Handler {
+	r.overflowOnce.Do(func() {
+		inner := http.HandlerFunc(r.serveOverflow)
+		defer func() {
+			if rec := recover(); rec != nil {
+				r.logger.Printf("[root][overflow] DID middleware unavailable: %v", rec)
+			}
+		}()
+		mw, err := a2autil.BuildDIDMiddleware(false)
+		if err != nil {
+			r.logger.Printf("[root][overflow] DID middleware unavailable: %v", err)
+			return
+		}
+		r.overflowH = mw.Wrap(inner)
+	})
+	return r.overflowH
+}
+
+// handleOverflow: GET /overflow/{token} (DID-authenticated).
+func (r *RootAgent) handleOverflow(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := r.overflowAuth()
+	if h == nil {
+		http.Error(w, "signature verification unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	h.ServeHTTP(w, req)
+}
+
+func (r *RootAgent) serveOverflow(w http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.URL.Path, "/overflow/")
+	e, ok := r.overflow.get(token)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	signer := kidbind.SignerDID(req)
+	if want := targetDID(e.target); want == "" || !strings.EqualFold(signer, want) {
+		r.audit.Emit(audit.Event{
+			Type: "security", Action: "overflow.fetch", Outcome: "denied", Actor: firstNonEmpty(signer, req.RemoteAddr), Target: e.target,
+			Detail: map[string]any{"key": e.key},
+		})
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	r.audit.Emit(audit.Event{
+		Type: "security", Action: "overflow.fetch", Outcome: "success", Actor: signer, Target: e.target,
+		Detail: map[string]any{"key": e.key, "bytes": len(e.raw)},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(e.raw)
+}
@@ -0,0 +1,101 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rawOfSize(n int) json.RawMessage {
+	return json.RawMessage(`"` + strings.Repeat("x", n-2) + `"`)
+}
+
+// Past the byte cap the oldest values go first; expired ones are dropped
+// before anything is evicted.
+func TestOverflowStoreCap(t *testing.T) {
+	t.Setenv("ROOT_OVERFLOW_MAX_BYTES", "1000")
+	s := newOverflowStore()
+	a := s.put("payment", "a", rawOfSize(400))
+	b := s.put("payment", "b", rawOfSize(400))
+	c := s.put("payment", "c", rawOfSize(400))
+	if _, ok := s.get(a); ok {
+		t.Fatal("oldest value kept past the cap")
+	}
+	for _, tok := range []string{b, c} {
+		if _, ok := s.get(tok); !ok {
+			t.Fatalf("%s evicted", tok)
+		}
+	}
+	if s.bytes != 800 || len(s.order) != 2 || len(s.m) != 2 {
+		t.Fatalf("bytes=%d order=%d entries=%d", s.bytes, len(s.order), len(s.m))
+	}
+
+	// b expires: the next put only has to drop b, so c stays
+	e := s.m[b]
+	e.expires = time.Now().Add(-time.Second)
+	s.m[b] = e
+	d := s.put("medical", "d", rawOfSize(500))
+	if _, ok := s.get(c); !ok {
+		t.Fatal("live value evicted while an expired one was held")
+	}
+	if _, ok := s.get(d); !ok || s.bytes != 900 || len(s.order) != 2 {
+		t.Fatalf("bytes=%d order=%v", s.bytes, s.order)
+	}
+
+	// a value larger than the cap replaces everything
+	big := s.put("medical", "big", rawOfSize(1500))
+	if _, ok := s.get(big); !ok || len(s.m) != 1 || s.bytes != 1500 {
+		t.Fatalf("oversized value: entries=%d bytes=%d", len(s.m), s.bytes)
+	}
+}
+
+// A parked value is served only to a request whose verified signature is the
+// target agent's DID; a claimed X-SAGE-DID header does not count.
+func TestServeOverflowSignerOnly(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`[{"name":"payment","did":"did:sage:test:pay"},{"name":"medical","did":"did:sage:test:med"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HPKE_KEYS_FILE", keys)
+	r := statusRoot(t)
+	tok := r.overflow.put("medical", "medical.history", json.RawMessage(`{"visits":3}`))
+
+	sig := func(did string) string { return `sig1=("@method" "@path");keyid="` + did + `"` }
+	cases := []struct {
+		name          string
+		token         string
+		sigInput, did string
+		want          int
+	}{
+		{"target agent", tok, sig("did:sage:test:med"), "", http.StatusOK},
+		{"other agent", tok, sig("did:sage:test:pay"), "", http.StatusForbidden},
+		{"unsigned", tok, "", "", http.StatusForbidden},
+		{"claimed DID header", tok, "", "did:sage:test:med", http.StatusForbidden},
+		{"unknown token", "ovf-nope", sig("did:sage:test:med"), "", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/overflow/"+tc.token, nil)
+			if tc.sigInput != "" {
+				req.Header.Set("Signature-Input", tc.sigInput)
+			}
+			if tc.did != "" {
+				req.Header.Set("X-SAGE-DID", tc.did)
+			}
+			w := httptest.NewRecorder()
+			r.serveOverflow(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if tc.want == http.StatusOK && !bytes.Equal(w.Body.Bytes(), []byte(`{"visits":3}`)) {
+				t.Fatalf("body %s", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package root - payload invariants at the Root hop.
+//
+// sendVia runs internal/payloadcheck on the plaintext it is about to sign or
+// encrypt and on the plaintext of every upstream answer. A violation is logged
+// with the hop trace and emitted as audit security/payload.invariant. With
+// STRICT_PAYLOAD on, an outbound violation fails the send (error class
+// verification_failed) instead of being forwarded. An answer is never
+// rejected, since the upstream has already acted on the request; a
+// double-encoded answer is unwrapped so its fields reach the client instead of
+// the encoded text.
+//
+// In debug mode (admin token or X-SAGE-Debug: true) each upstream request
+// carries X-SAGE-Payload-Trace, started with Root's fingerprint of the
+// plaintext. The gateway and the agent append theirs, and the agent logs the
+// trace and echoes it in its response. Root's own /process answer carries
+// the traces it started, one per send.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const ctxPayloadTraceKey ctxKey = "payloadTrace"
+
+// payloadTrace collects the traces Root started during one debug request.
+type payloadTrace struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+// withPayloadTrace enables tracing for req when it is in debug mode.
+func withPayloadTrace(req *http.Request) context.Context {
+	if !wantsDebug(req) {
+		return req.Context()
+	}
+	return context.WithValue(req.Context(), ctxPayloadTraceKey, &payloadTrace{})
+}
+
+func payloadTraceFrom(ctx context.Context) *payloadTrace {
+	pt, _ := ctx.Value(ctxPayloadTraceKey).(*payloadTrace)
+	return pt
+}
+
+// start returns the trace header for a send of plain to target ("" when the
+// request is not traced).
+func (pt *payloadTrace) start(target string, plain []byte) string {
+	if pt == nil {
+		return ""
+	}
+	v := payloadcheck.AppendTrace("", "root", payloadcheck.Fingerprint(plain))
+	pt.mu.Lock()
+	pt.sent = append(pt.sent, target+": "+v)
+	pt.mu.Unlock()
+	return v
+}
+
+// value is the response header: the traces started, in send order.
+func (pt *payloadTrace) value() string {
+	if pt == nil {
+		return ""
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return strings.Join(pt.sent, "; ")
+}
+
+// payloadInvariantError: the outbound plaintext failed a payload invariant
+// under STRICT_PAYLOAD.
+type payloadInvariantError struct {
+	Target   string
+	Findings payloadcheck.Findings
+}
+
+func (e *payloadInvariantError) Error() string {
+	return fmt.Sprintf("payload invariant violated for %s: %s", e.Target, e.Findings)
+}
+
+// checkPayload inspects plain on its way to target (dir "request") or back
+// from it ("response"). It returns the bytes to use, and for a request under
+// STRICT_PAYLOAD an error instead of sending.
+func (r *RootAgent) checkPayload(target, dir, cid string, plain []byte, trace string) ([]byte, error) {
+	fs := payloadcheck.Inspect(plain)
+	if len(fs) == 0 {
+		return plain, nil
+	}
+	strict := dir == "request" && payloadcheck.Strict()
+	r.logger.Printf("[root][payload] ⚠️ %s %s cid=%s invariant violated: %s strict=%v trace=%q", dir, target, cid, fs, strict, trace)
+	r.audit.Emit(audit.Event{
+		Type: "security", Action: "payload.invariant", Outcome: "failure", Target: target, CID: cid,
+		Detail: map[string]any{"direction": dir, "findings": fs, "trace": trace, "rejected": strict},
+	})
+	if strict {
+		return nil, &payloadInvariantError{Target: target, Findings: fs}
+	}
+	if dir == "response" {
+		if inner, n := payloadcheck.Unwrap(plain); n > 0 {
+			return inner, nil
+		}
+	}
+	return plain, nil
+}
+
+// upstreamErrorText is the reason quoted in an "external error: …" message.
+// An upstream that answers an error status with an encoded AgentMessage
+// contributes its content, so the error's content never is itself an encoded
+// message.
+func upstreamErrorText(body string) string {
+	body = strings.TrimSpace(body)
+	inner, _ := payloadcheck.Unwrap([]byte(body))
+	var m types.AgentMessage
+	if len(inner) > 0 && inner[0] == '{' && json.Unmarshal(inner, &m) == nil && (m.ID != "" || m.Type != "") {
+		if c := strings.TrimSpace(m.Content); c != "" {
+			return c
+		}
+	}
+	return body
+}
+
+func isPayloadInvariantErr(err error) bool {
+	var pe *payloadInvariantError
+	return errors.As(err, &pe)
+}
@@ -0,0 +1,34 @@
+package root
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+)
+
+// Strict mode stops a corrupt request before it is sent; a corrupt response
+// is never rejected, only unwrapped.
+func TestRootCheckPayload(t *testing.T) {
+	r := statusRoot(t)
+	msg := `{"id":"m1","from":"root","to":"payment","type":"request","content":"pay"}`
+	encoded := []byte(`"{\"id\":\"m1\",\"from\":\"root\",\"to\":\"payment\",\"type\":\"request\",\"content\":\"pay\"}"`)
+
+	t.Setenv("STRICT_PAYLOAD", "true")
+	_, err := r.checkPayload("payment", "request", "c1", encoded, "")
+	var pe *payloadInvariantError
+	if !errors.As(err, &pe) || pe.Target != "payment" || pe.Findings.Codes()[0] != payloadcheck.DoubleEncoded || !isPayloadInvariantErr(err) {
+		t.Fatalf("strict request: %v", err)
+	}
+	if got, err := r.checkPayload("payment", "response", "c1", encoded, ""); err != nil || string(got) != msg {
+		t.Fatalf("response: %s %v", got, err)
+	}
+
+	t.Setenv("STRICT_PAYLOAD", "")
+	if got, err := r.checkPayload("payment", "request", "c1", encoded, ""); err != nil || string(got) != string(encoded) {
+		t.Fatalf("lenient request: %s %v", got, err)
+	}
+	if got := upstreamErrorText(string(encoded)); got != "pay" {
+		t.Fatalf("upstreamErrorText = %q", got)
+	}
+}
@@ -0,0 +1,232 @@
+// Package root - upstream request size limits.
+//
+// Upstreams advertise how large a request they accept in the "limits" block
+// of their /status (internal/bodylimit). The health probe records the block
+// per route, and sendExternal sizes the serialized message against it before
+// signing and encryption: with HPKE the ciphertext is estimated as the
+// plaintext plus hpkeOverheadBytes. An upstream that advertises nothing gets
+// ROOT_UPSTREAM_MAX_BODY_BYTES (default 1 MiB) and
+// ROOT_UPSTREAM_MAX_METADATA_BYTES (default 64 KiB).
+//
+// When metadata overflow is on (ROOT_META_MAX_BYTES > 0, see overflow.go) the
+// forwarded metadata is first shrunk to what the upstream accepts, and by the
+// body's excess when the body is over. Whatever still does not fit fails
+// locally: 413 with a localized message and metadata error.code
+// "payload_too_large", sizes in the operator "debug" block. Sends that pass
+// carry the sizes in the response's "timings" metadata under "payload".
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+	"github.com/sage-x-project/sage-multi-agent/internal/overflow"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// hpkeOverheadBytes over-estimates what HPKE sealing adds to a body
+// (AEAD nonce and tag), so the pre-check never lets a ciphertext through
+// that the upstream would reject.
+const hpkeOverheadBytes = 64
+
+// upstreamLimits holds the limits each route advertised on its last probe.
+type upstreamLimits struct {
+	mu sync.Mutex
+	m  map[string]bodylimit.Limits
+}
+
+func newUpstreamLimits() *upstreamLimits {
+	return &upstreamLimits{m: map[string]bodylimit.Limits{}}
+}
+
+// note records what key advertised; nil (no block) forgets it.
+func (u *upstreamLimits) note(key string, l *bodylimit.Limits) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if l == nil {
+		delete(u.m, key)
+		return
+	}
+	u.m[key] = *l
+}
+
+// get returns key's limits and whether they were advertised; otherwise the
+// conservative defaults.
+func (u *upstreamLimits) get(key string) (bodylimit.Limits, bool) {
+	u.mu.Lock()
+	l, ok := u.m[key]
+	u.mu.Unlock()
+	if ok {
+		return l, true
+	}
+	return defaultUpstreamLimits(), false
+}
+
+func defaultUpstreamLimits() bodylimit.Limits {
+	return bodylimit.Limits{
+		MaxBodyBytes:     envInt("ROOT_UPSTREAM_MAX_BODY_BYTES", bodylimit.DefaultMaxBodyBytes),
+		MaxMetadataBytes: envInt("ROOT_UPSTREAM_MAX_METADATA_BYTES", bodylimit.DefaultMaxMetadataBytes),
+	}
+}
+
+// snapshot is the /status view.
+func (u *upstreamLimits) snapshot() map[string]any {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	keys := make([]string, 0, len(u.m))
+	for k := range u.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := map[string]any{}
+	for _, k := range keys {
+		out[k] = u.m[k]
+	}
+	return map[string]any{"advertised": out, "default": defaultUpstreamLimits()}
+}
+
+// payloadCheck is the result of sizing one outbound message.
+type payloadCheck struct {
+	Target        string
+	Bytes         int // serialized message; estimated ciphertext with HPKE
+	MetadataBytes int
+	Limits        bodylimit.Limits
+	Advertised    bool
+	HPKE          bool
+	Offloaded     []string // metadata keys moved to the overflow store
+}
+
+func (p payloadCheck) tooLarge() bool {
+	return (p.Limits.MaxBodyBytes > 0 && p.Bytes > p.Limits.MaxBodyBytes) ||
+		(p.Limits.MaxMetadataBytes > 0 && p.MetadataBytes > p.Limits.MaxMetadataBytes)
+}
+
+func (p payloadCheck) result() string {
+	switch {
+	case p.tooLarge():
+		return "too_large"
+	case len(p.Offloaded) > 0:
+		return "offloaded"
+	}
+	return "ok"
+}
+
+func (p payloadCheck) view() map[string]any {
+	v := map[string]any{
+		"result":           p.result(),
+		"bytes":            p.Bytes,
+		"metadataBytes":    p.MetadataBytes,
+		"maxBodyBytes":     p.Limits.MaxBodyBytes,
+		"maxMetadataBytes": p.Limits.MaxMetadataBytes,
+		"advertised":       p.Advertised,
+		"hpke":             p.HPKE,
+	}
+	if len(p.Offloaded) > 0 {
+		v["offloaded"] = p.Offloaded
+	}
+	return v
+}
+
+// payloadTooLargeError: the message does not fit the target's limits.
+type payloadTooLargeError struct {
+	Check payloadCheck
+}
+
+func (e *payloadTooLargeError) Error() string {
+	c := e.Check
+	return fmt.Sprintf("payload too large for %s: %d bytes (limit %d), metadata %d bytes (limit %d)",
+		c.Target, c.Bytes, c.Limits.MaxBodyBytes, c.MetadataBytes, c.Limits.MaxMetadataBytes)
+}
+
+// fitUpstreamLimits offloads metadata until msg fits agent's limits (when
+// overflow is on), then sizes it. It returns the serialized message.
+func (r *RootAgent) fitUpstreamLimits(agent string, msg *types.AgentMessage, hpke bool) ([]byte, payloadCheck) {
+	lim, adv := r.upLimits.get(agent)
+	pc := payloadCheck{Target: agent, Limits: lim, Advertised: adv, HPKE: hpke}
+	measure := func() []byte {
+		body, _ := json.Marshal(msg)
+		pc.Bytes = len(body)
+		if hpke {
+			pc.Bytes += hpkeOverheadBytes
+		}
+		pc.MetadataBytes = 0
+		if msg.Metadata != nil {
+			pc.MetadataBytes = overflow.Size(msg.Metadata)
+		}
+		return body
+	}
+	body := measure()
+	capBytes := envInt("ROOT_META_MAX_BYTES", 16<<10)
+	if capBytes <= 0 || msg.Metadata == nil {
+		return body, pc
+	}
+	if lim.MaxMetadataBytes > 0 {
+		capBytes = min(capBytes, lim.MaxMetadataBytes)
+	}
+	if over := pc.Bytes - lim.MaxBodyBytes; lim.MaxBodyBytes > 0 && over > 0 {
+		capBytes = min(capBytes, pc.MetadataBytes-over)
+	}
+	if pc.Offloaded = r.capForwardMeta(agent, msg, max(capBytes, 1)); len(pc.Offloaded) > 0 {
+		body = measure()
+	}
+	return body, pc
+}
+
+// checkSealedLimits sizes a body whose metadata fields were sealed after
+// fitUpstreamLimits ran (fieldenc.go); nothing is offloaded at this point.
+func (r *RootAgent) checkSealedLimits(agent string, body []byte, meta map[string]any) payloadCheck {
+	lim, adv := r.upLimits.get(agent)
+	return payloadCheck{Target: agent, Limits: lim, Advertised: adv, Bytes: len(body), MetadataBytes: overflow.Size(meta)}
+}
+
+// notePayloadCheck adds the check to out's "timings" metadata.
+func notePayloadCheck(out *types.AgentMessage, pc payloadCheck) {
+	if out == nil {
+		return
+	}
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	t, _ := out.Metadata["timings"].(map[string]any)
+	if t == nil {
+		t = map[string]any{}
+		out.Metadata["timings"] = t
+	}
+	t["payload"] = pc.view()
+}
+
+// kib rounds n bytes up to KiB for user-facing text.
+func kib(n int) int { return (n + 1023) / 1024 }
+
+// writePayloadTooLarge answers a failed pre-check with 413 and a localized
+// message; the sizes go to the audit log and the operator debug block.
+func (r *RootAgent) writePayloadTooLarge(w http.ResponseWriter, req *http.Request, lang, agent string, pe *payloadTooLargeError) {
+	c := pe.Check
+	r.runs.noteError()
+	r.audit.Emit(audit.Event{
+		Type: "error", Action: "send.payload_too_large", Outcome: "failure", Actor: requesterOf(req), Target: agent,
+		Detail: map[string]any{"payload": c.view()},
+	})
+	size, limit := c.Bytes, c.Limits.MaxBodyBytes
+	if limit <= 0 || size <= limit {
+		size, limit = c.MetadataBytes, c.Limits.MaxMetadataBytes
+	}
+	out := types.AgentMessage{
+		ID: "root-error", From: "root", To: "client", Type: "error",
+		Content:   tr(lang, "root.payload.too_large", agent, kib(size), kib(limit)),
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"error": map[string]any{"code": bodylimit.Code}, "domain": agent, "lang": lang},
+	}
+	if wantsDebug(req) {
+		out.Metadata["debug"] = map[string]any{"detail": pe.Error(), "upstream": r.externalURLFor(agent), "payload": c.view()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,78 @@
+package root
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+	"github.com/sage-x-project/sage-multi-agent/internal/overflow"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func limitsAgent(l *bodylimit.Limits) *RootAgent {
+	r := &RootAgent{logger: log.New(io.Discard, "", 0), upLimits: newUpstreamLimits(), overflow: newOverflowStore()}
+	r.upLimits.note("payment", l)
+	return r
+}
+
+func bigMessage(content string, meta int) *types.AgentMessage {
+	return &types.AgentMessage{
+		ID: "m1", From: "root", To: "payment", Type: "request", Content: content,
+		Metadata: map[string]any{"lang": "ko", "history": strings.Repeat("h", meta)},
+	}
+}
+
+// A message over the advertised body limit fails before anything is sent.
+func TestFitUpstreamLimitsLocalFailure(t *testing.T) {
+	t.Setenv("ROOT_META_MAX_BYTES", "0")
+	r := limitsAgent(&bodylimit.Limits{MaxBodyBytes: 1024, MaxMetadataBytes: 4096})
+
+	body, pc := r.fitUpstreamLimits("payment", bigMessage(strings.Repeat("x", 2000), 10), false)
+	if !pc.tooLarge() || !pc.Advertised || pc.Bytes != len(body) || pc.result() != "too_large" {
+		t.Fatalf("oversized body: %+v", pc)
+	}
+	_, pc = r.fitUpstreamLimits("payment", bigMessage("hi", 10), true)
+	if pc.tooLarge() || pc.Bytes <= hpkeOverheadBytes {
+		t.Fatalf("small body with HPKE: %+v", pc)
+	}
+	// the HPKE overhead alone tips a body at the limit over it
+	_, pc = r.fitUpstreamLimits("payment", bigMessage("hi", 10), false)
+	r.upLimits.note("payment", &bodylimit.Limits{MaxBodyBytes: pc.Bytes})
+	if _, pc = r.fitUpstreamLimits("payment", bigMessage("hi", 10), true); !pc.tooLarge() {
+		t.Fatalf("ciphertext estimate not counted: %+v", pc)
+	}
+}
+
+// With overflow on, metadata is moved out until the upstream accepts it.
+func TestFitUpstreamLimitsOffloads(t *testing.T) {
+	t.Setenv("ROOT_META_MAX_BYTES", "16384")
+	t.Setenv("ROOT_PUBLIC_URL", "http://root.test")
+	r := limitsAgent(&bodylimit.Limits{MaxBodyBytes: 2048, MaxMetadataBytes: 1024})
+
+	msg := bigMessage("hi", 8000)
+	_, pc := r.fitUpstreamLimits("payment", msg, false)
+	if pc.tooLarge() || pc.result() != "offloaded" || len(pc.Offloaded) != 1 || pc.Offloaded[0] != "history" {
+		t.Fatalf("offload: %+v", pc)
+	}
+	if _, ok := msg.Metadata["history"+overflow.RefSuffix]; !ok || msg.Metadata[overflow.MetaURL] != "http://root.test/overflow/" {
+		t.Fatalf("metadata after offload: %v", msg.Metadata)
+	}
+
+	// a body that is over on its own cannot be fixed by moving metadata
+	_, pc = r.fitUpstreamLimits("payment", bigMessage(strings.Repeat("x", 4000), 8000), false)
+	if !pc.tooLarge() {
+		t.Fatalf("oversized content passed: %+v", pc)
+	}
+}
+
+// Upstreams that advertise nothing get the configured defaults.
+func TestUpstreamLimitDefaults(t *testing.T) {
+	t.Setenv("ROOT_UPSTREAM_MAX_BODY_BYTES", "512")
+	r := limitsAgent(nil)
+	l, adv := r.upLimits.get("payment")
+	if adv || l.MaxBodyBytes != 512 || l.MaxMetadataBytes != bodylimit.DefaultMaxMetadataBytes {
+		t.Fatalf("defaults: %+v advertised=%v", l, adv)
+	}
+}
@@ -0,0 +1,173 @@
+// Package root - payments whose HPKE response did not open.
+//
+// When the request leg of an HPKE call worked but decryptIfHPKEResponse fails
+// (corrupted ciphertext, wrong kid echoed), the upstream may well have acted
+// on the request. sendExternal reports this as hpkeResponseError (error code
+// HPKE_RESPONSE_DECRYPT_FAILED) instead of a generic upstream error. For a
+// payment that is ambiguous, so forwardPayment does not treat it as a plain
+// failure: it asks the payment agent's status operation (GET
+// /lookup/{payment.idempotencyKey}) whether the charge happened and tells the
+// user which of three states applies:
+//
+//   - executed: the receipt is shown and the flow completes as usual;
+//   - not_executed: the confirm can be retried;
+//   - unknown: the lookup failed. The context is kept, including the
+//     idempotency key, so a retried confirm is deduplicated by the payment
+//     agent and cannot charge twice.
+//
+// Every step is audited (payment.ambiguous, payment.status_check).
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// hpkeResponseError: the upstream answered 2xx but its HPKE response could
+// not be decrypted.
+type hpkeResponseError struct {
+	Agent, KID string
+	Err        error
+}
+
+func (e *hpkeResponseError) Error() string {
+	return fmt.Sprintf("hpke response decrypt failed (agent=%s kid=%s): %v", e.Agent, e.KID, e.Err)
+}
+
+func (e *hpkeResponseError) Unwrap() error { return e.Err }
+
+// Outcomes of the status check.
+const (
+	payExecuted    = "executed"
+	payNotExecuted = "not_executed"
+	payUnknown     = "unknown"
+)
+
+// statusCheckTimeout bounds the lookup; it runs after the request's SLA.
+const statusCheckTimeout = 5 * time.Second
+
+// payIdempotencyKey returns the conversation's idempotency key, creating it
+// on first use. It lives until the payment context is cleared.
+func (r *RootAgent) payIdempotencyKey(cid string) string {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	c, ok := r.payContextStore.m[cid]
+	if !ok {
+		c = &payCtx{UpdatedAt: time.Now()}
+		r.payContextStore.set(cid, c)
+	}
+	if c.IdemKey == "" {
+		c.IdemKey = "idem-" + uuid.NewString()
+	}
+	return c.IdemKey
+}
+
+// checkPaymentStatus asks the payment agent whether the charge for key
+// happened.
+func (r *RootAgent) checkPaymentStatus(ctx context.Context, cid, key string) (state, orderID string, receipt map[string]any) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statusCheckTimeout)
+	defer cancel()
+	q := &types.AgentMessage{
+		ID: "status-" + uuid.NewString(), ContextID: cid, From: "root", To: "payment", Type: "request",
+		Timestamp: time.Now(), Metadata: map[string]any{"payment.idempotencyKey": key},
+	}
+	state = payUnknown
+	detail := map[string]any{"key": key}
+	out, err := r.sendExternal(ctx, "payment", "status", q)
+	switch {
+	case err != nil:
+		detail["error"] = err.Error()
+	case isErrorOut(out):
+		detail["error"] = out.Content
+	default:
+		lk, _ := out.Metadata["lookup"].(map[string]any)
+		switch s, _ := lk["state"].(string); s {
+		case "executed":
+			state = payExecuted
+			orderID, _ = lk["orderId"].(string)
+			receipt, _ = lk["receipt"].(map[string]any)
+		case "not_found":
+			state = payNotExecuted
+		default:
+			detail["error"] = fmt.Sprintf("unexpected lookup state %q", s)
+		}
+	}
+	detail["state"] = state
+	if orderID != "" {
+		detail["orderId"] = orderID
+	}
+	outcome := "success"
+	if state == payUnknown {
+		outcome = "failure"
+	}
+	r.audit.Emit(audit.Event{Type: "payment", Action: "payment.status_check", Outcome: outcome, Target: "payment", CID: cid, Detail: detail})
+	r.logger.Printf("[root][payment][status] cid=%s key=%s state=%s order=%s", cid, key, state, orderID)
+	return state, orderID, receipt
+}
+
+// writeAmbiguousPayment resolves a payment whose response did not open,
+// writes the honest answer and returns the state it found.
+func (r *RootAgent) writeAmbiguousPayment(w http.ResponseWriter, req *http.Request, cid, lang, claimedFrom, key string, he *hpkeResponseError) string {
+	r.audit.Emit(audit.Event{
+		Type: "payment", Action: "payment.ambiguous", Outcome: "failure", Actor: requesterOf(req), Target: "payment", CID: cid,
+		Detail: map[string]any{"code": errClassHPKEResponse, "key": key, "hpke_kid": he.KID, "reason": he.Err.Error()},
+	})
+	r.logger.Printf("[root][payment][ambiguous] cid=%s key=%s: %v", cid, key, he)
+
+	state, orderID, receipt := r.checkPaymentStatus(req.Context(), cid, key)
+	ref := firstNonEmpty(orderID, key)
+	text := tr(lang, "root.payment.ambiguous."+state, ref)
+	if state == payNotExecuted {
+		text = tr(lang, "root.payment.ambiguous."+state)
+	}
+	out := types.AgentMessage{
+		ID: "root-payment-" + state, ContextID: cid, From: "root", To: "client", Type: "error",
+		Content: text, Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"error":        map[string]any{"code": errClassHPKEResponse},
+			"paymentState": state,
+			"reference":    ref,
+			"domain":       "payment",
+			"lang":         lang,
+		},
+	}
+	status := http.StatusBadGateway
+	switch state {
+	case payExecuted:
+		// The charge is known: finish the flow exactly as a readable receipt would
+		out.Type = "response"
+		status = http.StatusOK
+		if receipt != nil {
+			out.Metadata["receipt"] = receipt
+		}
+		r.delPayCtx(cid)
+		r.rememberReceipt(cid, out.Metadata)
+	default:
+		// Retry allowed; the idempotency key stays with the context
+		if state == payNotExecuted {
+			r.closeAttempt(cid, attemptRejected, "")
+		}
+		r.releasePayToken(cid, claimedFrom)
+	}
+	if orderID != "" {
+		out.Metadata["orderId"] = orderID
+	}
+	if wantsDebug(req) {
+		out.Metadata["debug"] = map[string]any{"detail": he.Error(), "key": key}
+	}
+	if state != payExecuted {
+		r.runs.noteError()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+	return state
+}
@@ -0,0 +1,392 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/logclip"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// ---- Payment send + re-quotation ----
+
+// payQuote carries the budget estimate the user confirmed and the actual price
+// returned by the pricing source.
+type payQuote struct {
+	EstimatedKRW int64
+	QuotedKRW    int64
+}
+
+// forwardPayment injects payment.* metadata from the confirmed slots, sends to the
+// external payment agent and writes the HTTP response. claimedFrom is the stage
+// the confirm token was claimed from; it is restored when the send fails.
+func (r *RootAgent) forwardPayment(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang string, slots paySlots, q *payQuote, claimedFrom string) {
+	// 2) Inject required metadata
+	if msg.Metadata == nil {
+		msg.Metadata = map[string]any{}
+	}
+	msg.Metadata["lang"] = lang
+
+	// amount: fall back to budget when explicit amount is missing
+	amt := slots.AmountKRW
+	if amt <= 0 && slots.BudgetKRW > 0 {
+		amt = slots.BudgetKRW
+		msg.Metadata["payment.amountIsEstimated"] = true
+	}
+	// re-quoted price replaces the estimate; keep both for the receipt
+	if q != nil && q.QuotedKRW > 0 {
+		amt = q.QuotedKRW
+		delete(msg.Metadata, "payment.amountIsEstimated")
+		msg.Metadata["payment.estimatedKRW"] = q.EstimatedKRW
+		msg.Metadata["payment.quotedKRW"] = q.QuotedKRW
+	}
+	if amt > 0 {
+		msg.Metadata["payment.amountKRW"] = amt
+		msg.Metadata["amountKRW"] = amt // 호환 키
+	}
+
+	// recipient/method/item/merchant/shipping/card last4
+	if v := strings.TrimSpace(firstNonEmpty(slots.Recipient, slots.To)); v != "" {
+		msg.Metadata["payment.to"] = v
+		msg.Metadata["to"] = v // 호환 키
+		msg.Metadata["recipient"] = v
+	}
+	if v := strings.TrimSpace(slots.Method); v != "" {
+		msg.Metadata["payment.method"] = v
+		msg.Metadata["method"] = v
+	}
+	if v := firstNonEmpty(strings.TrimSpace(slots.Model), strings.TrimSpace(slots.Item)); v != "" {
+		msg.Metadata["payment.item"] = v
+		msg.Metadata["item"] = v
+	}
+	if v := strings.TrimSpace(slots.Merchant); v != "" {
+		msg.Metadata["payment.merchant"] = v
+	}
+	if v := strings.TrimSpace(slots.Shipping); v != "" {
+		msg.Metadata["payment.shipping"] = v
+	}
+	if v := strings.TrimSpace(slots.CardLast4); v != "" {
+		msg.Metadata["payment.cardLast4"] = v
+	}
+	if len(slots.Prov) > 0 {
+		msg.Metadata["payment.provenance"] = slots.Prov.compact()
+	}
+	// Stable per payment context: a retried confirm is deduplicated upstream
+	idemKey := r.payIdempotencyKey(cid)
+	msg.Metadata["payment.idempotencyKey"] = idemKey
+	stripHandoffDenied(msg.Metadata)
+	r.logger.Printf("[root][payment][send] injected meta: amount=%d method=%q to/recipient=%q shipping=%q merchant=%q",
+		amt, slots.Method, firstNonEmpty(slots.Recipient, slots.To), slots.Shipping, slots.Merchant)
+
+	// 3) Handle per-request SAGE/HPKE headers
+	sageRaw := strings.TrimSpace(req.Header.Get("X-SAGE-Enabled"))
+	hpkeRaw := strings.TrimSpace(req.Header.Get("X-HPKE-Enabled"))
+	r.logger.Printf("[root][payment][send] headers SAGE=%q HPKE=%q", sageRaw, hpkeRaw)
+
+	if hpkeRaw != "" && strings.EqualFold(hpkeRaw, "true") {
+		if sageRaw != "" && !strings.EqualFold(sageRaw, "true") {
+			r.releasePayToken(cid, claimedFrom)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"error":   "bad_request",
+				"message": "HPKE requires SAGE to be enabled (X-SAGE-Enabled: true)",
+			})
+			return
+		}
+	}
+	ctx2 := req.Context()
+	if sageRaw != "" {
+		ctx2 = context.WithValue(ctx2, ctxUseSAGEKey, strings.EqualFold(sageRaw, "true"))
+	}
+	if hpkeRaw != "" {
+		ctx2 = context.WithValue(ctx2, ctxHPKERawKey, hpkeRaw)
+	}
+
+	// X-SAGE-Dry-Run (admin only, e.g. cmd/loadgen): simulate instead of charging
+	op := opProcess
+	if strings.EqualFold(strings.TrimSpace(req.Header.Get("X-SAGE-Dry-Run")), "true") {
+		if hasAdminToken(req) {
+			op = "simulate"
+			msg.Metadata["payment.dryRun"] = true
+		} else {
+			r.logger.Printf("[root][payment][send] X-SAGE-Dry-Run ignored without admin token cid=%s", cid)
+		}
+	}
+	if op != "simulate" {
+		r.noteAttemptSent(cid, idemKey, amt, slots, q)
+		// Two-step flow: the confirm only places a hold (auth_capture.go)
+		if authCaptureFlow() {
+			msg.Metadata["payment.flow"] = flowAuthCapture
+		}
+	}
+
+	// 4) Send to external (actual payment)
+	r.logger.Printf("[root][payment][send] -> sendExternal(payment) op=%s", op)
+	if op != "simulate" {
+		abandonNoteFrom(ctx2).begin(abandonDispatched)
+	}
+	outPtr, err := r.sendWithSLA(ctx2, "payment", op, msg)
+	if err != nil {
+		r.logger.Printf("[root][payment][send][error] %v", err)
+		// Client left mid-send: a real charge may have happened, look it up later
+		if errors.Is(req.Context().Err(), context.Canceled) {
+			if op != "simulate" {
+				r.abandonDispatchedPayment(req, cid, claimedFrom, idemKey)
+			}
+			return
+		}
+		var he *hpkeResponseError
+		if errors.As(err, &he) {
+			r.writeAmbiguousPayment(w, req, cid, lang, claimedFrom, idemKey, he)
+			return
+		}
+		r.releasePayToken(cid, claimedFrom)
+		r.writeSendError(w, req, lang, "payment", err)
+		return
+	}
+	// Payment says the request is not a payment: nothing was charged, re-route
+	if isRedirect(outPtr) {
+		r.closeAttempt(cid, attemptRejected, "")
+		r.releasePayToken(cid, claimedFrom)
+		r.delPayCtx(cid)
+		r.followRedirect(w, req, "payment", cid, outPtr)
+		return
+	}
+	// Upstream needs more input (e.g. card last 4 digits): park and relay the question
+	if r.parkUpstreamAsk(w, req, msg, cid, lang, outPtr, &upstreamAsk{
+		Target: "payment", Msg: *msg, ClaimedFrom: claimedFrom, SageRaw: sageRaw, HPKERaw: hpkeRaw,
+	}) {
+		return
+	}
+	r.writePaymentResult(w, req, cid, lang, claimedFrom, *outPtr)
+}
+
+// writePaymentResult applies the payment agent's answer to the conversation
+// (receipt on success, stage restored otherwise) and writes it to the client.
+func (r *RootAgent) writePaymentResult(w http.ResponseWriter, req *http.Request, cid, lang, claimedFrom string, out types.AgentMessage) {
+	if strings.EqualFold(out.Type, "error") || strings.HasPrefix(strings.ToLower(out.Content), "external error:") {
+		r.logger.Printf("[root][payment][forward][ERR] cid=%s %s", cid, logclip.Clip("root", out.Content))
+		if looksLikeSigAuthFailure(strings.ToLower(out.Content)) || looksLikeContentDigestIssue(strings.ToLower(out.Content)) {
+			r.logger.Printf("[root][alert][tamper] ⚠️ cid=%s suspected tamper via gateway (payment). Check GW tamper mode/ATTACK_MESSAGE. details=%s",
+				cid, logclip.Clip("root", out.Content))
+		}
+	} else {
+		r.logger.Printf("[root][payment][forward] cid=%s -> external ok", cid)
+	}
+	// Clear context on success; otherwise let the user retry the same confirm
+	if strings.EqualFold(out.Type, "response") && !strings.HasPrefix(strings.ToLower(out.Content), "external error:") {
+		r.logger.Printf("[root][payment][ctx] delPayCtx cid=%s", cid)
+		r.delPayCtx(cid)
+		held, written := r.holdAuthorization(w, req, cid, lang, &out)
+		if written {
+			return
+		}
+		if !held {
+			r.rememberReceipt(cid, out.Metadata)
+		}
+	} else {
+		r.closeAttempt(cid, attemptRejected, "")
+		r.releasePayToken(cid, claimedFrom)
+	}
+
+	// Response
+	status := http.StatusOK
+	if code, ok := httpStatusFromAgent(&out); ok {
+		status = code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	r.presentOut(req, lang, "payment", &out, status)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// requoteTolerancePct: ROOT_REQUOTE_TOLERANCE_PCT (default 5%).
+func requoteTolerancePct() float64 {
+	if v := strings.TrimSpace(os.Getenv("ROOT_REQUOTE_TOLERANCE_PCT")); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return 5
+}
+
+// fetchQuote asks the configured pricing source (ROOT_PRICING_URL) for the actual
+// price of the item. Expected response: {"priceKRW": 123000} (amountKRW/price also accepted).
+// Returns ok=false when no source is configured or the lookup fails.
+func (r *RootAgent) fetchQuote(ctx context.Context, s paySlots) (int64, bool) {
+	base := strings.TrimSpace(os.Getenv("ROOT_PRICING_URL"))
+	if base == "" {
+		return 0, false
+	}
+	item := firstNonEmpty(strings.TrimSpace(s.Model), strings.TrimSpace(s.Item))
+	if item == "" {
+		return 0, false
+	}
+	q := url.Values{}
+	q.Set("item", item)
+	if s.Merchant != "" {
+		q.Set("merchant", s.Merchant)
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	hreq, err := http.NewRequestWithContext(ctx2, http.MethodGet, base+sep+q.Encode(), nil)
+	if err != nil {
+		return 0, false
+	}
+	resp, err := r.httpClient.Do(hreq)
+	if err != nil {
+		r.logger.Printf("[root][payment][requote] pricing lookup failed: %v", err)
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		r.logger.Printf("[root][payment][requote] pricing lookup status=%d", resp.StatusCode)
+		return 0, false
+	}
+	var m map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return 0, false
+	}
+	if n := intFrom(m, "priceKRW", "amountKRW", "price"); n > 0 {
+		return n, true
+	}
+	return 0, false
+}
+
+// maybeRequote runs after the user's "yes" when the amount is a budget estimate.
+// Within tolerance it returns the quote to forward; above tolerance it parks the
+// conversation in await_requote, writes a second confirmation and returns handled=true.
+func (r *RootAgent) maybeRequote(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang string, slots paySlots) (*payQuote, bool) {
+	if slots.AmountKRW > 0 || slots.BudgetKRW <= 0 {
+		return nil, false
+	}
+	quoted, ok := r.fetchQuote(req.Context(), slots)
+	if !ok {
+		return nil, false
+	}
+	est := slots.BudgetKRW
+	diff := quoted - est
+	if diff < 0 {
+		diff = -diff
+	}
+	pct := float64(diff) * 100 / float64(est)
+	tol := requoteTolerancePct()
+	r.logger.Printf("[root][payment][requote] cid=%s estimated=%d quoted=%d diff=%.1f%% tol=%.1f%%", cid, est, quoted, pct, tol)
+	q := &payQuote{EstimatedKRW: est, QuotedKRW: quoted}
+	if pct <= tol {
+		return q, false
+	}
+
+	token := uuid.NewString()
+	r.putPayCtxFull(cid, slots, "await_requote", token)
+	r.putPayQuote(cid, est, quoted)
+	content := map[string]string{
+		"ko": fmt.Sprintf("실제 가격이 예상과 달라요.\n- 예상: %s\n- 실제: %s\n이 금액으로 결제할까요? (예/아니오)", money.Display("ko", est, money.KRW), money.Display("ko", quoted, money.KRW)),
+		"en": fmt.Sprintf("The actual price differs from the estimate.\n- estimated: %s\n- quoted: %s\nPay the quoted amount? (yes/no)", money.Display("en", est, money.KRW), money.Display("en", quoted, money.KRW)),
+	}[langOrDefault(lang)]
+	out := types.AgentMessage{
+		ID: msg.ID + "-requote", ContextID: cid, From: "root", To: msg.From, Type: "confirm",
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"await": "payment.requote", "lang": lang, "domain": "payment", "confirmToken": token,
+			"estimatedKRW": est, "quotedKRW": quoted,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+	return nil, true
+}
+
+// handleRequoteAnswer processes the user's answer to the re-quote confirmation.
+// yes -> pay the quoted amount; no -> back to collect with the quoted price
+// prefilled as the amount, so the next confirm is not an estimate and is not
+// re-quoted again.
+func (r *RootAgent) handleRequoteAnswer(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang, token string) {
+	yes, no := parseYesNo(msg.Content)
+	if !yes && !no {
+		switch r.llmConfirmIntent(req.Context(), lang, msg.Content) {
+		case "yes":
+			yes = true
+		case "no":
+			no = true
+		}
+	}
+	est, quoted := r.getPayQuote(cid)
+	slots := r.getPayCtx(cid)
+	r.logger.Printf("[root][payment][requote] cid=%s yes=%v no=%v estimated=%d quoted=%d", cid, yes, no, est, quoted)
+
+	switch {
+	case yes:
+		if !r.claimPayToken(cid, "await_requote", token) {
+			writePaymentInFlight(w, msg, cid, lang)
+			return
+		}
+		r.forwardPayment(w, req, msg, cid, lang, slots, &payQuote{EstimatedKRW: est, QuotedKRW: quoted}, "await_requote")
+	case no:
+		if quoted > 0 {
+			slots.AmountKRW = quoted
+			slots.BudgetKRW = quoted
+		}
+		r.putPayCtxFull(cid, slots, "collect", "")
+		r.putPayQuote(cid, 0, 0)
+		out := types.AgentMessage{
+			ID: msg.ID + "-cancel", ContextID: cid, From: "root", To: msg.From, Type: "response",
+			Content: map[string]string{
+				"ko": fmt.Sprintf("결제하지 않았어요. 금액을 실제 가격(%s)으로 맞춰 두었어요. 무엇을 바꿀까요?", money.Display("ko", quoted, money.KRW)),
+				"en": fmt.Sprintf("Not paid. I set the amount to the quoted price (%s). What should I change?", money.Display("en", quoted, money.KRW)),
+			}[langOrDefault(lang)],
+			Timestamp: time.Now(),
+			Metadata:  map[string]any{"await": "payment.slots", "lang": lang, "domain": "payment", "quotedKRW": quoted},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+	default:
+		out := types.AgentMessage{
+			ID: msg.ID + "-requote", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
+			Content: map[string]string{
+				"ko": fmt.Sprintf("실제 가격 %s으로 결제할까요? (예/아니오)", money.Display("ko", quoted, money.KRW)),
+				"en": fmt.Sprintf("Pay the quoted %s? (yes/no)", money.Display("en", quoted, money.KRW)),
+			}[langOrDefault(lang)],
+			Timestamp: time.Now(),
+			Metadata:  map[string]any{"await": "payment.requote", "lang": lang, "domain": "payment", "confirmToken": token},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+// writePaymentInFlight answers a duplicate confirm while the first one is being sent.
+func writePaymentInFlight(w http.ResponseWriter, msg *types.AgentMessage, cid, lang string) {
+	out := types.AgentMessage{
+		ID: msg.ID + "-inflight", ContextID: cid, From: "root", To: msg.From, Type: "response",
+		Content: map[string]string{
+			"ko": "이미 결제를 처리하고 있어요. 잠시만 기다려 주세요.",
+			"en": "This payment is already being processed. Please wait.",
+		}[langOrDefault(lang)],
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"lang": lang, "domain": "payment", "inFlight": true},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(out)
+}
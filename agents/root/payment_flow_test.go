@@ -0,0 +1,178 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// requoteEnv is Root with a pricing source answering price and a payment
+// agent that records what it was asked to charge. hold, when set, keeps the
+// payment agent from answering until it is closed.
+type requoteEnv struct {
+	r       *RootAgent
+	quotes  *atomic.Int64
+	charges chan map[string]any
+}
+
+func newRequoteEnv(t *testing.T, price int64, hold chan struct{}) *requoteEnv {
+	t.Helper()
+	env := &requoteEnv{quotes: new(atomic.Int64), charges: make(chan map[string]any, 4)}
+	pricing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.quotes.Add(1)
+		_, _ = fmt.Fprintf(w, `{"priceKRW":%d}`, price)
+	}))
+	t.Cleanup(pricing.Close)
+	pay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		env.charges <- in.Metadata
+		if hold != nil {
+			<-hold
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "p1", From: "payment", Type: "response", Content: "결제 완료",
+			Metadata: map[string]any{"orderId": "O-1", "amountKRW": in.Metadata["payment.amountKRW"]}})
+	}))
+	t.Cleanup(pay.Close)
+
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_PRICING_URL", pricing.URL)
+	t.Setenv("ROOT_REQUOTE_TOLERANCE_PCT", "5")
+	t.Setenv("PAYMENT_URL", pay.URL)
+	t.Setenv("PAYMENT_DIRECT_URL", "")
+	env.r = NewRootAgent("root", 0)
+	env.r.logger = log.New(io.Discard, "", 0)
+	return env
+}
+
+func estimated(budget int64) paySlots {
+	return paySlots{Item: "에어팟", To: "shop", Method: "card", Shipping: "서울", BudgetKRW: budget}
+}
+
+// answer sends the user's reply to the pending re-quote confirmation.
+func (env *requoteEnv) answer(t *testing.T, cid, text string) types.AgentMessage {
+	t.Helper()
+	_, token := env.r.getStageToken(cid)
+	req := httptest.NewRequest(http.MethodPost, "/process", nil)
+	w := httptest.NewRecorder()
+	env.r.handleRequoteAnswer(w, req, &types.AgentMessage{ID: "m2", From: "client", Content: text}, cid, "ko", token)
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return out
+}
+
+// requote runs the step after the user's first "yes".
+func (env *requoteEnv) requote(t *testing.T, cid string, s paySlots) (*payQuote, bool, types.AgentMessage) {
+	t.Helper()
+	env.r.putPayCtxFull(cid, s, "sending", "")
+	req := httptest.NewRequest(http.MethodPost, "/process", nil)
+	w := httptest.NewRecorder()
+	q, handled := env.r.maybeRequote(w, req, &types.AgentMessage{ID: "m1", From: "client"}, cid, "ko", s)
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return q, handled, out
+}
+
+func TestRequoteWithinTolerance(t *testing.T) {
+	const cid = "cid-requote-within"
+	env := newRequoteEnv(t, 103000, nil)
+
+	q, handled, _ := env.requote(t, cid, estimated(100000))
+	if handled || q == nil || q.EstimatedKRW != 100000 || q.QuotedKRW != 103000 {
+		t.Fatalf("within tolerance: handled=%v quote=%+v", handled, q)
+	}
+	// an explicit amount is not an estimate: no lookup
+	s := estimated(100000)
+	s.AmountKRW = 100000
+	if q, handled, _ := env.requote(t, cid, s); q != nil || handled || env.quotes.Load() != 1 {
+		t.Fatalf("explicit amount re-quoted: %+v %v lookups=%d", q, handled, env.quotes.Load())
+	}
+}
+
+func TestRequoteAboveToleranceAccept(t *testing.T) {
+	const cid = "cid-requote-accept"
+	env := newRequoteEnv(t, 150000, nil)
+
+	_, handled, out := env.requote(t, cid, estimated(100000))
+	if stage, token := env.r.getStageToken(cid); !handled || stage != "await_requote" || token == "" || out.Metadata["quotedKRW"] != float64(150000) {
+		t.Fatalf("above tolerance: handled=%v stage=%s out=%+v", handled, stage, out)
+	}
+	env.answer(t, cid, "예")
+	m := <-env.charges
+	if m["payment.amountKRW"] != float64(150000) || m["payment.estimatedKRW"] != float64(100000) || m["payment.amountIsEstimated"] != nil {
+		t.Fatalf("charged with %v", m)
+	}
+}
+
+// Declining keeps the quoted price as the amount: the next confirm pays it
+// without asking the pricing source again.
+func TestRequoteDecline(t *testing.T) {
+	const cid = "cid-requote-decline"
+	env := newRequoteEnv(t, 150000, nil)
+
+	env.requote(t, cid, estimated(100000))
+	out := env.answer(t, cid, "아니오")
+	s := env.r.getPayCtx(cid)
+	if stage, _ := env.r.getStageToken(cid); stage != "collect" || s.AmountKRW != 150000 || s.BudgetKRW != 150000 {
+		t.Fatalf("after decline: stage=%s slots=%+v", stage, s)
+	}
+	if out.Metadata["await"] != "payment.slots" || !strings.Contains(out.Content, "150,000") {
+		t.Fatalf("decline answer: %+v", out)
+	}
+	if q, handled, _ := env.requote(t, cid, s); q != nil || handled || env.quotes.Load() != 1 {
+		t.Fatalf("declined quote asked again: %+v %v lookups=%d", q, handled, env.quotes.Load())
+	}
+	select {
+	case m := <-env.charges:
+		t.Fatalf("charged after a decline: %v", m)
+	default:
+	}
+}
+
+// A second "yes" while the first is being sent is answered as in flight and
+// charges nothing.
+func TestRequoteSendingStage(t *testing.T) {
+	const cid = "cid-requote-sending"
+	hold := make(chan struct{})
+	env := newRequoteEnv(t, 150000, hold)
+
+	env.requote(t, cid, estimated(100000))
+	_, token := env.r.getStageToken(cid)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodPost, "/process", nil)
+		env.r.handleRequoteAnswer(httptest.NewRecorder(), req, &types.AgentMessage{ID: "m2", From: "client", Content: "예"}, cid, "ko", token)
+	}()
+	<-env.charges // the first confirm reached the payment agent
+	if stage, _ := env.r.getStageToken(cid); stage != "sending" {
+		t.Fatalf("stage while sending = %q", stage)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/process", nil)
+	w := httptest.NewRecorder()
+	env.r.handleRequoteAnswer(w, req, &types.AgentMessage{ID: "m3", From: "client", Content: "예"}, cid, "ko", token)
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	if !strings.HasSuffix(out.ID, "-inflight") {
+		t.Fatalf("second confirm: %+v", out)
+	}
+	close(hold)
+	<-done
+	select {
+	case m := <-env.charges:
+		t.Fatalf("charged twice: %v", m)
+	default:
+	}
+}
@@ -9,9 +9,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 )
 
 // Normalized payment slots used across Root → Payment agent.
@@ -28,43 +28,57 @@ type paySlots struct {
 	Shipping  string
 	CardLast4 string
 	Note      string
+
+	Prov slotProv // per-field provenance (see slot_provenance.go)
 }
 
 // Merge (right-hand side wins)
 func mergePaySlots(a, b paySlots) paySlots {
 	out := a
+	out.Prov = a.Prov.clone()
 	if strings.TrimSpace(b.Mode) != "" {
 		out.Mode = strings.TrimSpace(b.Mode)
+		takeProv(&out.Prov, b.Prov, "mode")
 	}
 	if strings.TrimSpace(b.To) != "" {
 		out.To = strings.TrimSpace(b.To)
+		takeProv(&out.Prov, b.Prov, "to")
 	}
 	if b.AmountKRW > 0 {
 		out.AmountKRW = b.AmountKRW
+		takeProv(&out.Prov, b.Prov, "amountKRW")
 	}
 	if b.BudgetKRW > 0 {
 		out.BudgetKRW = b.BudgetKRW
+		takeProv(&out.Prov, b.Prov, "budgetKRW")
 	}
 	if strings.TrimSpace(b.Method) != "" {
 		out.Method = strings.TrimSpace(b.Method)
+		takeProv(&out.Prov, b.Prov, "method")
 	}
 	if strings.TrimSpace(b.Item) != "" {
 		out.Item = strings.TrimSpace(b.Item)
+		takeProv(&out.Prov, b.Prov, "item")
 	}
 	if strings.TrimSpace(b.Model) != "" {
 		out.Model = strings.TrimSpace(b.Model)
+		takeProv(&out.Prov, b.Prov, "model")
 	}
 	if strings.TrimSpace(b.Merchant) != "" {
 		out.Merchant = strings.TrimSpace(b.Merchant)
+		takeProv(&out.Prov, b.Prov, "merchant")
 	}
 	if strings.TrimSpace(b.Shipping) != "" {
 		out.Shipping = strings.TrimSpace(b.Shipping)
+		takeProv(&out.Prov, b.Prov, "shipping")
 	}
 	if strings.TrimSpace(b.CardLast4) != "" {
 		out.CardLast4 = strings.TrimSpace(b.CardLast4)
+		takeProv(&out.Prov, b.Prov, "cardLast4")
 	}
 	if strings.TrimSpace(b.Note) != "" {
 		out.Note = strings.TrimSpace(b.Note)
+		takeProv(&out.Prov, b.Prov, "note")
 	}
 	return out
 }
@@ -104,13 +118,24 @@ func buildPaymentPreview(lang string, s paySlots) string {
 	if merchant == "" {
 		merchant = "-"
 	}
+	// annotate assumed (defaulted / low-confidence) values
+	note := func(v, field string) string {
+		if v == "-" {
+			return v
+		}
+		return v + provNote(lang, s.Prov, field)
+	}
+	if strings.TrimSpace(s.Item) != "" {
+		item = note(item, "item")
+	} else {
+		item = note(item, "model")
+	}
+	method = note(method, "method")
+	ship = note(ship, "shipping")
+	merchant = note(merchant, "merchant")
 	budget := "-"
 	if s.BudgetKRW > 0 {
-		if lang == "ko" {
-			budget = withComma(s.BudgetKRW) + "원"
-		} else {
-			budget = "₩" + withComma(s.BudgetKRW)
-		}
+		budget = money.Display(lang, s.BudgetKRW, money.KRW)
 	}
 	memo := "-" // 비어있으면 하이픈 고정
 
@@ -122,34 +147,14 @@ func buildPaymentPreview(lang string, s paySlots) string {
 		item, method, ship, budget, merchant, memo)
 }
 
-func withComma(n int64) string {
-	s := fmt.Sprintf("%d", n)
-	if n < 0 {
-		return "-" + withComma(-n)
-	}
-	var out []byte
-	cnt := 0
-	for i := len(s) - 1; i >= 0; i-- {
-		out = append(out, s[i])
-		cnt++
-		if cnt%3 == 0 && i != 0 {
-			out = append(out, ',')
-		}
-	}
-	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
-		out[i], out[j] = out[j], out[i]
-	}
-	return string(out)
-}
-
 func (r *RootAgent) buildConfirmPromptLLM(ctx context.Context, lang string, s paySlots) string {
+	if confirmTemplateMode() {
+		return confirmTemplate(lang)
+	}
 	r.ensureLLM()
 	if r.llmClient == nil {
         // Fallback to fixed prompt
-		if lang == "ko" {
-			return "이대로 진행할까요? (예/아니오)"
-		}
-		return "Proceed with this? (yes/no)"
+		return confirmTemplate(lang)
 	}
 
 	sys := map[string]string{
@@ -169,7 +174,7 @@ Rules:
 - Output in English.`,
 	}[lang]
 
-	styleSeed := fmt.Sprintf("%d", time.Now().UnixNano()%7919)
+	styleSeed := fmt.Sprintf("%d", styleSeedFrom(ctx))
 
     // Provide only slot keywords (LLM crafts natural language)
 	var b strings.Builder
@@ -216,7 +221,7 @@ Rules:
 				b.WriteString(", ")
 			}
 			first = false
-			fmt.Fprintf(&b, "예산=%s", formatKRW(s.BudgetKRW))
+			fmt.Fprintf(&b, "예산=%s", money.FormatKRW("ko", s.BudgetKRW))
 		}
 		b.WriteString("\n")
 		fmt.Fprintf(&b, "styleSeed: %s\n", styleSeed)
@@ -264,19 +269,16 @@ Rules:
 				b.WriteString(", ")
 			}
 			first = false
-			fmt.Fprintf(&b, "budget=%s", formatKRW(s.BudgetKRW))
+			fmt.Fprintf(&b, "budget=%s", money.FormatKRW("en", s.BudgetKRW))
 		}
 		b.WriteString("\n")
 		fmt.Fprintf(&b, "styleSeed: %s\n", styleSeed)
 		fmt.Fprintf(&b, "Output: ONE short yes/no English question only\n")
 	}
 
-	out, err := r.llmClient.Chat(ctx, sys, b.String())
+	out, err := r.chatUser(ctx, lang, sys, b.String())
 	if err != nil {
-		if lang == "ko" {
-			return "이대로 진행할까요? (예/아니오)"
-		}
-		return "Proceed with this? (yes/no)"
+		return confirmTemplate(lang)
 	}
 	return strings.TrimSpace(out)
 }
@@ -288,7 +290,7 @@ func parseYesNo(s string) (yes bool, no bool) {
 
     // Strong positive (various affirmative/imperative cues, including abbreviated forms)
 	pos := []string{
-		"예", "네", "응", "ㅇㅇ", "ㅇㅋ", "ok", "okay", "그래", "좋아", "진행", "진행해", "진행해줘", "진행하세요",
+		"예", "네", "응", "ㅇㅇ", "ㅇㅋ", "yes", "ok", "okay", "그래", "좋아", "진행", "진행해", "진행해줘", "진행하세요",
 		"구매", "구매해", "구매해줘", "사줘", "사 주세요", "결제", "결제해", "결제해줘", "바로", "확정", "고고", "ㄱㄱ",
 	}
     // Strong negative
@@ -356,6 +358,7 @@ func extractPaymentSlots(msg *types.AgentMessage) (s paySlots, missing []string,
 		s.Note = getS("payment.note", "note", "memo")
 		s.AmountKRW = getI("payment.amountKRW", "amountKRW", "amount")
 		s.BudgetKRW = getI("payment.budgetKRW", "budgetKRW", "budget")
+		tagPaySlots(&s, provMetadata)
 	}
 
     // JSON body
@@ -366,6 +369,7 @@ func extractPaymentSlots(msg *types.AgentMessage) (s paySlots, missing []string,
 			setIf := func(dst *string, key string) {
 				if v, ok := m[key].(string); ok && strings.TrimSpace(v) != "" {
 					*dst = strings.TrimSpace(v)
+					markProv(&s.Prov, key, slotSource{Src: provUser})
 				}
 			}
 			setIf(&s.To, "to")
@@ -395,6 +399,7 @@ func extractPaymentSlots(msg *types.AgentMessage) (s paySlots, missing []string,
 					}
 				}
 			}
+			tagPaySlots(&s, provUser)
 		}
 	}
 
@@ -455,6 +460,7 @@ func extractPaymentSlots(msg *types.AgentMessage) (s paySlots, missing []string,
 		}
 	}
 
+	tagPaySlots(&s, provRule)
 	missing = computeMissingPayment(s)
 	ok = true
 	return
@@ -0,0 +1,23 @@
+package root
+
+import (
+	"strings"
+	"testing"
+)
+
+// The preview shows the budget in the reader's format, with the approximate
+// conversion where the locale thinks in another currency.
+func TestPaymentPreviewAmounts(t *testing.T) {
+	s := paySlots{Item: "맥북", Method: "card", Shipping: "서울시 강남구", Merchant: "쿠팡", BudgetKRW: 2500000}
+	for lang, want := range map[string]string{
+		"ko": "- 예산: 2,500,000원\n",
+		"en": "- budget: ₩2,500,000 (approx. $1,852)\n",
+	} {
+		if got := buildPaymentPreview(lang, s); !strings.Contains(got, want) {
+			t.Errorf("%s preview lacks %q:\n%s", lang, want, got)
+		}
+	}
+	if got := buildPaymentPreview("en", paySlots{Item: "맥북"}); !strings.Contains(got, "- budget: -\n") {
+		t.Errorf("no budget:\n%s", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Package root - planning response contract shared by the local LLM fallback
+// and external planning agents.
+//
+// Contract: metadata["planning.plan"] = {goal, timeframe, steps[], risks[], source}
+// where source is "local" (Root answered in-proc) or "external".
+//
+// The local path asks the model once for a JSON plan. A reply that is not a
+// usable plan is not retried: its summary or prose becomes the answer and the
+// steps are split from its lines. The routing metadata records which source
+// answered and, for local answers, whether the plan is the model's ("llm") or
+// assembled ("fallback").
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/llm"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+type planningPlan struct {
+	Goal      string   `json:"goal"`
+	Timeframe string   `json:"timeframe,omitempty"`
+	Steps     []string `json:"steps"`
+	Risks     []string `json:"risks"`
+	Source    string   `json:"source"` // "local" | "external"
+
+	// Origin of a local plan: "llm" (the model's JSON) or "fallback"
+	// (assembled from prose). Reported in the routing metadata, not the contract.
+	Origin string `json:"-"`
+}
+
+// planFailedText answers when no plan could be produced at all.
+var planFailedText = map[string]map[string]string{
+	"no_llm": {
+		"ko": "계획 요약을 생성하려면 LLM 설정이 필요해요.",
+		"en": "LLM is required to generate a plan.",
+	},
+	"failed": {
+		"ko": "요청하신 계획을 정리하지 못했어요. 핵심 목표/기간/제약을 한 번 더 알려주세요.",
+		"en": "I couldn't generate the plan. Please share goal/timeframe/constraints again.",
+	},
+}
+
+// asMeta converts the plan into a plain map so it survives JSON round-trips unchanged.
+func (p planningPlan) asMeta() map[string]any {
+	steps := p.Steps
+	if steps == nil {
+		steps = []string{}
+	}
+	risks := p.Risks
+	if risks == nil {
+		risks = []string{}
+	}
+	return map[string]any{
+		"goal":      p.Goal,
+		"timeframe": p.Timeframe,
+		"steps":     steps,
+		"risks":     risks,
+		"source":    p.Source,
+	}
+}
+
+// llmPlanningStructured asks the model once for a JSON plan and renders prose
+// from it. When the reply is not a usable plan the answer is its summary or
+// prose and the steps are split from its lines; there is no second call.
+func (r *RootAgent) llmPlanningStructured(ctx context.Context, lang, userText string, s planningSlots) (string, planningPlan) {
+	plan := planningPlan{Goal: s.Task, Timeframe: s.Timeframe, Source: "local", Origin: "fallback"}
+	if plan.Goal == "" {
+		plan.Goal = strings.TrimSpace(userText)
+	}
+
+	failed := "no_llm"
+	r.ensureLLM()
+	if r.llmClient != nil {
+		sys := map[string]string{
+			"ko": `너는 일정/계획 도우미야. 아래 JSON 한 개만 출력해. 설명/코드블록 금지.
+{"goal":"목표","timeframe":"기간","steps":["핵심 단계",...],"risks":["리스크/준비물",...],"summary":"4~6줄 요약(제안형 어조)"}`,
+			"en": `You are a planning assistant. Output exactly one JSON object, no prose or code fences.
+{"goal":"...","timeframe":"...","steps":["key step",...],"risks":["risk/prep",...],"summary":"4-6 short lines, suggestive tone"}`,
+		}[langOrDefault(lang)]
+		usr := fmt.Sprintf("Language=%s\n%s\nTask=%s\nTimeframe=%s\nContext=%s\nUserText=%s",
+			langOrDefault(lang), todayLine(ctx), s.Task, s.Timeframe, s.Context, strings.TrimSpace(userText))
+
+		failed = "failed"
+		raw, err := r.llmClient.Chat(llm.WithPurpose(ctx, llm.PurposePlanning), sys, usr)
+		if err != nil {
+			r.logger.Printf("[root][planning] structured plan: %v", err)
+		} else {
+			var out struct {
+				Goal      string   `json:"goal"`
+				Timeframe string   `json:"timeframe"`
+				Steps     []string `json:"steps"`
+				Risks     []string `json:"risks"`
+				Summary   string   `json:"summary"`
+			}
+			if b := extractFirstJSON(raw); b != nil && json.Unmarshal(b, &out) == nil {
+				steps := cleanList(out.Steps)
+				if strings.TrimSpace(out.Goal) != "" && len(steps) > 0 {
+					plan.Goal = strings.TrimSpace(out.Goal)
+					plan.Timeframe = firstNonEmpty(strings.TrimSpace(out.Timeframe), s.Timeframe)
+					plan.Steps = steps
+					plan.Risks = cleanList(out.Risks)
+					plan.Origin = "llm"
+					answer := strings.TrimSpace(out.Summary)
+					if answer == "" {
+						answer = renderPlanProse(lang, plan)
+					}
+					return answer, plan
+				}
+			}
+			r.logger.Printf("[root][planning] structured plan invalid; assembling steps from the reply")
+			if answer := replyProse(raw, out.Summary); answer != "" {
+				plan.Steps = stepsFromProse(answer)
+				return answer, plan
+			}
+		}
+	}
+	return planFailedText[failed][langOrDefault(lang)], plan
+}
+
+// replyProse is the usable text of a reply that was not a valid plan: its
+// summary, else the reply itself unless it is (broken) JSON.
+func replyProse(raw, summary string) string {
+	if s := strings.TrimSpace(summary); s != "" {
+		return s
+	}
+	t := strings.TrimSpace(strings.Trim(strings.TrimSpace(raw), "`"))
+	if strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") || strings.HasPrefix(t, "json") {
+		return ""
+	}
+	return t
+}
+
+// renderPlanProse builds a short answer from a structured plan.
+func renderPlanProse(lang string, p planningPlan) string {
+	var b strings.Builder
+	if langOrDefault(lang) == "ko" {
+		fmt.Fprintf(&b, "목표: %s", p.Goal)
+		if p.Timeframe != "" {
+			fmt.Fprintf(&b, "\n기간: %s", p.Timeframe)
+		}
+		fmt.Fprintf(&b, "\n핵심 단계: %s", strings.Join(p.Steps, " → "))
+		if len(p.Risks) > 0 {
+			fmt.Fprintf(&b, "\n리스크/준비물: %s", strings.Join(p.Risks, ", "))
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Goal: %s", p.Goal)
+	if p.Timeframe != "" {
+		fmt.Fprintf(&b, "\nTimeframe: %s", p.Timeframe)
+	}
+	fmt.Fprintf(&b, "\nKey steps: %s", strings.Join(p.Steps, " → "))
+	if len(p.Risks) > 0 {
+		fmt.Fprintf(&b, "\nRisks/prep: %s", strings.Join(p.Risks, ", "))
+	}
+	return b.String()
+}
+
+// stepsFromProse splits a prose answer into non-empty lines (bullets/numbers stripped).
+func stepsFromProse(s string) []string {
+	var out []string
+	for _, ln := range strings.Split(s, "\n") {
+		ln = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(ln), "-*•0123456789.) "))
+		if ln != "" {
+			out = append(out, ln)
+		}
+	}
+	return out
+}
+
+func cleanList(in []string) []string {
+	var out []string
+	for _, v := range in {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// normalizePlanningResponse maps an external planning response onto the same
+// planning.plan contract when it carries compatible fields (plan/steps/goal).
+func normalizePlanningResponse(out *types.AgentMessage, s planningSlots) {
+	if out == nil || strings.EqualFold(out.Type, "error") {
+		return
+	}
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	src := out.Metadata
+	if m, ok := out.Metadata["planning.plan"].(map[string]any); ok {
+		src = m
+	} else if m, ok := out.Metadata["plan"].(map[string]any); ok {
+		src = m
+	}
+	plan := planningPlan{
+		Goal:      firstNonEmpty(strFrom(src, "goal", "task"), s.Task),
+		Timeframe: firstNonEmpty(strFrom(src, "timeframe", "when"), s.Timeframe),
+		Steps:     listFrom(src, "steps"),
+		Risks:     listFrom(src, "risks"),
+		Source:    "external",
+	}
+	if len(plan.Steps) == 0 {
+		plan.Steps = stepsFromProse(out.Content)
+	}
+	out.Metadata["planning.plan"] = plan.asMeta()
+	out.Metadata["planning.source"] = "external"
+}
+
+// listFrom reads a []string from a decoded JSON value ([]any or []string).
+func listFrom(m map[string]any, key string) []string {
+	switch t := m[key].(type) {
+	case []string:
+		return cleanList(t)
+	case []any:
+		var out []string
+		for _, v := range t {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+		return out
+	}
+	return nil
+}
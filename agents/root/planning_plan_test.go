@@ -0,0 +1,127 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*/*.golden from the current output")
+
+// planLLM answers the structured planning prompt with reply (or err) and
+// counts the calls; any other prompt is an error so a stray call shows up.
+type planLLM struct {
+	reply string
+	err   error
+	calls int
+}
+
+func (m *planLLM) Chat(_ context.Context, system, _ string) (string, error) {
+	if !strings.Contains(system, `"summary":"`) {
+		return "", errors.New("unexpected prompt")
+	}
+	m.calls++
+	return m.reply, m.err
+}
+
+// checkGolden compares v, as indented JSON, with testdata/planning/name.golden.
+func checkGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	checkGoldenIn(t, "planning", name, v)
+}
+
+// checkGoldenIn compares v, as indented JSON, with testdata/dir/name.golden.
+func checkGoldenIn(t *testing.T, dir, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", dir, name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to record)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+// The local planning answer, its plan and the routing explanation, for each
+// kind of model reply. Replies that are not a usable plan fall back without
+// a second call.
+func TestPlanningStructuredGolden(t *testing.T) {
+	for _, k := range []string{"LLM_PROVIDER", "OPENAI_API_KEY", "LLM_API_KEY", "OPENAI_BASE_URL", "LLM_BASE_URL", "LLM_URL", "LLM_ALLOW_NO_KEY"} {
+		t.Setenv(k, "")
+	}
+	slots := planningSlots{Task: "busan trip", Timeframe: "next week"}
+	cases := []struct {
+		name, lang string
+		llm        *planLLM // nil: no LLM configured
+		wantCalls  int
+	}{
+		{"llm_json", "en", &planLLM{reply: `{"goal":"Busan trip","timeframe":"next week","steps":["book KTX","reserve a hotel in Haeundae"],"risks":["typhoon season"],"summary":"Book the KTX early and stay near Haeundae."}`}, 1},
+		{"llm_fenced", "ko", &planLLM{reply: "계획입니다:\n```json\n{\"goal\":\"부산 여행\",\"steps\":[\"KTX 예매\",\"숙소 예약\"],\"risks\":[]}\n```"}, 1},
+		{"fallback_prose", "en", &planLLM{reply: "1. Book the KTX\n2. Reserve a hotel\n- Pack for rain"}, 1},
+		{"fallback_summary", "en", &planLLM{reply: `{"goal":"Busan trip","summary":"Take the KTX on Friday.\nStay two nights in Haeundae."}`}, 1},
+		{"fallback_broken_json", "ko", &planLLM{reply: `{"goal":"부산 여행","steps":["KTX 예매",`}, 1},
+		{"llm_error", "ko", &planLLM{err: errors.New("upstream 503")}, 1},
+		{"no_llm", "en", nil, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &RootAgent{logger: log.New(io.Discard, "", 0)}
+			if tc.llm != nil {
+				r.llmClient = tc.llm
+			}
+			ctx := withRouting(context.Background())
+			answer, plan := r.llmPlanningStructured(ctx, tc.lang, "plan my busan trip", slots)
+			routingFrom(ctx).answeredBy("planning", "local", plan.Origin)
+			if tc.llm != nil && tc.llm.calls != tc.wantCalls {
+				t.Fatalf("LLM called %d times, want %d", tc.llm.calls, tc.wantCalls)
+			}
+			checkGolden(t, tc.name, map[string]any{
+				"content": answer,
+				"plan":    plan.asMeta(),
+				"routing": routingFrom(ctx).meta(),
+			})
+		})
+	}
+}
+
+// An external agent's own response shape is mapped onto planning.plan.
+func TestNormalizeExternalPlanningGolden(t *testing.T) {
+	slots := planningSlots{Task: "busan trip", Timeframe: "next week"}
+	cases := map[string]types.AgentMessage{
+		"external_plan": {Type: "response", Content: "Here is your plan.", Metadata: map[string]any{
+			"plan": map[string]any{"task": "Busan trip", "when": "Fri-Sun", "steps": []any{"book KTX", " ", "reserve hotel"}, "risks": []any{"rain"}},
+		}},
+		"external_prose": {Type: "response", Content: "1) Book the KTX\n2) Reserve a hotel"},
+	}
+	for name, out := range cases {
+		t.Run(name, func(t *testing.T) {
+			normalizePlanningResponse(&out, slots)
+			checkGolden(t, name, out.Metadata)
+		})
+	}
+}
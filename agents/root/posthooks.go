@@ -0,0 +1,28 @@
+// Package root - post-processing hooks on Root's chat answers.
+//
+// Answers Root composes itself in chat mode pass the "root" hook chain
+// (internal/posthook) before they are written; answers relayed from
+// payment/medical already went through those agents' chains. A failed
+// fail-closed hook turns the answer into a 502 posthook_failed error.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/posthook"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// writeChat runs the hooks on out and writes it.
+func (r *RootAgent) writeChat(ctx context.Context, w http.ResponseWriter, cid string, out types.AgentMessage) {
+	status := http.StatusOK
+	if results, err := r.hooks.Apply(ctx, cid, &out); err != nil {
+		out = posthook.FailureMessage(&out, results, err)
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+}
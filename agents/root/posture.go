@@ -0,0 +1,319 @@
+// Package root - per-request security posture.
+//
+// Every /process response carries one normalized posture so a UI can show a
+// badge without reading the individual security fields: metadata
+// security.posture {level, label, factors} and the X-SAGE-Posture header
+// (level only). The inputs are collected while the turn runs: whether the
+// client→root request was signed, and for each upstream hop whether it was
+// signed, HPKE-encrypted, accepted by the upstream's signature/Content-Digest
+// check, or rejected as tampered, plus the connection it used (TLS or
+// cleartext, conn_trace.go) and whether it went to the agent directly because
+// the gateway was unreachable (fallback.go). postureRules maps them to a level; the
+// first matching rule wins and "attack_detected" comes first, so a tamper
+// signal overrides everything else.
+//
+// Root does not verify signatures on /process itself, so the client hop
+// counts as signed when the RFC 9421 headers are present and is reported as
+// client.signed_unverified. The connection transport only adds a factor
+// (transport.tls / transport.cleartext / transport.mixed): signatures and
+// HPKE protect the message either way. Likewise a hop that bypassed the
+// gateway only adds gateway.bypassed: the gateway's observation (and any
+// policy it enforces) did not apply to it. A non-HPKE hop with sealed
+// metadata fields (fieldenc.go) reports upstream.field_encrypted, never
+// upstream.hpke, and counts as plaintext for the level. The hops themselves
+// are listed in metadata security.timeline.
+package root
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Posture levels, from best to worst.
+const (
+	postureProtected   = "protected"
+	posturePartial     = "partially_protected"
+	postureUnprotected = "unprotected"
+	postureAttack      = "attack_detected"
+)
+
+var postureLevels = []string{postureProtected, posturePartial, postureUnprotected, postureAttack}
+
+var postureLabels = map[string]string{
+	postureProtected:   "Protected",
+	posturePartial:     "Partially protected",
+	postureUnprotected: "Unprotected",
+	postureAttack:      "Attack detected",
+}
+
+// postureInputs is what one /process turn observed.
+type postureInputs struct {
+	ClientSigned   bool // Signature + Signature-Input on the inbound request
+	Hops           int  // upstream calls made
+	SignedHops     int  // ... sent with an RFC 9421 signature
+	HPKEHops       int  // ... sent HPKE-encrypted
+	DigestVerified int  // signed hops the upstream accepted (signature + Content-Digest checked)
+	Tamper         bool // upstream rejected signature/digest, or an HPKE response failed to open
+	TLSHops        int  // ... whose connection was TLS
+	CleartextHops  int  // ... whose connection was plain HTTP
+	BypassedHops   int  // ... sent to the direct fallback instead of the gateway
+	FieldEncHops   int  // ... sent without HPKE but with sealed metadata fields (fieldenc.go)
+}
+
+// postureRule is one row of the mapping table.
+type postureRule struct {
+	level string
+	match func(in postureInputs) bool
+}
+
+// postureRules is the whole input→level mapping, evaluated top to bottom.
+var postureRules = []postureRule{
+	{postureAttack, func(in postureInputs) bool { return in.Tamper }},
+	{postureProtected, func(in postureInputs) bool {
+		return in.ClientSigned && in.SignedHops == in.Hops && in.HPKEHops == in.Hops && in.DigestVerified == in.Hops
+	}},
+	{postureUnprotected, func(in postureInputs) bool { return !in.ClientSigned && in.SignedHops == 0 }},
+	{posturePartial, func(postureInputs) bool { return true }},
+}
+
+func (in postureInputs) level() string {
+	for _, rule := range postureRules {
+		if rule.match(in) {
+			return rule.level
+		}
+	}
+	return posturePartial
+}
+
+// factors lists what contributed to the level, in a stable order.
+func (in postureInputs) factors() []string {
+	var f []string
+	if in.ClientSigned {
+		f = append(f, "client.signed_unverified")
+	} else {
+		f = append(f, "client.unsigned")
+	}
+	switch {
+	case in.Hops == 0:
+		f = append(f, "upstream.none")
+	case in.SignedHops == in.Hops:
+		f = append(f, "upstream.signed")
+	case in.SignedHops == 0:
+		f = append(f, "upstream.unsigned")
+	default:
+		f = append(f, "upstream.partially_signed")
+	}
+	if in.Hops > 0 {
+		switch {
+		case in.HPKEHops == in.Hops:
+			f = append(f, "upstream.hpke")
+		case in.HPKEHops == 0 && in.FieldEncHops == in.Hops:
+			f = append(f, "upstream.field_encrypted")
+		case in.HPKEHops == 0:
+			f = append(f, "upstream.plaintext")
+		default:
+			f = append(f, "upstream.partially_hpke")
+		}
+		if in.FieldEncHops > 0 && in.FieldEncHops < in.Hops {
+			f = append(f, "upstream.field_encrypted")
+		}
+		if in.SignedHops > 0 && in.DigestVerified == in.SignedHops {
+			f = append(f, "digest.verified")
+		}
+		switch {
+		case in.TLSHops+in.CleartextHops == 0:
+		case in.CleartextHops == 0:
+			f = append(f, "transport.tls")
+		case in.TLSHops == 0:
+			f = append(f, "transport.cleartext")
+		default:
+			f = append(f, "transport.mixed")
+		}
+	}
+	if in.BypassedHops > 0 {
+		f = append(f, "gateway.bypassed")
+	}
+	if in.Tamper {
+		f = append(f, "tamper.suspected")
+	}
+	return f
+}
+
+// postureHop is one recorded upstream call, kept next to the aggregate
+// counts so the header self-check (verified.go) can re-derive from events.
+type postureHop struct {
+	Target                         string
+	Via                            string // "" or routedViaFallback
+	Signed, HPKE, Accepted, Tamper bool
+	FieldEncrypted                 bool         // not HPKE, but sealed metadata fields (fieldenc.go)
+	Conn                           *connSummary // nil: no connection was obtained
+}
+
+// postureTrace collects postureInputs for one request; upstream hops may be
+// recorded from several goroutines.
+type postureTrace struct {
+	mu   sync.Mutex
+	in   postureInputs
+	hops []postureHop
+	// responseTamper: a response failed verification after the hop was accepted
+	responseTamper bool
+}
+
+const ctxPostureKey ctxKey = "posture"
+
+// withPostureTrace starts a trace for req, recording the client hop.
+func withPostureTrace(req *http.Request) context.Context {
+	pt := &postureTrace{}
+	pt.in.ClientSigned = req.Header.Get("Signature") != "" && req.Header.Get("Signature-Input") != ""
+	return context.WithValue(req.Context(), ctxPostureKey, pt)
+}
+
+func postureFrom(ctx context.Context) *postureTrace {
+	pt, _ := ctx.Value(ctxPostureKey).(*postureTrace)
+	return pt
+}
+
+// noteUpstream records one upstream hop. Accepted means the upstream took the
+// request (for a signed hop: its signature and Content-Digest checks passed);
+// Via is the route's routedVia value ("" for the configured URL).
+func (pt *postureTrace) noteUpstream(h postureHop) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.in.Hops++
+	pt.hops = append(pt.hops, h)
+	if h.Via != "" {
+		pt.in.BypassedHops++
+	}
+	if h.Signed {
+		pt.in.SignedHops++
+		if h.Accepted {
+			pt.in.DigestVerified++
+		}
+	}
+	if h.HPKE {
+		pt.in.HPKEHops++
+	} else if h.FieldEncrypted {
+		pt.in.FieldEncHops++
+	}
+	if h.Tamper {
+		pt.in.Tamper = true
+	}
+	if conn := h.Conn; conn != nil {
+		if conn.Transport == transportTLS {
+			pt.in.TLSHops++
+		} else {
+			pt.in.CleartextHops++
+		}
+	}
+}
+
+// noteTamper flags the request as tampered with (e.g. an HPKE response that
+// did not open).
+func (pt *postureTrace) noteTamper() {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	pt.in.Tamper = true
+	pt.responseTamper = true
+	pt.mu.Unlock()
+}
+
+// events returns a copy of the recorded hops and whether a response failed
+// verification.
+func (pt *postureTrace) events() ([]postureHop, bool) {
+	if pt == nil {
+		return nil, false
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return append([]postureHop(nil), pt.hops...), pt.responseTamper
+}
+
+// timeline lists the hops for metadata security.timeline.
+func (pt *postureTrace) timeline() []map[string]any {
+	hops, _ := pt.events()
+	out := make([]map[string]any, 0, len(hops))
+	for _, h := range hops {
+		e := map[string]any{"target": h.Target, "signed": h.Signed, "hpke": h.HPKE, "accepted": h.Accepted, "tamper": h.Tamper}
+		if h.Via != "" {
+			e["via"] = h.Via
+		}
+		if h.FieldEncrypted {
+			e["fieldEncrypted"] = true
+		}
+		if h.Conn != nil {
+			e["conn"] = h.Conn
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (pt *postureTrace) inputs() postureInputs {
+	if pt == nil {
+		return postureInputs{}
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.in
+}
+
+// posture is the evaluated result as it appears in the response.
+func (in postureInputs) posture() map[string]any {
+	lvl := in.level()
+	return map[string]any{"level": lvl, "label": postureLabels[lvl], "factors": in.factors()}
+}
+
+// postureStats counts responses per level for /status and /metrics.
+type postureStats struct {
+	mu sync.Mutex
+	by map[string]int64
+}
+
+func newPostureStats() *postureStats {
+	return &postureStats{by: map[string]int64{}}
+}
+
+func (ps *postureStats) observe(level string) {
+	if ps == nil {
+		return
+	}
+	ps.mu.Lock()
+	ps.by[level]++
+	ps.mu.Unlock()
+}
+
+func (ps *postureStats) snapshot() map[string]int64 {
+	out := map[string]int64{}
+	if ps == nil {
+		return out
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, l := range postureLevels {
+		out[l] = ps.by[l]
+	}
+	return out
+}
+
+// writePrometheus appends the per-level counters to a /metrics body.
+func (ps *postureStats) writePrometheus(w io.Writer) {
+	if ps == nil {
+		return
+	}
+	snap := ps.snapshot()
+	var b strings.Builder
+	b.WriteString("# HELP sage_security_posture_total /process responses by security posture level.\n# TYPE sage_security_posture_total counter\n")
+	for _, l := range postureLevels {
+		b.WriteString(`sage_security_posture_total{level="` + l + `"} ` + strconv.FormatInt(snap[l], 10) + "\n")
+	}
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,141 @@
+package root
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Every combination of the per-hop signals on a single-hop turn, recorded the
+// way /process records them.
+func TestPostureEveryCombination(t *testing.T) {
+	for bits := 0; bits < 1<<7; bits++ {
+		var (
+			clientSigned = bits&1 != 0
+			signed       = bits&2 != 0
+			accepted     = bits&4 != 0
+			hpke         = bits&8 != 0
+			fieldEnc     = bits&16 != 0
+			tamper       = bits&32 != 0
+			cleartext    = bits&64 != 0
+		)
+		name := fmt.Sprintf("client=%v/signed=%v/accepted=%v/hpke=%v/fieldenc=%v/tamper=%v/cleartext=%v",
+			clientSigned, signed, accepted, hpke, fieldEnc, tamper, cleartext)
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/process", nil)
+			if clientSigned {
+				req.Header.Set("Signature-Input", `sig1=("@method")`)
+				req.Header.Set("Signature", "sig1=:AA==:")
+			}
+			pt := postureFrom(withPostureTrace(req))
+			conn := &connSummary{Transport: transportTLS}
+			if cleartext {
+				conn.Transport = transportCleartext
+			}
+			pt.noteUpstream(postureHop{Target: "payment", Signed: signed, Accepted: accepted, HPKE: hpke, FieldEncrypted: fieldEnc, Tamper: tamper, Conn: conn})
+
+			in := pt.inputs()
+			want := posturePartial
+			switch {
+			case tamper:
+				want = postureAttack
+			case clientSigned && signed && accepted && hpke:
+				want = postureProtected
+			case !clientSigned && !signed:
+				want = postureUnprotected
+			}
+			if got := in.level(); got != want {
+				t.Fatalf("level %s, want %s (%+v)", got, want, in)
+			}
+
+			f := in.factors()
+			has := func(s string) bool { return slices.Contains(f, s) }
+			expect := map[string]bool{
+				"client.signed_unverified": clientSigned,
+				"client.unsigned":          !clientSigned,
+				"upstream.signed":          signed,
+				"upstream.unsigned":        !signed,
+				"upstream.hpke":            hpke,
+				"upstream.field_encrypted": fieldEnc && !hpke,
+				"upstream.plaintext":       !hpke && !fieldEnc,
+				"digest.verified":          signed && accepted,
+				"transport.tls":            !cleartext,
+				"transport.cleartext":      cleartext,
+				"tamper.suspected":         tamper,
+				"gateway.bypassed":         false,
+			}
+			for factor, w := range expect {
+				if has(factor) != w {
+					t.Errorf("factor %s present=%v, want %v (factors %v)", factor, has(factor), w, f)
+				}
+			}
+			p := in.posture()
+			if p["level"] != want || p["label"] != postureLabels[want] {
+				t.Fatalf("posture %v", p)
+			}
+		})
+	}
+}
+
+// Multi-hop turns: one weaker hop drops the level, mixed signals get their own
+// factor, and a response that fails to open after an accepted hop is an attack.
+func TestPostureMixedHops(t *testing.T) {
+	full := postureHop{Signed: true, Accepted: true, HPKE: true, Conn: &connSummary{Transport: transportTLS}}
+	cases := []struct {
+		name    string
+		hops    []postureHop
+		tamper  bool
+		level   string
+		factors string
+	}{
+		{"all protected", []postureHop{full, full}, false, postureProtected,
+			"client.signed_unverified,upstream.signed,upstream.hpke,digest.verified,transport.tls"},
+		{"one hop plaintext over cleartext", []postureHop{full, {Signed: true, Accepted: true, Conn: &connSummary{Transport: transportCleartext}}}, false, posturePartial,
+			"client.signed_unverified,upstream.signed,upstream.partially_hpke,digest.verified,transport.mixed"},
+		{"one hop unsigned, sealed fields", []postureHop{full, {FieldEncrypted: true}}, false, posturePartial,
+			"client.signed_unverified,upstream.partially_signed,upstream.partially_hpke,upstream.field_encrypted,digest.verified,transport.tls"},
+		{"bypassed gateway", []postureHop{full, {Signed: true, Accepted: true, HPKE: true, Via: routedViaFallback}}, false, postureProtected,
+			"client.signed_unverified,upstream.signed,upstream.hpke,digest.verified,transport.tls,gateway.bypassed"},
+		{"response failed to open", []postureHop{full}, true, postureAttack,
+			"client.signed_unverified,upstream.signed,upstream.hpke,digest.verified,transport.tls,tamper.suspected"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/process", nil)
+			req.Header.Set("Signature-Input", `sig1=("@method")`)
+			req.Header.Set("Signature", "sig1=:AA==:")
+			pt := postureFrom(withPostureTrace(req))
+			for _, h := range tc.hops {
+				pt.noteUpstream(h)
+			}
+			if tc.tamper {
+				pt.noteTamper()
+			}
+			in := pt.inputs()
+			if got := strings.Join(in.factors(), ","); in.level() != tc.level || got != tc.factors {
+				t.Fatalf("level %s factors %s, want %s %s", in.level(), got, tc.level, tc.factors)
+			}
+			if _, responseTamper := pt.events(); responseTamper != tc.tamper {
+				t.Fatalf("responseTamper %v", responseTamper)
+			}
+		})
+	}
+}
+
+// Turns without an upstream call and a missing trace.
+func TestPostureNoHops(t *testing.T) {
+	if got := (postureInputs{}).level(); got != postureUnprotected {
+		t.Fatalf("no hops, unsigned client: %s", got)
+	}
+	if got := (postureInputs{ClientSigned: true}).level(); got != postureProtected {
+		t.Fatalf("no hops, signed client: %s", got)
+	}
+	var pt *postureTrace
+	pt.noteUpstream(postureHop{Signed: true})
+	pt.noteTamper()
+	if in := pt.inputs(); in != (postureInputs{}) {
+		t.Fatalf("nil trace recorded %+v", in)
+	}
+}
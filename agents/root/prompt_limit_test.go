@@ -0,0 +1,37 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Root enforces the limit again for callers that skip the client API; an
+// opted-in prompt is cut on a character boundary.
+func TestProcessPromptLimit(t *testing.T) {
+	t.Setenv("PROMPT_MAX_BYTES", "12")
+	env := newForkEnv(t, 0)
+	post := func(meta map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(types.AgentMessage{ID: "m1", From: "client", Content: "안녕하세요 반가워요", ContextID: "c1", Metadata: meta})
+		w := httptest.NewRecorder()
+		env.r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b)))
+		return w
+	}
+
+	w := post(nil)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), `"limitBytes":12`) {
+		t.Fatalf("refusal: %d %s", w.Code, w.Body.String())
+	}
+
+	w = post(map[string]any{"allowTruncation": true})
+	var out types.AgentMessage
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	if w.Code != http.StatusOK || !strings.Contains(out.Content, "안녕하세") || strings.Contains(out.Content, "안녕하세요") {
+		t.Fatalf("truncated turn: %d %q", w.Code, out.Content)
+	}
+}
@@ -0,0 +1,289 @@
+// Package root - domain hand-back from external agents.
+//
+// An external agent that receives a request outside its domain (a medication
+// price question routed to medical, a planning question routed to payment)
+// may answer with Type "redirect" and metadata suggestedDomain
+// (payment|medical|planning|chat) plus a reason instead of answering badly.
+//
+// Root follows at most one redirect per request: it re-runs /process for the
+// suggested domain with the original message (domain pinned, sticky
+// conversation contexts ignored) and explains the hop in the response
+// metadata "routing". The suggestion falls back to chat when it is not a
+// known domain, names the domain that redirected, or has no external URL
+// (planning is answered locally when it has none). A second redirect in the
+// same request — e.g. payment sending it back to medical — is not followed:
+// the turn is answered as chat and the hop is marked "loop".
+//
+// Each redirect emits audit routing/domain.redirect.
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const (
+	ctxRoutingKey  ctxKey = "routing"
+	ctxRedirectKey ctxKey = "redirectTo"
+)
+
+// Why a suggestion was not followed.
+const (
+	redirectInvalid      = "invalid_suggestion"
+	redirectUnconfigured = "unconfigured"
+	redirectLoop         = "loop"
+)
+
+// routingHop is one hand-back as reported in metadata routing.redirects.
+type routingHop struct {
+	From      string `json:"from"`
+	Suggested string `json:"suggestedDomain"`
+	Reason    string `json:"reason,omitempty"`
+	To        string `json:"to"` // domain actually used ("chat" on fallback)
+	Fallback  string `json:"fallback,omitempty"`
+}
+
+// routingNote carries the request's original message, the LLM router's
+// decision (routing_hints.go) and the redirects.
+type routingNote struct {
+	mu         sync.Mutex
+	origin     *types.AgentMessage
+	llmDomain  string // set when the LLM router picked the domain
+	promptHash string
+	hops       []routingHop
+
+	// which source answered (see answeredBy)
+	answerDomain string
+	answerSource string // "local" | "external"
+	planOrigin   string // local planning: "llm" | "fallback"
+}
+
+func withRouting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxRoutingKey, &routingNote{})
+}
+
+func routingFrom(ctx context.Context) *routingNote {
+	rn, _ := ctx.Value(ctxRoutingKey).(*routingNote)
+	return rn
+}
+
+// redirectTarget reports whether req is a re-run after a redirect, and for
+// which domain ("" = chat).
+func redirectTarget(ctx context.Context) (string, bool) {
+	to, ok := ctx.Value(ctxRedirectKey).(string)
+	return to, ok
+}
+
+// setOrigin keeps a copy of the message as received (after metadata policy).
+func (rn *routingNote) setOrigin(msg *types.AgentMessage) {
+	if rn == nil {
+		return
+	}
+	m := *msg
+	m.Metadata = make(map[string]any, len(msg.Metadata))
+	for k, v := range msg.Metadata {
+		m.Metadata[k] = v
+	}
+	rn.mu.Lock()
+	rn.origin = &m
+	rn.mu.Unlock()
+}
+
+func (rn *routingNote) add(h routingHop) {
+	if rn == nil {
+		return
+	}
+	rn.mu.Lock()
+	rn.hops = append(rn.hops, h)
+	rn.mu.Unlock()
+}
+
+// routed records that the LLM router picked domain with the prompt hashed
+// promptHash.
+func (rn *routingNote) routed(domain, promptHash string) {
+	if rn == nil {
+		return
+	}
+	rn.mu.Lock()
+	rn.llmDomain, rn.promptHash = domain, promptHash
+	rn.mu.Unlock()
+}
+
+// answeredBy records which source answered for domain: "local" (Root
+// in-proc, with the plan's origin) or "external".
+func (rn *routingNote) answeredBy(domain, source, origin string) {
+	if rn == nil {
+		return
+	}
+	rn.mu.Lock()
+	rn.answerDomain, rn.answerSource, rn.planOrigin = domain, source, origin
+	rn.mu.Unlock()
+}
+
+// meta is the "routing" response metadata; nil when the domain was neither
+// picked by the LLM router nor redirected and no answer source was recorded.
+func (rn *routingNote) meta() map[string]any {
+	if rn == nil {
+		return nil
+	}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	m := rn.routeMetaLocked()
+	if rn.answerSource == "" {
+		return m
+	}
+	answered := fmt.Sprintf("answered by the external %s agent", rn.answerDomain)
+	if rn.answerSource == "local" {
+		answered = fmt.Sprintf("answered by Root's local %s", rn.answerDomain)
+		switch rn.planOrigin {
+		case "llm":
+			answered += " (plan from the LLM)"
+		case "fallback":
+			answered += " (fallback plan, not from the LLM)"
+		}
+	}
+	if m == nil {
+		m = map[string]any{"domain": rn.answerDomain, "explanation": answered}
+	} else {
+		m["explanation"] = fmt.Sprintf("%s; %s", m["explanation"], answered)
+	}
+	m["answeredBy"] = rn.answerSource
+	if rn.planOrigin != "" {
+		m["planOrigin"] = rn.planOrigin
+	}
+	return m
+}
+
+// routeMetaLocked explains the routing decision; the caller holds rn.mu.
+func (rn *routingNote) routeMetaLocked() map[string]any {
+	if len(rn.hops) == 0 {
+		if rn.llmDomain == "" {
+			return nil
+		}
+		return map[string]any{
+			"domain":      rn.llmDomain,
+			"by":          "llm",
+			"promptHash":  rn.promptHash,
+			"explanation": fmt.Sprintf("classified as %s by the LLM router (prompt %s)", rn.llmDomain, rn.promptHash),
+		}
+	}
+	why := make([]string, 0, len(rn.hops))
+	for _, h := range rn.hops {
+		s := fmt.Sprintf("%s handed the request back", h.From)
+		if h.Reason != "" {
+			s += " (" + h.Reason + ")"
+		}
+		switch h.Fallback {
+		case "":
+			s += "; rerouted to " + h.To
+		case redirectLoop:
+			s += fmt.Sprintf("; its suggestion %q was not followed (one redirect per request), answered as chat", h.Suggested)
+		case redirectUnconfigured:
+			s += fmt.Sprintf("; suggested %s is not configured, answered as chat", h.Suggested)
+		default:
+			s += fmt.Sprintf("; suggestion %q is not a usable domain, answered as chat", h.Suggested)
+		}
+		why = append(why, s)
+	}
+	m := map[string]any{
+		"domain":      rn.hops[len(rn.hops)-1].To,
+		"redirects":   append([]routingHop(nil), rn.hops...),
+		"explanation": strings.Join(why, "; "),
+	}
+	if rn.llmDomain != "" {
+		m["promptHash"] = rn.promptHash
+		m["explanation"] = fmt.Sprintf("classified as %s by the LLM router (prompt %s); %s", rn.llmDomain, rn.promptHash, m["explanation"])
+	}
+	return m
+}
+
+// isRedirect: the upstream handed the request back.
+func isRedirect(out *types.AgentMessage) bool {
+	return out != nil && strings.EqualFold(strings.TrimSpace(out.Type), "redirect")
+}
+
+// redirectDestination validates a suggestion from the agent `from`; it
+// returns the domain to re-run ("" = chat) and why the suggestion was not
+// taken.
+func (r *RootAgent) redirectDestination(from, suggested string) (string, string) {
+	switch suggested {
+	case "chat":
+		return "", ""
+	case "payment", "medical", "planning":
+	default:
+		return "", redirectInvalid
+	}
+	if suggested == from {
+		return "", redirectInvalid
+	}
+	if suggested != "planning" && r.externalURLFor(suggested) == "" {
+		return "", redirectUnconfigured
+	}
+	return suggested, ""
+}
+
+// followRedirect handles an upstream hand-back: it picks the destination,
+// records it and re-runs the request there. from is the agent that answered.
+func (r *RootAgent) followRedirect(w http.ResponseWriter, req *http.Request, from, cid string, out *types.AgentMessage) {
+	suggested := strings.ToLower(strings.TrimSpace(strFrom(out.Metadata, "suggestedDomain")))
+	reason := strings.TrimSpace(strFrom(out.Metadata, "reason"))
+	to, fallback := r.redirectDestination(from, suggested)
+	if _, again := redirectTarget(req.Context()); again {
+		to, fallback = "", redirectLoop
+	}
+	hop := routingHop{From: from, Suggested: suggested, Reason: reason, To: firstNonEmpty(to, "chat"), Fallback: fallback}
+	rn := routingFrom(req.Context())
+	rn.add(hop)
+
+	r.logger.Printf("[root][redirect] cid=%s from=%s suggested=%q reason=%q -> %s fallback=%s",
+		cid, from, suggested, reason, hop.To, fallback)
+	outcome := "success"
+	if fallback != "" {
+		outcome = "failure"
+	}
+	r.audit.Emit(audit.Event{
+		Type: "routing", Action: "domain.redirect", Outcome: outcome, Target: from, CID: cid,
+		Detail: map[string]any{"suggestedDomain": suggested, "reason": reason, "to": hop.To, "fallback": fallback},
+	})
+
+	var orig types.AgentMessage
+	if rn != nil {
+		rn.mu.Lock()
+		if rn.origin != nil {
+			orig = *rn.origin
+		}
+		rn.mu.Unlock()
+	}
+	r.redispatch(w, req, orig, to)
+}
+
+// redispatch re-runs /process in-process for msg with the domain pinned to
+// `to` ("" = chat). w is the outer request's writer, so the response is
+// stamped once.
+func (r *RootAgent) redispatch(w http.ResponseWriter, req *http.Request, msg types.AgentMessage, to string) {
+	meta := make(map[string]any, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		meta[k] = v
+	}
+	meta["domain"] = firstNonEmpty(to, "chat")
+	msg.Metadata = meta
+	b, err := json.Marshal(msg)
+	if err != nil {
+		http.Error(w, "redirect failed", http.StatusInternalServerError)
+		return
+	}
+	req2 := req.Clone(context.WithValue(req.Context(), ctxRedirectKey, to))
+	req2.Header.Del("Content-Encoding")
+	req2.Body = io.NopCloser(bytes.NewReader(b))
+	req2.ContentLength = int64(len(b))
+	r.processFn(w, req2)
+}
@@ -0,0 +1,114 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// redirectRoot captures what followRedirect re-runs instead of running it.
+func redirectRoot(t *testing.T) (*RootAgent, *[]*http.Request) {
+	t.Helper()
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("PAYMENT_URL", "http://payment.test")
+	t.Setenv("MEDICAL_URL", "http://medical.test")
+	t.Setenv("PLANNING_EXTERNAL_URL", "")
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+	var reruns []*http.Request
+	r.processFn = func(w http.ResponseWriter, req *http.Request) { reruns = append(reruns, req) }
+	return r, &reruns
+}
+
+func handBack(to, reason string) *types.AgentMessage {
+	return &types.AgentMessage{Type: "redirect", Metadata: map[string]any{"suggestedDomain": to, "reason": reason}}
+}
+
+func TestRedirectDestination(t *testing.T) {
+	r, _ := redirectRoot(t)
+	r.setPrimary("medical", "")
+	cases := []struct {
+		from, suggested string
+		to, fallback    string
+	}{
+		{"medical", "payment", "payment", ""},
+		{"payment", "chat", "", ""},
+		{"payment", "planning", "planning", ""}, // answered locally without a URL
+		{"payment", "payment", "", redirectInvalid},
+		{"payment", "shopping", "", redirectInvalid},
+		{"payment", "medical", "", redirectUnconfigured},
+	}
+	for _, c := range cases {
+		to, fb := r.redirectDestination(c.from, c.suggested)
+		if to != c.to || fb != c.fallback {
+			t.Errorf("%s -> %q: got (%q, %q), want (%q, %q)", c.from, c.suggested, to, fb, c.to, c.fallback)
+		}
+	}
+}
+
+func TestFollowRedirectLoopCutOff(t *testing.T) {
+	r, reruns := redirectRoot(t)
+	ctx := withRouting(context.Background())
+	orig := &types.AgentMessage{ID: "m1", Content: "타이레놀 얼마야", Metadata: map[string]any{"lang": "ko"}}
+	routingFrom(ctx).setOrigin(orig)
+	req := httptest.NewRequest(http.MethodPost, "/process", nil).WithContext(ctx)
+
+	// medical hands back to payment: followed once, with the original message
+	r.followRedirect(httptest.NewRecorder(), req, "medical", "cid-1", handBack("payment", "price question"))
+	if len(*reruns) != 1 {
+		t.Fatalf("re-runs = %d, want 1", len(*reruns))
+	}
+	rerun := (*reruns)[0]
+	if to, ok := redirectTarget(rerun.Context()); !ok || to != "payment" {
+		t.Fatalf("re-run target = %q %v", to, ok)
+	}
+	var sent types.AgentMessage
+	_ = json.NewDecoder(rerun.Body).Decode(&sent)
+	if sent.Content != orig.Content || sent.Metadata["domain"] != "payment" {
+		t.Fatalf("re-run message = %+v", sent)
+	}
+
+	// payment sends it back to medical inside the re-run: cut off, answered as chat
+	r.followRedirect(httptest.NewRecorder(), rerun, "payment", "cid-1", handBack("medical", "not a purchase"))
+	if to, _ := redirectTarget((*reruns)[1].Context()); to != "" {
+		t.Fatalf("second redirect followed to %q", to)
+	}
+
+	m := routingFrom(ctx).meta()
+	hops := m["redirects"].([]routingHop)
+	if len(hops) != 2 || hops[0].To != "payment" || hops[1].Fallback != redirectLoop || hops[1].To != "chat" || m["domain"] != "chat" {
+		t.Fatalf("routing = %+v", m)
+	}
+	if ex := m["explanation"].(string); !strings.Contains(ex, "one redirect per request") || !strings.Contains(ex, "rerouted to payment") {
+		t.Fatalf("explanation = %q", ex)
+	}
+}
+
+func TestFollowRedirectFallbacks(t *testing.T) {
+	r, reruns := redirectRoot(t)
+	r.setPrimary("medical", "")
+	for _, c := range []struct{ suggested, fallback string }{
+		{"astrology", redirectInvalid},
+		{"medical", redirectUnconfigured},
+	} {
+		ctx := withRouting(context.Background())
+		routingFrom(ctx).setOrigin(&types.AgentMessage{ID: "m1", Content: "hi"})
+		req := httptest.NewRequest(http.MethodPost, "/process", nil).WithContext(ctx)
+		r.followRedirect(httptest.NewRecorder(), req, "payment", "cid-2", handBack(c.suggested, ""))
+
+		rerun := (*reruns)[len(*reruns)-1]
+		var sent types.AgentMessage
+		_ = json.NewDecoder(rerun.Body).Decode(&sent)
+		hops := routingFrom(ctx).meta()["redirects"].([]routingHop)
+		if sent.Metadata["domain"] != "chat" || hops[0].Fallback != c.fallback {
+			t.Errorf("%s: domain=%v hop=%+v", c.suggested, sent.Metadata["domain"], hops[0])
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package root - refund flow.
+//
+// Successful payment responses leave their receipt in a per-conversation
+// history. "환불해줘" / "refund my last order" resolves the most recent
+// unrefunded receipt (or an explicit ORD-… id), asks for confirmation with the
+// original receipt details, then sends it to the payment agent's refund
+// operation (operations.go). Structured 404/409 rejections are mapped to friendly messages.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+type receiptRef struct {
+	OrderID   string
+	AmountKRW int64
+	Item      string
+	To        string
+	Method    string
+	At        time.Time
+	Refunded  bool
+	RefundKRW int64 // 0 when unknown (e.g. already refunded elsewhere)
+	RefundID  string
+}
+
+type refundPending struct {
+	Receipt receiptRef
+	Token   string
+}
+
+var (
+	orderIDRe = regexp.MustCompile(`(?i)\bORD-[0-9A-F]{4,}\b`)
+)
+
+func isRefundIntent(c string) bool {
+	return containsAny(strings.ToLower(c), "환불", "refund")
+}
+
+// rememberReceipt records the receipt metadata of a successful payment response.
+func (r *RootAgent) rememberReceipt(cid string, meta map[string]any) {
+	if id := r.addReceipt(cid, meta); id != "" {
+		r.closeAttempt(cid, attemptCharged, id)
+	}
+}
+
+// addReceipt appends meta's receipt to cid's history and returns its order ID
+// ("" when meta has none). The caller settles the attempt it belongs to.
+func (r *RootAgent) addReceipt(cid string, meta map[string]any) string {
+	rc, _ := meta["receipt"].(map[string]any)
+	id, _ := rc["orderId"].(string)
+	if strings.TrimSpace(id) == "" {
+		return ""
+	}
+	amt, _ := pickIntFromMeta(rc, "amountKRW")
+	str := func(k string) string { v, _ := rc[k].(string); return v }
+	ref := receiptRef{OrderID: id, AmountKRW: int64(amt), Item: str("item"), To: str("to"), Method: str("method"), At: time.Now()}
+
+	r.receiptMu.Lock()
+	defer r.receiptMu.Unlock()
+	var list []receiptRef
+	if v, ok := r.receiptHistory.Load(cid); ok {
+		list = v.([]receiptRef)
+	}
+	r.receiptHistory.Store(cid, append(list, ref))
+	return id
+}
+
+// lastReceipt returns orderID's receipt, or the latest unrefunded one when orderID is empty.
+func (r *RootAgent) lastReceipt(cid, orderID string) (receiptRef, bool) {
+	r.receiptMu.Lock()
+	defer r.receiptMu.Unlock()
+	v, ok := r.receiptHistory.Load(cid)
+	if !ok {
+		return receiptRef{}, false
+	}
+	list := v.([]receiptRef)
+	for i := len(list) - 1; i >= 0; i-- {
+		if orderID != "" {
+			if strings.EqualFold(list[i].OrderID, orderID) {
+				return list[i], true
+			}
+			continue
+		}
+		if !list[i].Refunded {
+			return list[i], true
+		}
+	}
+	return receiptRef{}, false
+}
+
+// markRefunded flags orderID refunded; refund is the payment agent's refund
+// metadata when known.
+func (r *RootAgent) markRefunded(cid, orderID string, refund map[string]any) {
+	r.receiptMu.Lock()
+	defer r.receiptMu.Unlock()
+	v, ok := r.receiptHistory.Load(cid)
+	if !ok {
+		return
+	}
+	list := append([]receiptRef(nil), v.([]receiptRef)...)
+	for i := range list {
+		if strings.EqualFold(list[i].OrderID, orderID) {
+			list[i].Refunded = true
+			if amt, ok := pickIntFromMeta(refund, "amountKRW"); ok {
+				list[i].RefundKRW = int64(amt)
+			}
+			if id, _ := refund["refundId"].(string); id != "" {
+				list[i].RefundID = id
+			}
+		}
+	}
+	r.receiptHistory.Store(cid, list)
+}
+
+func refundPreview(lang string, rc receiptRef) string {
+	amt := money.Format(lang, rc.AmountKRW, money.KRW)
+	if langOrDefault(lang) == "ko" {
+		return fmt.Sprintf("다음 주문을 환불할까요?\n- 주문번호: %s\n- 상품: %s\n- 수령자: %s\n- 결제수단: %s\n- 금액: %s\n(예/아니오)",
+			rc.OrderID, firstNonEmpty(rc.Item, "-"), firstNonEmpty(rc.To, "-"), firstNonEmpty(rc.Method, "-"), amt)
+	}
+	return fmt.Sprintf("Refund this order?\n- Order: %s\n- Item: %s\n- Recipient: %s\n- Method: %s\n- Amount: %s\n(yes/no)",
+		rc.OrderID, firstNonEmpty(rc.Item, "-"), firstNonEmpty(rc.To, "-"), firstNonEmpty(rc.Method, "-"), amt)
+}
+
+// refundErrorText maps the payment agent's error code to a user-facing message.
+func refundErrorText(lang, code, orderID string) string {
+	msgs := map[string]map[string]string{
+		"order_not_found": {
+			"ko": "주문 " + orderID + "을(를) 찾을 수 없어요. 주문번호를 확인해 주세요.",
+			"en": "I couldn't find order " + orderID + ". Please check the order number.",
+		},
+		"already_refunded": {
+			"ko": "주문 " + orderID + "은(는) 이미 환불되었어요.",
+			"en": "Order " + orderID + " has already been refunded.",
+		},
+		"refund_exceeds_original": {
+			"ko": "환불 금액이 원래 결제 금액보다 커요.",
+			"en": "The refund amount exceeds the original payment.",
+		},
+	}
+	if m, ok := msgs[code]; ok {
+		return m[langOrDefault(lang)]
+	}
+	return map[string]string{
+		"ko": "환불을 처리하지 못했어요. 잠시 후 다시 시도해 주세요.",
+		"en": "The refund could not be processed. Please try again later.",
+	}[langOrDefault(lang)]
+}
+
+func writeRootMsg(w http.ResponseWriter, status int, out types.AgentMessage) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// handleRefund drives the refund intent/confirm stages. Returns false when the
+// message is not part of a refund conversation.
+func (r *RootAgent) handleRefund(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, text, cid, lang string) bool {
+	if v, ok := r.refundPendings.Load(cid); ok {
+		p := v.(refundPending)
+		yes, no := parseYesNo(text)
+		switch {
+		case yes || (isRefundIntent(text) && !no):
+			if _, still := r.refundPendings.LoadAndDelete(cid); !still {
+				writePaymentInFlight(w, msg, cid, lang)
+				return true
+			}
+			r.forwardRefund(w, req, msg, cid, lang, p.Receipt)
+		case no:
+			r.refundPendings.Delete(cid)
+			r.logger.Printf("[root][refund][confirm] cid=%s order=%s cancelled", cid, p.Receipt.OrderID)
+			writeRootMsg(w, http.StatusOK, types.AgentMessage{
+				ID: msg.ID + "-refund-cancel", ContextID: cid, From: "root", To: msg.From, Type: "response",
+				Content:   map[string]string{"ko": "환불을 취소했어요.", "en": "Refund cancelled."}[langOrDefault(lang)],
+				Timestamp: time.Now(),
+				Metadata:  map[string]any{"lang": lang, "domain": "payment"},
+			})
+		default:
+			writeRootMsg(w, http.StatusOK, types.AgentMessage{
+				ID: msg.ID + "-refund-confirm", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
+				Content:   refundPreview(lang, p.Receipt),
+				Timestamp: time.Now(),
+				Metadata:  map[string]any{"await": "payment.refund.confirm", "lang": lang, "domain": "payment", "confirmToken": p.Token, "orderId": p.Receipt.OrderID},
+			})
+		}
+		return true
+	}
+
+	orderID := strings.ToUpper(orderIDRe.FindString(text))
+	_, asked := r.refundAskOrder.LoadAndDelete(cid)
+	if !isRefundIntent(text) && !(asked && orderID != "") {
+		return false
+	}
+	rc, ok := r.lastReceipt(cid, orderID)
+	if !ok {
+		if orderID != "" {
+			// Not paid in this conversation: let the payment agent decide (404 if unknown)
+			r.forwardRefund(w, req, msg, cid, lang, receiptRef{OrderID: orderID})
+			return true
+		}
+		r.refundAskOrder.Store(cid, struct{}{})
+		writeRootMsg(w, http.StatusOK, types.AgentMessage{
+			ID: msg.ID + "-refund-none", ContextID: cid, From: "root", To: msg.From, Type: "clarify",
+			Content: map[string]string{
+				"ko": "이 대화에서 환불할 주문을 찾지 못했어요. 주문번호(ORD-…)를 알려주세요.",
+				"en": "I couldn't find an order to refund in this conversation. What's the order number (ORD-…)?",
+			}[langOrDefault(lang)],
+			Timestamp: time.Now(),
+			Metadata:  map[string]any{"await": "payment.refund.order", "lang": lang, "domain": "payment"},
+		})
+		return true
+	}
+
+	p := refundPending{Receipt: rc, Token: uuid.NewString()}
+	r.refundPendings.Store(cid, p)
+	r.logger.Printf("[root][refund] cid=%s order=%s await confirm", cid, rc.OrderID)
+	writeRootMsg(w, http.StatusOK, types.AgentMessage{
+		ID: msg.ID + "-refund-confirm", ContextID: cid, From: "root", To: msg.From, Type: "confirm",
+		Content:   refundPreview(lang, rc),
+		Timestamp: time.Now(),
+		Metadata:  map[string]any{"await": "payment.refund.confirm", "lang": lang, "domain": "payment", "confirmToken": p.Token, "orderId": rc.OrderID},
+	})
+	return true
+}
+
+// forwardRefund sends rc.OrderID to the payment refund operation (keeping
+// payment.op:"refund" for upstreams that only serve /process) and writes the result.
+func (r *RootAgent) forwardRefund(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid, lang string, rc receiptRef) {
+	fwd := *msg
+	fwd.Metadata = map[string]any{
+		"lang":            lang,
+		"payment.op":      "refund",
+		"payment.orderId": rc.OrderID,
+	}
+
+	ctx := req.Context()
+	if v := strings.TrimSpace(req.Header.Get("X-SAGE-Enabled")); v != "" {
+		ctx = context.WithValue(ctx, ctxUseSAGEKey, strings.EqualFold(v, "true"))
+	}
+	if v := strings.TrimSpace(req.Header.Get("X-HPKE-Enabled")); v != "" {
+		ctx = context.WithValue(ctx, ctxHPKERawKey, v)
+	}
+
+	r.logger.Printf("[root][refund][send] cid=%s order=%s", cid, rc.OrderID)
+	outPtr, err := r.sendWithSLA(ctx, "payment", "refund", &fwd)
+	if err != nil {
+		r.logger.Printf("[root][refund][send][error] %v", err)
+		r.writeSendError(w, req, lang, "payment", err)
+		return
+	}
+	out := *outPtr
+	status := http.StatusOK
+	if code, ok := httpStatusFromAgent(&out); ok {
+		status = code
+	}
+	em, _ := out.Metadata["error"].(map[string]any)
+	code, _ := em["code"].(string)
+	switch {
+	case strings.EqualFold(out.Type, "error") && (status == http.StatusNotFound || status == http.StatusConflict):
+		// Structured rejection: keep the code, replace the text
+		r.logger.Printf("[root][refund] cid=%s order=%s rejected code=%q status=%d", cid, rc.OrderID, code, status)
+		if code == "already_refunded" {
+			r.markRefunded(cid, rc.OrderID, nil)
+		}
+		out.Content = refundErrorText(lang, code, rc.OrderID)
+		if out.Metadata == nil {
+			out.Metadata = map[string]any{}
+		}
+		out.Metadata["domain"] = "payment"
+	case status/100 == 2 && !isErrorOut(&out):
+		rf, _ := out.Metadata["refund"].(map[string]any)
+		r.markRefunded(cid, rc.OrderID, rf)
+	default:
+		r.presentOut(req, lang, "payment", &out, status)
+	}
+	writeRootMsg(w, status, out)
+}
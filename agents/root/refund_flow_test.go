@@ -0,0 +1,109 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// refundUpstream refunds each known order once, like the payment agent:
+// 404 for unknown orders, 409 for a second refund.
+type refundUpstream struct {
+	mu       sync.Mutex
+	orders   map[string]int64
+	refunded map[string]bool
+	asked    []string
+}
+
+func (u *refundUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in types.AgentMessage
+	_ = json.NewDecoder(r.Body).Decode(&in)
+	id, _ := in.Metadata["payment.orderId"].(string)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.asked = append(u.asked, id)
+	out := types.AgentMessage{ID: in.ID + "-refund", From: "payment", Type: "response"}
+	amt, known := u.orders[id]
+	switch {
+	case !known:
+		out.Type, out.Metadata = "error", map[string]any{"error": map[string]any{"code": "order_not_found"}, "httpStatus": 404}
+	case u.refunded[id]:
+		out.Type, out.Metadata = "error", map[string]any{"error": map[string]any{"code": "already_refunded"}, "httpStatus": 409}
+	default:
+		u.refunded[id] = true
+		out.Content = "Refunded order " + id
+		out.Metadata = map[string]any{"refund": map[string]any{"refundId": "RFD-" + id[4:], "orderId": id, "amountKRW": amt}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func refundTurn(t *testing.T, r *RootAgent, cid, text string) (int, types.AgentMessage) {
+	t.Helper()
+	msg := &types.AgentMessage{ID: "m1", From: "user", Content: text}
+	w := httptest.NewRecorder()
+	if !r.handleRefund(w, httptest.NewRequest(http.MethodPost, "/process", nil), msg, text, cid, "en") {
+		t.Fatalf("%q was not handled as a refund", text)
+	}
+	var out types.AgentMessage
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+func TestRefundFlow(t *testing.T) {
+	up := &refundUpstream{orders: map[string]int64{"ORD-AAAA1111": 300000, "ORD-BBBB2222": 50000}, refunded: map[string]bool{"ORD-CCCC3333": true}}
+	up.orders["ORD-CCCC3333"] = 10000
+	srv := httptest.NewServer(up)
+	defer srv.Close()
+	r := fallbackRoot(t, srv.URL, "")
+	const cid = "c-refund"
+	for _, id := range []string{"ORD-AAAA1111", "ORD-BBBB2222"} {
+		r.rememberReceipt(cid, map[string]any{"receipt": map[string]any{"orderId": id, "amountKRW": float64(up.orders[id]), "item": "맥북"}})
+	}
+
+	// "my last order" is the latest unrefunded receipt of this conversation
+	_, out := refundTurn(t, r, cid, "refund my last order")
+	if out.Type != "confirm" || out.Metadata["orderId"] != "ORD-BBBB2222" || !strings.Contains(out.Content, "₩50,000") {
+		t.Fatalf("confirm: %+v", out)
+	}
+	code, out := refundTurn(t, r, cid, "yes")
+	if code != http.StatusOK || out.Content != "Refunded order ORD-BBBB2222" {
+		t.Fatalf("refund: %d %+v", code, out)
+	}
+	if rc, _ := r.lastReceipt(cid, "ORD-BBBB2222"); !rc.Refunded || rc.RefundKRW != 50000 || rc.RefundID != "RFD-BBBB2222" {
+		t.Fatalf("receipt after refund: %+v", rc)
+	}
+	if _, out := refundTurn(t, r, cid, "refund my last order"); out.Metadata["orderId"] != "ORD-AAAA1111" {
+		t.Fatalf("next last order: %+v", out)
+	}
+	if _, out := refundTurn(t, r, cid, "no"); out.Content != "Refund cancelled." {
+		t.Fatalf("cancel: %+v", out)
+	}
+
+	// an order not paid here goes to the payment agent as is
+	code, out = refundTurn(t, r, cid, "refund ORD-FFFF0000")
+	if code != http.StatusNotFound || out.Content != refundErrorText("en", "order_not_found", "ORD-FFFF0000") {
+		t.Fatalf("unknown order: %d %+v", code, out)
+	}
+	code, out = refundTurn(t, r, cid, "refund ORD-CCCC3333")
+	if code != http.StatusConflict || out.Content != refundErrorText("en", "already_refunded", "ORD-CCCC3333") {
+		t.Fatalf("already refunded: %d %+v", code, out)
+	}
+	if want := "ORD-BBBB2222,ORD-FFFF0000,ORD-CCCC3333"; strings.Join(up.asked, ",") != want {
+		t.Fatalf("payment asked for %v, want %s", up.asked, want)
+	}
+
+	// nothing to refund: ask for the order number, then take it
+	_, out = refundTurn(t, r, "c-empty", "환불해줘")
+	if out.Metadata["await"] != "payment.refund.order" {
+		t.Fatalf("no receipts: %+v", out)
+	}
+	if code, _ := refundTurn(t, r, "c-empty", "ORD-AAAA1111"); code != http.StatusOK || up.refunded["ORD-AAAA1111"] != true {
+		t.Fatalf("order number answer: %d", code)
+	}
+}
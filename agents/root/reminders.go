@@ -0,0 +1,208 @@
+// Package root - conversation reminders.
+//
+// POST /conversations/{cid}/reminders (admin) with {"text":..., "when":...}
+// schedules a reminder. "when" is read in the conversation's time zone
+// (timezone.go) by tz.ParseWhen: "tomorrow 9am", "내일 오전 9시", "in 30
+// minutes", a local "2006-01-02 15:04" or an RFC3339 instant. An optional
+// "timezone" reads it in another zone instead; an invalid one is warned
+// about (X-SAGE-Timezone-Warning) and the conversation's zone is used. The
+// wall clock time is converted to an instant once, DST-correctly, and the
+// timer runs on that instant, so a reminder set across a DST transition
+// fires at the local time asked for.
+//
+// A due reminder is marked fired, logged and emitted as audit
+// conversation/reminder.fired; there is no push channel to the client.
+// GET /conversations/{cid}/reminders lists a conversation's reminders with
+// due times in UTC and local time, as does GET /conversations/{cid}.
+// Reminders are kept in memory; ROOT_REMINDERS_MAX (default 20) caps the
+// pending ones per conversation.
+package root
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+)
+
+// maxFiredReminders bounds the fired reminders kept per conversation.
+const maxFiredReminders = 20
+
+const reminderMaxText = 200
+
+var errReminderLimit = errors.New("reminder limit reached (ROOT_REMINDERS_MAX)")
+
+func remindersMax() int { return envInt("ROOT_REMINDERS_MAX", 20) }
+
+type reminder struct {
+	ID      string
+	Text    string
+	Due     time.Time
+	Zone    *time.Location
+	Created time.Time
+	FiredAt time.Time
+	timer   *time.Timer
+}
+
+// reminderView is a reminder as listed.
+type reminderView struct {
+	ID      string  `json:"id"`
+	Text    string  `json:"text"`
+	Due     tz.Pair `json:"due"`
+	Created string  `json:"created"`
+	Fired   bool    `json:"fired"`
+	FiredAt string  `json:"firedAt,omitempty"`
+}
+
+type reminderStore struct {
+	mu sync.Mutex
+	by map[string][]*reminder // cid -> reminders, oldest first
+}
+
+// scheduleReminder adds a reminder for cid due at due (shown in loc).
+func (r *RootAgent) scheduleReminder(cid, text string, due time.Time, loc *time.Location) (reminderView, error) {
+	rm := &reminder{ID: "rem-" + uuid.NewString()[:8], Text: text, Due: due.UTC(), Zone: loc, Created: time.Now().UTC()}
+
+	r.reminderBook.mu.Lock()
+	defer r.reminderBook.mu.Unlock()
+	if r.reminderBook.by == nil {
+		r.reminderBook.by = map[string][]*reminder{}
+	}
+	pending, fired := 0, 0
+	for _, x := range r.reminderBook.by[cid] {
+		if x.FiredAt.IsZero() {
+			pending++
+		} else {
+			fired++
+		}
+	}
+	if max := remindersMax(); max > 0 && pending >= max {
+		return reminderView{}, fmt.Errorf("conversation %s already has %d pending reminders: %w", cid, pending, errReminderLimit)
+	}
+	list := r.reminderBook.by[cid]
+	if fired >= maxFiredReminders {
+		list = dropOldestFired(list)
+	}
+	r.reminderBook.by[cid] = append(list, rm)
+	rm.timer = time.AfterFunc(time.Until(rm.Due), func() { r.fireReminder(cid, rm) })
+	return rm.view(), nil
+}
+
+func dropOldestFired(list []*reminder) []*reminder {
+	for i, x := range list {
+		if !x.FiredAt.IsZero() {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+func (r *RootAgent) fireReminder(cid string, rm *reminder) {
+	r.reminderBook.mu.Lock()
+	rm.FiredAt = time.Now().UTC()
+	r.reminderBook.mu.Unlock()
+	r.logger.Printf("[root][reminder] cid=%s id=%s due=%s text=%q fired", cid, rm.ID, tz.Dual(rm.Due, rm.Zone), rm.Text)
+	r.audit.Emit(audit.Event{
+		Type: "conversation", Action: "reminder.fired", Outcome: "success", CID: cid,
+		Detail: map[string]any{"id": rm.ID, "text": rm.Text, "due": tz.Both(rm.Due, rm.Zone)},
+	})
+}
+
+// view renders rm; the caller holds reminderBook.mu or owns rm.
+func (rm *reminder) view() reminderView {
+	v := reminderView{ID: rm.ID, Text: rm.Text, Due: tz.Both(rm.Due, rm.Zone), Created: rm.Created.Format(time.RFC3339)}
+	if !rm.FiredAt.IsZero() {
+		v.Fired, v.FiredAt = true, rm.FiredAt.Format(time.RFC3339)
+	}
+	return v
+}
+
+// remindersOf lists cid's reminders, oldest first.
+func (r *RootAgent) remindersOf(cid string) []reminderView {
+	r.reminderBook.mu.Lock()
+	defer r.reminderBook.mu.Unlock()
+	out := make([]reminderView, 0, len(r.reminderBook.by[cid]))
+	for _, rm := range r.reminderBook.by[cid] {
+		out = append(out, rm.view())
+	}
+	return out
+}
+
+// handleReminders serves GET and POST /conversations/{cid}/reminders.
+func (r *RootAgent) handleReminders(w http.ResponseWriter, req *http.Request, cid string) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(map[string]any{"cid": cid, "timezone": r.convTimezone(cid).String(), "reminders": r.remindersOf(cid)})
+		return
+	}
+
+	var in struct {
+		Text     string `json:"text"`
+		When     string `json:"when"`
+		Timezone string `json:"timezone"`
+	}
+	if probs := decodeAdminBody(req, map[string]fieldSpec{
+		"text":     {Kind: "string", Required: true},
+		"when":     {Kind: "string", Required: true},
+		"timezone": {Kind: "string"},
+	}, &in); len(probs) > 0 {
+		writeInvalidRequest(w, probs)
+		return
+	}
+	loc := r.convTimezone(cid)
+	if name := strings.TrimSpace(in.Timezone); name != "" {
+		if l, err := tz.Resolve(name); err != nil {
+			r.logger.Printf("[root][reminder] ⚠️ cid=%s timezone: %v; using %s", cid, err, loc)
+			w.Header().Set(tz.WarningHeader, "unknown time zone "+clipHint(name, 64)+"; using "+loc.String())
+		} else {
+			loc = l
+		}
+	}
+	var probs []fieldProblem
+	text := clipHint(in.Text, reminderMaxText)
+	if text == "" {
+		probs = append(probs, fieldProblem{Field: "text", Message: "required"})
+	}
+	now := time.Now()
+	due, ok := tz.ParseWhen(in.When, now, loc)
+	switch {
+	case !ok:
+		probs = append(probs, fieldProblem{Field: "when", Message: `not understood (try "tomorrow 9am", "in 30 minutes", "2006-01-02 15:04")`})
+	case !due.After(now):
+		probs = append(probs, fieldProblem{Field: "when", Message: "in the past: " + tz.Dual(due, loc)})
+	}
+	if len(probs) > 0 {
+		writeInvalidRequest(w, probs)
+		return
+	}
+
+	v, err := r.scheduleReminder(cid, text, due, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	r.logger.Printf("[root][reminder] cid=%s id=%s when=%q due=%s scheduled", cid, v.ID, in.When, tz.Dual(due, loc))
+	r.audit.Emit(audit.Event{
+		Type: "conversation", Action: "reminder.scheduled", Outcome: "success", Actor: requesterOf(req), CID: cid,
+		Detail: map[string]any{"id": v.ID, "when": in.When, "due": v.Due},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,230 @@
+// Package root - LLM routing prompt assembled from upstream hints.
+//
+// Upstreams describe what they handle in the "routingHint" block of their
+// /status (selfid.RoutingHint): a sentence or two and example utterances per
+// language. The health probes record the block per agent together with the
+// probe result, and llmRoute builds its system prompt from the targets that
+// are configured and not known to be down (planning is answered locally when
+// it has no URL, so it always counts). A target that advertises nothing is
+// described by builtinRouteHints, or by its name alone. A new or changed hint,
+// or a target going down, takes effect on the next probe cycle
+// (ROOT_HEALTH_PROBE_MS) without a restart.
+//
+// GET /admin/routing/prompt (admin) returns the assembled prompt, its hash and
+// where each domain's text came from. Responses routed by the LLM carry the
+// hash in metadata routing.promptHash, so a routing decision can be matched to
+// the prompt it was made with.
+package root
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+)
+
+// Bounds on advertised text, so one upstream cannot crowd the prompt.
+const (
+	routeHintMaxDescription = 300
+	routeHintMaxExample     = 120
+	routeHintMaxExamples    = 3 // per language
+)
+
+// builtinRouteHints describes the stock domains when their agent advertises
+// nothing.
+var builtinRouteHints = map[string]selfid.RoutingHint{
+	"payment": {
+		Description: "Payments and money transfers: buying something, sending money, refunds and receipts.",
+		Examples: map[string][]string{
+			"ko": {"엄마한테 5만원 보내줘", "아이폰 15 결제해줘"},
+			"en": {"Send 50,000 won to my mom", "Buy an iPhone 15"},
+		},
+	},
+	"medical": {
+		Description: "Health questions: symptoms, conditions, medication and diet advice.",
+		Examples: map[string][]string{
+			"ko": {"당뇨가 있는데 뭘 먹으면 좋을까?"},
+			"en": {"What should I eat with diabetes?"},
+		},
+	},
+	"planning": {
+		Description: "Planning: schedules, itineraries, to-do lists and step-by-step plans.",
+		Examples: map[string][]string{
+			"ko": {"다음 주 제주도 여행 일정 짜줘"},
+			"en": {"Plan my trip to Jeju next week"},
+		},
+	},
+}
+
+// routeHints holds each agent's last advertised hint and probe results.
+type routeHints struct {
+	mu    sync.Mutex
+	hints map[string]*selfid.RoutingHint // agent -> advertised hint
+	up    map[string]map[string]bool     // agent -> route key -> last probe ok
+}
+
+func newRouteHints() *routeHints {
+	return &routeHints{hints: map[string]*selfid.RoutingHint{}, up: map[string]map[string]bool{}}
+}
+
+// note records one probe of agent over route key. A failed probe keeps the
+// last hint; an answer without one forgets it.
+func (h *routeHints) note(agent, key string, hint *selfid.RoutingHint, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.up[agent] == nil {
+		h.up[agent] = map[string]bool{}
+	}
+	h.up[agent][key] = ok
+	if !ok {
+		return
+	}
+	if hint == nil || strings.TrimSpace(hint.Description) == "" {
+		delete(h.hints, agent)
+		return
+	}
+	h.hints[agent] = hint
+}
+
+// get returns agent's advertised hint (nil when none) and whether every route
+// to it failed its last probe; an agent never probed is not down.
+func (h *routeHints) get(agent string) (*selfid.RoutingHint, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	routes := h.up[agent]
+	down := len(routes) > 0
+	for _, ok := range routes {
+		if ok {
+			down = false
+		}
+	}
+	return h.hints[agent], down
+}
+
+// routeDomain is one domain of the routing prompt.
+type routeDomain struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Examples    map[string][]string `json:"examples,omitempty"`
+	Source      string              `json:"source"` // advertised | builtin | name
+}
+
+// routePrompt is the assembled system prompt of the LLM router.
+type routePrompt struct {
+	Text     string            `json:"prompt"`
+	Hash     string            `json:"hash"`
+	Domains  []routeDomain     `json:"domains"`
+	Excluded map[string]string `json:"excluded,omitempty"` // target -> unconfigured | down
+}
+
+func (p routePrompt) has(domain string) bool {
+	for _, d := range p.Domains {
+		if d.Name == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// routePrompt builds the router prompt from the current targets and hints.
+func (r *RootAgent) routePrompt() routePrompt {
+	targets := r.knownTargets()
+	if !slices.Contains(targets, "planning") {
+		targets = append(targets, "planning")
+		sort.Strings(targets)
+	}
+	p := routePrompt{Excluded: map[string]string{}}
+	for _, t := range targets {
+		configured := r.externalURLFor(t) != ""
+		if !configured && t != "planning" {
+			p.Excluded[t] = "unconfigured"
+			continue
+		}
+		hint, down := r.routeHints.get(t)
+		if configured && down {
+			p.Excluded[t] = "down"
+			continue
+		}
+		d := routeDomain{Name: t, Source: "advertised"}
+		if hint == nil {
+			if b, ok := builtinRouteHints[t]; ok {
+				hint, d.Source = &b, "builtin"
+			} else {
+				hint, d.Source = &selfid.RoutingHint{Description: t + " requests."}, "name"
+			}
+		}
+		d.Description = clipHint(hint.Description, routeHintMaxDescription)
+		for lang, ex := range hint.Examples {
+			lang = strings.ToLower(strings.TrimSpace(lang))
+			for _, e := range ex {
+				if e = clipHint(e, routeHintMaxExample); e != "" && len(d.Examples[lang]) < routeHintMaxExamples {
+					if d.Examples == nil {
+						d.Examples = map[string][]string{}
+					}
+					d.Examples[lang] = append(d.Examples[lang], e)
+				}
+			}
+		}
+		p.Domains = append(p.Domains, d)
+	}
+
+	names := make([]string, 0, len(p.Domains)+1)
+	for _, d := range p.Domains {
+		names = append(names, d.Name)
+	}
+	names = append(names, "chat")
+	enum, _ := json.Marshal(names)
+	var b strings.Builder
+	b.WriteString("You are an intent classifier.\n")
+	fmt.Fprintf(&b, "Return a single JSON object with fields: domain in %s, lang in [\"ko\",\"en\"].\n", enum)
+	b.WriteString("Pick the most likely domain.\n\nDomains:\n")
+	for _, d := range p.Domains {
+		fmt.Fprintf(&b, "- %s: %s\n", d.Name, d.Description)
+		langs := make([]string, 0, len(d.Examples))
+		for l := range d.Examples {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		for _, l := range langs {
+			q := make([]string, len(d.Examples[l]))
+			for i, e := range d.Examples[l] {
+				q[i] = fmt.Sprintf("%q", e)
+			}
+			fmt.Fprintf(&b, "  examples (%s): %s\n", l, strings.Join(q, "; "))
+		}
+	}
+	b.WriteString("- chat: anything that fits none of the above (greetings, small talk, general questions).\n")
+	p.Text = b.String()
+	sum := sha256.Sum256([]byte(p.Text))
+	p.Hash = hex.EncodeToString(sum[:8])
+	return p
+}
+
+// clipHint folds whitespace (an advertised newline cannot start a prompt
+// line of its own) and caps s at max runes.
+func clipHint(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if rs := []rune(s); len(rs) > max {
+		s = string(rs[:max-1]) + "…"
+	}
+	return s
+}
+
+func (r *RootAgent) handleRoutingPrompt(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r.routePrompt())
+}
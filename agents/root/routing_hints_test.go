@@ -0,0 +1,129 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// hintAgent serves a /status that advertises hint (raw JSON, "" for none).
+func hintAgent(t *testing.T, name, hint string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body := `{"name":"` + name + `","type":"` + name + `"`
+		if hint != "" {
+			body += `,"routingHint":` + hint
+		}
+		_, _ = w.Write([]byte(body + "}"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func probeAll(r *RootAgent) {
+	for _, a := range r.knownTargets() {
+		if base := r.externalURLFor(a); base != "" {
+			_ = r.probeOnce(context.Background(), a, a, base)
+		}
+	}
+}
+
+func domainsOf(p routePrompt) map[string]routeDomain {
+	out := map[string]routeDomain{}
+	for _, d := range p.Domains {
+		out[d.Name] = d
+	}
+	return out
+}
+
+// The prompt lists each reachable target with what it advertises, falls back
+// to the built-in text or the bare name, and drops targets that are down.
+func TestRoutePromptHints(t *testing.T) {
+	pay := hintAgent(t, "payment", `{"description":"Card payments\nand refunds.","examples":{"ko":["a","b","c","d"],"EN":["pay me"]}}`)
+	med := hintAgent(t, "medical", "")
+	ord := hintAgent(t, "ordering", "")
+	t.Setenv("PAYMENT_URL", pay.URL)
+	t.Setenv("MEDICAL_URL", med.URL)
+	t.Setenv("PLANNING_URL", "")
+	r := statusRoot(t)
+	r.SetExternalURL("ordering", ord.URL)
+	r.SetExternalURL("legacy", "")
+	probeAll(r)
+
+	p := r.routePrompt()
+	ds := domainsOf(p)
+	if got := ds["payment"]; got.Source != "advertised" || got.Description != "Card payments and refunds." ||
+		len(got.Examples["ko"]) != routeHintMaxExamples || got.Examples["en"][0] != "pay me" {
+		t.Fatalf("payment %+v", got)
+	}
+	if ds["medical"].Source != "builtin" || ds["planning"].Source != "builtin" || ds["ordering"].Source != "name" {
+		t.Fatalf("fallbacks %+v", ds)
+	}
+	if p.Excluded["legacy"] != "unconfigured" {
+		t.Fatalf("excluded %v", p.Excluded)
+	}
+	if !strings.Contains(p.Text, `domain in ["medical","ordering","payment","planning","chat"]`) ||
+		!strings.Contains(p.Text, "- ordering: ordering requests.\n") {
+		t.Fatalf("prompt:\n%s", p.Text)
+	}
+	if again := r.routePrompt(); again.Hash != p.Hash {
+		t.Fatal("hash not stable")
+	}
+
+	med.Close()
+	probeAll(r)
+	down := r.routePrompt()
+	if down.Excluded["medical"] != "down" || down.has("medical") || down.Hash == p.Hash {
+		t.Fatalf("medical down: %v %s", down.Excluded, down.Hash)
+	}
+	// A failed probe keeps the last advertised hint
+	pay.Close()
+	probeAll(r)
+	if h, isDown := r.routeHints.get("payment"); !isDown || h == nil || !strings.HasPrefix(h.Description, "Card payments") {
+		t.Fatalf("payment after a failed probe: %+v down=%v", h, isDown)
+	}
+}
+
+// The router takes any domain in its prompt and reports the prompt's hash;
+// one the prompt does not list is refused.
+func TestLLMRouteUsesPrompt(t *testing.T) {
+	ord := hintAgent(t, "ordering", `{"description":"Food orders and deliveries."}`)
+	t.Setenv("PAYMENT_URL", "")
+	t.Setenv("MEDICAL_URL", "")
+	r := statusRoot(t)
+	r.SetExternalURL("ordering", ord.URL)
+	probeAll(r)
+	llm := &promptLLM{reply: `{"domain":"ordering","lang":"en"}`}
+	r.llmClient = llm
+
+	out, ok := r.llmRoute(context.Background(), "get me a pizza")
+	if !ok || out.Domain != "ordering" || out.PromptHash != r.routePrompt().Hash {
+		t.Fatalf("route %+v %v", out, ok)
+	}
+	if !strings.Contains(llm.system, "- ordering: Food orders and deliveries.") {
+		t.Fatalf("system prompt:\n%s", llm.system)
+	}
+	llm.reply = `{"domain":"medical","lang":"en"}`
+	if out, ok := r.llmRoute(context.Background(), "get me a pizza"); ok {
+		t.Fatalf("unlisted domain routed: %+v", out)
+	}
+
+	t.Setenv("ROOT_ADMIN_TOKEN", "hints-test")
+	req := httptest.NewRequest(http.MethodGet, "/admin/routing/prompt", nil)
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("without token: %d", w.Code)
+	}
+	req.Header.Set("X-Admin-Token", "hints-test")
+	w = httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	var got routePrompt
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.Hash != out.PromptHash || !got.has("ordering") {
+		t.Fatalf("admin prompt: %d %v %+v", w.Code, err, got)
+	}
+}
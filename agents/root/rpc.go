@@ -0,0 +1,301 @@
+// Package root - JSON-RPC 2.0 transport for backend integrators.
+//
+// POST /rpc (internal/jsonrpc) offers a schema'd alternative to the REST
+// surface with four methods:
+//
+//	Process          {message, meta}              -> {message, httpStatus, security}
+//	Confirm          {contextId, token, confirm, meta} -> same as Process
+//	GetConversation  {contextId}                  -> GET /conversations/{cid}
+//	GetStatus        {}                           -> GET /status
+//
+// Every method is dispatched in-process through Root's own mux (POST /process,
+// GET /conversations/{cid}, GET /status), so the flow engine, metadata
+// policy, admin gate and response stamping are exactly the REST ones and the
+// two surfaces cannot drift. Call meta maps 1:1 to the REST headers
+// (sage → X-SAGE-Enabled, hpke → X-HPKE-Enabled, dryRun → X-SAGE-Dry-Run,
+// lang → X-Lang, conversationId → X-Conversation-Id, timezone →
+// X-SAGE-Timezone); the admin token and RFC 9421 signature headers of the
+// /rpc request itself are passed through.
+// A REST status >= 400 becomes a JSON-RPC error with the canonical status
+// (jsonrpc.FromHTTPStatus) and {httpStatus, body} in error.data.
+//
+// Confirm answers a payment confirm/re-quote or refund confirmation, but only
+// when token matches the one Root issued (metadata confirmToken); a stale or
+// missing confirmation is FAILED_PRECONDITION.
+//
+// ROOT_RPC_ENABLED=true mounts /rpc on Root's port; ROOT_RPC_ADDR (e.g.
+// ":18090") serves it on a second listener instead.
+package root
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/jsonrpc"
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// rpcMeta is the per-call equivalent of Root's request headers.
+type rpcMeta struct {
+	SAGE           *bool  `json:"sage,omitempty"`
+	HPKE           *bool  `json:"hpke,omitempty"`
+	DryRun         bool   `json:"dryRun,omitempty"`
+	Lang           string `json:"lang,omitempty"`
+	ConversationID string `json:"conversationId,omitempty"`
+	Timezone       string `json:"timezone,omitempty"`
+}
+
+type rpcProcessParams struct {
+	Message types.AgentMessage `json:"message"`
+	Meta    rpcMeta            `json:"meta"`
+}
+
+type rpcConfirmParams struct {
+	ContextID string  `json:"contextId"`
+	Token     string  `json:"token"`
+	Confirm   bool    `json:"confirm"`
+	Meta      rpcMeta `json:"meta"`
+}
+
+type rpcConversationParams struct {
+	ContextID string `json:"contextId"`
+}
+
+// rpcSecurity carries the security response headers of /process.
+type rpcSecurity struct {
+	Posture          string `json:"posture,omitempty"`
+	Verified         string `json:"verified,omitempty"`
+	SignatureValid   string `json:"signatureValid,omitempty"`
+	MetadataStripped string `json:"metadataStripped,omitempty"`
+}
+
+type rpcProcessResult struct {
+	Message    types.AgentMessage `json:"message"`
+	HTTPStatus int                `json:"httpStatus"`
+	Security   rpcSecurity        `json:"security"`
+}
+
+// rpcPassHeaders are copied from the /rpc request onto each dispatched call.
+var rpcPassHeaders = []string{
+	"Authorization", "X-Admin-Token", "Signature", "Signature-Input", "Content-Digest",
+	"X-SAGE-Enabled", "X-HPKE-Enabled", "X-SAGE-Dry-Run", "X-Lang", "X-Conversation-Id", "X-SAGE-Context-Id",
+	tz.Header,
+}
+
+// rpcRecorder is the in-memory ResponseWriter for dispatched calls.
+type rpcRecorder struct {
+	hdr    http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *rpcRecorder) Header() http.Header { return rr.hdr }
+func (rr *rpcRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+}
+func (rr *rpcRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	return rr.body.Write(b)
+}
+
+// dispatch runs method+path (+body) through Root's mux on behalf of outer.
+func (r *RootAgent) dispatch(outer *http.Request, method, path string, body []byte, meta rpcMeta) *rpcRecorder {
+	req, err := http.NewRequestWithContext(outer.Context(), method, path, bytes.NewReader(body))
+	rr := &rpcRecorder{hdr: http.Header{}}
+	if err != nil {
+		rr.status = http.StatusBadRequest
+		return rr
+	}
+	req.RemoteAddr = outer.RemoteAddr
+	for _, h := range rpcPassHeaders {
+		if v := outer.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if meta.SAGE != nil {
+		req.Header.Set("X-SAGE-Enabled", strconv.FormatBool(*meta.SAGE))
+	}
+	if meta.HPKE != nil {
+		req.Header.Set("X-HPKE-Enabled", strconv.FormatBool(*meta.HPKE))
+	}
+	if meta.DryRun {
+		req.Header.Set("X-SAGE-Dry-Run", "true")
+	}
+	if v := strings.TrimSpace(meta.Lang); v != "" {
+		req.Header.Set("X-Lang", v)
+	}
+	if v := strings.TrimSpace(meta.ConversationID); v != "" {
+		req.Header.Set("X-Conversation-Id", v)
+	}
+	if v := strings.TrimSpace(meta.Timezone); v != "" {
+		req.Header.Set(tz.Header, v)
+	}
+	r.mux.ServeHTTP(rr, req)
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	return rr
+}
+
+// rpcError maps a failed REST call to a canonical JSON-RPC error.
+func rpcError(rr *rpcRecorder) error {
+	var body any = strings.TrimSpace(rr.body.String())
+	var decoded any
+	if json.Unmarshal(rr.body.Bytes(), &decoded) == nil {
+		body = decoded
+	}
+	msg := http.StatusText(rr.status)
+	if m, ok := decoded.(map[string]any); ok {
+		msg = firstNonEmpty(strFrom(m, "message", "error", "content"), msg)
+	} else if s, ok := body.(string); ok && s != "" {
+		msg = s
+	}
+	return jsonrpc.Errorf(jsonrpc.FromHTTPStatus(rr.status), map[string]any{"httpStatus": rr.status, "body": body}, "%s", msg)
+}
+
+// rpcJSON decodes a successful REST body into v.
+func rpcJSON(rr *rpcRecorder, v any) error {
+	if rr.status >= 400 {
+		return rpcError(rr)
+	}
+	if err := json.Unmarshal(rr.body.Bytes(), v); err != nil {
+		return jsonrpc.Errorf(jsonrpc.Internal, map[string]any{"httpStatus": rr.status}, "undecodable response: %v", err)
+	}
+	return nil
+}
+
+// rpcProcess sends msg through POST /process.
+func (r *RootAgent) rpcProcess(outer *http.Request, msg types.AgentMessage, meta rpcMeta) (any, error) {
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil, jsonrpc.Errorf(jsonrpc.InvalidArgument, nil, "message.content is required")
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.InvalidArgument, nil, "message: %v", err)
+	}
+	rr := r.dispatch(outer, http.MethodPost, "/process", b, meta)
+	var res rpcProcessResult
+	if err := rpcJSON(rr, &res.Message); err != nil {
+		return nil, err
+	}
+	res.HTTPStatus = rr.status
+	res.Security = rpcSecurity{
+		Posture:          rr.hdr.Get("X-SAGE-Posture"),
+		Verified:         rr.hdr.Get(hdrVerified),
+		SignatureValid:   rr.hdr.Get(hdrSignatureValid),
+		MetadataStripped: rr.hdr.Get("X-Root-Metadata-Stripped"),
+	}
+	return res, nil
+}
+
+// confirmPending reports whether token is the confirm token Root issued for cid.
+func (r *RootAgent) confirmPending(cid, token string) bool {
+	if x, ok := r.refundPendings.Load(cid); ok && x.(refundPending).Token == token {
+		return true
+	}
+	stage, t := r.getStageToken(cid)
+	return (stage == "await_confirm" || stage == "await_requote") && t != "" && t == token
+}
+
+func (r *RootAgent) newRPCServer() *jsonrpc.Server {
+	s := jsonrpc.NewServer()
+	s.Register("Process", func(ctx context.Context, req *http.Request, params json.RawMessage) (any, error) {
+		var p rpcProcessParams
+		if err := jsonrpc.Decode(params, &p); err != nil {
+			return nil, err
+		}
+		return r.rpcProcess(req, p.Message, p.Meta)
+	})
+	s.Register("Confirm", func(ctx context.Context, req *http.Request, params json.RawMessage) (any, error) {
+		var p rpcConfirmParams
+		if err := jsonrpc.Decode(params, &p); err != nil {
+			return nil, err
+		}
+		cid, token := strings.TrimSpace(p.ContextID), strings.TrimSpace(p.Token)
+		if cid == "" || token == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidArgument, nil, "contextId and token are required")
+		}
+		if !r.confirmPending(cid, token) {
+			return nil, jsonrpc.Errorf(jsonrpc.FailedPrecondition, map[string]any{"contextId": cid}, "no pending confirmation for this token")
+		}
+		// parseYesNo vocabulary: "ok" confirms, "no" declines
+		text := "no"
+		if p.Confirm {
+			text = "ok"
+		}
+		p.Meta.ConversationID = cid
+		return r.rpcProcess(req, types.AgentMessage{ID: "rpc-confirm-" + token, ContextID: cid, From: "rpc", To: "root", Type: "request", Content: text}, p.Meta)
+	})
+	s.Register("GetConversation", func(ctx context.Context, req *http.Request, params json.RawMessage) (any, error) {
+		var p rpcConversationParams
+		if err := jsonrpc.Decode(params, &p); err != nil {
+			return nil, err
+		}
+		cid := strings.TrimSpace(p.ContextID)
+		if cid == "" || strings.Contains(cid, "/") {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidArgument, nil, "contextId is required")
+		}
+		var v conversationView
+		if err := rpcJSON(r.dispatch(req, http.MethodGet, "/conversations/"+cid, nil, rpcMeta{}), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+	s.Register("GetStatus", func(ctx context.Context, req *http.Request, params json.RawMessage) (any, error) {
+		var v map[string]any
+		if err := rpcJSON(r.dispatch(req, http.MethodGet, "/status", nil, rpcMeta{}), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+	return s
+}
+
+// mountRPC mounts /rpc per ROOT_RPC_ENABLED / ROOT_RPC_ADDR.
+func (r *RootAgent) mountRPC() {
+	addr := strings.TrimSpace(os.Getenv("ROOT_RPC_ADDR"))
+	if !envBool("ROOT_RPC_ENABLED", false) && addr == "" {
+		return
+	}
+	s := r.newRPCServer()
+	if addr == "" {
+		r.mux.Handle("/rpc", s)
+		r.logger.Printf("[root][rpc] JSON-RPC enabled at /rpc")
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", s)
+	r.rpcServer = &http.Server{Addr: addr, Handler: r.reqm.Wrap(mux)}
+}
+
+// startRPC serves the second RPC listener, if configured.
+func (r *RootAgent) startRPC() error {
+	if r.rpcServer == nil {
+		return nil
+	}
+	ln, err := net.Listen("tcp", r.rpcServer.Addr)
+	if err != nil {
+		return err
+	}
+	r.logger.Printf("[root][rpc] JSON-RPC listening on %s/rpc", ln.Addr().String())
+	// Holds one worker of r.bg until Shutdown closes the listener
+	return r.Background(func(context.Context) {
+		if err := r.rpcServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			r.logger.Printf("[root][rpc] serve: %v", err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/jsonrpc"
+)
+
+type rpcReply struct {
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpc.Error  `json:"error"`
+}
+
+func (r rpcReply) status() string {
+	if r.Error == nil {
+		return ""
+	}
+	d, _ := r.Error.Data.(map[string]any)
+	s, _ := d["status"].(string)
+	return s
+}
+
+func rpcCall(t *testing.T, r *RootAgent, admin bool, method string, params any) rpcReply {
+	t.Helper()
+	b, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(string(b)))
+	if admin {
+		req.Header.Set("X-Admin-Token", "fork-test")
+	}
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	var out rpcReply
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s: %d %s", method, w.Code, w.Body)
+	}
+	return out
+}
+
+// A payment taken through Process, Confirm and GetConversation the way a
+// backend would, with the REST failures mapped onto canonical errors.
+func TestRPCPaymentFlow(t *testing.T) {
+	t.Setenv("ROOT_RPC_ENABLED", "true")
+	env := newForkEnv(t, 1)
+	const cid = "ctx-rpc"
+	process := func(text string) rpcProcessResult {
+		t.Helper()
+		out := rpcCall(t, env.r, false, "Process", map[string]any{
+			"message": map[string]any{"id": "m1", "from": "backend", "content": text, "contextId": cid},
+			"meta":    map[string]any{"conversationId": cid, "lang": "ko"},
+		})
+		var res rpcProcessResult
+		if out.Error != nil || json.Unmarshal(out.Result, &res) != nil {
+			t.Fatalf("Process %q: %+v", text, out.Error)
+		}
+		return res
+	}
+
+	process("맥북 사줘")
+	res := process("카드로, 애플스토어, 서울 강남구, 300만원")
+	token, _ := res.Message.Metadata["confirmToken"].(string)
+	if res.HTTPStatus != http.StatusOK || token == "" || res.Security.Verified != verifiedNA {
+		t.Fatalf("preview %+v", res)
+	}
+
+	if out := rpcCall(t, env.r, false, "Confirm", map[string]any{"contextId": cid, "token": "stale", "confirm": true}); out.status() != "FAILED_PRECONDITION" || out.Error.Code != -32009 {
+		t.Fatalf("stale token: %+v", out.Error)
+	}
+	if len(env.charges) != 0 {
+		t.Fatal("stale token charged")
+	}
+
+	// the payment agent fails the first charge: 502 -> UNAVAILABLE with the REST status
+	out := rpcCall(t, env.r, false, "Confirm", map[string]any{"contextId": cid, "token": token, "confirm": true})
+	if out.status() != "UNAVAILABLE" || out.Error.Data.(map[string]any)["httpStatus"] != float64(http.StatusBadGateway) {
+		t.Fatalf("upstream failure: %+v", out.Error)
+	}
+	env.charge(t)
+
+	if out := rpcCall(t, env.r, false, "GetConversation", map[string]any{"contextId": cid}); out.status() != "PERMISSION_DENIED" {
+		t.Fatalf("conversation without admin: %+v", out.Error)
+	}
+	out = rpcCall(t, env.r, true, "GetConversation", map[string]any{"contextId": cid})
+	var view conversationView
+	if out.Error != nil || json.Unmarshal(out.Result, &view) != nil || view.ContextID != cid || !view.Known || view.Payment == nil || view.Payment.Stage != "await_confirm" {
+		t.Fatalf("conversation: %+v %s", out.Error, out.Result)
+	}
+
+	_, token = env.r.getStageToken(cid)
+	out = rpcCall(t, env.r, false, "Confirm", map[string]any{"contextId": cid, "token": token, "confirm": true})
+	if err := json.Unmarshal(out.Result, &res); out.Error != nil || err != nil || !strings.Contains(res.Message.Content, "결제 완료") {
+		t.Fatalf("confirm: %+v %s", out.Error, out.Result)
+	}
+	env.charge(t)
+
+	if out := rpcCall(t, env.r, true, "GetConversation", map[string]any{"contextId": "ctx-none"}); out.status() != "NOT_FOUND" {
+		t.Fatalf("unknown conversation: %+v", out.Error)
+	}
+}
+
+func TestRPCArguments(t *testing.T) {
+	t.Setenv("ROOT_RPC_ENABLED", "true")
+	env := newForkEnv(t, 0)
+	cases := []struct {
+		method string
+		params any
+		status string
+	}{
+		{"Process", map[string]any{"message": map[string]any{"content": " "}}, "INVALID_ARGUMENT"},
+		{"Process", map[string]any{"message": map[string]any{"content": "hi"}, "meta": map[string]any{"sgae": true}}, "INVALID_ARGUMENT"},
+		{"Confirm", map[string]any{"contextId": "c"}, "INVALID_ARGUMENT"},
+		{"GetConversation", map[string]any{"contextId": "a/b"}, "INVALID_ARGUMENT"},
+		{"Refund", map[string]any{}, "UNIMPLEMENTED"},
+	}
+	for _, tc := range cases {
+		if out := rpcCall(t, env.r, true, tc.method, tc.params); out.status() != tc.status {
+			t.Errorf("%s %v: %+v", tc.method, tc.params, out.Error)
+		}
+	}
+	out := rpcCall(t, env.r, false, "GetStatus", nil)
+	var st map[string]any
+	if out.Error != nil || json.Unmarshal(out.Result, &st) != nil || st["name"] == nil {
+		t.Fatalf("status: %+v %s", out.Error, out.Result)
+	}
+}
@@ -0,0 +1,126 @@
+// Package root - rule-first extraction for short clarify answers.
+//
+// Answers to a payment clarify question are usually a word or two ("토스로",
+// "50만원"). For those the keyword/regex extractor runs first and the LLM
+// extractor is only consulted when the rules filled none of the slots the
+// question asked for; longer free-form turns keep the LLM-first order.
+// ROOT_RULE_FIRST_MAX_RUNES sets the length threshold (default 20, 0 disables);
+// the root.rule_first flag turns the behavior off at runtime.
+//
+// While a clarify answer is pending the LLM connection is pre-warmed, so the
+// fallback and the next question do not pay a cold TLS handshake. Per-path
+// extraction latency is exposed in /status ("extraction") and per turn in the
+// response metadata ("timings").
+package root
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sage-x-project/sage-multi-agent/llm"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func ruleFirstMaxRunes() int { return envInt("ROOT_RULE_FIRST_MAX_RUNES", 20) }
+
+// ruleFirstEligible: a short answer in the collect stage of a started payment.
+func ruleFirstEligible(stage, text string, slots paySlots) bool {
+	max := ruleFirstMaxRunes()
+	if max <= 0 || !flagRuleFirst.On() || stage != "collect" || !payCtxNotEmpty(slots) {
+		return false
+	}
+	n := utf8.RuneCountInString(strings.TrimSpace(text))
+	return n > 0 && n <= max
+}
+
+// rulePaySlots runs the keyword/regex extractor and defaults to/recipient from each other.
+func rulePaySlots(nmsg *types.AgentMessage, turn int) paySlots {
+	s, _, _ := extractPaymentSlots(nmsg)
+	if s.To == "" && strings.TrimSpace(s.Recipient) != "" {
+		s.To = s.Recipient
+		markProv(&s.Prov, "to", slotSource{Src: provDefault + "recipient"})
+	}
+	if s.Recipient == "" && strings.TrimSpace(s.To) != "" {
+		s.Recipient = s.To
+		markProv(&s.Prov, "recipient", slotSource{Src: provDefault + "to"})
+	}
+	s.Prov.stamp(turn)
+	return s
+}
+
+// filledAny reports whether s covers at least one of the previously missing fields.
+func filledAny(prevMissing []string, s paySlots) bool {
+	still := map[string]bool{}
+	for _, m := range computeMissingPayment(s) {
+		still[m] = true
+	}
+	for _, m := range prevMissing {
+		if !still[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// extractTimings aggregates extraction latency per path ("rule", "llm", "rule-fallback").
+type extractTimings struct {
+	mu sync.Mutex
+	n  map[string]int64
+	ms map[string]int64
+}
+
+func (t *extractTimings) observe(path string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == nil {
+		t.n, t.ms = map[string]int64{}, map[string]int64{}
+	}
+	t.n[path]++
+	t.ms[path] += d.Milliseconds()
+}
+
+func (t *extractTimings) snapshot() map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]any, len(t.n))
+	for k, n := range t.n {
+		out[k] = map[string]any{"count": n, "avgMs": t.ms[k] / n}
+	}
+	return out
+}
+
+// turnTimings is the per-response "timings" metadata.
+func turnTimings(req *http.Request, extractor string, extract time.Duration) map[string]any {
+	return map[string]any{
+		"extractor": extractor,
+		"extractMs": extract.Milliseconds(),
+		"turnMs":    time.Since(reqStartFrom(req.Context())).Milliseconds(),
+	}
+}
+
+// prewarmLLM opens the LLM connection in the background (at most every 20s).
+func (r *RootAgent) prewarmLLM() {
+	now := time.Now().UnixNano()
+	last := r.lastLLMWarm.Load()
+	if now-last < int64(20*time.Second) || !r.lastLLMWarm.CompareAndSwap(last, now) {
+		return
+	}
+	r.ensureLLM()
+	wm, ok := r.llmClient.(llm.Warmer)
+	if !ok {
+		return
+	}
+	if err := r.Background(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := wm.Warm(ctx); err != nil {
+			r.logger.Printf("[root][llm][warm] %v", err)
+		}
+	}); err != nil {
+		r.logger.Printf("[root][llm][warm] not scheduled: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package root
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// extractCounter counts payment extraction prompts reaching the LLM.
+type extractCounter struct {
+	scriptedExtractor
+	n int
+}
+
+func (c *extractCounter) Chat(ctx context.Context, system, user string) (string, error) {
+	if strings.Contains(system, `"fields":{"mode"`) {
+		c.n++
+	}
+	return c.scriptedExtractor.Chat(ctx, system, user)
+}
+
+// ruleFirstEnv is a payment at the collect stage with only the item known.
+func ruleFirstEnv(t *testing.T) (*forkEnv, *extractCounter) {
+	t.Helper()
+	env := newForkEnv(t, 0)
+	script := env.r.llmClient.(scriptedExtractor)
+	script["평소 쓰던"] = `{"fields":{"method":"card"}}`
+	llm := &extractCounter{scriptedExtractor: script}
+	env.r.llmClient = llm
+	env.send(t, "ctx-r", "맥북 사줘")
+	llm.n = 0
+	return env, llm
+}
+
+func TestRuleFirstEligible(t *testing.T) {
+	started := paySlots{Item: "맥북"}
+	cases := []struct {
+		name, stage, text string
+		slots             paySlots
+		want              bool
+	}{
+		{"short answer", "collect", "토스로", started, true},
+		{"long answer", "collect", "음 생각해보니 토스로 하고 배송은 회사로 해줘", started, false},
+		{"first turn", "", "토스로", started, false},
+		{"nothing started", "collect", "토스로", paySlots{}, false},
+		{"blank", "collect", "  ", started, false},
+	}
+	for _, c := range cases {
+		if got := ruleFirstEligible(c.stage, c.text, c.slots); got != c.want {
+			t.Errorf("%s: got %v", c.name, got)
+		}
+	}
+
+	t.Setenv("ROOT_RULE_FIRST_MAX_RUNES", "0")
+	if ruleFirstEligible("collect", "토스로", started) {
+		t.Error("threshold 0 did not disable rule-first")
+	}
+}
+
+// A short answer the rules understand never reaches the LLM extractor.
+func TestRuleFirstShortAnswer(t *testing.T) {
+	env, llm := ruleFirstEnv(t)
+	out := env.send(t, "ctx-r", "토스로")
+	if llm.n != 0 {
+		t.Fatalf("LLM extractor called %d times", llm.n)
+	}
+	if tm, _ := out.Metadata["timings"].(map[string]any); tm["extractor"] != "rule" {
+		t.Fatalf("timings %v", out.Metadata["timings"])
+	}
+	if s := env.r.getPayCtx("ctx-r"); s.Method == "" || s.Item != "맥북" {
+		t.Fatalf("slots %+v", s)
+	}
+}
+
+// A short answer that fills none of the missing slots falls back to the LLM.
+func TestRuleFirstFallsBack(t *testing.T) {
+	env, llm := ruleFirstEnv(t)
+	out := env.send(t, "ctx-r", "평소 쓰던 걸로")
+	if llm.n != 1 {
+		t.Fatalf("LLM extractor called %d times", llm.n)
+	}
+	if tm, _ := out.Metadata["timings"].(map[string]any); tm["extractor"] != "llm" {
+		t.Fatalf("timings %v", out.Metadata["timings"])
+	}
+	if s := env.r.getPayCtx("ctx-r"); s.Method != "card" {
+		t.Fatalf("slots %+v", s)
+	}
+}
+
+// Long free-form answers keep the LLM-first order even when rules would do.
+func TestRuleFirstLongAnswer(t *testing.T) {
+	env, llm := ruleFirstEnv(t)
+	env.send(t, "ctx-r", "카드로, 애플스토어, 서울 강남구, 300만원")
+	if llm.n != 1 {
+		t.Fatalf("LLM extractor called %d times", llm.n)
+	}
+}
+
+func TestFilledAny(t *testing.T) {
+	prev := computeMissingPayment(paySlots{Item: "맥북"})
+	if filledAny(prev, paySlots{Item: "맥북"}) {
+		t.Fatal("nothing new counted as filled")
+	}
+	if !filledAny(prev, paySlots{Item: "맥북", Method: "toss"}) {
+		t.Fatalf("method not counted (missing %v)", prev)
+	}
+}
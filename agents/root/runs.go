@@ -0,0 +1,213 @@
+// Package root - demo "runs" for isolating telemetry of one presentation.
+//
+// POST /admin/run/start {label} opens a run; while it is active its ID is
+// stamped on every audit event, forwarded to external agents as metadata
+// ("run"), and shown in /status. POST /admin/run/stop closes it and emits a
+// summary event. GET /admin/runs lists recent runs. Only one run is active at
+// a time; starting a new one auto-closes the previous.
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+const maxRecentRuns = 20
+
+type demoRun struct {
+	ID            string    `json:"id"`
+	Label         string    `json:"label,omitempty"`
+	StartedAt     time.Time `json:"startedAt"`
+	EndedAt       time.Time `json:"endedAt,omitempty"`
+	DurationMs    int64     `json:"durationMs,omitempty"`
+	Conversations int       `json:"conversations"`
+	Errors        int       `json:"errors"`
+	Tamper        int       `json:"tamperDetections"`
+	AutoClosed    bool      `json:"autoClosed,omitempty"`
+}
+
+type runTracker struct {
+	mu     sync.Mutex
+	cur    *demoRun
+	cids   map[string]bool
+	recent []demoRun // newest last
+}
+
+func newRunTracker() *runTracker { return &runTracker{} }
+
+// start opens a run, closing (and returning) any active one.
+func (t *runTracker) start(label string) (demoRun, *demoRun) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var prev *demoRun
+	if t.cur != nil {
+		p := t.closeLocked(true)
+		prev = &p
+	}
+	t.cur = &demoRun{ID: "run-" + uuid.NewString()[:8], Label: label, StartedAt: time.Now().UTC()}
+	t.cids = map[string]bool{}
+	return *t.cur, prev
+}
+
+func (t *runTracker) stop() (demoRun, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur == nil {
+		return demoRun{}, false
+	}
+	return t.closeLocked(false), true
+}
+
+func (t *runTracker) closeLocked(auto bool) demoRun {
+	r := *t.cur
+	r.EndedAt = time.Now().UTC()
+	r.DurationMs = r.EndedAt.Sub(r.StartedAt).Milliseconds()
+	r.AutoClosed = auto
+	t.recent = append(t.recent, r)
+	if len(t.recent) > maxRecentRuns {
+		t.recent = t.recent[len(t.recent)-maxRecentRuns:]
+	}
+	t.cur, t.cids = nil, nil
+	return r
+}
+
+// current returns the active run ID ("" when none).
+func (t *runTracker) current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur == nil {
+		return ""
+	}
+	return t.cur.ID
+}
+
+func (t *runTracker) active() (demoRun, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur == nil {
+		return demoRun{}, false
+	}
+	return *t.cur, true
+}
+
+// touch counts a conversation seen during the active run.
+func (t *runTracker) touch(cid string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur != nil && cid != "" && !t.cids[cid] {
+		t.cids[cid] = true
+		t.cur.Conversations++
+	}
+}
+
+func (t *runTracker) noteError() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur != nil {
+		t.cur.Errors++
+	}
+}
+
+func (t *runTracker) noteTamper() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur != nil {
+		t.cur.Tamper++
+	}
+}
+
+// list returns recent closed runs (newest first) plus the active one, if any.
+func (t *runTracker) list() []demoRun {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]demoRun, 0, len(t.recent)+1)
+	if t.cur != nil {
+		out = append(out, *t.cur)
+	}
+	for i := len(t.recent) - 1; i >= 0; i-- {
+		out = append(out, t.recent[i])
+	}
+	return out
+}
+
+// ---- HTTP ----
+
+func (r *RootAgent) handleRunStart(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	var in struct {
+		Label string `json:"label"`
+	}
+	if probs := decodeAdminBody(req, map[string]fieldSpec{
+		"label": {Kind: "string"},
+	}, &in); len(probs) > 0 {
+		writeInvalidRequest(w, probs)
+		return
+	}
+	run, prev := r.runs.start(strings.TrimSpace(in.Label))
+	if prev != nil {
+		r.logger.Printf("[root][run][warn] run %s (%q) still active; auto-closed before starting %s", prev.ID, prev.Label, run.ID)
+		r.emitRunSummary(req, *prev)
+	}
+	r.audit.SetRun(run.ID)
+	r.audit.Emit(audit.Event{Type: "run", Action: "run.start", Outcome: "success", Actor: requesterOf(req), Detail: map[string]any{"label": run.Label}})
+	r.logger.Printf("[root][run] started %s label=%q", run.ID, run.Label)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"run": run, "autoClosed": prev})
+}
+
+func (r *RootAgent) handleRunStop(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	run, ok := r.runs.stop()
+	if !ok {
+		http.Error(w, "no active run", http.StatusConflict)
+		return
+	}
+	r.emitRunSummary(req, run)
+	r.audit.SetRun("")
+	r.logger.Printf("[root][run] stopped %s durMs=%d conversations=%d errors=%d tamper=%d",
+		run.ID, run.DurationMs, run.Conversations, run.Errors, run.Tamper)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"run": run})
+}
+
+func (r *RootAgent) handleRuns(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"runs": r.runs.list()})
+}
+
+// emitRunSummary records the closed run; the event carries the run's own ID.
+func (r *RootAgent) emitRunSummary(req *http.Request, run demoRun) {
+	r.audit.Emit(audit.Event{
+		Type: "run", Action: "run.summary", Outcome: "success", Actor: requesterOf(req), Run: run.ID,
+		Detail: map[string]any{
+			"label": run.Label, "durationMs": run.DurationMs, "conversations": run.Conversations,
+			"errors": run.Errors, "tamperDetections": run.Tamper, "autoClosed": run.AutoClosed,
+		},
+	})
+}
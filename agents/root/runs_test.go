@@ -0,0 +1,109 @@
+package root
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+)
+
+func startRun(t *testing.T, r *RootAgent, label string) (demoRun, *demoRun) {
+	t.Helper()
+	w := adminCall(r, http.MethodPost, "/admin/run/start", map[string]any{"label": label})
+	var out struct {
+		Run        demoRun  `json:"run"`
+		AutoClosed *demoRun `json:"autoClosed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil || w.Code != http.StatusOK || out.Run.ID == "" {
+		t.Fatalf("run start: %d %v", w.Code, err)
+	}
+	return out.Run, out.AutoClosed
+}
+
+func listRuns(t *testing.T, r *RootAgent) []demoRun {
+	t.Helper()
+	w := adminCall(r, http.MethodGet, "/admin/runs", nil)
+	var out struct {
+		Runs []demoRun `json:"runs"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("runs: %d %v", w.Code, err)
+	}
+	return out.Runs
+}
+
+// Starting a run while one is active closes the old one; stopping twice is a
+// conflict.
+func TestRunSingleActive(t *testing.T) {
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	r := statusRoot(t)
+	first, prev := startRun(t, r, "morning")
+	if prev != nil {
+		t.Fatalf("nothing to auto-close, got %+v", prev)
+	}
+	second, prev := startRun(t, r, "afternoon")
+	if prev == nil || prev.ID != first.ID || !prev.AutoClosed || prev.EndedAt.IsZero() {
+		t.Fatalf("auto-closed %+v", prev)
+	}
+
+	runs := listRuns(t, r)
+	if len(runs) != 2 || runs[0].ID != second.ID || !runs[0].EndedAt.IsZero() || runs[1].ID != first.ID {
+		t.Fatalf("runs %+v", runs)
+	}
+
+	if w := adminCall(r, http.MethodPost, "/admin/run/stop", nil); w.Code != http.StatusOK {
+		t.Fatalf("stop: %d", w.Code)
+	}
+	if w := adminCall(r, http.MethodPost, "/admin/run/stop", nil); w.Code != http.StatusConflict {
+		t.Fatalf("second stop: %d", w.Code)
+	}
+	if r.runs.current() != "" {
+		t.Fatal("run still active after stop")
+	}
+}
+
+// Events, forwarded metadata and the summary carry the run ID only while the
+// run is active; the summary counts conversations and errors seen in it.
+func TestRunTagging(t *testing.T) {
+	dir := t.TempDir()
+	env := newForkEnv(t, 2)
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	a := audit.New("root", dir, log.New(io.Discard, "", 0))
+	env.r.audit = a
+
+	run, _ := startRun(t, env.r, "demo")
+	env.send(t, "ctx-a", "맥북 사줘")
+	env.send(t, "ctx-a", "카드로, 애플스토어, 서울 강남구, 300만원")
+	env.send(t, "ctx-a", "네") // both charges fail
+	if got := env.charge(t)["run"]; got != run.ID {
+		t.Fatalf("forwarded run %v, want %s", got, run.ID)
+	}
+	env.send(t, "ctx-b", "맥북 사줘")
+
+	w := adminCall(env.r, http.MethodPost, "/admin/run/stop", nil)
+	var stopped struct {
+		Run demoRun `json:"run"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&stopped)
+	if s := stopped.Run; s.ID != run.ID || s.Conversations != 2 || s.Errors != 1 || s.Tamper != 0 {
+		t.Fatalf("summary %+v", s)
+	}
+
+	env.send(t, "ctx-a", "네") // retried (and failing) outside the run
+	if got, ok := env.charge(t)["run"]; ok {
+		t.Fatalf("run %v forwarded after stop", got)
+	}
+
+	summary := auditLines(t, a, dir, "run.summary")
+	if len(summary) != 1 || !strings.Contains(summary[0], `"run":"`+run.ID+`"`) || !strings.Contains(summary[0], `"conversations":2`) {
+		t.Fatalf("summary events %v", summary)
+	}
+	errs := auditLines(t, a, dir, "upstream.error")
+	if len(errs) != 2 || !strings.Contains(errs[0], `"run":"`+run.ID+`"`) || strings.Contains(errs[1], `"run":`) {
+		t.Fatalf("upstream.error events %v", errs)
+	}
+}
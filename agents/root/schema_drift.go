@@ -0,0 +1,284 @@
+// Package root - upstream response schema drift.
+//
+// Each external agent's successful responses are checked against the
+// expected metadata shape for its domain: required keys and their JSON types
+// (payment receipts, medical triage context, planning). The schemas are
+// versioned and live next to the routing config in configs/response_schemas.json
+// (ROOT_RESPONSE_SCHEMAS overrides the path; the built-in copy below is used
+// when the file is missing). A mismatch never rejects the response: it is
+// logged, counted per target (/status "schemaDrift", /metrics), and listed in
+// the response's metadata.schemaWarnings. After ROOT_SCHEMA_ALERT_AFTER
+// (default 3) consecutive violating responses from one target an alert audit
+// event is emitted; a clean response resets the streak.
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// responseSchema is the expected shape of one target's responses.
+type responseSchema struct {
+	Types   []string          `json:"types,omitempty"`  // message types checked (default: response)
+	SkipIf  []string          `json:"skipIf,omitempty"` // metadata keys that exempt a response (e.g. dryRun)
+	Require map[string]string `json:"require"`          // path -> string|number|bool|object|array|any
+}
+
+type schemaSet struct {
+	Version string                    `json:"version"`
+	Targets map[string]responseSchema `json:"targets"`
+	Source  string                    `json:"-"`
+}
+
+// builtinSchemas mirrors configs/response_schemas.json.
+var builtinSchemas = schemaSet{
+	Version: "2026-10-15.2",
+	Targets: map[string]responseSchema{
+		"payment": {
+			Types:  []string{"response"},
+			SkipIf: []string{"dryRun", "authorization"}, // holds carry no receipt yet
+			Require: map[string]string{
+				"receipt":             "object",
+				"receipt.orderId":     "string",
+				"receipt.amountKRW":   "number",
+				"receipt.to":          "string",
+				"receipt.method":      "string",
+				"receipt.generatedAt": "string",
+			},
+		},
+		"medical": {
+			Types: []string{"response"},
+			Require: map[string]string{
+				"agent":               "string",
+				"context":             "object",
+				"context.topic":       "string",
+				"context.severity":    "string",
+				"context.history_len": "number",
+			},
+		},
+		"planning": {
+			Types:   []string{"response"},
+			Require: map[string]string{"agent_type": "string"},
+		},
+	},
+	Source: "builtin",
+}
+
+func loadResponseSchemas(logger *log.Logger) schemaSet {
+	path := firstNonEmpty(strings.TrimSpace(os.Getenv("ROOT_RESPONSE_SCHEMAS")), "configs/response_schemas.json")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return builtinSchemas
+	}
+	var set schemaSet
+	if err := json.Unmarshal(b, &set); err != nil || len(set.Targets) == 0 {
+		logger.Printf("[root][schema] ignoring %s (using built-in schemas): %v", path, err)
+		return builtinSchemas
+	}
+	set.Source = path
+	logger.Printf("[root][schema] loaded %s version=%s targets=%d", path, set.Version, len(set.Targets))
+	return set
+}
+
+// metaPath looks path up in m: a flat key ("payment.dryRun") wins over a
+// nested walk ("receipt" -> "orderId").
+func metaPath(m map[string]any, path string) (any, bool) {
+	if v, ok := m[path]; ok {
+		return v, true
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil, false
+	}
+	sub, ok := m[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return metaPath(sub, rest)
+}
+
+func jsonTypeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64, float32, int, int32, int64, uint, uint32, uint64, json.Number:
+		return "number"
+	case map[string]any:
+		return "object"
+	case []any, []string, []map[string]any:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// validate returns one warning per violated requirement, sorted by path;
+// applies is false when the response is exempt (other type, skipIf key set).
+func (s responseSchema) validate(out *types.AgentMessage) (warns []string, applies bool) {
+	typ := strings.ToLower(strings.TrimSpace(out.Type))
+	want := s.Types
+	if len(want) == 0 {
+		want = []string{"response"}
+	}
+	matched := false
+	for _, t := range want {
+		if strings.EqualFold(t, typ) {
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, false
+	}
+	for _, k := range s.SkipIf {
+		if v, ok := metaPath(out.Metadata, k); ok && v != nil && v != false && v != "" {
+			return nil, false
+		}
+	}
+	for path, kind := range s.Require {
+		v, ok := metaPath(out.Metadata, path)
+		switch {
+		case !ok:
+			warns = append(warns, path+": missing")
+		case kind == "any":
+		case jsonTypeOf(v) != kind:
+			warns = append(warns, fmt.Sprintf("%s: want %s, got %s", path, kind, jsonTypeOf(v)))
+		}
+	}
+	sort.Strings(warns)
+	return warns, true
+}
+
+type driftCounter struct {
+	Checked      int64     `json:"checked"`
+	Violations   int64     `json:"violations"`
+	Consecutive  int       `json:"consecutive"`
+	Alerts       int64     `json:"alerts"`
+	LastWarnings []string  `json:"lastWarnings,omitempty"`
+	LastAt       time.Time `json:"lastViolationAt,omitempty"`
+}
+
+// schemaDrift holds the loaded schemas and per-target counters.
+type schemaDrift struct {
+	set        schemaSet
+	alertAfter int
+
+	mu sync.Mutex
+	by map[string]*driftCounter
+}
+
+func newSchemaDrift(logger *log.Logger) *schemaDrift {
+	n := envInt("ROOT_SCHEMA_ALERT_AFTER", 3)
+	if n < 1 {
+		n = 1
+	}
+	return &schemaDrift{set: loadResponseSchemas(logger), alertAfter: n, by: map[string]*driftCounter{}}
+}
+
+// checkResponseSchema validates a successful upstream response for agent and
+// annotates it; it never changes the outcome.
+func (r *RootAgent) checkResponseSchema(agent string, out *types.AgentMessage) {
+	sd := r.drift
+	if sd == nil {
+		return
+	}
+	s, ok := sd.set.Targets[agent]
+	if !ok {
+		return
+	}
+	warns, applies := s.validate(out)
+	if !applies {
+		return
+	}
+
+	sd.mu.Lock()
+	c := sd.by[agent]
+	if c == nil {
+		c = &driftCounter{}
+		sd.by[agent] = c
+	}
+	c.Checked++
+	if len(warns) == 0 {
+		c.Consecutive = 0
+		sd.mu.Unlock()
+		return
+	}
+	c.Violations++
+	c.Consecutive++
+	c.LastWarnings, c.LastAt = warns, time.Now()
+	streak := c.Consecutive
+	alert := streak == sd.alertAfter
+	if alert {
+		c.Alerts++
+	}
+	sd.mu.Unlock()
+
+	r.logger.Printf("[root][schema] target=%s cid=%s version=%s violations=%v", agent, out.ContextID, sd.set.Version, warns)
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	out.Metadata["schemaWarnings"] = warns
+	if alert {
+		r.logger.Printf("[root][alert][schema] ⚠️ target=%s returned %d consecutive responses off schema %s: %v", agent, streak, sd.set.Version, warns)
+		r.audit.Emit(audit.Event{
+			Type: "schema", Action: "upstream.schema_drift", Outcome: "alert", Target: agent, CID: out.ContextID,
+			Detail: map[string]any{"version": sd.set.Version, "consecutive": streak, "warnings": warns},
+		})
+	}
+}
+
+// snapshot is the /status view.
+func (sd *schemaDrift) snapshot() map[string]any {
+	if sd == nil {
+		return nil
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	targets := make(map[string]driftCounter, len(sd.by))
+	for k, c := range sd.by {
+		targets[k] = *c
+	}
+	return map[string]any{
+		"version":    sd.set.Version,
+		"source":     sd.set.Source,
+		"alertAfter": sd.alertAfter,
+		"targets":    targets,
+	}
+}
+
+// writePrometheus appends the per-target schema counters to a /metrics body.
+func (sd *schemaDrift) writePrometheus(w io.Writer) {
+	if sd == nil {
+		return
+	}
+	sd.mu.Lock()
+	names := make([]string, 0, len(sd.by))
+	for k := range sd.by {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("# HELP sage_upstream_schema_checks_total Upstream responses checked against their schema.\n# TYPE sage_upstream_schema_checks_total counter\n")
+	for _, k := range names {
+		b.WriteString(`sage_upstream_schema_checks_total{target="` + k + `"} ` + strconv.FormatInt(sd.by[k].Checked, 10) + "\n")
+	}
+	b.WriteString("# HELP sage_upstream_schema_violations_total Upstream responses that did not match their schema.\n# TYPE sage_upstream_schema_violations_total counter\n")
+	for _, k := range names {
+		b.WriteString(`sage_upstream_schema_violations_total{target="` + k + `"} ` + strconv.FormatInt(sd.by[k].Violations, 10) + "\n")
+	}
+	sd.mu.Unlock()
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,113 @@
+package root
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestResponseSchemaValidate(t *testing.T) {
+	s := builtinSchemas.Targets["payment"]
+	good := map[string]any{"receipt": map[string]any{"orderId": "O-1", "amountKRW": float64(1000), "to": "a", "method": "card", "generatedAt": "now"}}
+	cases := []struct {
+		name    string
+		out     types.AgentMessage
+		warns   []string
+		applies bool
+	}{
+		{"matches", types.AgentMessage{Type: "response", Metadata: good}, nil, true},
+		{"no receipt", types.AgentMessage{Type: "response", Metadata: map[string]any{"orderId": "O-1"}}, []string{
+			"receipt.amountKRW: missing", "receipt.generatedAt: missing", "receipt.method: missing",
+			"receipt.orderId: missing", "receipt.to: missing", "receipt: missing",
+		}, true},
+		{"wrong type", types.AgentMessage{Type: "response", Metadata: map[string]any{"receipt": map[string]any{
+			"orderId": float64(1), "amountKRW": "1000", "to": "a", "method": "card", "generatedAt": "now",
+		}}}, []string{"receipt.amountKRW: want number, got string", "receipt.orderId: want string, got number"}, true},
+		{"dry run exempt", types.AgentMessage{Type: "response", Metadata: map[string]any{"dryRun": true}}, nil, false},
+		{"clarify not checked", types.AgentMessage{Type: "clarify"}, nil, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			warns, applies := s.validate(&tc.out)
+			if applies != tc.applies || !slices.Equal(warns, tc.warns) {
+				t.Fatalf("warns %q applies %v", warns, applies)
+			}
+		})
+	}
+}
+
+// An off-schema payment response still reaches the user, annotated; the third
+// in a row raises one alert, and a clean response resets the streak.
+func TestSchemaDriftNeverBlocks(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ROOT_SCHEMA_ALERT_AFTER", "3")
+	env := newForkEnv(t, 0)
+	env.r.drift = newSchemaDrift(log.New(io.Discard, "", 0))
+	env.r.audit = audit.New("root", dir, log.New(io.Discard, "", 0))
+
+	for i, cid := range []string{"ctx-drift-1", "ctx-drift-2", "ctx-drift-3", "ctx-drift-4"} {
+		env.send(t, cid, "맥북 사줘")
+		env.send(t, cid, "카드로, 애플스토어, 서울 강남구, 300만원")
+		out := env.send(t, cid, "네")
+		env.charge(t)
+		warns, _ := out.Metadata["schemaWarnings"].([]any)
+		if out.Type != "response" || !strings.Contains(out.Content, "결제 완료") || !slices.Contains(warns, any("receipt: missing")) {
+			t.Fatalf("payment %d: %+v", i+1, out)
+		}
+	}
+	c := *env.r.drift.by["payment"]
+	if c.Checked != 4 || c.Violations != 4 || c.Consecutive != 4 || c.Alerts != 1 {
+		t.Fatalf("counter %+v", c)
+	}
+
+	env.r.checkResponseSchema("payment", &types.AgentMessage{Type: "response", Metadata: map[string]any{"receipt": map[string]any{
+		"orderId": "O-1", "amountKRW": float64(1000), "to": "a", "method": "card", "generatedAt": "now",
+	}}})
+	if c := env.r.drift.by["payment"]; c.Consecutive != 0 || c.Checked != 5 {
+		t.Fatalf("clean response did not reset the streak: %+v", c)
+	}
+
+	env.r.audit.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "root-*.jsonl"))
+	var alerts int
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		alerts += strings.Count(string(b), `"upstream.schema_drift"`)
+	}
+	if alerts != 1 {
+		t.Fatalf("%d alert events", alerts)
+	}
+
+	var metrics strings.Builder
+	env.r.drift.writePrometheus(&metrics)
+	if !strings.Contains(metrics.String(), `sage_upstream_schema_violations_total{target="payment"} 4`) {
+		t.Fatalf("metrics:\n%s", metrics.String())
+	}
+}
+
+// A schemas file replaces the built-in copy; a broken one is ignored.
+func TestLoadResponseSchemas(t *testing.T) {
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.json"), filepath.Join(dir, "bad.json")
+	_ = os.WriteFile(good, []byte(`{"version":"v9","targets":{"ordering":{"require":{"orderId":"string"}}}}`), 0o600)
+	_ = os.WriteFile(bad, []byte(`{"version":`), 0o600)
+	logger := log.New(io.Discard, "", 0)
+
+	t.Setenv("ROOT_RESPONSE_SCHEMAS", good)
+	if set := loadResponseSchemas(logger); set.Version != "v9" || set.Source != good || len(set.Targets) != 1 {
+		t.Fatalf("loaded %+v", set)
+	}
+	for _, p := range []string{bad, filepath.Join(dir, "missing.json")} {
+		t.Setenv("ROOT_RESPONSE_SCHEMAS", p)
+		if set := loadResponseSchemas(logger); set.Source != "builtin" {
+			t.Fatalf("%s: %+v", p, set)
+		}
+	}
+}
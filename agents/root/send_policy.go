@@ -0,0 +1,103 @@
+// Package root - retry / circuit-breaker policy for outbound sends.
+//
+// Decisions branch on the transport's typed errors instead of response text:
+//   - ErrConnect: the upstream never saw the request -> safe to retry
+//     (ROOT_SEND_ATTEMPTS, default 2) and counts toward the target's breaker.
+//   - ErrTimeout / 5xx ErrHTTPStatus: counted by the breaker, not retried
+//     (the upstream may have acted on the request).
+//   - 4xx ErrHTTPStatus / ErrBodyRead: returned as-is.
+//
+// The breaker opens after ROOT_CB_FAILURES (default 5) consecutive failures and
+// probes again after ROOT_CB_RESET_MS (default 30000).
+package root
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/alert"
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+	"github.com/sage-x-project/sage-multi-agent/resilience"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+func (r *RootAgent) breakerFor(agent string) *resilience.CircuitBreaker {
+	if v, ok := r.breakers.Load(agent); ok {
+		return v.(*resilience.CircuitBreaker)
+	}
+	cb := resilience.NewCircuitBreaker(envInt("ROOT_CB_FAILURES", 5), time.Duration(envInt("ROOT_CB_RESET_MS", 30000))*time.Millisecond)
+	cb.SetOnStateChange(func(from, to resilience.State) {
+		r.logger.Printf("[root][breaker] target=%s %s -> %s", agent, from, to)
+		if to == resilience.StateOpen && r.alertRequiredTarget(agent) {
+			r.raiseAlert("breaker.open", alert.SeverityCritical, agent, "", "circuit breaker opened for a required target", map[string]any{
+				"from": from.String(), "failures": envInt("ROOT_CB_FAILURES", 5),
+			})
+		}
+	})
+	v, _ := r.breakers.LoadOrStore(agent, cb)
+	return v.(*resilience.CircuitBreaker)
+}
+
+func isConnectErr(err error) bool {
+	var ce prototx.ErrConnect
+	return errors.As(err, &ce)
+}
+
+// breakerFailure reports whether an exchange outcome should count against the breaker.
+func breakerFailure(resp *transport.Response, err error) bool {
+	if err != nil {
+		var te prototx.ErrTimeout
+		return isConnectErr(err) || errors.As(err, &te)
+	}
+	var he prototx.ErrHTTPStatus
+	return resp != nil && errors.As(resp.Error, &he) && he.Code >= 500
+}
+
+// sendTransport runs tx.Send under the target's breaker and connect-retry policy.
+func (r *RootAgent) sendTransport(ctx context.Context, agent string, tx *prototx.A2ATransport, sm *transport.SecureMessage) (*transport.Response, error) {
+	var (
+		resp    *transport.Response
+		sendErr error
+	)
+	cfg := resilience.DefaultRetryConfig()
+	cfg.MaxAttempts = envInt("ROOT_SEND_ATTEMPTS", 2)
+	cfg.RetryIf = isConnectErr
+
+	cbErr := r.breakerFor(agent).Execute(func() error {
+		_ = resilience.RetryWithConfig(ctx, cfg, func() error {
+			resp, sendErr = tx.Send(ctx, sm)
+			if sendErr != nil && isConnectErr(sendErr) {
+				r.logger.Printf("[root][send] connect failed target=%s: %v", agent, sendErr)
+			}
+			return sendErr
+		})
+		if breakerFailure(resp, sendErr) {
+			if sendErr != nil {
+				return sendErr
+			}
+			return resp.Error
+		}
+		return nil
+	})
+	if errors.Is(cbErr, resilience.ErrCircuitOpen) || errors.Is(cbErr, resilience.ErrTooManyRequests) {
+		return nil, prototx.ErrConnect{Err: cbErr}
+	}
+	return resp, sendErr
+}
+
+// transportErrorMeta is the structured error metadata for a failed exchange.
+func transportErrorMeta(resp *transport.Response, err error) map[string]any {
+	if err == nil && resp != nil {
+		err = resp.Error
+	}
+	if err == nil {
+		return nil
+	}
+	m := map[string]any{"kind": prototx.ErrorKind(err)}
+	var he prototx.ErrHTTPStatus
+	if errors.As(err, &he) {
+		m["status"] = he.Code
+	}
+	return m
+}
@@ -0,0 +1,89 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+	"github.com/sage-x-project/sage-multi-agent/resilience"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// countingDoer fails to connect, or answers with status, and counts attempts.
+type countingDoer struct {
+	status int // 0: connection refused
+	calls  int
+}
+
+func (d *countingDoer) Do(_ context.Context, req *http.Request) (*http.Response, error) {
+	d.calls++
+	if d.status == 0 {
+		return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: errors.New("connection refused")}
+	}
+	return &http.Response{StatusCode: d.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"error":"x"}`))}, nil
+}
+
+// Connect failures are retried; 5xx and timeouts are not; 4xx never trips
+// the breaker, 5xx does.
+func TestSendPolicy(t *testing.T) {
+	t.Setenv("ROOT_SEND_ATTEMPTS", "2")
+	t.Setenv("ROOT_CB_FAILURES", "2")
+	cases := []struct {
+		name      string
+		status    int
+		wantCalls int
+		kind      string
+		opens     bool
+	}{
+		{"refused", 0, 2, "connect", true},
+		{"server error", http.StatusBadGateway, 1, "http_status", true},
+		{"client error", http.StatusUnauthorized, 1, "http_status", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := statusRoot(t)
+			d := &countingDoer{status: tc.status}
+			tx := prototx.NewA2ATransport(d, "http://payment", false, false)
+			send := func() (*transport.Response, error) {
+				return r.sendTransport(context.Background(), "payment", tx, &transport.SecureMessage{ID: "m1", Payload: []byte(`{}`), Metadata: map[string]string{}})
+			}
+
+			resp, err := send()
+			if d.calls != tc.wantCalls {
+				t.Fatalf("%d attempts, want %d", d.calls, tc.wantCalls)
+			}
+			if meta := transportErrorMeta(resp, err); meta["kind"] != tc.kind {
+				t.Fatalf("error meta %v (err %v)", meta, err)
+			}
+
+			_, _ = send()
+			before := d.calls
+			_, err = send()
+			open := errors.Is(err, resilience.ErrCircuitOpen)
+			if open != tc.opens || (open && d.calls != before) {
+				t.Fatalf("third send: err=%v, upstream reached=%v", err, d.calls != before)
+			}
+			if open && !isConnectErr(err) {
+				t.Fatalf("open breaker is not a connect error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransportErrorMeta(t *testing.T) {
+	if m := transportErrorMeta(&transport.Response{Success: true}, nil); m != nil {
+		t.Fatalf("success: %v", m)
+	}
+	m := transportErrorMeta(&transport.Response{Error: prototx.ErrHTTPStatus{Code: 503}}, nil)
+	if m["kind"] != "http_status" || m["status"] != 503 {
+		t.Fatalf("status meta %v", m)
+	}
+	if m := transportErrorMeta(nil, prototx.ErrTimeout{Err: context.DeadlineExceeded}); m["kind"] != "timeout" || m["status"] != nil {
+		t.Fatalf("timeout meta %v", m)
+	}
+}
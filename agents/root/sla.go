@@ -0,0 +1,210 @@
+// Package root - per-domain SLA budgets for outbound sends.
+//
+// ROOT_SLA_MS_PAYMENT / ROOT_SLA_MS_MEDICAL / ROOT_SLA_MS_PLANNING set the total
+// budget (milliseconds) for a request routed to that domain, measured from the
+// moment /process received it. Unset or 0 means no budget.
+//
+// A single-domain request over budget fails with a phase-attributed 504. A
+// multi-target request (/compare) answers with what finished in time instead:
+// each result still running is marked pending with a task ID and keeps going
+// in the background; GET /tasks/{id} (admin) serves it once it lands. Tasks
+// are kept for ROOT_SLA_TASK_TTL_MS (default 10 minutes), which also bounds
+// how long a pending result may run.
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const ctxReqStartKey ctxKey = "reqStart"
+
+// slaError reports that a domain's budget ran out, and in which phase.
+type slaError struct {
+	Domain  string
+	Phase   string // "extraction" (before send) | "upstream" (during send)
+	Budget  time.Duration
+	Elapsed time.Duration
+}
+
+func (e *slaError) Error() string {
+	return fmt.Sprintf("sla exceeded: domain=%s phase=%s budget=%dms elapsed=%dms",
+		e.Domain, e.Phase, e.Budget.Milliseconds(), e.Elapsed.Milliseconds())
+}
+
+func slaFor(domain string) time.Duration {
+	key := "ROOT_SLA_MS_" + strings.ToUpper(strings.TrimSpace(domain))
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+func reqStartFrom(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(ctxReqStartKey).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+// sendWithSLA wraps sendExternal with the remaining domain budget. On success it
+// records the domain status/elapsed time in the response metadata.
+func (r *RootAgent) sendWithSLA(ctx context.Context, agent, op string, msg *types.AgentMessage) (*types.AgentMessage, error) {
+	start := reqStartFrom(ctx)
+	budget := slaFor(agent)
+	if budget > 0 {
+		remaining := budget - time.Since(start)
+		if remaining <= 0 {
+			return nil, &slaError{Domain: agent, Phase: "extraction", Budget: budget, Elapsed: time.Since(start)}
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, remaining)
+		defer cancel()
+	}
+
+	note := abandonNoteFrom(ctx)
+	note.begin(abandonUpstream)
+	out, err := r.sendExternal(ctx, agent, op, msg)
+	note.end(ctx, err)
+	elapsed := time.Since(start)
+	if err != nil {
+		if budget > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &slaError{Domain: agent, Phase: "upstream", Budget: budget, Elapsed: elapsed}
+		}
+		return nil, err
+	}
+	if out.Metadata == nil {
+		out.Metadata = map[string]any{}
+	}
+	status := "done"
+	if strings.EqualFold(out.Type, "error") {
+		status = "failed"
+	}
+	out.Metadata["domains"] = []map[string]any{{
+		"domain": agent, "status": status, "elapsedMs": elapsed.Milliseconds(),
+	}}
+	return out, nil
+}
+
+// writeSLAError writes the phase-attributed 504 envelope.
+func writeSLAError(w http.ResponseWriter, se *slaError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":     "sla_exceeded",
+		"domain":    se.Domain,
+		"phase":     se.Phase,
+		"budgetMs":  se.Budget.Milliseconds(),
+		"elapsedMs": se.Elapsed.Milliseconds(),
+		"domains": []map[string]any{{
+			"domain": se.Domain, "status": "failed", "elapsedMs": se.Elapsed.Milliseconds(),
+		}},
+	})
+}
+
+// slaTaskTTL: ROOT_SLA_TASK_TTL_MS (default 10 minutes).
+func slaTaskTTL() time.Duration {
+	return time.Duration(envInt("ROOT_SLA_TASK_TTL_MS", 600000)) * time.Millisecond
+}
+
+// slaTask is a result that missed its domain budget in a multi-target request.
+type slaTask struct {
+	ID         string     `json:"taskId"`
+	Domain     string     `json:"domain"`
+	Leg        string     `json:"leg,omitempty"`
+	Status     string     `json:"status"` // pending | done | failed
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ElapsedMs  int64      `json:"elapsedMs"`
+	Result     any        `json:"result,omitempty"`
+}
+
+// slaTaskStore holds pending and finished tasks until they expire. The zero
+// value is ready to use.
+type slaTaskStore struct {
+	mu sync.Mutex
+	m  map[string]*slaTask
+}
+
+// open registers a pending task for a request that started at start.
+func (s *slaTaskStore) open(domain, leg string, start time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(time.Now())
+	if s.m == nil {
+		s.m = map[string]*slaTask{}
+	}
+	t := &slaTask{ID: "task-" + uuid.NewString(), Domain: domain, Leg: leg, Status: "pending", StartedAt: start.UTC()}
+	s.m[t.ID] = t
+	return t.ID
+}
+
+// finish records the result of task id (no-op once it expired).
+func (s *slaTaskStore) finish(id, status string, result any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.m[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	t.Status, t.Result, t.FinishedAt = status, result, &now
+	t.ElapsedMs = now.Sub(t.StartedAt).Milliseconds()
+}
+
+// get returns a copy of task id.
+func (s *slaTaskStore) get(id string) (slaTask, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(time.Now())
+	t, ok := s.m[id]
+	if !ok {
+		return slaTask{}, false
+	}
+	out := *t
+	if out.FinishedAt == nil {
+		out.ElapsedMs = time.Since(out.StartedAt).Milliseconds()
+	}
+	return out, true
+}
+
+func (s *slaTaskStore) purgeLocked(now time.Time) {
+	ttl := slaTaskTTL()
+	for id, t := range s.m {
+		if now.Sub(t.StartedAt) > ttl {
+			delete(s.m, id)
+		}
+	}
+}
+
+// handleTask serves GET /tasks/{id}.
+func (r *RootAgent) handleTask(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	t, ok := r.slaTasks.get(strings.TrimPrefix(req.URL.Path, "/tasks/"))
+	if !ok {
+		http.Error(w, "unknown or expired task", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,123 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// slaRoot is Root with a planning upstream that answers the plaintext compare
+// leg only after slow.
+func slaRoot(t *testing.T, slow time.Duration, budgetMs string) *RootAgent {
+	t.Helper()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.Metadata["compare"] == "plain" {
+			time.Sleep(slow)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "p1", From: "planning", Type: "response", Content: "plan for " + in.Metadata["compare"].(string)})
+	}))
+	t.Cleanup(up.Close)
+
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("ROOT_ADMIN_TOKEN", "sla-test")
+	t.Setenv("PLANNING_EXTERNAL_URL", up.URL)
+	t.Setenv("PLANNING_DIRECT_URL", "")
+	t.Setenv("ROOT_SLA_MS_PLANNING", budgetMs)
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+	return r
+}
+
+func adminCall(r *RootAgent, method, path string, body any) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("X-Admin-Token", "sla-test")
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	return w
+}
+
+type compareOut struct {
+	Legs    []compareLeg     `json:"legs"`
+	Domains []map[string]any `json:"domains"`
+	Partial bool             `json:"partial"`
+	Diff    map[string]any   `json:"diff"`
+}
+
+func compare(t *testing.T, r *RootAgent) compareOut {
+	t.Helper()
+	w := adminCall(r, http.MethodPost, "/compare", map[string]any{
+		"target": "planning", "message": map[string]any{"id": "m1", "from": "client", "content": "plan a trip"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("compare: %d %s", w.Code, w.Body)
+	}
+	var out compareOut
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	return out
+}
+
+// The slow leg misses the budget: the answer carries the fast one, the slow
+// one is pending and its result is served by its task once it lands.
+func TestComparePartialResult(t *testing.T) {
+	r := slaRoot(t, 300*time.Millisecond, "100")
+	t0 := time.Now()
+	out := compare(t, r)
+	if took := time.Since(t0); took > 250*time.Millisecond {
+		t.Fatalf("waited for the slow leg: %s", took)
+	}
+	fast, slow := out.Legs[0], out.Legs[1]
+	if !out.Partial || out.Diff != nil || fast.Pending || !slow.Pending || slow.TaskID == "" {
+		t.Fatalf("partial answer: %+v", out)
+	}
+	if out.Domains[0]["status"] == "pending" || out.Domains[1]["status"] != "pending" || out.Domains[1]["taskId"] != slow.TaskID {
+		t.Fatalf("domains: %v", out.Domains)
+	}
+
+	var task slaTask
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		w := adminCall(r, http.MethodGet, "/tasks/"+slow.TaskID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("task: %d %s", w.Code, w.Body)
+		}
+		task = slaTask{}
+		_ = json.NewDecoder(w.Body).Decode(&task)
+		if task.Status != "pending" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	res, _ := task.Result.(map[string]any)
+	if task.Status != "done" || task.Domain != "planning" || task.Leg != "plain" || res["content"] != "plan for plain" || task.FinishedAt == nil {
+		t.Fatalf("task: %+v", task)
+	}
+	if w := adminCall(r, http.MethodGet, "/tasks/task-unknown", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown task: %d", w.Code)
+	}
+}
+
+// Without a budget compare waits for both legs, as before.
+func TestCompareNoBudget(t *testing.T) {
+	r := slaRoot(t, 50*time.Millisecond, "")
+	out := compare(t, r)
+	if out.Partial || out.Diff == nil || out.Legs[1].Pending || out.Legs[1].Content != "plan for plain" {
+		t.Fatalf("complete answer: %+v", out)
+	}
+	for _, d := range out.Domains {
+		if d["status"] == "pending" || d["taskId"] != nil {
+			t.Fatalf("domains: %v", out.Domains)
+		}
+	}
+}
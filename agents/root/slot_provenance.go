@@ -0,0 +1,206 @@
+// Package root - per-slot provenance (where each slot value came from).
+//
+// Every slot value records its source and the turn it was captured on.
+// Sources: "user" (explicit JSON body), "llm" (LLM extractor), "rule"
+// (keyword/regex extractor), "metadata" (caller-supplied metadata override),
+// "default:<field>" (copied from another slot) and "carried-from-<domain>"
+// (handoff from another domain). Merges keep the provenance of the winning value.
+package root
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	provUser     = "user"
+	provLLM      = "llm"
+	provRule     = "rule"
+	provMetadata = "metadata"
+	provDefault  = "default:"      // + source field
+	provCarried  = "carried-from-" // + source domain
+)
+
+type slotSource struct {
+	Src  string `json:"src"`
+	Turn int    `json:"turn"`
+	Low  bool   `json:"low,omitempty"` // low confidence (LLM value not found in the user's text)
+}
+
+// slotProv maps a slot field name to its provenance.
+type slotProv map[string]slotSource
+
+func (p slotProv) clone() slotProv {
+	if p == nil {
+		return nil
+	}
+	out := make(slotProv, len(p))
+	for k, v := range p {
+		out[k] = v
+	}
+	return out
+}
+
+// stamp sets the turn on entries captured without one.
+func (p slotProv) stamp(turn int) {
+	for k, v := range p {
+		if v.Turn == 0 {
+			v.Turn = turn
+			p[k] = v
+		}
+	}
+}
+
+// compact renders {"shipping":"default:to@2","item":"llm?@1"} for metadata ("?" = low confidence).
+func (p slotProv) compact() map[string]string {
+	out := make(map[string]string, len(p))
+	for k, v := range p {
+		src := v.Src
+		if v.Low {
+			src += "?"
+		}
+		out[k] = src + "@" + strconv.Itoa(v.Turn)
+	}
+	return out
+}
+
+func markProv(p *slotProv, field string, src slotSource) {
+	if *p == nil {
+		*p = slotProv{}
+	}
+	(*p)[field] = src
+}
+
+// takeProv copies b's provenance for a field that b won in a merge.
+func takeProv(dst *slotProv, b slotProv, field string) {
+	if v, ok := b[field]; ok {
+		markProv(dst, field, v)
+		return
+	}
+	delete(*dst, field)
+}
+
+// ---- payment ----
+
+// payFieldValues lists the non-empty paySlots fields by provenance key.
+func payFieldValues(s paySlots) map[string]string {
+	out := map[string]string{}
+	add := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			out[k] = v
+		}
+	}
+	add("mode", s.Mode)
+	add("to", s.To)
+	add("recipient", s.Recipient)
+	add("method", s.Method)
+	add("item", s.Item)
+	add("model", s.Model)
+	add("merchant", s.Merchant)
+	add("shipping", s.Shipping)
+	add("cardLast4", s.CardLast4)
+	add("note", s.Note)
+	if s.AmountKRW > 0 {
+		out["amountKRW"] = strconv.FormatInt(s.AmountKRW, 10)
+	}
+	if s.BudgetKRW > 0 {
+		out["budgetKRW"] = strconv.FormatInt(s.BudgetKRW, 10)
+	}
+	return out
+}
+
+// tagPaySlots marks every set field that has no provenance yet with src.
+func tagPaySlots(s *paySlots, src string) {
+	for k := range payFieldValues(*s) {
+		if _, ok := s.Prov[k]; !ok {
+			markProv(&s.Prov, k, slotSource{Src: src})
+		}
+	}
+}
+
+// paySlotsFromXO converts an extractor result into paySlots with per-field
+// provenance. LLM-sourced strings that do not appear in text are low confidence.
+func paySlotsFromXO(xo *llmPaymentExtract, text string) paySlots {
+	s := paySlotsFromFields(xo)
+	low := strings.ToLower(text)
+	for k, v := range payFieldValues(s) {
+		src := firstNonEmpty(xo.Prov[k], provLLM)
+		ss := slotSource{Src: src}
+		if src == provLLM && k != "mode" && k != "method" && k != "amountKRW" && k != "budgetKRW" {
+			ss.Low = !strings.Contains(low, strings.ToLower(v))
+		}
+		markProv(&s.Prov, k, ss)
+	}
+	return s
+}
+
+// provNote annotates assumed values in the preview.
+func provNote(lang string, p slotProv, field string) string {
+	v, ok := p[field]
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(v.Src, provDefault) {
+		from := strings.TrimPrefix(v.Src, provDefault)
+		label := map[string]map[string]string{
+			"to":        {"ko": "수령자", "en": "recipient"},
+			"recipient": {"ko": "수령자", "en": "recipient"},
+			"shipping":  {"ko": "배송지", "en": "shipping"},
+		}[from][langOrDefault(lang)]
+		if label == "" {
+			label = from
+		}
+		return map[string]string{
+			"ko": " (" + label + "와 동일 — 맞나요?)",
+			"en": " (same as " + label + " — correct?)",
+		}[langOrDefault(lang)]
+	}
+	if strings.HasPrefix(v.Src, provCarried) {
+		return map[string]string{"ko": " (상담 내용에서 가져옴 — 맞나요?)", "en": " (carried over — correct?)"}[langOrDefault(lang)]
+	}
+	if v.Low {
+		return map[string]string{"ko": " (추정 — 맞나요?)", "en": " (assumed — correct?)"}[langOrDefault(lang)]
+	}
+	return ""
+}
+
+// ---- medical ----
+
+func tagMedCtx(s *medCtx, src string) {
+	set := func(k, v string) {
+		if strings.TrimSpace(v) == "" {
+			return
+		}
+		if _, ok := s.Prov[k]; !ok {
+			markProv(&s.Prov, k, slotSource{Src: src})
+		}
+	}
+	set("condition", s.Slots.Condition)
+	set("topic", s.Slots.Topic)
+	set("audience", s.Slots.Audience)
+	set("duration", s.Slots.Duration)
+	set("age", s.Slots.Age)
+	set("medications", s.Slots.Medications)
+	set("symptoms", firstNonEmpty(s.Symptoms, s.Slots.Symptoms))
+}
+
+// ---- planning ----
+
+// nextPlanTurn advances and returns the conversation's planning turn index (1-based).
+func (r *RootAgent) nextPlanTurn(cid string) int {
+	v, _ := r.planTurns.LoadOrStore(cid, new(atomic.Int64))
+	return int(v.(*atomic.Int64).Add(1))
+}
+
+// planningProv tags the set planning slots with src; planning keeps no slots
+// between turns, so every value is captured on turn.
+func planningProv(s planningSlots, src string, turn int) slotProv {
+	p := slotProv{}
+	for k, v := range map[string]string{"task": s.Task, "timeframe": s.Timeframe, "context": s.Context} {
+		if strings.TrimSpace(v) != "" {
+			p[k] = slotSource{Src: src, Turn: turn}
+		}
+	}
+	return p
+}
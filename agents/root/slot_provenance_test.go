@@ -0,0 +1,53 @@
+package root
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// A value the rules captured on turn 1 and the LLM replaced on turn 2 carries
+// the LLM's provenance and turn; fields the LLM left alone keep theirs.
+func TestProvenanceAcrossTurns(t *testing.T) {
+	slots := rulePaySlots(&types.AgentMessage{Content: "맥북 토스로 결제"}, 1)
+	if got := slots.Prov.compact(); got["method"] != "rule@1" || got["item"] != "rule@1" {
+		t.Fatalf("turn 1: %v", got)
+	}
+
+	xo := &llmPaymentExtract{}
+	xo.Fields.Method = "bank"
+	xo.Fields.To = "김철수"
+	xo.Fields.Shipping = "서울시 강남구"
+	text := "계좌이체로 김철수에게"
+	xs := paySlotsFromXO(xo, text)
+	xs.Prov.stamp(2)
+	slots = mergePaySlots(slots, xs)
+
+	want := map[string]string{
+		"method":   "llm@2",  // overwritten
+		"to":       "llm@2",  // found in the text
+		"shipping": "llm?@2", // not in the text: low confidence
+		"item":     "rule@1", // untouched
+	}
+	got := slots.Prov.compact()
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("%s: %q, want %q (all %v)", k, got[k], w, got)
+		}
+	}
+	if slots.Method != "bank" || provNote("en", slots.Prov, "shipping") == "" || provNote("en", slots.Prov, "method") != "" {
+		t.Fatalf("method %q, notes %q %q", slots.Method, provNote("en", slots.Prov, "shipping"), provNote("en", slots.Prov, "method"))
+	}
+}
+
+// Planning provenance records the conversation's planning turn.
+func TestPlanningProvTurn(t *testing.T) {
+	r := &RootAgent{}
+	if a, b, other := r.nextPlanTurn("c1"), r.nextPlanTurn("c1"), r.nextPlanTurn("c2"); a != 1 || b != 2 || other != 1 {
+		t.Fatalf("turns %d %d %d", a, b, other)
+	}
+	got := planningProv(planningSlots{Task: "출장 일정", Timeframe: "다음 주"}, provLLM, 3).compact()
+	if len(got) != 2 || got["task"] != "llm@3" || got["timeframe"] != "llm@3" {
+		t.Fatalf("provenance %v", got)
+	}
+}
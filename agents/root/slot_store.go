@@ -10,73 +10,147 @@ import (
 	"sync"
 	"time"
 
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 )
 
-var payContextStore struct {
+// payStore holds each conversation's payment context.
+type payStore struct {
 	mu sync.Mutex
 	m  map[string]*payCtx
 }
 
+// set stores c under id; the caller holds s.mu.
+func (s *payStore) set(id string, c *payCtx) {
+	if s.m == nil {
+		s.m = make(map[string]*payCtx)
+	}
+	s.m[id] = c
+}
+
 // Add Stage/Token to payCtx
 type payCtx struct {
 	Slots     paySlots
-	Stage     string // "collect" | "await_confirm"
+	Stage     string // "collect" | "await_confirm" | "await_requote" | "sending" | "await_upstream"
 	Token     string
 	UpdatedAt time.Time
-}
 
-func init() { payContextStore.m = make(map[string]*payCtx) }
+	// Re-quotation (only set when the confirmed amount was a budget estimate)
+	EstimatedKRW int64
+	QuotedKRW    int64
+
+	// Turn counts payment turns in this conversation (slot provenance)
+	Turn int
+
+	// IdemKey is sent as payment.idempotencyKey; kept until the context is
+	// cleared so a retried confirm cannot charge twice (payment_ambiguity.go)
+	IdemKey string
+}
 
 // Keep existing get/put/del while preserving Stage/Token
-func getPayCtx(id string) paySlots {
-	payContextStore.mu.Lock()
-	defer payContextStore.mu.Unlock()
-	if c, ok := payContextStore.m[id]; ok {
+func (r *RootAgent) getPayCtx(id string) paySlots {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if c, ok := r.payContextStore.m[id]; ok {
 		return c.Slots
 	}
 	return paySlots{}
 }
 
-func putPayCtx(id string, s paySlots) {
-	payContextStore.mu.Lock()
-	defer payContextStore.mu.Unlock()
+func (r *RootAgent) putPayCtx(id string, s paySlots) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
 	now := time.Now()
-	if c, ok := payContextStore.m[id]; ok {
+	if c, ok := r.payContextStore.m[id]; ok {
 		c.Slots = s
 		c.UpdatedAt = now
 	} else {
-		payContextStore.m[id] = &payCtx{Slots: s, UpdatedAt: now}
+		r.payContextStore.set(id, &payCtx{Slots: s, UpdatedAt: now})
 	}
 }
 
-func putPayCtxFull(id string, s paySlots, stage, token string) {
-	payContextStore.mu.Lock()
-	defer payContextStore.mu.Unlock()
+func (r *RootAgent) putPayCtxFull(id string, s paySlots, stage, token string) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
 	now := time.Now()
-	if c, ok := payContextStore.m[id]; ok {
+	if c, ok := r.payContextStore.m[id]; ok {
 		c.Slots = s
 		c.Stage = stage
 		c.Token = token
 		c.UpdatedAt = now
 	} else {
-		payContextStore.m[id] = &payCtx{Slots: s, Stage: stage, Token: token, UpdatedAt: now}
+		r.payContextStore.set(id, &payCtx{Slots: s, Stage: stage, Token: token, UpdatedAt: now})
 	}
 }
 
-func getStageToken(id string) (stage, token string) {
-	payContextStore.mu.Lock()
-	defer payContextStore.mu.Unlock()
-	if c, ok := payContextStore.m[id]; ok {
+func (r *RootAgent) getStageToken(id string) (stage, token string) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if c, ok := r.payContextStore.m[id]; ok {
 		return c.Stage, c.Token
 	}
 	return "", ""
 }
 
-func delPayCtx(id string) {
-	payContextStore.mu.Lock()
-	defer payContextStore.mu.Unlock()
-	delete(payContextStore.m, id)
+// claimPayToken atomically moves stage -> "sending" when the token matches.
+// A second confirm racing the first sees "sending" and is rejected (no double charge).
+func (r *RootAgent) claimPayToken(id, stage, token string) bool {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	c, ok := r.payContextStore.m[id]
+	if !ok || c.Stage != stage || c.Token == "" || c.Token != token {
+		return false
+	}
+	c.Stage = "sending"
+	c.UpdatedAt = time.Now()
+	return true
+}
+
+// releasePayToken restores the stage after a failed send so the user can retry.
+func (r *RootAgent) releasePayToken(id, stage string) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if c, ok := r.payContextStore.m[id]; ok && c.Stage == "sending" {
+		c.Stage = stage
+		c.UpdatedAt = time.Now()
+	}
+}
+
+func (r *RootAgent) putPayQuote(id string, estimated, quoted int64) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if c, ok := r.payContextStore.m[id]; ok {
+		c.EstimatedKRW = estimated
+		c.QuotedKRW = quoted
+		c.UpdatedAt = time.Now()
+	}
+}
+
+func (r *RootAgent) getPayQuote(id string) (estimated, quoted int64) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	if c, ok := r.payContextStore.m[id]; ok {
+		return c.EstimatedKRW, c.QuotedKRW
+	}
+	return 0, 0
+}
+
+// nextPayTurn advances and returns the conversation's payment turn index (1-based).
+func (r *RootAgent) nextPayTurn(id string) int {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	c, ok := r.payContextStore.m[id]
+	if !ok {
+		c = &payCtx{UpdatedAt: time.Now()}
+		r.payContextStore.set(id, c)
+	}
+	c.Turn++
+	return c.Turn
+}
+
+func (r *RootAgent) delPayCtx(id string) {
+	r.payContextStore.mu.Lock()
+	defer r.payContextStore.mu.Unlock()
+	delete(r.payContextStore.m, id)
 }
 
 // Whether current payment slots are non-empty
@@ -92,18 +166,18 @@ func payCtxNotEmpty(s paySlots) bool {
 }
 
 // Extract only the stage name (helper for getStageToken which returns (stage, token))
-func getStageName(id string) string {
-	stage, _ := getStageToken(id)
+func (r *RootAgent) getStageName(id string) string {
+	stage, _ := r.getStageToken(id)
 	return stage
 }
 
 // Sticky payment: (1) any slot is partially filled or (2) stage is collect/await_confirm
 // Keep routing to payment unless the user strongly asks for "medical/planning"
-func shouldForcePayment(cid, userText string) bool {
-	s := getPayCtx(cid)
-	stage, _ := getStageToken(cid)
+func (r *RootAgent) shouldForcePayment(cid, userText string) bool {
+	s := r.getPayCtx(cid)
+	stage, _ := r.getStageToken(cid)
 
-	if payCtxNotEmpty(s) || stage == "collect" || stage == "await_confirm" {
+	if payCtxNotEmpty(s) || stage == "collect" || stage == "await_confirm" || stage == "await_requote" || stage == "sending" {
 		low := strings.ToLower(strings.TrimSpace(userText))
         // These two functions likely already exist in the project.
         // If not, simple heuristics are fine.
@@ -121,56 +195,68 @@ type medCtx struct {
 	Await      string   // "", "symptoms", "condition"
 	Transcript []string // 유저 원문 히스토리(턴별 Content)
 	FirstQ     string   // 첫 질문 원문(선택)
+	Prov       slotProv // per-field provenance
+	UpdatedAt  time.Time // last putMedCtx (await expiry)
 }
 
-var medStore sync.Map
-
-func getMedCtx(cid string) medCtx {
-	if v, ok := medStore.Load(cid); ok {
+func (r *RootAgent) getMedCtx(cid string) medCtx {
+	if v, ok := r.medStore.Load(cid); ok {
 		if s, ok2 := v.(medCtx); ok2 {
 			return s
 		}
 	}
 	return medCtx{}
 }
-func putMedCtx(cid string, s medCtx) { medStore.Store(cid, s) }
-func delMedCtx(cid string)           { medStore.Delete(cid) }
+func (r *RootAgent) putMedCtx(cid string, s medCtx) {
+	s.UpdatedAt = awaitNow()
+	r.medStore.Store(cid, s)
+}
+func (r *RootAgent) delMedCtx(cid string) { r.medStore.Delete(cid) }
 
 func mergeMedCtx(a, b medCtx) medCtx {
 	ts := func(s string) string { return strings.TrimSpace(s) }
+	a.Prov = a.Prov.clone()
 
 	// slots
 	if v := ts(b.Slots.Condition); v != "" {
 		a.Slots.Condition = v
+		takeProv(&a.Prov, b.Prov, "condition")
 	}
 	if v := ts(b.Slots.Topic); v != "" {
 		a.Slots.Topic = v
+		takeProv(&a.Prov, b.Prov, "topic")
 	}
 	if v := ts(b.Slots.Audience); v != "" {
 		a.Slots.Audience = v
+		takeProv(&a.Prov, b.Prov, "audience")
 	}
 	if v := ts(b.Slots.Duration); v != "" {
 		a.Slots.Duration = v
+		takeProv(&a.Prov, b.Prov, "duration")
 	}
 	if v := ts(b.Slots.Age); v != "" {
 		a.Slots.Age = v
+		takeProv(&a.Prov, b.Prov, "age")
 	}
 	if v := ts(b.Slots.Medications); v != "" {
 		a.Slots.Medications = v
+		takeProv(&a.Prov, b.Prov, "medications")
 	}
 
 	if v := ts(b.Slots.Symptoms); v != "" {
 		a.Symptoms = v
+		takeProv(&a.Prov, b.Prov, "symptoms")
 	}
 	if v := ts(b.Symptoms); v != "" {
 		a.Symptoms = v
+		takeProv(&a.Prov, b.Prov, "symptoms")
 	}
 
     // transcript/await/firstQ are managed by the caller
 	return a
 }
-func hasMedCtx(cid string) bool {
-	_, ok := medStore.Load(cid)
+func (r *RootAgent) hasMedCtx(cid string) bool {
+	_, ok := r.medStore.Load(cid)
 	return ok
 }
 
@@ -189,6 +275,7 @@ func extractMedicalCore(msg *types.AgentMessage) medCtx {
 		} else if v, ok := msg.Metadata["symptoms"].(string); ok && strings.TrimSpace(v) != "" {
 			s.Symptoms = strings.TrimSpace(v)
 		}
+		tagMedCtx(&s, provMetadata)
 	}
 
     // 2) JSON body fallback
@@ -208,6 +295,7 @@ func extractMedicalCore(msg *types.AgentMessage) medCtx {
 			if v, ok := m["symptoms"].(string); ok && strings.TrimSpace(v) != "" {
 				s.Symptoms = strings.TrimSpace(v)
 			}
+			tagMedCtx(&s, provUser)
 		}
 	}
 
@@ -227,6 +315,7 @@ func extractMedicalCore(msg *types.AgentMessage) medCtx {
 			s.Slots.Condition = "고지혈증"
 		}
 	}
+	tagMedCtx(&s, provRule)
 	return s
 }
 
@@ -256,7 +345,7 @@ func (r *RootAgent) askForSymptomsLLM(ctx context.Context, lang, condition, user
 		}[langOrDefault(lang)]
 		usr := fmt.Sprintf("Condition=%s\nUserText=%s\nOutput: ONE-sentence ask in %s",
 			strings.TrimSpace(condition), strings.TrimSpace(userText), langOrDefault(lang))
-		if out, err := r.llmClient.Chat(ctx, sys, usr); err == nil && strings.TrimSpace(out) != "" {
+		if out, err := r.chatUser(ctx, lang, sys, usr); err == nil && strings.TrimSpace(out) != "" {
 			return strings.TrimSpace(out)
 		} else {
 			log.Println("[llm][err]", err)
@@ -290,7 +379,7 @@ func (r *RootAgent) askForCondAndSymptomsLLM(ctx context.Context, lang, userText
 		"en": "You collect medical info. Ask for 'condition + personal symptoms' together in ONE sentence. No examples/lists/code.",
 	}[langOrDefault(lang)]
 	usr := fmt.Sprintf("UserText=%s\nOutput: ONE-sentence ask in %s", compact(userText, 160), langOrDefault(lang))
-	out, err := r.llmClient.Chat(ctx, sys, usr)
+	out, err := r.chatUser(ctx, lang, sys, usr)
 	if err != nil || strings.TrimSpace(out) == "" {
 		if langOrDefault(lang) == "ko" {
 			return "상담할 질병명과 개인 증상을 한 번에 알려주세요."
@@ -308,6 +397,10 @@ func (r *RootAgent) askForCondAndSymptomsLLM(ctx context.Context, lang, userText
 var amountRe = regexp.MustCompile(`(?i)(\d[\d,\.]*)\s*(원|krw|만원|usd|usdc|eth|btc)`)
 
 func isPaymentActionIntent(c string) bool {
+	// "주문하지 마" / "don't buy it": leave the turn to the LLM router or chat
+	if hasNegatedPaymentIntent(c) {
+		return false
+	}
     // If phrased as a question, hold off routing (allow if strong imperative)
 	if isQuestionLike(c) && !isOrderish(c) && !containsAny(c, "보내", "송금", "이체", "지불해", "pay", "send", "transfer") {
 		return false
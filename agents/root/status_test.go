@@ -0,0 +1,68 @@
+package root
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func statusRoot(t *testing.T) *RootAgent {
+	t.Helper()
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("AUDIT_DIR", "")
+	r := NewRootAgent("root", 0)
+	r.logger = log.New(io.Discard, "", 0)
+	return r
+}
+
+func revalidate(r *RootAgent, path, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	return w
+}
+
+// An unchanged source answers 304; live counters are not part of it.
+func TestStatusNotModified(t *testing.T) {
+	r := statusRoot(t)
+	for _, path := range []string{"/status", "/sage/status", "/hpke/status?target=payment"} {
+		first := revalidate(r, path, "")
+		tag := first.Header().Get("ETag")
+		if first.Code != http.StatusOK || tag == "" {
+			t.Fatalf("%s: %d %v", path, first.Code, first.Header())
+		}
+		if w := revalidate(r, path, tag); w.Code != http.StatusNotModified {
+			t.Fatalf("%s unchanged: %d", path, w.Code)
+		}
+	}
+	w := revalidate(r, "/status/live", "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("/status/live: %d %v", w.Code, w.Header())
+	}
+}
+
+// Enabling HPKE changes the tags of the endpoints that show it.
+func TestStatusETagAfterHPKEEnable(t *testing.T) {
+	r := statusRoot(t)
+	tags := map[string]string{}
+	for _, path := range []string{"/sage/status", "/hpke/status?target=payment"} {
+		tags[path] = revalidate(r, path, "").Header().Get("ETag")
+	}
+
+	// what EnableHPKE leaves behind after a handshake
+	r.hpkeStates.Store("payment", &hpkeState{kid: "kid-1"})
+	r.recordHPKEEnable(context.Background(), "payment", "kid-1")
+
+	for path, tag := range tags {
+		w := revalidate(r, path, tag)
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == tag {
+			t.Fatalf("%s after enable: %d %s", path, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
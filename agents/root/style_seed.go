@@ -0,0 +1,57 @@
+// Package root - reproducible phrasing variation for LLM prompts.
+//
+// The confirm prompt asks the LLM to vary its wording using a styleSeed. The
+// seed is derived from the conversation ID and turn (same conversation, same
+// turn -> same seed), or fixed for the whole process with ROOT_STYLE_SEED.
+// PAYMENT_RECEIPT_MODE=template skips the LLM for confirm prompts entirely.
+package root
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ctxStyleSeedKey ctxKey = "styleSeed"
+
+	styleSeedMod = 7919
+)
+
+// styleSeedFor returns ROOT_STYLE_SEED when set, else a hash of cid and turn.
+func styleSeedFor(cid string, turn int) int64 {
+	if v := strings.TrimSpace(os.Getenv("ROOT_STYLE_SEED")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(cid + "#" + strconv.Itoa(turn)))
+	return int64(h.Sum32() % styleSeedMod)
+}
+
+func withStyleSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, ctxStyleSeedKey, seed)
+}
+
+// styleSeedFrom returns the request's seed; without one, the fixed/zero seed.
+func styleSeedFrom(ctx context.Context) int64 {
+	if v, ok := ctx.Value(ctxStyleSeedKey).(int64); ok {
+		return v
+	}
+	return styleSeedFor("", 0)
+}
+
+// confirmTemplateMode reports whether confirm prompts must be template-only.
+func confirmTemplateMode() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("PAYMENT_RECEIPT_MODE")), "template")
+}
+
+func confirmTemplate(lang string) string {
+	if lang == "ko" {
+		return "이대로 진행할까요? (예/아니오)"
+	}
+	return "Proceed with this? (yes/no)"
+}
@@ -0,0 +1,75 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+// promptLLM records the prompt it was given and answers with reply (or err).
+type promptLLM struct {
+	reply        string
+	err          error
+	system, user string
+	calls        int
+}
+
+func (m *promptLLM) Chat(_ context.Context, system, user string) (string, error) {
+	m.calls++
+	m.system, m.user = system, user
+	return m.reply, m.err
+}
+
+func TestStyleSeedFor(t *testing.T) {
+	t.Setenv("ROOT_STYLE_SEED", "")
+	if styleSeedFor("c-1", 2) != styleSeedFor("c-1", 2) {
+		t.Fatal("same conversation and turn, different seeds")
+	}
+	if styleSeedFor("c-1", 2) == styleSeedFor("c-1", 3) {
+		t.Fatal("next turn kept the seed")
+	}
+	if s := styleSeedFor("c-1", 2); s < 0 || s >= styleSeedMod {
+		t.Fatalf("seed %d out of range", s)
+	}
+	t.Setenv("ROOT_STYLE_SEED", "42")
+	if styleSeedFor("c-1", 2) != 42 || styleSeedFrom(context.Background()) != 42 {
+		t.Fatal("ROOT_STYLE_SEED ignored")
+	}
+}
+
+// The confirm prompt the LLM sees, for a fixed seed and one derived from the
+// conversation; template mode and a failing LLM use the fixed question.
+func TestConfirmPromptGolden(t *testing.T) {
+	t.Setenv("PAYMENT_RECEIPT_MODE", "")
+	slots := paySlots{Item: "맥북", Method: "card", Merchant: "쿠팡", Shipping: "서울시 강남구", To: "김철수", BudgetKRW: 2500000}
+	cases := []struct {
+		name, lang, fixed string
+		llm               *promptLLM
+		template          bool
+	}{
+		{"ko_fixed_seed", "ko", "42", &promptLLM{reply: "이대로 결제할까요?"}, false},
+		{"en_fixed_seed", "en", "42", &promptLLM{reply: "Shall I place the order?"}, false},
+		{"en_conversation_seed", "en", "", &promptLLM{reply: "Go ahead with this purchase?"}, false},
+		{"ko_llm_error", "ko", "42", &promptLLM{err: errors.New("upstream 503")}, false},
+		{"en_template_mode", "en", "42", &promptLLM{reply: "unused"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ROOT_STYLE_SEED", tc.fixed)
+			if tc.template {
+				t.Setenv("PAYMENT_RECEIPT_MODE", "template")
+			}
+			r := &RootAgent{logger: log.New(io.Discard, "", 0), llmClient: tc.llm}
+			ctx := withStyleSeed(context.Background(), styleSeedFor("c-golden", 3))
+			got := r.buildConfirmPromptLLM(ctx, tc.lang, slots)
+			checkGoldenIn(t, "confirm", tc.name, map[string]any{
+				"calls":  tc.llm.calls,
+				"system": tc.llm.system,
+				"user":   tc.llm.user,
+				"prompt": got,
+			})
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package root - per-conversation time zone.
+//
+// A /process turn may name the conversation's zone in the X-SAGE-Timezone
+// header or metadata "timezone" (IANA name; the header wins). A valid name is
+// remembered for the conversation, so later turns need not repeat it; without
+// one the conversation keeps its earlier setting, or DEFAULT_TIMEZONE (UTC
+// when unset). An invalid name is logged and answered with
+// X-SAGE-Timezone-Warning, and the turn goes on in the zone it would have had
+// without it. Every /process response carries the effective zone in
+// X-SAGE-Timezone.
+//
+// Root forwards the zone to upstreams as metadata "timezone" (the payment
+// agent renders receipts with it), resolves relative planning dates in it
+// (planning.date) and uses it for reminders (reminders.go) and the local times
+// of GET /conversations/{cid}. Forks inherit it.
+package root
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const ctxTimezoneKey ctxKey = "timezone"
+
+// convTimezone is cid's zone: its setting, else the deployment default.
+func (r *RootAgent) convTimezone(cid string) *time.Location {
+	if v, ok := r.convTimezones.Load(cid); ok {
+		if loc, err := tz.Resolve(v.(string)); err == nil {
+			return loc
+		}
+	}
+	return tz.Default()
+}
+
+// tzFrom is the zone resolved for the current turn (the default outside one).
+func tzFrom(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(ctxTimezoneKey).(*time.Location); ok {
+		return loc
+	}
+	return tz.Default()
+}
+
+// withTimezone resolves the turn's zone, remembers a valid requested one for
+// cid and returns the context carrying it.
+func (r *RootAgent) withTimezone(w http.ResponseWriter, req *http.Request, msg *types.AgentMessage, cid string) context.Context {
+	name, src := strings.TrimSpace(req.Header.Get(tz.Header)), "header"
+	if name == "" {
+		name, src = strFrom(msg.Metadata, tz.MetaKey), "metadata"
+	}
+	if name != "" {
+		if loc, err := tz.Resolve(name); err != nil {
+			fallback := r.convTimezone(cid)
+			r.logger.Printf("[root][tz] ⚠️ cid=%s %s %s: %v; using %s", cid, src, tz.MetaKey, err, fallback)
+			w.Header().Set(tz.WarningHeader, "unknown time zone "+clipHint(name, 64)+"; using "+fallback.String())
+		} else {
+			r.convTimezones.Store(cid, loc.String())
+		}
+	}
+	loc := r.convTimezone(cid)
+	w.Header().Set(tz.Header, loc.String())
+	return context.WithValue(req.Context(), ctxTimezoneKey, loc)
+}
+
+// dateTimeframe resolves a relative planning timeframe ("tomorrow", "내일")
+// to a date in the turn's zone: metadata planning.date and planning.timezone.
+func dateTimeframe(ctx context.Context, msg *types.AgentMessage) {
+	tf := strFrom(msg.Metadata, "planning.timeframe", "timeframe")
+	if tf == "" {
+		return
+	}
+	loc := tzFrom(ctx)
+	if d, ok := tz.RelativeDate(tf, time.Now(), loc); ok {
+		msg.Metadata["planning.date"] = d
+		msg.Metadata["planning.timezone"] = loc.String()
+	}
+}
+
+// datedTimeframe is the timeframe as given to the planning prompt, with the
+// resolved date when there is one: "tomorrow (2026-10-16)".
+func datedTimeframe(m map[string]any) string {
+	tf := strFrom(m, "planning.timeframe", "timeframe")
+	if d := strFrom(m, "planning.date"); d != "" && tf != "" {
+		return tf + " (" + d + ")"
+	}
+	return tf
+}
+
+// todayLine tells the planning prompt what day it is for the user.
+func todayLine(ctx context.Context) string {
+	loc := tzFrom(ctx)
+	return "Today=" + time.Now().In(loc).Format("2006-01-02 (Mon)") + " " + loc.String()
+}
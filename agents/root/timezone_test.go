@@ -0,0 +1,53 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/tz"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func sendInZone(r *RootAgent, cid, zone string) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: "안녕하세요", ContextID: cid})
+	req := httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(b))
+	if zone != "" {
+		req.Header.Set(tz.Header, zone)
+	}
+	w := httptest.NewRecorder()
+	r.mux.ServeHTTP(w, req)
+	return w
+}
+
+// A valid zone sticks to the conversation; an invalid one is warned about and
+// the turn, and a reminder naming it, keep the zone the conversation had.
+func TestTimezoneInvalidFallback(t *testing.T) {
+	t.Setenv(tz.EnvDefault, "Asia/Seoul")
+	env := newForkEnv(t, 0)
+
+	if w := sendInZone(env.r, "c1", "America/New_York"); w.Header().Get(tz.Header) != "America/New_York" || w.Header().Get(tz.WarningHeader) != "" {
+		t.Fatalf("valid zone: %v", w.Header())
+	}
+	w := sendInZone(env.r, "c1", "Mars/Olympus_Mons")
+	if w.Header().Get(tz.Header) != "America/New_York" || !strings.Contains(w.Header().Get(tz.WarningHeader), "using America/New_York") {
+		t.Fatalf("invalid zone: %v", w.Header())
+	}
+	if w := sendInZone(env.r, "c2", "Local"); w.Header().Get(tz.Header) != "Asia/Seoul" || w.Header().Get(tz.WarningHeader) == "" {
+		t.Fatalf("server-local zone: %v", w.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/conversations/c1/reminders",
+		strings.NewReader(`{"text":"call the pharmacy","when":"in 30 minutes","timezone":"Mars/Olympus_Mons"}`))
+	req.Header.Set("X-Admin-Token", "fork-test")
+	w = httptest.NewRecorder()
+	env.r.mux.ServeHTTP(w, req)
+	var v reminderView
+	_ = json.NewDecoder(w.Body).Decode(&v)
+	if w.Code != http.StatusCreated || w.Header().Get(tz.WarningHeader) == "" || v.Due.Timezone != "America/New_York" {
+		t.Fatalf("reminder: %d %v %+v", w.Code, w.Header(), v)
+	}
+}
@@ -0,0 +1,396 @@
+// Package root - guided diagnosis of common misconfigurations.
+//
+// GET /troubleshoot (admin token) runs an ordered battery of checks built
+// from the machinery Root already uses and answers a report
+// (internal/troubleshoot) where every check carries its evidence and, when it
+// did not pass, a hint naming the setting or command that fixes it:
+//
+//  1. signing key   ROOT_JWK_FILE loads and yields a DID (loadSigningKey)
+//  2. registry rpc  ETH_RPC_URL answers eth_chainId
+//  3. own did       the registry resolves Root's DID to the same public key
+//  4. upstream X    each configured URL answers /status as the right agent
+//  5. hpke X        per required target: DID row, KEM public key, ownership
+//     proof or pin, session
+//  6. clock skew    upstream Date headers against Root's clock
+//  7. llm           the LLM endpoint answers
+//  8. boot baseline Root's boot report against BOOT_REPORT_BASELINE (when
+//     set; bootreport.go)
+//
+// HPKE targets are the ones with a live session plus ROOT_HPKE_TARGETS when
+// ROOT_HPKE=true (?hpke=payment,medical overrides). Each check is bounded by
+// ROOT_TROUBLESHOOT_CHECK_MS (default 5000). The verdict is "ready",
+// "degraded" (warnings only) or "not_ready"; the endpoint answers 200 either
+// way. cmd/root --troubleshoot runs the same battery and prints a table.
+package root
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+	"github.com/sage-x-project/sage-multi-agent/internal/troubleshoot"
+	"github.com/sage-x-project/sage-multi-agent/llm"
+
+	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+// Clock skew thresholds. RFC 9421 signatures carry a created timestamp the
+// receiver checks against its own clock.
+const (
+	skewWarn = 5 * time.Second
+	skewFail = 60 * time.Second
+)
+
+// urlSetting names the env variable behind an upstream route.
+func urlSetting(agent string, direct bool) string {
+	if direct {
+		return strings.ToUpper(agent) + "_DIRECT_URL"
+	}
+	if agent == "planning" {
+		return "PLANNING_EXTERNAL_URL"
+	}
+	return strings.ToUpper(agent) + "_URL"
+}
+
+// tsState is what earlier checks hand to later ones.
+type tsState struct {
+	key    sagecrypto.KeyPair
+	did    string
+	rpcOK  bool
+	probes map[string]troubleshoot.Probe // route key -> /status answer
+	at     map[string]time.Time          // route key -> when it arrived
+}
+
+// Troubleshoot runs the diagnosis battery. hpkeTargets nil means the default
+// set (live sessions plus ROOT_HPKE_TARGETS when ROOT_HPKE=true).
+func (r *RootAgent) Troubleshoot(ctx context.Context, hpkeTargets []string) troubleshoot.Report {
+	st := &tsState{probes: map[string]troubleshoot.Probe{}, at: map[string]time.Time{}}
+	checks := []troubleshoot.Check{
+		{Name: "signing key", Run: func(context.Context) troubleshoot.Result { return r.checkSigningKey(st) }},
+		{Name: "registry rpc", Run: func(ctx context.Context) troubleshoot.Result { return checkRPC(ctx, st) }},
+		{Name: "own did", Run: func(ctx context.Context) troubleshoot.Result { return r.checkOwnDID(ctx, st) }},
+	}
+	for _, agent := range r.knownTargets() {
+		base := r.externalURLFor(agent)
+		if base == "" {
+			continue
+		}
+		rt := extRoute{Agent: agent, Key: agent, Base: base}
+		checks = append(checks, troubleshoot.Check{Name: "upstream " + agent, Run: func(ctx context.Context) troubleshoot.Result {
+			return checkUpstream(ctx, st, rt, urlSetting(agent, false))
+		}})
+		if d, ok := r.directRoute(agent); ok {
+			checks = append(checks, troubleshoot.Check{Name: "upstream " + d.Key, Run: func(ctx context.Context) troubleshoot.Result {
+				return checkUpstream(ctx, st, d, urlSetting(agent, true))
+			}})
+		}
+	}
+	if hpkeTargets == nil {
+		hpkeTargets = r.requiredHPKETargets()
+	}
+	for _, t := range hpkeTargets {
+		checks = append(checks, troubleshoot.Check{Name: "hpke " + t, Run: func(context.Context) troubleshoot.Result {
+			return r.checkHPKE(st, t)
+		}})
+	}
+	checks = append(checks,
+		troubleshoot.Check{Name: "clock skew", Run: func(context.Context) troubleshoot.Result { return checkSkew(st) }},
+		troubleshoot.Check{Name: "llm", Run: func(ctx context.Context) troubleshoot.Result { return r.checkLLM(ctx) }},
+	)
+	if base := strings.TrimSpace(os.Getenv("BOOT_REPORT_BASELINE")); base != "" {
+		checks = append(checks, troubleshoot.Check{Name: "boot baseline", Run: func(context.Context) troubleshoot.Result { return r.checkBootBaseline(base) }})
+	}
+	timeout := time.Duration(envInt("ROOT_TROUBLESHOOT_CHECK_MS", 5000)) * time.Millisecond
+	return troubleshoot.Run(ctx, checks, timeout)
+}
+
+// requiredHPKETargets: live sessions plus ROOT_HPKE_TARGETS when ROOT_HPKE=true.
+func (r *RootAgent) requiredHPKETargets() []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(t string) {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if envBool("ROOT_HPKE", false) {
+		for _, t := range strings.Split(envOr("ROOT_HPKE_TARGETS", "payment"), ",") {
+			add(t)
+		}
+	}
+	for _, t := range r.knownTargets() {
+		if r.IsHPKEEnabled(t) {
+			add(t)
+		}
+	}
+	return out
+}
+
+func (r *RootAgent) checkSigningKey(st *tsState) troubleshoot.Result {
+	kp, did, err := loadSigningKey()
+	if err == nil {
+		st.key, st.did = kp, did
+		src := "derived from the key"
+		if strings.TrimSpace(os.Getenv("ROOT_DID")) != "" {
+			src = "ROOT_DID"
+		}
+		return troubleshoot.Result{Status: troubleshoot.OK, Evidence: fmt.Sprintf("ROOT_JWK_FILE=%s, did=%s (%s)", os.Getenv("ROOT_JWK_FILE"), did, src)}
+	}
+	res := troubleshoot.Result{Status: troubleshoot.Fail, Evidence: err.Error(), Hint: signingKeyHint(err)}
+	if !r.sageEnabled {
+		// Only signed traffic needs the key
+		res.Status = troubleshoot.Warn
+		res.Evidence += " (SAGE signing is off)"
+	}
+	return res
+}
+
+func signingKeyHint(err error) string {
+	gen := "go run -tags reg_agents_key ./tools/keygen --agents root"
+	var se *signingKeyError
+	if !errors.As(err, &se) {
+		return "fix ROOT_JWK_FILE"
+	}
+	switch se.Step {
+	case "unset":
+		return "set ROOT_JWK_FILE (or --jwk) to Root's private JWK; generate one with " + gen + " (writes keys/root.jwk)"
+	case "read":
+		if errors.Is(se.Err, os.ErrNotExist) {
+			return fmt.Sprintf("ROOT_JWK_FILE points to %s which does not exist; generate it with %s, or fix the path", se.Path, gen)
+		}
+		return fmt.Sprintf("ROOT_JWK_FILE %s cannot be read (%v); check its permissions", se.Path, se.Err)
+	case "import":
+		return fmt.Sprintf("%s is not a private JWK (a public key or another format?); point ROOT_JWK_FILE at keys/root.jwk from %s", se.Path, gen)
+	}
+	return "the key type gives no DID; set ROOT_DID to Root's registered DID"
+}
+
+func checkRPC(ctx context.Context, st *tsState) troubleshoot.Result {
+	rpc := a2autil.RegistryConfigFromEnv("").RPCEndpoint
+	id, err := troubleshoot.RPCChainID(ctx, http.DefaultClient, rpc)
+	if err != nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("ETH_RPC_URL=%s: %v", rpc, err),
+			Hint:     fmt.Sprintf("no Ethereum JSON-RPC answers at %s; start the local chain (anvil or npx hardhat node) or set ETH_RPC_URL. DIDs and KEM keys cannot be resolved without it", rpc),
+		}
+	}
+	st.rpcOK = true
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: fmt.Sprintf("ETH_RPC_URL=%s chainId=%s", rpc, id)}
+}
+
+func (r *RootAgent) checkOwnDID(ctx context.Context, st *tsState) troubleshoot.Result {
+	if st.key == nil || !st.rpcOK {
+		return troubleshoot.Result{Status: troubleshoot.Skip, Evidence: "needs the signing key and the registry rpc"}
+	}
+	if err := r.ensureResolver(); err != nil {
+		return troubleshoot.Result{Status: troubleshoot.Fail, Evidence: err.Error(), Hint: "check SAGE_REGISTRY_ADDRESS and ETH_RPC_URL"}
+	}
+	cfg := a2autil.RegistryConfigFromEnv("")
+	pub, err := r.resolver.ResolvePublicKey(ctx, sagedid.AgentDID(st.did))
+	if err != nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("%s at registry %s: %v", st.did, cfg.ContractAddress, err),
+			Hint: fmt.Sprintf("%s is not registered at SAGE_REGISTRY_ADDRESS=%s; register Root with sh ./scripts/00_register_agents.sh --agents root (see Quick Start), or check that the address matches the deployed registry",
+				st.did, cfg.ContractAddress),
+		}
+	}
+	if want, ok := st.key.PrivateKey().(*ecdsa.PrivateKey); ok {
+		if got, ok := pub.(*ecdsa.PublicKey); ok && !want.PublicKey.Equal(got) {
+			return troubleshoot.Result{
+				Status:   troubleshoot.Fail,
+				Evidence: fmt.Sprintf("%s resolves to %s, ROOT_JWK_FILE holds %s", st.did, ethcrypto.PubkeyToAddress(*got).Hex(), ethcrypto.PubkeyToAddress(want.PublicKey).Hex()),
+				Hint:     "ROOT_JWK_FILE is not the key registered for this DID; point it at the registered key or re-register (tools/keytool rotate --agent root)",
+			}
+		}
+	}
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: st.did + " resolves to the key in ROOT_JWK_FILE"}
+}
+
+func checkUpstream(ctx context.Context, st *tsState, rt extRoute, setting string) troubleshoot.Result {
+	p, err := troubleshoot.ProbeStatus(ctx, http.DefaultClient, rt.Base)
+	if err == nil && !p.Identity.Is(rt.Agent) {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("%s=%s answers as %s", setting, rt.Base, p.Identity),
+			Hint:     fmt.Sprintf("%s reaches the %s component, not %s; set it to the %s agent's URL", setting, firstNonEmpty(p.Identity.Type, p.Identity.Name), rt.Agent, rt.Agent),
+		}
+	}
+	if err != nil {
+		return troubleshoot.Result{Status: troubleshoot.Fail, Evidence: fmt.Sprintf("%s=%s: %v", setting, rt.Base, err), Hint: upstreamHint(rt, setting, err)}
+	}
+	st.probes[rt.Key] = p
+	st.at[rt.Key] = time.Now()
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: fmt.Sprintf("%s=%s answers as %s in %dms", setting, rt.Base, p.Identity, p.Latency.Milliseconds())}
+}
+
+func upstreamHint(rt extRoute, setting string, err error) string {
+	u, _ := url.Parse(rt.Base)
+	host, path := rt.Base, ""
+	if u != nil {
+		host, path = u.Host, strings.Trim(u.Path, "/")
+	}
+	var ne net.Error
+	var oe *net.OpError
+	switch {
+	case errors.As(err, &oe) && oe.Op == "dial" && !(errors.As(err, &ne) && ne.Timeout()):
+		if path == rt.Agent {
+			return fmt.Sprintf("nothing listens at %s; %s=%s goes through the gateway, which looks down: start it (go run ./cmd/gateway, or ./scripts/06_start_all.sh) or point %s at the %s agent directly",
+				host, setting, rt.Base, setting, rt.Agent)
+		}
+		return fmt.Sprintf("nothing listens at %s; start the %s agent (go run ./cmd/%s) or fix %s", host, rt.Agent, rt.Agent, setting)
+	case errors.As(err, &ne) && ne.Timeout():
+		return fmt.Sprintf("%s did not answer /status in time; check that the process is not stuck and that no firewall drops the traffic", host)
+	case strings.Contains(err.Error(), "HTTP 404"):
+		return fmt.Sprintf("%s/status does not exist; the path prefix is probably wrong (the gateway serves /%s, an agent serves /status at its root)", rt.Base, rt.Agent)
+	}
+	return fmt.Sprintf("%s=%s does not answer /status like a SAGE agent; check the URL", setting, rt.Base)
+}
+
+func (r *RootAgent) checkHPKE(st *tsState, target string) troubleshoot.Result {
+	keys := hpkeKeysPath()
+	dids, err := loadDIDsFromKeys(keys)
+	if err != nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("HPKE keys file %s: %v", keys, err),
+			Hint:     fmt.Sprintf("HPKE needs the merged agent keys; create %s with sh ./scripts/00_register_agents.sh --kem --merge --combined-out %s, or set ROOT_HPKE_KEYS", keys, keys),
+		}
+	}
+	serverDID := strings.TrimSpace(firstNonEmpty(dids[target], dids["external"]))
+	if serverDID == "" {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("%s has no %q (or \"external\") row", keys, target),
+			Hint:     fmt.Sprintf("register %s with KEM keys and merge them: sh ./scripts/00_register_agents.sh --kem --merge --agents %s", target, target),
+		}
+	}
+	row, ok := kemPublicFor(target, serverDID)
+	if !ok || kemFingerprint(row.X25519Public) == "" {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Fail,
+			Evidence: fmt.Sprintf("no X25519 KEM key for %s (%s) in %s", target, serverDID, kemPublicPath()),
+			Hint: fmt.Sprintf("the KEM key is missing; generate and register it with sh ./scripts/00_register_agents.sh --kem --agents %s, then check HPKE_KEM_PUBLIC_FILE (default keys/kem/kem_all_keys.json)",
+				target),
+		}
+	}
+	ev := fmt.Sprintf("did=%s kem=%s", serverDID, kemFingerprint(row.X25519Public))
+	if r.IsHPKEEnabled(target) {
+		ev += " session=" + r.CurrentHPKEKID(target)
+	} else {
+		ev += " session=none"
+	}
+	proofs, _ := kemproof.Load(kemProofsPath())
+	_, proven := kemproof.Find(proofs, serverDID, target)
+	pin, pinned := r.pins.get(target)
+	if !proven && !(pinned && pin.KEMFingerprint != "") {
+		res := troubleshoot.Result{
+			Status:   troubleshoot.Warn,
+			Evidence: ev + " ownership=unverified",
+			Hint: fmt.Sprintf("no KEM ownership proof in %s and no pinned fingerprint for %s: handshakes are refused with HPKE_KEM_STRICT=true; re-run the KEM registration to write the proof",
+				kemProofsPath(), target),
+		}
+		if hpkeKEMStrict() {
+			res.Status = troubleshoot.Fail
+		}
+		return res
+	}
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: ev}
+}
+
+func checkSkew(st *tsState) troubleshoot.Result {
+	worst, worstKey, seen := time.Duration(0), "", 0
+	for key, p := range st.probes {
+		d, ok := p.Skew(st.at[key])
+		if !ok {
+			continue
+		}
+		seen++
+		if d.Abs() >= worst.Abs() {
+			worst, worstKey = d, key
+		}
+	}
+	if seen == 0 {
+		return troubleshoot.Result{Status: troubleshoot.Skip, Evidence: "no upstream answered with a Date header"}
+	}
+	dir := "ahead of"
+	if worst < 0 {
+		dir = "behind"
+	}
+	ev := fmt.Sprintf("largest: %s is %s %s Root's clock (%d upstreams compared)", worstKey, worst.Abs().Round(time.Second), dir, seen)
+	hint := fmt.Sprintf("%s's clock is %s %s Root's; signatures carry a created timestamp checked against the receiver's clock, so sync both hosts with NTP",
+		worstKey, worst.Abs().Round(time.Second), dir)
+	switch a := worst.Abs(); {
+	case a >= skewFail:
+		return troubleshoot.Result{Status: troubleshoot.Fail, Evidence: ev, Hint: hint}
+	case a >= skewWarn:
+		return troubleshoot.Result{Status: troubleshoot.Warn, Evidence: ev, Hint: hint}
+	}
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: ev}
+}
+
+func (r *RootAgent) checkLLM(ctx context.Context) troubleshoot.Result {
+	if !envBool("LLM_ENABLED", true) {
+		return troubleshoot.Result{Status: troubleshoot.Skip, Evidence: "LLM_ENABLED=false"}
+	}
+	r.ensureLLM()
+	if r.llmClient == nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Warn,
+			Evidence: "no LLM client (no API key for a remote LLM_BASE_URL)",
+			Hint:     "set LLM_API_KEY, point LLM_BASE_URL at a local server, or set LLM_ENABLED=false; Root falls back to rule-based answers meanwhile",
+		}
+	}
+	wm, ok := r.llmClient.(llm.Warmer)
+	if !ok {
+		return troubleshoot.Result{Status: troubleshoot.Skip, Evidence: "client cannot be pinged"}
+	}
+	base := firstNonEmpty(os.Getenv("LLM_BASE_URL"), os.Getenv("OPENAI_BASE_URL"), os.Getenv("GEMINI_API_URL"), "provider default")
+	if err := wm.Warm(ctx); err != nil {
+		return troubleshoot.Result{
+			Status:   troubleshoot.Warn,
+			Evidence: fmt.Sprintf("LLM_BASE_URL=%s: %v", base, err),
+			Hint:     fmt.Sprintf("the LLM at %s does not answer; start it (e.g. ollama serve) or fix LLM_BASE_URL; Root falls back to rule-based answers meanwhile", base),
+		}
+	}
+	return troubleshoot.Result{Status: troubleshoot.OK, Evidence: "LLM_BASE_URL=" + base + " answers"}
+}
+
+// handleTroubleshoot: GET /troubleshoot[?hpke=payment,medical] (admin token).
+func (r *RootAgent) handleTroubleshoot(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, req) {
+		return
+	}
+	var targets []string
+	if v, ok := req.URL.Query()["hpke"]; ok {
+		targets = []string{}
+		for _, t := range strings.Split(strings.Join(v, ","), ",") {
+			if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+				targets = append(targets, t)
+			}
+		}
+	}
+	rep := r.Troubleshoot(req.Context(), targets)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(rep)
+}
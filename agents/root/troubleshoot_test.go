@@ -0,0 +1,110 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/troubleshoot"
+)
+
+func newTSState() *tsState {
+	return &tsState{probes: map[string]troubleshoot.Probe{}, at: map[string]time.Time{}}
+}
+
+func TestSigningKeyHint(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&signingKeyError{Step: "unset"}, "set ROOT_JWK_FILE"},
+		{&signingKeyError{Step: "read", Path: "keys/root.jwk", Err: os.ErrNotExist}, "keys/root.jwk which does not exist"},
+		{&signingKeyError{Step: "read", Path: "keys/root.jwk", Err: os.ErrPermission}, "check its permissions"},
+		{&signingKeyError{Step: "import", Path: "keys/root.pub"}, "is not a private JWK"},
+		{errors.New("other"), "fix ROOT_JWK_FILE"},
+	}
+	for _, c := range cases {
+		if got := signingKeyHint(c.err); !strings.Contains(got, c.want) {
+			t.Errorf("%v: hint %q lacks %q", c.err, got, c.want)
+		}
+	}
+}
+
+func statusServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"` + name + `","type":"` + name + `"}`))
+	}))
+}
+
+// A port nothing listens on.
+func closedURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return "http://" + addr
+}
+
+func TestCheckUpstreamHints(t *testing.T) {
+	medical := statusServer("medical")
+	defer medical.Close()
+	payment := statusServer("payment")
+	defer payment.Close()
+	down := closedURL(t)
+
+	cases := []struct {
+		name, base string
+		status     string
+		hint       string
+	}{
+		{"right agent", payment.URL, troubleshoot.OK, ""},
+		{"wrong role", medical.URL, troubleshoot.Fail, "reaches the medical component, not payment"},
+		{"gateway down", down + "/payment", troubleshoot.Fail, "goes through the gateway, which looks down"},
+		{"agent down", down, troubleshoot.Fail, "start the payment agent"},
+		{"404 prefix", payment.URL + "/api", troubleshoot.Fail, "path prefix is probably wrong"},
+	}
+	for _, c := range cases {
+		st := newTSState()
+		res := checkUpstream(context.Background(), st, extRoute{Agent: "payment", Key: "payment", Base: c.base}, "PAYMENT_URL")
+		if res.Status != c.status || !strings.Contains(res.Hint, c.hint) || (c.hint == "" && res.Hint != "") {
+			t.Errorf("%s: status=%s hint=%q, want %s with %q", c.name, res.Status, res.Hint, c.status, c.hint)
+		}
+		if _, ok := st.probes["payment"]; ok != (c.status == troubleshoot.OK) {
+			t.Errorf("%s: probe kept=%v", c.name, ok)
+		}
+	}
+}
+
+func TestCheckSkew(t *testing.T) {
+	now := time.Now()
+	at := func(skew time.Duration) *tsState {
+		st := newTSState()
+		st.probes["payment"] = troubleshoot.Probe{Date: now.Add(skew)}
+		st.at["payment"] = now
+		return st
+	}
+	if res := checkSkew(newTSState()); res.Status != troubleshoot.Skip {
+		t.Errorf("no probes: %+v", res)
+	}
+	if res := checkSkew(at(0)); res.Status != troubleshoot.OK {
+		t.Errorf("in sync: %+v", res)
+	}
+	if res := checkSkew(at(-20 * time.Second)); res.Status != troubleshoot.Warn || !strings.Contains(res.Hint, "behind") {
+		t.Errorf("20s behind: %+v", res)
+	}
+	if res := checkSkew(at(5 * time.Minute)); res.Status != troubleshoot.Fail || !strings.Contains(res.Hint, "NTP") {
+		t.Errorf("5m ahead: %+v", res)
+	}
+}
@@ -0,0 +1,132 @@
+// Package root - factual verification headers.
+//
+// X-SAGE-Verified and X-SAGE-Signature-Valid describe what actually happened
+// on the upstream hops of a /process turn, not just the HTTP status:
+//
+//   - not-applicable: no upstream hop was signed (SAGE off, or the turn never
+//     left Root);
+//   - false: a hop went out unsigned next to signed ones, the upstream
+//     rejected a signed request, or a response failed verification (HPKE
+//     open);
+//   - true: every hop was signed and accepted, and every response verified.
+//
+// X-SAGE-Signature-Valid only looks at whether the signed requests were
+// accepted; X-SAGE-Verified additionally needs the responses to verify.
+// Both are derived from the posture trace (posture.go) and set when the
+// response is flushed, so every /process reply carries them, errors included.
+//
+// With ROOT_HEADER_SELFCHECK=true Root re-derives the expected values from
+// the recorded hop events right before emitting and logs an error on any
+// mismatch, including a value a handler set on its own.
+package root
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// Values of the verification headers.
+const (
+	verifiedTrue  = "true"
+	verifiedFalse = "false"
+	verifiedNA    = "not-applicable"
+)
+
+const (
+	hdrVerified       = "X-SAGE-Verified"
+	hdrSignatureValid = "X-SAGE-Signature-Valid"
+)
+
+// verificationHeaders maps the aggregated trace to the two header values.
+func (in postureInputs) verificationHeaders() (verified, sigValid string) {
+	if in.SignedHops == 0 {
+		return verifiedNA, verifiedNA
+	}
+	sigValid = verifiedTrue
+	if in.SignedHops < in.Hops || in.DigestVerified < in.SignedHops {
+		sigValid = verifiedFalse
+	}
+	verified = sigValid
+	if in.Tamper {
+		verified = verifiedFalse
+	}
+	return verified, sigValid
+}
+
+// expectedFromEvents derives the same values hop by hop.
+func expectedFromEvents(hops []postureHop, responseTamper bool) (verified, sigValid string) {
+	signed := 0
+	sigValid = verifiedTrue
+	for _, h := range hops {
+		if h.Signed {
+			signed++
+		}
+		if !h.Signed || !h.Accepted {
+			sigValid = verifiedFalse
+		}
+	}
+	if signed == 0 {
+		return verifiedNA, verifiedNA
+	}
+	verified = sigValid
+	for _, h := range hops {
+		if h.Tamper {
+			verified = verifiedFalse
+		}
+	}
+	if responseTamper {
+		verified = verifiedFalse
+	}
+	return verified, sigValid
+}
+
+// headerCheck is the ROOT_HEADER_SELFCHECK state; nil when disabled.
+type headerCheck struct {
+	logger   *log.Logger
+	checked  atomic.Int64
+	failures atomic.Int64
+}
+
+func newHeaderCheck(logger *log.Logger) *headerCheck {
+	if !envBool("ROOT_HEADER_SELFCHECK", false) {
+		return nil
+	}
+	logger.Printf("[root][selfcheck] verification header self-check enabled")
+	return &headerCheck{logger: logger}
+}
+
+// verify compares the headers about to be emitted (and any value a handler
+// already set in h) with what the recorded events imply.
+func (hc *headerCheck) verify(h http.Header, pt *postureTrace, verified, sigValid string) {
+	if hc == nil {
+		return
+	}
+	hc.checked.Add(1)
+	hops, respTamper := pt.events()
+	wantV, wantS := expectedFromEvents(hops, respTamper)
+	bad := func(header, got, want, source string) {
+		hc.failures.Add(1)
+		hc.logger.Printf("[root][selfcheck][error] %s=%s (%s) but events imply %s: hops=%+v responseTamper=%v",
+			header, got, source, want, hops, respTamper)
+	}
+	if verified != wantV {
+		bad(hdrVerified, verified, wantV, "derived")
+	}
+	if sigValid != wantS {
+		bad(hdrSignatureValid, sigValid, wantS, "derived")
+	}
+	if got := h.Get(hdrVerified); got != "" && got != wantV {
+		bad(hdrVerified, got, wantV, "set by handler")
+	}
+	if got := h.Get(hdrSignatureValid); got != "" && got != wantS {
+		bad(hdrSignatureValid, got, wantS, "set by handler")
+	}
+}
+
+func (hc *headerCheck) snapshot() map[string]any {
+	if hc == nil {
+		return map[string]any{"enabled": false}
+	}
+	return map[string]any{"enabled": true, "checked": hc.checked.Load(), "failures": hc.failures.Load()}
+}
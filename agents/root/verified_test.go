@@ -0,0 +1,87 @@
+package root
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The headers follow the hops, not the HTTP status, and the aggregate and the
+// per-event derivation agree.
+func TestVerificationHeaders(t *testing.T) {
+	ok := postureHop{Target: "payment", Signed: true, Accepted: true}
+	cases := []struct {
+		name             string
+		hops             []postureHop
+		responseTamper   bool
+		verified, sigVal string
+	}{
+		{"no upstream hop", nil, false, verifiedNA, verifiedNA},
+		{"unsigned hops", []postureHop{{Target: "payment", Accepted: true}, {Target: "medical"}}, false, verifiedNA, verifiedNA},
+		{"signed and accepted", []postureHop{ok, ok}, false, verifiedTrue, verifiedTrue},
+		{"unsigned next to signed", []postureHop{ok, {Target: "medical", Accepted: true}}, false, verifiedFalse, verifiedFalse},
+		{"signed request rejected", []postureHop{{Target: "payment", Signed: true}}, false, verifiedFalse, verifiedFalse},
+		{"hop tampered", []postureHop{{Target: "payment", Signed: true, Accepted: true, Tamper: true}}, false, verifiedFalse, verifiedTrue},
+		{"response failed to open", []postureHop{ok}, true, verifiedFalse, verifiedTrue},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pt := postureFrom(withPostureTrace(httptest.NewRequest(http.MethodPost, "/process", nil)))
+			for _, h := range tc.hops {
+				pt.noteUpstream(h)
+			}
+			if tc.responseTamper {
+				pt.noteTamper()
+			}
+			v, s := pt.inputs().verificationHeaders()
+			if v != tc.verified || s != tc.sigVal {
+				t.Fatalf("verified=%s signature-valid=%s, want %s %s", v, s, tc.verified, tc.sigVal)
+			}
+			hops, respTamper := pt.events()
+			if ev, es := expectedFromEvents(hops, respTamper); ev != v || es != s {
+				t.Fatalf("events imply %s %s", ev, es)
+			}
+		})
+	}
+}
+
+// The self-check flags a handler that set a value the events do not support.
+func TestHeaderSelfCheck(t *testing.T) {
+	t.Setenv("ROOT_HEADER_SELFCHECK", "true")
+	var logs bytes.Buffer
+	hc := newHeaderCheck(log.New(&logs, "", 0))
+	pt := postureFrom(withPostureTrace(httptest.NewRequest(http.MethodPost, "/process", nil)))
+	pt.noteUpstream(postureHop{Target: "payment", Signed: true})
+
+	h := http.Header{}
+	hc.verify(h, pt, verifiedFalse, verifiedFalse)
+	h.Set(hdrVerified, verifiedTrue)
+	hc.verify(h, pt, verifiedFalse, verifiedFalse)
+	if snap := hc.snapshot(); snap["checked"] != int64(2) || snap["failures"] != int64(1) {
+		t.Fatalf("snapshot %v", snap)
+	}
+	if !strings.Contains(logs.String(), "X-SAGE-Verified=true (set by handler) but events imply false") {
+		t.Fatalf("log:\n%s", logs.String())
+	}
+
+	t.Setenv("ROOT_HEADER_SELFCHECK", "")
+	if newHeaderCheck(log.New(io.Discard, "", 0)) != nil {
+		t.Fatal("self-check on by default")
+	}
+}
+
+// Every /process reply carries the headers, errors included.
+func TestVerificationHeadersOnProcess(t *testing.T) {
+	env := newForkEnv(t, 0)
+	for _, body := range []string{`{"id":"m1","from":"client","content":"안녕"}`, `{"id":`} {
+		w := httptest.NewRecorder()
+		env.r.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(body)))
+		if w.Header().Get(hdrVerified) != verifiedNA || w.Header().Get(hdrSignatureValid) != verifiedNA {
+			t.Fatalf("%s: %d headers %v", body, w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// cmd/bootreport/main.go
+// Merges the boot reports of a launch into a system summary and compares a
+// launch against a known-good one (internal/bootreport).
+//
+//	go run ./cmd/bootreport merge -o logs/boot/system.json logs/boot/*.json
+//	go run ./cmd/bootreport merge logs/root.log logs/payment.log   # SAGE-BOOT-REPORT lines
+//	go run ./cmd/bootreport diff known-good.json logs/boot/system.json
+//
+// Inputs may be single reports, system summaries or logs holding
+// SAGE-BOOT-REPORT lines. diff prints one line per change ("+" new, "-" gone,
+// "~" changed) and exits 1 when anything differs.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: bootreport merge [-o file] report...\n       bootreport diff [-json] known-good current...")
+	os.Exit(2)
+}
+
+func readAll(paths []string) []bootreport.Report {
+	var out []bootreport.Report
+	for _, p := range paths {
+		reps, err := bootreport.ReadFile(p)
+		if err != nil {
+			log.Fatalf("[bootreport] %v", err)
+		}
+		if len(reps) == 0 {
+			log.Printf("[bootreport] %s: no boot report found", p)
+		}
+		out = append(out, reps...)
+	}
+	return out
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "merge":
+		fs := flag.NewFlagSet("merge", flag.ExitOnError)
+		out := fs.String("o", "", "write the summary to this file (default stdout)")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() == 0 {
+			usage()
+		}
+		sys := bootreport.Merge(readAll(fs.Args()))
+		b, _ := json.MarshalIndent(sys, "", "  ")
+		b = append(b, '\n')
+		if *out == "" {
+			_, _ = os.Stdout.Write(b)
+		} else if err := os.WriteFile(*out, b, 0o644); err != nil {
+			log.Fatalf("[bootreport] %v", err)
+		}
+		for _, w := range sys.Warnings {
+			log.Printf("[bootreport] WARN %s", w)
+		}
+		log.Printf("[bootreport] %d component(s), %d warning(s)", len(sys.Components), len(sys.Warnings))
+	case "diff":
+		fs := flag.NewFlagSet("diff", flag.ExitOnError)
+		asJSON := fs.Bool("json", false, "print the changes as JSON")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() < 2 {
+			usage()
+		}
+		changes := bootreport.Diff(readAll(fs.Args()[:1]), readAll(fs.Args()[1:]))
+		if *asJSON {
+			b, _ := json.MarshalIndent(changes, "", "  ")
+			fmt.Println(string(b))
+		} else {
+			for _, c := range changes {
+				fmt.Println(c)
+			}
+		}
+		if len(changes) > 0 {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
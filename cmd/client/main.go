@@ -9,19 +9,34 @@ import (
 	"strings"
 
 	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
-	"github.com/sage-x-project/sage-multi-agent/api"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/pkg/clientapi"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
 	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
 	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
 	"github.com/sage-x-project/sage/pkg/agent/did"
 )
 
 func main() {
+	// --fixtures: point the key env vars at testdata/fixtures before the env-backed defaults are read
+	if _, err := fixtures.Flag(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 	port := flag.Int("port", 8086, "client api port")
 	rootBase := flag.String("root", "http://localhost:18080", "root base URL")
 
-	clientJWK := flag.String("client-jwk", "", "optional: path to JWK (private) for signing client->root")
-	clientDID := flag.String("client-did", "", "optional: DID to use for client signing")
+	clientJWK := flag.String("client-jwk", os.Getenv("CLIENT_JWK_FILE"), "optional: path to JWK (private) for signing client->root")
+	clientDID := flag.String("client-did", os.Getenv("CLIENT_DID"), "optional: DID to use for client signing")
 	flag.Parse()
+	if err := fixtures.Guard("client", *clientJWK); err != nil {
+		log.Fatal(err)
+	}
+
+	rep := bootreport.New("client").Port("http", *port).Upstream("root", *rootBase)
+	rep.Key("signing", *clientJWK)
+	rep.Mode("signing", "off")
 
 	var a2a *a2aclient.A2AClient
 	if *clientJWK != "" {
@@ -44,13 +59,15 @@ func main() {
 		}
 		a2a = a2aclient.NewA2AClient(did.AgentDID(didStr), kp, http.DefaultClient)
 		log.Printf("[client] A2A signing enabled (DID=%s)", didStr)
+		rep.DID("self", didStr).Mode("signing", "on")
 	}
 
-	apiServer := api.NewClientAPIWithA2A(*rootBase, "", http.DefaultClient, a2a)
+	apiServer := clientapi.NewClientAPIWithA2A(*rootBase, "", http.DefaultClient, a2a)
 
 	mux := http.NewServeMux()
 	// Single public endpoint. Routing is done by Root.
 	mux.HandleFunc("/api/request", apiServer.HandleRequest)
+	mux.HandleFunc("/api/status", apiServer.HandleStatus)
 	mux.HandleFunc("/api/sage/config", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"ok":true}`))
@@ -58,5 +75,6 @@ func main() {
 
 	addr := ":" + strconv.Itoa(*port)
 	log.Printf("[boot] client api on %s -> root=%s", addr, *rootBase)
-	log.Fatal(http.ListenAndServe(addr, mux))
+	_ = rep.Emit(log.Printf)
+	log.Fatal(http.ListenAndServe(addr, reqmetrics.New("client", nil).Wrap(gzipx.Handler(mux))))
 }
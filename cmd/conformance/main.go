@@ -0,0 +1,110 @@
+// cmd/conformance/main.go
+// Conformance suite for SAGE-compatible agents. Points at a candidate agent's
+// base URL and checks the contract Root relies on: /status shape, the plaintext
+// /process AgentMessage round trip, RFC 9421 signature/Content-Digest handling,
+// optional HPKE handshake + data mode, and the error response on bad JSON.
+// With -op name=/path the round trip (and HPKE data mode) is repeated on a
+// non-default operation path, as Root calls it for named operations.
+//
+//	go run ./cmd/conformance -base http://localhost:19083
+//	go run ./cmd/conformance -base http://agent:8080 -jwk keys/root.jwk -did did:sage:ethereum:0x… -server-did did:sage:ethereum:0x… -hpke
+//	go run ./cmd/conformance -base http://localhost:19083 -op refund=/refund
+//	go run ./cmd/conformance -self            # run against an in-process agents/payment
+//
+// The checks live in pkg/conformance (usable from an agent's own tests); this
+// command is its front end. Exit status is 1 when any
+// check fails; skipped checks (missing keys, HPKE not requested) do not fail.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/agents/payment"
+	"github.com/sage-x-project/sage-multi-agent/pkg/conformance"
+)
+
+func getenvStr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func main() {
+	base := flag.String("base", getenvStr("CONFORMANCE_BASE", ""), "candidate agent base URL (POST {base}/process, GET {base}/status)")
+	jwk := flag.String("jwk", getenvStr("CONFORMANCE_JWK_FILE", ""), "optional: client signing JWK (enables RFC 9421 checks)")
+	did := flag.String("did", getenvStr("CONFORMANCE_DID", ""), "client DID for -jwk (must be resolvable by the agent)")
+	serverDID := flag.String("server-did", getenvStr("CONFORMANCE_SERVER_DID", ""), "candidate agent DID (required for -hpke)")
+	wantHPKE := flag.Bool("hpke", false, "run the HPKE handshake and data-mode round trip")
+	self := flag.Bool("self", false, "self-test: run the suite against an in-process agents/payment")
+	kemJWK := flag.String("kem-jwk", getenvStr("PAYMENT_KEM_JWK_FILE", ""), "self-test: payment X25519 KEM JWK (enables its HPKE server)")
+	signJWK := flag.String("sign-jwk", getenvStr("PAYMENT_JWK_FILE", ""), "self-test: payment Ed25519 signing JWK")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	op := flag.String("op", getenvStr("CONFORMANCE_OP", ""), "optional: also check a non-default operation, name=/path (self-test default simulate=/simulate)")
+	flag.Parse()
+
+	if *self {
+		srv, err := startSelf(*jwk != "", *signJWK, *kemJWK)
+		if err != nil {
+			log.Fatalf("[conformance] self-test: start payment agent: %v", err)
+		}
+		defer srv.Close()
+		*base = srv.URL
+		if *op == "" {
+			*op = "simulate=/simulate"
+		}
+	}
+	if strings.TrimSpace(*base) == "" {
+		fmt.Fprintln(os.Stderr, "usage: conformance -base <agent URL> [-jwk key -did did] [-server-did did -hpke] | -self")
+		os.Exit(2)
+	}
+
+	cfg := conformance.Config{Base: *base, JWKFile: *jwk, DID: *did, ServerDID: *serverDID, HPKE: *wantHPKE, Timeout: *timeout}
+	if *op != "" {
+		name, p, ok := strings.Cut(*op, "=")
+		if !ok || !strings.HasPrefix(p, "/") {
+			log.Fatalf("[conformance] -op %q: want name=/path", *op)
+		}
+		cfg.Operation, cfg.OperationPath = name, p
+	}
+	rep, err := conformance.Run(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("[conformance] %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		rep.Print(os.Stdout)
+	}
+	if rep.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// startSelf boots agents/payment in full mode on a loopback listener. Signature
+// enforcement follows the client side: it is only required when a client key is given.
+func startSelf(requireSig bool, signJWK, kemJWK string) (*httptest.Server, error) {
+	if signJWK != "" {
+		_ = os.Setenv("PAYMENT_JWK_FILE", signJWK)
+	}
+	if kemJWK != "" {
+		_ = os.Setenv("PAYMENT_KEM_JWK_FILE", kemJWK)
+	}
+	agent, err := payment.NewPaymentAgentWithMode(payment.ModeFull, requireSig)
+	if err != nil {
+		return nil, err
+	}
+	return httptest.NewServer(agent.Handler()), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/protocol"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// signedAuthority is what an RFC 9421 signer covers as @authority: the Host
+// header when set, else the URL's host. The doer stands in for Root's signing
+// client and records it before sending for real.
+type authorityDoer struct{ signed string }
+
+func (d *authorityDoer) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	d.signed = req.Host
+	if d.signed == "" {
+		d.signed = req.URL.Host
+	}
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
+
+// The authority Root signs must be the Host the upstream verifies against:
+// with preserveHost the gateway forwards it as is; without it only a pinned
+// upstream authority survives the rewrite.
+func TestSignedAuthoritySeenUpstream(t *testing.T) {
+	var seen, forwarded string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, forwarded = r.Host, r.Header.Get("X-Forwarded-Host")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer up.Close()
+	upHost := hostOf(t, up.URL)
+
+	cases := []struct {
+		name      string
+		preserve  bool
+		pin       string
+		wantMatch bool
+	}{
+		{"preserve", true, "", true},
+		{"preserve pinned", true, "payment.internal:19083", true},
+		{"rewrite pinned to upstream", false, upHost, true},
+		{"rewrite unpinned", false, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			seen, forwarded = "", ""
+			gw := httptest.NewServer(proxyKeepPath(up.URL, "", newTamperBook(8), nil, true, tc.preserve))
+			defer gw.Close()
+
+			doer := &authorityDoer{}
+			tx := protocol.NewA2ATransport(doer, gw.URL, false, false).WithAuthority(tc.pin)
+			if _, err := tx.Send(context.Background(), &transport.SecureMessage{
+				ID: "m1", Payload: []byte(`{"id":"m1"}`), Metadata: map[string]string{"ctype": "application/json"},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			want := tc.pin
+			if want == "" {
+				want = hostOf(t, gw.URL)
+			}
+			if doer.signed != want {
+				t.Fatalf("signed %q, want %q", doer.signed, want)
+			}
+			if (seen == doer.signed) != tc.wantMatch {
+				t.Fatalf("signed %q, upstream saw %q", doer.signed, seen)
+			}
+			if tc.preserve && forwarded != doer.signed {
+				t.Fatalf("X-Forwarded-Host %q", forwarded)
+			}
+			if !tc.preserve && seen != upHost {
+				t.Fatalf("rewrite left Host %q", seen)
+			}
+		})
+	}
+}
+
+func hostOf(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
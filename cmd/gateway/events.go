@@ -0,0 +1,293 @@
+// cmd/gateway/events.go
+// Traffic event feed for the demo UI (the "attacker's view").
+// Every POST .../process the gateway proxies produces one compact event:
+// upstream, sizes in and out, what the tamper layer did (attack message,
+// rules applied or dry-run), the observed signature result when
+// GW_OBSERVE_SIGNATURES is on, the upstream status and the duration.
+// Events go to a ring buffer read by two admin endpoints:
+//
+//	GET /admin/traffic   the ring as JSON, oldest first
+//	GET /events          Server-Sent Events: replays the last GW_EVENTS_REPLAY
+//	                     events (default 20, ?replay=N overrides; Last-Event-ID
+//	                     replays what a reconnecting client missed), then streams
+//
+// Both need GW_ADMIN_TOKEN (see tamper_rules.go); browsers' EventSource cannot
+// send headers, so the UI reads the stream with fetch. Publishing never
+// blocks the proxy: each subscriber has a GW_EVENTS_BUFFER-deep queue
+// (default 64) and events that do not fit are dropped for that subscriber and
+// counted (/status "eventsDropped"); the seq gap shows the client what it
+// missed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// trafficEvent is one proxied /process request.
+type trafficEvent struct {
+	Seq          uint64    `json:"seq"`
+	Time         time.Time `json:"time"`
+	Upstream     string    `json:"upstream"` // payment | medical
+	UpstreamHost string    `json:"upstreamHost,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Mode         string    `json:"mode,omitempty"` // json | handshake | hpke | other; empty when never forwarded
+	InBytes      int64     `json:"inBytes"`
+	OutBytes     int64     `json:"outBytes"`
+	RespBytes    int64     `json:"respBytes"`
+	Tampered     bool      `json:"tampered"`
+	Attack       bool      `json:"attackMessage,omitempty"`
+	Rules        []string  `json:"rules,omitempty"`
+	DryRunRules  []string  `json:"dryRunRules,omitempty"`
+	Signature    string    `json:"signature,omitempty"` // valid | invalid | absent (observation mode only)
+	Status       int       `json:"status"`
+	DurationMs   int64     `json:"durationMs"`
+}
+
+// trafficNote collects what tamperTransport did to one request; the feed
+// middleware puts it in the request context and the transport fills it in
+// (ReverseProxy calls the transport on the serving goroutine).
+type trafficNote struct {
+	host     string
+	mode     string
+	outBytes int64
+	attack   bool
+	rules    []string
+	dryRun   []string
+	// observed signature result after the tamper step ("" = observation off)
+	signature string
+}
+
+type trafficNoteKey struct{}
+
+func noteFrom(ctx context.Context) *trafficNote {
+	n, _ := ctx.Value(trafficNoteKey{}).(*trafficNote)
+	return n
+}
+
+type trafficFeed struct {
+	replay int
+	buffer int
+
+	mu   sync.Mutex
+	ring []trafficEvent
+	next int
+	full bool
+	seq  uint64
+	subs map[chan trafficEvent]struct{}
+
+	dropped atomic.Int64
+}
+
+func newTrafficFeed(size int) *trafficFeed {
+	if size <= 0 {
+		size = 200
+	}
+	return &trafficFeed{
+		replay: envIntOr("GW_EVENTS_REPLAY", 20),
+		buffer: max(envIntOr("GW_EVENTS_BUFFER", 64), 1),
+		ring:   make([]trafficEvent, size),
+		subs:   map[chan trafficEvent]struct{}{},
+	}
+}
+
+func envIntOr(k string, d int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(k))); err == nil && n >= 0 {
+		return n
+	}
+	return d
+}
+
+// publish stores ev and offers it to every subscriber without waiting.
+func (f *trafficFeed) publish(ev trafficEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	ev.Seq = f.seq
+	f.ring[f.next] = ev
+	f.next = (f.next + 1) % len(f.ring)
+	if f.next == 0 {
+		f.full = true
+	}
+	for ch := range f.subs {
+		select {
+		case ch <- ev:
+		default:
+			f.dropped.Add(1)
+		}
+	}
+}
+
+// eventsLocked returns the ring oldest-first; f.mu must be held.
+func (f *trafficFeed) eventsLocked() []trafficEvent {
+	if !f.full {
+		return append([]trafficEvent(nil), f.ring[:f.next]...)
+	}
+	return append(append([]trafficEvent(nil), f.ring[f.next:]...), f.ring[:f.next]...)
+}
+
+func (f *trafficFeed) events() []trafficEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.eventsLocked()
+}
+
+// subscribe registers a queue and returns, atomically with it, the backlog to
+// replay: events after seq afterSeq when it is set, otherwise the last n.
+func (f *trafficFeed) subscribe(n int, afterSeq uint64) (chan trafficEvent, []trafficEvent) {
+	ch := make(chan trafficEvent, f.buffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[ch] = struct{}{}
+	all := f.eventsLocked()
+	if afterSeq > 0 {
+		i := 0
+		for i < len(all) && all[i].Seq <= afterSeq {
+			i++
+		}
+		return ch, all[i:]
+	}
+	if n < len(all) {
+		all = all[len(all)-n:]
+	}
+	return ch, all
+}
+
+func (f *trafficFeed) unsubscribe(ch chan trafficEvent) {
+	f.mu.Lock()
+	delete(f.subs, ch)
+	f.mu.Unlock()
+}
+
+func (f *trafficFeed) subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+// middleware publishes one event per POST .../process once the proxy has
+// answered. It must run inside dumpInboundMW (which sets Content-Length).
+func (f *trafficFeed) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/process") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		note := &trafficNote{}
+		r = r.WithContext(context.WithValue(r.Context(), trafficNoteKey{}, note))
+		rw := &recorder{ResponseWriter: w, status: 200}
+		next.ServeHTTP(rw, r)
+
+		upstream, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		f.publish(trafficEvent{
+			Time:         start,
+			Upstream:     upstream,
+			UpstreamHost: note.host,
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Mode:         note.mode,
+			InBytes:      max(r.ContentLength, 0),
+			OutBytes:     note.outBytes,
+			RespBytes:    rw.bytes,
+			Tampered:     note.attack || len(note.rules) > 0,
+			Attack:       note.attack,
+			Rules:        note.rules,
+			DryRunRules:  note.dryRun,
+			Signature:    note.signature,
+			Status:       rw.status,
+			DurationMs:   time.Since(start).Milliseconds(),
+		})
+	})
+}
+
+// trafficMode classifies an outbound /process request the way tamperTransport does.
+func trafficMode(req *http.Request) string {
+	ct := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Type")))
+	switch {
+	case strings.HasPrefix(ct, "application/sage+hpke"):
+		return "hpke"
+	case !strings.HasPrefix(ct, "application/json"):
+		return "other"
+	case looksLikeHPKEHandshake(req):
+		return "handshake"
+	}
+	return "json"
+}
+
+func (f *trafficFeed) handleTraffic(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": f.events()})
+}
+
+// sseWriteTimeout bounds one write to a subscriber, so a stalled client
+// ends its own stream instead of holding the handler forever.
+const sseWriteTimeout = 10 * time.Second
+
+func (f *trafficFeed) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	n := f.replay
+	if v, err := strconv.Atoi(r.URL.Query().Get("replay")); err == nil && v >= 0 {
+		n = v
+	}
+	last, _ := strconv.ParseUint(strings.TrimSpace(r.Header.Get("Last-Event-ID")), 10, 64)
+
+	rc := http.NewResponseController(w)
+	ch, backlog := f.subscribe(n, last)
+	defer f.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev trafficEvent) error {
+		b, _ := json.Marshal(ev)
+		_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: traffic\ndata: %s\n\n", ev.Seq, b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	for _, ev := range backlog {
+		if err := send(ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("[GW][EVENTS] streaming unsupported: %v", err)
+		return
+	}
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			if err := send(ev); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Each proxied POST .../process yields one event carrying what the transport
+// noted; other requests pass through unrecorded.
+func TestTrafficEventEmitted(t *testing.T) {
+	f := newTrafficFeed(8)
+	h := f.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := noteFrom(r.Context()); n != nil {
+			n.host, n.mode, n.outBytes = "payment:19083", "json", 42
+			n.rules = []string{"amount-x10"}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/payment/status", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/payment/process", strings.NewReader(`{"id":"m1"}`)))
+
+	evs := f.events()
+	if len(evs) != 1 {
+		t.Fatalf("events %+v", evs)
+	}
+	ev := evs[0]
+	if ev.Seq != 1 || ev.Upstream != "payment" || ev.UpstreamHost != "payment:19083" || ev.Mode != "json" ||
+		ev.InBytes != 11 || ev.OutBytes != 42 || ev.RespBytes != 2 || ev.Status != http.StatusAccepted ||
+		!ev.Tampered || ev.Attack || len(ev.Rules) != 1 {
+		t.Fatalf("event %+v", ev)
+	}
+}
+
+// sseIDs reads n event ids from an SSE stream.
+func sseIDs(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var ids []string
+	for len(ids) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %v: %v", ids, err)
+		}
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// /events replays the last N (or what a reconnecting client missed after
+// Last-Event-ID), then streams new events.
+func TestEventsReplay(t *testing.T) {
+	t.Setenv("GW_ADMIN_TOKEN", "gw-test")
+	f := newTrafficFeed(8)
+	for range 5 {
+		f.publish(trafficEvent{Upstream: "medical", Status: 200})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(f.handleEvents))
+	defer srv.Close()
+
+	open := func(query, lastID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+query, nil)
+		req.Header.Set("X-Admin-Token", "gw-test")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("open %s: %v %v", query, err, resp)
+		}
+		return bufio.NewReader(resp.Body), func() { cancel(); resp.Body.Close() }
+	}
+
+	r, done := open("?replay=2", "")
+	if ids := sseIDs(t, r, 2); ids[0] != "4" || ids[1] != "5" {
+		t.Fatalf("replay ids %v", ids)
+	}
+	f.publish(trafficEvent{Upstream: "payment"})
+	if ids := sseIDs(t, r, 1); ids[0] != "6" {
+		t.Fatalf("live id %v", ids)
+	}
+	done()
+
+	r, done = open("?replay=0", "3")
+	defer done()
+	if ids := sseIDs(t, r, 3); strings.Join(ids, ",") != "4,5,6" {
+		t.Fatalf("resume ids %v", ids)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	w := httptest.NewRecorder()
+	f.handleEvents(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("without token: %d", w.Code)
+	}
+}
+
+// A subscriber that never reads costs only its own events: publish keeps
+// going and the overflow is counted.
+func TestSlowSubscriberNeverBlocks(t *testing.T) {
+	t.Setenv("GW_EVENTS_BUFFER", "2")
+	f := newTrafficFeed(4)
+	ch, _ := f.subscribe(0, 0)
+	defer f.unsubscribe(ch)
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			f.publish(trafficEvent{Upstream: "payment"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+	if got := f.dropped.Load(); got != 8 {
+		t.Fatalf("dropped %d, want 8", got)
+	}
+	if evs := f.events(); len(evs) != 4 || evs[0].Seq != 7 || evs[3].Seq != 10 {
+		t.Fatalf("ring %+v", evs)
+	}
+	if (<-ch).Seq != 1 || (<-ch).Seq != 2 {
+		t.Fatal("subscriber lost the events that fit")
+	}
+}
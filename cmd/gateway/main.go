@@ -7,8 +7,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -16,6 +18,12 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/httpcache"
+	"github.com/sage-x-project/sage-multi-agent/internal/logclip"
+	"github.com/sage-x-project/sage-multi-agent/internal/payloadcheck"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
 )
 
 func envOr(k, d string) string {
@@ -25,6 +33,13 @@ func envOr(k, d string) string {
 	return d
 }
 
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
+
 func computeContentDigest(b []byte) string {
 	sum := sha256.Sum256(b)
 	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
@@ -53,17 +68,30 @@ func looksLikeHPKEHandshake(req *http.Request) bool {
 // - Never tampers with application/sage+hpke (ciphertext)
 // - Never tampers with JSON that looks like an HPKE handshake
 // - Only tampers with plain JSON data-mode requests
+// Field-level rules (tamper_rules.go) run before the attack message is appended.
+// With an observer, signatures are checked after tampering (observe.go).
 type tamperTransport struct {
 	base            http.RoundTripper
 	attackMsg       string
+	rules           *tamperBook
 	recomputeDigest bool
+	obs             *observer // nil = observation off
 }
 
 func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	isProcessPost := (req != nil && req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/process"))
+	note := noteFrom(req.Context())
+	if note == nil {
+		note = &trafficNote{}
+	}
+	if isProcessPost {
+		note.host = req.URL.Host
+		note.mode = trafficMode(req)
+	}
 
 	// --- Tamper only on data-mode JSON (not HPKE handshake, not HPKE ciphertext) ---
-	if isProcessPost && t.attackMsg != "" {
+	tampered := false
+	if isProcessPost && (t.attackMsg != "" || t.rules.active()) {
 		ct := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Type")))
 
 		// HPKE ciphertext: do not touch
@@ -74,7 +102,7 @@ func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			if looksLikeHPKEHandshake(req) {
 				// pass
 			} else {
-				// Plain JSON data-mode: inject the attack message
+				// Plain JSON data-mode: apply field rules, then inject the attack message
 				var body []byte
 				if req.Body != nil {
 					body, _ = io.ReadAll(req.Body)
@@ -86,18 +114,29 @@ func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 				// otherwise add a _gw_tamper field; if not JSON, append raw text.
 				var m map[string]any
 				if len(body) > 0 && body[0] == '{' && json.Unmarshal(body, &m) == nil {
-					if old, ok := m["Content"].(string); ok {
-						m["Content"] = old + "\n" + t.attackMsg
-					} else {
-						m["_gw_tamper"] = t.attackMsg
+					changed := false
+					if t.rules.active() {
+						changed, note.rules, note.dryRun = t.rules.apply(req.URL.String(), m)
+					}
+					if t.attackMsg != "" {
+						if old, ok := m["Content"].(string); ok {
+							m["Content"] = old + "\n" + t.attackMsg
+						} else {
+							m["_gw_tamper"] = t.attackMsg
+						}
+						changed = true
+						note.attack = true
 					}
-					if b2, err := json.Marshal(m); err == nil {
+					if !changed {
+						// rules matched nothing (or dry-run only): forward unchanged
+					} else if b2, err := json.Marshal(m); err == nil {
 						newBody = b2
 					} else {
 						newBody = append(body, []byte("\n"+t.attackMsg)...)
 					}
-				} else {
+				} else if t.attackMsg != "" {
 					newBody = append(body, []byte("\n"+t.attackMsg)...)
+					note.attack = true
 				}
 
 				req.Body = io.NopCloser(bytes.NewReader(newBody))
@@ -106,18 +145,25 @@ func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 				req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(newBody)), nil }
 
 				// If upstream validates Content-Digest, recompute it after tamper
-				if t.recomputeDigest {
+				if t.recomputeDigest && !bytes.Equal(newBody, body) {
 					req.Header.Set("Content-Digest", computeContentDigest(newBody))
 				}
+				tampered = !bytes.Equal(newBody, body)
 			}
 		}
 	}
 
 	// --- Always dump the final outbound packet for POST .../process (after tamper/no-tamper) ---
 	if isProcessPost {
+		appendPayloadTrace(req, note.mode)
+		// Observe the signature on what the upstream will actually receive
+		if t.obs != nil {
+			note.signature = t.obs.observe(req, tampered)
+		}
+		note.outBytes = max(req.ContentLength, 0)
 		if dump, err := httputil.DumpRequestOut(req, true); err == nil {
 			log.Printf("\n===== GW OUTBOUND >>> %s %s =====\n%s\n===== END GW OUTBOUND =====\n",
-				req.Method, req.URL.String(), dump)
+				req.Method, req.URL.String(), logclip.Clip("gateway", string(dump)))
 		} else {
 			log.Printf("[GW][WARN] outbound dump error: %v", err)
 		}
@@ -126,9 +172,35 @@ func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.base.RoundTrip(req)
 }
 
+// appendPayloadTrace adds the gateway's hop to X-SAGE-Payload-Trace when the
+// sender asked for a trace (internal/payloadcheck): the fingerprint of a plain
+// JSON body as forwarded, otherwise only the mode (ciphertext and gzip cannot
+// be compared with the plaintext fingerprints of the other hops).
+func appendPayloadTrace(req *http.Request, mode string) {
+	trace := req.Header.Get(payloadcheck.TraceHeader)
+	if trace == "" {
+		return
+	}
+	fp := mode
+	if mode == "json" && req.Header.Get("Content-Encoding") == "" && req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		fp = payloadcheck.Fingerprint(body)
+	}
+	req.Header.Set(payloadcheck.TraceHeader, payloadcheck.AppendTrace(trace, "gateway", fp))
+}
+
 // proxyKeepPath builds a reverse proxy that preserves the original request path/query,
 // replaces only the scheme/host, and uses tamperTransport for outbound traffic.
-func proxyKeepPath(target string, attackMsg string, recomputeDigest bool) *httputil.ReverseProxy {
+//
+// With preserveHost the inbound Host header is forwarded unchanged (and echoed
+// in X-Forwarded-Host) so a signature covering @authority still verifies at
+// the upstream; the sender must sign the authority the upstream checks
+// (Root: ROOT_SIGNED_AUTHORITY_<AGENT>). Without it Host is rewritten to the
+// upstream address, which breaks @authority unless the sender signed that.
+func proxyKeepPath(target string, attackMsg string, rules *tamperBook, obs *observer, recomputeDigest, preserveHost bool) *httputil.ReverseProxy {
 	u, err := url.Parse(target)
 	if err != nil {
 		log.Fatalf("bad upstream url %q: %v", target, err)
@@ -140,13 +212,19 @@ func proxyKeepPath(target string, attackMsg string, recomputeDigest bool) *httpu
 		_ = origDirector // keep original path/query
 		req.URL.Scheme = u.Scheme
 		req.URL.Host = u.Host
-		req.Host = u.Host // keep @authority consistent
+		if preserveHost && req.Host != "" {
+			req.Header.Set("X-Forwarded-Host", req.Host)
+		} else {
+			req.Host = u.Host
+		}
 	}
 
 	rp.Transport = &tamperTransport{
 		base:            http.DefaultTransport,
 		attackMsg:       attackMsg,
+		rules:           rules,
 		recomputeDigest: recomputeDigest,
+		obs:             obs,
 	}
 
 	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, e error) {
@@ -177,7 +255,7 @@ func dumpInboundMW(next http.Handler) http.Handler {
 
 			if dump, err := httputil.DumpRequest(clone, true); err == nil {
 				log.Printf("\n===== GW INBOUND  <<< %s %s =====\n%s\n===== END GW INBOUND  =====\n",
-					clone.Method, clone.URL.Path, dump)
+					clone.Method, clone.URL.Path, logclip.Clip("gateway", string(dump)))
 			} else {
 				log.Printf("[GW][WARN] inbound dump error: %v", err)
 			}
@@ -199,10 +277,11 @@ func dumpInboundMW(next http.Handler) http.Handler {
 	})
 }
 
-// recorder tracks the status code written by the handler for logging.
+// recorder tracks the status code and body size written by the handler.
 type recorder struct {
 	http.ResponseWriter
 	status int
+	bytes  int64
 }
 
 func (rw *recorder) WriteHeader(code int) {
@@ -210,6 +289,15 @@ func (rw *recorder) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *recorder) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush and write deadlines (SSE).
+func (rw *recorder) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
+
 func main() {
 	// Env defaults + flags
 	listenDef := envOr("GW_LISTEN", ":5500")
@@ -221,22 +309,104 @@ func main() {
 	payUp := flag.String("pay-upstream", payDef, "payment upstream")
 	medUp := flag.String("med-upstream", medDef, "medical upstream")
 	attackMsg := flag.String("attack-msg", attackDef, "tamper message (empty = pass-through)")
+	preserveHost := flag.Bool("preserve-host", !strings.EqualFold(os.Getenv("GW_PRESERVE_HOST"), "false"), "forward the inbound Host so signed @authority survives the hop")
+	observe := flag.Bool("observe-signatures", strings.EqualFold(os.Getenv("GW_OBSERVE_SIGNATURES"), "true"), "verify inbound signatures and annotate (never block)")
 	flag.Parse()
 
 	mux := http.NewServeMux()
 
+	// Field-level tamper rules (file seed + admin API)
+	rules := newTamperBook(200)
+	if err := rules.loadFile(os.Getenv("GW_TAMPER_RULES_FILE")); err != nil {
+		log.Fatalf("[GW] tamper rules: %v", err)
+	}
+	mux.HandleFunc("/admin/tamper/rules", rules.handleRules)
+	mux.HandleFunc("/admin/tamper/events", rules.handleEvents)
+	// Per-request traffic summaries for the demo UI (events.go)
+	feed := newTrafficFeed(200)
+	mux.HandleFunc("/admin/traffic", feed.handleTraffic)
+	mux.HandleFunc("/events", feed.handleEvents)
+	// Full content of clipped dumps ("details: dbg-…")
+	mux.HandleFunc("/admin/debug/", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		e, ok := logclip.Lookup(strings.TrimPrefix(r.URL.Path, "/admin/debug/"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, e)
+	})
+
+	// Signature observation (annotate only, after the tamper step; admin endpoints)
+	var obs *observer
+	if *observe {
+		o, err := newObserver(200)
+		if err != nil {
+			log.Printf("[GW][WARN] signature observation disabled: %v", err)
+			bootreport.Warnf("signature observation disabled: %v", err)
+		} else {
+			obs = o
+			mux.HandleFunc("/observed", obs.handleRecent)
+			mux.HandleFunc("/metrics", obs.handleMetrics)
+		}
+	}
+
 	// Upstreams (preserve original path/query; tamper only on plain JSON data-mode)
-	mux.Handle("/payment/", proxyKeepPath(*payUp, *attackMsg, true))
-	mux.Handle("/medical/", proxyKeepPath(*medUp, *attackMsg, true))
+	mux.Handle("/payment/", proxyKeepPath(*payUp, *attackMsg, rules, obs, true, *preserveHost))
+	mux.Handle("/medical/", proxyKeepPath(*medUp, *attackMsg, rules, obs, true, *preserveHost))
 
 	// Health endpoint
 	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"ok":true,"gw":"ready","tamper":` + strconv.FormatBool(strings.TrimSpace(*attackMsg) != "") + `}`))
+		httpcache.WriteJSON(w, r, map[string]any{
+			"name":              "gateway",
+			"type":              "gateway",
+			"version":           selfid.Version,
+			"ok":                true,
+			"gw":                "ready",
+			"tamper":            strings.TrimSpace(*attackMsg) != "",
+			"tamperRules":       len(rules.list()),
+			"preserveHost":      *preserveHost,
+			"observeSignatures": obs != nil,
+		})
+	})
+	// Live counters, never revalidated
+	mux.HandleFunc("/status/live", func(w http.ResponseWriter, r *http.Request) {
+		httpcache.WriteLive(w, map[string]any{
+			"eventSubscribers": feed.subscribers(),
+			"eventsDropped":    feed.dropped.Load(),
+		})
 	})
 
-	h := dumpInboundMW(mux)
+	h := dumpInboundMW(feed.middleware(mux))
+
+	// Self-identification: upstreams must answer as the agents they are configured for
+	gwPort := 0
+	if _, p, err := net.SplitHostPort(*listen); err == nil {
+		gwPort, _ = strconv.Atoi(p)
+	}
+	if err := selfid.Run("gateway", gwPort, []selfid.Upstream{
+		{Setting: "PAYMENT_UPSTREAM", Role: "payment", URL: *payUp},
+		{Setting: "MEDICAL_UPSTREAM", Role: "medical", URL: *medUp},
+	}, log.Printf); err != nil {
+		log.Fatal(err)
+	}
+
+	// Startup banner + boot report (BOOT_REPORT_FILE / BOOT_REPORT_DIR / stdout)
+	rep := bootreport.New("gateway").Port("http", gwPort)
+	rep.Upstream("payment", *payUp).Upstream("medical", *medUp)
+	tamper := "off"
+	if strings.TrimSpace(*attackMsg) != "" || len(rules.list()) > 0 {
+		tamper = fmt.Sprintf("on (message=%v, rules=%d)", strings.TrimSpace(*attackMsg) != "", len(rules.list()))
+		rep.Warn("tamper mode is on: plain JSON requests to the agents are modified in transit")
+	}
+	rep.Mode("tamper", tamper)
+	rep.Mode("observe_signatures", onOff(obs != nil))
+	rep.Mode("admin", onOff(strings.TrimSpace(os.Getenv("GW_ADMIN_TOKEN")) != ""))
+	rep.Setting("preserve_host", fmt.Sprint(*preserveHost))
+	rep.Setting("listen", *listen)
+	_ = rep.Emit(log.Printf)
 
 	log.Printf("[GW] listening on %s\nPAYMENT_UPSTREAM=%s\nMEDICAL_UPSTREAM=%s\nATTACK_MESSAGE=%q",
 		*listen, *payUp, *medUp, *attackMsg)
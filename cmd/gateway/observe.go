@@ -0,0 +1,162 @@
+// cmd/gateway/observe.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+)
+
+// Signature observation mode (GW_OBSERVE_SIGNATURES=true).
+// The gateway verifies RFC 9421 signatures + Content-Digest on POST .../process
+// with the same a2autil middleware the agents use, but never blocks: it only
+// annotates the upstream request with X-GW-Observed-Signature and records the
+// result for the demo UI (GET /observed, GET /metrics; both need
+// GW_ADMIN_TOKEN). Verification runs in tamperTransport after the tamper step,
+// on the request exactly as the upstream will receive it, so a tampered
+// request is observed as invalid.
+
+const observedHeader = "X-GW-Observed-Signature"
+
+type observation struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Result string    `json:"result"` // valid | invalid | absent
+	Reason string    `json:"reason,omitempty"`
+	// Tampered: the gateway changed the body before it was observed
+	Tampered bool `json:"tampered,omitempty"`
+}
+
+type observer struct {
+	verify func(http.Handler) http.Handler // the agents' DID middleware (Wrap)
+
+	mu   sync.Mutex
+	ring []observation
+	next int
+	full bool
+
+	valid, invalid, absent atomic.Int64
+}
+
+func newObserver(size int) (*observer, error) {
+	// Non-optional: unsigned/invalid requests fail verification (we only observe the outcome).
+	mw, err := a2autil.BuildDIDMiddleware(false)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = 200
+	}
+	return &observer{verify: mw.Wrap, ring: make([]observation, size)}, nil
+}
+
+// classify runs the verifier against a copy of the request; the original is untouched.
+func (o *observer) classify(r *http.Request, body []byte) (string, string) {
+	if strings.TrimSpace(r.Header.Get("Signature")) == "" && strings.TrimSpace(r.Header.Get("Signature-Input")) == "" {
+		return "absent", ""
+	}
+	if cd := strings.TrimSpace(r.Header.Get("Content-Digest")); cd != "" && cd != a2autil.ComputeContentDigest(body) {
+		return "invalid", "content-digest mismatch"
+	}
+
+	clone := r.Clone(r.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+
+	reached := false
+	probe := o.verify(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { reached = true }))
+	rec := &discardWriter{h: http.Header{}, status: http.StatusOK}
+	probe.ServeHTTP(rec, clone)
+	if reached {
+		return "valid", ""
+	}
+	return "invalid", strings.TrimSpace(rec.buf.String())
+}
+
+func (o *observer) record(ob observation) {
+	switch ob.Result {
+	case "valid":
+		o.valid.Add(1)
+	case "invalid":
+		o.invalid.Add(1)
+	default:
+		o.absent.Add(1)
+	}
+	o.mu.Lock()
+	o.ring[o.next] = ob
+	o.next = (o.next + 1) % len(o.ring)
+	if o.next == 0 {
+		o.full = true
+	}
+	o.mu.Unlock()
+}
+
+// recent returns observations oldest-first.
+func (o *observer) recent() []observation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.full {
+		return append([]observation(nil), o.ring[:o.next]...)
+	}
+	return append(append([]observation(nil), o.ring[o.next:]...), o.ring[:o.next]...)
+}
+
+// observe classifies an outbound POST .../process request after the tamper
+// step, annotates it and records the result. The body is left re-readable.
+func (o *observer) observe(req *http.Request, tampered bool) string {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	result, reason := o.classify(req, body)
+	req.Header.Set(observedHeader, result)
+	o.record(observation{Time: time.Now(), Method: req.Method, Path: req.URL.Path, Result: result, Reason: reason, Tampered: tampered})
+	log.Printf("[GW][OBSERVE] %s %s signature=%s tampered=%v %s", req.Method, req.URL.Path, result, tampered, reason)
+	return result
+}
+
+func (o *observer) handleRecent(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"observed": o.recent()})
+}
+
+func (o *observer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		label string
+		n     int64
+	}{{"valid", o.valid.Load()}, {"invalid", o.invalid.Load()}, {"absent", o.absent.Load()}} {
+		_, _ = io.WriteString(w, "gw_observed_signatures_total{result=\""+m.label+"\"} "+strconv.FormatInt(m.n, 10)+"\n")
+	}
+}
+
+// discardWriter captures the verifier's rejection without touching the real response.
+type discardWriter struct {
+	h      http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (d *discardWriter) Header() http.Header         { return d.h }
+func (d *discardWriter) WriteHeader(code int)        { d.status = code }
+func (d *discardWriter) Write(p []byte) (int, error) { return d.buf.Write(p) }
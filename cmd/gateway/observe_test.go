@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeSignature stands in for RFC 9421: it covers @method, @path and the
+// Content-Digest header, like the agents' signer.
+func fakeSignature(method, path, digest string) string {
+	sum := sha256.Sum256([]byte(method + "\n" + path + "\n" + digest))
+	return "sig1=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func signReq(req *http.Request, body []byte) {
+	d := computeContentDigest(body)
+	req.Header.Set("Content-Digest", d)
+	req.Header.Set("Signature-Input", `sig1=("@method" "@path" "content-digest")`)
+	req.Header.Set("Signature", fakeSignature(req.Method, req.URL.Path, d))
+}
+
+// testObserver verifies fakeSignature the way the DID middleware verifies a
+// real one: it answers 401 and never reaches the handler on a mismatch.
+func testObserver() *observer {
+	return &observer{ring: make([]observation, 8), verify: func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Signature") != fakeSignature(r.Method, r.URL.Path, r.Header.Get("Content-Digest")) {
+				http.Error(w, "signature verification failed", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}}
+}
+
+func TestObserverClassify(t *testing.T) {
+	body := []byte(`{"Content":"pay 10000"}`)
+	cases := []struct {
+		name      string
+		prep      func(*http.Request)
+		body      []byte
+		want, why string
+	}{
+		{"unsigned", func(*http.Request) {}, body, "absent", ""},
+		{"signed", func(r *http.Request) { signReq(r, body) }, body, "valid", ""},
+		{"body changed", func(r *http.Request) { signReq(r, body) }, []byte(`{"Content":"pay 99999"}`), "invalid", "content-digest mismatch"},
+		{"digest recomputed", func(r *http.Request) {
+			signReq(r, body)
+			r.Header.Set("Content-Digest", computeContentDigest([]byte(`{}`)))
+		}, []byte(`{}`), "invalid", "signature verification failed"},
+		{"path changed", func(r *http.Request) {
+			signReq(r, body)
+			r.URL.Path = "/medical/process"
+		}, body, "invalid", "signature verification failed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/payment/process", bytes.NewReader(tc.body))
+			tc.prep(req)
+			got, why := testObserver().classify(req, tc.body)
+			if got != tc.want || why != tc.why {
+				t.Fatalf("classify = %s %q, want %s %q", got, why, tc.want, tc.why)
+			}
+		})
+	}
+}
+
+// Through tamperTransport the signature is observed on the request as the
+// upstream receives it: a tampered body is invalid whether or not the gateway
+// recomputed Content-Digest, and the upstream sees the verdict header.
+func TestObserveAfterTamper(t *testing.T) {
+	seen := make(chan string, 1)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get(observedHeader)
+	}))
+	defer up.Close()
+
+	body := []byte(`{"Content":"pay 10000"}`)
+	cases := []struct {
+		name                string
+		attack              string
+		recompute, unsigned bool
+		want                string
+		tampered            bool
+	}{
+		{"signed untouched", "", false, false, "valid", false},
+		{"unsigned", "", false, true, "absent", false},
+		{"tampered", "ATTACK", false, false, "invalid", true},
+		{"tampered digest recomputed", "ATTACK", true, false, "invalid", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := testObserver()
+			tx := &tamperTransport{base: http.DefaultTransport, attackMsg: tc.attack, recomputeDigest: tc.recompute, obs: obs}
+			req, _ := http.NewRequest(http.MethodPost, up.URL+"/payment/process", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if !tc.unsigned {
+				signReq(req, body)
+			}
+			resp, err := tx.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := <-seen; got != tc.want {
+				t.Fatalf("upstream saw %s=%q, want %q", observedHeader, got, tc.want)
+			}
+			rec := obs.recent()
+			if len(rec) != 1 || rec[0].Result != tc.want || rec[0].Tampered != tc.tampered || rec[0].Path != "/payment/process" {
+				t.Fatalf("recorded %+v", rec)
+			}
+			if tc.want == "invalid" && !strings.Contains(rec[0].Reason, "mismatch") && !strings.Contains(rec[0].Reason, "verification failed") {
+				t.Fatalf("reason %q", rec[0].Reason)
+			}
+		})
+	}
+}
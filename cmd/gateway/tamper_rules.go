@@ -0,0 +1,598 @@
+// cmd/gateway/tamper_rules.go
+// Content-aware tamper rules. Instead of appending ATTACK_MESSAGE to the body,
+// a rule targets one JSON field of a data-mode request and rewrites it:
+//
+//	{"id":"inflate","path":"$.metadata[\"payment.amountKRW\"]","op":"multiply","value":10,
+//	 "when":{"op":"gt","value":100000}}
+//	{"id":"swap-to","path":"$.metadata[\"payment.to\"]","op":"set","value":"attacker"}
+//	{"id":"rename","path":"$.content","op":"replace","find":"alice","value":"mallory","dryRun":true}
+//
+// Paths are "$" followed by .field or ["key"] segments; object keys match
+// exactly first and case-insensitively otherwise (so $.Metadata finds the
+// "metadata" key of a marshalled AgentMessage). "when" tests a field (its own
+// path, or the rule's path when omitted) with gt|gte|lt|lte|eq|ne|contains.
+// A dryRun rule records what it would change without touching the body.
+//
+// Rules come from GW_TAMPER_RULES_FILE (JSON array) and the admin API:
+//
+//	GET    /admin/tamper/rules          list
+//	POST   /admin/tamper/rules          add one rule (same id replaces)
+//	PUT    /admin/tamper/rules          replace all rules (JSON array)
+//	DELETE /admin/tamper/rules[?id=x]   remove one rule, or all
+//	GET    /admin/tamper/events         applied rules, oldest first
+//
+// Rule bodies (and the file) are decoded strictly: unknown fields, wrong
+// types, invalid rules and trailing data are all reported at once as
+//
+//	{"error":"invalid_request","problems":[{"field":"[1].when.op","message":"unknown condition op \"between\""}]}
+//
+// Admin calls need GW_ADMIN_TOKEN as "X-Admin-Token" or "Authorization: Bearer";
+// with the variable unset they answer 403.
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type tamperCond struct {
+	Path  string `json:"path,omitempty"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+type tamperRule struct {
+	ID     string      `json:"id"`
+	Path   string      `json:"path"`
+	Op     string      `json:"op"` // set | multiply | replace
+	Value  any         `json:"value"`
+	Find   string      `json:"find,omitempty"` // replace: substring to replace
+	When   *tamperCond `json:"when,omitempty"`
+	DryRun bool        `json:"dryRun,omitempty"`
+
+	segs     []string
+	condSegs []string
+}
+
+// tamperEvent is one rule application recorded in the ring buffer.
+type tamperEvent struct {
+	Time   time.Time `json:"time"`
+	URL    string    `json:"url"`
+	Rule   string    `json:"rule"`
+	Path   string    `json:"path"`
+	Op     string    `json:"op"`
+	Before any       `json:"before"`
+	After  any       `json:"after"`
+	DryRun bool      `json:"dryRun,omitempty"`
+}
+
+// parseJSONPath splits `$.a.b["c.d"]` into ["a","b","c.d"].
+func parseJSONPath(p string) ([]string, error) {
+	p = strings.TrimSpace(p)
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("path %q must start with $", p)
+	}
+	rest := p[1:]
+	var segs []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q: empty field name", p)
+			}
+			segs = append(segs, rest[:end])
+			rest = rest[end:]
+		case '[':
+			if len(rest) < 2 || (rest[1] != '"' && rest[1] != '\'') {
+				return nil, fmt.Errorf("path %q: expected quoted key after [", p)
+			}
+			q := rest[1]
+			end := strings.IndexByte(rest[2:], q)
+			if end < 0 || len(rest) < end+4 || rest[end+3] != ']' {
+				return nil, fmt.Errorf("path %q: unterminated [ ]", p)
+			}
+			segs = append(segs, rest[2:end+2])
+			rest = rest[end+4:]
+		default:
+			return nil, fmt.Errorf("path %q: unexpected %q", p, rest[0])
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("path %q selects the whole body", p)
+	}
+	return segs, nil
+}
+
+func objKey(m map[string]any, k string) (string, bool) {
+	if _, ok := m[k]; ok {
+		return k, true
+	}
+	for mk := range m {
+		if strings.EqualFold(mk, k) {
+			return mk, true
+		}
+	}
+	return k, false
+}
+
+// lookupPath returns the parent object and resolved key of segs in m.
+func lookupPath(m map[string]any, segs []string) (map[string]any, string, bool) {
+	cur := m
+	for i, s := range segs {
+		k, ok := objKey(cur, s)
+		if i == len(segs)-1 {
+			return cur, k, ok
+		}
+		next, isObj := cur[k].(map[string]any)
+		if !ok || !isObj {
+			return nil, "", false
+		}
+		cur = next
+	}
+	return nil, "", false
+}
+
+func toNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(t), ",", ""), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func condHolds(op string, have, want any) bool {
+	if op == "contains" {
+		return strings.Contains(fmt.Sprint(have), fmt.Sprint(want))
+	}
+	a, okA := toNumber(have)
+	b, okB := toNumber(want)
+	if !okA || !okB {
+		switch op {
+		case "eq":
+			return fmt.Sprint(have) == fmt.Sprint(want)
+		case "ne":
+			return fmt.Sprint(have) != fmt.Sprint(want)
+		}
+		return false
+	}
+	switch op {
+	case "gt":
+		return a > b
+	case "gte":
+		return a >= b
+	case "lt":
+		return a < b
+	case "lte":
+		return a <= b
+	case "eq":
+		return a == b
+	case "ne":
+		return a != b
+	}
+	return false
+}
+
+// compile validates the rule and caches its parsed paths.
+func (r *tamperRule) compile() error {
+	if strings.TrimSpace(r.ID) == "" {
+		return fmt.Errorf("rule id is required")
+	}
+	segs, err := parseJSONPath(r.Path)
+	if err != nil {
+		return err
+	}
+	r.segs = segs
+	switch r.Op {
+	case "set":
+	case "multiply":
+		if _, ok := toNumber(r.Value); !ok {
+			return fmt.Errorf("rule %s: multiply needs a numeric value", r.ID)
+		}
+	case "replace":
+		if r.Find == "" {
+			return fmt.Errorf("rule %s: replace needs find", r.ID)
+		}
+	default:
+		return fmt.Errorf("rule %s: unknown op %q (set|multiply|replace)", r.ID, r.Op)
+	}
+	r.condSegs = nil
+	if r.When != nil {
+		switch r.When.Op {
+		case "gt", "gte", "lt", "lte", "eq", "ne", "contains":
+		default:
+			return fmt.Errorf("rule %s: unknown condition op %q", r.ID, r.When.Op)
+		}
+		r.condSegs = r.segs
+		if r.When.Path != "" {
+			if r.condSegs, err = parseJSONPath(r.When.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rewrite computes the new value for before; ok is false when the op does not apply.
+func (r *tamperRule) rewrite(before any, present bool) (any, bool) {
+	switch r.Op {
+	case "set":
+		return r.Value, true
+	case "multiply":
+		n, ok := toNumber(before)
+		if !present || !ok {
+			return nil, false
+		}
+		f, _ := toNumber(r.Value)
+		out := n * f
+		if n == math.Trunc(n) {
+			out = math.Round(out)
+		}
+		if _, isStr := before.(string); isStr {
+			return strconv.FormatFloat(out, 'f', -1, 64), true
+		}
+		return out, true
+	case "replace":
+		s, ok := before.(string)
+		if !present || !ok || !strings.Contains(s, r.Find) {
+			return nil, false
+		}
+		return strings.ReplaceAll(s, r.Find, fmt.Sprint(r.Value)), true
+	}
+	return nil, false
+}
+
+// tamperBook holds the active rules and the ring buffer of applied ones.
+type tamperBook struct {
+	mu    sync.Mutex
+	rules []tamperRule
+	ring  []tamperEvent
+	next  int
+	full  bool
+}
+
+func newTamperBook(size int) *tamperBook {
+	return &tamperBook{ring: make([]tamperEvent, size)}
+}
+
+// loadFile reads a JSON array of rules from path (empty = none).
+func (b *tamperBook) loadFile(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules, probs := decodeRules(raw, true)
+	if len(probs) > 0 {
+		return fmt.Errorf("%s: %s", path, probs)
+	}
+	return b.replace(rules)
+}
+
+// ruleProblem is one problem found in a rule body.
+type ruleProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ruleProblems []ruleProblem
+
+func (ps ruleProblems) String() string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = strings.TrimPrefix(p.Field+": "+p.Message, ": ")
+	}
+	return strings.Join(out, "; ")
+}
+
+// Accepted fields and their JSON kinds ("" = any value).
+var (
+	ruleFields = map[string]string{"id": "string", "path": "string", "op": "string", "value": "", "find": "string", "when": "object", "dryRun": "bool"}
+	condFields = map[string]string{"path": "string", "op": "string", "value": ""}
+)
+
+// decodeRules decodes one rule, or a JSON array of rules when many is set,
+// and compiles them. It reports every problem instead of stopping at the
+// first; rules are only returned when there are none.
+func decodeRules(body []byte, many bool) ([]tamperRule, ruleProblems) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	var top json.RawMessage
+	if err := dec.Decode(&top); err != nil {
+		return nil, ruleProblems{{Message: "body is not JSON: " + err.Error()}}
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, ruleProblems{{Message: "unexpected data after the JSON value"}}
+	}
+	elems := []json.RawMessage{top}
+	if many {
+		if err := json.Unmarshal(top, &elems); err != nil {
+			return nil, ruleProblems{{Message: "body must be a JSON array of rules"}}
+		}
+	}
+	var probs ruleProblems
+	rules := make([]tamperRule, 0, len(elems))
+	for i, raw := range elems {
+		prefix := ""
+		if many {
+			prefix = fmt.Sprintf("[%d]", i)
+		}
+		n := len(probs)
+		probs = append(probs, checkFields(prefix, raw, ruleFields)...)
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) == nil && len(probs) == n {
+			if w, ok := fields["when"]; ok && string(w) != "null" {
+				probs = append(probs, checkFields(joinField(prefix, "when"), w, condFields)...)
+			}
+		}
+		if len(probs) > n {
+			continue
+		}
+		var r tamperRule
+		if err := json.Unmarshal(raw, &r); err != nil {
+			probs = append(probs, ruleProblem{Field: prefix, Message: err.Error()})
+			continue
+		}
+		if err := r.compile(); err != nil {
+			probs = append(probs, ruleProblem{Field: prefix, Message: err.Error()})
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if len(probs) > 0 {
+		return nil, probs
+	}
+	return rules, nil
+}
+
+// checkFields reports unknown fields of the object raw and known ones of the wrong kind.
+func checkFields(prefix string, raw json.RawMessage, spec map[string]string) ruleProblems {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return ruleProblems{{Field: prefix, Message: "must be a JSON object"}}
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var probs ruleProblems
+	for _, k := range names {
+		kind, ok := spec[k]
+		if !ok {
+			probs = append(probs, ruleProblem{Field: joinField(prefix, k), Message: "unknown field"})
+			continue
+		}
+		v := fields[k]
+		if kind == "" || string(v) == "null" {
+			continue
+		}
+		var bad bool
+		switch kind {
+		case "string":
+			var s string
+			bad = json.Unmarshal(v, &s) != nil
+		case "bool":
+			var b bool
+			bad = json.Unmarshal(v, &b) != nil
+		case "object":
+			var o map[string]json.RawMessage
+			bad = json.Unmarshal(v, &o) != nil
+		}
+		if bad {
+			probs = append(probs, ruleProblem{Field: joinField(prefix, k), Message: "must be a " + map[string]string{"string": "string", "bool": "boolean", "object": "JSON object"}[kind]})
+		}
+	}
+	return probs
+}
+
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func (b *tamperBook) replace(rules []tamperRule) error {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return err
+		}
+	}
+	b.mu.Lock()
+	b.rules = rules
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *tamperBook) add(r tamperRule) error {
+	if err := r.compile(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.rules {
+		if b.rules[i].ID == r.ID {
+			b.rules[i] = r
+			return nil
+		}
+	}
+	b.rules = append(b.rules, r)
+	return nil
+}
+
+// remove drops the rule with id (all rules when id is empty) and reports how many went.
+func (b *tamperBook) remove(id string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id == "" {
+		n := len(b.rules)
+		b.rules = nil
+		return n
+	}
+	kept := b.rules[:0]
+	for _, r := range b.rules {
+		if r.ID != id {
+			kept = append(kept, r)
+		}
+	}
+	n := len(b.rules) - len(kept)
+	b.rules = kept
+	return n
+}
+
+func (b *tamperBook) list() []tamperRule {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]tamperRule(nil), b.rules...)
+}
+
+func (b *tamperBook) active() bool { return len(b.list()) > 0 }
+
+func (b *tamperBook) record(ev tamperEvent) {
+	b.mu.Lock()
+	b.ring[b.next] = ev
+	b.next = (b.next + 1) % len(b.ring)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.mu.Unlock()
+}
+
+// events returns applied rules oldest-first.
+func (b *tamperBook) events() []tamperEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]tamperEvent(nil), b.ring[:b.next]...)
+	}
+	return append(append([]tamperEvent(nil), b.ring[b.next:]...), b.ring[:b.next]...)
+}
+
+// apply runs every matching rule against m in order and reports whether m
+// changed, with the ids of the rules applied and of the dry-run rules that matched.
+func (b *tamperBook) apply(url string, m map[string]any) (changed bool, applied, dryRun []string) {
+	for _, r := range b.list() {
+		if r.When != nil {
+			cp, ck, ok := lookupPath(m, r.condSegs)
+			if !ok || !condHolds(r.When.Op, cp[ck], r.When.Value) {
+				continue
+			}
+		}
+		parent, key, present := lookupPath(m, r.segs)
+		if parent == nil {
+			continue
+		}
+		before := parent[key]
+		after, ok := r.rewrite(before, present)
+		if !ok {
+			continue
+		}
+		if !r.DryRun {
+			parent[key] = after
+			changed = true
+			applied = append(applied, r.ID)
+		} else {
+			dryRun = append(dryRun, r.ID)
+		}
+		b.record(tamperEvent{Time: time.Now(), URL: url, Rule: r.ID, Path: r.Path, Op: r.Op, Before: before, After: after, DryRun: r.DryRun})
+		log.Printf("[GW][TAMPER] rule=%s %s %s: %v -> %v dryRun=%v", r.ID, r.Op, r.Path, before, after, r.DryRun)
+	}
+	return changed, applied, dryRun
+}
+
+// requireAdmin writes 403 and returns false unless req carries GW_ADMIN_TOKEN.
+func requireAdmin(w http.ResponseWriter, req *http.Request) bool {
+	want := strings.TrimSpace(os.Getenv("GW_ADMIN_TOKEN"))
+	got := strings.TrimSpace(req.Header.Get("X-Admin-Token"))
+	if got == "" {
+		if a := req.Header.Get("Authorization"); len(a) > 7 && strings.EqualFold(a[:7], "bearer ") {
+			got = strings.TrimSpace(a[7:])
+		}
+	}
+	if want == "" || got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+const maxRulesBody = 256 << 10
+
+func (b *tamperBook) handleRules(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRulesBody+1))
+		if err != nil || len(body) > maxRulesBody {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request", "problems": ruleProblems{{Message: fmt.Sprintf("body unreadable or over %d bytes", maxRulesBody)}}})
+			return
+		}
+		rules, probs := decodeRules(body, r.Method == http.MethodPut)
+		if len(probs) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request", "problems": probs})
+			return
+		}
+		if r.Method == http.MethodPost {
+			err = b.add(rules[0])
+		} else {
+			err = b.replace(rules)
+		}
+		if err != nil { // decodeRules compiled them already
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request", "problems": ruleProblems{{Message: err.Error()}}})
+			return
+		}
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if b.remove(id) == 0 && id != "" {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "rule_not_found", "id": id})
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": b.list()})
+}
+
+func (b *tamperBook) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": b.events()})
+}
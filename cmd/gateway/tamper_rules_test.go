@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/agents/payment"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestDecodeRulesStrict(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		many bool
+		want []string // "field: message" fragments, all expected
+	}{
+		{"valid", `{"id":"a","path":"$.content","op":"set","value":"x"}`, false, nil},
+		{"valid array", `[{"id":"a","path":"$.content","op":"set","value":"x"},{"id":"b","path":"$.metadata[\"payment.amountKRW\"]","op":"multiply","value":10,"when":{"op":"gt","value":1}}]`, true, nil},
+		{"unknown field", `{"id":"a","path":"$.content","op":"set","valeu":"x"}`, false, []string{"valeu: unknown field"}},
+		{"wrong type", `{"id":"a","path":"$.content","op":"set","dryRun":"yes"}`, false, []string{"dryRun: must be a boolean"}},
+		{"condition", `{"id":"a","path":"$.content","op":"set","when":{"op":"gt","valu":1,"path":7}}`, false, []string{"when.path: must be a string", "when.valu: unknown field"}},
+		{"invalid rule", `{"id":"a","path":"content","op":"set"}`, false, []string{`must start with $`}},
+		{"trailing data", `{"id":"a","path":"$.content","op":"set"} {"id":"b"}`, false, []string{"unexpected data after the JSON value"}},
+		{"not an array", `{"id":"a","path":"$.content","op":"set"}`, true, []string{"JSON array"}},
+		{"every problem", `[{"id":"a","path":"$.x","op":"explode"},{"id":1,"path":"$.y","op":"set","extra":true},{"id":"c","path":"$.z","op":"replace"}]`, true,
+			[]string{`[0]: rule a: unknown op "explode"`, "[1].extra: unknown field", "[1].id: must be a string", "[2]: rule c: replace needs find"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, probs := decodeRules([]byte(tc.body), tc.many)
+			if len(tc.want) == 0 {
+				if len(probs) > 0 || len(rules) == 0 {
+					t.Fatalf("rules=%d problems=%s", len(rules), probs)
+				}
+				return
+			}
+			if rules != nil {
+				t.Fatalf("rules returned with problems: %v", rules)
+			}
+			got := probs.String()
+			for _, w := range tc.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("problems %q lack %q", got, w)
+				}
+			}
+			if len(probs) != len(tc.want) {
+				t.Errorf("%d problems, want %d: %s", len(probs), len(tc.want), got)
+			}
+		})
+	}
+}
+
+// A rejected body leaves the rule set as it was.
+func TestRulesAdminInvalid(t *testing.T) {
+	t.Setenv("GW_ADMIN_TOKEN", "gw-test")
+	b := newTamperBook(8)
+	if err := b.add(tamperRule{ID: "keep", Path: "$.content", Op: "set", Value: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/admin/tamper/rules", strings.NewReader(`[{"id":"a","path":"$.x","op":"set","when":{"op":"between"}},{"id":"b","oops":1}]`))
+	req.Header.Set("X-Admin-Token", "gw-test")
+	w := httptest.NewRecorder()
+	b.handleRules(w, req)
+	var out struct {
+		Error    string        `json:"error"`
+		Problems []ruleProblem `json:"problems"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || w.Code != http.StatusBadRequest || out.Error != "invalid_request" || len(out.Problems) != 2 {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if l := b.list(); len(l) != 1 || l[0].ID != "keep" {
+		t.Fatalf("rules changed: %+v", l)
+	}
+}
+
+// verifyingUpstream puts the payment agent behind a stand-in for its DID
+// middleware: Content-Digest must match the body and the signature must
+// cover it (see fakeSignature).
+func verifyingUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("PAYMENT_FLAGS_FILE", "")
+	pa, err := payment.NewPaymentAgentWithMode(payment.ModeFull, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := testObserver().verify(pa.Handler())
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Digest") != computeContentDigest(body) {
+			http.Error(w, "content-digest mismatch", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sig.ServeHTTP(w, r)
+	}))
+}
+
+// A ×10 amount rule changes the body the signature covers, so the payment
+// agent refuses the request, whether or not the gateway recomputes the digest.
+func TestAmountRuleRejectedByPayment(t *testing.T) {
+	up := verifyingUpstream(t)
+	defer up.Close()
+
+	msg, _ := json.Marshal(types.AgentMessage{ID: "m1", From: "root", To: "payment", Type: "request", Content: "맥북 결제",
+		Metadata: map[string]any{"payment.amountKRW": 300000, "payment.to": "애플스토어", "payment.method": "card", "lang": "ko"}})
+	inflate := tamperRule{ID: "inflate", Path: `$.metadata["payment.amountKRW"]`, Op: "multiply", Value: 10, When: &tamperCond{Op: "gt", Value: 100000}}
+
+	cases := []struct {
+		name      string
+		rule      bool
+		recompute bool
+		want      int
+	}{
+		{"no rule", false, false, http.StatusOK},
+		{"x10", true, false, http.StatusUnauthorized},
+		{"x10 digest recomputed", true, true, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			book := newTamperBook(8)
+			if tc.rule {
+				if err := book.add(inflate); err != nil {
+					t.Fatal(err)
+				}
+			}
+			gw := httptest.NewServer(proxyKeepPath(up.URL, "", book, nil, tc.recompute, false))
+			defer gw.Close()
+
+			req, _ := http.NewRequest(http.MethodPost, gw.URL+"/process", bytes.NewReader(msg))
+			req.Header.Set("Content-Type", "application/json")
+			signReq(req, msg)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, tc.want, body)
+			}
+			evs := book.events()
+			if !tc.rule {
+				if len(evs) != 0 {
+					t.Fatalf("events: %+v", evs)
+				}
+				return
+			}
+			if len(evs) != 1 || evs[0].Rule != "inflate" || evs[0].Before != float64(300000) || evs[0].After != float64(3000000) {
+				t.Fatalf("events: %+v", evs)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// cmd/loadgen/main.go
+// Load generator that drives scripted multi-turn conversations (chat, medical
+// intake, payment collect → confirm as a dry run) through the client API or
+// Root directly, so the stateful paths — LLM calls, HPKE sessions, the
+// per-conversation locks — are exercised, not just one endpoint.
+//
+//	go run ./cmd/loadgen -profile default -base http://localhost:8086
+//	go run ./cmd/loadgen -target root -base http://localhost:18080 -users 20 -duration 5m
+//	go run ./cmd/loadgen -profile smoke -fail-on-error     # CI: low concurrency, zero errors
+//	go run ./cmd/loadgen -profile my_profile.json -json
+//
+// A profile (builtin "default"/"smoke" or a JSON file of the same shape, see
+// profile.go) sets the virtual users, iterations or duration, ramp-up, think
+// times, the SAGE/HPKE mix and the weighted conversation templates; the
+// flags below override its numbers. The report lists latency percentiles per
+// turn type, errors by structured error code, and the server-side deltas of
+// every /metrics series that changed during the run.
+//
+// Dry-run turns send X-SAGE-Dry-Run: true, which Root honours only with its
+// admin token (-admin-token / ROOT_ADMIN_TOKEN); otherwise the confirm turn
+// goes to the payment agent's normal operation.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func getenvStr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func main() {
+	profileRef := flag.String("profile", "default", "builtin profile (default, smoke) or path to a profile JSON")
+	target := flag.String("target", targetClient, "client (POST {base}/api/request) or root (POST {base}/process)")
+	base := flag.String("base", "", "target base URL (default http://localhost:8086 for client, http://localhost:18080 for root)")
+	users := flag.Int("users", 0, "override: virtual users")
+	iterations := flag.Int("iterations", -1, "override: conversations per user (0: run for -duration)")
+	duration := flag.Duration("duration", 0, "override: run duration")
+	rampUp := flag.Duration("ramp-up", -1, "override: ramp-up")
+	think := flag.String("think", "", `override: think time between turns ("1s" or "500ms-2s")`)
+	sageRatio := flag.Float64("sage-ratio", -1, "override: share of conversations with SAGE on [0,1]")
+	hpkeRatio := flag.Float64("hpke-ratio", -1, "override: share of SAGE conversations with HPKE [0,1]")
+	metrics := flag.String("metrics", getenvStr("LOADGEN_METRICS", "http://localhost:18080/metrics"), "comma-separated /metrics URLs to diff before/after (empty: skip)")
+	adminToken := flag.String("admin-token", getenvStr("ROOT_ADMIN_TOKEN", ""), "Root admin token (enables dry-run turns)")
+	timeout := flag.Duration("timeout", 60*time.Second, "per-turn timeout")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	failOnError := flag.Bool("fail-on-error", false, "exit 1 when any turn failed")
+	flag.Parse()
+
+	p, err := loadProfile(*profileRef)
+	if err != nil {
+		log.Fatalf("[loadgen] %v", err)
+	}
+	if *users > 0 {
+		p.Users = *users
+	}
+	if *iterations >= 0 {
+		p.Iterations = *iterations
+	}
+	if *duration > 0 {
+		p.Duration = duration.String()
+	}
+	if *rampUp >= 0 {
+		p.RampUp = rampUp.String()
+	}
+	if *think != "" {
+		p.Think = *think
+	}
+	if *sageRatio >= 0 {
+		p.SAGERatio = *sageRatio
+	}
+	if *hpkeRatio >= 0 {
+		p.HPKERatio = *hpkeRatio
+	}
+	if err := p.validate(); err != nil {
+		log.Fatalf("[loadgen] profile %s: %v", p.Name, err)
+	}
+	switch *target {
+	case targetClient, targetRoot:
+	default:
+		log.Fatalf("[loadgen] -target must be client or root")
+	}
+	if *base == "" {
+		*base = map[string]string{targetClient: "http://localhost:8086", targetRoot: "http://localhost:18080"}[*target]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	hc := &http.Client{Timeout: *timeout}
+	var scrapeURLs []string
+	for _, u := range strings.Split(*metrics, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			scrapeURLs = append(scrapeURLs, u)
+		}
+	}
+	rep := &report{Profile: p.Name, Target: *target, Base: *base}
+	before := map[string]map[string]float64{}
+	for _, u := range scrapeURLs {
+		m, err := scrapeMetrics(ctx, hc, u)
+		if err != nil {
+			rep.MetricsErrors = append(rep.MetricsErrors, "before: "+err.Error())
+			continue
+		}
+		before[u] = m
+	}
+
+	r := &runner{p: p, target: *target, base: strings.TrimRight(*base, "/"), adminToken: *adminToken, http: hc}
+	log.Printf("[loadgen] profile=%s target=%s base=%s users=%d iterations=%d duration=%s", p.Name, *target, r.base, p.Users, p.Iterations, p.duration)
+	start := time.Now()
+	r.run(ctx)
+	rep.Elapsed = time.Since(start).Round(time.Millisecond).String()
+
+	if len(before) > 0 {
+		rep.MetricsDelta = map[string]float64{}
+		for _, u := range scrapeURLs {
+			b, ok := before[u]
+			if !ok {
+				continue
+			}
+			after, err := scrapeMetrics(context.Background(), hc, u)
+			if err != nil {
+				rep.MetricsErrors = append(rep.MetricsErrors, "after: "+err.Error())
+				continue
+			}
+			prefix := ""
+			if len(scrapeURLs) > 1 {
+				if pu, err := url.Parse(u); err == nil {
+					prefix = pu.Host + " "
+				}
+			}
+			metricsDelta(prefix, b, after, rep.MetricsDelta)
+		}
+	}
+
+	rep.Conversations = r.convs
+	rep.Turns = len(r.samples)
+	rep.ByType, rep.ByCode, rep.Errors = summarize(r.samples)
+	if rep.Turns > 0 {
+		rep.ErrorRate = float64(rep.Errors) / float64(rep.Turns)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		rep.print(os.Stdout)
+	}
+	if *failOnError && (rep.Errors > 0 || rep.Turns == 0) {
+		fmt.Fprintf(os.Stderr, "[loadgen] %d of %d turns failed\n", rep.Errors, rep.Turns)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// turn is one user message in a conversation template.
+type turn struct {
+	Type   string `json:"type"`             // report bucket, e.g. "payment.confirm"
+	Prompt string `json:"prompt"`           // what the virtual user says
+	Think  string `json:"think,omitempty"`  // overrides the profile think time before this turn
+	DryRun bool   `json:"dryRun,omitempty"` // ask Root to route payment to its simulate operation
+
+	// ExpectType, when set, lists the acceptable AgentMessage types
+	// (response, clarify, confirm, ...); anything else counts as an error.
+	ExpectType []string `json:"expectType,omitempty"`
+
+	think time.Duration
+}
+
+// template is a scripted multi-turn conversation.
+type template struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight,omitempty"` // relative pick frequency (default 1)
+	Turns  []turn `json:"turns"`
+}
+
+// profile is a complete load run.
+type profile struct {
+	Name       string     `json:"name"`
+	Users      int        `json:"users"`                // virtual users
+	Iterations int        `json:"iterations,omitempty"` // conversations per user (0: until Duration)
+	Duration   string     `json:"duration,omitempty"`   // stop starting conversations after this
+	RampUp     string     `json:"rampUp,omitempty"`     // users start evenly spread over this
+	Think      string     `json:"think,omitempty"`      // pause between turns ("1s" or "500ms-2s")
+	SAGERatio  float64    `json:"sageRatio"`            // share of conversations sent with X-SAGE-Enabled
+	HPKERatio  float64    `json:"hpkeRatio"`            // share of SAGE conversations that also ask for HPKE
+	Templates  []template `json:"templates"`
+
+	duration, rampUp   time.Duration
+	thinkMin, thinkMax time.Duration
+	weightTotal        int
+}
+
+// builtinProfiles are selectable with -profile <name>.
+var builtinProfiles = map[string]profile{
+	"default": {
+		Name: "default", Users: 10, Duration: "2m", RampUp: "20s", Think: "500ms-2s",
+		SAGERatio: 0.7, HPKERatio: 0.5,
+		Templates: defaultTemplates,
+	},
+	// smoke: low concurrency, every template once per user; CI asserts zero errors
+	"smoke": {
+		Name: "smoke", Users: 2, Iterations: 3, Think: "100ms",
+		SAGERatio: 0.5, HPKERatio: 0.5,
+		Templates: defaultTemplates,
+	},
+}
+
+var defaultTemplates = []template{
+	{Name: "chat", Weight: 2, Turns: []turn{
+		{Type: "chat", Prompt: "안녕하세요, 오늘 하루 어떻게 보내면 좋을까요?"},
+		{Type: "chat", Prompt: "고마워요. 짧게 요약해 줄래요?"},
+	}},
+	{Name: "medical_intake", Weight: 1, Turns: []turn{
+		{Type: "medical.intake", Prompt: "요즘 두통이 심해요"},
+		{Type: "medical.intake", Prompt: "사흘 전부터 오후에 심해지고 진통제는 잘 안 들어요"},
+		{Type: "medical.intake", Prompt: "다른 증상은 없어요. 병원에 가봐야 할까요?"},
+	}},
+	{Name: "payment_dry_run", Weight: 1, Turns: []turn{
+		{Type: "payment.collect", Prompt: "김철수에게 5만원 송금해줘"},
+		{Type: "payment.collect", Prompt: "카드로 결제할게요"},
+		{Type: "payment.confirm", Prompt: "네, 진행해 주세요", DryRun: true},
+	}},
+}
+
+// parseThink accepts "1s" or a "min-max" range.
+func parseThink(s string) (lo, hi time.Duration, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	a, b, isRange := strings.Cut(s, "-")
+	if lo, err = time.ParseDuration(strings.TrimSpace(a)); err != nil {
+		return 0, 0, err
+	}
+	hi = lo
+	if isRange {
+		if hi, err = time.ParseDuration(strings.TrimSpace(b)); err != nil {
+			return 0, 0, err
+		}
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("think range %q: max < min", s)
+	}
+	return lo, hi, nil
+}
+
+func parseOptDuration(field, s string) (time.Duration, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", field, err)
+	}
+	return d, nil
+}
+
+// validate checks the profile and fills the parsed fields.
+func (p *profile) validate() error {
+	var err error
+	if p.Users <= 0 {
+		return fmt.Errorf("users must be > 0")
+	}
+	if p.duration, err = parseOptDuration("duration", p.Duration); err != nil {
+		return err
+	}
+	if p.Iterations <= 0 && p.duration <= 0 {
+		return fmt.Errorf("set iterations or duration")
+	}
+	if p.rampUp, err = parseOptDuration("rampUp", p.RampUp); err != nil {
+		return err
+	}
+	if p.thinkMin, p.thinkMax, err = parseThink(p.Think); err != nil {
+		return fmt.Errorf("think: %w", err)
+	}
+	if p.SAGERatio < 0 || p.SAGERatio > 1 || p.HPKERatio < 0 || p.HPKERatio > 1 {
+		return fmt.Errorf("sageRatio/hpkeRatio must be within [0,1]")
+	}
+	if len(p.Templates) == 0 {
+		return fmt.Errorf("no templates")
+	}
+	p.weightTotal = 0
+	for i := range p.Templates {
+		t := &p.Templates[i]
+		if t.Weight <= 0 {
+			t.Weight = 1
+		}
+		p.weightTotal += t.Weight
+		if len(t.Turns) == 0 {
+			return fmt.Errorf("template %q has no turns", t.Name)
+		}
+		for j := range t.Turns {
+			tu := &t.Turns[j]
+			if strings.TrimSpace(tu.Prompt) == "" {
+				return fmt.Errorf("template %q turn %d: empty prompt", t.Name, j)
+			}
+			if tu.Type == "" {
+				tu.Type = t.Name
+			}
+			if tu.think, err = parseOptDuration(t.Name+" turn think", tu.Think); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadProfile returns a builtin profile by name or reads a JSON file.
+func loadProfile(ref string) (*profile, error) {
+	if p, ok := builtinProfiles[ref]; ok {
+		p.Templates = append([]template(nil), p.Templates...)
+		for i := range p.Templates {
+			p.Templates[i].Turns = append([]turn(nil), p.Templates[i].Turns...)
+		}
+		return &p, nil
+	}
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: not a builtin (default, smoke) and %w", ref, err)
+	}
+	var p profile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", ref, err)
+	}
+	if p.Name == "" {
+		p.Name = ref
+	}
+	return &p, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThink(t *testing.T) {
+	cases := []struct {
+		in      string
+		lo, hi  time.Duration
+		wantErr bool
+	}{
+		{"", 0, 0, false},
+		{"1s", time.Second, time.Second, false},
+		{"500ms-2s", 500 * time.Millisecond, 2 * time.Second, false},
+		{" 100ms - 300ms ", 100 * time.Millisecond, 300 * time.Millisecond, false},
+		{"2s-1s", 0, 0, true},
+		{"soon", 0, 0, true},
+		{"1s-later", 0, 0, true},
+	}
+	for _, c := range cases {
+		lo, hi, err := parseThink(c.in)
+		if (err != nil) != c.wantErr || lo != c.lo || hi != c.hi {
+			t.Errorf("parseThink(%q) = %v, %v, %v", c.in, lo, hi, err)
+		}
+	}
+}
+
+// A template file gets the same defaults as the builtins: weight 1, the
+// template name as turn type, per-turn think times parsed.
+func TestLoadProfileFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.json")
+	err := os.WriteFile(path, []byte(`{
+		"users": 3, "iterations": 2, "think": "100ms-200ms", "sageRatio": 1,
+		"templates": [
+			{"name": "refund", "turns": [
+				{"prompt": "환불해줘"},
+				{"type": "refund.confirm", "prompt": "네", "think": "1s", "dryRun": true, "expectType": ["response"]}
+			]},
+			{"name": "chat", "weight": 3, "turns": [{"prompt": "안녕"}]}
+		]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != path || p.weightTotal != 4 || p.thinkMin != 100*time.Millisecond || p.thinkMax != 200*time.Millisecond {
+		t.Fatalf("profile %+v", p)
+	}
+	refund := p.Templates[0]
+	if refund.Weight != 1 || refund.Turns[0].Type != "refund" || refund.Turns[0].think != 0 {
+		t.Fatalf("first turn defaults %+v", refund)
+	}
+	if tu := refund.Turns[1]; tu.Type != "refund.confirm" || tu.think != time.Second || !tu.DryRun || len(tu.ExpectType) != 1 {
+		t.Fatalf("second turn %+v", tu)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	one := []template{{Name: "chat", Turns: []turn{{Prompt: "hi"}}}}
+	cases := []struct {
+		name string
+		p    profile
+		want string
+	}{
+		{"no users", profile{Iterations: 1, Templates: one}, "users"},
+		{"no stop", profile{Users: 1, Templates: one}, "iterations or duration"},
+		{"bad duration", profile{Users: 1, Duration: "forever", Templates: one}, "duration"},
+		{"ratio", profile{Users: 1, Iterations: 1, SAGERatio: 1.5, Templates: one}, "within [0,1]"},
+		{"no templates", profile{Users: 1, Iterations: 1}, "no templates"},
+		{"no turns", profile{Users: 1, Iterations: 1, Templates: []template{{Name: "x"}}}, "no turns"},
+		{"empty prompt", profile{Users: 1, Iterations: 1, Templates: []template{{Name: "x", Turns: []turn{{Prompt: " "}}}}}, "empty prompt"},
+		{"turn think", profile{Users: 1, Iterations: 1, Templates: []template{{Name: "x", Turns: []turn{{Prompt: "hi", Think: "1x"}}}}}, "turn think"},
+	}
+	for _, c := range cases {
+		if err := c.p.validate(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
+
+// Builtins are copied on load, so validating one run's profile does not
+// change the next.
+func TestLoadProfileBuiltinCopy(t *testing.T) {
+	p, err := loadProfile("smoke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Templates[0].Turns[0].Prompt = "changed"
+	if builtinProfiles["smoke"].Templates[0].Turns[0].Prompt == "changed" {
+		t.Fatal("builtin template modified through a loaded profile")
+	}
+	if _, err := loadProfile(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "not a builtin") {
+		t.Fatalf("missing file: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// turnStats is the per-turn-type summary.
+type turnStats struct {
+	Type   string         `json:"type"`
+	Count  int            `json:"count"`
+	Errors int            `json:"errors"`
+	P50    float64        `json:"p50Ms"`
+	P90    float64        `json:"p90Ms"`
+	P99    float64        `json:"p99Ms"`
+	Max    float64        `json:"maxMs"`
+	ByCode map[string]int `json:"errorsByCode,omitempty"`
+}
+
+type report struct {
+	Profile       string             `json:"profile"`
+	Target        string             `json:"target"`
+	Base          string             `json:"base"`
+	Elapsed       string             `json:"elapsed"`
+	Conversations int                `json:"conversations"`
+	Turns         int                `json:"turns"`
+	Errors        int                `json:"errors"`
+	ErrorRate     float64            `json:"errorRate"`
+	ByType        []turnStats        `json:"byType"`
+	ByCode        map[string]int     `json:"errorsByCode,omitempty"`
+	MetricsDelta  map[string]float64 `json:"metricsDelta,omitempty"` // server-side counter deltas
+	MetricsErrors []string           `json:"metricsErrors,omitempty"`
+}
+
+func ms(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+// percentile returns the nearest-rank percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.999999) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func summarize(samples []sample) ([]turnStats, map[string]int, int) {
+	lat := map[string][]time.Duration{}
+	stats := map[string]*turnStats{}
+	byCode := map[string]int{}
+	errs := 0
+	for _, s := range samples {
+		ts := stats[s.Type]
+		if ts == nil {
+			ts = &turnStats{Type: s.Type, ByCode: map[string]int{}}
+			stats[s.Type] = ts
+		}
+		ts.Count++
+		lat[s.Type] = append(lat[s.Type], s.Latency)
+		if s.Code != "" {
+			ts.Errors++
+			ts.ByCode[s.Code]++
+			byCode[s.Code]++
+			errs++
+		}
+	}
+	out := make([]turnStats, 0, len(stats))
+	for t, ts := range stats {
+		l := lat[t]
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		ts.P50, ts.P90, ts.P99 = ms(percentile(l, 0.50)), ms(percentile(l, 0.90)), ms(percentile(l, 0.99))
+		ts.Max = ms(l[len(l)-1])
+		if len(ts.ByCode) == 0 {
+			ts.ByCode = nil
+		}
+		out = append(out, *ts)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out, byCode, errs
+}
+
+// scrapeMetrics reads a Prometheus text endpoint into series → value.
+func scrapeMetrics(ctx context.Context, c *http.Client, url string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	return parseMetrics(resp.Body), nil
+}
+
+func parseMetrics(r io.Reader) map[string]float64 {
+	out := map[string]float64{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i <= 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			continue
+		}
+		out[strings.TrimSpace(line[:i])] = v
+	}
+	return out
+}
+
+// metricsDelta keeps the series that changed between two scrapes, prefixed
+// with the scraped URL's host when several endpoints are scraped.
+func metricsDelta(prefix string, before, after map[string]float64, into map[string]float64) {
+	for k, a := range after {
+		if d := a - before[k]; d != 0 {
+			into[prefix+k] = d
+		}
+	}
+}
+
+func (rep *report) print(w io.Writer) {
+	fmt.Fprintf(w, "loadgen %s → %s (%s) in %s\n", rep.Profile, rep.Base, rep.Target, rep.Elapsed)
+	fmt.Fprintf(w, "%d conversations, %d turns, %d errors (%.2f%%)\n\n", rep.Conversations, rep.Turns, rep.Errors, 100*rep.ErrorRate)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TURN\tCOUNT\tERR\tP50 ms\tP90 ms\tP99 ms\tMAX ms")
+	for _, ts := range rep.ByType {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\n", ts.Type, ts.Count, ts.Errors, ts.P50, ts.P90, ts.P99, ts.Max)
+	}
+	_ = tw.Flush()
+
+	if len(rep.ByCode) > 0 {
+		fmt.Fprintln(w, "\nerrors by code:")
+		for _, k := range sortedKeys(rep.ByCode) {
+			fmt.Fprintf(w, "  %-32s %d\n", k, rep.ByCode[k])
+		}
+	}
+	if len(rep.MetricsDelta) > 0 {
+		fmt.Fprintln(w, "\nserver-side deltas (/metrics):")
+		for _, k := range sortedKeys(rep.MetricsDelta) {
+			fmt.Fprintf(w, "  %-64s %+g\n", k, rep.MetricsDelta[k])
+		}
+	}
+	for _, e := range rep.MetricsErrors {
+		fmt.Fprintf(w, "\nmetrics: %s\n", e)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var l []time.Duration
+	for i := 1; i <= 100; i++ {
+		l = append(l, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{0.50: 50, 0.90: 90, 0.99: 99, 1: 100, 0: 1} {
+		if got := percentile(l, p); got != want*time.Millisecond {
+			t.Errorf("p%v = %v, want %vms", p*100, got, int64(want))
+		}
+	}
+	if got := percentile(l[:1], 0.99); got != time.Millisecond {
+		t.Errorf("single sample p99 = %v", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("empty p50 = %v", got)
+	}
+}
+
+// Latencies are aggregated per turn type; errors are counted per type and
+// per code.
+func TestSummarize(t *testing.T) {
+	var samples []sample
+	for i := 10; i >= 1; i-- {
+		samples = append(samples, sample{Type: "chat", Latency: time.Duration(i) * time.Millisecond})
+	}
+	samples = append(samples,
+		sample{Type: "payment.confirm", Latency: 40 * time.Millisecond, Code: "upstream_timeout"},
+		sample{Type: "payment.confirm", Latency: 20 * time.Millisecond},
+		sample{Type: "payment.collect", Latency: 5 * time.Millisecond, Code: "upstream_timeout"},
+	)
+	stats, byCode, errs := summarize(samples)
+	if errs != 2 || byCode["upstream_timeout"] != 2 || len(stats) != 3 {
+		t.Fatalf("errs %d byCode %v stats %+v", errs, byCode, stats)
+	}
+	chat, confirm := stats[0], stats[2]
+	if chat.Type != "chat" || chat.Count != 10 || chat.Errors != 0 || chat.P50 != 5 || chat.P90 != 9 || chat.P99 != 10 || chat.Max != 10 || chat.ByCode != nil {
+		t.Fatalf("chat %+v", chat)
+	}
+	if confirm.Type != "payment.confirm" || confirm.Count != 2 || confirm.Errors != 1 || confirm.P50 != 20 || confirm.Max != 40 || confirm.ByCode["upstream_timeout"] != 1 {
+		t.Fatalf("confirm %+v", confirm)
+	}
+}
+
+func TestMetricsDelta(t *testing.T) {
+	before := parseMetrics(strings.NewReader(`# HELP x
+# TYPE root_requests_total counter
+root_requests_total{agent="payment"} 10
+root_requests_total{agent="medical"} 4
+root_up 1
+garbage line
+`))
+	after := parseMetrics(strings.NewReader(`root_requests_total{agent="payment"} 25
+root_requests_total{agent="medical"} 4
+root_up 1
+root_new_total 2
+`))
+	if len(before) != 3 || before[`root_requests_total{agent="payment"}`] != 10 {
+		t.Fatalf("parsed %v", before)
+	}
+	delta := map[string]float64{}
+	metricsDelta("root:18080 ", before, after, delta)
+	if len(delta) != 2 || delta[`root:18080 root_requests_total{agent="payment"}`] != 15 || delta["root:18080 root_new_total"] != 2 {
+		t.Fatalf("delta %v", delta)
+	}
+}
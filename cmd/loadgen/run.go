@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const (
+	targetClient = "client" // POST {base}/api/request (client API)
+	targetRoot   = "root"   // POST {base}/process (Root directly)
+)
+
+// sample is the outcome of one turn.
+type sample struct {
+	Type    string
+	Latency time.Duration
+	Code    string // "" on success, else a structured error code
+}
+
+type runner struct {
+	p          *profile
+	target     string
+	base       string
+	adminToken string
+	http       *http.Client
+
+	mu      sync.Mutex
+	samples []sample
+	convs   int
+}
+
+func (r *runner) record(s sample) {
+	r.mu.Lock()
+	r.samples = append(r.samples, s)
+	r.mu.Unlock()
+}
+
+// run starts the virtual users (spread over the ramp-up) and waits for them.
+func (r *runner) run(ctx context.Context) {
+	if r.p.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.p.duration)
+		defer cancel()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < r.p.Users; i++ {
+		delay := time.Duration(0)
+		if r.p.rampUp > 0 && r.p.Users > 1 {
+			delay = r.p.rampUp * time.Duration(i) / time.Duration(r.p.Users-1)
+		}
+		wg.Add(1)
+		go func(vu int, delay time.Duration) {
+			defer wg.Done()
+			if !sleepCtx(ctx, delay) {
+				return
+			}
+			r.user(ctx, vu, rand.New(rand.NewSource(time.Now().UnixNano()+int64(vu))))
+		}(i, delay)
+	}
+	wg.Wait()
+}
+
+// user runs conversations until the iteration count or the deadline.
+func (r *runner) user(ctx context.Context, vu int, rng *rand.Rand) {
+	for it := 0; r.p.Iterations <= 0 || it < r.p.Iterations; it++ {
+		if ctx.Err() != nil {
+			return
+		}
+		t := r.pick(rng, it)
+		sage := rng.Float64() < r.p.SAGERatio
+		hpke := sage && rng.Float64() < r.p.HPKERatio
+		cid := fmt.Sprintf("loadgen-%d-%s", vu, uuid.NewString()[:8])
+		r.conversation(ctx, rng, t, cid, sage, hpke)
+		r.mu.Lock()
+		r.convs++
+		r.mu.Unlock()
+	}
+}
+
+// pick chooses a template by weight. With a fixed iteration count the first
+// iterations walk the templates in order so every template runs at least once.
+func (r *runner) pick(rng *rand.Rand, it int) *template {
+	if r.p.Iterations > 0 && it < len(r.p.Templates) {
+		return &r.p.Templates[it]
+	}
+	n := rng.Intn(r.p.weightTotal)
+	for i := range r.p.Templates {
+		if n -= r.p.Templates[i].Weight; n < 0 {
+			return &r.p.Templates[i]
+		}
+	}
+	return &r.p.Templates[0]
+}
+
+func (r *runner) conversation(ctx context.Context, rng *rand.Rand, t *template, cid string, sage, hpke bool) {
+	for i, tu := range t.Turns {
+		if i > 0 {
+			think := tu.think
+			if think == 0 {
+				think = r.p.thinkMin
+				if span := r.p.thinkMax - r.p.thinkMin; span > 0 {
+					think += time.Duration(rng.Int63n(int64(span)))
+				}
+			}
+			if !sleepCtx(ctx, think) {
+				return
+			}
+		}
+		start := time.Now()
+		msgType, code := r.send(ctx, tu, cid, sage, hpke)
+		if code == "canceled" {
+			return // run deadline hit mid-turn; not a server error
+		}
+		if code == "" && len(tu.ExpectType) > 0 && !containsFold(tu.ExpectType, msgType) {
+			code = "unexpected_type:" + msgType
+		}
+		r.record(sample{Type: tu.Type, Latency: time.Since(start), Code: code})
+		if code != "" {
+			return // the rest of the script assumes this turn worked
+		}
+	}
+}
+
+// send performs one turn and returns the reply's message type and an error
+// code ("" on success).
+func (r *runner) send(ctx context.Context, tu turn, cid string, sage, hpke bool) (string, string) {
+	var url string
+	var body []byte
+	switch r.target {
+	case targetRoot:
+		url = r.base + "/process"
+		body, _ = json.Marshal(types.AgentMessage{
+			ID: "loadgen-" + uuid.NewString(), ContextID: cid, From: "loadgen", To: "root",
+			Type: "request", Content: tu.Prompt, Timestamp: time.Now(),
+		})
+	default:
+		url = r.base + "/api/request"
+		body, _ = json.Marshal(types.PromptRequest{Prompt: tu.Prompt})
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", "request_build"
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Conversation-ID", cid)
+	req.Header.Set("X-SAGE-Context-ID", cid)
+	req.Header.Set("X-SAGE-Enabled", fmt.Sprint(sage))
+	req.Header.Set("X-HPKE-Enabled", fmt.Sprint(hpke))
+	if tu.DryRun {
+		req.Header.Set("X-SAGE-Dry-Run", "true")
+		if r.adminToken != "" {
+			req.Header.Set("X-Admin-Token", r.adminToken)
+		}
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", "canceled"
+		}
+		return "", "transport"
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	return classify(r.target, resp.StatusCode, raw)
+}
+
+// classify extracts the message type and a structured error code: the
+// client API's error.code, Root's metadata.error.code or {"error": ...}
+// envelope, else http_<status>.
+func classify(target string, status int, raw []byte) (string, string) {
+	var env struct {
+		Error json.RawMessage `json:"error"`
+	}
+	_ = json.Unmarshal(raw, &env)
+	var envCode string
+	if len(env.Error) > 0 {
+		var ed types.ErrorDetail
+		if json.Unmarshal(env.Error, &ed) == nil && ed.Code != "" {
+			envCode = ed.Code
+		} else {
+			_ = json.Unmarshal(env.Error, &envCode)
+		}
+	}
+
+	var msg types.AgentMessage
+	if target == targetRoot && json.Unmarshal(raw, &msg) == nil && strings.EqualFold(msg.Type, "error") {
+		if e, ok := msg.Metadata["error"].(map[string]any); ok {
+			if c, ok := e["code"].(string); ok && c != "" {
+				return msg.Type, c
+			}
+		}
+		return msg.Type, firstNonEmpty(envCode, fmt.Sprintf("http_%d", status))
+	}
+	if status/100 != 2 {
+		return msg.Type, firstNonEmpty(envCode, fmt.Sprintf("http_%d", status))
+	}
+	if envCode != "" {
+		return msg.Type, envCode
+	}
+	if target == targetRoot {
+		return msg.Type, ""
+	}
+	return "response", ""
+}
+
+func firstNonEmpty(v ...string) string {
+	for _, s := range v {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepCtx waits d unless ctx ends first; it reports whether ctx is still live.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
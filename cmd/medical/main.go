@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -15,6 +16,11 @@ import (
 	"strconv"
 
 	"github.com/sage-x-project/sage-multi-agent/agents/medical"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
 )
 
 func getenvInt(keys []string, def int) int {
@@ -70,6 +76,10 @@ func main() {
 	// clearer logs
 	log.SetFlags(log.LstdFlags)
 	log.SetPrefix("[medical] ")
+	// --fixtures: point the key env vars at testdata/fixtures before the env-backed defaults are read
+	if _, err := fixtures.Flag(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 
 	// ---------- Flags (ENV as defaults) ----------
 	// Port: prefer EXTERNAL_MEDICAL_PORT, then MEDICAL_AGENT_PORT, default 19082
@@ -100,6 +110,9 @@ func main() {
 			"keys/external-medical.jwk",
 			"keys/external.jwk", // fallback
 		)
+		if filepath.Base(*signJWK) == "external.jwk" {
+			bootreport.Warnf("MEDICAL_JWK_FILE unset and no medical key found; using the shared fallback key %s", *signJWK)
+		}
 	}
 	if *kemJWK == "" {
 		*kemJWK = firstExisting(
@@ -107,6 +120,9 @@ func main() {
 			"keys/kem/external-medical.x25519.jwk",
 			"keys/kem/external.x25519.jwk", // fallback
 		)
+		if filepath.Base(*kemJWK) == "external.x25519.jwk" {
+			bootreport.Warnf("MEDICAL_KEM_JWK_FILE unset and no medical key found; using the shared fallback key %s", *kemJWK)
+		}
 	}
 	if *keysFile == "" {
 		*keysFile = firstExisting(
@@ -126,9 +142,13 @@ func main() {
 	if *keysFile != "" {
 		_ = os.Setenv("HPKE_KEYS_FILE", *keysFile)
 	}
+	if err := fixtures.Guard("medical", *signJWK, *kemJWK, *keysFile); err != nil {
+		log.Fatal(err)
+	}
 
 	// LLM env (shared convention with payment)
 	_ = os.Setenv("LLM_ENABLED", fmt.Sprintf("%v", *llmEnable))
+	flags.Boot("medical.llm", *llmEnable) // registered at package init, before -llm was parsed
 	if *llmURL != "" {
 		_ = os.Setenv("LLM_BASE_URL", *llmURL)
 	}
@@ -143,21 +163,24 @@ func main() {
 	}
 	_ = os.Setenv("LLM_TIMEOUT_MS", strconv.Itoa(*llmTimeout))
 
-	log.Printf("[boot] requireSig=%v  sign-jwk=%q  kem-jwk=%q  keys=%q  llm={enable:%v url:%q model:%q lang:%q timeout:%dms}",
-		*requireSig, os.Getenv("MEDICAL_JWK_FILE"), os.Getenv("MEDICAL_KEM_JWK_FILE"), os.Getenv("HPKE_KEYS_FILE"),
-		*llmEnable, os.Getenv("LLM_BASE_URL"), os.Getenv("LLM_MODEL"), os.Getenv("LLM_LANG_DEFAULT"), *llmTimeout)
-
 	// ---------- Start agent HTTP server ----------
 	agent, err := medical.NewMedicalAgent(*requireSig)
 	if err != nil {
 		log.Fatalf("medical agent init: %v", err)
 	}
 
+	if err := selfid.Run("medical", *port, nil, log.Printf); err != nil {
+		log.Fatal(err)
+	}
+	reqmetrics.StartPushFromEnv(context.Background(), nil)
+
+	// Startup banner + boot report (BOOT_REPORT_FILE / BOOT_REPORT_DIR / stdout)
+	_ = agent.BootReport(*port).Emit(log.Printf)
+
 	addr := fmt.Sprintf(":%d", *port)
-	srv := &http.Server{Addr: addr, Handler: agent.Handler()}
-	log.Printf("listening on %s (HPKE auto by env; lazy-enable supported)", addr)
+	log.Printf("listening on %s (HPKE auto by env; lazy-enable supported; port 0 = ephemeral)", addr)
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := agent.Start(addr); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("listen: %v", err)
 	}
 }
@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -13,8 +14,14 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/sage-x-project/sage-multi-agent/agents/payment"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
 )
 
 func getenvInt(key string, def int) int {
@@ -65,9 +72,14 @@ func main() {
 	// clearer logs
 	log.SetFlags(log.LstdFlags)
 	log.SetPrefix("[payment] ")
+	// --fixtures: point the key env vars at testdata/fixtures before the env-backed defaults are read
+	if _, err := fixtures.Flag(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 
 	// flags (ENV as defaults)
 	port := flag.Int("port", getenvInt("EXTERNAL_PAYMENT_PORT", 19083), "HTTP port for payment server")
+	mode := flag.String("mode", getenvStr("PAYMENT_MODE", payment.ModeFull), "behavior mode: full|verify-only|echo")
 	requireSig := flag.Bool("require", getenvBool("PAYMENT_REQUIRE_SIGNATURE", true), "require RFC9421 signature")
 	signJWK := flag.String("sign-jwk", getenvStr("PAYMENT_JWK_FILE", ""), "Ed25519 signing JWK path (enables HPKE server)")
 	kemJWK := flag.String("kem-jwk", getenvStr("PAYMENT_KEM_JWK_FILE", ""), "X25519 KEM JWK path (enables HPKE server)")
@@ -85,10 +97,14 @@ func main() {
 
 	// ---- Auto-detect defaults if flags/env are empty ----
 	if *signJWK == "" {
-		*signJWK = firstExisting("keys/external.jwk")
+		if *signJWK = firstExisting("keys/external.jwk"); *signJWK != "" {
+			bootreport.Warnf("PAYMENT_JWK_FILE unset; using fallback key %s", *signJWK)
+		}
 	}
 	if *kemJWK == "" {
-		*kemJWK = firstExisting("keys/kem/external.x25519.jwk")
+		if *kemJWK = firstExisting("keys/kem/external.x25519.jwk"); *kemJWK != "" {
+			bootreport.Warnf("PAYMENT_KEM_JWK_FILE unset; using fallback key %s", *kemJWK)
+		}
 	}
 	if *keysFile == "" {
 		*keysFile = firstExisting("merged_agent_keys.json", "generated_agent_keys.json", "keys/merged_agent_keys.json")
@@ -104,9 +120,13 @@ func main() {
 	if *keysFile != "" {
 		_ = os.Setenv("HPKE_KEYS_FILE", *keysFile)
 	}
+	if err := fixtures.Guard("payment", *signJWK, *kemJWK, *keysFile); err != nil {
+		log.Fatal(err)
+	}
 
 	// === Export LLM env for agent (added) ===
 	_ = os.Setenv("LLM_ENABLED", fmt.Sprintf("%v", *llmEnable))
+	flags.Boot("payment.llm", *llmEnable) // registered at package init, before -llm was parsed
 	if *llmURL != "" {
 		_ = os.Setenv("LLM_BASE_URL", *llmURL)
 	}
@@ -121,20 +141,33 @@ func main() {
 	}
 	_ = os.Setenv("LLM_TIMEOUT_MS", strconv.Itoa(*llmTimeout))
 
-	log.Printf("[boot] requireSig=%v  sign-jwk=%q  kem-jwk=%q  keys=%q  llm={enable:%v url:%q model:%q lang:%q timeout:%dms}",
-		*requireSig, os.Getenv("PAYMENT_JWK_FILE"), os.Getenv("PAYMENT_KEM_JWK_FILE"), os.Getenv("HPKE_KEYS_FILE"),
-		*llmEnable, os.Getenv("LLM_BASE_URL"), os.Getenv("LLM_MODEL"), os.Getenv("LLM_LANG_DEFAULT"), *llmTimeout)
+	m, ok := payment.NormalizeMode(*mode)
+	if !ok {
+		log.Fatalf("unknown -mode %q (full|verify-only|echo)", *mode)
+	}
+	// Only legacy aliases are deprecated, not a difference in case or spacing
+	if !strings.EqualFold(m, strings.TrimSpace(*mode)) {
+		log.Printf("[boot] WARN mode %q is deprecated; use %q", *mode, m)
+		bootreport.Warnf("mode %q is deprecated; use %q", *mode, m)
+	}
 
-	agent, err := payment.NewPaymentAgent(*requireSig)
+	agent, err := payment.NewPaymentAgentWithMode(m, *requireSig)
 	if err != nil {
 		log.Fatalf("payment agent init: %v", err)
 	}
 
+	if err := selfid.Run("payment", *port, nil, log.Printf); err != nil {
+		log.Fatal(err)
+	}
+	reqmetrics.StartPushFromEnv(context.Background(), nil)
+
+	// Startup banner + boot report (BOOT_REPORT_FILE / BOOT_REPORT_DIR / stdout)
+	_ = agent.BootReport(*port).Emit(log.Printf)
+
 	addr := fmt.Sprintf(":%d", *port)
-	srv := &http.Server{Addr: addr, Handler: agent.Handler()}
-	log.Printf("listening on %s (HPKE auto by env; lazy-enable supported)", addr)
+	log.Printf("listening on %s (HPKE auto by env; lazy-enable supported; port 0 = ephemeral)", addr)
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := agent.Start(addr); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("listen: %v", err)
 	}
 }
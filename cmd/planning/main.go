@@ -6,17 +6,17 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/sage-x-project/sage-multi-agent/agents/planning"
-	"github.com/sage-x-project/sage-multi-agent/types"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
 )
 
 func getenvInt(key string, def int) int {
@@ -29,40 +29,39 @@ func getenvInt(key string, def int) int {
 }
 
 func main() {
+	// --fixtures: point the key env vars at testdata/fixtures before the env-backed defaults are read
+	if _, err := fixtures.Flag(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 	port := flag.Int("port", getenvInt("PLANNING_AGENT_PORT", 18081), "HTTP port")
 	flag.Parse()
+	if err := fixtures.Guard("planning", os.Getenv("PLANNING_JWK_FILE")); err != nil {
+		log.Fatal(err)
+	}
 
 	agent := planning.NewPlanningAgent("PlanningAgent")
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{
-			"name": "PlanningAgent",
-			"type": "planning-debug",
-			"time": time.Now().Format(time.RFC3339),
-		})
-	})
-	mux.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		var msg types.AgentMessage
-		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
-		}
-		out, err := agent.Process(r.Context(), msg)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	if err := selfid.Run("planning", *port, nil, log.Printf); err != nil {
+		log.Fatal(err)
+	}
+
+	// Startup banner + boot report (BOOT_REPORT_FILE / BOOT_REPORT_DIR / stdout)
+	rep := bootreport.New("planning").Port("http", *port)
+	rep.DID("self", os.Getenv("PLANNING_DID"))
+	rep.Key("signing", os.Getenv("PLANNING_JWK_FILE"))
+	signing := "off"
+	if agent.SAGEEnabled && agent.ExternalURL != "" {
+		signing = "on"
+		if strings.TrimSpace(os.Getenv("PLANNING_JWK_FILE")) == "" {
+			rep.Warn("PLANNING_SAGE_ENABLED with no PLANNING_JWK_FILE: forwarding to %s will fail", agent.ExternalURL)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(out)
-	})
+	}
+	rep.Mode("signing", signing)
+	rep.Upstream("planning.external", agent.ExternalURL)
+	rep.Setting("server", "debug (Root calls the planning agent in-proc)")
+	_ = rep.Emit(log.Printf)
 
 	addr := fmt.Sprintf(":%d", *port)
-	log.Printf("[planning-debug] listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, mux))
+	log.Printf("[planning-debug] listening on %s (port 0 = ephemeral)", addr)
+	log.Fatal(agent.Start(addr))
 }
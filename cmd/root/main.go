@@ -5,11 +5,20 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sage-x-project/sage-multi-agent/agents/root"
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+	"github.com/sage-x-project/sage-multi-agent/internal/reqmetrics"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
+	"github.com/sage-x-project/sage-multi-agent/websocket"
 )
 
 // env-backed defaults
@@ -43,6 +52,10 @@ func main() {
 	// Distinct process prefix for clearer logs
 	log.SetFlags(log.LstdFlags)
 	log.SetPrefix("[root] ")
+	// --fixtures: point the key env vars at testdata/fixtures before the env-backed defaults are read
+	if _, err := fixtures.Flag(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 	// ---- Root flags (env-backed defaults) ----
 	rootName := flag.String("name", getenvStr("ROOT_AGENT_NAME", "root"), "root agent name")
 	rootPort := flag.Int("port", getenvInt("ROOT_AGENT_PORT", 18080), "root agent port")
@@ -61,6 +74,7 @@ func main() {
 	hpke := flag.Bool("hpke", getenvBool("ROOT_HPKE", false), "initialize HPKE to external at startup (root)")
 	hpkeKeys := flag.String("hpke-keys", getenvStr("ROOT_HPKE_KEYS", "merged_agent_keys.json"), "path to DID mapping JSON")
 	hpkeTargets := flag.String("hpke-targets", getenvStr("ROOT_HPKE_TARGETS", "payment"), "comma-separated targets: payment,medical,planning")
+	wsPort := flag.Int("ws-port", getenvInt("ROOT_WS_PORT", 0), "WebSocket log feed port for HPKE state changes (0: off)")
 
 	// === LLM config for Root pre-ask (added) ===
 	llmEnable := flag.Bool("llm", getenvBool("LLM_ENABLED", true), "enable LLM prompts (root pre-ask)")
@@ -70,6 +84,9 @@ func main() {
 	llmLang := flag.String("llm-lang", getenvStr("LLM_LANG_DEFAULT", "auto"), "default language (auto|ko|en)")
 	llmTimeout := flag.Int("llm-timeout", getenvInt("LLM_TIMEOUT_MS", 80000), "LLM timeout in milliseconds")
 
+	// Run the diagnosis battery (same as GET /troubleshoot), print it and exit
+	troubleshoot := flag.Bool("troubleshoot", false, "diagnose the configuration, print a report and exit (1 when not ready)")
+
 	flag.Parse()
 
 	// ---- Export env BEFORE constructing Root (Root reads env on NewRootAgent) ----
@@ -90,6 +107,10 @@ func main() {
 		_ = os.Setenv("ROOT_DID", *rootDID)
 	}
 
+	if err := fixtures.Guard("root", os.Getenv("ROOT_JWK_FILE"), *hpkeKeys); err != nil {
+		log.Fatal(err)
+	}
+
 	// === Export LLM env for Root pre-ask (added) ===
 	_ = os.Setenv("LLM_ENABLED", fmt.Sprintf("%v", *llmEnable))
 	if *llmURL != "" {
@@ -106,8 +127,47 @@ func main() {
 	}
 	_ = os.Setenv("LLM_TIMEOUT_MS", strconv.Itoa(*llmTimeout))
 
+	if *troubleshoot {
+		var targets []string // nil: live sessions only (none yet)
+		if *hpke {
+			targets = []string{}
+			for _, t := range strings.Split(*hpkeTargets, ",") {
+				if t = strings.TrimSpace(strings.ToLower(t)); t != "" {
+					targets = append(targets, t)
+				}
+			}
+		}
+		rep := root.NewRootAgent(*rootName, *rootPort).Troubleshoot(context.Background(), targets)
+		rep.WriteTable(os.Stdout)
+		if !rep.Ready {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// ---- Self-identification: our port and each upstream must answer as expected ----
+	if err := selfid.Run("root", *rootPort, []selfid.Upstream{
+		{Setting: "PAYMENT_URL", Role: "payment", URL: os.Getenv("PAYMENT_URL")},
+		{Setting: "MEDICAL_URL", Role: "medical", URL: os.Getenv("MEDICAL_URL")},
+		{Setting: "PLANNING_EXTERNAL_URL", Role: "planning", URL: os.Getenv("PLANNING_EXTERNAL_URL")},
+		{Setting: "PAYMENT_DIRECT_URL", Role: "payment", URL: os.Getenv("PAYMENT_DIRECT_URL")},
+		{Setting: "MEDICAL_DIRECT_URL", Role: "medical", URL: os.Getenv("MEDICAL_DIRECT_URL")},
+		{Setting: "PLANNING_DIRECT_URL", Role: "planning", URL: os.Getenv("PLANNING_DIRECT_URL")},
+	}, log.Printf); err != nil {
+		log.Fatal(err)
+	}
+	reqmetrics.StartPushFromEnv(context.Background(), nil)
+
 	// ---- Root ----
 	r := root.NewRootAgent(*rootName, *rootPort)
+	if *wsPort > 0 {
+		ls := websocket.NewLogServer(*wsPort)
+		if err := ls.Start(); err != nil {
+			log.Fatal(err)
+		}
+		r.SetLogBroadcaster(ls)
+		log.Printf("[root] WebSocket log feed on :%d/ws", *wsPort)
+	}
 
 	// Optional: initialize HPKE sessions for targets at startup
 	if *hpke {
@@ -120,6 +180,7 @@ func main() {
 			}
 			if err := r.EnableHPKE(context.Background(), tgt, keys); err != nil {
 				log.Printf("[root] HPKE init FAILED target=%s: %v", tgt, err)
+				bootreport.Warnf("HPKE init failed for %s: %v", tgt, err)
 			} else {
 				log.Printf("[root] HPKE init OK target=%s (keys=%s)", tgt, keys)
 			}
@@ -128,16 +189,22 @@ func main() {
 		log.Printf("[root] HPKE disabled at startup")
 	}
 
-	log.Printf(
-		"[boot] root:%d  ext{planning=%s medical=%s payment=%s}  SAGE=%v  llm={enable:%v url:%q model:%q lang:%q timeout:%dms}",
-		*rootPort,
-		os.Getenv("PLANNING_EXTERNAL_URL"),
-		os.Getenv("MEDICAL_URL"),
-		os.Getenv("PAYMENT_URL"),
-		*sage,
-		*llmEnable, os.Getenv("LLM_BASE_URL"), os.Getenv("LLM_MODEL"), os.Getenv("LLM_LANG_DEFAULT"), *llmTimeout,
-	)
-	if err := r.Start(); err != nil {
+	// ---- Startup banner + boot report (BOOT_REPORT_FILE / BOOT_REPORT_DIR / stdout) ----
+	_ = r.BootReport().Emit(log.Printf)
+
+	// Graceful shutdown: stop HTTP and drain background work
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.Shutdown(ctx); err != nil {
+			log.Printf("[root] shutdown: %v", err)
+		}
+	}()
+
+	if err := r.Start(); err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 }
@@ -4,12 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"os"
-	"strings"
 
 	// a2a-go: DID verifier, key selector, RFC9421 verifier interfaces/implementations
 	"github.com/sage-x-project/sage-a2a-go/pkg/server"
-	"github.com/sage-x-project/sage/pkg/agent/did"
 	dideth "github.com/sage-x-project/sage/pkg/agent/did/ethereum"
 )
 
@@ -18,43 +15,22 @@ type DIDAuth struct {
 	Mw *server.DIDAuthMiddleware
 }
 
-// ETH_RPC_URL               (default: http://127.0.0.1:8545)
-// SAGE_REGISTRY_ADDRESS  (default: 0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512)
-// SAGE_OPERATOR_PRIVATE_KEY (default: 0xac0974...ff80)  // hex; 0x prefix allowed
+// BuildDIDMiddleware reads the registry settings from env (RegistryConfigFromEnv)
 func BuildDIDMiddleware(optional bool) (*server.DIDAuthMiddleware, error) {
 
-	// Read envs with hard defaults.
-	rpc := strings.TrimSpace(os.Getenv("ETH_RPC_URL"))
-	if rpc == "" {
-		rpc = "http://127.0.0.1:8545"
-	}
-	contract := strings.TrimSpace(os.Getenv("SAGE_REGISTRY_ADDRESS"))
-	if contract == "" {
-		contract = "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"
-	}
-	priv := strings.TrimSpace(os.Getenv("SAGE_EXTERNAL_KEY"))
-	if priv == "" {
-		priv = "0x47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a"
-	}
-	priv = strings.TrimPrefix(priv, "0x") // normalize
+	cfg := RegistryConfigFromEnv("0x47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a")
 
-	cfg := &did.RegistryConfig{
-		RPCEndpoint:        rpc,
-		ContractAddress:    contract,
-		PrivateKey:         priv, // optional for read-only resolve; required for tx-signed ops
-		GasPrice:           0,    // use node suggestion
-		MaxRetries:         24,
-		ConfirmationBlocks: 0,
-	}
+	// Both clients come from the process-wide pool and live as long as the
+	// middleware (the process), so the leases are never released.
 
 	// 1) V4 AgentCard resolver (GetAgentByDID)
-	resolver, err := dideth.NewAgentCardClient(cfg)
+	resolver, _, err := Shared(KindAgentCard, cfg, dideth.NewAgentCardClient)
 	if err != nil {
 		panic(err)
 	}
 
 	// 2) Public key client (ResolvePublicKey / ResolveKEMKey)
-	client, err := dideth.NewEthereumClient(cfg)
+	client, _, err := Shared(KindEthereum, cfg, dideth.NewEthereumClient)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,57 @@
+package a2autil
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/ethpool"
+	"github.com/sage-x-project/sage/pkg/agent/did"
+)
+
+// Pooled client kinds (see Shared).
+const (
+	KindEthereum  = "ethereum"  // dideth.NewEthereumClient: public/KEM key resolution
+	KindAgentCard = "agentcard" // dideth.NewAgentCardClient: V4 AgentCard lookups
+)
+
+// RegistryConfigFromEnv reads the registry settings every component shares:
+//
+//	ETH_RPC_URL           (default: http://127.0.0.1:8545)
+//	SAGE_REGISTRY_ADDRESS (default: 0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512)
+//	SAGE_EXTERNAL_KEY     (default: defaultKey; hex, 0x prefix allowed)
+func RegistryConfigFromEnv(defaultKey string) *did.RegistryConfig {
+	rpc := strings.TrimSpace(os.Getenv("ETH_RPC_URL"))
+	if rpc == "" {
+		rpc = "http://127.0.0.1:8545"
+	}
+	contract := strings.TrimSpace(os.Getenv("SAGE_REGISTRY_ADDRESS"))
+	if contract == "" {
+		contract = "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"
+	}
+	priv := strings.TrimSpace(os.Getenv("SAGE_EXTERNAL_KEY"))
+	if priv == "" {
+		priv = defaultKey
+	}
+	return &did.RegistryConfig{
+		RPCEndpoint:        rpc,
+		ContractAddress:    contract,
+		PrivateKey:         strings.TrimPrefix(priv, "0x"), // optional for read-only resolve; required for tx-signed ops
+		GasPrice:           0,                              // use node suggestion
+		MaxRetries:         24,
+		ConfirmationBlocks: 0,
+	}
+}
+
+// Shared returns the process-wide client of kind for cfg's RPC endpoint and
+// registry contract, dialing it with dial only when no one holds it yet. Call
+// release when the consumer shuts down; the last release closes the client.
+// Resolution is read-only, so consumers with different keys share a client.
+func Shared[T any](kind string, cfg *did.RegistryConfig, dial func(*did.RegistryConfig) (T, error)) (client T, release func(), err error) {
+	lease, err := ethpool.Default().Acquire(ethpool.Key{Kind: kind, RPC: cfg.RPCEndpoint, Contract: cfg.ContractAddress},
+		func() (any, error) { return dial(cfg) })
+	if err != nil {
+		return client, func() {}, err
+	}
+	client, _ = lease.Value().(T)
+	return client, lease.Release, nil
+}
@@ -0,0 +1,505 @@
+// Package alert pages operators about critical security events. Alerts are
+// classified with a severity and dispatched to pluggable sinks (console,
+// HMAC-signed webhook, SMTP email), each with its own minimum severity, rate
+// limit and deduplication window, so a flapping upstream produces one alert
+// per window instead of one per request.
+//
+// Env:
+//
+//	ALERT_SINKS_FILE      JSON array of SinkConfig (replaces the variables below)
+//	ALERT_CONSOLE         console sink on/off (default true)
+//	ALERT_WEBHOOK_URL     generic webhook sink (POST application/json)
+//	ALERT_WEBHOOK_SECRET  HMAC-SHA256 key for X-SAGE-Alert-Signature
+//	ALERT_SMTP_ADDR       host:port of the SMTP relay (enables the email sink)
+//	ALERT_SMTP_FROM       envelope/header sender
+//	ALERT_SMTP_TO         comma-separated recipients
+//	ALERT_SMTP_USER       optional PLAIN auth user (password: ALERT_SMTP_PASSWORD)
+//	ALERT_MIN_SEVERITY    default minimum severity for env-configured sinks (default warning)
+//	ALERT_RATE_LIMIT      default max alerts per sink per window (default 10; 0 = unlimited)
+//	ALERT_RATE_WINDOW     rate limit window (default 1m)
+//	ALERT_DEDUPE_WINDOW   default dedupe window per sink (default 10m)
+//
+// Raise never blocks request paths: alerts go through a bounded channel and
+// are dropped (and counted) when delivery falls behind. The last 100 alerts
+// with their per-sink outcome are kept for the admin endpoint.
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Severity orders alerts; sinks drop alerts below their minimum.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseSeverity maps a string onto a Severity (unknown: info).
+func ParseSeverity(s string) Severity {
+	switch Severity(strings.ToLower(strings.TrimSpace(s))) {
+	case SeverityCritical:
+		return SeverityCritical
+	case SeverityWarning:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// Alert is one notification. Kind is a stable machine name
+// ("tamper.suspected", "hpke.pin_mismatch", ...); DedupeKey defaults to
+// Kind + Target.
+type Alert struct {
+	ID        string         `json:"id"`
+	Time      time.Time      `json:"ts"`
+	Agent     string         `json:"agent"`
+	Kind      string         `json:"kind"`
+	Severity  Severity       `json:"severity"`
+	Title     string         `json:"title"`
+	Target    string         `json:"target,omitempty"`
+	CID       string         `json:"cid,omitempty"`
+	Detail    map[string]any `json:"detail,omitempty"`
+	DedupeKey string         `json:"dedupeKey"`
+}
+
+// Delivery outcomes recorded per sink.
+const (
+	OutcomeSent        = "sent"
+	OutcomeDeduped     = "deduped"
+	OutcomeRateLimited = "rate_limited"
+	OutcomeBelowMin    = "below_min_severity"
+	OutcomeFailed      = "failed"
+)
+
+// Delivery is what one sink did with an alert.
+type Delivery struct {
+	Sink    string `json:"sink"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Record is a history entry.
+type Record struct {
+	Alert      Alert      `json:"alert"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Sink delivers alerts somewhere.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, a Alert) error
+}
+
+// SinkConfig configures one sink (ALERT_SINKS_FILE entries).
+type SinkConfig struct {
+	Type        string `json:"type"` // console | webhook | email
+	Name        string `json:"name,omitempty"`
+	MinSeverity string `json:"minSeverity,omitempty"`
+	RateLimit   int    `json:"rateLimit,omitempty"`    // alerts per RateWindow (0: default)
+	RateWindow  string `json:"rateWindow,omitempty"`   // e.g. "1m"
+	DedupeFor   string `json:"dedupeWindow,omitempty"` // e.g. "10m"; "0s" disables
+
+	// webhook
+	URL    string `json:"url,omitempty"`
+	Secret string `json:"secret,omitempty"`
+
+	// email
+	SMTPAddr string   `json:"smtpAddr,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	User     string   `json:"user,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+// Policy is a sink's filtering: minimum severity, rate limit, dedupe.
+type Policy struct {
+	MinSeverity Severity
+	RateLimit   int
+	RateWindow  time.Duration
+	DedupeFor   time.Duration
+}
+
+const historySize = 100
+
+// sinkState wraps a sink with its policy and bookkeeping.
+type sinkState struct {
+	sink   Sink
+	policy Policy
+
+	mu       sync.Mutex
+	sentAt   []time.Time          // sends inside the current rate window
+	lastSent map[string]time.Time // dedupe key -> last send
+	counts   map[string]int64     // outcome -> count
+}
+
+// admit applies the policy and reserves a send slot.
+func (s *sinkState) admit(a Alert, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.Severity.rank() < s.policy.MinSeverity.rank() {
+		return OutcomeBelowMin
+	}
+	if s.policy.DedupeFor > 0 {
+		if t, ok := s.lastSent[a.DedupeKey]; ok && now.Sub(t) < s.policy.DedupeFor {
+			return OutcomeDeduped
+		}
+	}
+	if s.policy.RateLimit > 0 {
+		keep := s.sentAt[:0]
+		for _, t := range s.sentAt {
+			if now.Sub(t) < s.policy.RateWindow {
+				keep = append(keep, t)
+			}
+		}
+		s.sentAt = keep
+		if len(s.sentAt) >= s.policy.RateLimit {
+			return OutcomeRateLimited
+		}
+		s.sentAt = append(s.sentAt, now)
+	}
+	s.lastSent[a.DedupeKey] = now
+	return OutcomeSent
+}
+
+func (s *sinkState) count(outcome string) {
+	s.mu.Lock()
+	s.counts[outcome]++
+	s.mu.Unlock()
+}
+
+// Dispatcher fans alerts out to sinks.
+type Dispatcher struct {
+	agent  string
+	logger *log.Logger
+	sinks  []*sinkState
+
+	ch      chan Alert
+	seq     atomic.Int64
+	raised  atomic.Int64
+	dropped atomic.Int64
+
+	hmu     sync.Mutex
+	history []Record // ring, oldest first
+
+	stop    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+}
+
+// New starts a Dispatcher without sinks (alerts are only kept in history
+// until AddSink).
+func New(agent string, logger *log.Logger) *Dispatcher {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[alert] ", log.LstdFlags)
+	}
+	d := &Dispatcher{
+		agent:  agent,
+		logger: logger,
+		ch:     make(chan Alert, 256),
+		stop:   make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.loop()
+	return d
+}
+
+// AddSink registers s with policy p. Call before alerts are raised.
+func (d *Dispatcher) AddSink(s Sink, p Policy) {
+	if p.RateWindow <= 0 {
+		p.RateWindow = time.Minute
+	}
+	d.sinks = append(d.sinks, &sinkState{
+		sink: s, policy: p,
+		lastSent: map[string]time.Time{},
+		counts:   map[string]int64{},
+	})
+}
+
+// FromEnv builds a Dispatcher for agent from ALERT_* env (see package doc).
+func FromEnv(agent string, logger *log.Logger) *Dispatcher {
+	d := New(agent, logger)
+	cfgs, err := ConfigsFromEnv()
+	if err != nil {
+		d.logger.Printf("[alert] %v; falling back to console", err)
+		cfgs = []SinkConfig{{Type: "console"}}
+	}
+	def := defaultPolicy()
+	for _, c := range cfgs {
+		s, p, err := BuildSink(c, def, d.logger)
+		if err != nil {
+			d.logger.Printf("[alert] sink %q skipped: %v", firstNonEmpty(c.Name, c.Type), err)
+			continue
+		}
+		d.AddSink(s, p)
+	}
+	return d
+}
+
+// ConfigsFromEnv reads ALERT_SINKS_FILE or the single-sink variables.
+func ConfigsFromEnv() ([]SinkConfig, error) {
+	if path := strings.TrimSpace(os.Getenv("ALERT_SINKS_FILE")); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ALERT_SINKS_FILE: %w", err)
+		}
+		var cfgs []SinkConfig
+		if err := json.Unmarshal(b, &cfgs); err != nil {
+			return nil, fmt.Errorf("ALERT_SINKS_FILE %s: %w", path, err)
+		}
+		return cfgs, nil
+	}
+	var cfgs []SinkConfig
+	if v := strings.TrimSpace(os.Getenv("ALERT_CONSOLE")); v == "" || parseBool(v) {
+		cfgs = append(cfgs, SinkConfig{Type: "console"})
+	}
+	if u := strings.TrimSpace(os.Getenv("ALERT_WEBHOOK_URL")); u != "" {
+		cfgs = append(cfgs, SinkConfig{Type: "webhook", URL: u, Secret: os.Getenv("ALERT_WEBHOOK_SECRET")})
+	}
+	if a := strings.TrimSpace(os.Getenv("ALERT_SMTP_ADDR")); a != "" {
+		cfgs = append(cfgs, SinkConfig{
+			Type: "email", SMTPAddr: a,
+			From: strings.TrimSpace(os.Getenv("ALERT_SMTP_FROM")),
+			To:   splitList(os.Getenv("ALERT_SMTP_TO")),
+			User: strings.TrimSpace(os.Getenv("ALERT_SMTP_USER")), Password: os.Getenv("ALERT_SMTP_PASSWORD"),
+		})
+	}
+	return cfgs, nil
+}
+
+func defaultPolicy() Policy {
+	p := Policy{
+		MinSeverity: SeverityWarning,
+		RateLimit:   10,
+		RateWindow:  time.Minute,
+		DedupeFor:   10 * time.Minute,
+	}
+	if v := strings.TrimSpace(os.Getenv("ALERT_MIN_SEVERITY")); v != "" {
+		p.MinSeverity = ParseSeverity(v)
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("ALERT_RATE_LIMIT"))); err == nil && n >= 0 {
+		p.RateLimit = n
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("ALERT_RATE_WINDOW"))); err == nil && d > 0 {
+		p.RateWindow = d
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("ALERT_DEDUPE_WINDOW"))); err == nil && d >= 0 {
+		p.DedupeFor = d
+	}
+	return p
+}
+
+// BuildSink turns c into a sink; unset policy fields fall back to def.
+func BuildSink(c SinkConfig, def Policy, logger *log.Logger) (Sink, Policy, error) {
+	p := def
+	if c.MinSeverity != "" {
+		p.MinSeverity = ParseSeverity(c.MinSeverity)
+	}
+	if c.RateLimit > 0 {
+		p.RateLimit = c.RateLimit
+	}
+	if c.RateWindow != "" {
+		d, err := time.ParseDuration(c.RateWindow)
+		if err != nil || d <= 0 {
+			return nil, p, fmt.Errorf("rateWindow %q", c.RateWindow)
+		}
+		p.RateWindow = d
+	}
+	if c.DedupeFor != "" {
+		d, err := time.ParseDuration(c.DedupeFor)
+		if err != nil || d < 0 {
+			return nil, p, fmt.Errorf("dedupeWindow %q", c.DedupeFor)
+		}
+		p.DedupeFor = d
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "console", "":
+		if c.MinSeverity == "" {
+			p.MinSeverity = SeverityInfo
+		}
+		return &ConsoleSink{Logger: logger}, p, nil
+	case "webhook":
+		if strings.TrimSpace(c.URL) == "" {
+			return nil, p, fmt.Errorf("webhook: url required")
+		}
+		return NewWebhookSink(firstNonEmpty(c.Name, "webhook"), c.URL, c.Secret), p, nil
+	case "email", "smtp":
+		if c.SMTPAddr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, p, fmt.Errorf("email: smtpAddr, from and to required")
+		}
+		return &SMTPSink{SinkName: firstNonEmpty(c.Name, "email"), Addr: c.SMTPAddr, From: c.From, To: c.To, User: c.User, Password: c.Password}, p, nil
+	default:
+		return nil, p, fmt.Errorf("unknown type %q", c.Type)
+	}
+}
+
+// Raise queues a. It never blocks.
+func (d *Dispatcher) Raise(a Alert) {
+	if d == nil {
+		return
+	}
+	if a.Time.IsZero() {
+		a.Time = time.Now().UTC()
+	}
+	if a.Agent == "" {
+		a.Agent = d.agent
+	}
+	if a.Severity == "" {
+		a.Severity = SeverityWarning
+	}
+	if a.DedupeKey == "" {
+		a.DedupeKey = a.Kind + "|" + a.Target
+	}
+	if a.ID == "" {
+		a.ID = fmt.Sprintf("%s-%d-%d", d.agent, a.Time.UnixNano(), d.seq.Add(1))
+	}
+	d.raised.Add(1)
+	select {
+	case d.ch <- a:
+	default:
+		if n := d.dropped.Add(1); n%100 == 1 {
+			d.logger.Printf("[alert] queue full; dropped=%d", n)
+		}
+	}
+}
+
+func (d *Dispatcher) loop() {
+	defer d.wg.Done()
+	for {
+		select {
+		case a := <-d.ch:
+			d.dispatch(a)
+		case <-d.stop:
+			for {
+				select {
+				case a := <-d.ch:
+					d.dispatch(a)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(a Alert) {
+	rec := Record{Alert: a, Deliveries: make([]Delivery, 0, len(d.sinks))}
+	now := time.Now()
+	for _, s := range d.sinks {
+		dl := Delivery{Sink: s.sink.Name(), Outcome: s.admit(a, now)}
+		if dl.Outcome == OutcomeSent {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := s.sink.Send(ctx, a); err != nil {
+				dl.Outcome, dl.Error = OutcomeFailed, err.Error()
+				d.logger.Printf("[alert] sink=%s kind=%s: %v", dl.Sink, a.Kind, err)
+			}
+			cancel()
+		}
+		s.count(dl.Outcome)
+		rec.Deliveries = append(rec.Deliveries, dl)
+	}
+	d.hmu.Lock()
+	d.history = append(d.history, rec)
+	if len(d.history) > historySize {
+		d.history = append([]Record(nil), d.history[len(d.history)-historySize:]...)
+	}
+	d.hmu.Unlock()
+}
+
+// History returns the most recent alerts, newest first.
+func (d *Dispatcher) History() []Record {
+	if d == nil {
+		return nil
+	}
+	d.hmu.Lock()
+	defer d.hmu.Unlock()
+	out := make([]Record, len(d.history))
+	for i, r := range d.history {
+		out[len(out)-1-i] = r
+	}
+	return out
+}
+
+// Stats returns counters for status endpoints.
+func (d *Dispatcher) Stats() map[string]any {
+	if d == nil {
+		return map[string]any{"sinks": 0}
+	}
+	sinks := make([]map[string]any, 0, len(d.sinks))
+	for _, s := range d.sinks {
+		s.mu.Lock()
+		counts := make(map[string]int64, len(s.counts))
+		for k, v := range s.counts {
+			counts[k] = v
+		}
+		s.mu.Unlock()
+		sinks = append(sinks, map[string]any{
+			"name":        s.sink.Name(),
+			"minSeverity": s.policy.MinSeverity,
+			"rateLimit":   s.policy.RateLimit,
+			"rateWindow":  s.policy.RateWindow.String(),
+			"dedupe":      s.policy.DedupeFor.String(),
+			"outcomes":    counts,
+		})
+	}
+	return map[string]any{
+		"raised":  d.raised.Load(),
+		"dropped": d.dropped.Load(),
+		"sinks":   sinks,
+	}
+}
+
+// Close delivers queued alerts and stops the dispatcher.
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.stopped.Do(func() { close(d.stop) })
+	d.wg.Wait()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	return err == nil && b
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
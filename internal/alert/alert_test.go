@@ -0,0 +1,147 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func quiet() *log.Logger { return log.New(io.Discard, "", 0) }
+
+// memSink records what it was asked to send.
+type memSink struct {
+	mu   sync.Mutex
+	sent []Alert
+}
+
+func (m *memSink) Name() string { return "mem" }
+
+func (m *memSink) Send(_ context.Context, a Alert) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, a)
+	m.mu.Unlock()
+	return nil
+}
+
+// The webhook body is signed with the shared secret; the receiver can verify
+// it and a changed body fails.
+func TestWebhookSignature(t *testing.T) {
+	var (
+		body []byte
+		hdr  http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		hdr = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	a := Alert{ID: "a-1", Kind: "tamper.suspected", Severity: SeverityCritical, Target: "payment"}
+	if err := NewWebhookSink("hook", srv.URL, "s3cret").Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	sig := hdr.Get(HeaderSignature)
+	if !strings.HasPrefix(sig, "sha256=") || hdr.Get(HeaderAlertID) != "a-1" {
+		t.Fatalf("headers %v", hdr)
+	}
+	if !Verify("s3cret", body, sig) || Verify("other", body, sig) || Verify("s3cret", append(body, ' '), sig) {
+		t.Fatal("signature does not bind the secret and the body")
+	}
+	var got Alert
+	if json.Unmarshal(body, &got) != nil || got.Kind != "tamper.suspected" {
+		t.Fatalf("body %s", body)
+	}
+
+	// no secret, no signature header; a non-2xx is a failure
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr = r.Header.Clone()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	if err := NewWebhookSink("hook", bad.URL, "").Send(context.Background(), a); err == nil || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Fatalf("err %v", err)
+	}
+	if hdr.Get(HeaderSignature) != "" {
+		t.Fatal("unsigned sink sent a signature")
+	}
+}
+
+// One alert per dedupe key per window, at most RateLimit per rate window, and
+// nothing below the sink's minimum severity.
+func TestPolicy(t *testing.T) {
+	s := &sinkState{sink: &memSink{}, policy: Policy{MinSeverity: SeverityWarning, RateLimit: 2, RateWindow: time.Minute, DedupeFor: 10 * time.Minute},
+		lastSent: map[string]time.Time{}, counts: map[string]int64{}}
+	t0 := time.Now()
+	alert := func(key string, sev Severity) Alert { return Alert{DedupeKey: key, Severity: sev} }
+	steps := []struct {
+		a    Alert
+		at   time.Duration
+		want string
+	}{
+		{alert("breaker|payment", SeverityCritical), 0, OutcomeSent},
+		{alert("breaker|payment", SeverityCritical), time.Second, OutcomeDeduped},
+		{alert("tamper|payment", SeverityInfo), time.Second, OutcomeBelowMin},
+		{alert("tamper|payment", SeverityWarning), 2 * time.Second, OutcomeSent},
+		{alert("pin|medical", SeverityCritical), 3 * time.Second, OutcomeRateLimited},
+		{alert("pin|medical", SeverityCritical), 61 * time.Second, OutcomeSent},
+		{alert("breaker|payment", SeverityCritical), 11 * time.Minute, OutcomeSent},
+	}
+	for i, st := range steps {
+		if got := s.admit(st.a, t0.Add(st.at)); got != st.want {
+			t.Fatalf("step %d (%s at %v): %s, want %s", i, st.a.DedupeKey, st.at, got, st.want)
+		}
+	}
+}
+
+// Raise fills in the defaults; the dispatcher records each sink's outcome and
+// keeps only the newest historySize alerts, newest first.
+func TestDispatcherHistory(t *testing.T) {
+	d := New("root", quiet())
+	mem := &memSink{}
+	d.AddSink(mem, Policy{MinSeverity: SeverityInfo, DedupeFor: time.Hour})
+	for i := 0; i < historySize+20; i++ {
+		d.Raise(Alert{Kind: "breaker.open", Target: "payment", Detail: map[string]any{"n": i}})
+	}
+	d.Close()
+
+	h := d.History()
+	if len(h) != historySize {
+		t.Fatalf("history %d", len(h))
+	}
+	if h[0].Alert.Detail["n"] != historySize+19 || h[len(h)-1].Alert.Detail["n"] != 20 {
+		t.Fatalf("history order: newest %v oldest %v", h[0].Alert.Detail, h[len(h)-1].Alert.Detail)
+	}
+	if len(mem.sent) != 1 || mem.sent[0].Agent != "root" || mem.sent[0].Severity != SeverityWarning || mem.sent[0].DedupeKey != "breaker.open|payment" || mem.sent[0].ID == "" {
+		t.Fatalf("sent %+v", mem.sent)
+	}
+	if dl := h[0].Deliveries; len(dl) != 1 || dl[0].Sink != "mem" || dl[0].Outcome != OutcomeDeduped {
+		t.Fatalf("deliveries %+v", dl)
+	}
+	sinks := d.Stats()["sinks"].([]map[string]any)
+	if out := sinks[0]["outcomes"].(map[string]int64); out[OutcomeSent] != 1 || out[OutcomeDeduped] != int64(historySize+19) {
+		t.Fatalf("outcomes %v", out)
+	}
+}
+
+func TestBuildSink(t *testing.T) {
+	def := Policy{MinSeverity: SeverityWarning, RateLimit: 10, RateWindow: time.Minute, DedupeFor: 10 * time.Minute}
+	s, p, err := BuildSink(SinkConfig{Type: "webhook", Name: "pager", URL: "http://x", MinSeverity: "critical", DedupeFor: "0s"}, def, quiet())
+	if err != nil || s.Name() != "pager" || p.MinSeverity != SeverityCritical || p.DedupeFor != 0 || p.RateLimit != 10 {
+		t.Fatalf("webhook: %v %+v", err, p)
+	}
+	if _, p, _ := BuildSink(SinkConfig{Type: "console"}, def, quiet()); p.MinSeverity != SeverityInfo {
+		t.Fatalf("console min %s", p.MinSeverity)
+	}
+	for _, c := range []SinkConfig{{Type: "webhook"}, {Type: "email", SMTPAddr: "x:25"}, {Type: "pager"}, {Type: "console", RateWindow: "soon"}} {
+		if _, _, err := BuildSink(c, def, quiet()); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConsoleSink logs alerts (the default sink).
+type ConsoleSink struct {
+	Logger *log.Logger
+}
+
+func (c *ConsoleSink) Name() string { return "console" }
+
+func (c *ConsoleSink) Send(_ context.Context, a Alert) error {
+	detail, _ := json.Marshal(a.Detail)
+	c.Logger.Printf("[alert][%s] ⚠️ %s kind=%s target=%s cid=%s detail=%s", a.Severity, a.Title, a.Kind, a.Target, a.CID, detail)
+	return nil
+}
+
+// Webhook headers. The signature is "sha256=" + hex(HMAC-SHA256(secret, body)).
+const (
+	HeaderSignature = "X-SAGE-Alert-Signature"
+	HeaderAlertID   = "X-SAGE-Alert-ID"
+)
+
+// Sign returns the X-SAGE-Alert-Signature value for body.
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether sig is the signature of body under secret.
+func Verify(secret string, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(strings.TrimSpace(sig)))
+}
+
+// WebhookSink POSTs the alert as JSON; any 2xx is success.
+type WebhookSink struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookSink(name, url, secret string) *WebhookSink {
+	return &WebhookSink{name: name, url: url, secret: secret, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *WebhookSink) Name() string { return s.name }
+
+func (s *WebhookSink) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderAlertID, a.ID)
+	if s.secret != "" {
+		req.Header.Set(HeaderSignature, Sign(s.secret, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: HTTP %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPSink sends a plain-text email through a relay (net/smtp; PLAIN auth
+// when User is set, STARTTLS when the server offers it).
+type SMTPSink struct {
+	SinkName string
+	Addr     string
+	From     string
+	To       []string
+	User     string
+	Password string
+}
+
+func (s *SMTPSink) Name() string { return s.SinkName }
+
+func (s *SMTPSink) Send(_ context.Context, a Alert) error {
+	var auth smtp.Auth
+	if s.User != "" {
+		host := s.Addr
+		if i := strings.LastIndexByte(host, ':'); i > 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.User, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, s.To, s.message(a))
+}
+
+func (s *SMTPSink) message(a Alert) []byte {
+	var b strings.Builder
+	subject := fmt.Sprintf("[%s][%s] %s", strings.ToUpper(string(a.Severity)), a.Agent, a.Title)
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", a.Title)
+	fmt.Fprintf(&b, "kind:     %s\r\nseverity: %s\r\nagent:    %s\r\n", a.Kind, a.Severity, a.Agent)
+	if a.Target != "" {
+		fmt.Fprintf(&b, "target:   %s\r\n", a.Target)
+	}
+	if a.CID != "" {
+		fmt.Fprintf(&b, "cid:      %s\r\n", a.CID)
+	}
+	fmt.Fprintf(&b, "time:     %s\r\nid:       %s\r\n", a.Time.Format(time.RFC3339), a.ID)
+	if len(a.Detail) > 0 {
+		b.WriteString("\r\n")
+		keys := make([]string, 0, len(a.Detail))
+		for k := range a.Detail {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s: %v\r\n", k, a.Detail[k])
+		}
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,267 @@
+// Package async provides a small bounded executor shared by agents for
+// background work (callbacks, notifications, probes, HPKE retries).
+// Each named pool caps concurrency and queue depth, recovers panics, and
+// exposes gauges so stuck work is visible instead of leaking goroutines.
+// Names are unique per process: a second live pool asking for a taken name
+// is registered as "name#2", "name#3", ... (see Name).
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when the pool queue is at capacity.
+	ErrQueueFull = errors.New("async: queue full")
+	// ErrPoolClosed is returned by Submit after Drain has been called.
+	ErrPoolClosed = errors.New("async: pool closed")
+)
+
+// Stats is a point-in-time snapshot of a pool's gauges/counters.
+type Stats struct {
+	Name      string `json:"name"`
+	Workers   int    `json:"workers"`
+	QueueCap  int    `json:"queueCap"`
+	Active    int64  `json:"active"`
+	Queued    int64  `json:"queued"`
+	Completed int64  `json:"completed"`
+	Failed    int64  `json:"failed"`
+	Panics    int64  `json:"panics"`
+	Rejected  int64  `json:"rejected"`
+}
+
+// Task is a unit of background work. Returning an error counts as failed.
+type Task func(ctx context.Context) error
+
+// Pool is a named, bounded worker pool.
+type Pool struct {
+	name    string
+	workers int
+	logger  *log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Task
+	wg     sync.WaitGroup
+
+	active    atomic.Int64
+	queued    atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+	panics    atomic.Int64
+	rejected  atomic.Int64
+}
+
+// NewPool starts a pool with the given concurrency and queue depth.
+// Non-positive values fall back to 1 worker / 64 queued tasks.
+func NewPool(name string, workers, queueDepth int, logger *log.Logger) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueDepth <= 0 {
+		queueDepth = 64
+	}
+	if logger == nil {
+		logger = log.New(os.Stdout, "[async] ", log.LstdFlags)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		name:    name,
+		workers: workers,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		queue:   make(chan Task, queueDepth),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.loop()
+	}
+	register(p)
+	return p
+}
+
+// Name returns the pool name as registered (with a "#n" suffix when another
+// live pool already had the requested one).
+func (p *Pool) Name() string { return p.name }
+
+// Submit enqueues a task without blocking. It fails fast when the queue is full
+// or the pool is draining so callers on request paths never stall.
+func (p *Pool) Submit(t Task) error {
+	if t == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.rejected.Add(1)
+		return ErrPoolClosed
+	}
+	select {
+	case p.queue <- t:
+		p.queued.Add(1)
+		return nil
+	default:
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Go is a convenience wrapper for tasks that don't report errors.
+func (p *Pool) Go(fn func(ctx context.Context)) error {
+	return p.Submit(func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	})
+}
+
+// Every runs fn on the pool every interval until stop is closed or the pool
+// drains. No goroutine or worker is held between runs; the next run is
+// scheduled when the previous one returns. A tick that finds the queue full
+// is skipped (counted as rejected) and tried again one interval later.
+func (p *Pool) Every(interval time.Duration, stop <-chan struct{}, fn func(ctx context.Context)) {
+	var tick func()
+	tick = func() {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		err := p.Go(func(ctx context.Context) {
+			defer time.AfterFunc(interval, tick)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			fn(ctx)
+		})
+		if errors.Is(err, ErrQueueFull) {
+			time.AfterFunc(interval, tick)
+		}
+	}
+	time.AfterFunc(interval, tick)
+}
+
+func (p *Pool) loop() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		p.queued.Add(-1)
+		p.run(t)
+	}
+}
+
+func (p *Pool) run(t Task) {
+	p.active.Add(1)
+	defer p.active.Add(-1)
+	defer func() {
+		if rec := recover(); rec != nil {
+			p.panics.Add(1)
+			p.failed.Add(1)
+			p.logger.Printf("[async][%s][panic] %v\n%s", p.name, rec, debug.Stack())
+		}
+	}()
+	if err := t(p.ctx); err != nil {
+		p.failed.Add(1)
+		p.logger.Printf("[async][%s][fail] %v", p.name, err)
+		return
+	}
+	p.completed.Add(1)
+}
+
+// Stats returns the current gauges/counters.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Name:      p.name,
+		Workers:   p.workers,
+		QueueCap:  cap(p.queue),
+		Active:    p.active.Load(),
+		Queued:    p.queued.Load(),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+		Panics:    p.panics.Load(),
+		Rejected:  p.rejected.Load(),
+	}
+}
+
+// Drain stops accepting new work and waits for queued and in-flight tasks.
+// If ctx expires first, the task context is cancelled and ctx.Err() is returned.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		unregister(p)
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		s := p.Stats()
+		return fmt.Errorf("async: drain %s: %w (active=%d queued=%d)", p.name, ctx.Err(), s.Active, s.Queued)
+	}
+}
+
+// ---- process-wide registry (for status/metrics endpoints) ----
+
+var (
+	regMu sync.Mutex
+	reg   = map[string]*Pool{}
+)
+
+func register(p *Pool) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	name := p.name
+	for n := 2; reg[name] != nil; n++ {
+		name = fmt.Sprintf("%s#%d", p.name, n)
+	}
+	p.name = name
+	reg[name] = p
+}
+
+func unregister(p *Pool) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	if cur, ok := reg[p.name]; ok && cur == p {
+		delete(reg, p.name)
+	}
+}
+
+// Snapshot returns stats for every live pool, sorted by name.
+func Snapshot() []Stats {
+	regMu.Lock()
+	pools := make([]*Pool, 0, len(reg))
+	for _, p := range reg {
+		pools = append(pools, p)
+	}
+	regMu.Unlock()
+
+	out := make([]Stats, 0, len(pools))
+	for _, p := range pools {
+		out = append(out, p.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
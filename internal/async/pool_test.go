@@ -0,0 +1,157 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var quiet = log.New(io.Discard, "", 0)
+
+func drain(t *testing.T, p *Pool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicRecovered(t *testing.T) {
+	p := NewPool("test.panic", 1, 4, quiet)
+	_ = p.Go(func(context.Context) { panic("boom") })
+	var ran atomic.Bool
+	_ = p.Go(func(context.Context) { ran.Store(true) })
+	_ = p.Submit(func(context.Context) error { return errors.New("failed") })
+	drain(t, p)
+
+	st := p.Stats()
+	if !ran.Load() || st.Panics != 1 || st.Failed != 2 || st.Completed != 1 {
+		t.Fatalf("after a panic: ran=%v stats=%+v", ran.Load(), st)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	p := NewPool("test.full", 1, 2, quiet)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = p.Go(func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started // the only worker is busy; the queue holds two more
+	for i := 0; i < 2; i++ {
+		if err := p.Go(func(context.Context) {}); err != nil {
+			t.Fatalf("task %d: %v", i, err)
+		}
+	}
+	if err := p.Go(func(context.Context) {}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("third queued task: %v", err)
+	}
+	if st := p.Stats(); st.Rejected != 1 || st.Queued != 2 || st.Active != 1 {
+		t.Fatalf("stats: %+v", st)
+	}
+	close(release)
+	drain(t, p)
+	if err := p.Go(func(context.Context) {}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("after drain: %v", err)
+	}
+}
+
+func TestNamesPerInstance(t *testing.T) {
+	a := NewPool("test.bg", 1, 1, quiet)
+	b := NewPool("test.bg", 1, 1, quiet)
+	if a.Name() != "test.bg" || b.Name() != "test.bg#2" {
+		t.Fatalf("names %q %q", a.Name(), b.Name())
+	}
+	names := map[string]bool{}
+	for _, s := range Snapshot() {
+		names[s.Name] = true
+	}
+	if !names["test.bg"] || !names["test.bg#2"] {
+		t.Fatalf("snapshot lost a pool: %v", names)
+	}
+	drain(t, a)
+	drain(t, b)
+	// a drained pool frees its name
+	c := NewPool("test.bg", 1, 1, quiet)
+	defer drain(t, c)
+	if c.Name() != "test.bg" {
+		t.Fatalf("name after drain: %q", c.Name())
+	}
+}
+
+func TestEvery(t *testing.T) {
+	p := NewPool("test.every", 1, 1, quiet)
+	stop := make(chan struct{})
+	runs := make(chan struct{}, 16)
+	p.Every(5*time.Millisecond, stop, func(context.Context) { runs <- struct{}{} })
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("run %d never happened", i)
+		}
+	}
+	close(stop)
+	time.Sleep(20 * time.Millisecond)
+	for len(runs) > 0 {
+		<-runs
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(runs); n != 0 {
+		t.Fatalf("%d runs after stop", n)
+	}
+	if st := p.Stats(); st.Active != 0 {
+		t.Fatalf("a worker is held between runs: %+v", st)
+	}
+	drain(t, p)
+}
+
+// Drain returns only after the running task and the queued ones finish.
+func TestDrainWaitsForWork(t *testing.T) {
+	p := NewPool("test.drain", 1, 4, quiet)
+	var done atomic.Int32
+	for i := 0; i < 3; i++ {
+		_ = p.Go(func(context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			done.Add(1)
+		})
+	}
+	drain(t, p)
+	if n := done.Load(); n != 3 {
+		t.Fatalf("drain returned with %d of 3 tasks done", n)
+	}
+	if st := p.Stats(); st.Active != 0 || st.Queued != 0 || st.Completed != 3 {
+		t.Fatalf("stats: %+v", st)
+	}
+}
+
+// A task that outlives the deadline gets its context cancelled and Drain
+// reports the deadline with what was still running.
+func TestDrainDeadline(t *testing.T) {
+	p := NewPool("test.drain.deadline", 1, 4, quiet)
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	_ = p.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := p.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "active=1") {
+		t.Fatalf("drain: %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("task context not cancelled")
+	}
+}
@@ -0,0 +1,379 @@
+// Package audit records security/audit events as JSONL files with size/age
+// retention, NDJSON export and an optional at-least-once HTTP forwarder.
+//
+// Env:
+//
+//	AUDIT_DIR             directory for <agent>-YYYYMMDD.jsonl files (empty = disabled)
+//	AUDIT_MAX_AGE         max file age before pruning (default 168h)
+//	AUDIT_MAX_TOTAL_BYTES cap for all files of this agent; oldest files pruned first (default 256MB)
+//	AUDIT_FORWARD_URL     optional HTTP(S) endpoint receiving batched NDJSON POSTs
+//
+// Emit never waits on the forwarder: events go through a bounded channel to
+// the writer, and when the writer falls behind Emit appends the event to the
+// day file itself (counted as spilled). The files are the forward queue, so a
+// forward target that is down only grows them, up to AUDIT_MAX_TOTAL_BYTES;
+// pruning past the forward cursor is counted and logged. Events are dropped
+// only when the disk write fails.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SchemaVersion is bumped on incompatible Event changes. See docs/AUDIT.md.
+const SchemaVersion = 1
+
+// Event is a single audit record. Field names map onto common SIEM fields:
+// ts->@timestamp, actor->user.name/source, action->event.action,
+// outcome->event.outcome, target->destination/service.
+type Event struct {
+	Version int            `json:"v"`
+	Time    time.Time      `json:"ts"`
+	Agent   string         `json:"agent"`
+	Type    string         `json:"type"` // e.g. "hpke", "security", "payment"
+	Actor   string         `json:"actor,omitempty"`
+	Action  string         `json:"action"`
+	Outcome string         `json:"outcome,omitempty"` // "success" | "failure" | "denied"
+	Target  string         `json:"target,omitempty"`
+	CID     string         `json:"cid,omitempty"`
+	Run     string         `json:"run,omitempty"` // demo run ID active when emitted
+	Detail  map[string]any `json:"detail,omitempty"`
+}
+
+// Logger writes events for one agent.
+type Logger struct {
+	agent    string
+	dir      string
+	maxAge   time.Duration
+	maxTotal int64
+	logger   *log.Logger
+
+	run atomic.Value // string: current run ID stamped on events
+
+	ch           chan Event
+	dropped      atomic.Int64
+	written      atomic.Int64
+	spilled      atomic.Int64
+	prunedUnsent atomic.Int64 // files pruned before the forwarder reached them
+
+	mu      sync.Mutex // guards file writes
+	fwd     *forwarder
+	stop    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+}
+
+// FromEnv builds a Logger for agent using AUDIT_* env. It returns a disabled
+// (no-op) logger when AUDIT_DIR is empty.
+func FromEnv(agent string, logger *log.Logger) *Logger {
+	dir := strings.TrimSpace(os.Getenv("AUDIT_DIR"))
+	l := New(agent, dir, logger)
+	if v := strings.TrimSpace(os.Getenv("AUDIT_MAX_AGE")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			l.maxAge = d
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AUDIT_MAX_TOTAL_BYTES")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			l.maxTotal = n
+		}
+	}
+	if u := strings.TrimSpace(os.Getenv("AUDIT_FORWARD_URL")); u != "" && l.Enabled() {
+		l.fwd = newForwarder(l, u)
+		l.wg.Add(1)
+		go l.fwd.loop()
+	}
+	return l
+}
+
+// New creates a Logger writing under dir. Empty dir disables persistence.
+func New(agent, dir string, logger *log.Logger) *Logger {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[audit] ", log.LstdFlags)
+	}
+	l := &Logger{
+		agent:    agent,
+		dir:      dir,
+		maxAge:   7 * 24 * time.Hour,
+		maxTotal: 256 << 20,
+		logger:   logger,
+		ch:       make(chan Event, 1024),
+		stop:     make(chan struct{}),
+	}
+	if dir == "" {
+		return l
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logger.Printf("[audit] disabled: mkdir %s: %v", dir, err)
+		l.dir = ""
+		return l
+	}
+	l.wg.Add(2)
+	go l.writeLoop()
+	go l.pruneLoop()
+	return l
+}
+
+// Enabled reports whether events are persisted.
+func (l *Logger) Enabled() bool { return l != nil && l.dir != "" }
+
+// Emit queues ev for writing, or writes it directly when the queue is full.
+func (l *Logger) Emit(ev Event) {
+	if !l.Enabled() {
+		return
+	}
+	ev.Version = SchemaVersion
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if ev.Agent == "" {
+		ev.Agent = l.agent
+	}
+	if ev.Run == "" {
+		ev.Run = l.Run()
+	}
+	select {
+	case l.ch <- ev:
+	default:
+		if n := l.spilled.Add(1); n%100 == 1 {
+			l.logger.Printf("[audit] queue full; writing inline (spilled=%d)", n)
+		}
+		l.write(ev)
+	}
+}
+
+// SetRun sets the run ID stamped on subsequent events ("" clears it).
+func (l *Logger) SetRun(id string) {
+	if l != nil {
+		l.run.Store(id)
+	}
+}
+
+// Run returns the current run ID ("" when none).
+func (l *Logger) Run() string {
+	if l == nil {
+		return ""
+	}
+	s, _ := l.run.Load().(string)
+	return s
+}
+
+// Stats returns counters for status endpoints.
+func (l *Logger) Stats() map[string]any {
+	if l == nil {
+		return map[string]any{"enabled": false}
+	}
+	out := map[string]any{
+		"enabled": l.Enabled(),
+		"written": l.written.Load(),
+		"dropped": l.dropped.Load(),
+		"spilled": l.spilled.Load(),
+	}
+	if l.fwd != nil {
+		fs := l.fwd.stats()
+		fs["prunedUnsent"] = l.prunedUnsent.Load()
+		out["forward"] = fs
+	}
+	return out
+}
+
+// Close flushes queued events and stops background loops.
+func (l *Logger) Close() {
+	if !l.Enabled() {
+		return
+	}
+	l.stopped.Do(func() { close(l.stop) })
+	l.wg.Wait()
+}
+
+func (l *Logger) fileFor(t time.Time) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%s-%s.jsonl", l.agent, t.UTC().Format("20060102")))
+}
+
+func (l *Logger) writeLoop() {
+	defer l.wg.Done()
+	for {
+		select {
+		case ev := <-l.ch:
+			l.write(ev)
+		case <-l.stop:
+			for {
+				select {
+				case ev := <-l.ch:
+					l.write(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *Logger) write(ev Event) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		l.dropped.Add(1)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.fileFor(ev.Time), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		l.dropped.Add(1)
+		l.logger.Printf("[audit] open: %v", err)
+		return
+	}
+	_, err = f.Write(append(b, '\n'))
+	_ = f.Close()
+	if err != nil {
+		l.dropped.Add(1)
+		return
+	}
+	l.written.Add(1)
+}
+
+// ---- retention ----
+
+func (l *Logger) pruneLoop() {
+	defer l.wg.Done()
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	l.Prune(time.Now())
+	for {
+		select {
+		case <-t.C:
+			l.Prune(time.Now())
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// files returns this agent's JSONL files, oldest first.
+func (l *Logger) files() []string {
+	m, _ := filepath.Glob(filepath.Join(l.dir, l.agent+"-*.jsonl"))
+	sort.Strings(m)
+	return m
+}
+
+// unsent reports whether the forwarder has not yet passed file f.
+func (l *Logger) unsent(f string) bool {
+	if l.fwd == nil {
+		return false
+	}
+	l.fwd.mu.Lock()
+	cur := l.fwd.cur.File
+	l.fwd.mu.Unlock()
+	return cur == "" || filepath.Base(f) >= cur
+}
+
+// remove deletes f, counting it when the forwarder had not reached it yet.
+func (l *Logger) remove(f, why string) {
+	_ = os.Remove(f)
+	if l.unsent(f) {
+		n := l.prunedUnsent.Add(1)
+		l.logger.Printf("[audit] pruned (%s) %s before it was forwarded (prunedUnsent=%d)", why, filepath.Base(f), n)
+		return
+	}
+	l.logger.Printf("[audit] pruned (%s) %s", why, filepath.Base(f))
+}
+
+// Prune removes files older than maxAge, then oldest files until the total
+// size fits maxTotal. The current day's file is never removed.
+func (l *Logger) Prune(now time.Time) {
+	if !l.Enabled() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := l.fileFor(now)
+	var keep []string
+	var total int64
+	for _, f := range l.files() {
+		st, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if f != current && now.Sub(st.ModTime()) > l.maxAge {
+			l.remove(f, "age")
+			continue
+		}
+		keep = append(keep, f)
+		total += st.Size()
+	}
+	for len(keep) > 1 && total > l.maxTotal {
+		f := keep[0]
+		if st, err := os.Stat(f); err == nil {
+			total -= st.Size()
+		}
+		l.remove(f, "size")
+		keep = keep[1:]
+	}
+}
+
+// ---- export ----
+
+// Filter selects events for Export. Zero values match everything.
+type Filter struct {
+	From  time.Time
+	To    time.Time
+	Types map[string]bool
+	Run   string
+}
+
+func (f Filter) match(ev *Event) bool {
+	if !f.From.IsZero() && ev.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && ev.Time.After(f.To) {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[ev.Type] {
+		return false
+	}
+	if f.Run != "" && ev.Run != f.Run {
+		return false
+	}
+	return true
+}
+
+// Export streams matching events as NDJSON to w and returns the count.
+func (l *Logger) Export(w io.Writer, f Filter) (int, error) {
+	if !l.Enabled() {
+		return 0, nil
+	}
+	n := 0
+	for _, path := range l.files() {
+		fh, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(fh)
+		sc.Buffer(make([]byte, 64<<10), 4<<20)
+		for sc.Scan() {
+			var ev Event
+			if json.Unmarshal(sc.Bytes(), &ev) != nil || !f.match(&ev) {
+				continue
+			}
+			if _, err := w.Write(append(append([]byte{}, sc.Bytes()...), '\n')); err != nil {
+				_ = fh.Close()
+				return n, err
+			}
+			n++
+		}
+		_ = fh.Close()
+	}
+	return n, nil
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func quiet() *log.Logger { return log.New(io.Discard, "", 0) }
+
+// lines counts the events in dir's files for agent.
+func lines(t *testing.T, dir, agent string) int {
+	t.Helper()
+	m, _ := filepath.Glob(filepath.Join(dir, agent+"-*.jsonl"))
+	n := 0
+	for _, p := range m {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			n++
+		}
+		_ = f.Close()
+	}
+	return n
+}
+
+// A stalled writer must not cost events: once the queue is full Emit writes
+// them itself.
+func TestEmitSpillsWhenQueueFull(t *testing.T) {
+	dir := t.TempDir()
+	l := New("t", dir, quiet())
+
+	l.mu.Lock() // stall the writer (and the inline writes) on the file lock
+	total := cap(l.ch) + 50
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < total; i++ {
+			l.Emit(Event{Type: "security", Action: "test"})
+		}
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(l.ch) < cap(l.ch) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	l.mu.Unlock()
+	<-done
+	l.Close()
+
+	if got := lines(t, dir, "t"); got != total {
+		t.Fatalf("events on disk = %d, want %d", got, total)
+	}
+	if l.dropped.Load() != 0 || l.spilled.Load() == 0 {
+		t.Fatalf("dropped=%d spilled=%d, want 0 and >0", l.dropped.Load(), l.spilled.Load())
+	}
+}
+
+// A forward target that is down leaves the events queued on disk and the
+// cursor where it was; a restarted forwarder resumes from the cursor and
+// sends each event once.
+func TestForwardDownThenResume(t *testing.T) {
+	dir := t.TempDir()
+	var up atomic.Bool
+	var mu sync.Mutex
+	got := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		sc := bufio.NewScanner(r.Body)
+		mu.Lock()
+		for sc.Scan() {
+			got++
+		}
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	l := New("t", dir, quiet())
+	for i := 0; i < 700; i++ {
+		l.Emit(Event{Type: "hpke", Action: "test"})
+	}
+	l.Close() // flushes the queue to disk
+
+	f := newForwarder(l, srv.URL)
+	if _, err := f.flushOnce(); err == nil {
+		t.Fatal("flush succeeded with the target down")
+	}
+	if c := f.loadCursor(); c != (cursor{}) {
+		t.Fatalf("cursor moved while down: %+v", c)
+	}
+
+	up.Store(true)
+	if n, err := f.flushOnce(); err != nil || n != f.batch {
+		t.Fatalf("first batch: n=%d err=%v", n, err)
+	}
+
+	// restart: a new forwarder picks up the persisted cursor
+	f = newForwarder(l, srv.URL)
+	if n, err := f.flushOnce(); err != nil || n != 700-f.batch {
+		t.Fatalf("resumed batch: n=%d err=%v", n, err)
+	}
+	if n, _ := f.flushOnce(); n != 0 {
+		t.Fatalf("re-sent %d events", n)
+	}
+	if got != 700 {
+		t.Fatalf("target received %d events, want 700", got)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	l := New("t", dir, quiet())
+	l.Close()
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	l.maxAge = 48 * time.Hour
+	l.maxTotal = 25
+	mk := func(day int, size int, age time.Duration) string {
+		p := l.fileFor(time.Date(2025, 3, day, 0, 0, 0, 0, time.UTC))
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(p, now.Add(-age), now.Add(-age))
+		return p
+	}
+	old := mk(1, 10, 9*24*time.Hour)
+	older := mk(8, 10, 2*24*time.Hour-time.Minute)
+	recent := mk(9, 10, 24*time.Hour)
+	current := mk(10, 10, 0)
+
+	l.Prune(now)
+	for p, want := range map[string]bool{old: false, older: false, recent: true, current: true} {
+		_, err := os.Stat(p)
+		if (err == nil) != want {
+			t.Errorf("%s kept=%v, want %v", filepath.Base(p), err == nil, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/statefile"
+)
+
+// forwarder ships JSONL files to AUDIT_FORWARD_URL in batches. The files on
+// disk are the queue; a cursor (file + byte offset) is persisted after every
+// acknowledged batch, so delivery is at-least-once across restarts.
+type forwarder struct {
+	l      *Logger
+	url    string
+	client *http.Client
+	batch  int
+
+	mu       sync.Mutex
+	cur      cursor
+	lastErr  string
+	sent     int64
+	failures int64
+}
+
+type cursor struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+}
+
+func newForwarder(l *Logger, url string) *forwarder {
+	f := &forwarder{
+		l:      l,
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		batch:  500,
+	}
+	f.cur = f.loadCursor()
+	return f
+}
+
+func (f *forwarder) cursorPath() string {
+	return filepath.Join(f.l.dir, "."+f.l.agent+".cursor")
+}
+
+// cursorOpts: one backup is enough; a cursor that falls back a batch only
+// means that batch is forwarded again (at-least-once).
+var cursorOpts = statefile.Options{Backups: 1, Perm: 0o644}
+
+func (f *forwarder) loadCursor() cursor {
+	var c cursor
+	if _, err := statefile.LoadJSON(f.cursorPath(), &c, cursorOpts); err != nil {
+		c = cursor{} // unrecoverable: re-forward from the oldest file
+	}
+	return c
+}
+
+func (f *forwarder) saveCursor(c cursor) error {
+	return statefile.SaveJSON(f.cursorPath(), c, cursorOpts)
+}
+
+func (f *forwarder) stats() map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return map[string]any{
+		"url":      f.url,
+		"sent":     f.sent,
+		"failures": f.failures,
+		"lastErr":  f.lastErr,
+		"cursor":   f.cur,
+	}
+}
+
+func (f *forwarder) loop() {
+	defer f.l.wg.Done()
+	backoff := time.Second
+	for {
+		wait := 5 * time.Second
+		n, err := f.flushOnce()
+		f.mu.Lock()
+		if err != nil {
+			f.failures++
+			f.lastErr = err.Error()
+			wait = backoff
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+		} else {
+			f.lastErr = ""
+			backoff = time.Second
+			if n == f.batch {
+				wait = 0 // more pending
+			}
+		}
+		f.mu.Unlock()
+		select {
+		case <-f.l.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// flushOnce reads up to one batch after the cursor and POSTs it.
+func (f *forwarder) flushOnce() (int, error) {
+	f.mu.Lock()
+	cur := f.cur
+	f.mu.Unlock()
+
+	files := f.l.files()
+	if len(files) == 0 {
+		return 0, nil
+	}
+	// Cursor file pruned or never set: start at the oldest remaining file.
+	idx := -1
+	for i, p := range files {
+		if filepath.Base(p) == cur.File {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		idx, cur = 0, cursor{File: filepath.Base(files[0])}
+	}
+
+	var buf bytes.Buffer
+	n := 0
+	next := cur
+	for i := idx; i < len(files) && n < f.batch; i++ {
+		path := files[i]
+		off := int64(0)
+		if filepath.Base(path) == cur.File {
+			off = cur.Offset
+		}
+		fh, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if _, err := fh.Seek(off, 0); err != nil {
+			_ = fh.Close()
+			continue
+		}
+		rd := bufio.NewReader(fh)
+		for n < f.batch {
+			line, err := rd.ReadBytes('\n')
+			if err != nil {
+				break // partial line stays for the next round
+			}
+			buf.Write(line)
+			off += int64(len(line))
+			n++
+		}
+		_ = fh.Close()
+		next = cursor{File: filepath.Base(path), Offset: off}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	resp, err := f.client.Post(f.url, "application/x-ndjson", &buf)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("forward status %d", resp.StatusCode)
+	}
+	if err := f.saveCursor(next); err != nil {
+		return 0, fmt.Errorf("save cursor: %w", err)
+	}
+	f.mu.Lock()
+	f.cur = next
+	f.sent += int64(n)
+	f.mu.Unlock()
+	return n, nil
+}
@@ -0,0 +1,85 @@
+// Package bodylimit is the request size limits an agent advertises and
+// enforces.
+//
+// Upstream agents publish their limits in the "limits" block of GET /status
+//
+//	{"limits": {"maxBodyBytes": 1048576, "maxMetadataBytes": 65536}}
+//
+// and reject larger requests with 413 before any signature or HPKE work.
+// Root reads the block with the health probe and checks the serialized
+// message (uncompressed; plus the AEAD overhead for HPKE) before it signs and
+// encrypts, so an oversized payload fails locally with a clear message
+// instead of as a vague upstream 413. A value of 0 means no limit.
+package bodylimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Defaults used by agents that do not configure limits, and by Root for
+// upstreams that advertise none.
+const (
+	DefaultMaxBodyBytes     = 1 << 20
+	DefaultMaxMetadataBytes = 64 << 10
+)
+
+// Code is the error code of a 413 answer.
+const Code = "payload_too_large"
+
+// Limits is the /status "limits" block.
+type Limits struct {
+	MaxBodyBytes     int `json:"maxBodyBytes"`
+	MaxMetadataBytes int `json:"maxMetadataBytes"`
+}
+
+// Default returns the default limits.
+func Default() Limits {
+	return Limits{MaxBodyBytes: DefaultMaxBodyBytes, MaxMetadataBytes: DefaultMaxMetadataBytes}
+}
+
+// FromEnv reads <PREFIX>_MAX_BODY_BYTES and <PREFIX>_MAX_METADATA_BYTES
+// (unset or invalid = default, 0 = no limit).
+func FromEnv(prefix string) Limits {
+	l := Default()
+	l.MaxBodyBytes = envInt(prefix+"_MAX_BODY_BYTES", l.MaxBodyBytes)
+	l.MaxMetadataBytes = envInt(prefix+"_MAX_METADATA_BYTES", l.MaxMetadataBytes)
+	return l
+}
+
+func envInt(k string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(k))); err == nil && n >= 0 {
+		return n
+	}
+	return def
+}
+
+// Handler rejects request bodies above l.MaxBodyBytes: by Content-Length
+// before next runs, and by capping the body for chunked requests. Sizes are
+// the bytes on the wire (compressed when Content-Encoding is gzip).
+func Handler(l Limits, next http.Handler) http.Handler {
+	if l.MaxBodyBytes <= 0 {
+		return next
+	}
+	max := int64(l.MaxBodyBytes)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			WriteTooLarge(w, l, r.ContentLength)
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteTooLarge answers 413 with the limits and the offending size.
+func WriteTooLarge(w http.ResponseWriter, l Limits, size int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": Code, "bytes": size, "limits": l})
+}
@@ -0,0 +1,78 @@
+package bodylimit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// upstream is a fake agent enforcing l; it answers how much body it read.
+func upstream(l Limits) *httptest.Server {
+	return httptest.NewServer(Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteTooLarge(w, l, int64(len(b)))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})))
+}
+
+func TestHandler(t *testing.T) {
+	l := Limits{MaxBodyBytes: 100}
+	srv := upstream(l)
+	defer srv.Close()
+
+	post := func(body io.Reader) *http.Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL, "application/json", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := post(strings.NewReader(strings.Repeat("a", 100))); resp.StatusCode != http.StatusOK {
+		t.Fatalf("at the limit: HTTP %d", resp.StatusCode)
+	}
+
+	resp := post(strings.NewReader(strings.Repeat("a", 101)))
+	var out struct {
+		Error  string `json:"error"`
+		Bytes  int64  `json:"bytes"`
+		Limits Limits `json:"limits"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || out.Error != Code || out.Bytes != 101 || out.Limits != l {
+		t.Fatalf("over by Content-Length: HTTP %d %+v", resp.StatusCode, out)
+	}
+
+	// no Content-Length: the capped reader stops it
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte(strings.Repeat("a", 300)))
+		_ = pw.Close()
+	}()
+	if resp := post(pr); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("chunked over the limit: HTTP %d", resp.StatusCode)
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	srv := upstream(Limits{})
+	defer srv.Close()
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(strings.Repeat("a", 2<<20)))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unlimited: %v %v", resp, err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_MAX_BODY_BYTES", "0")
+	t.Setenv("PAYMENT_MAX_METADATA_BYTES", "junk")
+	if l := FromEnv("PAYMENT"); l.MaxBodyBytes != 0 || l.MaxMetadataBytes != DefaultMaxMetadataBytes {
+		t.Fatalf("FromEnv = %+v", l)
+	}
+}
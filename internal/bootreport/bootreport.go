@@ -0,0 +1,468 @@
+// Package bootreport is the startup banner and machine-readable boot report
+// shared by the cmd binaries.
+//
+// After init, a binary fills a Report with what it is about to serve with:
+// listening ports, key files and their key IDs, DIDs, security modes
+// (signature enforcement, HPKE, insecure demo), upstream URLs and the settings
+// worth comparing between runs. Emit then adds the registered feature flags
+// (internal/flags) and every warning collected with Warnf during init (a
+// fallback key path, insecure mode, missing translations), logs a banner and
+// writes the report as JSON:
+//
+//	BOOT_REPORT_FILE set   the report is written to that file
+//	BOOT_REPORT_DIR set    to <dir>/<component>.json
+//	neither                to stdout, as one line prefixed with Marker
+//
+// Secrets never reach the banner or the report: settings and flags whose name
+// looks secret (token, secret, password, api key, private, credential, or
+// ending in "key") are replaced by "[redacted]", URL passwords and
+// secret-looking query parameters are scrubbed, and key files are reported by
+// key ID only (the JWK "kid", else a fingerprint of the public members).
+//
+// Merge folds the reports of a launch into one system summary and Diff
+// compares a report against a known-good one (cmd/bootreport).
+package bootreport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/flags"
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+)
+
+// Schema is the report format version.
+const Schema = 1
+
+// Marker prefixes a report printed to stdout, so it can be found in a log.
+const Marker = "SAGE-BOOT-REPORT "
+
+// Output settings.
+const (
+	EnvFile = "BOOT_REPORT_FILE"
+	EnvDir  = "BOOT_REPORT_DIR"
+)
+
+// Redacted replaces a secret value.
+const Redacted = "[redacted]"
+
+// Key is a key file a component loaded.
+type Key struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	KID  string `json:"kid,omitempty"`
+}
+
+// Report is one component's boot report.
+type Report struct {
+	Schema    int               `json:"schema"`
+	Component string            `json:"component"`
+	Version   string            `json:"version"`
+	PID       int               `json:"pid"`
+	Host      string            `json:"host,omitempty"`
+	GoVersion string            `json:"goVersion"`
+	StartedAt string            `json:"startedAt"`
+	Ports     map[string]int    `json:"ports,omitempty"`
+	DIDs      map[string]string `json:"dids,omitempty"`
+	Keys      []Key             `json:"keys,omitempty"`
+	Security  map[string]string `json:"security,omitempty"`
+	Upstreams map[string]string `json:"upstreams,omitempty"`
+	Features  map[string]bool   `json:"features,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+	Warnings  []string          `json:"warnings,omitempty"`
+}
+
+// New starts component's report.
+func New(component string) *Report {
+	host, _ := os.Hostname()
+	return &Report{
+		Schema:    Schema,
+		Component: component,
+		Version:   selfid.Version,
+		PID:       os.Getpid(),
+		Host:      host,
+		GoVersion: runtime.Version(),
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Port records a listening port ("http").
+func (r *Report) Port(name string, port int) *Report {
+	if r.Ports == nil {
+		r.Ports = map[string]int{}
+	}
+	r.Ports[name] = port
+	return r
+}
+
+// DID records the DID the component acts as under name ("self", "client").
+// An empty did is skipped.
+func (r *Report) DID(name, did string) *Report {
+	if did = strings.TrimSpace(did); did == "" {
+		return r
+	}
+	if r.DIDs == nil {
+		r.DIDs = map[string]string{}
+	}
+	r.DIDs[name] = did
+	return r
+}
+
+// Key records the key file at path under name with its key ID. An empty path
+// is skipped; an unreadable one is recorded as a warning.
+func (r *Report) Key(name, path string) *Report {
+	if path = strings.TrimSpace(path); path == "" {
+		return r
+	}
+	kid, err := KeyID(path)
+	if err != nil {
+		r.Warn("key %s (%s): %v", name, path, err)
+	}
+	r.Keys = append(r.Keys, Key{Name: name, Path: path, KID: kid})
+	return r
+}
+
+// Mode records a security mode ("signatures": "required").
+func (r *Report) Mode(name, mode string) *Report {
+	if r.Security == nil {
+		r.Security = map[string]string{}
+	}
+	r.Security[name] = mode
+	return r
+}
+
+// Upstream records an upstream URL. An empty url is skipped.
+func (r *Report) Upstream(name, u string) *Report {
+	if u = strings.TrimSpace(u); u == "" {
+		return r
+	}
+	if r.Upstreams == nil {
+		r.Upstreams = map[string]string{}
+	}
+	r.Upstreams[name] = u
+	return r
+}
+
+// Setting records a configuration value. Secret-looking names are redacted
+// when the report is finished.
+func (r *Report) Setting(name, v string) *Report {
+	if r.Settings == nil {
+		r.Settings = map[string]string{}
+	}
+	r.Settings[name] = v
+	return r
+}
+
+// Warn records an init warning on this report.
+func (r *Report) Warn(format string, args ...any) *Report {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+	return r
+}
+
+// Collected warnings, for code that runs before the report exists.
+var pending struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// Warnf collects an init warning for the process's boot report. It does not
+// log; the caller keeps its own log line.
+func Warnf(format string, args ...any) {
+	pending.mu.Lock()
+	pending.list = append(pending.list, fmt.Sprintf(format, args...))
+	pending.mu.Unlock()
+}
+
+// Finish adds the feature flags and collected warnings and redacts secrets.
+// Emit calls it; it is idempotent.
+func (r *Report) Finish() *Report {
+	for _, f := range flags.List() {
+		if r.Features == nil {
+			r.Features = map[string]bool{}
+		}
+		r.Features[f.Name] = f.Value
+	}
+	pending.mu.Lock()
+	r.Warnings = append(r.Warnings, pending.list...)
+	pending.mu.Unlock()
+
+	seen := map[string]bool{}
+	ws := r.Warnings[:0]
+	for _, w := range r.Warnings {
+		if w = scrubText(w); !seen[w] {
+			seen[w] = true
+			ws = append(ws, w)
+		}
+	}
+	r.Warnings = ws
+	for k, v := range r.Settings {
+		r.Settings[k] = redact(k, v)
+	}
+	for k, v := range r.Upstreams {
+		r.Upstreams[k] = scrubText(v)
+	}
+	for i, k := range r.Keys {
+		r.Keys[i].Path = scrubText(k.Path)
+	}
+	return r
+}
+
+var (
+	lastMu sync.Mutex
+	last   *Report
+)
+
+// Last is the report this process emitted (nil before Emit).
+func Last() *Report {
+	lastMu.Lock()
+	defer lastMu.Unlock()
+	return last
+}
+
+// Emit finishes r, logs the banner through logf and writes the JSON report
+// (see the package doc). A write failure is logged and returned; the caller
+// decides whether it matters.
+func (r *Report) Emit(logf func(string, ...any)) error {
+	r.Finish()
+	for _, line := range r.Banner() {
+		logf("%s", line)
+	}
+	lastMu.Lock()
+	last = r
+	lastMu.Unlock()
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := strings.TrimSpace(os.Getenv(EnvFile))
+	if path == "" {
+		if dir := strings.TrimSpace(os.Getenv(EnvDir)); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				logf("[boot] report: %v", err)
+				return err
+			}
+			path = filepath.Join(dir, r.Component+".json")
+		}
+	}
+	if path == "" {
+		line, _ := json.Marshal(r)
+		fmt.Fprintf(os.Stdout, "%s%s\n", Marker, line)
+		return nil
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		logf("[boot] report: %v", err)
+		return err
+	}
+	logf("[boot] report written to %s", path)
+	return nil
+}
+
+// Banner is the human-readable summary, one log line per section.
+func (r *Report) Banner() []string {
+	out := []string{fmt.Sprintf("[boot] ==== %s %s (pid %d, %s) ====", r.Component, r.Version, r.PID, r.GoVersion)}
+	add := func(section string, kv []string) {
+		if len(kv) > 0 {
+			out = append(out, fmt.Sprintf("[boot] %-9s %s", section, strings.Join(kv, "  ")))
+		}
+	}
+	ports := make([]string, 0, len(r.Ports))
+	for k, v := range r.Ports {
+		ports = append(ports, fmt.Sprintf("%s=%d", k, v))
+	}
+	sort.Strings(ports)
+	add("ports", ports)
+	add("dids", pairs(r.DIDs))
+	keys := make([]string, 0, len(r.Keys))
+	for _, k := range r.Keys {
+		kid := k.KID
+		if kid == "" {
+			kid = "?"
+		}
+		keys = append(keys, fmt.Sprintf("%s=%s (kid %s)", k.Name, k.Path, kid))
+	}
+	add("keys", keys)
+	add("security", pairs(r.Security))
+	add("upstream", pairs(r.Upstreams))
+	feats := make([]string, 0, len(r.Features))
+	for k, v := range r.Features {
+		feats = append(feats, fmt.Sprintf("%s=%s", k, onOff(v)))
+	}
+	sort.Strings(feats)
+	add("features", feats)
+	add("settings", pairs(r.Settings))
+	for _, w := range r.Warnings {
+		out = append(out, "[boot] WARN "+w)
+	}
+	return out
+}
+
+func pairs(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k, v := range m {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
+
+// KeyID identifies the JWK at path without exposing it: its "kid", else
+// "sha256:" and 16 hex digits of a hash over the public members.
+func KeyID(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var jwk map[string]any
+	if err := json.Unmarshal(b, &jwk); err != nil {
+		return "", fmt.Errorf("not a JWK: %w", err)
+	}
+	if kid, _ := jwk["kid"].(string); strings.TrimSpace(kid) != "" {
+		return kid, nil
+	}
+	var pub []string
+	for _, k := range []string{"kty", "crv", "x", "y", "n", "e"} {
+		if v, ok := jwk[k].(string); ok {
+			pub = append(pub, k+"="+v)
+		}
+	}
+	if len(pub) == 0 {
+		return "", fmt.Errorf("no public key members")
+	}
+	sum := sha256.Sum256([]byte(strings.Join(pub, "\n")))
+	return "sha256:" + hex.EncodeToString(sum[:8]), nil
+}
+
+// Secret reports whether a setting or parameter name looks secret.
+func Secret(name string) bool {
+	n := strings.ToLower(name)
+	for _, s := range []string{"secret", "token", "passw", "apikey", "api_key", "api-key", "private", "credential"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return strings.HasSuffix(n, "key")
+}
+
+// redact is v as reported under name.
+func redact(name, v string) string {
+	if Secret(name) {
+		if strings.TrimSpace(v) == "" || v == Redacted {
+			return v
+		}
+		return Redacted
+	}
+	return scrubText(v)
+}
+
+// scrubURL removes the password and secret query parameters from u.
+func scrubURL(u string) string {
+	p, err := url.Parse(u)
+	if err != nil || p.Host == "" {
+		return u
+	}
+	if p.User != nil {
+		if _, ok := p.User.Password(); ok {
+			p.User = url.UserPassword(p.User.Username(), "redacted")
+		}
+	}
+	if p.RawQuery != "" {
+		q := p.Query()
+		for k := range q {
+			if Secret(k) {
+				q.Set(k, "redacted")
+			}
+		}
+		p.RawQuery = q.Encode()
+	}
+	return p.String()
+}
+
+var (
+	urlRe    = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+	secretRe = regexp.MustCompile(`(?i)\b([a-z0-9_.-]*(?:secret|token|passw(?:or)?d?|api[_-]?key|credential)[a-z0-9_.-]*)\s*[=:]\s*("[^"]*"|[^\s,;&]+)`)
+)
+
+// scrubText scrubs the URLs and name=value secrets inside free text.
+func scrubText(s string) string {
+	s = urlRe.ReplaceAllStringFunc(s, scrubURL)
+	return secretRe.ReplaceAllString(s, "$1="+Redacted)
+}
+
+// Read parses a report or a system summary written by Emit or Merge. Input
+// that is not JSON is scanned for Marker lines, so a component log can be
+// read directly; every report found is returned.
+func Read(rd io.Reader) ([]Report, error) {
+	b, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	if t := bytes.TrimSpace(b); len(t) > 0 && t[0] == '{' {
+		var probe struct {
+			Components []Report `json:"components"`
+			Component  string   `json:"component"`
+		}
+		if err := json.Unmarshal(t, &probe); err != nil {
+			return nil, err
+		}
+		if probe.Component == "" {
+			return probe.Components, nil
+		}
+		var one Report
+		if err := json.Unmarshal(t, &one); err != nil {
+			return nil, err
+		}
+		return []Report{one}, nil
+	}
+	var out []Report
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		i := strings.Index(line, Marker)
+		if i < 0 {
+			continue
+		}
+		var one Report
+		if err := json.Unmarshal([]byte(line[i+len(Marker):]), &one); err != nil {
+			return nil, fmt.Errorf("bad %s line: %w", strings.TrimSpace(Marker), err)
+		}
+		out = append(out, one)
+	}
+	return out, sc.Err()
+}
+
+// ReadFile is Read on a file.
+func ReadFile(path string) ([]Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reps, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return reps, nil
+}
@@ -0,0 +1,151 @@
+package bootreport
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// System is the summary of one launch: every component's report.
+type System struct {
+	Schema      int      `json:"schema"`
+	GeneratedAt string   `json:"generatedAt"`
+	Components  []Report `json:"components"`
+	Warnings    []string `json:"warnings,omitempty"` // "component: warning"
+}
+
+// Merge builds the system summary. A component reported twice keeps its
+// latest report.
+func Merge(reps []Report) System {
+	by := map[string]Report{}
+	for _, r := range reps {
+		if prev, ok := by[r.Component]; !ok || r.StartedAt >= prev.StartedAt {
+			by[r.Component] = r
+		}
+	}
+	sys := System{Schema: Schema, GeneratedAt: time.Now().UTC().Format(time.RFC3339), Components: make([]Report, 0, len(by))}
+	for _, r := range by {
+		sys.Components = append(sys.Components, r)
+	}
+	sort.Slice(sys.Components, func(i, j int) bool { return sys.Components[i].Component < sys.Components[j].Component })
+	for _, r := range sys.Components {
+		for _, w := range r.Warnings {
+			sys.Warnings = append(sys.Warnings, r.Component+": "+w)
+		}
+	}
+	return sys
+}
+
+// Change is one difference from the known-good report.
+type Change struct {
+	Field string `json:"field"` // "payment.security.signatures"
+	Want  string `json:"want,omitempty"`
+	Got   string `json:"got,omitempty"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Want == "":
+		return fmt.Sprintf("+ %s = %s", c.Field, c.Got)
+	case c.Got == "":
+		return fmt.Sprintf("- %s (was %s)", c.Field, c.Want)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Field, c.Want, c.Got)
+}
+
+// Diff compares the reports of a launch against known-good ones, component by
+// component. Values that change on every start (pid, start time, host) are
+// ignored; a missing or extra component is one change.
+func Diff(good, got []Report) []Change {
+	want, have := byComponent(good), byComponent(got)
+	names := map[string]bool{}
+	for n := range want {
+		names[n] = true
+	}
+	for n := range have {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	var out []Change
+	for _, n := range sorted {
+		w, okW := want[n]
+		h, okH := have[n]
+		switch {
+		case !okH:
+			out = append(out, Change{Field: n, Want: "running"})
+		case !okW:
+			out = append(out, Change{Field: n, Got: "running"})
+		default:
+			out = append(out, diffFields(n, flatten(w), flatten(h))...)
+		}
+	}
+	return out
+}
+
+func byComponent(reps []Report) map[string]Report {
+	m := map[string]Report{}
+	for _, r := range reps {
+		m[r.Component] = r
+	}
+	return m
+}
+
+func diffFields(prefix string, want, have map[string]string) []Change {
+	keys := map[string]bool{}
+	for k := range want {
+		keys[k] = true
+	}
+	for k := range have {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var out []Change
+	for _, k := range sorted {
+		if want[k] != have[k] {
+			out = append(out, Change{Field: prefix + "." + k, Want: want[k], Got: have[k]})
+		}
+	}
+	return out
+}
+
+// flatten lists the comparable fields of r as dotted names.
+func flatten(r Report) map[string]string {
+	m := map[string]string{"schema": strconv.Itoa(r.Schema), "version": r.Version}
+	for k, v := range r.Ports {
+		m["ports."+k] = strconv.Itoa(v)
+	}
+	for k, v := range r.DIDs {
+		m["dids."+k] = v
+	}
+	for _, k := range r.Keys {
+		m["keys."+k.Name+".path"] = k.Path
+		m["keys."+k.Name+".kid"] = k.KID
+	}
+	for k, v := range r.Security {
+		m["security."+k] = v
+	}
+	for k, v := range r.Upstreams {
+		m["upstreams."+k] = v
+	}
+	for k, v := range r.Features {
+		m["features."+k] = onOff(v)
+	}
+	for k, v := range r.Settings {
+		m["settings."+k] = v
+	}
+	for _, w := range r.Warnings {
+		m[fmt.Sprintf("warning %q", strings.TrimSpace(w))] = "present"
+	}
+	return m
+}
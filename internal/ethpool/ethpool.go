@@ -0,0 +1,314 @@
+// Package ethpool shares Ethereum registry clients across a process.
+//
+// Root's HPKE path, the DID middleware and the external agents' HPKE servers
+// each used to dial the RPC endpoint on their own. Here clients are keyed by
+// kind + RPC endpoint + registry contract: consumers with the same key share
+// one client and hold a Lease on it; the client is closed when the last lease
+// is released (agent Shutdown). A background probe (JSON-RPC eth_chainId every
+// ETH_POOL_HEALTH_MS, default 15000) marks clients whose endpoint stops
+// answering unhealthy; the next probe that succeeds — or the next Acquire —
+// redials, so an RPC outage ends in a reconnect instead of a dead client.
+// Components built before a reconnect keep the handle they were given; the
+// next re-init (e.g. EnableHPKE) picks up the fresh one.
+package ethpool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Key identifies a shared client.
+type Key struct {
+	Kind     string // client flavor, e.g. "resolver" | "agentcard"
+	RPC      string
+	Contract string
+}
+
+func (k Key) norm() Key {
+	return Key{
+		Kind:     strings.ToLower(strings.TrimSpace(k.Kind)),
+		RPC:      strings.TrimRight(strings.TrimSpace(k.RPC), "/"),
+		Contract: strings.ToLower(strings.TrimSpace(k.Contract)),
+	}
+}
+
+// Dialer builds a new client for a key.
+type Dialer func() (any, error)
+
+type entry struct {
+	key      Key
+	dial     Dialer
+	val      any
+	refs     int
+	healthy  bool
+	lastErr  string
+	dialedAt time.Time
+}
+
+// Pool is a set of shared, reference-counted clients.
+type Pool struct {
+	mu      sync.Mutex
+	entries map[Key]*entry
+
+	dials      atomic.Int64
+	failures   atomic.Int64
+	reconnects atomic.Int64
+
+	probe    func(ctx context.Context, rpc string) error
+	interval time.Duration
+	stop     chan struct{} // health loop; nil when not running
+}
+
+// New returns an empty pool that probes endpoints every interval.
+func New(interval time.Duration) *Pool {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &Pool{entries: make(map[Key]*entry), probe: probeRPC, interval: interval}
+}
+
+// Lease is one consumer's hold on a shared client.
+type Lease struct {
+	p    *Pool
+	key  Key
+	once sync.Once
+}
+
+// Value returns the current client (the reconnected one after an outage).
+func (l *Lease) Value() any {
+	l.p.mu.Lock()
+	defer l.p.mu.Unlock()
+	if e, ok := l.p.entries[l.key]; ok {
+		return e.val
+	}
+	return nil
+}
+
+// Release drops the hold; the last release closes the client. Safe to call twice.
+func (l *Lease) Release() {
+	l.once.Do(func() { l.p.release(l.key) })
+}
+
+// Acquire returns a lease on the client for k, dialing it when no consumer
+// holds one yet (or when the existing one is marked unhealthy).
+func (p *Pool) Acquire(k Key, dial Dialer) (*Lease, error) {
+	k = k.norm()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[k]
+	if ok && !e.healthy {
+		if err := p.redialLocked(e); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		v, err := p.dialLocked(dial)
+		if err != nil {
+			return nil, err
+		}
+		e = &entry{key: k, dial: dial, val: v, healthy: true, dialedAt: time.Now()}
+		p.entries[k] = e
+		p.startLocked()
+	}
+	e.refs++
+	return &Lease{p: p, key: k}, nil
+}
+
+func (p *Pool) dialLocked(dial Dialer) (any, error) {
+	p.dials.Add(1)
+	v, err := dial()
+	if err != nil {
+		p.failures.Add(1)
+		return nil, err
+	}
+	return v, nil
+}
+
+// redialLocked replaces e's client with a fresh one.
+func (p *Pool) redialLocked(e *entry) error {
+	v, err := p.dialLocked(e.dial)
+	if err != nil {
+		e.lastErr = err.Error()
+		return fmt.Errorf("ethpool: redial %s: %w", e.key.RPC, err)
+	}
+	closeValue(e.val)
+	e.val, e.healthy, e.lastErr, e.dialedAt = v, true, "", time.Now()
+	p.reconnects.Add(1)
+	return nil
+}
+
+func (p *Pool) release(k Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[k]
+	if !ok {
+		return
+	}
+	if e.refs--; e.refs > 0 {
+		return
+	}
+	delete(p.entries, k)
+	closeValue(e.val)
+	if len(p.entries) == 0 && p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+func closeValue(v any) {
+	switch c := v.(type) {
+	case io.Closer:
+		_ = c.Close()
+	case interface{ Close() }:
+		c.Close()
+	}
+}
+
+func (p *Pool) startLocked() {
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	go p.healthLoop(p.stop)
+}
+
+func (p *Pool) healthLoop(stop chan struct{}) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			p.CheckHealth(context.Background())
+		}
+	}
+}
+
+// CheckHealth probes every endpoint once: failing endpoints mark their clients
+// unhealthy, recovered ones are redialed.
+func (p *Pool) CheckHealth(ctx context.Context) {
+	p.mu.Lock()
+	rpcs := map[string]bool{}
+	for k := range p.entries {
+		rpcs[k.RPC] = true
+	}
+	p.mu.Unlock()
+
+	for rpc := range rpcs {
+		pctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := p.probe(pctx, rpc)
+		cancel()
+
+		p.mu.Lock()
+		if err != nil {
+			p.failures.Add(1)
+		}
+		for k, e := range p.entries {
+			if k.RPC != rpc {
+				continue
+			}
+			switch {
+			case err != nil:
+				e.healthy, e.lastErr = false, err.Error()
+			case !e.healthy:
+				_ = p.redialLocked(e)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// probeRPC asks the endpoint for its chain id.
+func probeRPC(ctx context.Context, rpc string) error {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpc, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("rpc %s: HTTP %d", rpc, resp.StatusCode)
+	}
+	return nil
+}
+
+// EntryStats describes one shared client.
+type EntryStats struct {
+	Kind      string    `json:"kind"`
+	RPC       string    `json:"rpc"`
+	Contract  string    `json:"contract"`
+	Refs      int       `json:"refs"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"lastError,omitempty"`
+	DialedAt  time.Time `json:"dialedAt"`
+}
+
+// Stats is a point-in-time snapshot of the pool.
+type Stats struct {
+	Clients    int          `json:"clients"`
+	Dials      int64        `json:"dials"`
+	Failures   int64        `json:"failures"`
+	Reconnects int64        `json:"reconnects"`
+	Entries    []EntryStats `json:"entries"`
+}
+
+// Stats returns the pool's counters and clients.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := Stats{Clients: len(p.entries), Dials: p.dials.Load(), Failures: p.failures.Load(), Reconnects: p.reconnects.Load()}
+	for k, e := range p.entries {
+		st.Entries = append(st.Entries, EntryStats{
+			Kind: k.Kind, RPC: k.RPC, Contract: k.Contract, Refs: e.refs,
+			Healthy: e.healthy, LastError: e.lastErr, DialedAt: e.dialedAt,
+		})
+	}
+	sort.Slice(st.Entries, func(i, j int) bool {
+		a, b := st.Entries[i], st.Entries[j]
+		if a.RPC != b.RPC {
+			return a.RPC < b.RPC
+		}
+		if a.Contract != b.Contract {
+			return a.Contract < b.Contract
+		}
+		return a.Kind < b.Kind
+	})
+	return st
+}
+
+var (
+	defaultOnce sync.Once
+	defaultPool *Pool
+)
+
+// Default is the process-wide pool.
+func Default() *Pool {
+	defaultOnce.Do(func() {
+		iv := 15 * time.Second
+		if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("ETH_POOL_HEALTH_MS"))); err == nil && n > 0 {
+			iv = time.Duration(n) * time.Millisecond
+		}
+		defaultPool = New(iv)
+	})
+	return defaultPool
+}
+
+// Snapshot returns the process-wide pool's stats (for /status).
+func Snapshot() Stats { return Default().Stats() }
@@ -0,0 +1,135 @@
+package ethpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type client struct{ closed atomic.Bool }
+
+func (c *client) Close() { c.closed.Store(true) }
+
+// fakePool never probes on its own; tests drive CheckHealth with rpcDown.
+func fakePool(rpcDown *atomic.Bool) *Pool {
+	p := New(time.Hour)
+	p.probe = func(ctx context.Context, rpc string) error {
+		if rpcDown.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	return p
+}
+
+func TestSharedClient(t *testing.T) {
+	var down atomic.Bool
+	p := fakePool(&down)
+	dial := func() (any, error) { return &client{}, nil }
+
+	a, err := p.Acquire(Key{Kind: "resolver", RPC: "http://rpc.test/", Contract: "0xABC"}, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Acquire(Key{Kind: "Resolver", RPC: "http://rpc.test", Contract: "0xabc"}, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Value() != b.Value() {
+		t.Fatal("identical config got two clients")
+	}
+	if st := p.Stats(); st.Clients != 1 || st.Dials != 1 || st.Entries[0].Refs != 2 {
+		t.Fatalf("stats: %+v", st)
+	}
+	other, _ := p.Acquire(Key{Kind: "agentcard", RPC: "http://rpc.test", Contract: "0xabc"}, dial)
+	if other.Value() == a.Value() || p.Stats().Clients != 2 {
+		t.Fatal("different kinds shared a client")
+	}
+
+	c := a.Value().(*client)
+	a.Release()
+	a.Release() // second release is a no-op
+	if c.closed.Load() {
+		t.Fatal("closed while still leased")
+	}
+	b.Release()
+	if !c.closed.Load() || p.Stats().Clients != 1 {
+		t.Fatalf("last release: closed=%v stats=%+v", c.closed.Load(), p.Stats())
+	}
+	other.Release()
+}
+
+func TestOutageReconnects(t *testing.T) {
+	var down atomic.Bool
+	p := fakePool(&down)
+	var dials atomic.Int64
+	dial := func() (any, error) {
+		dials.Add(1)
+		return &client{}, nil
+	}
+	k := Key{Kind: "resolver", RPC: "http://rpc.test", Contract: "0xabc"}
+	l, err := p.Acquire(k, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	first := l.Value().(*client)
+
+	down.Store(true)
+	p.CheckHealth(context.Background())
+	if st := p.Stats(); st.Entries[0].Healthy || st.Entries[0].LastError == "" || st.Failures != 1 {
+		t.Fatalf("outage not recorded: %+v", st)
+	}
+
+	// the endpoint answers again: the next probe redials
+	down.Store(false)
+	p.CheckHealth(context.Background())
+	if l.Value() == any(first) || !first.closed.Load() || dials.Load() != 2 {
+		t.Fatal("probe did not replace the dead client")
+	}
+	if st := p.Stats(); !st.Entries[0].Healthy || st.Reconnects != 1 {
+		t.Fatalf("after recovery: %+v", st)
+	}
+
+	// an Acquire during an outage redials instead of handing out the dead client
+	down.Store(true)
+	p.CheckHealth(context.Background())
+	l2, err := p.Acquire(k, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Release()
+	if st := p.Stats(); !st.Entries[0].Healthy || st.Reconnects != 2 || dials.Load() != 3 {
+		t.Fatalf("acquire after outage: %+v", st)
+	}
+}
+
+func TestRedialFailureKeepsUnhealthy(t *testing.T) {
+	var down atomic.Bool
+	p := fakePool(&down)
+	var fail atomic.Bool
+	dial := func() (any, error) {
+		if fail.Load() {
+			return nil, errors.New("dial refused")
+		}
+		return &client{}, nil
+	}
+	k := Key{Kind: "resolver", RPC: "http://rpc.test"}
+	l, _ := p.Acquire(k, dial)
+	defer l.Release()
+
+	down.Store(true)
+	p.CheckHealth(context.Background())
+	fail.Store(true)
+	if _, err := p.Acquire(k, dial); err == nil {
+		t.Fatal("acquire succeeded while the redial failed")
+	}
+	fail.Store(false)
+	if l2, err := p.Acquire(k, dial); err != nil {
+		t.Fatalf("acquire after the endpoint recovered: %v", err)
+	} else {
+		l2.Release()
+	}
+}
@@ -0,0 +1,287 @@
+// Package fieldenc seals individual metadata values for deployments that
+// cannot run HPKE.
+//
+// Root replaces each configured metadata key (by default payment.shipping,
+// medical.symptoms and medical.history) with an envelope
+//
+//	{"enc": "v1", "data": "<base64(nonce || AES-256-GCM ciphertext)>"}
+//
+// whose plaintext is the value's JSON encoding and whose additional data is
+// the key name, so an envelope cannot be moved to another key. The AES key is
+// a static 32-byte secret shared out of band with one upstream agent (one key
+// file per target); the receiving agent opens the envelopes it can and leaves
+// the rest in place.
+//
+// This is weaker than HPKE: the key is long-lived (no forward secrecy, no
+// per-session keys), anyone holding the file can read every past message, and
+// only the listed values are hidden — the content, other metadata and the
+// response still travel in cleartext. The signed Content-Digest covers the
+// envelopes as sent, so tampering is still detected by the signature check.
+package fieldenc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Version is the envelope format tag.
+const Version = "v1"
+
+// KeySize is the AES-256 key length in bytes.
+const KeySize = 32
+
+// DefaultFields are sealed when FIELDENC_FIELDS is unset.
+var DefaultFields = []string{"payment.shipping", "medical.symptoms", "medical.history"}
+
+// ErrNoKey is reported for an envelope when no key is configured.
+var ErrNoKey = errors.New("fieldenc: no key")
+
+// Envelope is the sealed form of one metadata value.
+type Envelope struct {
+	Enc  string `json:"enc"`
+	Data string `json:"data"`
+}
+
+// Key seals and opens envelopes for one upstream.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// NewKey wraps a raw 32-byte key.
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("fieldenc: key must be %d bytes, got %d", KeySize, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// LoadKey reads a key file holding the 32 bytes as base64 or hex (e.g.
+// `openssl rand -base64 32`).
+func LoadKey(path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSpace(string(b))
+	if raw, err := base64.StdEncoding.DecodeString(s); err == nil && len(raw) == KeySize {
+		return NewKey(raw)
+	}
+	if raw, err := hex.DecodeString(strings.TrimPrefix(s, "0x")); err == nil && len(raw) == KeySize {
+		return NewKey(raw)
+	}
+	return nil, fmt.Errorf("fieldenc: %s: want %d bytes as base64 or hex", path, KeySize)
+}
+
+// KeyFromEnv loads the key file named by env; nil, nil when env is unset.
+func KeyFromEnv(env string) (*Key, error) {
+	p := strings.TrimSpace(os.Getenv(env))
+	if p == "" {
+		return nil, nil
+	}
+	k, err := LoadKey(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", env, err)
+	}
+	return k, nil
+}
+
+// FieldsFromEnv reads FIELDENC_FIELDS (comma-separated metadata keys).
+func FieldsFromEnv() []string {
+	v := strings.TrimSpace(os.Getenv("FIELDENC_FIELDS"))
+	if v == "" {
+		return append([]string(nil), DefaultFields...)
+	}
+	var out []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (k *Key) seal(field string, v any) (Envelope, error) {
+	pt, err := json.Marshal(v)
+	if err != nil {
+		return Envelope{}, err
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Envelope{}, err
+	}
+	ct := k.aead.Seal(nonce, nonce, pt, []byte(field))
+	return Envelope{Enc: Version, Data: base64.StdEncoding.EncodeToString(ct)}, nil
+}
+
+func (k *Key) open(field string, env Envelope) (any, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	ct, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("fieldenc: %s: %w", field, err)
+	}
+	ns := k.aead.NonceSize()
+	if len(ct) < ns {
+		return nil, fmt.Errorf("fieldenc: %s: short ciphertext", field)
+	}
+	pt, err := k.aead.Open(nil, ct[:ns], ct[ns:], []byte(field))
+	if err != nil {
+		return nil, fmt.Errorf("fieldenc: %s: %w", field, err)
+	}
+	var v any
+	if err := json.Unmarshal(pt, &v); err != nil {
+		return nil, fmt.Errorf("fieldenc: %s: %w", field, err)
+	}
+	return v, nil
+}
+
+// Seal returns a copy of meta with the present fields replaced by envelopes,
+// and the keys it sealed (sorted). meta itself is not modified.
+func (k *Key) Seal(meta map[string]any, fields []string) (map[string]any, []string, error) {
+	out := make(map[string]any, len(meta))
+	for key, v := range meta {
+		out[key] = v
+	}
+	var sealed []string
+	for _, f := range fields {
+		v, ok := out[f]
+		if !ok || v == nil || IsEnvelope(v) {
+			continue
+		}
+		env, err := k.seal(f, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		out[f] = env
+		sealed = append(sealed, f)
+	}
+	sort.Strings(sealed)
+	return out, sealed, nil
+}
+
+// Open replaces the envelopes in meta that k can open with their values, in
+// place. Envelopes it cannot open (no key, wrong key, corrupted) stay as they
+// are and are returned in unreadable. A nil Key opens nothing.
+func (k *Key) Open(meta map[string]any) (opened, unreadable []string) {
+	for f, v := range meta {
+		env, ok := asEnvelope(v)
+		if !ok {
+			continue
+		}
+		pv, err := k.open(f, env)
+		if err != nil {
+			unreadable = append(unreadable, f)
+			continue
+		}
+		meta[f] = pv
+		opened = append(opened, f)
+	}
+	sort.Strings(opened)
+	sort.Strings(unreadable)
+	return opened, unreadable
+}
+
+// IsEnvelope reports whether v is a sealed value (as built by Seal or as
+// decoded from JSON).
+func IsEnvelope(v any) bool {
+	_, ok := asEnvelope(v)
+	return ok
+}
+
+func asEnvelope(v any) (Envelope, bool) {
+	switch t := v.(type) {
+	case Envelope:
+		return t, t.Enc == Version
+	case map[string]any:
+		enc, _ := t["enc"].(string)
+		data, _ := t["data"].(string)
+		if enc != Version || data == "" || len(t) != 2 {
+			return Envelope{}, false
+		}
+		return Envelope{Enc: enc, Data: data}, true
+	}
+	return Envelope{}, false
+}
+
+// ---- per-request report (receiving side) ----
+
+// Report records what one request's Open did, so the answer can say which
+// values the agent could not read.
+type Report struct {
+	mu         sync.Mutex
+	opened     []string
+	unreadable []string
+}
+
+type ctxKey struct{}
+
+// WithReport attaches an empty Report to ctx.
+func WithReport(ctx context.Context) (context.Context, *Report) {
+	rep := &Report{}
+	return context.WithValue(ctx, ctxKey{}, rep), rep
+}
+
+// ReportFrom returns ctx's Report, or nil.
+func ReportFrom(ctx context.Context) *Report {
+	rep, _ := ctx.Value(ctxKey{}).(*Report)
+	return rep
+}
+
+// Note records an Open result; nil-safe.
+func (r *Report) Note(opened, unreadable []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.opened = append(r.opened, opened...)
+	r.unreadable = append(r.unreadable, unreadable...)
+	r.mu.Unlock()
+}
+
+// Empty reports whether nothing was sealed in the request; nil-safe.
+func (r *Report) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.opened) == 0 && len(r.unreadable) == 0
+}
+
+// Stamp sets metadata "fieldEnc" {opened, unreadable} when anything was sealed.
+func (r *Report) Stamp(meta map[string]any) map[string]any {
+	if r.Empty() {
+		return meta
+	}
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fe := map[string]any{"opened": append([]string{}, r.opened...)}
+	if len(r.unreadable) > 0 {
+		fe["unreadable"] = append([]string{}, r.unreadable...)
+	}
+	meta["fieldEnc"] = fe
+	return meta
+}
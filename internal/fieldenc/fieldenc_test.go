@@ -0,0 +1,147 @@
+package fieldenc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testKey(t *testing.T, b byte) *Key {
+	t.Helper()
+	k, err := NewKey(bytes.Repeat([]byte{b}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// wire round-trips meta through JSON, as it travels.
+func wire(t *testing.T, meta map[string]any) map[string]any {
+	t.Helper()
+	b, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSealOpen(t *testing.T) {
+	k := testKey(t, 1)
+	meta := map[string]any{
+		"lang":             "ko",
+		"payment.shipping": map[string]any{"address": "서울시 강남구", "zip": "06000"},
+		"medical.symptoms": []any{"두통", "발열"},
+	}
+	sealedMeta, keys, err := k.Seal(meta, DefaultFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"medical.symptoms", "payment.shipping"}) {
+		t.Fatalf("sealed %v", keys)
+	}
+	if IsEnvelope(meta["payment.shipping"]) || !IsEnvelope(sealedMeta["payment.shipping"]) || sealedMeta["lang"] != "ko" {
+		t.Fatalf("input modified or output not sealed: %v / %v", meta, sealedMeta)
+	}
+	if b, _ := json.Marshal(sealedMeta); bytes.Contains(b, []byte("강남구")) {
+		t.Fatalf("plaintext in sealed form: %s", b)
+	}
+
+	// sealing again leaves envelopes alone
+	if _, again, _ := k.Seal(sealedMeta, DefaultFields); len(again) != 0 {
+		t.Fatalf("sealed twice: %v", again)
+	}
+
+	got := wire(t, sealedMeta)
+	opened, unreadable := k.Open(got)
+	if len(unreadable) != 0 || !reflect.DeepEqual(opened, keys) {
+		t.Fatalf("opened %v unreadable %v", opened, unreadable)
+	}
+	if !reflect.DeepEqual(got, wire(t, meta)) {
+		t.Fatalf("round trip: %v", got)
+	}
+}
+
+// The key name is the additional data: an envelope moved to another key, a
+// changed ciphertext, or the wrong key leaves the value unreadable.
+func TestOpenRefuses(t *testing.T) {
+	k := testKey(t, 1)
+	sealed := func() map[string]any {
+		m, _, err := k.Seal(map[string]any{"medical.history": "2019 수술", "medical.symptoms": "기침"}, DefaultFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wire(t, m)
+	}
+	flip := func(env any) any {
+		e := env.(map[string]any)
+		raw, _ := base64.StdEncoding.DecodeString(e["data"].(string))
+		raw[len(raw)-1] ^= 1
+		return map[string]any{"enc": Version, "data": base64.StdEncoding.EncodeToString(raw)}
+	}
+
+	cases := []struct {
+		name   string
+		key    *Key
+		change func(m map[string]any)
+		want   []string
+	}{
+		{"moved to another key", k, func(m map[string]any) {
+			m["medical.history"], m["medical.symptoms"] = m["medical.symptoms"], m["medical.history"]
+		}, []string{"medical.history", "medical.symptoms"}},
+		{"ciphertext changed", k, func(m map[string]any) { m["medical.history"] = flip(m["medical.history"]) }, []string{"medical.history"}},
+		{"not base64", k, func(m map[string]any) { m["medical.history"] = map[string]any{"enc": Version, "data": "%%"} }, []string{"medical.history"}},
+		{"wrong key", testKey(t, 2), func(map[string]any) {}, []string{"medical.history", "medical.symptoms"}},
+		{"no key", nil, func(map[string]any) {}, []string{"medical.history", "medical.symptoms"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := sealed()
+			tc.change(m)
+			before := wire(t, m)
+			_, unreadable := tc.key.Open(m)
+			if !reflect.DeepEqual(unreadable, tc.want) {
+				t.Fatalf("unreadable %v, want %v", unreadable, tc.want)
+			}
+			for _, f := range tc.want {
+				if !reflect.DeepEqual(m[f], before[f]) {
+					t.Fatalf("%s changed although unreadable", f)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	raw := bytes.Repeat([]byte{7}, KeySize)
+	files := map[string]string{
+		"b64":   base64.StdEncoding.EncodeToString(raw) + "\n",
+		"hex":   "0x" + "07070707070707070707070707070707" + "07070707070707070707070707070707",
+		"short": base64.StdEncoding.EncodeToString(raw[:16]),
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"b64", "hex"} {
+		if _, err := LoadKey(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if _, err := LoadKey(filepath.Join(dir, "short")); err == nil {
+		t.Fatal("16-byte key accepted")
+	}
+	t.Setenv("TEST_FIELDENC_KEY_FILE", "")
+	if k, err := KeyFromEnv("TEST_FIELDENC_KEY_FILE"); k != nil || err != nil {
+		t.Fatalf("unset env: %v %v", k, err)
+	}
+}
@@ -0,0 +1,327 @@
+// Package flags is a small runtime feature-flag registry for experimental
+// agent behaviors that used to be env-only and needed a restart to flip.
+//
+// Flags are registered in code (package-level vars) with a name, default,
+// owner component and description. The boot value comes from, in order:
+//
+//	state file (operator choice persisted by a previous toggle)
+//	command-line flag passed to Boot
+//	env var named in the spec ("1|true|on|yes" = on, anything else = off)
+//	default
+//
+// Components read a flag through (*Flag).On, a single atomic load. Handler
+// serves GET /flags (list) and POST /flags/{name} (toggle, gated by the
+// caller's authorizer) and emits an audit event per toggle.
+package flags
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/internal/statefile"
+)
+
+// ErrUnknown is returned by Set for a name that was never registered.
+var ErrUnknown = errors.New("unknown flag")
+
+// Spec describes a flag at registration.
+type Spec struct {
+	Name        string // dotted, owner-prefixed: "root.hpke_kem_strict"
+	Env         string // optional boot-time env override
+	Default     bool
+	Owner       string // component that reads the flag
+	Description string
+}
+
+// Flag is a registered flag. The zero value is never handed out.
+type Flag struct {
+	spec   Spec
+	v      atomic.Bool
+	source atomic.Value // string: default | env | boot | state | runtime
+}
+
+// On reports the current value.
+func (f *Flag) On() bool { return f.v.Load() }
+
+// Name returns the registered name.
+func (f *Flag) Name() string { return f.spec.Name }
+
+// Info is the listed view of a flag.
+type Info struct {
+	Name        string `json:"name"`
+	Value       bool   `json:"value"`
+	Default     bool   `json:"default"`
+	Env         string `json:"env,omitempty"`
+	Owner       string `json:"owner"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+}
+
+var (
+	mu        sync.Mutex
+	registry  = map[string]*Flag{}
+	statePath string
+	state     = map[string]bool{} // persisted operator choices
+)
+
+func parseBool(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// Register adds a flag and returns it. Registering the same name twice panics.
+func Register(s Spec) *Flag {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[s.Name]; dup {
+		panic("flags: duplicate flag " + s.Name)
+	}
+	f := &Flag{spec: s}
+	f.v.Store(s.Default)
+	f.source.Store("default")
+	if s.Env != "" {
+		if v := strings.TrimSpace(os.Getenv(s.Env)); v != "" {
+			f.v.Store(parseBool(v))
+			f.source.Store("env")
+		}
+	}
+	if v, ok := state[s.Name]; ok {
+		f.v.Store(v)
+		f.source.Store("state")
+	}
+	registry[s.Name] = f
+	return f
+}
+
+// Boot applies a boot-time value (a command-line flag, say) to name unless a
+// persisted or runtime choice already holds. Unknown names are ignored.
+func Boot(name string, v bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	f, ok := registry[name]
+	if !ok {
+		return
+	}
+	if src := f.source.Load().(string); src == "state" || src == "runtime" {
+		return
+	}
+	f.v.Store(v)
+	f.source.Store("boot")
+}
+
+// Persist loads operator choices from path, applies them to registered flags
+// and saves later toggles there. An empty path keeps toggles in memory only.
+func Persist(path string) error {
+	path = strings.TrimSpace(path)
+	mu.Lock()
+	defer mu.Unlock()
+	statePath = path
+	if path == "" {
+		return nil
+	}
+	loaded := map[string]bool{}
+	if _, err := statefile.LoadJSON(path, &loaded, statefile.Options{}); err != nil {
+		return fmt.Errorf("flags state %s: %w", path, err)
+	}
+	for k, v := range loaded {
+		state[k] = v
+		if f, ok := registry[k]; ok {
+			f.v.Store(v)
+			f.source.Store("state")
+		}
+	}
+	return nil
+}
+
+// saveLocked writes the state file atomically (see internal/statefile); mu
+// must be held.
+func saveLocked() error {
+	if statePath == "" {
+		return nil
+	}
+	return statefile.SaveJSON(statePath, state, statefile.Options{})
+}
+
+// Set changes a flag at runtime and persists the choice. It returns the previous value.
+func Set(name string, v bool) (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	f, ok := registry[name]
+	if !ok {
+		return false, fmt.Errorf("%w %q", ErrUnknown, name)
+	}
+	old := f.v.Swap(v)
+	f.source.Store("runtime")
+	state[name] = v
+	if err := saveLocked(); err != nil {
+		return old, fmt.Errorf("flag set but not persisted: %w", err)
+	}
+	return old, nil
+}
+
+func persisted() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return statePath != ""
+}
+
+// List returns all flags sorted by name.
+func List() []Info {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Info, 0, len(registry))
+	for _, f := range registry {
+		out = append(out, Info{
+			Name: f.spec.Name, Value: f.On(), Default: f.spec.Default, Env: f.spec.Env,
+			Owner: f.spec.Owner, Description: f.spec.Description, Source: f.source.Load().(string),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// TokenAuth authorizes requests carrying the token in envKey as
+// "X-Admin-Token" or "Authorization: Bearer". An unset token denies all.
+func TokenAuth(envKey string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		want := strings.TrimSpace(os.Getenv(envKey))
+		got := strings.TrimSpace(r.Header.Get("X-Admin-Token"))
+		if got == "" {
+			if a := r.Header.Get("Authorization"); len(a) > 7 && strings.EqualFold(a[:7], "bearer ") {
+				got = strings.TrimSpace(a[7:])
+			}
+		}
+		return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// problem is one reason a toggle body was refused.
+type problem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+const maxToggleBody = 4 << 10
+
+// decodeToggle reads {"value":true|false} strictly: unknown fields, a missing
+// or non-boolean value and trailing data are all reported at once.
+func decodeToggle(r *http.Request) (bool, []problem) {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxToggleBody+1))
+	if err != nil || len(b) > maxToggleBody {
+		return false, []problem{{Message: fmt.Sprintf("body unreadable or over %d bytes", maxToggleBody)}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var raw map[string]json.RawMessage
+	if err := dec.Decode(&raw); err != nil || raw == nil {
+		return false, []problem{{Message: `body must be {"value":true|false}`}}
+	}
+	var probs []problem
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		probs = append(probs, problem{Message: "unexpected data after the JSON object"})
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k != "value" {
+			probs = append(probs, problem{Field: k, Message: "unknown field"})
+		}
+	}
+	var v bool
+	if rv, ok := raw["value"]; !ok || string(rv) == "null" {
+		probs = append(probs, problem{Field: "value", Message: "required"})
+	} else if json.Unmarshal(rv, &v) != nil {
+		probs = append(probs, problem{Field: "value", Message: "must be a boolean"})
+	}
+	return v, probs
+}
+
+// Handler serves GET /flags and POST /flags/{name}; mount it on both "/flags"
+// and "/flags/". The toggle body is {"value":true|false}, decoded strictly
+// (400 {"error":"invalid_request","problems":[...]}); "?value=" also works.
+func Handler(authorized func(*http.Request) bool, al *audit.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/flags"), "/")
+		if name == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"flags": List(), "persisted": persisted()})
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if authorized == nil || !authorized(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var value bool
+		if q := r.URL.Query().Get("value"); q != "" {
+			b, err := strconv.ParseBool(q)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request", "problems": []problem{{Field: "value", Message: "must be true or false"}}})
+				return
+			}
+			value = b
+		} else {
+			v, probs := decodeToggle(r)
+			if len(probs) > 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request", "problems": probs})
+				return
+			}
+			value = v
+		}
+		old, err := Set(name, value)
+		if errors.Is(err, ErrUnknown) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "flag_not_found", "name": name})
+			return
+		}
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		al.Emit(audit.Event{
+			Type: "flags", Action: "flag.toggle", Outcome: outcome, Actor: r.RemoteAddr, Target: name,
+			Detail: map[string]any{"old": old, "new": value, "error": errString(err)},
+		})
+		resp := map[string]any{"name": name, "old": old, "value": value, "time": time.Now().Format(time.RFC3339)}
+		if err != nil {
+			resp["warning"] = err.Error()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
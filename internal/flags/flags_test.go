@@ -0,0 +1,179 @@
+package flags
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate gives the test an empty registry and restores the real one after.
+func isolate(t *testing.T) {
+	t.Helper()
+	mu.Lock()
+	savedReg, savedState, savedPath := registry, state, statePath
+	registry, state, statePath = map[string]*Flag{}, map[string]bool{}, ""
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		registry, state, statePath = savedReg, savedState, savedPath
+		mu.Unlock()
+	})
+}
+
+func info(t *testing.T, name string) Info {
+	t.Helper()
+	for _, i := range List() {
+		if i.Name == name {
+			return i
+		}
+	}
+	t.Fatalf("flag %s not listed", name)
+	return Info{}
+}
+
+func TestBootValue(t *testing.T) {
+	isolate(t)
+	t.Setenv("TEST_FLAG_ON", "yes")
+	t.Setenv("TEST_FLAG_OFF", "0")
+	on := Register(Spec{Name: "t.env_on", Env: "TEST_FLAG_ON"})
+	off := Register(Spec{Name: "t.env_off", Env: "TEST_FLAG_OFF", Default: true})
+	def := Register(Spec{Name: "t.default", Env: "TEST_FLAG_UNSET", Default: true})
+	if !on.On() || off.On() || !def.On() {
+		t.Fatalf("on=%v off=%v default=%v", on.On(), off.On(), def.On())
+	}
+	if info(t, "t.env_on").Source != "env" || info(t, "t.default").Source != "default" {
+		t.Fatalf("sources: %+v", List())
+	}
+
+	// A boot value overrides env, but not a runtime choice
+	Boot("t.env_on", false)
+	if on.On() || info(t, "t.env_on").Source != "boot" {
+		t.Fatalf("boot: %+v", info(t, "t.env_on"))
+	}
+	if _, err := Set("t.default", false); err != nil {
+		t.Fatal(err)
+	}
+	Boot("t.default", true)
+	if def.On() {
+		t.Fatal("boot overrode a runtime toggle")
+	}
+}
+
+// A toggle is saved and wins over env on the next boot.
+func TestPersistence(t *testing.T) {
+	isolate(t)
+	path := filepath.Join(t.TempDir(), "flags.json")
+	t.Setenv("TEST_FLAG", "on")
+	f := Register(Spec{Name: "t.persist", Env: "TEST_FLAG"})
+	if err := Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	if old, err := Set("t.persist", false); err != nil || !old {
+		t.Fatalf("set: old=%v err=%v", old, err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(raw), `"t.persist": false`) {
+		t.Fatalf("state file %q: %v", raw, err)
+	}
+
+	// next process: same env, the saved choice applies whether the flag is
+	// registered before or after Persist
+	mu.Lock()
+	registry, state, statePath = map[string]*Flag{}, map[string]bool{}, ""
+	mu.Unlock()
+	f = Register(Spec{Name: "t.persist", Env: "TEST_FLAG"})
+	if err := Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	later := Register(Spec{Name: "t.later", Env: "TEST_FLAG"})
+	if f.On() || info(t, "t.persist").Source != "state" || !later.On() {
+		t.Fatalf("after restart: %+v", List())
+	}
+	if _, err := Set("t.nope", true); err == nil {
+		t.Fatal("unknown flag set")
+	}
+}
+
+func TestHandlerToggle(t *testing.T) {
+	isolate(t)
+	t.Setenv("TEST_ADMIN", "tok")
+	f := Register(Spec{Name: "t.toggle"})
+	h := Handler(TokenAuth("TEST_ADMIN"), nil)
+	call := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(http.MethodGet, "/flags", "", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"t.toggle"`) {
+		t.Fatalf("list: %d %s", w.Code, w.Body)
+	}
+	if w := call(http.MethodPost, "/flags/t.toggle", `{"value":true}`, "wrong"); w.Code != http.StatusForbidden || f.On() {
+		t.Fatalf("bad token: %d", w.Code)
+	}
+	if w := call(http.MethodPost, "/flags/t.missing", `{"value":true}`, "tok"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown: %d", w.Code)
+	}
+	w := call(http.MethodPost, "/flags/t.toggle", `{"value":true}`, "tok")
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if w.Code != http.StatusOK || !f.On() || out["old"] != false || out["value"] != true {
+		t.Fatalf("toggle: %d %s", w.Code, w.Body)
+	}
+	if w := call(http.MethodPost, "/flags/t.toggle?value=false", "", "tok"); w.Code != http.StatusOK || f.On() {
+		t.Fatalf("query toggle: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestHandlerStrictBody(t *testing.T) {
+	isolate(t)
+	t.Setenv("TEST_ADMIN", "tok")
+	f := Register(Spec{Name: "t.strict"})
+	h := Handler(TokenAuth("TEST_ADMIN"), nil)
+	cases := []struct {
+		name, body string
+		want       []problem
+	}{
+		{"unknown field", `{"value":true,"enable":true}`, []problem{{"enable", "unknown field"}}},
+		{"wrong type", `{"value":"true"}`, []problem{{"value", "must be a boolean"}}},
+		{"missing", `{}`, []problem{{"value", "required"}}},
+		{"trailing data", `{"value":true} {"value":false}`, []problem{{"", "unexpected data after the JSON object"}}},
+		{"every problem", `{"valeu":true,"x":1} []`, []problem{{"", "unexpected data after the JSON object"}, {"valeu", "unknown field"}, {"x", "unknown field"}, {"value", "required"}}},
+		{"not an object", `true`, []problem{{"", `body must be {"value":true|false}`}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/flags/t.strict", strings.NewReader(tc.body))
+			req.Header.Set("X-Admin-Token", "tok")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			var out struct {
+				Error    string    `json:"error"`
+				Problems []problem `json:"problems"`
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &out)
+			if w.Code != http.StatusBadRequest || out.Error != "invalid_request" {
+				t.Fatalf("%d %s", w.Code, w.Body)
+			}
+			if got, _ := json.Marshal(out.Problems); string(got) != mustJSON(tc.want) {
+				t.Fatalf("problems %s, want %s", got, mustJSON(tc.want))
+			}
+			if f.On() {
+				t.Fatal("flag toggled by a refused body")
+			}
+		})
+	}
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
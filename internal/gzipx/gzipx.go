@@ -0,0 +1,267 @@
+// Package gzipx is the optional gzip layer of the agent HTTP servers and
+// Root's outbound transport.
+//
+// Responses: Handler compresses a response when the client sent
+// Accept-Encoding: gzip and the body reaches GZIP_MIN_BYTES (default 1024).
+// GZIP_RESPONSES=false turns it off. HPKE ciphertext (application/sage+hpke or
+// X-SAGE-HPKE) and responses that already carry Content-Encoding or a
+// Content-Digest are sent as-is, so a digest always matches the bytes on the
+// wire.
+//
+// Requests: with GZIP_REQUESTS=true, Root compresses plain (non-HPKE) /process
+// bodies of at least GZIP_MIN_BYTES before signing. Content-Digest and the RFC
+// 9421 signature therefore cover the compressed representation as sent. Receivers
+// verify the raw received bytes in the DID middleware and inflate only
+// afterwards, with RequestBody in the /process handler. Inflated bodies are
+// capped at GZIP_MAX_INFLATE_BYTES (default 16 MiB). A gzip-encoded HPKE body
+// is rejected.
+package gzipx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrHPKECompressed rejects a gzip Content-Encoding on an HPKE body.
+var ErrHPKECompressed = errors.New("gzipx: HPKE bodies must not be compressed")
+
+// MinBytes is the compression threshold (GZIP_MIN_BYTES, default 1024).
+func MinBytes() int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("GZIP_MIN_BYTES"))); err == nil && n > 0 {
+		return n
+	}
+	return 1024
+}
+
+func envBool(k string, d bool) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(k))) {
+	case "1", "true", "on", "yes":
+		return true
+	case "0", "false", "off", "no":
+		return false
+	}
+	return d
+}
+
+// OutboundMinBytes is the request compression threshold for Root's outbound
+// transport, or 0 when GZIP_REQUESTS is not enabled.
+func OutboundMinBytes() int {
+	if !envBool("GZIP_REQUESTS", false) {
+		return 0
+	}
+	return MinBytes()
+}
+
+func maxInflate() int64 {
+	if n, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("GZIP_MAX_INFLATE_BYTES")), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 16 << 20
+}
+
+func isHPKE(h http.Header) bool {
+	return strings.TrimSpace(h.Get("X-SAGE-HPKE")) != "" ||
+		strings.HasPrefix(strings.ToLower(h.Get("Content-Type")), "application/sage+hpke")
+}
+
+// Compress gzips b.
+func Compress(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// RequestBody returns r's body, inflated when Content-Encoding is gzip. Call it
+// after signature/digest verification: those cover the compressed bytes.
+func RequestBody(r *http.Request) (io.Reader, error) {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch enc {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip", "x-gzip":
+	default:
+		return nil, fmt.Errorf("gzipx: unsupported Content-Encoding %q", enc)
+	}
+	if isHPKE(r.Header) {
+		return nil, ErrHPKECompressed
+	}
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("gzipx: %w", err)
+	}
+	return &capReader{r: zr, left: maxInflate()}, nil
+}
+
+// capReader fails instead of silently truncating an oversized inflated body.
+type capReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.left <= 0 {
+		var one [1]byte
+		if n, _ := c.r.Read(one[:]); n > 0 {
+			return 0, errors.New("gzipx: inflated body exceeds GZIP_MAX_INFLATE_BYTES")
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.left {
+		p = p[:c.left]
+	}
+	n, err := c.r.Read(p)
+	c.left -= int64(n)
+	return n, err
+}
+
+var writerPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+// Handler compresses responses of next (see package doc).
+func Handler(next http.Handler) http.Handler {
+	if !envBool("GZIP_RESPONSES", true) {
+		return next
+	}
+	min := MinBytes()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &respWriter{ResponseWriter: w, min: min}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, q, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(enc), "gzip") && strings.TrimSpace(q) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// respWriter buffers up to min bytes, then decides: compress (and stream) or
+// pass through unchanged.
+type respWriter struct {
+	http.ResponseWriter
+	min     int
+	status  int
+	buf     []byte
+	decided bool
+	zw      *gzip.Writer
+}
+
+func (g *respWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *respWriter) Write(b []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if g.decided {
+		if g.zw != nil {
+			return g.zw.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= g.min {
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// eligible reports whether the response may be compressed at all.
+func (g *respWriter) eligible() bool {
+	h := g.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Digest") != "" || isHPKE(h) {
+		return false
+	}
+	switch g.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return g.status >= 200
+}
+
+// decide writes the header and the buffered bytes, compressed when big is
+// set and the response is eligible.
+func (g *respWriter) decide(big bool) error {
+	g.decided = true
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if big && g.eligible() {
+		h := g.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.zw = writerPool.Get().(*gzip.Writer)
+		g.zw.Reset(g.ResponseWriter)
+		g.ResponseWriter.WriteHeader(g.status)
+		_, err := g.zw.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+// Flush sends what has been written so far (compressing from here on if the
+// threshold was reached).
+func (g *respWriter) Flush() {
+	if !g.decided {
+		_ = g.decide(len(g.buf) >= g.min)
+	}
+	if g.zw != nil {
+		_ = g.zw.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through for upgrade handlers.
+func (g *respWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := g.ResponseWriter.(http.Hijacker); ok {
+		g.decided = true
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (g *respWriter) finish() {
+	if !g.decided {
+		if g.status == 0 && len(g.buf) == 0 {
+			return // handler wrote nothing
+		}
+		_ = g.decide(false)
+	}
+	if g.zw != nil {
+		_ = g.zw.Close()
+		writerPool.Put(g.zw)
+		g.zw = nil
+	}
+}
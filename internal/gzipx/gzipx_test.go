@@ -0,0 +1,204 @@
+package gzipx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// digest is the Content-Digest value over exactly the bytes given.
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func inflate(t *testing.T, b []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestOutboundMinBytes(t *testing.T) {
+	t.Setenv("GZIP_MIN_BYTES", "2048")
+	t.Setenv("GZIP_REQUESTS", "")
+	if n := OutboundMinBytes(); n != 0 {
+		t.Fatalf("requests off: %d", n)
+	}
+	t.Setenv("GZIP_REQUESTS", "true")
+	if n := OutboundMinBytes(); n != 2048 {
+		t.Fatalf("requests on: %d", n)
+	}
+	t.Setenv("GZIP_MIN_BYTES", "junk")
+	if n := MinBytes(); n != 1024 {
+		t.Fatalf("default threshold: %d", n)
+	}
+}
+
+// The sender signs the compressed bytes; the receiver checks the digest over
+// the raw body first and inflates only afterwards.
+func TestSignedRequestRoundTrip(t *testing.T) {
+	plain := []byte(`{"content":"` + strings.Repeat("가", 2000) + `"}`)
+	wire := Compress(plain)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Digest") != digest(raw) {
+			http.Error(w, "digest mismatch", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		src, err := RequestBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = io.Copy(w, src)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(wire))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Digest", digest(wire))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, plain) {
+		t.Fatalf("HTTP %d, %d bytes back", resp.StatusCode, len(got))
+	}
+
+	// a digest over the uncompressed form does not verify
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(wire))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Digest", digest(plain))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("digest over plain bytes: HTTP %d", resp.StatusCode)
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+	req := func(body []byte, h map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/process", bytes.NewReader(body))
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	src, err := RequestBody(req([]byte("plain"), nil))
+	if b, _ := io.ReadAll(src); err != nil || string(b) != "plain" {
+		t.Fatalf("identity: %q %v", b, err)
+	}
+	if _, err := RequestBody(req(Compress([]byte("x")), map[string]string{"Content-Encoding": "gzip", "X-SAGE-HPKE": "v1"})); !errors.Is(err, ErrHPKECompressed) {
+		t.Fatalf("gzip HPKE body: %v", err)
+	}
+	if _, err := RequestBody(req(Compress([]byte("x")), map[string]string{"Content-Encoding": "gzip", "Content-Type": "application/sage+hpke"})); !errors.Is(err, ErrHPKECompressed) {
+		t.Fatalf("gzip HPKE content type: %v", err)
+	}
+	if _, err := RequestBody(req([]byte("x"), map[string]string{"Content-Encoding": "br"})); err == nil {
+		t.Fatal("unsupported encoding accepted")
+	}
+
+	t.Setenv("GZIP_MAX_INFLATE_BYTES", "100")
+	src, err = RequestBody(req(Compress(bytes.Repeat([]byte("a"), 100)), map[string]string{"Content-Encoding": "gzip"}))
+	if b, rerr := io.ReadAll(src); err != nil || rerr != nil || len(b) != 100 {
+		t.Fatalf("at the cap: %d bytes, %v %v", len(b), err, rerr)
+	}
+	src, _ = RequestBody(req(Compress(bytes.Repeat([]byte("a"), 5000)), map[string]string{"Content-Encoding": "gzip"}))
+	if _, err := io.ReadAll(src); err == nil || !strings.Contains(err.Error(), "GZIP_MAX_INFLATE_BYTES") {
+		t.Fatalf("over the cap: %v", err)
+	}
+}
+
+func TestHandlerThreshold(t *testing.T) {
+	t.Setenv("GZIP_MIN_BYTES", "100")
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hpke":
+			w.Header().Set("Content-Type", "application/sage+hpke")
+		case "/digest":
+			w.Header().Set("Content-Digest", "sha-256=:x:")
+		}
+		_, _ = w.Write([]byte(strings.Repeat("a", len(r.URL.Query().Get("n")))))
+	}))
+	get := func(path, n string, accept bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path+"?n="+n, nil)
+		if accept {
+			r.Header.Set("Accept-Encoding", "gzip, deflate")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	big := strings.Repeat("1", 100)
+
+	w := get("/", big, true)
+	if w.Header().Get("Content-Encoding") != "gzip" || string(inflate(t, w.Body.Bytes())) != strings.Repeat("a", 100) {
+		t.Fatalf("at the threshold: %v", w.Header())
+	}
+	if w := get("/", big[:99], true); w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 99 {
+		t.Fatalf("below the threshold: %v", w.Header())
+	}
+	if w := get("/", big, false); w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("compressed without Accept-Encoding")
+	}
+	for _, path := range []string{"/hpke", "/digest"} {
+		if w := get(path, big, true); w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 100 {
+			t.Fatalf("%s compressed: %v", path, w.Header())
+		}
+	}
+
+	t.Setenv("GZIP_RESPONSES", "false")
+	h = Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(big))
+	}))
+	if w := get("/", big, true); w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("GZIP_RESPONSES=false ignored")
+	}
+}
+
+// Responses are compressed on the way out and inflate to the original.
+func TestResponseRoundTrip(t *testing.T) {
+	t.Setenv("GZIP_MIN_BYTES", "64")
+	plain := []byte(strings.Repeat(`{"step":"plan"}`, 50))
+	srv := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(plain)
+	})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" || len(raw) >= len(plain) {
+		t.Fatalf("response not compressed: %v, %d bytes", resp.Header, len(raw))
+	}
+	if !bytes.Equal(inflate(t, raw), plain) {
+		t.Fatal("inflated response differs")
+	}
+}
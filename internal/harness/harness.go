@@ -0,0 +1,126 @@
+// Package harness runs Root and its upstream agents (payment, medical,
+// planning) in one process on ephemeral ports, with Root wired to the
+// addresses the upstreams actually bound. Every agent keeps its state on its
+// own instance, so several harnesses can run side by side in one test binary.
+//
+// Configuration comes from the environment as for cmd/*: a test clears
+// ROOT_RPC_ADDR and turns SAGE off (ROOT_SAGE_ENABLED=false) unless it brings
+// keys. The upstreams run without signature verification.
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/agents/medical"
+	"github.com/sage-x-project/sage-multi-agent/agents/payment"
+	"github.com/sage-x-project/sage-multi-agent/agents/planning"
+	"github.com/sage-x-project/sage-multi-agent/agents/root"
+	"github.com/sage-x-project/sage-multi-agent/pkg/lifecycle"
+)
+
+// bindTimeout bounds how long Start waits for an agent to bind.
+const bindTimeout = 5 * time.Second
+
+// Harness is one running set of agents.
+type Harness struct {
+	Root     *root.RootAgent
+	Payment  *payment.PaymentAgent
+	Medical  *medical.MedicalAgent
+	Planning *planning.PlanningAgent
+
+	rootURL string
+	started []lifecycle.Agent
+	errs    chan error // Start results of agents that stopped serving
+}
+
+// Start brings up the upstreams on 127.0.0.1:0, then a Root named name on
+// port 0 routed to them. On error everything already started is shut down.
+func Start(name string) (*Harness, error) {
+	h := &Harness{errs: make(chan error, 4)}
+	var err error
+	if h.Payment, err = payment.NewPaymentAgentWithMode(payment.ModeFull, false); err != nil {
+		return nil, fmt.Errorf("payment: %w", err)
+	}
+	if h.Medical, err = medical.NewMedicalAgent(false); err != nil {
+		return nil, fmt.Errorf("medical: %w", err)
+	}
+	h.Planning = planning.NewPlanningAgent(name + "-planning")
+
+	urls := map[string]string{}
+	for agent, up := range map[string]lifecycle.Upstream{"payment": h.Payment, "medical": h.Medical, "planning": h.Planning} {
+		up := up
+		addr, err := h.serve(up, func() error { return up.Start("127.0.0.1:0") })
+		if err != nil {
+			h.Close(context.Background())
+			return nil, fmt.Errorf("%s: %w", agent, err)
+		}
+		urls[agent] = "http://" + addr
+	}
+
+	h.Root = root.NewRootAgent(name, 0)
+	for agent, u := range urls {
+		h.Root.SetExternalURL(agent, u)
+	}
+	addr, err := h.serve(h.Root, h.Root.Start)
+	if err != nil {
+		h.Close(context.Background())
+		return nil, fmt.Errorf("root: %w", err)
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	h.rootURL = "http://127.0.0.1:" + port
+	return h, nil
+}
+
+// serve runs start in the background and returns the address a bound.
+func (h *Harness) serve(a lifecycle.Agent, start func() error) (string, error) {
+	h.started = append(h.started, a)
+	done := make(chan error, 1)
+	go func() {
+		err := start()
+		done <- err
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.errs <- err
+		}
+	}()
+	deadline := time.Now().Add(bindTimeout)
+	for time.Now().Before(deadline) {
+		if addr := a.Addr(); addr != "" {
+			return addr, nil
+		}
+		select {
+		case err := <-done:
+			return "", err
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	return "", fmt.Errorf("not listening after %s", bindTimeout)
+}
+
+// URL is Root's base URL.
+func (h *Harness) URL() string { return h.rootURL }
+
+// Err reports the first agent that stopped serving on its own, if any.
+func (h *Harness) Err() error {
+	select {
+	case err := <-h.errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Close shuts the agents down in reverse start order (Root first).
+func (h *Harness) Close(ctx context.Context) error {
+	var errs []error
+	for i := len(h.started) - 1; i >= 0; i-- {
+		if err := h.started[i].Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
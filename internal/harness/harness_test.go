@@ -0,0 +1,149 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const adminToken = "harness-test"
+
+func quietEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("ROOT_SAGE_ENABLED", "false")
+	t.Setenv("ROOT_RPC_ADDR", "")
+	t.Setenv("ROOT_ADMIN_TOKEN", adminToken)
+	t.Setenv("ROOT_SEND_ATTEMPTS", "1")
+	t.Setenv("AUDIT_DIR", "")
+	t.Setenv("PLANNING_EXTERNAL_URL", "")
+	for _, a := range []string{"PLANNING", "MEDICAL", "PAYMENT"} {
+		t.Setenv(a+"_DIRECT_URL", "")
+	}
+}
+
+func start(t *testing.T, name string) *Harness {
+	t.Helper()
+	h, err := Start(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// A spare connection the transport dialed but never used counts as
+		// active to Shutdown for its first 5s; close those first.
+		http.DefaultClient.CloseIdleConnections()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := h.Close(ctx); err != nil {
+			t.Errorf("close %s: %v", name, err)
+		}
+	})
+	return h
+}
+
+func send(t *testing.T, h *Harness, cid, text string) types.AgentMessage {
+	t.Helper()
+	body, _ := json.Marshal(types.AgentMessage{ID: "m-" + cid, From: "client", Content: text, ContextID: cid})
+	resp, err := http.Post(h.URL()+"/process", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out types.AgentMessage
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("%s: %s answered %d: %v", text, h.URL(), resp.StatusCode, err)
+	}
+	return out
+}
+
+// conversation is Root's GET /conversations/{cid} view.
+func conversation(t *testing.T, h *Harness, cid string) map[string]any {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, h.URL()+"/conversations/"+cid, nil)
+	req.Header.Set("X-Admin-Token", adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var v map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&v)
+	return v
+}
+
+func TestEphemeralPorts(t *testing.T) {
+	quietEnv(t)
+	h := start(t, "root-eph")
+
+	for name, addr := range map[string]string{"root": h.Root.Addr(), "payment": h.Payment.Addr(), "medical": h.Medical.Addr(), "planning": h.Planning.Addr()} {
+		if addr == "" || strings.HasSuffix(addr, ":0") {
+			t.Fatalf("%s bound %q", name, addr)
+		}
+	}
+	// /status advertises the bound address
+	resp, err := http.Get("http://" + h.Planning.Addr() + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if st["addr"] != h.Planning.Addr() {
+		t.Fatalf("planning /status addr = %v, want %s", st["addr"], h.Planning.Addr())
+	}
+
+	// Root reaches the planning agent on the port it discovered
+	out := send(t, h, "ctx-plan", "gangnam hotel plan 짜줘")
+	if !strings.Contains(out.Content, "Hotel recommendations") {
+		t.Fatalf("planning answer: %+v", out)
+	}
+	if err := h.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Two harnesses in one process: no port clash, and a conversation on one is
+// unknown to the other even under the same conversation ID.
+func TestConcurrentHarnesses(t *testing.T) {
+	quietEnv(t)
+	a, b := start(t, "root-a"), start(t, "root-b")
+	if a.URL() == b.URL() || a.Payment.Addr() == b.Payment.Addr() {
+		t.Fatalf("harnesses share an address: %s %s", a.URL(), b.URL())
+	}
+
+	const cid = "ctx-shared"
+	var wg sync.WaitGroup
+	outs := make([]types.AgentMessage, 2)
+	for i, h := range []*Harness{a, b} {
+		wg.Add(1)
+		go func(i int, h *Harness) {
+			defer wg.Done()
+			text := "맥북 사줘"
+			if i == 1 {
+				text = "gangnam hotel plan 짜줘"
+			}
+			outs[i] = send(t, h, cid, text)
+		}(i, h)
+	}
+	wg.Wait()
+	if outs[0].Metadata["await"] != "payment.slots" || !strings.Contains(outs[1].Content, "Hotel recommendations") {
+		t.Fatalf("answers: a=%+v b=%+v", outs[0], outs[1])
+	}
+
+	if v := conversation(t, a, cid); v["payment"] == nil {
+		t.Fatalf("a lost its payment flow: %v", v)
+	}
+	if v := conversation(t, b, cid); v["payment"] != nil {
+		t.Fatalf("b sees a's payment flow: %v", v)
+	}
+	// b's next turn is not pinned to a's payment intake
+	if out := send(t, b, cid, "myeongdong hotel plan 짜줘"); !strings.Contains(out.Content, "Hotel recommendations") {
+		t.Fatalf("b routed by a's state: %+v", out)
+	}
+}
@@ -0,0 +1,116 @@
+// Package httpcache adds conditional-request support to read-only JSON
+// endpoints polled by frontends (/status, /sage/status, /hpke/status).
+//
+// The ETag is a weak hash of the whole response body, so an unchanged source
+// answers If-None-Match with 304 and any real change (HPKE enabled, upstream
+// health flipped, …) produces a new tag. Values that change on every poll
+// (timestamps, live counters) do not belong in a revalidated body: agents
+// serve them separately with WriteLive (GET /status/live). Last-Modified is
+// the time the current tag was first served for that path. Responses carry
+// Cache-Control: no-cache so clients revalidate instead of caching blindly.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type version struct {
+	etag string
+	mod  time.Time
+}
+
+// maxSeen bounds the Last-Modified memory; the key includes the raw query,
+// which callers choose freely.
+const maxSeen = 256
+
+// seen remembers the current tag per path (+query) for Last-Modified.
+var seen = struct {
+	mu sync.Mutex
+	m  map[string]version
+}{m: map[string]version{}}
+
+// ETag returns the weak tag for v.
+func ETag(v any) string {
+	b, _ := json.Marshal(v) // map keys are sorted
+	sum := sha256.Sum256(b)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func lastModified(key, etag string) time.Time {
+	now := time.Now().UTC().Truncate(time.Second)
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+	if v, ok := seen.m[key]; ok && v.etag == etag {
+		return v.mod
+	}
+	if _, ok := seen.m[key]; !ok && len(seen.m) >= maxSeen {
+		// forget the key that changed longest ago
+		oldest := ""
+		for k, v := range seen.m {
+			if oldest == "" || v.mod.Before(seen.m[oldest].mod) {
+				oldest = k
+			}
+		}
+		delete(seen.m, oldest)
+	}
+	seen.m[key] = version{etag: etag, mod: now}
+	return now
+}
+
+func matchETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified sets the caching headers for etag and reports whether the
+// request is satisfied by the client's copy (the 304 is already written).
+func NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	mod := lastModified(r.URL.Path+"?"+r.URL.RawQuery, etag)
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", mod.Format(http.TimeFormat))
+	h.Set("Cache-Control", "no-cache")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	fresh := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		fresh = matchETag(inm, etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			fresh = !mod.After(t)
+		}
+	}
+	if fresh {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// WriteJSON writes v as JSON with ETag/Last-Modified, or 304 when the client's copy is current.
+func WriteJSON(w http.ResponseWriter, r *http.Request, v any) {
+	if NotModified(w, r, ETag(v)) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteLive writes v as JSON that is never revalidated (live counters).
+func WriteLive(w http.ResponseWriter, v any) {
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(v any, path, inm string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if inm != "" {
+		req.Header.Set("If-None-Match", inm)
+	}
+	w := httptest.NewRecorder()
+	WriteJSON(w, req, v)
+	return w
+}
+
+func TestRevalidate(t *testing.T) {
+	v := map[string]any{"hpke": false, "n": 1}
+	first := get(v, "/status", "")
+	tag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || tag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("first: %d %v", first.Code, first.Header())
+	}
+	if w := get(v, "/status", tag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("unchanged: %d %q", w.Code, w.Body)
+	}
+	// every field is part of the tag, so any change is a full answer
+	for _, k := range []string{"hpke", "n"} {
+		changed := map[string]any{"hpke": false, "n": 1}
+		changed[k] = "other"
+		w := get(changed, "/status", tag)
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == tag {
+			t.Fatalf("%s changed: %d %s", k, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestWriteLive(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteLive(w, map[string]any{"requests": 3})
+	if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "no-store" || w.Body.String() != "{\"requests\":3}\n" {
+		t.Fatalf("live: %v %q", w.Header(), w.Body)
+	}
+}
+
+// Query strings are client-chosen; the Last-Modified memory stays bounded.
+func TestSeenBounded(t *testing.T) {
+	for i := 0; i < 3*maxSeen; i++ {
+		get(map[string]any{"i": i}, fmt.Sprintf("/hpke/status?target=x%d", i), "")
+	}
+	seen.mu.Lock()
+	n := len(seen.m)
+	seen.mu.Unlock()
+	if n > maxSeen {
+		t.Fatalf("%d entries, cap %d", n, maxSeen)
+	}
+}
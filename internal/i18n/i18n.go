@@ -0,0 +1,264 @@
+// Package i18n is a small message catalog for user-facing templates.
+//
+// Templates are registered per key with one fmt format per language
+// (Register("root.busy", map[string]string{"en": "...%d...", "ko": "..."}))
+// and rendered with T(lang, key, args...). English is the base pack: a key
+// missing in the requested language renders the English template and counts
+// a fallback for (lang, key), so untranslated keys that users actually hit
+// show up in /metrics (sage_i18n_fallback_total) instead of silently
+// switching languages mid-conversation.
+//
+// Check diffs every pack against the English key set and renders every key
+// in every language with dummy arguments typed after the English verbs, so a
+// translation whose verbs differ (%s vs %d, a missing or extra verb) is
+// reported at boot instead of printing %!d(string=...) to a user. LogReport
+// logs the summary at startup.
+//
+// I18N_STRICT=1 (meant for CI test runs) escalates: LogReport returns an
+// error for any missing key or broken template, and T panics on a fallback.
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+)
+
+// Base is the language every key must have.
+const Base = "en"
+
+// Catalog holds the packs and the fallback counters.
+type Catalog struct {
+	mu        sync.RWMutex
+	packs     map[string]map[string]string // lang -> key -> template
+	fallbacks map[[2]string]int64          // (lang, key) -> renders that fell back
+	strict    bool
+}
+
+// Default is the process-wide catalog.
+var Default = New()
+
+// New returns an empty catalog; strict follows I18N_STRICT.
+func New() *Catalog {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("I18N_STRICT")))
+	return &Catalog{
+		packs:     map[string]map[string]string{Base: {}},
+		fallbacks: map[[2]string]int64{},
+		strict:    v == "1" || v == "true" || v == "yes",
+	}
+}
+
+// Register adds key's templates, one per language.
+func (c *Catalog) Register(key string, byLang map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for lang, tmpl := range byLang {
+		p := c.packs[lang]
+		if p == nil {
+			p = map[string]string{}
+			c.packs[lang] = p
+		}
+		p[key] = tmpl
+	}
+}
+
+// Register adds key to the Default catalog.
+func Register(key string, byLang map[string]string) { Default.Register(key, byLang) }
+
+// T renders key in lang with args (fmt verbs), falling back to English.
+// An unknown key renders as the key itself.
+func (c *Catalog) T(lang, key string, args ...any) string {
+	c.mu.RLock()
+	tmpl, ok := c.packs[lang][key]
+	fallback := !ok
+	if fallback {
+		tmpl, ok = c.packs[Base][key]
+	}
+	c.mu.RUnlock()
+	if !ok {
+		return key
+	}
+	if fallback {
+		c.mu.Lock()
+		c.fallbacks[[2]string{lang, key}]++
+		c.mu.Unlock()
+		if c.strict {
+			panic(fmt.Sprintf("i18n: %q has no %s translation (I18N_STRICT)", key, lang))
+		}
+	}
+	if len(args) == 0 {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// T renders key from the Default catalog.
+func T(lang, key string, args ...any) string { return Default.T(lang, key, args...) }
+
+// Report is Check's result for one language.
+type Report struct {
+	Lang     string   `json:"lang"`
+	Keys     int      `json:"keys"`    // English keys
+	Missing  []string `json:"missing"` // English keys without a translation
+	Complete float64  `json:"completeness"`
+	Errors   []string `json:"errors,omitempty"` // verb mismatches and render failures
+}
+
+var verbRe = regexp.MustCompile(`%[-+# 0]*(?:\d+|\*)?(?:\.(?:\d+|\*))?[a-zA-Z%]`)
+
+// verbs lists the formatting verbs of tmpl (without %%).
+func verbs(tmpl string) []string {
+	var out []string
+	for _, v := range verbRe.FindAllString(tmpl, -1) {
+		if v != "%%" {
+			out = append(out, v[len(v)-1:])
+		}
+	}
+	return out
+}
+
+// dummyArgs returns one argument per verb, typed to satisfy it.
+func dummyArgs(vs []string) []any {
+	args := make([]any, len(vs))
+	for i, v := range vs {
+		switch v {
+		case "d", "x", "X", "o", "b", "c":
+			args[i] = 7
+		case "f", "g", "e", "F", "G", "E":
+			args[i] = 1.5
+		case "t":
+			args[i] = true
+		default:
+			args[i] = "x"
+		}
+	}
+	return args
+}
+
+// Check reports, for every language including English, which English keys
+// it lacks and which of its templates do not render with the English verbs.
+func (c *Catalog) Check() []Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	base := c.packs[Base]
+	langs := make([]string, 0, len(c.packs))
+	for l := range c.packs {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	var out []Report
+	for _, lang := range langs {
+		rep := Report{Lang: lang, Keys: len(base), Missing: []string{}}
+		pack := c.packs[lang]
+		for key, en := range base {
+			tmpl, ok := pack[key]
+			if !ok {
+				rep.Missing = append(rep.Missing, key)
+				continue
+			}
+			want := verbs(en)
+			if got := verbs(tmpl); lang != Base && strings.Join(got, ",") != strings.Join(want, ",") {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("%s: verbs %v, English has %v", key, got, want))
+				continue
+			}
+			if s := fmt.Sprintf(tmpl, dummyArgs(want)...); strings.Contains(s, "%!") {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("%s: renders as %q", key, s))
+			}
+		}
+		for key := range pack {
+			if _, ok := base[key]; !ok {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("%s: no English template", key))
+			}
+		}
+		sort.Strings(rep.Missing)
+		sort.Strings(rep.Errors)
+		rep.Complete = 100
+		if rep.Keys > 0 {
+			rep.Complete = math.Round(float64(rep.Keys-len(rep.Missing))/float64(rep.Keys)*1000) / 10
+		}
+		out = append(out, rep)
+	}
+	return out
+}
+
+// LogReport logs Check's summary, one line per language, and collects
+// missing or broken templates as boot report warnings. In strict mode it
+// returns an error when anything is missing or broken.
+func (c *Catalog) LogReport(logger *log.Logger, prefix string) error {
+	var bad []string
+	for _, rep := range c.Check() {
+		line := fmt.Sprintf("[%s][i18n] %s: %.1f%% (%d/%d keys)", prefix, rep.Lang, rep.Complete, rep.Keys-len(rep.Missing), rep.Keys)
+		if n := len(rep.Missing); n > 0 {
+			first := rep.Missing[:min(n, 5)]
+			line += ", missing " + strings.Join(first, ", ")
+			if n > len(first) {
+				line += fmt.Sprintf(" (+%d more)", n-len(first))
+			}
+			bad = append(bad, fmt.Sprintf("%s: %d missing", rep.Lang, n))
+			bootreport.Warnf("i18n %s: %d translations missing (%s)", rep.Lang, n, strings.Join(first, ", "))
+		}
+		logger.Print(line)
+		for _, e := range rep.Errors {
+			logger.Printf("[%s][i18n][error] %s: %s", prefix, rep.Lang, e)
+		}
+		if len(rep.Errors) > 0 {
+			bad = append(bad, fmt.Sprintf("%s: %d broken", rep.Lang, len(rep.Errors)))
+			bootreport.Warnf("i18n %s: %d broken templates", rep.Lang, len(rep.Errors))
+		}
+	}
+	if c.strict && len(bad) > 0 {
+		return fmt.Errorf("i18n: %s (I18N_STRICT)", strings.Join(bad, "; "))
+	}
+	return nil
+}
+
+// Status is the /status view: completeness per language and fallback counts.
+func (c *Catalog) Status() map[string]any {
+	langs := map[string]any{}
+	for _, rep := range c.Check() {
+		langs[rep.Lang] = map[string]any{"completeness": rep.Complete, "missing": len(rep.Missing), "errors": len(rep.Errors)}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var total int64
+	top := make([]map[string]any, 0, len(c.fallbacks))
+	for k, n := range c.fallbacks {
+		total += n
+		top = append(top, map[string]any{"lang": k[0], "key": k[1], "count": n})
+	}
+	sort.Slice(top, func(i, j int) bool { return top[i]["count"].(int64) > top[j]["count"].(int64) })
+	if len(top) > 10 {
+		top = top[:10]
+	}
+	return map[string]any{"languages": langs, "fallbacks": total, "topFallbacks": top, "strict": c.strict}
+}
+
+// WritePrometheus appends the fallback counters and completeness gauges.
+func (c *Catalog) WritePrometheus(w io.Writer, agent string) {
+	var b strings.Builder
+	b.WriteString("# HELP sage_i18n_completeness_percent Share of English template keys translated, per language.\n# TYPE sage_i18n_completeness_percent gauge\n")
+	for _, rep := range c.Check() {
+		b.WriteString(`sage_i18n_completeness_percent{agent="` + agent + `",lang="` + rep.Lang + `"} ` + strconv.FormatFloat(rep.Complete, 'f', 1, 64) + "\n")
+	}
+	c.mu.RLock()
+	keys := make([][2]string, 0, len(c.fallbacks))
+	for k := range c.fallbacks {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+	b.WriteString("# HELP sage_i18n_fallback_total Renders that fell back to English because the key is untranslated.\n# TYPE sage_i18n_fallback_total counter\n")
+	for _, k := range keys {
+		b.WriteString(`sage_i18n_fallback_total{agent="` + agent + `",lang="` + k[0] + `",key="` + k[1] + `"} ` + strconv.FormatInt(c.fallbacks[k], 10) + "\n")
+	}
+	c.mu.RUnlock()
+	_, _ = io.WriteString(w, b.String())
+}
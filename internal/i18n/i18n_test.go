@@ -0,0 +1,92 @@
+package i18n
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func testCatalog(t *testing.T, strict bool) *Catalog {
+	t.Helper()
+	t.Setenv("I18N_STRICT", map[bool]string{true: "1", false: ""}[strict])
+	c := New()
+	c.Register("busy", map[string]string{"en": "Retry in %ds.", "ko": "%d초 후에 다시 시도해 주세요."})
+	c.Register("plain", map[string]string{"en": "Done.", "ko": "완료."})
+	return c
+}
+
+func TestRender(t *testing.T) {
+	c := testCatalog(t, false)
+	if got := c.T("ko", "busy", 3); got != "3초 후에 다시 시도해 주세요." {
+		t.Fatalf("ko: %q", got)
+	}
+	if got := c.T("en", "plain"); got != "Done." {
+		t.Fatalf("en: %q", got)
+	}
+	if got := c.T("en", "no.such.key"); got != "no.such.key" {
+		t.Fatalf("unknown key: %q", got)
+	}
+}
+
+// A key missing in a language renders in English and is counted.
+func TestFallbackCounted(t *testing.T) {
+	c := testCatalog(t, false)
+	c.Register("new", map[string]string{"en": "New %s"})
+	for i := 0; i < 2; i++ {
+		if got := c.T("ko", "new", "x"); got != "New x" {
+			t.Fatalf("fallback: %q", got)
+		}
+	}
+	st := c.Status()
+	if st["fallbacks"] != int64(2) {
+		t.Fatalf("fallbacks: %v", st)
+	}
+	var b strings.Builder
+	c.WritePrometheus(&b, "root")
+	if !strings.Contains(b.String(), `sage_i18n_fallback_total{agent="root",lang="ko",key="new"} 2`) {
+		t.Fatalf("metrics:\n%s", b.String())
+	}
+}
+
+func TestStrictFallbackPanics(t *testing.T) {
+	c := testCatalog(t, true)
+	c.Register("new", map[string]string{"en": "New"})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic on fallback in strict mode")
+		}
+	}()
+	c.T("ko", "new")
+}
+
+// Check reports missing keys, verbs that differ from English and templates
+// that render with %! in them.
+func TestCheck(t *testing.T) {
+	c := testCatalog(t, true)
+	c.Register("count", map[string]string{"en": "%d items", "ko": "%s개"})
+	c.Register("missing", map[string]string{"en": "Only English"})
+	c.Register("orphan", map[string]string{"ko": "영어 없음"})
+	c.Register("bad", map[string]string{"en": "%z", "ko": "%z"})
+
+	reps := map[string]Report{}
+	for _, rep := range c.Check() {
+		reps[rep.Lang] = rep
+	}
+	ko := reps["ko"]
+	if strings.Join(ko.Missing, ",") != "missing" {
+		t.Fatalf("missing: %v", ko.Missing)
+	}
+	errs := strings.Join(ko.Errors, "\n")
+	for _, want := range []string{"count: verbs [s]", "orphan: no English template", "bad: renders as"} {
+		if !strings.Contains(errs, want) {
+			t.Fatalf("ko errors lack %q:\n%s", want, errs)
+		}
+	}
+	if en := reps["en"]; len(en.Missing) != 0 || en.Complete != 100 {
+		t.Fatalf("en: %+v", en)
+	}
+	if err := c.LogReport(log.New(io.Discard, "", 0), "test"); err == nil {
+		t.Fatal("strict LogReport passed a broken catalog")
+	}
+}
@@ -0,0 +1,268 @@
+// Package jsonrpc is a small JSON-RPC 2.0 server over HTTP POST.
+//
+// Methods are registered by name with a typed handler. A request body may be
+// a single call or a batch (array); calls without an id are notifications and
+// get no response. Protocol failures use the standard codes (-32700 parse
+// error, -32600 invalid request, -32601 method not found, -32602 invalid
+// params, -32603 internal error). Application errors carry a canonical status
+// (the gRPC vocabulary: NOT_FOUND, PERMISSION_DENIED, ...) in error.data.status
+// and use code -32000 minus the numeric gRPC code, so clients can switch on
+// either. FromHTTPStatus maps an HTTP status to its canonical status.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Version is the only protocol version accepted.
+const Version = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Canonical is an application status name.
+type Canonical string
+
+const (
+	Cancelled          Canonical = "CANCELLED"
+	Unknown            Canonical = "UNKNOWN"
+	InvalidArgument    Canonical = "INVALID_ARGUMENT"
+	DeadlineExceeded   Canonical = "DEADLINE_EXCEEDED"
+	NotFound           Canonical = "NOT_FOUND"
+	PermissionDenied   Canonical = "PERMISSION_DENIED"
+	ResourceExhausted  Canonical = "RESOURCE_EXHAUSTED"
+	FailedPrecondition Canonical = "FAILED_PRECONDITION"
+	Aborted            Canonical = "ABORTED"
+	Unimplemented      Canonical = "UNIMPLEMENTED"
+	Internal           Canonical = "INTERNAL"
+	Unavailable        Canonical = "UNAVAILABLE"
+	Unauthenticated    Canonical = "UNAUTHENTICATED"
+)
+
+var grpcCodes = map[Canonical]int{
+	Cancelled: 1, Unknown: 2, InvalidArgument: 3, DeadlineExceeded: 4, NotFound: 5,
+	PermissionDenied: 7, ResourceExhausted: 8, FailedPrecondition: 9, Aborted: 10,
+	Unimplemented: 12, Internal: 13, Unavailable: 14, Unauthenticated: 16,
+}
+
+// Code is the JSON-RPC error code used for c.
+func (c Canonical) Code() int {
+	if n, ok := grpcCodes[c]; ok {
+		return -32000 - n
+	}
+	return -32000 - grpcCodes[Unknown]
+}
+
+// FromHTTPStatus maps an HTTP status (>= 400) to a canonical status.
+func FromHTTPStatus(status int) Canonical {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusConflict:
+		return Aborted
+	case http.StatusTooManyRequests:
+		return ResourceExhausted
+	case 499:
+		return Cancelled
+	case http.StatusNotImplemented, http.StatusMethodNotAllowed:
+		return Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return DeadlineExceeded
+	}
+	switch {
+	case status >= 500:
+		return Internal
+	case status >= 400:
+		return FailedPrecondition
+	}
+	return Unknown
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("jsonrpc %d: %s", e.Code, e.Message) }
+
+// Errorf builds an application error with status c; data (may be nil) is
+// merged into error.data next to "status".
+func Errorf(c Canonical, data map[string]any, format string, args ...any) *Error {
+	d := map[string]any{"status": string(c)}
+	for k, v := range data {
+		d[k] = v
+	}
+	return &Error{Code: c.Code(), Message: fmt.Sprintf(format, args...), Data: d}
+}
+
+func protoError(code int, msg string) *Error {
+	st := InvalidArgument
+	if code == CodeInternalError {
+		st = Internal
+	} else if code == CodeMethodNotFound {
+		st = Unimplemented
+	}
+	return &Error{Code: code, Message: msg, Data: map[string]any{"status": string(st)}}
+}
+
+// Request is one call.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is one reply.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Handler serves one method. req is the HTTP request carrying the call (for
+// headers and context). Returning a non-*Error error yields -32603.
+type Handler func(ctx context.Context, req *http.Request, params json.RawMessage) (any, error)
+
+// Server dispatches calls to registered methods.
+type Server struct {
+	methods  map[string]Handler
+	MaxBody  int64 // default 4 MiB
+	MaxBatch int   // default 20
+}
+
+func NewServer() *Server {
+	return &Server{methods: map[string]Handler{}, MaxBody: 4 << 20, MaxBatch: 20}
+}
+
+// Register adds method name.
+func (s *Server) Register(name string, h Handler) { s.methods[name] = h }
+
+// Methods lists the registered method names.
+func (s *Server) Methods() []string {
+	out := make([]string, 0, len(s.methods))
+	for k := range s.methods {
+		out = append(out, k)
+	}
+	return out
+}
+
+// Decode unmarshals params into v, reporting -32602 on failure. Unknown
+// fields are rejected so a typo does not silently drop an option.
+func Decode(params json.RawMessage, v any) error {
+	if len(bytes.TrimSpace(params)) == 0 {
+		params = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return protoError(CodeInvalidParams, "invalid params: "+err.Error())
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, s.MaxBody))
+	if err != nil {
+		writeJSON(w, Response{JSONRPC: Version, Error: protoError(CodeParseError, "read body: "+err.Error()), ID: json.RawMessage("null")})
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var calls []json.RawMessage
+		if err := json.Unmarshal(body, &calls); err != nil {
+			writeJSON(w, Response{JSONRPC: Version, Error: protoError(CodeParseError, "parse error"), ID: json.RawMessage("null")})
+			return
+		}
+		if len(calls) == 0 || len(calls) > s.MaxBatch {
+			writeJSON(w, Response{JSONRPC: Version, Error: protoError(CodeInvalidRequest, fmt.Sprintf("batch must hold 1..%d calls", s.MaxBatch)), ID: json.RawMessage("null")})
+			return
+		}
+		out := make([]Response, 0, len(calls))
+		for _, c := range calls {
+			if resp, ok := s.call(req, c); ok {
+				out = append(out, resp)
+			}
+		}
+		if len(out) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, out)
+		return
+	}
+	resp, ok := s.call(req, body)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// call runs one call; ok=false for a notification.
+func (s *Server) call(req *http.Request, raw json.RawMessage) (Response, bool) {
+	var c Request
+	if err := json.Unmarshal(raw, &c); err != nil {
+		var se *json.SyntaxError
+		code := CodeInvalidRequest
+		if errors.As(err, &se) {
+			code = CodeParseError
+		}
+		return Response{JSONRPC: Version, Error: protoError(code, "invalid request"), ID: json.RawMessage("null")}, true
+	}
+	notify := len(c.ID) == 0
+	id := c.ID
+	if notify {
+		id = json.RawMessage("null")
+	}
+	if c.JSONRPC != Version || c.Method == "" {
+		return Response{JSONRPC: Version, Error: protoError(CodeInvalidRequest, `"jsonrpc" must be "2.0" and "method" set`), ID: id}, !notify
+	}
+	h, ok := s.methods[c.Method]
+	if !ok {
+		return Response{JSONRPC: Version, Error: protoError(CodeMethodNotFound, "method not found: "+c.Method), ID: id}, !notify
+	}
+	res, err := h(req.Context(), req, c.Params)
+	if err != nil {
+		var re *Error
+		if !errors.As(err, &re) {
+			re = protoError(CodeInternalError, err.Error())
+		}
+		return Response{JSONRPC: Version, Error: re, ID: id}, !notify
+	}
+	return Response{JSONRPC: Version, Result: res, ID: id}, !notify
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,119 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testServer() *Server {
+	s := NewServer()
+	s.Register("Echo", func(_ context.Context, _ *http.Request, params json.RawMessage) (any, error) {
+		var p struct {
+			Say string `json:"say"`
+		}
+		if err := Decode(params, &p); err != nil {
+			return nil, err
+		}
+		return p.Say, nil
+	})
+	s.Register("Missing", func(context.Context, *http.Request, json.RawMessage) (any, error) {
+		return nil, Errorf(NotFound, map[string]any{"id": "x"}, "no %s", "x")
+	})
+	s.Register("Broken", func(context.Context, *http.Request, json.RawMessage) (any, error) {
+		return nil, errors.New("boom")
+	})
+	return s
+}
+
+func post(s *Server, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+	return w
+}
+
+func TestCall(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		result any
+		code   int
+		status string
+	}{
+		{"ok", `{"jsonrpc":"2.0","id":1,"method":"Echo","params":{"say":"hi"}}`, "hi", 0, ""},
+		{"parse error", `{"jsonrpc":`, nil, CodeParseError, "INVALID_ARGUMENT"},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"Echo"}`, nil, CodeInvalidRequest, "INVALID_ARGUMENT"},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"Nope"}`, nil, CodeMethodNotFound, "UNIMPLEMENTED"},
+		{"unknown param", `{"jsonrpc":"2.0","id":1,"method":"Echo","params":{"sya":"hi"}}`, nil, CodeInvalidParams, "INVALID_ARGUMENT"},
+		{"application error", `{"jsonrpc":"2.0","id":1,"method":"Missing"}`, nil, -32005, "NOT_FOUND"},
+		{"plain error", `{"jsonrpc":"2.0","id":1,"method":"Broken"}`, nil, CodeInternalError, "INTERNAL"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp struct {
+				Result any
+				Error  *Error
+			}
+			if err := json.Unmarshal(post(testServer(), tc.body).Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if tc.code == 0 {
+				if resp.Error != nil || resp.Result != tc.result {
+					t.Fatalf("%+v", resp)
+				}
+				return
+			}
+			data, _ := resp.Error.Data.(map[string]any)
+			if resp.Error == nil || resp.Error.Code != tc.code || data["status"] != tc.status {
+				t.Fatalf("error %+v", resp.Error)
+			}
+		})
+	}
+}
+
+// A batch answers every call but its notifications; a batch of notifications
+// (or a single one) gets 204.
+func TestBatchAndNotifications(t *testing.T) {
+	w := post(testServer(), `[{"jsonrpc":"2.0","id":"a","method":"Echo","params":{"say":"1"}},{"jsonrpc":"2.0","method":"Echo"},{"jsonrpc":"2.0","id":"b","method":"Nope"}]`)
+	var out []Response
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || len(out) != 2 {
+		t.Fatalf("%s", w.Body)
+	}
+	if string(out[0].ID) != `"a"` || out[0].Result != "1" || string(out[1].ID) != `"b"` || out[1].Error.Code != CodeMethodNotFound {
+		t.Fatalf("%+v", out)
+	}
+	for _, body := range []string{`{"jsonrpc":"2.0","method":"Echo"}`, `[{"jsonrpc":"2.0","method":"Broken"}]`} {
+		if w := post(testServer(), body); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Fatalf("%s: %d %s", body, w.Code, w.Body)
+		}
+	}
+	s := testServer()
+	s.MaxBatch = 1
+	if w := post(s, `[{"jsonrpc":"2.0","id":1,"method":"Echo"},{"jsonrpc":"2.0","id":2,"method":"Echo"}]`); !strings.Contains(w.Body.String(), `"code":-32600`) {
+		t.Fatalf("oversized batch: %s", w.Body)
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET: %d", w.Code)
+	}
+}
+
+func TestFromHTTPStatus(t *testing.T) {
+	for status, want := range map[int]Canonical{
+		400: InvalidArgument, 401: Unauthenticated, 403: PermissionDenied, 404: NotFound, 409: Aborted,
+		413: InvalidArgument, 418: FailedPrecondition, 429: ResourceExhausted, 499: Cancelled,
+		500: Internal, 502: Unavailable, 503: Unavailable, 504: DeadlineExceeded,
+	} {
+		if got := FromHTTPStatus(status); got != want {
+			t.Errorf("%d: %s, want %s", status, got, want)
+		}
+	}
+	if FailedPrecondition.Code() != -32009 || Canonical("BOGUS").Code() != -32002 {
+		t.Fatal("codes")
+	}
+}
@@ -0,0 +1,154 @@
+// Package kemproof verifies X25519 KEM key ownership proofs.
+//
+// tools/registration/register_kem_agents signs each agent's X25519 public key
+// with the agent's secp256k1 key before registering it:
+//
+//	msg  = keccak256("SAGE X25519 Ownership:" || x25519Pub(32) || chainID(32) || registry(20) || owner(20))
+//	sig  = sign(keccak256("\x19Ethereum Signed Message:\n32" || msg))
+//
+// Verify recovers the signer and checks it is the claimed owner, binding the
+// KEM key to the owner address for that chain and registry. A proof only says
+// who signed it; Check also ties it to what the registry resolves for the DID
+// (its KEM key and owner), so a self-signed proof for another key is refused.
+package kemproof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// Code is the error code reported when ownership cannot be verified.
+const Code = "KEM_OWNERSHIP_UNVERIFIED"
+
+// Error reports a failed or missing ownership proof.
+type Error struct {
+	DID    string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: did=%s: %s", Code, e.DID, e.Reason)
+}
+
+// Proof is one registered ownership proof (hex fields, 0x optional).
+type Proof struct {
+	Name         string `json:"name,omitempty"`
+	DID          string `json:"did"`
+	X25519Public string `json:"x25519Public"`
+	Owner        string `json:"owner"`
+	ChainID      string `json:"chainId"` // decimal
+	Registry     string `json:"registry"`
+	Signature    string `json:"signature"` // 65 bytes r||s||v (v may be 27/28)
+}
+
+// Digest returns the Ethereum-prefixed hash that the owner signs.
+func Digest(xpub []byte, chainID *big.Int, registry, owner common.Address) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("SAGE X25519 Ownership:")
+	buf.Write(xpub)
+	buf.Write(common.LeftPadBytes(chainID.Bytes(), 32))
+	buf.Write(registry.Bytes())
+	buf.Write(owner.Bytes())
+	msg := gethcrypto.Keccak256(buf.Bytes())
+	return gethcrypto.Keccak256(append([]byte("\x19Ethereum Signed Message:\n32"), msg...))
+}
+
+// Verify checks p's signature; the recovered signer must equal p.Owner.
+func Verify(p Proof) error {
+	fail := func(format string, a ...any) error { return &Error{DID: p.DID, Reason: fmt.Sprintf(format, a...)} }
+
+	xpub, err := decodeHex(p.X25519Public)
+	if err != nil || len(xpub) != 32 {
+		return fail("x25519Public must be 32 bytes hex")
+	}
+	if !common.IsHexAddress(p.Owner) || !common.IsHexAddress(p.Registry) {
+		return fail("owner/registry must be hex addresses")
+	}
+	chainID, ok := new(big.Int).SetString(strings.TrimSpace(p.ChainID), 10)
+	if !ok {
+		return fail("chainId must be decimal")
+	}
+	sig, err := decodeHex(p.Signature)
+	if err != nil || len(sig) != 65 {
+		return fail("signature must be 65 bytes hex")
+	}
+	sig = append([]byte(nil), sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	owner := common.HexToAddress(p.Owner)
+	pub, err := gethcrypto.SigToPub(Digest(xpub, chainID, common.HexToAddress(p.Registry), owner), sig)
+	if err != nil {
+		return fail("recover signer: %v", err)
+	}
+	if signer := gethcrypto.PubkeyToAddress(*pub); signer != owner {
+		return fail("signed by %s, not owner %s", signer.Hex(), owner.Hex())
+	}
+	return nil
+}
+
+// Check verifies p and that it covers kemPub, the X25519 key the registry
+// resolves for the DID, and was signed by owner, the DID's registered owner.
+func Check(p Proof, kemPub, owner string) error {
+	if err := Verify(p); err != nil {
+		return err
+	}
+	if !SameKey(p.X25519Public, kemPub) {
+		return &Error{DID: p.DID, Reason: "proof covers a different KEM key than the registry publishes"}
+	}
+	if !common.IsHexAddress(owner) {
+		return &Error{DID: p.DID, Reason: "registered owner unknown"}
+	}
+	if common.HexToAddress(owner) != common.HexToAddress(p.Owner) {
+		return &Error{DID: p.DID, Reason: fmt.Sprintf("proof owner %s is not the registered owner %s", p.Owner, owner)}
+	}
+	return nil
+}
+
+// Load reads proofs from path ({"proofs":[...]} or a top-level array).
+func Load(path string) ([]Proof, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var arr []Proof
+	if err := json.Unmarshal(b, &arr); err == nil {
+		return arr, nil
+	}
+	var w struct {
+		Proofs []Proof `json:"proofs"`
+	}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return nil, fmt.Errorf("kem proof json not recognized: %s", path)
+	}
+	return w.Proofs, nil
+}
+
+// Find returns the proof for did (or name), if any.
+func Find(proofs []Proof, did, name string) (Proof, bool) {
+	for _, p := range proofs {
+		if (did != "" && strings.EqualFold(strings.TrimSpace(p.DID), did)) || (name != "" && p.Name == name) {
+			return p, true
+		}
+	}
+	return Proof{}, false
+}
+
+// SameKey reports whether two hex-encoded keys are equal.
+func SameKey(a, b string) bool {
+	x, err1 := decodeHex(a)
+	y, err2 := decodeHex(b)
+	return err1 == nil && err2 == nil && len(x) > 0 && bytes.Equal(x, y)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+}
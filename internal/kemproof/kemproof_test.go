@@ -0,0 +1,86 @@
+package kemproof
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures/fixturetest"
+)
+
+// loadFixtureProofs returns the fixture proofs and the registry snapshot
+// standing in for the resolver.
+func loadFixtureProofs(t *testing.T) ([]Proof, *fixtures.Snapshot) {
+	t.Helper()
+	fx := fixturetest.Load(t)
+	proofs, err := Load(fx.Path("keys", "kem", "kem_ownership.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proofs, fx.Snapshot
+}
+
+func TestCheckFixtureProofs(t *testing.T) {
+	proofs, snap := loadFixtureProofs(t)
+	for _, name := range []string{"payment", "medical"} {
+		a, _ := snap.ByName(name)
+		p, ok := Find(proofs, a.DID, "")
+		if !ok {
+			t.Fatalf("%s: no proof", name)
+		}
+		if err := Check(p, a.X25519Public, a.Address); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestCheckRejects(t *testing.T) {
+	proofs, snap := loadFixtureProofs(t)
+	pay, _ := snap.ByName("payment")
+	med, _ := snap.ByName("medical")
+	p, _ := Find(proofs, pay.DID, "")
+
+	// An unrelated key signing its own address over the payment KEM key:
+	// a valid signature, but not by the DID's registered owner.
+	k, _ := gethcrypto.GenerateKey()
+	self := p
+	self.Owner = gethcrypto.PubkeyToAddress(k.PublicKey).Hex()
+	xpub, _ := hex.DecodeString(p.X25519Public[2:])
+	sig, _ := gethcrypto.Sign(Digest(xpub, big.NewInt(fixtures.ChainID), common.HexToAddress(p.Registry), common.HexToAddress(self.Owner)), k)
+	self.Signature = "0x" + hex.EncodeToString(sig)
+	if err := Verify(self); err != nil {
+		t.Fatalf("self-signed proof should verify on its own: %v", err)
+	}
+
+	flipped := p
+	b := []byte(p.Signature)
+	if b[10] == 'a' {
+		b[10] = 'b'
+	} else {
+		b[10] = 'a'
+	}
+	flipped.Signature = string(b)
+
+	cases := []struct {
+		name        string
+		p           Proof
+		kemPub, own string
+	}{
+		{"bad signature", flipped, pay.X25519Public, pay.Address},
+		{"other agent's key", p, med.X25519Public, pay.Address},
+		{"self-signed owner", self, pay.X25519Public, pay.Address},
+		{"other owner", p, pay.X25519Public, med.Address},
+		{"owner unknown", p, pay.X25519Public, ""},
+	}
+	for _, c := range cases {
+		if err := Check(c.p, c.kemPub, c.own); err == nil {
+			t.Errorf("%s: accepted", c.name)
+		} else if _, ok := err.(*Error); !ok {
+			t.Errorf("%s: %T, want *Error", c.name, err)
+		}
+	}
+}
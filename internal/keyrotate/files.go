@@ -0,0 +1,131 @@
+// Package keyrotate holds the file side of agent key rotation
+// (tools/keytool): all-or-nothing updates of the key files with backups, the
+// JSON row edits for the key lists, and the persisted rotation state that a
+// failed run resumes from.
+//
+// Apply writes every new file to "<path>.tmp" first, then for each file copies
+// the current content to "<path>.bak-<stamp>" and renames the temp file over
+// it. If any step fails, the files already replaced are restored from their
+// backups and the temp files are removed, so the set is either fully old or
+// fully new. The backups are kept after success so an operator can still roll
+// back by hand (Restore).
+package keyrotate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Change is one file to replace.
+type Change struct {
+	Path string
+	Data []byte
+	Mode os.FileMode // used when the file does not exist yet (default 0600)
+}
+
+// Backup records where a replaced file's previous content was saved.
+// From is empty when the file did not exist before.
+type Backup struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+}
+
+// Apply replaces every file in changes or none of them (see package doc).
+func Apply(changes []Change, stamp string) ([]Backup, error) {
+	var temps []string
+	cleanup := func() {
+		for _, t := range temps {
+			_ = os.Remove(t)
+		}
+	}
+	for _, c := range changes {
+		mode := c.Mode
+		if fi, err := os.Stat(c.Path); err == nil {
+			mode = fi.Mode().Perm()
+		} else if mode == 0 {
+			mode = 0o600
+		}
+		if dir := filepath.Dir(c.Path); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				cleanup()
+				return nil, err
+			}
+		}
+		tmp := c.Path + ".tmp"
+		if err := os.WriteFile(tmp, c.Data, mode); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("keyrotate: write %s: %w", tmp, err)
+		}
+		temps = append(temps, tmp)
+	}
+
+	var done []Backup
+	for i, c := range changes {
+		b := Backup{Path: c.Path}
+		if _, err := os.Stat(c.Path); err == nil {
+			b.From = c.Path + ".bak-" + stamp
+			if err := copyFile(c.Path, b.From); err != nil {
+				cleanup()
+				return nil, errors.Join(fmt.Errorf("keyrotate: backup %s: %w", c.Path, err), Restore(done))
+			}
+		}
+		if err := os.Rename(temps[i], c.Path); err != nil {
+			if b.From != "" {
+				_ = os.Remove(b.From)
+			}
+			cleanup()
+			return nil, errors.Join(fmt.Errorf("keyrotate: replace %s: %w", c.Path, err), Restore(done))
+		}
+		done = append(done, b)
+	}
+	return done, nil
+}
+
+// Restore puts the backed-up content back (files that did not exist before
+// are removed). Backups are left in place.
+func Restore(backups []Backup) error {
+	var errs []error
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if b.From == "" {
+			if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := copyFile(b.From, b.Path); err != nil {
+			errs = append(errs, fmt.Errorf("keyrotate: restore %s: %w", b.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp-copy"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,153 @@
+package keyrotate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+)
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// The JWK and the merged key list are replaced together, the old content is
+// kept as .bak-<stamp> and a file that did not exist is created.
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	jwk, merged, fresh := filepath.Join(dir, "payment.jwk"), filepath.Join(dir, "merged_agent_keys.json"), filepath.Join(dir, "keys", "new.json")
+	_ = os.WriteFile(jwk, []byte("old-jwk"), 0o600)
+	_ = os.WriteFile(merged, []byte("old-merged"), 0o644)
+
+	backups, err := Apply([]Change{{Path: jwk, Data: []byte("new-jwk")}, {Path: merged, Data: []byte("new-merged")}, {Path: fresh, Data: []byte("x")}}, "S1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read(t, jwk) != "new-jwk" || read(t, merged) != "new-merged" || read(t, fresh) != "x" {
+		t.Fatal("files not replaced")
+	}
+	if read(t, jwk+".bak-S1") != "old-jwk" || read(t, merged+".bak-S1") != "old-merged" || backups[2].From != "" {
+		t.Fatalf("backups %+v", backups)
+	}
+	if fi, _ := os.Stat(merged); fi.Mode().Perm() != 0o644 {
+		t.Fatalf("mode %v not kept", fi.Mode().Perm())
+	}
+	if m, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(m) != 0 {
+		t.Fatalf("temp files left: %v", m)
+	}
+
+	// manual rollback
+	if err := Restore(backups); err != nil {
+		t.Fatal(err)
+	}
+	if read(t, jwk) != "old-jwk" || read(t, merged) != "old-merged" {
+		t.Fatal("restore")
+	}
+	if _, err := os.Stat(fresh); !os.IsNotExist(err) {
+		t.Fatal("file created by the rotation survived the restore")
+	}
+}
+
+// A failure after the first file was replaced puts it back: the set is
+// either fully old or fully new.
+func TestApplyPartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	jwk, blocked := filepath.Join(dir, "payment.jwk"), filepath.Join(dir, "merged_agent_keys.json")
+	_ = os.WriteFile(jwk, []byte("old-jwk"), 0o600)
+	_ = os.Mkdir(blocked, 0o755) // cannot be backed up or replaced
+
+	_, err := Apply([]Change{{Path: jwk, Data: []byte("new-jwk")}, {Path: blocked, Data: []byte("new-merged")}}, "S2")
+	if err == nil || !strings.Contains(err.Error(), "keyrotate: backup "+blocked) {
+		t.Fatalf("err %v", err)
+	}
+	if read(t, jwk) != "old-jwk" {
+		t.Fatal("first file not restored")
+	}
+	if m, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(m) != 0 {
+		t.Fatalf("temp files left: %v", m)
+	}
+
+	// a temp file that cannot be written leaves everything untouched
+	_, err = Apply([]Change{{Path: jwk, Data: []byte("new-jwk")}, {Path: filepath.Join(jwk, "nested"), Data: []byte("x")}}, "S3")
+	if err == nil || read(t, jwk) != "old-jwk" {
+		t.Fatalf("err %v", err)
+	}
+	if _, err := os.Stat(jwk + ".bak-S3"); !os.IsNotExist(err) {
+		t.Fatal("backup taken before every temp file was written")
+	}
+}
+
+// A run that failed mid-way is saved and resumed from the failed step.
+func TestStateResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotation.json")
+	st := New("payment", "did:sage:ethereum:0xold", "0xold")
+	st.Mark(StepKeygen, Done, "", "")
+	st.Mark(StepOnchain, Done, "", "")
+	st.Mark(StepFiles, Failed, "rename merged_agent_keys.json", "fix the permissions and rerun keytool rotate")
+	st.Backups = []Backup{{Path: "keys/payment.jwk", From: "keys/payment.jwk.bak-" + st.Stamp}}
+	if err := st.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("state mode %v", fi.Mode().Perm())
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsDone(StepOnchain) || got.IsDone(StepFiles) || got.Complete() || len(got.Backups) != 1 {
+		t.Fatalf("loaded %+v", got)
+	}
+	plan := got.Plan()
+	if len(plan) != 3 || !strings.HasPrefix(plan[0], "files    failed   rename") || !strings.Contains(plan[0], "-> fix the permissions") {
+		t.Fatalf("plan %q", plan)
+	}
+	for _, s := range []string{StepFiles, StepReload} {
+		got.Mark(s, Done, "", "")
+	}
+	got.Mark(StepRoot, Skipped, "root not running", "")
+	if !got.Complete() || len(got.Plan()) != 0 {
+		t.Fatalf("not complete: %q", got.Plan())
+	}
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !os.IsNotExist(err) {
+		t.Fatalf("load after remove: %v", err)
+	}
+}
+
+func TestRowEdits(t *testing.T) {
+	merged := []byte(`{"agents":[{"name":"payment","did":"did:old","x25519Public":"aa","n":1.50}]}`)
+	out, err := UpsertByName(merged, "payment", map[string]any{"did": "did:new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(out); !strings.Contains(s, `"did": "did:new"`) || !strings.Contains(s, `"x25519Public": "aa"`) || !strings.Contains(s, `1.50`) {
+		t.Fatalf("upsert kept the wrong fields:\n%s", s)
+	}
+	if out, _ := UpsertByName([]byte(`[]`), "medical", map[string]any{"did": "did:m"}); !strings.HasPrefix(string(out), "[") || !strings.Contains(string(out), `"medical"`) {
+		t.Fatalf("append to a bare array:\n%s", out)
+	}
+
+	out, err = ReplaceVerifierKey([]byte(`{"agents":[{"DID":"DID:OLD","PublicKey":"01","Type":"secp256k1"}]}`), "did:old", "did:new", "02")
+	var v struct{ Agents []map[string]string }
+	_ = json.Unmarshal(out, &v)
+	if err != nil || len(v.Agents) != 1 || v.Agents[0]["DID"] != "did:new" || v.Agents[0]["PublicKey"] != "02" {
+		t.Fatalf("verifier list %s: %v", out, err)
+	}
+
+	out, err = UpsertProof([]byte(`[{"name":"payment","did":"did:old"}]`), kemproof.Proof{Name: "payment", DID: "did:new"})
+	if err != nil || strings.Count(string(out), `"did:new"`) != 1 || strings.Contains(string(out), "did:old") {
+		t.Fatalf("proofs %s: %v", out, err)
+	}
+}
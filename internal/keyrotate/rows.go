@@ -0,0 +1,135 @@
+package keyrotate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+)
+
+// decodeRows reads a top-level array of objects or a {"<wrap>": [...]} wrapper,
+// keeping unknown fields and number formatting.
+func decodeRows(b []byte, wrap string) (rows []map[string]any, wrapped bool, err error) {
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false, err
+	}
+	var arr []any
+	switch t := v.(type) {
+	case []any:
+		arr = t
+	case map[string]any:
+		a, ok := t[wrap].([]any)
+		if !ok {
+			return nil, false, fmt.Errorf("expected an array or {%q: [...]}", wrap)
+		}
+		arr, wrapped = a, true
+	default:
+		return nil, false, fmt.Errorf("expected an array or {%q: [...]}", wrap)
+	}
+	for _, x := range arr {
+		m, ok := x.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("row is not an object")
+		}
+		rows = append(rows, m)
+	}
+	return rows, wrapped, nil
+}
+
+func encodeRows(rows []map[string]any, wrap string, wrapped bool) ([]byte, error) {
+	var v any = rows
+	if wrapped {
+		v = map[string]any{wrap: rows}
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// UpsertByName sets fields on the row whose "name" is name (appending a new
+// row when there is none). Used for the signing keys list
+// (generated_agent_keys.json) and merged_agent_keys.json; other fields of the
+// row — e.g. the X25519 pair in the merged file — are kept.
+func UpsertByName(b []byte, name string, fields map[string]any) ([]byte, error) {
+	rows, wrapped, err := decodeRows(b, "agents")
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, r := range rows {
+		if n, _ := r["name"].(string); n == name {
+			for k, v := range fields {
+				r[k] = v
+			}
+			found = true
+		}
+	}
+	if !found {
+		r := map[string]any{"name": name}
+		for k, v := range fields {
+			r[k] = v
+		}
+		rows = append(rows, r)
+	}
+	return encodeRows(rows, "agents", wrapped)
+}
+
+// ReplaceVerifierKey swaps oldDID's entry in an inbound verifier list
+// (keys/all_keys.json: {"agents":[{DID, PublicKey, Type}]}) for the new DID and
+// key, appending it when oldDID is not listed.
+func ReplaceVerifierKey(b []byte, oldDID, newDID, pubHex string) ([]byte, error) {
+	rows, _, err := decodeRows(b, "agents")
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, r := range rows {
+		if d, _ := r["DID"].(string); strings.EqualFold(strings.TrimSpace(d), oldDID) {
+			r["DID"], r["PublicKey"] = newDID, pubHex
+			found = true
+		}
+	}
+	if !found {
+		rows = append(rows, map[string]any{"DID": newDID, "PublicKey": pubHex, "Type": "secp256k1"})
+	}
+	return encodeRows(rows, "agents", true)
+}
+
+// UpsertProof replaces the KEM ownership proof for p.Name (or p.DID) in a
+// proofs file ({"proofs":[...]}), appending it when absent.
+func UpsertProof(b []byte, p kemproof.Proof) ([]byte, error) {
+	var proofs []kemproof.Proof
+	if len(bytes.TrimSpace(b)) > 0 {
+		var w struct {
+			Proofs []kemproof.Proof `json:"proofs"`
+		}
+		if err := json.Unmarshal(b, &proofs); err != nil {
+			if err := json.Unmarshal(b, &w); err != nil {
+				return nil, fmt.Errorf("kem proof json not recognized")
+			}
+			proofs = w.Proofs
+		}
+	}
+	found := false
+	for i, q := range proofs {
+		if (p.Name != "" && q.Name == p.Name) || strings.EqualFold(q.DID, p.DID) {
+			proofs[i] = p
+			found = true
+		}
+	}
+	if !found {
+		proofs = append(proofs, p)
+	}
+	out, err := json.MarshalIndent(map[string]any{"proofs": proofs}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,147 @@
+package keyrotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+	"github.com/sage-x-project/sage-multi-agent/internal/statefile"
+)
+
+// Rotation steps, in order.
+const (
+	StepKeygen  = "keygen"  // new key pair generated and parked
+	StepOnchain = "onchain" // new key registered in the AgentCard registry
+	StepFiles   = "files"   // JWK + key lists replaced (with backups)
+	StepReload  = "reload"  // agent picked up the key (hot reload or restart)
+	StepRoot    = "root"    // Root's HPKE session + pin for the target dropped
+)
+
+// Steps lists the rotation steps in execution order.
+var Steps = []string{StepKeygen, StepOnchain, StepFiles, StepReload, StepRoot}
+
+// Step statuses.
+const (
+	Pending = "pending"
+	Done    = "done"
+	Failed  = "failed"
+	Manual  = "manual" // needs an operator action (see Resume)
+	Skipped = "skipped"
+)
+
+// StepState is one step's outcome; Resume tells the operator how to finish it.
+type StepState struct {
+	Status string    `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+	Resume string    `json:"resume,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// State is a rotation in progress, persisted after every step so a failed run
+// can be resumed (or rolled back) from where it stopped.
+type State struct {
+	Agent     string    `json:"agent"`
+	Stamp     string    `json:"stamp"` // backup suffix
+	StartedAt time.Time `json:"startedAt"`
+
+	OldDID     string `json:"oldDid"`
+	OldAddress string `json:"oldAddress,omitempty"`
+	NewDID     string `json:"newDid,omitempty"`
+	NewAddress string `json:"newAddress,omitempty"`
+	NewPublic  string `json:"newPublicKey,omitempty"`
+
+	// PendingKey is the 0600 file holding the new key until the rotation is
+	// complete (a rollback re-applies it from there).
+	PendingKey string `json:"pendingKey,omitempty"`
+
+	// KEMProof binds the agent's X25519 key to the new owner address.
+	KEMProof *kemproof.Proof `json:"kemProof,omitempty"`
+
+	Steps   map[string]StepState `json:"steps"`
+	Backups []Backup             `json:"backups,omitempty"`
+}
+
+// New starts a rotation for agent.
+func New(agent, oldDID, oldAddr string) *State {
+	now := time.Now().UTC()
+	st := &State{
+		Agent: agent, Stamp: now.Format("20060102T150405Z"), StartedAt: now,
+		OldDID: oldDID, OldAddress: oldAddr, Steps: map[string]StepState{},
+	}
+	for _, s := range Steps {
+		st.Steps[s] = StepState{Status: Pending, At: now}
+	}
+	return st
+}
+
+// stateOpts keeps the payload private (it names the pending key file).
+var stateOpts = statefile.Options{Perm: 0o600}
+
+// Load reads a saved rotation; os.ErrNotExist when there is none. A corrupt
+// file is recovered from its last good copy (internal/statefile).
+func Load(path string) (*State, error) {
+	b, err := statefile.Load(path, stateOpts)
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("keyrotate: %s: %w", path, err)
+	}
+	if st.Steps == nil {
+		st.Steps = map[string]StepState{}
+	}
+	return &st, nil
+}
+
+// Save writes the state atomically (0600: it names the pending key file).
+func (st *State) Save(path string) error {
+	return statefile.SaveJSON(path, st, stateOpts)
+}
+
+// Remove deletes a finished rotation's state with its backups.
+func Remove(path string) error {
+	return statefile.Remove(path)
+}
+
+// Mark records a step's outcome.
+func (st *State) Mark(step, status, detail, resume string) {
+	st.Steps[step] = StepState{Status: status, Detail: detail, Resume: resume, At: time.Now().UTC()}
+}
+
+// IsDone reports whether step has completed (or was skipped).
+func (st *State) IsDone(step string) bool {
+	s := st.Steps[step].Status
+	return s == Done || s == Skipped
+}
+
+// Complete reports whether nothing is left to do.
+func (st *State) Complete() bool {
+	for _, s := range Steps {
+		if !st.IsDone(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan lists what is left, one line per unfinished step, in order.
+func (st *State) Plan() []string {
+	var out []string
+	for _, s := range Steps {
+		ss := st.Steps[s]
+		if st.IsDone(s) {
+			continue
+		}
+		line := fmt.Sprintf("%-8s %-8s", s, ss.Status)
+		if ss.Detail != "" {
+			line += " " + ss.Detail
+		}
+		if ss.Resume != "" {
+			line += "\n         -> " + ss.Resume
+		}
+		out = append(out, line)
+	}
+	return out
+}
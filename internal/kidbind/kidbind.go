@@ -0,0 +1,254 @@
+// Package kidbind validates HPKE key IDs and ties each session's KID to the
+// DID that established it.
+//
+// The server side looks HPKE sessions up by the X-KID header alone, and that
+// header travels in clear text: anyone who has seen a KID could send data-mode
+// requests under another peer's session. Agents therefore remember, per KID,
+// the DID whose handshake created it, and Check the signer of every data-mode
+// request against it. Bindings come only from handshakes: Book.Handshake
+// reads the KID from the handshake answer (the X-KID header, else the "kid"
+// of the ack in the transport.Response "data" field). A KID the book has no
+// binding for is refused (KID_UNBOUND), never bound to whoever uses it first.
+// When the book is full, the DID holding the most bindings loses its oldest,
+// so one peer flooding handshakes only evicts its own sessions.
+//
+// Normalize rejects malformed values before they reach the session map:
+// 1..MaxLen characters of [A-Za-z0-9._:-].
+//
+// A data-mode request under a KID the agent has no session for is answered
+// 409 SESSION_NOT_READY (WriteNotReady) rather than handed to the handshake
+// server: right after a handshake the sender's first request can arrive
+// before the session is committed. Only a body that parses as a handshake
+// message (LooksLikeHandshake) still goes to the handshake server.
+package kidbind
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxLen is the longest accepted KID.
+const MaxLen = 128
+
+// Error codes in the JSON error body.
+const (
+	CodeInvalid  = "INVALID_KID"
+	CodeMismatch = "KID_DID_MISMATCH"
+	CodeUnbound  = "KID_UNBOUND"
+	CodeNotReady = "SESSION_NOT_READY"
+)
+
+// ErrInvalid is returned by Normalize for a malformed KID.
+var ErrInvalid = errors.New("kidbind: invalid kid")
+
+// Normalize trims raw and validates it; "" (no header) is a handshake and
+// not an error.
+func Normalize(raw string) (string, error) {
+	kid := strings.TrimSpace(raw)
+	if kid == "" {
+		return "", nil
+	}
+	if len(kid) > MaxLen {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalid, MaxLen)
+	}
+	for i := 0; i < len(kid); i++ {
+		c := kid[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return "", fmt.Errorf("%w: character %q at %d", ErrInvalid, c, i)
+		}
+	}
+	return kid, nil
+}
+
+var keyIDRe = regexp.MustCompile(`keyid="([^"]+)"`)
+
+// SignerDID is the keyid of the request's Signature-Input, "" when unsigned.
+// It is only the signer's DID behind the DID middleware, which verified it;
+// unlike RequestDID it never falls back to a header the caller chose.
+func SignerDID(r *http.Request) string {
+	if m := keyIDRe.FindStringSubmatch(r.Header.Get("Signature-Input")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// RequestDID is the DID a request speaks for: the Signature-Input keyid
+// (verified when the DID middleware is on), else the X-SAGE-DID header.
+func RequestDID(r *http.Request) string {
+	if did := SignerDID(r); did != "" {
+		return did
+	}
+	return strings.TrimSpace(r.Header.Get("X-SAGE-DID"))
+}
+
+type binding struct {
+	did string
+	at  time.Time
+}
+
+// Book maps KIDs to their establishing DIDs. At most max bindings are kept;
+// when full, the oldest binding of the DID with the most goes first.
+type Book struct {
+	mu  sync.Mutex
+	m   map[string]binding
+	max int
+}
+
+// NewBook returns a Book holding at most max bindings (default 4096).
+func NewBook(max int) *Book {
+	if max <= 0 {
+		max = 4096
+	}
+	return &Book{m: map[string]binding{}, max: max}
+}
+
+// Bind records did as kid's owner.
+func (b *Book) Bind(kid, did string) {
+	if kid == "" || did == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.m[kid]; !ok && len(b.m) >= b.max {
+		b.evictLocked()
+	}
+	b.m[kid] = binding{did: did, at: time.Now()}
+}
+
+// evictLocked drops the oldest binding of the DID holding the most.
+func (b *Book) evictLocked() {
+	count := map[string]int{}
+	top := ""
+	for _, v := range b.m {
+		count[v.did]++
+		if top == "" || count[v.did] > count[top] {
+			top = v.did
+		}
+	}
+	oldest, at := "", time.Time{}
+	for k, v := range b.m {
+		if v.did == top && (oldest == "" || v.at.Before(at)) {
+			oldest, at = k, v.at
+		}
+	}
+	delete(b.m, oldest)
+}
+
+// Check reports whether did may use kid, and the DID kid is bound to ("" for
+// a kid no handshake bound; never ok).
+func (b *Book) Check(kid, did string) (bound string, ok bool) {
+	b.mu.Lock()
+	v, found := b.m[kid]
+	b.mu.Unlock()
+	if !found {
+		return "", false
+	}
+	return v.did, v.did == did
+}
+
+// Len is the number of bindings.
+func (b *Book) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.m)
+}
+
+// Handshake serves a handshake request through h and binds the KID in its
+// answer to did. It returns that KID ("" when the answer carried none).
+func (b *Book) Handshake(h http.Handler, w http.ResponseWriter, r *http.Request, did string) string {
+	rec := &teeWriter{ResponseWriter: w}
+	h.ServeHTTP(rec, r)
+	if rec.status >= 300 {
+		return ""
+	}
+	kid := kidFromAnswer(rec.Header(), rec.buf.Bytes())
+	if kid, err := Normalize(kid); err == nil && kid != "" {
+		b.Bind(kid, did)
+		return kid
+	}
+	return ""
+}
+
+// teeWriter passes the answer through and keeps the first 64 KiB of it.
+type teeWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (t *teeWriter) WriteHeader(code int) {
+	if t.status == 0 {
+		t.status = code
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	if room := 64<<10 - t.buf.Len(); room > 0 {
+		t.buf.Write(p[:min(len(p), room)])
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+// kidFromAnswer reads the KID from a handshake answer: the X-KID header,
+// else the handshake server's reply, a transport.Response whose "data"
+// (base64 JSON) is the ack carrying the session "kid".
+func kidFromAnswer(h http.Header, body []byte) string {
+	if kid := strings.TrimSpace(h.Get("X-KID")); kid != "" {
+		return kid
+	}
+	var resp struct {
+		Success bool   `json:"success"`
+		Data    []byte `json:"data"`
+	}
+	if json.Unmarshal(body, &resp) != nil || !resp.Success {
+		return ""
+	}
+	var ack struct {
+		KID string `json:"kid"`
+	}
+	if json.Unmarshal(resp.Data, &ack) != nil {
+		return ""
+	}
+	return ack.KID
+}
+
+// LooksLikeHandshake reports whether body is a JSON handshake message rather
+// than HPKE ciphertext.
+func LooksLikeHandshake(body []byte) bool {
+	b := bytes.TrimSpace(body)
+	return len(b) > 0 && b[0] == '{' && json.Valid(b)
+}
+
+var notReady atomic.Int64
+
+// WriteNotReady answers 409 SESSION_NOT_READY for a data-mode request under
+// kid, which has no session (yet), and counts it.
+func WriteNotReady(w http.ResponseWriter, kid string) {
+	notReady.Add(1)
+	WriteError(w, http.StatusConflict, CodeNotReady, "no hpke session for kid "+kid+" yet; retry shortly or handshake again")
+}
+
+// NotReadyCount is the number of SESSION_NOT_READY answers written.
+func NotReadyCount() int64 { return notReady.Load() }
+
+// WriteError answers status with {"error": code, "reason": reason}.
+func WriteError(w http.ResponseWriter, status int, code, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "reason": reason})
+}
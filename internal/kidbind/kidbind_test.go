@@ -0,0 +1,138 @@
+package kidbind
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// handshakeServer answers like the sage handshake adapter: a
+// transport.Response whose data is the ack carrying the new kid.
+func handshakeServer(kid string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ack, _ := json.Marshal(map[string]any{"v": "v1", "kid": kid, "ackTagB64": "AAAA"})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "message_id": "m1", "task_id": "t1", "data": ack})
+	})
+}
+
+func handshake(t *testing.T, b *Book, kid, did string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/payment/process", strings.NewReader(`{}`))
+	if got := b.Handshake(handshakeServer(kid), httptest.NewRecorder(), r, did); got != kid {
+		t.Fatalf("Handshake bound %q, want %q", got, kid)
+	}
+}
+
+func TestTwoIdentities(t *testing.T) {
+	const alice, mallory = "did:sage:ethereum:0xA11CE", "did:sage:ethereum:0x3A11"
+	b := NewBook(8)
+	handshake(t, b, "kid-alice", alice)
+	handshake(t, b, "kid-mallory", mallory)
+
+	if bound, ok := b.Check("kid-alice", alice); !ok || bound != alice {
+		t.Fatalf("alice on her kid: bound=%q ok=%v", bound, ok)
+	}
+	if bound, ok := b.Check("kid-alice", mallory); ok || bound != alice {
+		t.Fatalf("mallory on alice's kid: bound=%q ok=%v", bound, ok)
+	}
+	// No trust on first use: a kid no handshake bound stays refused
+	for i := 0; i < 2; i++ {
+		if bound, ok := b.Check("kid-unseen", mallory); ok || bound != "" {
+			t.Fatalf("unbound kid: bound=%q ok=%v", bound, ok)
+		}
+	}
+
+	// Mallory flooding handshakes evicts only Mallory's bindings
+	for i := 0; i < 100; i++ {
+		handshake(t, b, fmt.Sprintf("kid-flood-%d", i), mallory)
+	}
+	if b.Len() != 8 {
+		t.Fatalf("book holds %d bindings, want 8", b.Len())
+	}
+	if _, ok := b.Check("kid-alice", alice); !ok {
+		t.Fatal("alice's binding was evicted by another DID's handshakes")
+	}
+	if _, ok := b.Check("kid-flood-99", mallory); !ok {
+		t.Fatal("newest binding missing")
+	}
+}
+
+func TestKIDFromAnswer(t *testing.T) {
+	ack, _ := json.Marshal(map[string]string{"kid": "kid-1"})
+	wire, _ := json.Marshal(map[string]any{"success": true, "data": ack})
+	failed, _ := json.Marshal(map[string]any{"success": false, "data": ack, "error": "bad"})
+	cases := []struct {
+		name   string
+		header string
+		body   []byte
+		want   string
+	}{
+		{"header", "kid-h", nil, "kid-h"},
+		{"ack in data", "", wire, "kid-1"},
+		{"failed handshake", "", failed, ""},
+		// a kid elsewhere in the body is not the session's
+		{"not in ack", "", []byte(`{"success":true,"data":null,"meta":{"kid":"kid-x"}}`), ""},
+		{"not json", "", []byte("ciphertext"), ""},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		if c.header != "" {
+			h.Set("X-KID", c.header)
+		}
+		if got := kidFromAnswer(h, c.body); got != c.want {
+			t.Errorf("%s: kid=%q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// SignerDID only trusts the signature's keyid; RequestDID falls back to the
+// X-SAGE-DID header.
+func TestSignerDID(t *testing.T) {
+	signed := httptest.NewRequest(http.MethodGet, "/", nil)
+	signed.Header.Set("Signature-Input", `sig1=("@method" "@path");created=1;keyid="did:sage:a";alg="es256k"`)
+	signed.Header.Set("X-SAGE-DID", "did:sage:b")
+	claimed := httptest.NewRequest(http.MethodGet, "/", nil)
+	claimed.Header.Set("X-SAGE-DID", "did:sage:b")
+
+	if got := SignerDID(signed); got != "did:sage:a" {
+		t.Fatalf("signed: %q", got)
+	}
+	if got := SignerDID(claimed); got != "" {
+		t.Fatalf("header-only request has signer %q", got)
+	}
+	if RequestDID(signed) != "did:sage:a" || RequestDID(claimed) != "did:sage:b" {
+		t.Fatalf("RequestDID: %q %q", RequestDID(signed), RequestDID(claimed))
+	}
+}
+
+// Ciphertext under an unknown kid is answered 409 SESSION_NOT_READY; only a
+// JSON body is still taken for a handshake.
+func TestNotReady(t *testing.T) {
+	for body, want := range map[string]bool{
+		`{"v":"v1","enc":"AAAA"}`: true,
+		"  {\"init\":1}\n":        true,
+		"\x8f\x01ciphertext":      false,
+		`{"truncated":`:           false,
+		"":                        false,
+	} {
+		if LooksLikeHandshake([]byte(body)) != want {
+			t.Errorf("LooksLikeHandshake(%q) = %v", body, !want)
+		}
+	}
+
+	before := NotReadyCount()
+	w := httptest.NewRecorder()
+	WriteNotReady(w, "kid-7")
+	var got map[string]string
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusConflict || got["error"] != CodeNotReady || !strings.Contains(got["reason"], "kid-7") {
+		t.Fatalf("%d %v", w.Code, got)
+	}
+	if NotReadyCount() != before+1 {
+		t.Fatal("answer not counted")
+	}
+}
@@ -0,0 +1,194 @@
+// Package logclip keeps log lines short without losing what they cut.
+//
+// Clip(component, s) returns s cut to the component's inline limit:
+// LOG_CLIP_<COMPONENT> bytes (e.g. LOG_CLIP_ROOT, LOG_CLIP_LLM,
+// LOG_CLIP_GATEWAY), else LOG_CLIP_DEFAULT, else the built-in default for the
+// component; 0 means no limit. When the text is cut, the full content goes to
+// the debug sink under an ID and the inline text ends with
+// "… (details: dbg-7f3a9c)". The sink always keeps the latest
+// LOG_DEBUG_RING (default 512) entries in memory; with LOG_DEBUG_DIR set it
+// also appends them to <dir>/debug.ndjson, rotated to debug.ndjson.1 at
+// LOG_DEBUG_MAX_BYTES (default 64 MiB), so IDs printed by another process
+// sharing the directory can be looked up too (Lookup).
+package logclip
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// builtinLimits are the inline limits when nothing is configured.
+var builtinLimits = map[string]int{
+	"root":    240,
+	"llm":     2048,
+	"gateway": 2048,
+}
+
+const fallbackLimit = 240
+
+// Limit returns the inline limit in bytes for component (0 = unlimited).
+func Limit(component string) int {
+	c := strings.ToUpper(strings.TrimSpace(component))
+	for _, k := range []string{"LOG_CLIP_" + c, "LOG_CLIP_DEFAULT"} {
+		if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(k))); err == nil && n >= 0 {
+			return n
+		}
+	}
+	if n, ok := builtinLimits[strings.ToLower(c)]; ok {
+		return n
+	}
+	return fallbackLimit
+}
+
+// Entry is one full-content capture.
+type Entry struct {
+	ID        string    `json:"id"`
+	Component string    `json:"component"`
+	At        time.Time `json:"at"`
+	Bytes     int       `json:"bytes"`
+	Content   string    `json:"content"`
+}
+
+// Clip returns s within component's limit; cut text is captured in the sink.
+func Clip(component, s string) string {
+	return ClipN(component, s, Limit(component))
+}
+
+// ClipN is Clip with an explicit limit.
+func ClipN(component, s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	id := defaultSink.put(component, s)
+	return s[:cut] + "… (details: " + id + ")"
+}
+
+// Lookup returns the captured entry for id from memory or the sink files.
+func Lookup(id string) (Entry, bool) {
+	return defaultSink.get(strings.TrimSpace(id))
+}
+
+type sink struct {
+	mu    sync.Mutex
+	ring  []Entry
+	next  int
+	index map[string]int // id -> ring slot
+
+	dir      string
+	maxBytes int64
+}
+
+var defaultSink = newSink()
+
+func newSink() *sink {
+	n := 512
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LOG_DEBUG_RING"))); err == nil && v > 0 {
+		n = v
+	}
+	max := int64(64 << 20)
+	if v, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("LOG_DEBUG_MAX_BYTES")), 10, 64); err == nil && v > 0 {
+		max = v
+	}
+	return &sink{
+		ring: make([]Entry, 0, n), index: make(map[string]int),
+		dir: strings.TrimSpace(os.Getenv("LOG_DEBUG_DIR")), maxBytes: max,
+	}
+}
+
+func newID() string {
+	var b [3]byte
+	_, _ = rand.Read(b[:])
+	return "dbg-" + hex.EncodeToString(b[:])
+}
+
+func (s *sink) put(component, content string) string {
+	e := Entry{ID: newID(), Component: component, At: time.Now().UTC(), Bytes: len(content), Content: content}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ring) < cap(s.ring) {
+		s.index[e.ID] = len(s.ring)
+		s.ring = append(s.ring, e)
+	} else {
+		delete(s.index, s.ring[s.next].ID)
+		s.ring[s.next] = e
+		s.index[e.ID] = s.next
+		s.next = (s.next + 1) % len(s.ring)
+	}
+	if s.dir != "" {
+		s.appendFile(e)
+	}
+	return e.ID
+}
+
+// appendFile writes e to the NDJSON file, rotating first when it is full.
+// Errors are dropped: the inline log line is still written.
+func (s *sink) appendFile(e Entry) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return
+	}
+	path := filepath.Join(s.dir, "debug.ndjson")
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= s.maxBytes {
+		_ = os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	b, _ := json.Marshal(e)
+	_, _ = f.Write(append(b, '\n'))
+}
+
+func (s *sink) get(id string) (Entry, bool) {
+	s.mu.Lock()
+	if i, ok := s.index[id]; ok {
+		e := s.ring[i]
+		s.mu.Unlock()
+		return e, true
+	}
+	dir := s.dir
+	s.mu.Unlock()
+	if dir == "" || id == "" {
+		return Entry{}, false
+	}
+	for _, name := range []string{"debug.ndjson", "debug.ndjson.1"} {
+		if e, ok := scanFile(filepath.Join(dir, name), id); ok {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+func scanFile(path, id string) (Entry, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 64<<20)
+	needle := `"id":"` + id + `"`
+	for sc.Scan() {
+		if !strings.Contains(sc.Text(), needle) {
+			continue
+		}
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
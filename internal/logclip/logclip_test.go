@@ -0,0 +1,97 @@
+package logclip
+
+import (
+	"strings"
+	"testing"
+)
+
+// freshSink replaces the process sink for one test.
+func freshSink(t *testing.T) {
+	t.Helper()
+	prev := defaultSink
+	defaultSink = newSink()
+	t.Cleanup(func() { defaultSink = prev })
+}
+
+func detailsID(t *testing.T, line string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(line, "(details: ")
+	if !ok {
+		t.Fatalf("no details ID in %q", line)
+	}
+	return strings.TrimSuffix(rest, ")")
+}
+
+func TestLimit(t *testing.T) {
+	t.Setenv("LOG_CLIP_ROOT", "")
+	t.Setenv("LOG_CLIP_DEFAULT", "")
+	if n := Limit("root"); n != 240 {
+		t.Fatalf("root builtin: %d", n)
+	}
+	if n := Limit("payment"); n != fallbackLimit {
+		t.Fatalf("unknown component: %d", n)
+	}
+	t.Setenv("LOG_CLIP_DEFAULT", "500")
+	t.Setenv("LOG_CLIP_ROOT", "0")
+	if Limit("root") != 0 || Limit("gateway") != 500 {
+		t.Fatalf("configured: root=%d gateway=%d", Limit("root"), Limit("gateway"))
+	}
+}
+
+func TestClipInlineAndLookup(t *testing.T) {
+	t.Setenv("LOG_DEBUG_DIR", "")
+	freshSink(t)
+
+	if s := ClipN("root", "short", 10); s != "short" {
+		t.Fatalf("under the limit: %q", s)
+	}
+	full := "upstream said: " + strings.Repeat("결제 실패 ", 100) + "REASON: kid not registered"
+	line := ClipN("root", full, 40)
+	inline, _, _ := strings.Cut(line, "… (details: ")
+	if len(inline) > 40 || !strings.HasPrefix(full, inline) {
+		t.Fatalf("inline %q (%d bytes)", inline, len(inline))
+	}
+	e, ok := Lookup(detailsID(t, line))
+	if !ok || e.Content != full || e.Component != "root" || e.Bytes != len(full) {
+		t.Fatalf("lookup: %+v %v", e, ok)
+	}
+}
+
+func TestRingEviction(t *testing.T) {
+	t.Setenv("LOG_DEBUG_DIR", "")
+	t.Setenv("LOG_DEBUG_RING", "2")
+	freshSink(t)
+
+	var ids []string
+	for _, s := range []string{"first entry", "second entry", "third entry"} {
+		ids = append(ids, detailsID(t, ClipN("llm", s, 3)))
+	}
+	if _, ok := Lookup(ids[0]); ok {
+		t.Fatal("oldest entry survived a full ring")
+	}
+	for _, id := range ids[1:] {
+		if _, ok := Lookup(id); !ok {
+			t.Fatalf("%s evicted", id)
+		}
+	}
+}
+
+// IDs printed by another process sharing LOG_DEBUG_DIR are found in the files,
+// including the rotated one.
+func TestFileLookup(t *testing.T) {
+	t.Setenv("LOG_DEBUG_DIR", t.TempDir())
+	t.Setenv("LOG_DEBUG_MAX_BYTES", "1")
+	freshSink(t)
+	old := detailsID(t, ClipN("gateway", "request dump one", 4))
+	cur := detailsID(t, ClipN("gateway", "request dump two", 4))
+
+	defaultSink = newSink() // the other process: empty ring, same directory
+	for id, want := range map[string]string{old: "request dump one", cur: "request dump two"} {
+		if e, ok := Lookup(id); !ok || e.Content != want {
+			t.Fatalf("%s: %+v %v", id, e, ok)
+		}
+	}
+	if _, ok := Lookup("dbg-000000"); ok {
+		t.Fatal("unknown ID found")
+	}
+}
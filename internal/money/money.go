@@ -0,0 +1,163 @@
+// Package money formats transaction amounts for a display locale.
+//
+// The transaction currency is always primary (KRW in this demo). When the
+// display locale's typical currency differs, an approximate conversion from a
+// static demo rate table can be appended, always marked "approx.". Root's
+// preview and the payment agent's receipt both render through this package so
+// the same amount never appears in two formats.
+//
+// Group is the only thousands-separator implementation in the tree and
+// ParseKRW the matching parser: for every amount n >= 0,
+// ParseKRW(FormatKRW(lang, n)) == n in every locale.
+package money
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KRW is the default transaction currency.
+const KRW = "KRW"
+
+// demoRates is the value of one unit of each currency in KRW (static, demo only).
+var demoRates = map[string]float64{
+	"KRW": 1,
+	"USD": 1350,
+	"EUR": 1470,
+	"JPY": 9,
+}
+
+// localeCurrency is the currency a reader of each display locale thinks in.
+var localeCurrency = map[string]string{
+	"ko": "KRW",
+	"en": "USD",
+	"ja": "JPY",
+}
+
+var symbols = map[string]string{"KRW": "₩", "USD": "$", "EUR": "€", "JPY": "¥"}
+
+// Group inserts thousands separators: 1250000 -> "1,250,000", 0 -> "0",
+// -1234 -> "-1,234". The sign is kept off the digits rather than negating n,
+// so math.MinInt64 formats as "-9,223,372,036,854,775,808".
+func Group(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	digits := strings.TrimPrefix(s, "-")
+	var b strings.Builder
+	b.Grow(len(s) + len(digits)/3)
+	if n < 0 {
+		b.WriteByte('-')
+	}
+	for i := 0; i < len(digits); i++ {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte(digits[i])
+	}
+	return b.String()
+}
+
+func normCurrency(c string) string {
+	c = strings.ToUpper(strings.TrimSpace(c))
+	if c == "" {
+		return KRW
+	}
+	return c
+}
+
+// Format renders amount in currency for lang: ko "1,250,000원", en "₩1,250,000".
+func Format(lang string, amount int64, currency string) string {
+	currency = normCurrency(currency)
+	if currency == KRW && lang == "ko" {
+		return Group(amount) + "원"
+	}
+	if sym, ok := symbols[currency]; ok {
+		return sym + Group(amount)
+	}
+	return Group(amount) + " " + currency
+}
+
+// FormatKRW is Format for a KRW amount: ko "1,250,000원", others "₩1,250,000".
+func FormatKRW(lang string, amount int64) string {
+	return Format(lang, amount, KRW)
+}
+
+// reKRW matches an amount with a KRW marker: "150만원", "1.5만", "1,250,000원",
+// "150000 KRW", "₩1,250,000".
+var reKRW = regexp.MustCompile(`(?i)(₩)?(\d[\d,.]*)(만원|만|원|krw)?`)
+
+// ParseKRW reads the first KRW amount in s (spaces ignored), scaling 만 by
+// 10,000 and truncating decimals. It returns 0 when s has no amount with a
+// currency marker or the amount overflows int64. Amounts are non-negative: a
+// leading "-" is read as a separator, not a sign.
+func ParseKRW(s string) int64 {
+	for _, m := range reKRW.FindAllStringSubmatch(strings.ReplaceAll(s, " ", ""), -1) {
+		if m[1] == "" && m[3] == "" {
+			continue
+		}
+		num := strings.ReplaceAll(m[2], ",", "")
+		factor := int64(1)
+		if m[3] == "만원" || m[3] == "만" {
+			factor = 10000
+		}
+		if strings.Contains(num, ".") {
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil || f*float64(factor) >= math.MaxInt64 {
+				return 0
+			}
+			return int64(f * float64(factor))
+		}
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || n > math.MaxInt64/factor {
+			return 0
+		}
+		return n * factor
+	}
+	return 0
+}
+
+// Approx returns an approximate conversion into the locale's typical currency
+// (e.g. "approx. $926"), or "" when they match or no rate is known.
+func Approx(lang string, amount int64, currency string) string {
+	currency = normCurrency(currency)
+	target, ok := localeCurrency[lang]
+	if !ok || target == currency {
+		return ""
+	}
+	from, ok1 := demoRates[currency]
+	to, ok2 := demoRates[target]
+	if !ok1 || !ok2 || to == 0 {
+		return ""
+	}
+	conv := int64(math.Round(float64(amount) * from / to))
+	label := "approx. "
+	if lang == "ko" {
+		label = "약 "
+	}
+	return label + Format(lang, conv, target)
+}
+
+// Display is Format plus the approx line in parentheses, when any.
+func Display(lang string, amount int64, currency string) string {
+	s := Format(lang, amount, currency)
+	if a := Approx(lang, amount, currency); a != "" {
+		s += " (" + a + ")"
+	}
+	return s
+}
+
+// Rendered is the metadata block carried with previews/receipts so UIs do not
+// re-format: canonical amount/currency plus the strings shown to the user.
+func Rendered(lang string, amount int64, currency string) map[string]any {
+	m := map[string]any{
+		"amount":   amount,
+		"currency": normCurrency(currency),
+		"locale":   lang,
+		"display":  Format(lang, amount, currency),
+	}
+	if a := Approx(lang, amount, currency); a != "" {
+		m["approx"] = a
+	}
+	return m
+}
@@ -0,0 +1,143 @@
+package money
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:             "0",
+		7:             "7",
+		999:           "999",
+		1000:          "1,000",
+		-1234:         "-1,234",
+		-999:          "-999",
+		1250000:       "1,250,000",
+		math.MaxInt64: "9,223,372,036,854,775,807",
+		math.MinInt64: "-9,223,372,036,854,775,808",
+	} {
+		if got := Group(n); got != want {
+			t.Errorf("Group(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		lang     string
+		amount   int64
+		currency string
+		want     string
+	}{
+		{"ko", 1250000, "KRW", "1,250,000원"},
+		{"ko", 1250000, "", "1,250,000원"},
+		{"en", 1250000, "krw", "₩1,250,000"},
+		{"en", 0, "KRW", "₩0"},
+		{"ko", 926, "USD", "$926"},
+		{"en", 12, "CHF", "12 CHF"},
+	}
+	for _, tc := range cases {
+		if got := Format(tc.lang, tc.amount, tc.currency); got != tc.want {
+			t.Errorf("Format(%s, %d, %q) = %q, want %q", tc.lang, tc.amount, tc.currency, got, tc.want)
+		}
+	}
+	if got := FormatKRW("ko", 3000000); got != "3,000,000원" {
+		t.Errorf("FormatKRW ko = %q", got)
+	}
+	if got := FormatKRW("en", 3000000); got != "₩3,000,000" {
+		t.Errorf("FormatKRW en = %q", got)
+	}
+}
+
+func TestParseKRW(t *testing.T) {
+	for in, want := range map[string]int64{
+		"150만원":                 1500000,
+		"150 만원":                1500000,
+		"1.5만":                  15000,
+		"2.55만원":                25500,
+		"1,250,000원":            1250000,
+		"150000 KRW":            150000,
+		"₩1,250,000":            1250000,
+		"12.7원":                 12,
+		"맥북 300만원 결제해줘":         3000000,
+		"3개 사고 5만원":             50000, // "3" has no currency marker
+		"-5만원":                  50000,
+		"":                      0,
+		"300":                   0,
+		"99999999999999999999원": 0,
+		"1000000000000000만원":    0,
+	} {
+		if got := ParseKRW(in); got != want {
+			t.Errorf("ParseKRW(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+// ParseKRW reads back whatever FormatKRW writes, in every locale.
+func TestFormatParseRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	amounts := []int64{0, 1, 999, 1000, 10000, 1250000, math.MaxInt64}
+	for i := 0; i < 1000; i++ {
+		amounts = append(amounts, rng.Int63n(int64(1)<<uint(rng.Intn(63))+1))
+	}
+	for _, n := range amounts {
+		for _, lang := range []string{"ko", "en", "ja", ""} {
+			if got := ParseKRW(FormatKRW(lang, n)); got != n {
+				t.Fatalf("ParseKRW(%q) = %d, want %d", FormatKRW(lang, n), got, n)
+			}
+		}
+	}
+}
+
+func FuzzFormatParseKRW(f *testing.F) {
+	for _, n := range []int64{0, 1, 1000, 1250000, math.MaxInt64} {
+		f.Add(n, "ko")
+		f.Add(n, "en")
+	}
+	f.Fuzz(func(t *testing.T, n int64, lang string) {
+		if n < 0 {
+			n = -(n + 1)
+		}
+		if got := ParseKRW(FormatKRW(lang, n)); got != n {
+			t.Fatalf("ParseKRW(%q) = %d, want %d", FormatKRW(lang, n), got, n)
+		}
+	})
+}
+
+func TestApproxDisplay(t *testing.T) {
+	cases := []struct {
+		lang     string
+		amount   int64
+		currency string
+		approx   string
+		display  string
+	}{
+		{"ko", 1250000, "KRW", "", "1,250,000원"},
+		{"en", 1250000, "KRW", "approx. $926", "₩1,250,000 (approx. $926)"},
+		{"ja", 1250000, "", "approx. ¥138,889", "₩1,250,000 (approx. ¥138,889)"},
+		{"ko", 100, "usd", "약 135,000원", "$100 (약 135,000원)"},
+		{"en", 100, "USD", "", "$100"},
+		{"en", 500, "GBP", "", "500 GBP"},        // no rate
+		{"fr", 1250000, "KRW", "", "₩1,250,000"}, // no locale currency
+	}
+	for _, tc := range cases {
+		if got := Approx(tc.lang, tc.amount, tc.currency); got != tc.approx {
+			t.Errorf("Approx(%s, %d, %q) = %q, want %q", tc.lang, tc.amount, tc.currency, got, tc.approx)
+		}
+		if got := Display(tc.lang, tc.amount, tc.currency); got != tc.display {
+			t.Errorf("Display(%s, %d, %q) = %q, want %q", tc.lang, tc.amount, tc.currency, got, tc.display)
+		}
+	}
+}
+
+func TestRendered(t *testing.T) {
+	m := Rendered("en", 1250000, "")
+	if m["amount"] != int64(1250000) || m["currency"] != "KRW" || m["locale"] != "en" || m["display"] != "₩1,250,000" || m["approx"] != "approx. $926" {
+		t.Fatalf("en: %v", m)
+	}
+	if _, ok := Rendered("ko", 1250000, KRW)["approx"]; ok {
+		t.Fatal("ko carries an approx line for KRW")
+	}
+}
@@ -0,0 +1,75 @@
+// Package msgdecode reads and decodes AgentMessage payloads on the payment and
+// medical /process path with fewer transient allocations than
+// io.ReadAll + json.Unmarshal.
+//
+// Request bodies are read into pooled buffers. Metadata is decoded key by key
+// into a map sized up front, and arrays of strings (history, transcripts) come
+// back as []string instead of []any, which removes one interface box per
+// element. Other values decode exactly as json.Unmarshal would (numbers as
+// float64, objects as map[string]any).
+//
+// A json.Decoder over bytes.Reader was measured and rejected: it copies the
+// input into its own buffer and allocates more than Unmarshal on both small
+// and 1 MB messages.
+package msgdecode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// maxPooled keeps one oversized request from pinning memory in the pool.
+const maxPooled = 4 << 20
+
+var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// ReadBody reads r into a pooled buffer. The returned bytes are valid until
+// release is called; copy anything that must outlive the request.
+func ReadBody(r io.Reader) (body []byte, release func(), err error) {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err = buf.ReadFrom(r)
+	release = func() {
+		if buf.Cap() <= maxPooled {
+			bufPool.Put(buf)
+		}
+	}
+	return buf.Bytes(), release, err
+}
+
+// AgentMessage decodes b into out. The result does not alias b.
+func AgentMessage(b []byte, out *types.AgentMessage) error {
+	var shadow struct {
+		types.AgentMessage
+		Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+	}
+	if err := json.Unmarshal(b, &shadow); err != nil {
+		return err
+	}
+	*out = shadow.AgentMessage
+	out.Metadata = nil
+	if shadow.Metadata == nil {
+		return nil
+	}
+	out.Metadata = make(map[string]any, len(shadow.Metadata))
+	for k, raw := range shadow.Metadata {
+		// a null element would decode as "" in []string; leave those to any
+		if len(raw) > 0 && raw[0] == '[' && !bytes.Contains(raw, []byte("null")) {
+			var ss []string
+			if json.Unmarshal(raw, &ss) == nil {
+				out.Metadata[k] = ss
+				continue
+			}
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		out.Metadata[k] = v
+	}
+	return nil
+}
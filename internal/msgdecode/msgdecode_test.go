@@ -0,0 +1,121 @@
+package msgdecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// asUnmarshal turns the []string values AgentMessage produces back into the
+// []any json.Unmarshal would have produced.
+func asUnmarshal(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if ss, ok := v.([]string); ok {
+			a := make([]any, len(ss))
+			for i, s := range ss {
+				a[i] = s
+			}
+			v = a
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func TestEquivalentToUnmarshal(t *testing.T) {
+	inputs := []string{
+		`{"id":"m1","from":"root","to":"payment","content":"아이폰 사줘","type":"request","timestamp":"2025-01-02T03:04:05Z"}`,
+		`{"id":"m2","metadata":{}}`,
+		`{"id":"m3","metadata":null}`,
+		`{"id":"m4","metadata":{"history":["a","b \"quoted\"","한글"],"empty":[],"amount":150000,"ok":true,"none":null}}`,
+		`{"id":"m5","metadata":{"mixed":["a",1,{"k":"v"}],"nulls":["a",null],"nested":[["x"],["y"]]}}`,
+		`{"id":"m6","metadata":{"slots":{"item":"맥북","qty":2},"note":"[not an array]"}}`,
+		`{"id":"m7","metadata":{"text":["contains null as text"]}}`,
+	}
+	for _, in := range inputs {
+		var want, got types.AgentMessage
+		if err := json.Unmarshal([]byte(in), &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := AgentMessage([]byte(in), &got); err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		got.Metadata = asUnmarshal(got.Metadata)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got  %#v\n want %#v", in, got, want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, in := range []string{``, `{"id":`, `{"metadata":{"a":[1,}}`, `[]`} {
+		var want, got types.AgentMessage
+		werr := json.Unmarshal([]byte(in), &want)
+		gerr := AgentMessage([]byte(in), &got)
+		if (werr == nil) != (gerr == nil) {
+			t.Errorf("%q: error %v, json.Unmarshal %v", in, gerr, werr)
+		}
+	}
+}
+
+func TestResultDoesNotAliasInput(t *testing.T) {
+	b := []byte(`{"id":"m1","content":"hello","metadata":{"history":["abc"]}}`)
+	var got types.AgentMessage
+	if err := AgentMessage(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	for i := range b {
+		b[i] = 'x'
+	}
+	if got.Content != "hello" || got.Metadata["history"].([]string)[0] != "abc" {
+		t.Fatalf("decoded message changed with its input: %+v", got)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	body, release, err := ReadBody(strings.NewReader(`{"id":"m1"}`))
+	if err != nil || string(body) != `{"id":"m1"}` {
+		t.Fatalf("%q %v", body, err)
+	}
+	release()
+}
+
+// message builds an AgentMessage body of roughly size bytes, most of it history.
+func message(size int) []byte {
+	var history []string
+	for n := 0; n < size; n += 64 {
+		history = append(history, fmt.Sprintf("turn %d: %s", len(history), strings.Repeat("가", 16)))
+	}
+	b, _ := json.Marshal(types.AgentMessage{
+		ID: "m1", From: "root", To: "payment", Type: "request", Content: "아이폰 사줘",
+		Metadata: map[string]any{"lang": "ko", "history": history, "amount": 150000},
+	})
+	return b
+}
+
+func benchDecode(b *testing.B, size int, decode func([]byte, *types.AgentMessage) error) {
+	in := message(size)
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var m types.AgentMessage
+		if err := decode(in, &m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func unmarshal(b []byte, m *types.AgentMessage) error { return json.Unmarshal(b, m) }
+
+func BenchmarkAgentMessage1KB(b *testing.B) { benchDecode(b, 1<<10, AgentMessage) }
+func BenchmarkAgentMessage1MB(b *testing.B) { benchDecode(b, 1<<20, AgentMessage) }
+func BenchmarkUnmarshal1KB(b *testing.B)    { benchDecode(b, 1<<10, unmarshal) }
+func BenchmarkUnmarshal1MB(b *testing.B)    { benchDecode(b, 1<<20, unmarshal) }
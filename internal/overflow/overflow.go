@@ -0,0 +1,122 @@
+// Package overflow keeps forwarded metadata under a byte budget.
+//
+// When the serialized metadata exceeds the cap, the largest values are moved
+// to a side store and replaced by "<key>_ref" tokens (e.g. medical.history ->
+// medical.history_ref). The sender advertises where tokens can be fetched in
+// "overflow.url"; receivers resolve refs lazily with a Fetcher.
+package overflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// RefSuffix marks a metadata key whose value was moved out.
+	RefSuffix = "_ref"
+	// MetaURL is the metadata key carrying the fetch base URL (ends with "/").
+	MetaURL = "overflow.url"
+
+	// minMoveBytes: values smaller than this are never moved (ids, flags, lang).
+	minMoveBytes = 256
+)
+
+// Size returns the serialized size of meta in bytes.
+func Size(meta map[string]any) int {
+	b, _ := json.Marshal(meta)
+	return len(b)
+}
+
+// Shrink moves the largest values out of meta until it fits capBytes (or no
+// movable value is left). put stores a value and returns its token. It returns
+// the moved keys, largest first.
+func Shrink(meta map[string]any, capBytes int, put func(key string, raw json.RawMessage) string) []string {
+	if capBytes <= 0 || Size(meta) <= capBytes {
+		return nil
+	}
+	type kv struct {
+		key string
+		raw json.RawMessage
+	}
+	var cands []kv
+	for k, v := range meta {
+		if strings.HasSuffix(k, RefSuffix) || k == MetaURL {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil || len(raw) < minMoveBytes {
+			continue
+		}
+		cands = append(cands, kv{k, raw})
+	}
+	sort.Slice(cands, func(i, j int) bool { return len(cands[i].raw) > len(cands[j].raw) })
+
+	var moved []string
+	for _, c := range cands {
+		if Size(meta) <= capBytes {
+			break
+		}
+		delete(meta, c.key)
+		meta[c.key+RefSuffix] = put(c.key, c.raw)
+		moved = append(moved, c.key)
+	}
+	return moved
+}
+
+// Fetcher retrieves a moved value by token.
+type Fetcher func(ctx context.Context, token string) (json.RawMessage, error)
+
+// Doer sends an HTTP request (e.g. an RFC 9421 signing A2A client).
+type Doer interface {
+	Do(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// HTTPFetcher fetches GET <base><token> through doer.
+func HTTPFetcher(doer Doer, base string) Fetcher {
+	return func(ctx context.Context, token string) (json.RawMessage, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+token, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doer.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("overflow fetch %s: status %d", token, resp.StatusCode)
+		}
+		return b, nil
+	}
+}
+
+// Resolve fills meta[key] from meta[key+"_ref"] when only the ref is present.
+// It reports whether meta[key] is available afterwards.
+func Resolve(ctx context.Context, meta map[string]any, key string, fetch Fetcher) (bool, error) {
+	if _, ok := meta[key]; ok {
+		return true, nil
+	}
+	token, _ := meta[key+RefSuffix].(string)
+	if token == "" || fetch == nil {
+		return false, nil
+	}
+	raw, err := fetch(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return false, fmt.Errorf("overflow %s: %w", token, err)
+	}
+	meta[key] = v
+	return true, nil
+}
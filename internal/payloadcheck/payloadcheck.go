@@ -0,0 +1,178 @@
+// Package payloadcheck asserts the shape of AgentMessage payloads at each hop.
+//
+// A /process body must be one JSON object. Inspect flags the corruptions
+// seen in practice:
+//
+//   - double_encoded: the body is a JSON string whose content is an
+//     AgentMessage (something marshaled already-encoded bytes again);
+//   - nested_message: the "content" field holds a whole encoded AgentMessage;
+//   - gw_tamper: a "_gw_tamper" field left by the demo gateway's attack mode,
+//     at the top level or nested in metadata.
+//
+// Root checks what it is about to sign and encrypt, and what it decrypted;
+// the agents check what they decrypted or received in plain. A violation is
+// logged with the hop trace and, when STRICT_PAYLOAD is on, rejected instead of
+// forwarded.
+//
+// The hop trace travels in the X-SAGE-Payload-Trace request header when the
+// sender is in debug mode: each hop appends "name=fingerprint", the first 8
+// hex digits of the SHA-256 of the plaintext it saw and its length, so the
+// first hop whose fingerprint differs is where the bytes changed. A hop that
+// cannot see the plaintext (HPKE ciphertext, gzip) appends its mode instead.
+package payloadcheck
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TraceHeader carries the hop trace.
+const TraceHeader = "X-SAGE-Payload-Trace"
+
+// Finding codes.
+const (
+	DoubleEncoded   = "double_encoded"
+	NestedMessage   = "nested_message"
+	GatewayArtifact = "gw_tamper"
+)
+
+// maxHops bounds the trace, so a forwarding loop cannot grow the header.
+const maxHops = 8
+
+// maxUnwrap bounds how many string layers Unwrap peels.
+const maxUnwrap = 4
+
+// Finding is one violated invariant.
+type Finding struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Findings is what Inspect found; empty when the payload is sound.
+type Findings []Finding
+
+func (fs Findings) String() string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = f.Code
+		if f.Detail != "" {
+			parts[i] += "(" + f.Detail + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Codes lists the finding codes.
+func (fs Findings) Codes() []string {
+	out := make([]string, len(fs))
+	for i, f := range fs {
+		out[i] = f.Code
+	}
+	return out
+}
+
+// Inspect checks b, a plaintext AgentMessage body. Bodies that are neither a
+// JSON object nor a JSON string (plain text answers, empty bodies) pass: only
+// the corruptions above are reported.
+func Inspect(b []byte) Findings {
+	var fs Findings
+	inner, layers := Unwrap(b)
+	if layers > 0 {
+		fs = append(fs, Finding{Code: DoubleEncoded, Detail: "string-encoded " + strconv.Itoa(layers) + "x"})
+	}
+	if !bytes.Contains(inner, []byte("_gw_tamper")) && !bytes.Contains(inner, []byte(`"content"`)) {
+		return fs
+	}
+	var top map[string]json.RawMessage
+	if json.Unmarshal(inner, &top) != nil {
+		return fs
+	}
+	if _, ok := top["_gw_tamper"]; ok {
+		fs = append(fs, Finding{Code: GatewayArtifact, Detail: "top-level"})
+	} else if bytes.Contains(inner, []byte("_gw_tamper")) {
+		fs = append(fs, Finding{Code: GatewayArtifact, Detail: "nested"})
+	}
+	var content string
+	if raw, ok := top["content"]; ok && json.Unmarshal(raw, &content) == nil && isMessage([]byte(strings.TrimSpace(content))) {
+		fs = append(fs, Finding{Code: NestedMessage, Detail: "content"})
+	}
+	return fs
+}
+
+// Unwrap peels JSON string layers around an AgentMessage. It returns the
+// innermost bytes and how many layers it removed (0: b is returned as is).
+func Unwrap(b []byte) ([]byte, int) {
+	cur := bytes.TrimSpace(b)
+	for n := 0; n < maxUnwrap; n++ {
+		if len(cur) == 0 || cur[0] != '"' {
+			break
+		}
+		var s string
+		if json.Unmarshal(cur, &s) != nil {
+			break
+		}
+		next := bytes.TrimSpace([]byte(s))
+		if !isMessage(next) && !(len(next) > 0 && next[0] == '"') {
+			break
+		}
+		cur = next
+		if isMessage(cur) {
+			return cur, n + 1
+		}
+	}
+	return b, 0
+}
+
+// isMessage reports whether b is a JSON object shaped like an AgentMessage:
+// a content field and at least two of id, from, to, type and timestamp.
+func isMessage(b []byte) bool {
+	if len(b) == 0 || b[0] != '{' {
+		return false
+	}
+	var m map[string]json.RawMessage
+	if json.Unmarshal(b, &m) != nil {
+		return false
+	}
+	if _, ok := m["content"]; !ok {
+		return false
+	}
+	n := 0
+	for _, k := range []string{"id", "from", "to", "type", "timestamp"} {
+		if _, ok := m[k]; ok {
+			n++
+		}
+	}
+	return n >= 2
+}
+
+// Strict reports whether violations are rejected (STRICT_PAYLOAD).
+func Strict() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STRICT_PAYLOAD"))) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// Fingerprint is b's trace entry: 8 hex digits of its SHA-256 and its length.
+func Fingerprint(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:4]) + "/" + strconv.Itoa(len(b))
+}
+
+// AppendTrace adds "hop=fp" to trace; past maxHops entries it is unchanged.
+func AppendTrace(trace, hop, fp string) string {
+	trace = strings.TrimSpace(trace)
+	if trace == "" {
+		return hop + "=" + fp
+	}
+	if strings.Count(trace, ",")+1 >= maxHops {
+		return trace
+	}
+	return trace + ", " + hop + "=" + fp
+}
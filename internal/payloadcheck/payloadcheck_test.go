@@ -0,0 +1,91 @@
+package payloadcheck
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const msg = `{"id":"m1","from":"root","to":"payment","type":"request","content":"pay 5000"}`
+
+// quoted JSON-encodes s n times, as a sender marshaling bytes it had already
+// encoded would.
+func quoted(s string, n int) string {
+	for range n {
+		b, _ := json.Marshal(s)
+		s = string(b)
+	}
+	return s
+}
+
+func TestInspect(t *testing.T) {
+	nested, _ := json.Marshal(map[string]any{"id": "m2", "type": "request", "content": msg})
+	for _, tc := range []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"sound", msg, nil},
+		{"plain text", "결제 완료", nil},
+		{"empty", "", nil},
+		{"ordinary string", `"hello"`, nil},
+		{"double encoded", quoted(msg, 1), []string{DoubleEncoded}},
+		{"triple encoded", quoted(msg, 2), []string{DoubleEncoded}},
+		{"nested message", string(nested), []string{NestedMessage}},
+		{"gateway top-level", `{"id":"m1","type":"request","content":"x","_gw_tamper":true}`, []string{GatewayArtifact}},
+		{"gateway in metadata", `{"id":"m1","type":"request","content":"x","metadata":{"_gw_tamper":"amount"}}`, []string{GatewayArtifact}},
+		{"encoded and tampered", quoted(`{"id":"m1","type":"request","content":"x","_gw_tamper":1}`, 1), []string{DoubleEncoded, GatewayArtifact}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Inspect([]byte(tc.body)).Codes(); !slices.Equal(got, tc.want) {
+				t.Fatalf("Inspect = %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if got := Inspect([]byte(quoted(msg, 2))).String(); got != "double_encoded(string-encoded 2x)" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	if inner, n := Unwrap([]byte(quoted(msg, 3))); n != 3 || string(inner) != msg {
+		t.Fatalf("Unwrap = %s, %d", inner, n)
+	}
+	for _, b := range []string{msg, `"not a message"`, quoted(`{"content":"only one key"}`, 1)} {
+		if inner, n := Unwrap([]byte(b)); n != 0 || string(inner) != b {
+			t.Fatalf("Unwrap(%s) = %s, %d", b, inner, n)
+		}
+	}
+}
+
+// Each hop appends its fingerprint; the trace stops growing at maxHops.
+func TestTrace(t *testing.T) {
+	fp := Fingerprint([]byte(msg))
+	if len(fp) != len("0123abcd/78") || !strings.HasSuffix(fp, "/78") || fp != Fingerprint([]byte(msg)) {
+		t.Fatalf("Fingerprint = %q", fp)
+	}
+	if Fingerprint([]byte(quoted(msg, 1))) == fp {
+		t.Fatal("re-encoded bytes share a fingerprint")
+	}
+	trace := AppendTrace("", "root", fp)
+	trace = AppendTrace(trace, "gateway", "hpke")
+	if trace != "root="+fp+", gateway=hpke" {
+		t.Fatalf("trace %q", trace)
+	}
+	for range 20 {
+		trace = AppendTrace(trace, "loop", fp)
+	}
+	if n := strings.Count(trace, "="); n != maxHops {
+		t.Fatalf("%d hops in %q", n, trace)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "true": true, " ON ": true, "1": true, "false": false, "strict": false} {
+		t.Setenv("STRICT_PAYLOAD", v)
+		if Strict() != want {
+			t.Errorf("STRICT_PAYLOAD=%q: %v", v, !want)
+		}
+	}
+}
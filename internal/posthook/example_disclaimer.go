@@ -0,0 +1,33 @@
+//go:build posthook_disclaimer
+
+// Compiled-in example: appends a compliance disclaimer to medical answers.
+// Build the medical agent with -tags posthook_disclaimer; MEDICAL_DISCLAIMER
+// overrides the text.
+
+package posthook
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+const defaultDisclaimer = "This information is not a diagnosis. Consult a licensed healthcare professional."
+
+func init() {
+	Register("medical", "compliance-disclaimer", 100, FailOpen, 0, func(_ context.Context, msg *types.AgentMessage) error {
+		if msg.Type != "response" || strings.TrimSpace(msg.Content) == "" {
+			return nil
+		}
+		text := strings.TrimSpace(os.Getenv("MEDICAL_DISCLAIMER"))
+		if text == "" {
+			text = defaultDisclaimer
+		}
+		if !strings.Contains(msg.Content, text) {
+			msg.Content = strings.TrimRight(msg.Content, "\n") + "\n\n" + text
+		}
+		return nil
+	})
+}
@@ -0,0 +1,361 @@
+// Package posthook runs deployment-specific post-processing hooks on an
+// agent's outgoing AgentMessage before it is serialized, so small
+// customizations (a compliance disclaimer on medical answers, internal merchant
+// codes on receipts) do not need a fork. Hooks run before HPKE encryption and
+// signing, so integrity covers the final content.
+//
+// Hooks are ordered per agent ("payment", "medical", "root" for Root's chat
+// answers) and come from two sources:
+//
+//   - compiled in: Register from an init() in a file behind a build tag, e.g.
+//     //go:build posthook_compliance, built with -tags posthook_compliance;
+//   - HTTP: POSTHOOKS_FILE, a JSON array of Config. The hook gets the message
+//     as a POST body (application/json) and answers 200 with the transformed
+//     message, or 204 to leave it unchanged. id, from, to and contextId are
+//     kept from the original.
+//
+// Every hook has a timeout (HTTP default 500ms) and a fail mode: "open" (the
+// default) logs the failure and continues with the message as it was,
+// "closed" stops the chain and Apply returns ErrFailClosed so the caller
+// answers with an error instead of the untransformed message.
+//
+// Apply records what ran in metadata postHooks ([]Result) and emits audit
+// posthook/run.
+package posthook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/audit"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// Fail modes.
+const (
+	FailOpen   = "open"
+	FailClosed = "closed"
+)
+
+// Outcomes in Result.
+const (
+	OutcomeApplied   = "applied"
+	OutcomeUnchanged = "unchanged"
+	OutcomeFailOpen  = "failed_open"
+	OutcomeFailClose = "failed_closed"
+)
+
+const maxHookResponse = 1 << 20
+
+// ErrFailClosed marks a fail-closed hook that did not complete.
+var ErrFailClosed = errors.New("post-processing hook failed (fail-closed)")
+
+// Func is a compiled-in hook. It edits msg in place.
+type Func func(ctx context.Context, msg *types.AgentMessage) error
+
+// Config is one HTTP hook in POSTHOOKS_FILE.
+type Config struct {
+	Agent     string `json:"agent"` // payment | medical | root
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	TimeoutMs int    `json:"timeoutMs,omitempty"` // default 500
+	FailMode  string `json:"failMode,omitempty"`  // open (default) | closed
+	Order     int    `json:"order,omitempty"`     // lower runs first
+}
+
+// Result is one hook execution as recorded in metadata postHooks.
+type Result struct {
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"` // builtin | http
+	DurationMs float64 `json:"durationMs"`
+	Modified   bool    `json:"modified"`
+	Outcome    string  `json:"outcome"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type hook struct {
+	name     string
+	kind     string
+	order    int
+	failMode string
+	timeout  time.Duration
+	fn       Func
+	url      string
+}
+
+var (
+	regMu    sync.Mutex
+	compiled = map[string][]hook{}
+)
+
+// Register adds a compiled-in hook for agent. A zero timeout means 2s.
+func Register(agent, name string, order int, failMode string, timeout time.Duration, fn Func) {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	regMu.Lock()
+	defer regMu.Unlock()
+	compiled[agent] = append(compiled[agent], hook{name: name, kind: "builtin", order: order, failMode: normFailMode(failMode), timeout: timeout, fn: fn})
+}
+
+func normFailMode(s string) string {
+	if strings.EqualFold(strings.TrimSpace(s), FailClosed) {
+		return FailClosed
+	}
+	return FailOpen
+}
+
+// ConfigsFromEnv reads POSTHOOKS_FILE (nil when unset).
+func ConfigsFromEnv() ([]Config, error) {
+	path := strings.TrimSpace(os.Getenv("POSTHOOKS_FILE"))
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("POSTHOOKS_FILE: %w", err)
+	}
+	var cfgs []Config
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, fmt.Errorf("POSTHOOKS_FILE %s: %w", path, err)
+	}
+	return cfgs, nil
+}
+
+// Chain is the ordered hook list of one agent.
+type Chain struct {
+	agent  string
+	hooks  []hook
+	client *http.Client
+	audit  *audit.Logger
+	logger *log.Logger
+}
+
+// New builds agent's chain from compiled-in hooks and cfgs.
+func New(agent string, cfgs []Config, aud *audit.Logger, logger *log.Logger) *Chain {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[posthook] ", log.LstdFlags)
+	}
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	c := &Chain{agent: agent, client: &http.Client{}, audit: aud, logger: logger}
+	regMu.Lock()
+	c.hooks = append(c.hooks, compiled[agent]...)
+	regMu.Unlock()
+	for _, cfg := range cfgs {
+		if !strings.EqualFold(strings.TrimSpace(cfg.Agent), agent) {
+			continue
+		}
+		if strings.TrimSpace(cfg.URL) == "" {
+			logger.Printf("[posthook] %s hook %q skipped: no url", agent, cfg.Name)
+			continue
+		}
+		t := time.Duration(cfg.TimeoutMs) * time.Millisecond
+		if t <= 0 {
+			t = 500 * time.Millisecond
+		}
+		c.hooks = append(c.hooks, hook{
+			name: firstNonEmpty(cfg.Name, cfg.URL), kind: "http", order: cfg.Order,
+			failMode: normFailMode(cfg.FailMode), timeout: t, url: strings.TrimSpace(cfg.URL),
+		})
+	}
+	sort.SliceStable(c.hooks, func(i, j int) bool { return c.hooks[i].order < c.hooks[j].order })
+	for _, h := range c.hooks {
+		logger.Printf("[posthook] %s: %s hook %q (order=%d fail=%s timeout=%s)", agent, h.kind, h.name, h.order, h.failMode, h.timeout)
+	}
+	return c
+}
+
+// FromEnv is New with POSTHOOKS_FILE; a bad file is logged and ignored.
+func FromEnv(agent string, aud *audit.Logger, logger *log.Logger) *Chain {
+	cfgs, err := ConfigsFromEnv()
+	if err != nil && logger != nil {
+		logger.Printf("[posthook] %v; HTTP hooks disabled", err)
+	}
+	return New(agent, cfgs, aud, logger)
+}
+
+// Len is the number of hooks.
+func (c *Chain) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.hooks)
+}
+
+// Apply runs the hooks on msg in order and records the results in
+// msg.Metadata["postHooks"]; cid is the conversation for the audit event. The
+// error wraps ErrFailClosed when a fail-closed hook failed; msg must then not
+// be sent as is (see FailureMessage).
+func (c *Chain) Apply(ctx context.Context, cid string, msg *types.AgentMessage) ([]Result, error) {
+	if c.Len() == 0 || msg == nil {
+		return nil, nil
+	}
+	results := make([]Result, 0, len(c.hooks))
+	var closedErr error
+	for _, h := range c.hooks {
+		before, _ := json.Marshal(msg)
+		start := time.Now()
+		next, err := c.run(ctx, h, msg)
+		res := Result{Name: h.name, Kind: h.kind, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+		switch {
+		case err != nil && h.failMode == FailClosed:
+			res.Outcome, res.Error = OutcomeFailClose, err.Error()
+			closedErr = fmt.Errorf("%w: %s: %v", ErrFailClosed, h.name, err)
+		case err != nil:
+			res.Outcome, res.Error = OutcomeFailOpen, err.Error()
+		default:
+			*msg = next
+			after, _ := json.Marshal(msg)
+			res.Modified = !bytes.Equal(before, after)
+			res.Outcome = OutcomeUnchanged
+			if res.Modified {
+				res.Outcome = OutcomeApplied
+			}
+		}
+		if err != nil {
+			c.logger.Printf("[posthook][warn] %s hook %q failed (%s) after %.1fms: %v", c.agent, h.name, h.failMode, res.DurationMs, err)
+		}
+		results = append(results, res)
+		if closedErr != nil {
+			break
+		}
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = map[string]any{}
+	}
+	msg.Metadata["postHooks"] = results
+	outcome := "success"
+	if closedErr != nil {
+		outcome = "failure"
+	}
+	c.audit.Emit(audit.Event{
+		Type: "posthook", Action: "run", Outcome: outcome, Target: c.agent, CID: firstNonEmpty(cid, msg.ContextID),
+		Detail: map[string]any{"hooks": results},
+	})
+	return results, closedErr
+}
+
+// FailureMessage replaces msg after a fail-closed hook failed: an error
+// answer with httpStatus 502 that keeps the hook results.
+func FailureMessage(msg *types.AgentMessage, results []Result, err error) types.AgentMessage {
+	return types.AgentMessage{
+		ID:        msg.ID,
+		ContextID: msg.ContextID,
+		From:      msg.From,
+		To:        msg.To,
+		Type:      "error",
+		Content:   "response post-processing failed",
+		Timestamp: time.Now(),
+		Metadata: map[string]any{
+			"error":      "posthook_failed",
+			"reason":     err.Error(),
+			"httpStatus": http.StatusBadGateway,
+			"postHooks":  results,
+		},
+	}
+}
+
+// run executes one hook on a copy of msg and returns the new message.
+func (c *Chain) run(ctx context.Context, h hook, msg *types.AgentMessage) (types.AgentMessage, error) {
+	hctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	if h.fn != nil {
+		cp := cloneMessage(msg)
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					done <- fmt.Errorf("panic: %v", p)
+				}
+			}()
+			done <- h.fn(hctx, &cp)
+		}()
+		select {
+		case err := <-done:
+			return cp, err
+		case <-hctx.Done():
+			return types.AgentMessage{}, fmt.Errorf("timeout after %s", h.timeout)
+		}
+	}
+	return c.post(hctx, h, msg)
+}
+
+// post calls an HTTP hook.
+func (c *Chain) post(ctx context.Context, h hook, msg *types.AgentMessage) (types.AgentMessage, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return types.AgentMessage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return types.AgentMessage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-SAGE-Hook-Agent", c.agent)
+	req.Header.Set("X-SAGE-Hook-Name", h.name)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return types.AgentMessage{}, fmt.Errorf("timeout after %s", h.timeout)
+		}
+		return types.AgentMessage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return cloneMessage(msg), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.AgentMessage{}, fmt.Errorf("hook answered HTTP %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxHookResponse+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			return types.AgentMessage{}, fmt.Errorf("timeout after %s", h.timeout)
+		}
+		return types.AgentMessage{}, err
+	}
+	if len(b) > maxHookResponse {
+		return types.AgentMessage{}, fmt.Errorf("hook response over %d bytes", maxHookResponse)
+	}
+	var out types.AgentMessage
+	if err := json.Unmarshal(b, &out); err != nil {
+		return types.AgentMessage{}, fmt.Errorf("hook response is not an AgentMessage: %v", err)
+	}
+	// Routing identity is not the hook's to change
+	out.ID, out.From, out.To, out.ContextID = msg.ID, msg.From, msg.To, msg.ContextID
+	return out, nil
+}
+
+// cloneMessage copies msg with its top-level metadata map.
+func cloneMessage(msg *types.AgentMessage) types.AgentMessage {
+	cp := *msg
+	if msg.Metadata != nil {
+		cp.Metadata = make(map[string]any, len(msg.Metadata))
+		for k, v := range msg.Metadata {
+			cp.Metadata[k] = v
+		}
+	}
+	return cp
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if s := strings.TrimSpace(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
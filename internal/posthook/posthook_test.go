@@ -0,0 +1,152 @@
+package posthook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func quiet() *log.Logger { return log.New(io.Discard, "", 0) }
+
+func response() *types.AgentMessage {
+	return &types.AgentMessage{ID: "m1", From: "medical", To: "root", ContextID: "c1", Type: "response", Content: "rest and fluids"}
+}
+
+// hookServer answers with h after checking the hook headers.
+func hookServer(t *testing.T, h func(w http.ResponseWriter, in types.AgentMessage)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in types.AgentMessage
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if r.Header.Get("X-SAGE-Hook-Agent") == "" || r.Header.Get("X-SAGE-Hook-Name") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h(w, in)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// An HTTP hook rewrites the content but not the routing identity; 204 leaves
+// the message as it was.
+func TestHTTPRewrite(t *testing.T) {
+	rewrite := hookServer(t, func(w http.ResponseWriter, in types.AgentMessage) {
+		in.Content += " [reviewed]"
+		in.ID, in.To = "forged", "attacker"
+		_ = json.NewEncoder(w).Encode(in)
+	})
+	keep := hookServer(t, func(w http.ResponseWriter, _ types.AgentMessage) { w.WriteHeader(http.StatusNoContent) })
+	c := New("medical", []Config{
+		{Agent: "medical", Name: "keep", URL: keep, Order: 2},
+		{Agent: "medical", Name: "review", URL: rewrite, Order: 1},
+		{Agent: "payment", Name: "other", URL: rewrite},
+	}, nil, quiet())
+
+	msg := response()
+	res, err := c.Apply(context.Background(), "c1", msg)
+	if err != nil || len(res) != 2 {
+		t.Fatalf("%v %+v", err, res)
+	}
+	if res[0].Name != "review" || res[0].Outcome != OutcomeApplied || !res[0].Modified || res[1].Outcome != OutcomeUnchanged {
+		t.Fatalf("results %+v", res)
+	}
+	if msg.Content != "rest and fluids [reviewed]" || msg.ID != "m1" || msg.To != "root" {
+		t.Fatalf("message %+v", msg)
+	}
+	if got, _ := msg.Metadata["postHooks"].([]Result); len(got) != 2 {
+		t.Fatalf("postHooks %v", msg.Metadata["postHooks"])
+	}
+}
+
+// A slow fail-open hook is cut off at its timeout and the chain continues
+// with the message unchanged.
+func TestTimeoutFailOpen(t *testing.T) {
+	slow := hookServer(t, func(w http.ResponseWriter, in types.AgentMessage) {
+		time.Sleep(300 * time.Millisecond)
+		in.Content = "late"
+		_ = json.NewEncoder(w).Encode(in)
+	})
+	c := New("medical", []Config{{Agent: "medical", Name: "slow", URL: slow, TimeoutMs: 20}}, nil, quiet())
+	msg := response()
+	start := time.Now()
+	res, err := c.Apply(context.Background(), "", msg)
+	if err != nil || res[0].Outcome != OutcomeFailOpen || !strings.Contains(res[0].Error, "timeout after 20ms") {
+		t.Fatalf("%v %+v", err, res)
+	}
+	if msg.Content != "rest and fluids" || time.Since(start) > 250*time.Millisecond {
+		t.Fatalf("content %q after %v", msg.Content, time.Since(start))
+	}
+}
+
+// A failing fail-closed hook stops the chain; the caller answers with
+// FailureMessage instead.
+func TestFailClosed(t *testing.T) {
+	down := hookServer(t, func(w http.ResponseWriter, _ types.AgentMessage) { w.WriteHeader(http.StatusInternalServerError) })
+	after := hookServer(t, func(w http.ResponseWriter, in types.AgentMessage) {
+		in.Content = "should not run"
+		_ = json.NewEncoder(w).Encode(in)
+	})
+	c := New("payment", []Config{
+		{Agent: "payment", Name: "merchant-codes", URL: down, FailMode: "CLOSED"},
+		{Agent: "payment", Name: "after", URL: after, Order: 1},
+	}, nil, quiet())
+	msg := response()
+	res, err := c.Apply(context.Background(), "", msg)
+	if !errors.Is(err, ErrFailClosed) || len(res) != 1 || res[0].Outcome != OutcomeFailClose || !strings.Contains(res[0].Error, "HTTP 500") {
+		t.Fatalf("%v %+v", err, res)
+	}
+	out := FailureMessage(msg, res, err)
+	if out.Type != "error" || out.ID != "m1" || out.Metadata["httpStatus"] != http.StatusBadGateway || out.Metadata["error"] != "posthook_failed" {
+		t.Fatalf("failure message %+v", out)
+	}
+}
+
+// A compiled-in hook that panics fails open without taking the caller down,
+// and its edits are discarded.
+func TestCompiledPanic(t *testing.T) {
+	Register("posthook-test", "panics", 0, "", 0, func(_ context.Context, msg *types.AgentMessage) error {
+		msg.Content = "half-edited"
+		panic("boom")
+	})
+	Register("posthook-test", "tags", 1, FailOpen, 0, func(_ context.Context, msg *types.AgentMessage) error {
+		msg.Content += " ✓"
+		return nil
+	})
+	msg := response()
+	res, err := New("posthook-test", nil, nil, quiet()).Apply(context.Background(), "", msg)
+	if err != nil || res[0].Outcome != OutcomeFailOpen || res[0].Error != "panic: boom" || res[1].Outcome != OutcomeApplied {
+		t.Fatalf("%v %+v", err, res)
+	}
+	if msg.Content != "rest and fluids ✓" {
+		t.Fatalf("content %q", msg.Content)
+	}
+}
+
+func TestConfigsFromEnv(t *testing.T) {
+	t.Setenv("POSTHOOKS_FILE", "")
+	if cfgs, err := ConfigsFromEnv(); cfgs != nil || err != nil {
+		t.Fatalf("unset: %v %v", cfgs, err)
+	}
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	_ = os.WriteFile(path, []byte(`[{"agent":"medical","name":"d","url":"http://x","timeoutMs":50}]`), 0o600)
+	t.Setenv("POSTHOOKS_FILE", path)
+	if cfgs, err := ConfigsFromEnv(); err != nil || len(cfgs) != 1 || cfgs[0].TimeoutMs != 50 {
+		t.Fatalf("%+v %v", cfgs, err)
+	}
+	_ = os.WriteFile(path, []byte(`{`), 0o600)
+	if c := FromEnv("medical", nil, quiet()); c.Len() != 0 {
+		t.Fatal("a bad file added hooks")
+	}
+}
@@ -0,0 +1,79 @@
+// Package prompt holds the prompt-size limit shared by the client API and Root.
+//
+// The limit is counted in UTF-8 BYTES (not runes), so a Korean syllable counts
+// as 3. PROMPT_MAX_BYTES overrides the default; 0 or negative disables it.
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxBytes is the default prompt limit (16 KiB).
+const DefaultMaxBytes = 16 << 10
+
+// MetaAllowTruncation is the metadata key that opts into truncation instead of refusal.
+const MetaAllowTruncation = "allowTruncation"
+
+// MaxBytes returns the configured limit in bytes (0 = unlimited).
+func MaxBytes() int {
+	if v := strings.TrimSpace(os.Getenv("PROMPT_MAX_BYTES")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			if n < 0 {
+				return 0
+			}
+			return n
+		}
+	}
+	return DefaultMaxBytes
+}
+
+// TooLargeError reports a prompt over the limit.
+type TooLargeError struct {
+	LimitBytes int
+	SizeBytes  int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("prompt is %d bytes; the limit is %d bytes (UTF-8)", e.SizeBytes, e.LimitBytes)
+}
+
+// Check returns a *TooLargeError when s exceeds max bytes (max <= 0 = unlimited).
+func Check(s string, max int) error {
+	if max > 0 && len(s) > max {
+		return &TooLargeError{LimitBytes: max, SizeBytes: len(s)}
+	}
+	return nil
+}
+
+// Truncate trims s to at most max bytes without splitting a rune and returns
+// the number of characters (runes) removed.
+func Truncate(s string, max int) (string, int) {
+	if max <= 0 || len(s) <= max {
+		return s, 0
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut], utf8.RuneCountInString(s[cut:])
+}
+
+// WriteTooLarge answers 413 with the limit and the actual size.
+func WriteTooLarge(w http.ResponseWriter, err error) {
+	body := map[string]any{"error": "prompt_too_large", "message": err.Error()}
+	var te *TooLargeError
+	if errors.As(err, &te) {
+		body["limitBytes"] = te.LimitBytes
+		body["sizeBytes"] = te.SizeBytes
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,63 @@
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaxBytes(t *testing.T) {
+	for v, want := range map[string]int{"": DefaultMaxBytes, "100": 100, "0": 0, "-5": 0, "lots": DefaultMaxBytes} {
+		t.Setenv("PROMPT_MAX_BYTES", v)
+		if got := MaxBytes(); got != want {
+			t.Errorf("PROMPT_MAX_BYTES=%q: %d, want %d", v, got, want)
+		}
+	}
+}
+
+// The limit counts bytes: three Korean syllables are 9 bytes.
+func TestCheck(t *testing.T) {
+	var te *TooLargeError
+	if err := Check("가나다", 8); !errors.As(err, &te) || te.SizeBytes != 9 || te.LimitBytes != 8 {
+		t.Fatalf("over: %v", err)
+	}
+	if Check("가나다", 9) != nil || Check("가나다", 0) != nil {
+		t.Fatal("at the limit or unlimited must pass")
+	}
+}
+
+// Truncate never splits a rune and reports removed characters, not bytes.
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		max     int
+		out     string
+		removed int
+	}{
+		{"hello world", 5, "hello", 6},
+		{"가나다", 4, "가", 2},
+		{"가나다", 6, "가나", 1},
+		{"가나다", 2, "", 3},
+		{"ok 🙂🙂", 6, "ok ", 2},
+		{"short", 100, "short", 0},
+		{"no limit", 0, "no limit", 0},
+	} {
+		out, removed := Truncate(tc.in, tc.max)
+		if out != tc.out || removed != tc.removed || !utf8.ValidString(out) {
+			t.Errorf("Truncate(%q, %d) = %q, %d", tc.in, tc.max, out, removed)
+		}
+	}
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteTooLarge(w, Check("가나다", 4))
+	var body map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusRequestEntityTooLarge || body["error"] != "prompt_too_large" || body["limitBytes"] != float64(4) || body["sizeBytes"] != float64(9) {
+		t.Fatalf("%d %v", w.Code, body)
+	}
+}
@@ -0,0 +1,52 @@
+package reqmetrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus renders stats in the Prometheus text exposition format
+// (version 0.0.4). Bucket counts are cumulative, as Prometheus expects.
+func WritePrometheus(w io.Writer, stats []Stats) error {
+	var b bytes.Buffer
+	b.WriteString("# HELP sage_requests_in_flight Requests currently being served.\n# TYPE sage_requests_in_flight gauge\n")
+	for _, st := range stats {
+		b.WriteString(`sage_requests_in_flight{agent="` + labelEscaper.Replace(st.Name) + `"} ` + strconv.FormatInt(st.InFlight, 10) + "\n")
+	}
+	b.WriteString("# HELP sage_request_duration_ms Request latency per route in milliseconds.\n# TYPE sage_request_duration_ms histogram\n")
+	for _, st := range stats {
+		for _, rs := range st.Routes {
+			lbl := `agent="` + labelEscaper.Replace(st.Name) + `",route="` + labelEscaper.Replace(rs.Route) + `"`
+			var cum int64
+			for _, ub := range bucketBoundsMs {
+				cum += rs.Buckets["le_"+strconv.FormatInt(ub, 10)]
+				b.WriteString("sage_request_duration_ms_bucket{" + lbl + `,le="` + strconv.FormatInt(ub, 10) + `"} ` + strconv.FormatInt(cum, 10) + "\n")
+			}
+			cum += rs.Buckets["le_inf"]
+			b.WriteString("sage_request_duration_ms_bucket{" + lbl + `,le="+Inf"} ` + strconv.FormatInt(cum, 10) + "\n")
+			b.WriteString("sage_request_duration_ms_sum{" + lbl + "} " + strconv.FormatInt(rs.SumMs, 10) + "\n")
+			b.WriteString("sage_request_duration_ms_count{" + lbl + "} " + strconv.FormatInt(rs.Count, 10) + "\n")
+		}
+	}
+	b.WriteString("# HELP sage_requests_slow_total Requests slower than their threshold.\n# TYPE sage_requests_slow_total counter\n")
+	for _, st := range stats {
+		for _, rs := range st.Routes {
+			b.WriteString(`sage_requests_slow_total{agent="` + labelEscaper.Replace(st.Name) + `",route="` + labelEscaper.Replace(rs.Route) + `"} ` + strconv.FormatInt(rs.Slow, 10) + "\n")
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Handler serves every tracker in this process for scraping (pull mode).
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = WritePrometheus(w, Snapshot())
+	})
+}
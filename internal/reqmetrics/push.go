@@ -0,0 +1,211 @@
+package reqmetrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Push mode, for machines without a Prometheus server to scrape /metrics.
+//
+//	METRICS_PUSH_URL          Pushgateway base URL (unset = push disabled)
+//	METRICS_PUSH_INTERVAL_MS  push period (default 15000)
+//	METRICS_PUSH_JOB          job label (default "sage-multi-agent")
+//	METRICS_PUSH_INSTANCE     instance label (default hostname)
+//	METRICS_PUSH_MAX_BYTES    payload cap (default 1 MiB)
+//
+// Each push PUTs the full current snapshot to
+// <url>/metrics/job/<job>/instance/<instance>, replacing the previous group.
+// A label value containing "/" (or an empty one) is sent in the Pushgateway's
+// <label>@base64/<value> form, since escaping it would still split the path.
+// Nothing is queued while the target is down: the next attempt sends the
+// then-current snapshot, so an unreachable gateway never grows memory.
+// Failures back off exponentially (interval x 2^n, capped at 5 minutes).
+// Pull mode (Handler) is unaffected.
+
+const maxPushBackoff = 5 * time.Minute
+
+// PushStatus is reported in /status.
+type PushStatus struct {
+	Target              string    `json:"target"`
+	Job                 string    `json:"job"`
+	Instance            string    `json:"instance"`
+	IntervalMs          int64     `json:"intervalMs"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+}
+
+// Pusher periodically pushes Snapshot() to a Pushgateway.
+type Pusher struct {
+	url      string // full group URL
+	interval time.Duration
+	maxBytes int
+	client   *http.Client
+	logger   *log.Logger
+	snapshot func() []Stats
+
+	mu     sync.Mutex
+	status PushStatus
+}
+
+// NewPusher builds a pusher for the given Pushgateway base URL and labels.
+func NewPusher(base, job, instance string, interval time.Duration, logger *log.Logger) *Pusher {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[metrics] ", log.LstdFlags)
+	}
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	u := strings.TrimRight(base, "/") + "/metrics/" + groupLabel("job", job) + "/" + groupLabel("instance", instance)
+	return &Pusher{
+		url: u, interval: interval, maxBytes: 1 << 20,
+		client: &http.Client{Timeout: 10 * time.Second}, logger: logger, snapshot: Snapshot,
+		status: PushStatus{Target: base, Job: job, Instance: instance, IntervalMs: interval.Milliseconds()},
+	}
+}
+
+// groupLabel renders one label of the grouping key as a URL path segment pair.
+func groupLabel(name, value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		return name + "@base64/" + firstNonEmpty(base64.RawURLEncoding.EncodeToString([]byte(value)), "=")
+	}
+	return name + "/" + url.PathEscape(value)
+}
+
+// Status returns the pusher's current state.
+func (p *Pusher) Status() PushStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Push sends one snapshot.
+func (p *Pusher) Push(ctx context.Context) error {
+	var body bytes.Buffer
+	_ = WritePrometheus(&body, p.snapshot())
+	err := p.send(ctx, body.Bytes())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.status.ConsecutiveFailures++
+		p.status.LastError = err.Error()
+		return err
+	}
+	p.status.ConsecutiveFailures = 0
+	p.status.LastError = ""
+	p.status.LastSuccess = time.Now().UTC()
+	return nil
+}
+
+func (p *Pusher) send(ctx context.Context, body []byte) error {
+	if len(body) > p.maxBytes {
+		return fmt.Errorf("payload %d bytes exceeds METRICS_PUSH_MAX_BYTES=%d", len(body), p.maxBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// nextDelay is the wait before the next push given the current failure count.
+func (p *Pusher) nextDelay() time.Duration {
+	n := p.Status().ConsecutiveFailures
+	d := p.interval
+	for i := 0; i < n && d < maxPushBackoff; i++ {
+		d *= 2
+	}
+	if d > maxPushBackoff {
+		d = maxPushBackoff
+	}
+	return d
+}
+
+// Run pushes until ctx is done, then makes one last attempt.
+func (p *Pusher) Run(ctx context.Context) {
+	t := time.NewTimer(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			_ = p.Push(fctx)
+			cancel()
+			return
+		case <-t.C:
+		}
+		if err := p.Push(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			p.logger.Printf("[metrics][push] %v (failures=%d, next in %s)", err, p.Status().ConsecutiveFailures, p.nextDelay())
+		}
+		t.Reset(p.nextDelay())
+	}
+}
+
+var (
+	pushMu     sync.Mutex
+	procPusher *Pusher
+)
+
+// StartPushFromEnv starts the process-wide pusher when METRICS_PUSH_URL is
+// set (once per process) and returns it; nil when push mode is off.
+func StartPushFromEnv(ctx context.Context, logger *log.Logger) *Pusher {
+	base := strings.TrimSpace(os.Getenv("METRICS_PUSH_URL"))
+	if base == "" {
+		return nil
+	}
+	pushMu.Lock()
+	defer pushMu.Unlock()
+	if procPusher != nil {
+		return procPusher
+	}
+	interval := 15 * time.Second
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("METRICS_PUSH_INTERVAL_MS"))); err == nil && n > 0 {
+		interval = time.Duration(n) * time.Millisecond
+	}
+	job := firstNonEmpty(os.Getenv("METRICS_PUSH_JOB"), "sage-multi-agent")
+	host, _ := os.Hostname()
+	instance := firstNonEmpty(os.Getenv("METRICS_PUSH_INSTANCE"), host, "unknown")
+	p := NewPusher(base, job, instance, interval, logger)
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("METRICS_PUSH_MAX_BYTES"))); err == nil && n > 0 {
+		p.maxBytes = n
+	}
+	procPusher = p
+	go p.Run(ctx)
+	p.logger.Printf("[metrics][push] pushing to %s every %s", p.url, interval)
+	return p
+}
+
+// PushSnapshot returns the process-wide pusher's status (nil when push is off).
+func PushSnapshot() *PushStatus {
+	pushMu.Lock()
+	p := procPusher
+	pushMu.Unlock()
+	if p == nil {
+		return nil
+	}
+	st := p.Status()
+	return &st
+}
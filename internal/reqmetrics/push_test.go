@@ -0,0 +1,146 @@
+package reqmetrics
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var quiet = log.New(io.Discard, "", 0)
+
+// fakeGateway records what each push sent and answers with status.
+type fakeGateway struct {
+	mu     sync.Mutex
+	status int
+	pushes []pushed
+}
+
+type pushed struct {
+	method, path, contentType, body string
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pushes = append(g.pushes, pushed{r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), string(body)})
+	if g.status != 0 {
+		w.WriteHeader(g.status)
+	}
+}
+
+func (g *fakeGateway) last(t *testing.T) pushed {
+	t.Helper()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.pushes) == 0 {
+		t.Fatal("nothing pushed")
+	}
+	return g.pushes[len(g.pushes)-1]
+}
+
+func testPusher(t *testing.T, g *fakeGateway, instance string) *Pusher {
+	t.Helper()
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	p := NewPusher(srv.URL+"/", "sage", instance, time.Second, quiet)
+	p.snapshot = func() []Stats {
+		return []Stats{{Name: "payment", InFlight: 2, Routes: []RouteStats{{Route: "/process", Count: 5, SumMs: 40, Slow: 1}}}}
+	}
+	return p
+}
+
+func TestPushPayload(t *testing.T) {
+	g := &fakeGateway{}
+	p := testPusher(t, g, "host-1")
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := g.last(t)
+	if got.method != http.MethodPut || got.path != "/metrics/job/sage/instance/host-1" || got.contentType != "text/plain; version=0.0.4" {
+		t.Fatalf("push %+v", got)
+	}
+	for _, line := range []string{
+		`sage_requests_in_flight{agent="payment"} 2`,
+		`sage_request_duration_ms_count{agent="payment",route="/process"} 5`,
+		`sage_requests_slow_total{agent="payment",route="/process"} 1`,
+	} {
+		if !strings.Contains(got.body, line+"\n") {
+			t.Errorf("payload lacks %q:\n%s", line, got.body)
+		}
+	}
+	if st := p.Status(); st.LastSuccess.IsZero() || st.ConsecutiveFailures != 0 || st.Instance != "host-1" {
+		t.Fatalf("status %+v", st)
+	}
+
+	p.maxBytes = 16
+	if err := p.Push(context.Background()); err == nil || !strings.Contains(err.Error(), "METRICS_PUSH_MAX_BYTES") {
+		t.Fatalf("oversized payload: %v", err)
+	}
+	if len(g.pushes) != 1 {
+		t.Fatalf("oversized payload was sent")
+	}
+}
+
+// Label values the Pushgateway would split on "/" use its base64 form.
+func TestPushGroupLabels(t *testing.T) {
+	for value, want := range map[string]string{
+		"host-1":         "instance/host-1",
+		"pod 7":          "instance/pod%207",
+		"10.0.0.1:80/a":  "instance@base64/MTAuMC4wLjE6ODAvYQ",
+		"team/payment/1": "instance@base64/dGVhbS9wYXltZW50LzE",
+		"":               "instance@base64/=",
+	} {
+		if got := groupLabel("instance", value); got != want {
+			t.Errorf("groupLabel(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	g := &fakeGateway{}
+	p := testPusher(t, g, "node/a")
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.last(t).path; got != "/metrics/job/sage/instance@base64/bm9kZS9h" {
+		t.Fatalf("path %s", got)
+	}
+}
+
+// Failures double the wait up to maxPushBackoff; a success resets it.
+func TestPushBackoff(t *testing.T) {
+	g := &fakeGateway{status: http.StatusServiceUnavailable}
+	p := testPusher(t, g, "host-1")
+	for i := 1; i <= 3; i++ {
+		if err := p.Push(context.Background()); err == nil || !strings.Contains(err.Error(), "HTTP 503") {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	if st := p.Status(); st.ConsecutiveFailures != 3 || st.LastError != "pushgateway: HTTP 503" {
+		t.Fatalf("status %+v", st)
+	}
+	if d := p.nextDelay(); d != 8*time.Second {
+		t.Fatalf("delay after 3 failures: %s", d)
+	}
+	for i := 0; i < 10; i++ {
+		_ = p.Push(context.Background())
+	}
+	if d := p.nextDelay(); d != maxPushBackoff {
+		t.Fatalf("delay after 13 failures: %s", d)
+	}
+
+	g.mu.Lock()
+	g.status = http.StatusOK
+	g.mu.Unlock()
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Status(); st.ConsecutiveFailures != 0 || st.LastError != "" || p.nextDelay() != time.Second {
+		t.Fatalf("after recovery: %+v delay %s", st, p.nextDelay())
+	}
+}
@@ -0,0 +1,251 @@
+// Package reqmetrics provides a shared inbound-request middleware for agents:
+// in-flight gauges, a per-route latency histogram, and a structured warning for
+// requests slower than a configurable threshold.
+//
+// Thresholds (milliseconds):
+//
+//	SLOW_REQUEST_MS         default threshold (default 2000; 0 disables logging)
+//	SLOW_REQUEST_MS_ROUTES  per-route overrides, e.g. "/process=8000,/status=250"
+//	                        (matched exactly, then as a path suffix)
+//
+// Fast requests cost a timestamp and a pooled status recorder; nothing else is
+// allocated unless the request is slow.
+package reqmetrics
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxRoutes caps distinct tracked paths; the rest are folded into "other".
+const maxRoutes = 64
+
+// bucketBoundsMs are the histogram upper bounds; the last bucket is +Inf.
+var bucketBoundsMs = [...]int64{50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// RouteStats is a point-in-time snapshot of one route.
+type RouteStats struct {
+	Route    string           `json:"route"`
+	InFlight int64            `json:"inFlight"`
+	Count    int64            `json:"count"`
+	Slow     int64            `json:"slow"`
+	SumMs    int64            `json:"sumMs"`
+	Buckets  map[string]int64 `json:"buckets"` // "le_<ms>" / "le_inf"
+}
+
+// Stats is a point-in-time snapshot of a tracker.
+type Stats struct {
+	Name     string       `json:"name"`
+	InFlight int64        `json:"inFlight"`
+	Routes   []RouteStats `json:"routes"`
+}
+
+type routeStats struct {
+	inFlight atomic.Int64
+	count    atomic.Int64
+	slow     atomic.Int64
+	sumMs    atomic.Int64
+	buckets  [len(bucketBoundsMs) + 1]atomic.Int64
+}
+
+type threshold struct {
+	route string
+	d     time.Duration
+}
+
+// Tracker wraps handlers of one agent/process.
+type Tracker struct {
+	name   string
+	logger *log.Logger
+
+	def       time.Duration
+	overrides []threshold
+
+	inFlight atomic.Int64
+	routes   sync.Map // path -> *routeStats
+	nRoutes  atomic.Int64
+}
+
+// New returns a tracker named name (e.g. "root", "payment") configured from env.
+func New(name string, logger *log.Logger) *Tracker {
+	if logger == nil {
+		logger = log.New(os.Stdout, "["+name+"] ", log.LstdFlags)
+	}
+	t := &Tracker{name: name, logger: logger, def: 2 * time.Second}
+	if v := strings.TrimSpace(os.Getenv("SLOW_REQUEST_MS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			t.def = time.Duration(n) * time.Millisecond
+		}
+	}
+	for _, kv := range strings.Split(os.Getenv("SLOW_REQUEST_MS_ROUTES"), ",") {
+		route, ms, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(ms)); err == nil && n >= 0 {
+			t.overrides = append(t.overrides, threshold{route: strings.TrimSpace(route), d: time.Duration(n) * time.Millisecond})
+		}
+	}
+	register(t)
+	return t
+}
+
+// thresholdFor returns the slow threshold for path (exact match, then suffix).
+func (t *Tracker) thresholdFor(path string) time.Duration {
+	for _, o := range t.overrides {
+		if o.route == path {
+			return o.d
+		}
+	}
+	for _, o := range t.overrides {
+		if strings.HasSuffix(path, o.route) {
+			return o.d
+		}
+	}
+	return t.def
+}
+
+func (t *Tracker) route(path string) *routeStats {
+	if v, ok := t.routes.Load(path); ok {
+		return v.(*routeStats)
+	}
+	if t.nRoutes.Load() >= maxRoutes {
+		path = "other"
+		if v, ok := t.routes.Load(path); ok {
+			return v.(*routeStats)
+		}
+	}
+	v, loaded := t.routes.LoadOrStore(path, &routeStats{})
+	if !loaded {
+		t.nRoutes.Add(1)
+	}
+	return v.(*routeStats)
+}
+
+// statusRecorder captures the response status; pooled to keep fast paths allocation-free.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+var recPool = sync.Pool{New: func() any { return new(statusRecorder) }}
+
+// Wrap instruments next.
+func (t *Tracker) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rs := t.route(r.URL.Path)
+		t.inFlight.Add(1)
+		rs.inFlight.Add(1)
+
+		rec := recPool.Get().(*statusRecorder)
+		rec.ResponseWriter, rec.status = w, http.StatusOK
+		defer func() {
+			dur := time.Since(start)
+			t.inFlight.Add(-1)
+			rs.inFlight.Add(-1)
+			rs.count.Add(1)
+			rs.sumMs.Add(dur.Milliseconds())
+			rs.buckets[bucketIndex(dur)].Add(1)
+			if th := t.thresholdFor(r.URL.Path); th > 0 && dur >= th {
+				rs.slow.Add(1)
+				t.logSlow(r, rec, dur, th)
+			}
+			rec.ResponseWriter = nil
+			recPool.Put(rec)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// logSlow writes one structured warning. Handlers can expose phase timings by
+// setting a Server-Timing response header; it is included verbatim.
+func (t *Tracker) logSlow(r *http.Request, rec *statusRecorder, dur, th time.Duration) {
+	cid := firstNonEmpty(r.Header.Get("X-SAGE-Context-ID"), r.Header.Get("X-Conversation-Id"))
+	t.logger.Printf("[%s][slow] method=%s path=%s status=%d durMs=%d thresholdMs=%d cid=%q did=%q phases=%q",
+		t.name, r.Method, r.URL.Path, rec.status, dur.Milliseconds(), th.Milliseconds(),
+		cid, r.Header.Get("X-SAGE-DID"), rec.Header().Get("Server-Timing"))
+}
+
+func bucketIndex(d time.Duration) int {
+	ms := d.Milliseconds()
+	for i, b := range bucketBoundsMs {
+		if ms <= b {
+			return i
+		}
+	}
+	return len(bucketBoundsMs)
+}
+
+// Stats returns a snapshot of the tracker, routes sorted by path.
+func (t *Tracker) Stats() Stats {
+	st := Stats{Name: t.name, InFlight: t.inFlight.Load()}
+	t.routes.Range(func(k, v any) bool {
+		rs := v.(*routeStats)
+		b := make(map[string]int64, len(rs.buckets))
+		for i := range rs.buckets {
+			key := "le_inf"
+			if i < len(bucketBoundsMs) {
+				key = "le_" + strconv.FormatInt(bucketBoundsMs[i], 10)
+			}
+			b[key] = rs.buckets[i].Load()
+		}
+		st.Routes = append(st.Routes, RouteStats{
+			Route: k.(string), InFlight: rs.inFlight.Load(), Count: rs.count.Load(), Slow: rs.slow.Load(), SumMs: rs.sumMs.Load(), Buckets: b,
+		})
+		return true
+	})
+	sort.Slice(st.Routes, func(i, j int) bool { return st.Routes[i].Route < st.Routes[j].Route })
+	return st
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ---- registry ----
+
+var (
+	regMu sync.Mutex
+	reg   = map[string]*Tracker{}
+)
+
+func register(t *Tracker) {
+	regMu.Lock()
+	reg[t.name] = t
+	regMu.Unlock()
+}
+
+// Snapshot returns stats for every tracker in this process, sorted by name.
+func Snapshot() []Stats {
+	regMu.Lock()
+	ts := make([]*Tracker, 0, len(reg))
+	for _, t := range reg {
+		ts = append(ts, t)
+	}
+	regMu.Unlock()
+
+	out := make([]Stats, 0, len(ts))
+	for _, t := range ts {
+		out = append(out, t.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
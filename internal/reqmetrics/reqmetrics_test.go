@@ -0,0 +1,80 @@
+package reqmetrics
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A slow handler is counted in flight while it runs, lands in the right
+// bucket and logs one structured warning; a fast one logs nothing.
+func TestSlowRequest(t *testing.T) {
+	t.Setenv("SLOW_REQUEST_MS", "20")
+	t.Setenv("SLOW_REQUEST_MS_ROUTES", "/status=0,/process=5")
+	var logs bytes.Buffer
+	tr := New("test.slow", log.New(&logs, "", 0))
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := tr.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/payment/process" {
+			close(entered)
+			<-release
+			time.Sleep(10 * time.Millisecond)
+			w.Header().Set("Server-Timing", "llm;dur=8")
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/payment/process", nil)
+		req.Header.Set("X-SAGE-Context-ID", "c-1")
+		req.Header.Set("X-SAGE-DID", "did:sage:test:root")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	<-entered
+	if st := tr.Stats(); st.InFlight != 1 || len(st.Routes) != 1 || st.Routes[0].InFlight != 1 {
+		t.Fatalf("while running: %+v", st)
+	}
+	close(release)
+	<-done
+
+	// /status is exempt (0) and /fast is under the default threshold
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/status", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+	st := tr.Stats()
+	if st.InFlight != 0 || len(st.Routes) != 3 {
+		t.Fatalf("after: %+v", st)
+	}
+	slow := st.Routes[1] // sorted: /fast, /payment/process, /status
+	if slow.Route != "/payment/process" || slow.Count != 1 || slow.Slow != 1 || slow.InFlight != 0 || slow.SumMs < 10 {
+		t.Fatalf("slow route %+v", slow)
+	}
+	if st.Routes[0].Slow != 0 || st.Routes[2].Slow != 0 {
+		t.Fatalf("fast routes counted slow: %+v", st.Routes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log lines %q", lines)
+	}
+	for _, f := range []string{"[test.slow][slow]", "method=POST", "path=/payment/process", "status=202", "thresholdMs=5", `cid="c-1"`, `did="did:sage:test:root"`, `phases="llm;dur=8"`} {
+		if !strings.Contains(lines[0], f) {
+			t.Errorf("log lacks %s: %s", f, lines[0])
+		}
+	}
+}
+
+func TestBucketIndex(t *testing.T) {
+	for d, want := range map[time.Duration]int{0: 0, 50 * time.Millisecond: 0, 51 * time.Millisecond: 1, 10 * time.Second: 7, time.Minute: 8} {
+		if got := bucketIndex(d); got != want {
+			t.Errorf("bucketIndex(%s) = %d, want %d", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package selfid is the startup self-identification handshake shared by the
+// cmd binaries.
+//
+// Every component's GET /status answers with at least
+//
+//	{"name": "<component name>", "type": "<component type>", "version": "<build>"}
+//
+// where type is the component's role ("root", "payment", "medical",
+// "planning", or a role-prefixed variant such as "planning-debug"). Agents
+// reached through the gateway also answer /<role>/status. Before serving, a
+// binary calls CheckPort to find out who already holds its port and
+// CheckUpstreams to confirm each configured URL answers as the role it was
+// configured for (MEDICAL_URL must not reach the payment agent). With
+// STRICT_STARTUP=true a mismatch is fatal; otherwise it is logged as a hard
+// warning. Unreachable upstreams are only noted, since start order varies.
+package selfid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bodylimit"
+)
+
+// Version is the build version reported in /status (set with
+// -ldflags "-X github.com/sage-x-project/sage-multi-agent/internal/selfid.Version=…").
+var Version = "dev"
+
+// ErrMismatch marks an upstream that answered as a different component.
+var ErrMismatch = errors.New("component mismatch")
+
+// Identity is what a component reports about itself in /status.
+type Identity struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+
+	// Limits is the advertised request size limits (nil when not advertised).
+	Limits *bodylimit.Limits `json:"limits,omitempty"`
+
+	// RoutingHint describes what the agent handles, for Root's LLM router
+	// (nil when not advertised).
+	RoutingHint *RoutingHint `json:"routingHint,omitempty"`
+}
+
+// RoutingHint is an agent's self-description for intent routing: one or two
+// sentences and a few example utterances per language ("ko", "en").
+type RoutingHint struct {
+	Description string              `json:"description"`
+	Examples    map[string][]string `json:"examples,omitempty"`
+}
+
+// LoadRoutingHint returns the hint in the JSON file named by the setting env
+// var, or def when the setting is empty.
+func LoadRoutingHint(setting string, def RoutingHint) (*RoutingHint, error) {
+	path := strings.TrimSpace(os.Getenv(setting))
+	if path == "" {
+		return &def, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return &def, fmt.Errorf("%s: %w", setting, err)
+	}
+	var h RoutingHint
+	if err := json.Unmarshal(b, &h); err != nil || strings.TrimSpace(h.Description) == "" {
+		return &def, fmt.Errorf("%s: %s is not a routing hint with a description", setting, path)
+	}
+	return &h, nil
+}
+
+func (id Identity) String() string {
+	v := id.Version
+	if v == "" {
+		v = "unknown"
+	}
+	return fmt.Sprintf("name=%s type=%s version=%s", id.Name, id.Type, v)
+}
+
+// Is reports whether id identifies as role.
+func (id Identity) Is(role string) bool {
+	role = strings.ToLower(role)
+	t, n := strings.ToLower(id.Type), strings.ToLower(id.Name)
+	return t == role || n == role || strings.HasPrefix(t, role+"-")
+}
+
+// Strict reports whether mismatches should stop the binary (STRICT_STARTUP).
+func Strict() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STRICT_STARTUP"))) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+var client = &http.Client{Timeout: 2 * time.Second}
+
+// Probe reads base/status. A non-200 answer or a body without name/type is an error.
+func Probe(ctx context.Context, base string) (Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+"/status", nil)
+	if err != nil {
+		return Identity{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("GET %s: HTTP %d", req.URL, resp.StatusCode)
+	}
+	var id Identity
+	if err := json.NewDecoder(resp.Body).Decode(&id); err != nil || (id.Name == "" && id.Type == "") {
+		return Identity{}, fmt.Errorf("GET %s: no name/type in /status", req.URL)
+	}
+	return id, nil
+}
+
+// CheckPort reports who already answers on port (nil when it is free or
+// silent). Binding would fail anyway; this names the holder.
+func CheckPort(ctx context.Context, role string, port int) error {
+	if port <= 0 {
+		return nil
+	}
+	id, err := Probe(ctx, fmt.Sprintf("http://127.0.0.1:%d", port))
+	if err != nil {
+		return nil
+	}
+	if id.Is(role) {
+		return fmt.Errorf("another %s instance is already bound to :%d (%s)", role, port, id)
+	}
+	return fmt.Errorf("port :%d is held by a different component (%s), not %s", port, id, role)
+}
+
+// Upstream is one configured dependency: the env/flag that set it, the role
+// it must answer as, and its base URL.
+type Upstream struct {
+	Setting string // e.g. "MEDICAL_URL"
+	Role    string
+	URL     string
+}
+
+// CheckUpstreams probes each upstream and returns an ErrMismatch-wrapped error
+// naming both sides for the first one that answers as another component.
+// Unreachable upstreams are passed to logf and otherwise ignored.
+func CheckUpstreams(ctx context.Context, self string, ups []Upstream, logf func(string, ...any)) error {
+	var errs []error
+	for _, u := range ups {
+		if strings.TrimSpace(u.URL) == "" {
+			continue
+		}
+		id, err := Probe(ctx, u.URL)
+		if err != nil {
+			logf("[selfid] %s=%s not verified: %v", u.Setting, u.URL, err)
+			continue
+		}
+		if !id.Is(u.Role) {
+			errs = append(errs, fmt.Errorf("%w: %s expects %s at %s=%s, but it answers as %s",
+				ErrMismatch, self, u.Role, u.Setting, u.URL, id))
+			continue
+		}
+		logf("[selfid] %s=%s ok (%s)", u.Setting, u.URL, id)
+	}
+	return errors.Join(errs...)
+}
+
+// Run does both checks before serving. A mismatch (or an occupied port) is
+// fatal under STRICT_STARTUP and logged as a hard warning otherwise; it
+// returns the error for the caller to exit on.
+func Run(role string, port int, ups []Upstream, logf func(string, ...any)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := errors.Join(CheckPort(ctx, role, port), CheckUpstreams(ctx, role, ups, logf))
+	if err == nil {
+		return nil
+	}
+	if Strict() {
+		return fmt.Errorf("startup self-identification failed (STRICT_STARTUP): %w", err)
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		logf("[selfid][WARN] %s", line)
+	}
+	return nil
+}
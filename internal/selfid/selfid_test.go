@@ -0,0 +1,100 @@
+package selfid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func statusServer(t *testing.T, id Identity) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"name":%q,"type":%q,"version":%q}`, id.Name, id.Type, id.Version)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// MEDICAL_URL pointing at the payment agent stops Root under STRICT_STARTUP,
+// and the error names both sides; without it the mismatch is only logged.
+func TestMiswiredUpstream(t *testing.T) {
+	payment := statusServer(t, Identity{Name: "payment", Type: "payment", Version: "1.2.0"})
+	planning := statusServer(t, Identity{Name: "planning", Type: "planning-debug"})
+	ups := []Upstream{
+		{Setting: "MEDICAL_URL", Role: "medical", URL: payment.URL},
+		{Setting: "PLANNING_URL", Role: "planning", URL: planning.URL},
+		{Setting: "PAYMENT_URL", Role: "payment", URL: "http://127.0.0.1:1"},
+	}
+
+	t.Setenv("STRICT_STARTUP", "true")
+	var logs []string
+	logf := func(f string, a ...any) { logs = append(logs, fmt.Sprintf(f, a...)) }
+	err := Run("root", 0, ups, logf)
+	if !errors.Is(err, ErrMismatch) {
+		t.Fatalf("strict: %v", err)
+	}
+	for _, s := range []string{"STRICT_STARTUP", "root expects medical at MEDICAL_URL=" + payment.URL, "answers as name=payment type=payment version=1.2.0"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("error lacks %q: %v", s, err)
+		}
+	}
+	if strings.Contains(err.Error(), "PLANNING_URL") || strings.Contains(err.Error(), "PAYMENT_URL") {
+		t.Fatalf("matching or unreachable upstream reported as a mismatch: %v", err)
+	}
+	if joined := strings.Join(logs, "\n"); !strings.Contains(joined, "PAYMENT_URL=http://127.0.0.1:1 not verified") || !strings.Contains(joined, "PLANNING_URL="+planning.URL+" ok") {
+		t.Fatalf("logs:\n%s", joined)
+	}
+
+	t.Setenv("STRICT_STARTUP", "")
+	logs = nil
+	if err := Run("root", 0, ups, logf); err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if joined := strings.Join(logs, "\n"); !strings.Contains(joined, "[selfid][WARN] component mismatch: root expects medical") {
+		t.Fatalf("no hard warning:\n%s", joined)
+	}
+}
+
+func TestCheckPort(t *testing.T) {
+	srv := statusServer(t, Identity{Name: "root", Type: "root"})
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	ctx := context.Background()
+	if err := CheckPort(ctx, "root", port); err == nil || !strings.Contains(err.Error(), "another root instance") {
+		t.Fatalf("same role: %v", err)
+	}
+	if err := CheckPort(ctx, "payment", port); err == nil || !strings.Contains(err.Error(), "held by a different component (name=root type=root version=unknown), not payment") {
+		t.Fatalf("other role: %v", err)
+	}
+	if err := CheckPort(ctx, "root", 1); err != nil {
+		t.Fatalf("silent port: %v", err)
+	}
+}
+
+func TestIdentityIs(t *testing.T) {
+	cases := []struct {
+		id   Identity
+		role string
+		want bool
+	}{
+		{Identity{Type: "planning-debug"}, "planning", true},
+		{Identity{Name: "Payment"}, "payment", true},
+		{Identity{Type: "paymentx"}, "payment", false},
+		{Identity{Name: "root", Type: "root"}, "medical", false},
+	}
+	for _, tc := range cases {
+		if got := tc.id.Is(tc.role); got != tc.want {
+			t.Errorf("%v.Is(%q) = %v", tc.id, tc.role, got)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package statefile persists small state files (JSON mostly) so that a crash
+// mid-write can never leave a truncated file behind.
+//
+// For a state file P:
+//
+//	P        the payload: written to a temp file in the same directory,
+//	         fsynced, renamed over P, then the directory is fsynced
+//	P.sum    sidecar checksum "sha256:<hex>" of P, replaced the same way
+//	P.1..N   the previous N good copies (each with its own .sum), newest first
+//
+// Load validates P against P.sum (and the caller's Validate, e.g. that it
+// decodes). When P is corrupt it falls back to the most recent valid backup,
+// logs the recovery loudly, keeps the bad file as P.corrupt-<unix> for
+// inspection and restores P from the backup. Files written before this
+// package (no .sum) are accepted when they validate. Corruptions, recoveries
+// and unrecoverable loads are counted process-wide (Snapshot, WritePrometheus).
+package statefile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCorrupt is returned by Load when neither P nor any backup is valid.
+var ErrCorrupt = errors.New("statefile: corrupt and no valid backup")
+
+// DefaultBackups is the number of rotated copies kept when Options.Backups is 0.
+const DefaultBackups = 3
+
+// Options tunes one file. The zero value keeps DefaultBackups copies with
+// mode 0600 and logs to the standard logger.
+type Options struct {
+	Backups  int         // rotated copies (<0: none)
+	Perm     os.FileMode // payload mode (default 0600)
+	Logger   *log.Logger
+	Validate func([]byte) error // extra load-time check (LoadJSON: decodes)
+}
+
+func (o Options) backups() int {
+	switch {
+	case o.Backups < 0:
+		return 0
+	case o.Backups == 0:
+		return DefaultBackups
+	}
+	return o.Backups
+}
+
+func (o Options) perm() os.FileMode {
+	if o.Perm == 0 {
+		return 0o600
+	}
+	return o.Perm
+}
+
+func (o Options) logf(format string, args ...any) {
+	if o.Logger != nil {
+		o.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+var (
+	saves       atomic.Int64
+	saveErrors  atomic.Int64
+	corruptions atomic.Int64
+	recoveries  atomic.Int64
+	lost        atomic.Int64
+
+	lastMu    sync.Mutex
+	lastEvent string
+
+	// locks serializes Save/Load per path within the process
+	locks sync.Map // path -> *sync.Mutex
+)
+
+func lockFor(path string) *sync.Mutex {
+	v, _ := locks.LoadOrStore(filepath.Clean(path), &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+func note(format string, args ...any) {
+	lastMu.Lock()
+	lastEvent = time.Now().UTC().Format(time.RFC3339) + " " + fmt.Sprintf(format, args...)
+	lastMu.Unlock()
+}
+
+// Snapshot returns the process-wide counters for /status.
+func Snapshot() map[string]any {
+	lastMu.Lock()
+	last := lastEvent
+	lastMu.Unlock()
+	return map[string]any{
+		"saves":       saves.Load(),
+		"saveErrors":  saveErrors.Load(),
+		"corruptions": corruptions.Load(),
+		"recoveries":  recoveries.Load(),
+		"lost":        lost.Load(),
+		"lastEvent":   last,
+	}
+}
+
+// WritePrometheus appends the counters to a /metrics body.
+func WritePrometheus(w io.Writer) {
+	var b strings.Builder
+	for _, m := range []struct {
+		name, help string
+		v          int64
+	}{
+		{"sage_statefile_corruptions_total", "State files that failed validation on load.", corruptions.Load()},
+		{"sage_statefile_recoveries_total", "Corrupt state files restored from a backup.", recoveries.Load()},
+		{"sage_statefile_lost_total", "Corrupt state files with no valid backup.", lost.Load()},
+		{"sage_statefile_save_errors_total", "State file saves that failed.", saveErrors.Load()},
+	} {
+		b.WriteString("# HELP " + m.name + " " + m.help + "\n# TYPE " + m.name + " counter\n")
+		b.WriteString(m.name + " " + strconv.FormatInt(m.v, 10) + "\n")
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func backupPath(path string, i int) string { return path + "." + strconv.Itoa(i) }
+
+// writeAtomic writes b to path via temp file + fsync + rename + dir fsync.
+func writeAtomic(path string, b []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(name)
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(name)
+		return err
+	}
+	if err := os.Rename(name, path); err != nil {
+		os.Remove(name)
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// copyFile duplicates src to dst (used for backups).
+func copyFile(src, dst string, perm os.FileMode) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(dst, b, perm)
+}
+
+// rotate shifts P.k -> P.k+1 and copies the current P (if valid) to P.1.
+func rotate(path string, o Options) {
+	n := o.backups()
+	if n == 0 {
+		return
+	}
+	if _, err := readValid(path, o); err != nil {
+		return // never rotate a bad file over a good backup
+	}
+	for i := n - 1; i >= 1; i-- {
+		src, dst := backupPath(path, i), backupPath(path, i+1)
+		if _, err := os.Stat(src); err == nil {
+			_ = os.Rename(src, dst)
+			_ = os.Rename(src+".sum", dst+".sum")
+		}
+	}
+	one := backupPath(path, 1)
+	if err := copyFile(path, one, o.perm()); err != nil {
+		return
+	}
+	if err := copyFile(path+".sum", one+".sum", 0o600); err != nil {
+		_ = os.Remove(one + ".sum") // legacy P without .sum: backup validates on content
+	}
+}
+
+// Save writes data to path atomically, rotating the previous good copy.
+func Save(path string, data []byte, o Options) error {
+	mu := lockFor(path)
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		saveErrors.Add(1)
+		return err
+	}
+	rotate(path, o)
+	if err := writeAtomic(path, data, o.perm()); err != nil {
+		saveErrors.Add(1)
+		return err
+	}
+	if err := writeAtomic(path+".sum", []byte(checksum(data)+"\n"), 0o600); err != nil {
+		saveErrors.Add(1)
+		return err
+	}
+	saves.Add(1)
+	return nil
+}
+
+// SaveJSON is Save of v encoded as indented JSON.
+func SaveJSON(path string, v any, o Options) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return Save(path, append(b, '\n'), o)
+}
+
+// Remove deletes path with its checksum and backups.
+func Remove(path string) error {
+	mu := lockFor(path)
+	mu.Lock()
+	defer mu.Unlock()
+	err := os.Remove(path)
+	_ = os.Remove(path + ".sum")
+	for i := 1; ; i++ {
+		bp := backupPath(path, i)
+		if _, serr := os.Stat(bp); serr != nil {
+			break
+		}
+		_ = os.Remove(bp)
+		_ = os.Remove(bp + ".sum")
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// readValid returns path's content when it matches its checksum (if any)
+// and passes o.Validate.
+func readValid(path string, o Options) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if sum, err := os.ReadFile(path + ".sum"); err == nil {
+		if want := strings.TrimSpace(string(sum)); want != checksum(b) {
+			return nil, fmt.Errorf("checksum mismatch (want %s)", want)
+		}
+	}
+	if o.Validate != nil {
+		if err := o.Validate(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Load returns path's content, recovering from the newest valid backup when
+// path is corrupt. A missing path is reported as os.ErrNotExist (backups are
+// not consulted: Save never leaves path absent, so it was removed on purpose);
+// ErrCorrupt means neither path nor any backup is valid.
+func Load(path string, o Options) ([]byte, error) {
+	mu := lockFor(path)
+	mu.Lock()
+	defer mu.Unlock()
+	b, err := readValid(path, o)
+	if err == nil {
+		return b, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err // deleted on purpose (Remove) or never written
+	}
+	corruptions.Add(1)
+	o.logf("[statefile] ⚠️ %s is corrupt: %v", path, err)
+	for i := 1; i <= o.backups(); i++ {
+		bp := backupPath(path, i)
+		bb, berr := readValid(bp, o)
+		if berr != nil {
+			continue
+		}
+		keep := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		_ = os.Rename(path, keep)
+		_ = os.Remove(path + ".sum")
+		if werr := writeAtomic(path, bb, o.perm()); werr == nil {
+			_ = writeAtomic(path+".sum", []byte(checksum(bb)+"\n"), 0o600)
+		}
+		recoveries.Add(1)
+		note("recovered %s from %s", path, filepath.Base(bp))
+		o.logf("[statefile] ⚠️ RECOVERED %s from backup %s (%v; bad copy kept as %s); changes after that snapshot are lost",
+			path, filepath.Base(bp), err, filepath.Base(keep))
+		return bb, nil
+	}
+	lost.Add(1)
+	note("no valid copy of %s", path)
+	o.logf("[statefile] ⚠️ %s is corrupt and no valid backup exists; starting from empty state", path)
+	return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, path, err)
+}
+
+// LoadJSON loads path into v (validating that it decodes). It reports
+// found=false when there is no file or no valid copy of it; the error is
+// ErrCorrupt in the latter case and v is left untouched.
+func LoadJSON(path string, v any, o Options) (found bool, err error) {
+	o.Validate = chainValidate(o.Validate, func(b []byte) error {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		return nil
+	})
+	b, err := Load(path, o)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, nil
+}
+
+func chainValidate(a, b func([]byte) error) func([]byte) error {
+	if a == nil {
+		return b
+	}
+	return func(p []byte) error {
+		if err := b(p); err != nil {
+			return err
+		}
+		return a(p)
+	}
+}
@@ -0,0 +1,141 @@
+package statefile
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type state struct {
+	Version int               `json:"version"`
+	Pins    map[string]string `json:"pins"`
+}
+
+var quiet = Options{Logger: log.New(io.Discard, "", 0)}
+
+// saveTwo writes v1 then v2, so P holds v2 and P.1 holds v1.
+func saveTwo(t *testing.T) (string, []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pins.json")
+	if err := SaveJSON(path, state{Version: 1, Pins: map[string]string{"payment": "aa"}}, quiet); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveJSON(path, state{Version: 2, Pins: map[string]string{"payment": "bb", "medical": "cc"}}, quiet); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, b
+}
+
+// Whatever a crash or a bad disk leaves in P, Load answers a valid state and
+// never a partial one.
+func TestRecoverTruncatedAndFlipped(t *testing.T) {
+	_, good := saveTwo(t)
+	for cut := 0; cut < len(good); cut++ {
+		path, _ := saveTwo(t)
+		if err := os.WriteFile(path, good[:cut], 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var got state
+		found, err := LoadJSON(path, &got, quiet)
+		if err != nil || !found || got.Version != 1 {
+			t.Fatalf("truncated at %d: found=%v err=%v state=%+v", cut, found, err, got)
+		}
+	}
+	for i := 0; i < len(good); i++ {
+		path, _ := saveTwo(t)
+		bad := append([]byte(nil), good...)
+		bad[i] ^= 0x20
+		if err := os.WriteFile(path, bad, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var got state
+		if found, err := LoadJSON(path, &got, quiet); err != nil || !found || got.Version != 1 {
+			t.Fatalf("byte %d flipped: found=%v err=%v state=%+v", i, found, err, got)
+		}
+	}
+}
+
+func TestRecoveryRestoresAndKeepsBadCopy(t *testing.T) {
+	path, good := saveTwo(t)
+	if err := os.WriteFile(path, good[:10], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before := Snapshot()["recoveries"].(int64)
+	if _, err := Load(path, quiet); err != nil {
+		t.Fatal(err)
+	}
+	if Snapshot()["recoveries"].(int64) != before+1 {
+		t.Fatal("recovery not counted")
+	}
+	// P was restored from the backup and verifies on its own again
+	if _, err := readValid(path, quiet); err != nil {
+		t.Fatalf("restored file: %v", err)
+	}
+	if m, _ := filepath.Glob(path + ".corrupt-*"); len(m) != 1 {
+		t.Fatalf("bad copies kept: %v", m)
+	}
+}
+
+func TestNoValidCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := SaveJSON(path, state{Version: 1}, quiet); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"vers`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var got state
+	found, err := LoadJSON(path, &got, quiet)
+	if found || !errors.Is(err, ErrCorrupt) || got.Version != 0 {
+		t.Fatalf("found=%v err=%v state=%+v", found, err, got)
+	}
+}
+
+func TestMissingAndLegacy(t *testing.T) {
+	dir := t.TempDir()
+	var got state
+	if found, err := LoadJSON(filepath.Join(dir, "none.json"), &got, quiet); found || err != nil {
+		t.Fatalf("missing file: found=%v err=%v", found, err)
+	}
+	// written before checksums: accepted when it decodes
+	legacy := filepath.Join(dir, "legacy.json")
+	if err := os.WriteFile(legacy, []byte(`{"version":7}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := LoadJSON(legacy, &got, quiet); !found || err != nil || got.Version != 7 {
+		t.Fatalf("legacy file: found=%v err=%v state=%+v", found, err, got)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.json")
+	o := quiet
+	o.Backups = 2
+	for v := 1; v <= 4; v++ {
+		if err := SaveJSON(path, state{Version: v}, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range map[int]int{1: 3, 2: 2} {
+		var got state
+		if found, err := LoadJSON(backupPath(path, i), &got, o); !found || err != nil || got.Version != want {
+			t.Errorf("backup %d: version %d (%v), want %d", i, got.Version, err, want)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("kept a third backup: %v", err)
+	}
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := filepath.Glob(path + "*"); len(m) != 0 {
+		t.Errorf("Remove left %v", m)
+	}
+}
@@ -0,0 +1,205 @@
+// Package troubleshoot runs an ordered battery of configuration checks and
+// reports, per check, what was found and what to do about it.
+//
+// A Check returns a Result with a Status, the Evidence it saw (paths, URLs,
+// DIDs, answers) and, unless it passed, a Hint naming the setting to change or
+// the command to run. Run executes the checks in order with a per-check
+// timeout; a check that panics is reported as failed instead of taking the
+// caller down. The Report's verdict is "ready" when every check passed or was
+// skipped, "degraded" when only warnings remain and "not_ready" otherwise.
+//
+// Checks may depend on earlier ones (a DID lookup needs the key): they share
+// state through the closures that build them and return Skip when a
+// prerequisite failed, so the report points at the first real problem.
+package troubleshoot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/selfid"
+)
+
+// Check outcomes.
+const (
+	OK   = "ok"
+	Warn = "warn"
+	Fail = "fail"
+	Skip = "skip"
+)
+
+// Verdicts.
+const (
+	Ready    = "ready"
+	Degraded = "degraded"
+	NotReady = "not_ready"
+)
+
+// Result is one check's outcome.
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Evidence string `json:"evidence,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+	Ms       int64  `json:"ms"`
+}
+
+// Check is one step of the battery.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// Report is the outcome of a battery.
+type Report struct {
+	Verdict string    `json:"verdict"`
+	Ready   bool      `json:"ready"`
+	Checks  []Result  `json:"checks"`
+	At      time.Time `json:"at"`
+}
+
+// Run executes checks in order, each bounded by timeout.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	rep := Report{Checks: make([]Result, 0, len(checks)), At: time.Now()}
+	for _, c := range checks {
+		rep.Checks = append(rep.Checks, runOne(ctx, c, timeout))
+	}
+	rep.Verdict = Ready
+	for _, res := range rep.Checks {
+		switch res.Status {
+		case Fail:
+			rep.Verdict = NotReady
+		case Warn:
+			if rep.Verdict == Ready {
+				rep.Verdict = Degraded
+			}
+		}
+	}
+	rep.Ready = rep.Verdict != NotReady
+	return rep
+}
+
+func runOne(ctx context.Context, c Check, timeout time.Duration) (res Result) {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			res = Result{Status: Fail, Evidence: fmt.Sprintf("check panicked: %v", p)}
+		}
+		res.Name = c.Name
+		res.Ms = time.Since(start).Milliseconds()
+	}()
+	return c.Run(cctx)
+}
+
+// WriteTable prints the report as a table followed by the hints.
+func (rep Report) WriteTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tCHECK\tSTATUS\tEVIDENCE")
+	for i, res := range rep.Checks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", i+1, res.Name, strings.ToUpper(res.Status), oneLine(res.Evidence, 100))
+	}
+	_ = tw.Flush()
+	hints := 0
+	for i, res := range rep.Checks {
+		if res.Hint == "" {
+			continue
+		}
+		if hints == 0 {
+			fmt.Fprintln(w, "\nWhat to do:")
+		}
+		hints++
+		fmt.Fprintf(w, "  %d. [%s] %s\n", i+1, res.Name, res.Hint)
+	}
+	fmt.Fprintf(w, "\nVerdict: %s\n", strings.ToUpper(rep.Verdict))
+}
+
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		return s[:max-3] + "..."
+	}
+	return s
+}
+
+// Probe is what a component's /status revealed.
+type Probe struct {
+	Identity selfid.Identity
+	Date     time.Time // the answer's Date header (zero when absent)
+	Latency  time.Duration
+}
+
+// ProbeStatus reads base/status like selfid.Probe and also keeps the Date
+// header, for clock comparisons.
+func ProbeStatus(ctx context.Context, client *http.Client, base string) (Probe, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+"/status", nil)
+	if err != nil {
+		return Probe{}, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Probe{}, err
+	}
+	defer resp.Body.Close()
+	p := Probe{Latency: time.Since(start)}
+	if d, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		p.Date = d
+	}
+	if resp.StatusCode != http.StatusOK {
+		return p, fmt.Errorf("GET %s: HTTP %d", req.URL, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&p.Identity); err != nil || (p.Identity.Name == "" && p.Identity.Type == "") {
+		return p, fmt.Errorf("GET %s: no name/type in /status", req.URL)
+	}
+	return p, nil
+}
+
+// Skew is how far the remote clock is ahead of the local one (negative =
+// behind), comparing the Date header with the midpoint of the exchange at
+// Date's one-second resolution; false when the answer had no Date.
+func (p Probe) Skew(receivedAt time.Time) (time.Duration, bool) {
+	if p.Date.IsZero() {
+		return 0, false
+	}
+	mid := receivedAt.Add(-p.Latency / 2)
+	return p.Date.Sub(mid.Truncate(time.Second)), true
+}
+
+// RPCChainID asks an Ethereum JSON-RPC endpoint for eth_chainId.
+func RPCChainID(ctx context.Context, client *http.Client, url string) (string, error) {
+	body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var out struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("not a JSON-RPC answer: %w", err)
+	}
+	if out.Error != nil {
+		return "", fmt.Errorf("rpc error: %s", out.Error.Message)
+	}
+	return out.Result, nil
+}
@@ -0,0 +1,105 @@
+package troubleshoot
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func result(status string) func(context.Context) Result {
+	return func(context.Context) Result { return Result{Status: status} }
+}
+
+func TestRunVerdict(t *testing.T) {
+	cases := []struct {
+		statuses []string
+		want     string
+	}{
+		{[]string{OK, Skip}, Ready},
+		{[]string{OK, Warn}, Degraded},
+		{[]string{Warn, Fail, OK}, NotReady},
+	}
+	for _, c := range cases {
+		var checks []Check
+		for _, s := range c.statuses {
+			checks = append(checks, Check{Name: s, Run: result(s)})
+		}
+		rep := Run(context.Background(), checks, time.Second)
+		if rep.Verdict != c.want || rep.Ready != (c.want != NotReady) {
+			t.Errorf("%v: verdict=%s ready=%v, want %s", c.statuses, rep.Verdict, rep.Ready, c.want)
+		}
+	}
+}
+
+// A panicking check fails on its own; the battery goes on.
+func TestRunPanic(t *testing.T) {
+	rep := Run(context.Background(), []Check{
+		{Name: "boom", Run: func(context.Context) Result { panic("nil map") }},
+		{Name: "after", Run: result(OK)},
+	}, time.Second)
+	if len(rep.Checks) != 2 || rep.Checks[0].Name != "boom" || rep.Checks[0].Status != Fail || !strings.Contains(rep.Checks[0].Evidence, "nil map") {
+		t.Fatalf("checks = %+v", rep.Checks)
+	}
+	if rep.Checks[1].Status != OK || rep.Verdict != NotReady {
+		t.Fatalf("after the panic: %+v", rep)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	rep := Run(context.Background(), []Check{{Name: "slow", Run: func(ctx context.Context) Result {
+		<-ctx.Done()
+		return Result{Status: Fail, Evidence: ctx.Err().Error()}
+	}}}, 20*time.Millisecond)
+	if rep.Checks[0].Evidence != context.DeadlineExceeded.Error() {
+		t.Fatalf("slow check: %+v", rep.Checks[0])
+	}
+}
+
+func TestWriteTableHints(t *testing.T) {
+	rep := Run(context.Background(), []Check{
+		{Name: "key", Run: result(OK)},
+		{Name: "rpc", Run: func(context.Context) Result { return Result{Status: Fail, Hint: "start the chain"} }},
+	}, time.Second)
+	var b bytes.Buffer
+	rep.WriteTable(&b)
+	if out := b.String(); !strings.Contains(out, "2. [rpc] start the chain") || !strings.Contains(out, "Verdict: NOT_READY") {
+		t.Fatalf("table:\n%s", out)
+	}
+}
+
+func TestProbeStatusAndSkew(t *testing.T) {
+	ahead := time.Now().Add(2 * time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", ahead.UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/status":
+			_, _ = w.Write([]byte(`{"name":"payment","type":"payment"}`))
+		case "/html/status":
+			_, _ = w.Write([]byte(`<html></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := ProbeStatus(context.Background(), srv.Client(), srv.URL)
+	if err != nil || p.Identity.Name != "payment" {
+		t.Fatalf("probe: %+v %v", p, err)
+	}
+	if d, ok := p.Skew(time.Now()); !ok || d < 110*time.Second || d > 130*time.Second {
+		t.Fatalf("skew = %v %v, want about 2m", d, ok)
+	}
+	if _, err := ProbeStatus(context.Background(), srv.Client(), srv.URL+"/wrong"); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Fatalf("wrong prefix: %v", err)
+	}
+	if _, err := ProbeStatus(context.Background(), srv.Client(), srv.URL+"/html"); err == nil {
+		t.Fatal("a page that is not /status JSON passed")
+	}
+	if _, ok := (Probe{}).Skew(time.Now()); ok {
+		t.Fatal("skew without a Date header")
+	}
+}
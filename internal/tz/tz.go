@@ -0,0 +1,289 @@
+// Package tz resolves a conversation's time zone and renders times in it.
+//
+// A conversation's zone is an IANA name ("Asia/Seoul") set by the client in
+// the X-SAGE-Timezone header or metadata "timezone"; DEFAULT_TIMEZONE sets
+// the deployment default (UTC when unset). The server's own zone is never
+// used: "Local" is rejected like any unknown name, and callers fall back to
+// the default with a warning. The zone database is embedded (time/tzdata), so
+// resolution does not depend on the host's /usr/share/zoneinfo.
+//
+// Stored and exchanged timestamps stay UTC; Local, Display and Dual render
+// one for a reader in the conversation's zone. At and ParseWhen turn a wall
+// clock time ("tomorrow 9am") into an instant and are correct across DST
+// transitions: a time that falls in a spring-forward gap moves forward by the
+// gap, and one that occurs twice in a fall-back overlap takes the first.
+package tz
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Header carries the client's time zone on /process requests and the
+// effective zone on the response.
+const Header = "X-SAGE-Timezone"
+
+// WarningHeader explains why a requested zone was not used.
+const WarningHeader = "X-SAGE-Timezone-Warning"
+
+// MetaKey is the metadata key carrying the zone between Root and the agents.
+const MetaKey = "timezone"
+
+// EnvDefault names the deployment's default zone.
+const EnvDefault = "DEFAULT_TIMEZONE"
+
+// ErrUnknown reports a name that is not a usable IANA zone.
+var ErrUnknown = errors.New("unknown time zone")
+
+// Default is the deployment's zone: DEFAULT_TIMEZONE when it names a valid
+// zone, UTC otherwise.
+func Default() *time.Location {
+	if loc, err := load(os.Getenv(EnvDefault)); err == nil && loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Resolve returns the zone named by name. An empty name is the default. An
+// unknown name returns the default together with an error wrapping
+// ErrUnknown, so a caller can warn and go on.
+func Resolve(name string) (*time.Location, error) {
+	loc, err := load(name)
+	if err != nil {
+		return Default(), err
+	}
+	if loc == nil {
+		return Default(), nil
+	}
+	return loc, nil
+}
+
+// load parses name; (nil, nil) for an empty name.
+func load(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(name, "Local") {
+		return nil, fmt.Errorf("%w: %q (server-local time is not a conversation zone)", ErrUnknown, name)
+	}
+	if strings.EqualFold(name, "UTC") || strings.EqualFold(name, "Z") {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknown, name)
+	}
+	return loc, nil
+}
+
+// Local is t in loc as RFC3339, with loc's offset.
+func Local(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// Display is t in loc for people: "2026-10-16 09:00 KST".
+func Display(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02 15:04 MST")
+}
+
+// Dual is Display followed by the UTC instant: "2026-10-16 09:00 KST (2026-10-16T00:00:00Z)".
+func Dual(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return Display(t, loc) + " (" + t.UTC().Format(time.RFC3339) + ")"
+}
+
+// Pair is one instant in UTC and in the conversation's zone, for JSON views.
+type Pair struct {
+	UTC      string `json:"utc"`
+	Local    string `json:"local"`
+	Timezone string `json:"timezone"`
+}
+
+// Both renders t as a Pair.
+func Both(t time.Time, loc *time.Location) Pair {
+	return Pair{UTC: t.UTC().Format(time.RFC3339), Local: Local(t, loc), Timezone: loc.String()}
+}
+
+// At is the instant at which clocks in loc show the given date and time.
+// Day and hour overflow normalise as in time.Date. In a spring-forward gap
+// the offset in force before the transition is used, so 02:30 on a day that
+// skips 02:00-03:00 is 03:30; in a fall-back overlap the earlier instant is
+// returned.
+func At(loc *time.Location, year int, month time.Month, day, hour, min int) time.Time {
+	wall := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	var best time.Time
+	for _, off := range offsetsNear(wall, loc) {
+		t := wall.Add(-time.Duration(off) * time.Second)
+		if sameWall(t.In(loc), wall) && (best.IsZero() || t.Before(best)) {
+			best = t
+		}
+	}
+	if !best.IsZero() {
+		return best.In(loc)
+	}
+	_, before := wall.Add(-48 * time.Hour).In(loc).Zone()
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+// offsetsNear lists loc's UTC offsets within two days of wall.
+func offsetsNear(wall time.Time, loc *time.Location) []int {
+	var offs []int
+	for _, d := range []time.Duration{-48 * time.Hour, 0, 48 * time.Hour} {
+		_, off := wall.Add(d).In(loc).Zone()
+		dup := false
+		for _, o := range offs {
+			dup = dup || o == off
+		}
+		if !dup {
+			offs = append(offs, off)
+		}
+	}
+	return offs
+}
+
+func sameWall(t, wall time.Time) bool {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := wall.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 && t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
+
+var (
+	dayWords = []struct {
+		re   *regexp.Regexp
+		days int
+	}{
+		{regexp.MustCompile(`(?i)\bday after tomorrow\b|모레`), 2},
+		{regexp.MustCompile(`글피`), 3},
+		{regexp.MustCompile(`(?i)\btomorrow\b|내일`), 1},
+		{regexp.MustCompile(`(?i)\btoday\b|\btonight\b|오늘`), 0},
+	}
+	clockEnRe = regexp.MustCompile(`(?i)\b(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)|\b(\d{1,2}):(\d{2})\b`)
+	clockKoRe = regexp.MustCompile(`(오전|오후|아침|저녁|밤)?\s*(\d{1,2})\s*시(?:\s*(\d{1,2})\s*분|\s*(반))?`)
+	inEnRe    = regexp.MustCompile(`(?i)\bin\s+(\d{1,4})\s*(minutes?|mins?|hours?|hrs?)\b`)
+	inKoRe    = regexp.MustCompile(`(\d{1,4})\s*(분|시간)\s*(?:후|뒤)`)
+)
+
+// ParseWhen reads a reminder time relative to now, in loc: an RFC3339
+// instant, a local "2006-01-02 15:04", "in 30 minutes" / "30분 후", or a
+// clock time with an optional day word ("tomorrow 9am", "today 18:30",
+// "내일 오전 9시", "모레 오후 3시 반"). A clock time without a day word is
+// today when still ahead, tomorrow otherwise.
+func ParseWhen(text string, now time.Time, loc *time.Location) (time.Time, bool) {
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if w, err := time.Parse(layout, s); err == nil {
+			return At(loc, w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute()), true
+		}
+	}
+	if m := inEnRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := time.Minute
+		if strings.HasPrefix(strings.ToLower(m[2]), "h") {
+			unit = time.Hour
+		}
+		return now.Add(time.Duration(n) * unit), true
+	}
+	if m := inKoRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := time.Minute
+		if m[2] == "시간" {
+			unit = time.Hour
+		}
+		return now.Add(time.Duration(n) * unit), true
+	}
+
+	hour, min, ok := clockOf(s)
+	if !ok {
+		return time.Time{}, false
+	}
+	days, dayGiven := dayOffset(s)
+	local := now.In(loc)
+	y, mo, d := local.Date()
+	t := At(loc, y, mo, d+days, hour, min)
+	if !dayGiven && !t.After(now) {
+		t = At(loc, y, mo, d+1, hour, min)
+	}
+	return t, true
+}
+
+// clockOf finds a clock time in s.
+func clockOf(s string) (hour, min int, ok bool) {
+	if m := clockKoRe.FindStringSubmatch(s); m != nil {
+		hour, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			min, _ = strconv.Atoi(m[3])
+		} else if m[4] != "" {
+			min = 30
+		}
+		switch m[1] {
+		case "오후", "저녁", "밤":
+			if hour < 12 {
+				hour += 12
+			}
+		case "오전", "아침":
+			if hour == 12 {
+				hour = 0
+			}
+		}
+		return hour, min, hour < 24 && min < 60
+	}
+	if m := clockEnRe.FindStringSubmatch(s); m != nil {
+		if m[4] != "" {
+			hour, _ = strconv.Atoi(m[4])
+			min, _ = strconv.Atoi(m[5])
+			return hour, min, hour < 24 && min < 60
+		}
+		hour, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			min, _ = strconv.Atoi(m[2])
+		}
+		if hour < 1 || hour > 12 || min > 59 {
+			return 0, 0, false
+		}
+		pm := strings.HasPrefix(strings.ToLower(m[3]), "p")
+		switch {
+		case pm && hour < 12:
+			hour += 12
+		case !pm && hour == 12:
+			hour = 0
+		}
+		return hour, min, true
+	}
+	return 0, 0, false
+}
+
+// dayOffset finds a relative day word in s.
+func dayOffset(s string) (int, bool) {
+	for _, w := range dayWords {
+		if w.re.MatchString(s) {
+			return w.days, true
+		}
+	}
+	return 0, false
+}
+
+// RelativeDate resolves a relative day word in text ("tomorrow", "내일",
+// "day after tomorrow") to a calendar date in loc, as "2006-01-02".
+func RelativeDate(text string, now time.Time, loc *time.Location) (string, bool) {
+	days, ok := dayOffset(text)
+	if !ok {
+		return "", false
+	}
+	y, mo, d := now.In(loc).Date()
+	return time.Date(y, mo, d+days, 12, 0, 0, 0, time.UTC).Format("2006-01-02"), true
+}
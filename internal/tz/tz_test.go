@@ -0,0 +1,118 @@
+package tz
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func zone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// An unusable name falls back to the default with ErrUnknown; "Local" is
+// never the server's zone.
+func TestResolve(t *testing.T) {
+	t.Setenv(EnvDefault, "Asia/Seoul")
+	for _, tc := range []struct {
+		name, want string
+		unknown    bool
+	}{
+		{"", "Asia/Seoul", false},
+		{"America/New_York", "America/New_York", false},
+		{"utc", "UTC", false},
+		{"Local", "Asia/Seoul", true},
+		{"Mars/Olympus_Mons", "Asia/Seoul", true},
+	} {
+		loc, err := Resolve(tc.name)
+		if loc.String() != tc.want || errors.Is(err, ErrUnknown) != tc.unknown {
+			t.Errorf("Resolve(%q) = %s, %v", tc.name, loc, err)
+		}
+	}
+	t.Setenv(EnvDefault, "Nowhere/Invalid")
+	if Default() != time.UTC {
+		t.Fatalf("invalid default: %s", Default())
+	}
+}
+
+// Wall clock times in a DST gap move forward by the gap; in an overlap the
+// first instant wins.
+func TestAtDST(t *testing.T) {
+	ny := zone(t, "America/New_York")
+	for _, tc := range []struct {
+		name string
+		got  time.Time
+		want string
+	}{
+		{"ordinary", At(ny, 2026, time.March, 7, 9, 0), "2026-03-07T14:00:00Z"},
+		{"spring gap", At(ny, 2026, time.March, 8, 2, 30), "2026-03-08T07:30:00Z"},
+		{"after spring", At(ny, 2026, time.March, 8, 9, 0), "2026-03-08T13:00:00Z"},
+		{"fall overlap", At(ny, 2026, time.November, 1, 1, 30), "2026-11-01T05:30:00Z"},
+		{"overflow", At(ny, 2026, time.March, 31, 24, 0), "2026-04-01T04:00:00Z"},
+	} {
+		if got := tc.got.UTC().Format(time.RFC3339); got != tc.want {
+			t.Errorf("%s: %s, want %s", tc.name, got, tc.want)
+		}
+	}
+	if got := At(ny, 2026, time.March, 8, 2, 30).Format("15:04 MST"); got != "03:30 EDT" {
+		t.Errorf("gap wall clock %s", got)
+	}
+}
+
+// "tomorrow 9am" set the evening before a DST change is 9:00 on the new
+// offset, not 24h after 9:00 on the old one.
+func TestParseWhenAcrossDST(t *testing.T) {
+	ny := zone(t, "America/New_York")
+	seoul := zone(t, "Asia/Seoul")
+	now := time.Date(2026, time.March, 7, 23, 0, 0, 0, ny) // Saturday 23:00 EST
+	for _, tc := range []struct {
+		text string
+		loc  *time.Location
+		want string
+	}{
+		{"tomorrow 9am", ny, "2026-03-08T09:00:00-04:00"},
+		{"today 18:30", ny, "2026-03-07T18:30:00-05:00"},
+		{"8am", ny, "2026-03-08T08:00:00-04:00"}, // already past today
+		{"in 90 minutes", ny, "2026-03-08T00:30:00-05:00"},
+		{"2026-03-08 02:15", ny, "2026-03-08T03:15:00-04:00"},
+		{"내일 오전 9시", seoul, "2026-03-09T09:00:00+09:00"},
+		{"모레 오후 3시 반", seoul, "2026-03-10T15:30:00+09:00"},
+		{"30분 후", seoul, "2026-03-08T13:30:00+09:00"},
+	} {
+		got, ok := ParseWhen(tc.text, now, tc.loc)
+		if !ok || got.In(tc.loc).Format(time.RFC3339) != tc.want {
+			t.Errorf("ParseWhen(%q) = %s, %v; want %s", tc.text, got.In(tc.loc).Format(time.RFC3339), ok, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "someday", "13pm", "25시"} {
+		if got, ok := ParseWhen(bad, now, ny); ok {
+			t.Errorf("ParseWhen(%q) = %s", bad, got)
+		}
+	}
+}
+
+// The same instant is a different calendar day in different zones.
+func TestRenderInZone(t *testing.T) {
+	at := time.Date(2026, time.October, 15, 16, 0, 0, 0, time.UTC)
+	seoul := zone(t, "Asia/Seoul")
+	if got := Dual(at, seoul); got != "2026-10-16 01:00 KST (2026-10-15T16:00:00Z)" {
+		t.Errorf("Dual = %q", got)
+	}
+	if got := Dual(at, time.UTC); got != "2026-10-15T16:00:00Z" {
+		t.Errorf("Dual UTC = %q", got)
+	}
+	if p := Both(at, seoul); p.Local != "2026-10-16T01:00:00+09:00" || p.Timezone != "Asia/Seoul" {
+		t.Errorf("Both = %+v", p)
+	}
+	if d, _ := RelativeDate("tomorrow", at, seoul); d != "2026-10-17" {
+		t.Errorf("RelativeDate Seoul = %s", d)
+	}
+	if d, _ := RelativeDate("내일", at, time.UTC); d != "2026-10-16" {
+		t.Errorf("RelativeDate UTC = %s", d)
+	}
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// ErrWrongLang is returned by ChatLang when neither the nudge nor the
+// translation pass produced the requested language; callers fall back to
+// their template text.
+var ErrWrongLang = errors.New("llm: output language mismatch")
+
+type langNoteKey struct{}
+
+// WithLangNote returns a context that records whether ChatLang had to
+// intervene on any call made with it (see LangCorrected).
+func WithLangNote(ctx context.Context) context.Context {
+	return context.WithValue(ctx, langNoteKey{}, new(atomic.Bool))
+}
+
+// LangCorrected reports whether a ChatLang call under ctx re-asked, translated
+// or gave up on an answer because of its language.
+func LangCorrected(ctx context.Context) bool {
+	n, _ := ctx.Value(langNoteKey{}).(*atomic.Bool)
+	return n != nil && n.Load()
+}
+
+func noteLangCorrected(ctx context.Context) {
+	if n, _ := ctx.Value(langNoteKey{}).(*atomic.Bool); n != nil {
+		n.Store(true)
+	}
+}
+
+// LangMismatch reports whether out is clearly not in want ("ko" | "en").
+// Korean answers routinely carry English product names and English answers
+// carry Korean names, so: "ko" only fails when there is no Hangul at all in a
+// non-trivial answer, "en" only fails when Hangul outweighs Latin letters.
+func LangMismatch(want, out string) bool {
+	hangul, latin := 0, 0
+	for _, r := range out {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+	switch want {
+	case "ko":
+		return hangul == 0 && latin >= 6
+	case "en":
+		return hangul > latin
+	}
+	return false
+}
+
+var langNudge = map[string]string{
+	"ko": "\n\n중요: 반드시 한국어로만 답해. Answer in Korean only.",
+	"en": "\n\nIMPORTANT: answer in English only. 한국어를 쓰지 마.",
+}
+
+var langTranslate = map[string]string{
+	"ko": "다음 글을 자연스러운 한국어로 번역해. 형식(줄 수, 숫자, 고유명사)은 그대로 두고 번역문만 출력해.",
+	"en": "Translate the following text into natural English. Keep the format (line count, numbers, proper nouns) and output the translation only.",
+}
+
+// ChatLang is Chat for text shown to users in language want. When the answer
+// comes back in the other language it re-asks once with an explicit nudge,
+// then tries a translation pass, and returns ErrWrongLang if both fail. Pass
+// want "" (or call Chat directly) for intentionally bilingual content.
+// Untagged calls are pinned as PurposeReply.
+func ChatLang(ctx context.Context, c Client, want, system, user string) (string, error) {
+	if ctx.Value(purposeKey{}) == nil {
+		ctx = WithPurpose(ctx, PurposeReply)
+	}
+	out, err := c.Chat(ctx, system, user)
+	if err != nil || want == "" || strings.TrimSpace(out) == "" || !LangMismatch(want, out) {
+		return out, err
+	}
+	noteLangCorrected(ctx)
+
+	if again, err := c.Chat(ctx, system+langNudge[want], user); err == nil && strings.TrimSpace(again) != "" && !LangMismatch(want, again) {
+		return again, nil
+	}
+	if tr, err := c.Chat(ctx, langTranslate[want], strings.TrimSpace(out)); err == nil && strings.TrimSpace(tr) != "" && !LangMismatch(want, tr) {
+		return tr, nil
+	}
+	return "", ErrWrongLang
+}
+
+// wantLang maps a caller's lang to the enforced language ("ko" or "en").
+func wantLang(lang string) string {
+	if strings.EqualFold(strings.TrimSpace(lang), "ko") {
+		return "ko"
+	}
+	return "en"
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// scripted answers each Chat call with the next reply and records the system
+// prompts it was given.
+type scripted struct {
+	replies []string
+	systems []string
+}
+
+func (s *scripted) Chat(_ context.Context, system, _ string) (string, error) {
+	s.systems = append(s.systems, system)
+	if len(s.systems) > len(s.replies) {
+		return "", errors.New("no more replies")
+	}
+	return s.replies[len(s.systems)-1], nil
+}
+
+func TestChatLang(t *testing.T) {
+	cases := []struct {
+		name      string
+		want      string
+		replies   []string
+		out       string
+		err       error
+		calls     int
+		corrected bool
+	}{
+		{"first answer fits", "ko", []string{"결제가 완료되었습니다."}, "결제가 완료되었습니다.", nil, 1, false},
+		{"nudge succeeds", "ko", []string{"Your payment is complete.", "결제가 완료되었습니다."}, "결제가 완료되었습니다.", nil, 2, true},
+		{"translation succeeds", "en", []string{"결제가 완료되었습니다.", "결제 완료!", "Your payment is complete."}, "Your payment is complete.", nil, 3, true},
+		{"both passes fail", "en", []string{"결제가 완료되었습니다.", "결제 완료!", "결제 완료"}, "", ErrWrongLang, 3, true},
+		{"no enforcement", "", []string{"결제가 완료되었습니다."}, "결제가 완료되었습니다.", nil, 1, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &scripted{replies: tc.replies}
+			ctx := WithLangNote(context.Background())
+			out, err := ChatLang(ctx, c, tc.want, "system", "user")
+			if out != tc.out || !errors.Is(err, tc.err) || len(c.systems) != tc.calls {
+				t.Fatalf("got %q %v after %d calls", out, err, len(c.systems))
+			}
+			if LangCorrected(ctx) != tc.corrected {
+				t.Fatalf("corrected=%v", LangCorrected(ctx))
+			}
+			if tc.calls >= 2 && !strings.HasSuffix(c.systems[1], langNudge[tc.want]) {
+				t.Fatalf("second call without the nudge: %q", c.systems[1])
+			}
+			if tc.calls == 3 && c.systems[2] != langTranslate[tc.want] {
+				t.Fatalf("third call is not the translation pass: %q", c.systems[2])
+			}
+		})
+	}
+}
+
+// Mixed text is the norm: product names in the other script never trip the check.
+func TestLangMismatch(t *testing.T) {
+	cases := []struct {
+		want, out string
+		mismatch  bool
+	}{
+		{"ko", "MacBook Pro를 카드로 결제할게요", false},
+		{"ko", "OK", false},
+		{"ko", "Your MacBook is on its way", true},
+		{"en", "Sending 김철수 the MacBook now", false},
+		{"en", "배송지: Seoul", false},
+		{"en", "맥북을 김철수님께 보냈어요 OK", true},
+		{"", "아무 말", false},
+	}
+	for _, tc := range cases {
+		if got := LangMismatch(tc.want, tc.out); got != tc.mismatch {
+			t.Errorf("LangMismatch(%q, %q) = %v", tc.want, tc.out, got)
+		}
+	}
+}
+
+// Without WithLangNote there is nothing to record, and that is not an error.
+func TestLangCorrectedWithoutNote(t *testing.T) {
+	c := &scripted{replies: []string{"Your payment is complete.", "결제 완료"}}
+	if out, err := ChatLang(context.Background(), c, "ko", "s", "u"); err != nil || out != "결제 완료" {
+		t.Fatalf("%q %v", out, err)
+	}
+	if LangCorrected(context.Background()) {
+		t.Fatal("corrected without a note")
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"strings"
 	"time"
 	"unicode"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/money"
 )
 
 var ErrLLMDisabled = errors.New("llm client disabled (missing key or base url)")
@@ -31,6 +33,9 @@ type OpenAIClient struct {
 	APIKey  string
 	Model   string
 	HTTP    *http.Client
+
+	// Ollama: ask /api/tags for the model digest (modelpin.go)
+	Ollama bool
 }
 
 type chatReq struct {
@@ -47,9 +52,11 @@ type chatMessage struct {
 }
 
 type chatResp struct {
-	ID      string       `json:"id"`
-	Object  string       `json:"object"`
-	Choices []chatChoice `json:"choices"`
+	ID                string       `json:"id"`
+	Object            string       `json:"object"`
+	Model             string       `json:"model"`
+	SystemFingerprint string       `json:"system_fingerprint,omitempty"`
+	Choices           []chatChoice `json:"choices"`
 	Error   *struct {
 		Message string      `json:"message"`
 		Type    string      `json:"type,omitempty"`
@@ -80,7 +87,9 @@ type chatChoice struct {
 //	Key:      GEMINI_API_KEY > GOOGLE_API_KEY > LLM_API_KEY
 //	Model:    GEMINI_MODEL > LLM_MODEL > gemini-2.5-flash
 //
-// Localhost/127.* base allows no key or LLM_ALLOW_NO_KEY=true.
+// Localhost/127.* base allows no key or LLM_ALLOW_NO_KEY=true. LLM_PROVIDER=ollama
+// (configured as openai) or a localhost base marks the backend as Ollama for
+// model pinning (modelpin.go).
 func NewFromEnv() (Client, error) {
 	provider := strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER")))
 	if provider == "" {
@@ -159,14 +168,44 @@ func NewFromEnv() (Client, error) {
 		return nil, ErrLLMDisabled
 	}
 
+	hc := &http.Client{Timeout: timeout}
+	// LLM_LOG_HTTP=true dumps prompts/responses (clipped to LOG_CLIP_LLM, full text in the debug sink)
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("LLM_LOG_HTTP")), "true") {
+		hc.Transport = &loggingRT{base: http.DefaultTransport}
+	}
 	return &OpenAIClient{
 		BaseURL: strings.TrimRight(base, "/"),
 		APIKey:  key,
 		Model:   model,
-		HTTP:    &http.Client{Timeout: timeout},
+		HTTP:    hc,
+		Ollama:  provider == "ollama" || strings.Contains(base, "localhost") || strings.Contains(base, "127.0.0.1"),
 	}, nil
 }
 
+// Warmer is implemented by clients that can open their connection ahead of use.
+type Warmer interface {
+	Warm(ctx context.Context) error
+}
+
+// Warm issues a cheap GET {base}/models so the pooled TCP/TLS connection is
+// ready before the next Chat call. No tokens are consumed.
+func (c *OpenAIClient) Warm(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(c.APIKey) != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	res, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body) // drain so the connection returns to the pool
+	return nil
+}
+
 // Chat sends a synchronous chat.completions request.
 func (c *OpenAIClient) Chat(ctx context.Context, system, user string) (string, error) {
 	reqBody := chatReq{
@@ -189,8 +228,14 @@ func (c *OpenAIClient) Chat(ctx context.Context, system, user string) (string, e
 
 		res, err := c.HTTP.Do(httpReq)
 		if err != nil {
+			// The caller is gone (client abort, deadline): do not retry
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
 			if attempt == 0 {
-				time.Sleep(3 * time.Second)
+				if err := sleepCtx(ctx, 3*time.Second); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", err
@@ -201,14 +246,12 @@ func (c *OpenAIClient) Chat(ctx context.Context, system, user string) (string, e
 		if res.StatusCode == http.StatusTooManyRequests { // 429
 			if attempt == 0 {
                 // Honor Retry-After header if present
-				if ra := strings.TrimSpace(res.Header.Get("Retry-After")); ra != "" {
-					if sec, _ := strconv.Atoi(ra); sec > 0 {
-						time.Sleep(time.Duration(sec) * time.Second)
-					} else {
-						time.Sleep(4 * time.Second)
-					}
-				} else {
-					time.Sleep(4 * time.Second)
+				wait := 4 * time.Second
+				if sec, _ := strconv.Atoi(strings.TrimSpace(res.Header.Get("Retry-After"))); sec > 0 {
+					wait = time.Duration(sec) * time.Second
+				}
+				if err := sleepCtx(ctx, wait); err != nil {
+					return "", err
 				}
 				continue
 			}
@@ -216,7 +259,9 @@ func (c *OpenAIClient) Chat(ctx context.Context, system, user string) (string, e
 		}
 		if res.StatusCode/100 != 2 {
 			if attempt == 0 {
-				time.Sleep(2 * time.Second)
+				if err := sleepCtx(ctx, 2*time.Second); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", fmt.Errorf("%d %s", res.StatusCode, strings.TrimSpace(string(body)))
@@ -229,23 +274,44 @@ func (c *OpenAIClient) Chat(ctx context.Context, system, user string) (string, e
 		}
 		if out.Error != nil {
 			if attempt == 0 {
-				time.Sleep(2 * time.Second)
+				if err := sleepCtx(ctx, 2*time.Second); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", errors.New(strings.TrimSpace(out.Error.Message))
 		}
 		if len(out.Choices) == 0 {
 			if attempt == 0 {
-				time.Sleep(1 * time.Second)
+				if err := sleepCtx(ctx, 1*time.Second); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", errors.New("llm: empty choices")
 		}
+		// Which model answered, checked against the pin (modelpin.go)
+		if err := c.observe(ctx, out.Model, out.SystemFingerprint); err != nil {
+			return "", err
+		}
 		return strings.TrimSpace(out.Choices[0].Message.Content), nil
 	}
 	return "", errors.New("llm: retry exhausted")
 }
 
+// sleepCtx waits d between retries, returning early with ctx's error when the
+// caller gives up.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 // ---------- Domain helpers (safe fallbacks inside) ----------
 
 func GeneratePaymentClarify(ctx context.Context, c Client, lang string, missing []string, userText string) string {
@@ -261,7 +327,7 @@ func GeneratePaymentClarify(ctx context.Context, c Client, lang string, missing
 			sys = "결제/송금 맥락에서 누락된 항목만 간결하게 한 문장으로 물어봐. 설명/목록 없이 질문 한 문장만."
 			user = fmt.Sprintf("사용자 입력: %q\n누락 항목: %s\n질문 한 문장만 출력.", strings.TrimSpace(userText), miss)
 		}
-		if out, err := c.Chat(ctx, sys, user); err == nil && strings.TrimSpace(out) != "" {
+		if out, err := ChatLang(ctx, c, wantLang(lang), sys, user); err == nil && strings.TrimSpace(out) != "" {
 			return strings.TrimSpace(out)
 		}
 	}
@@ -273,28 +339,25 @@ func GeneratePaymentClarify(ctx context.Context, c Client, lang string, missing
 }
 
 func GeneratePaymentReceipt(ctx context.Context, c Client, lang string, to string, amountKRW int64, method, item, memo string) string {
-	amt := withComma(amountKRW)
-	if amt == "" {
-		amt = "0"
-	}
+	amt := money.FormatKRW(lang, amountKRW)
 	if c != nil {
 		sys := "Generate exactly ONE single-line human-friendly receipt/confirmation sentence."
-		user := fmt.Sprintf("to=%s, amount=%s KRW, method=%s, item=%s, memo=%s. One short line.",
+		user := fmt.Sprintf("to=%s, amount=%s, method=%s, item=%s, memo=%s. One short line.",
 			nz(to), amt, nz(method), nz(item), nz(memo))
 		if lang == "ko" {
 			sys = "영수증 확인 문장을 한국어로 한 줄만 생성한다. 간결하게."
-			user = fmt.Sprintf("수신자=%s, 금액=%s원, 결제수단=%s, 제품=%s, 메모=%s. 한 줄만 출력.",
+			user = fmt.Sprintf("수신자=%s, 금액=%s, 결제수단=%s, 제품=%s, 메모=%s. 한 줄만 출력.",
 				nz(to), amt, nz(method), nz(item), nz(memo))
 		}
-		if out, err := c.Chat(ctx, sys, user); err == nil && strings.TrimSpace(out) != "" {
+		if out, err := ChatLang(ctx, c, wantLang(lang), sys, user); err == nil && strings.TrimSpace(out) != "" {
 			return strings.TrimSpace(out)
 		}
 	}
 	if lang == "ko" {
-		return fmt.Sprintf("%s님에 대한 결제 %s원(%s) 처리 완료%s.",
+		return fmt.Sprintf("%s님에 대한 결제 %s(%s) 처리 완료%s.",
 			nz(to), amt, nz(method), optionalSuffix(" - "+nz(item)))
 	}
-	return fmt.Sprintf("Payment %s KRW via %s to %s completed%s.",
+	return fmt.Sprintf("Payment %s via %s to %s completed%s.",
 		amt, nz(method), nz(to), optionalSuffix(" - "+nz(item)))
 }
 
@@ -348,22 +411,3 @@ func optionalSuffix(s string) string {
 	}
 	return s
 }
-
-func withComma(n int64) string {
-	neg := n < 0
-	if neg {
-		n = -n
-	}
-	s := fmt.Sprintf("%d", n)
-	var out []byte
-	for i, c := range []byte(s) {
-		if i > 0 && (len(s)-i)%3 == 0 {
-			out = append(out, ',')
-		}
-		out = append(out, c)
-	}
-	if neg {
-		return "-" + string(out)
-	}
-	return string(out)
-}
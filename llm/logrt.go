@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"regexp"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/logclip"
 )
 
 type loggingRT struct{ base http.RoundTripper }
@@ -25,7 +27,8 @@ func (l *loggingRT) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	safe := authRe.ReplaceAll(reqDump, []byte("Authorization: Bearer ***REDACTED***"))
 	if len(safe) > 0 {
-		log.Printf("\n===== LLM OUTBOUND >>> %s %s =====\n%s\n===== END LLM OUTBOUND =====\n", req.Method, req.URL.String(), safe)
+		// Prompts can be long: inline up to LOG_CLIP_LLM, the rest goes to the debug sink
+		log.Printf("\n===== LLM OUTBOUND >>> %s %s =====\n%s\n===== END LLM OUTBOUND =====\n", req.Method, req.URL.String(), logclip.Clip("llm", string(safe)))
 	}
 
 	// send
@@ -39,10 +42,7 @@ func (l *loggingRT) RoundTrip(req *http.Request) (*http.Response, error) {
 		b, _ := io.ReadAll(resp.Body)
 		resp.Body = io.NopCloser(bytes.NewReader(b))
 		d, _ := httputil.DumpResponse(resp, true)
-		if len(d) > 4096 { // truncate if too long
-			d = append(d[:4096], []byte("\n... (truncated) ...")...)
-		}
-		log.Printf("\n===== LLM INBOUND  <<< %s %s =====\n%s\n===== END LLM INBOUND  =====\n", req.Method, req.URL.String(), d)
+		log.Printf("\n===== LLM INBOUND  <<< %s %s =====\n%s\n===== END LLM INBOUND  =====\n", req.Method, req.URL.String(), logclip.Clip("llm", string(d)))
 	}
 	return resp, nil
 }
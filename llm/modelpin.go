@@ -0,0 +1,306 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Model pinning.
+//
+// Every answer names the model that produced it ("model" in the completion,
+// and "system_fingerprint" where the provider sends one). An Ollama backend
+// (LLM_PROVIDER=ollama, or a localhost base URL) is also asked GET /api/tags,
+// at most every LLM_MODEL_TAGS_TTL_MS (default 60000), for the digest behind
+// the tag: pulling a newer gemma3:4b keeps the name but changes the digest.
+//
+// LLM_MODEL_PIN is the identity every call expects; LLM_MODEL_PIN_<PURPOSE>
+// (ROUTING, EXTRACTION, PLANNING, REPLY) overrides it for one purpose. A pin
+// is "name", "name@digest" or "@digest"; a digest matches by prefix, with or
+// without "sha256:", and a pinned digest the backend does not report is a
+// mismatch. A mismatching call is counted and logged once per purpose and
+// identity, and noted on the context (WithModelNote), so agents stamp
+// metadata llmModelMismatch:true. With LLM_MODEL_PIN_STRICT=true its answer
+// is also discarded and Chat returns ErrModelMismatch: callers take their
+// rule-based or template path as for any LLM error.
+
+// Purposes a call can be pinned for (WithPurpose). ChatLang defaults to
+// PurposeReply; an untagged Chat uses LLM_MODEL_PIN alone.
+const (
+	PurposeRouting    = "routing"
+	PurposeExtraction = "extraction"
+	PurposePlanning   = "planning"
+	PurposeReply      = "reply"
+
+	purposeDefault = "default"
+)
+
+// ErrModelMismatch is returned in strict mode when the answering model does
+// not match the pin for the call's purpose.
+var ErrModelMismatch = errors.New("llm: model does not match the pin")
+
+type purposeKey struct{}
+
+// WithPurpose tags the calls made with ctx for pin lookup.
+func WithPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, purposeKey{}, purpose)
+}
+
+func purposeOf(ctx context.Context) string {
+	if p, _ := ctx.Value(purposeKey{}).(string); p != "" {
+		return p
+	}
+	return purposeDefault
+}
+
+type modelNoteKey struct{}
+
+// WithModelNote returns a context that records whether a call made with it
+// was answered by a model other than the pinned one (see ModelMismatched).
+func WithModelNote(ctx context.Context) context.Context {
+	return context.WithValue(ctx, modelNoteKey{}, new(atomic.Bool))
+}
+
+// ModelMismatched reports whether a call under ctx saw a pin mismatch.
+func ModelMismatched(ctx context.Context) bool {
+	n, _ := ctx.Value(modelNoteKey{}).(*atomic.Bool)
+	return n != nil && n.Load()
+}
+
+func noteModelMismatch(ctx context.Context) {
+	if n, _ := ctx.Value(modelNoteKey{}).(*atomic.Bool); n != nil {
+		n.Store(true)
+	}
+}
+
+// Identity is what a backend reported about the model behind its last answer.
+type Identity struct {
+	Backend   string    `json:"backend"`
+	Requested string    `json:"requested"`
+	Model     string    `json:"model"`
+	Digest    string    `json:"digest,omitempty"`
+	Version   string    `json:"version,omitempty"` // system_fingerprint
+	At        time.Time `json:"at"`
+}
+
+func (id Identity) String() string {
+	if id.Digest == "" {
+		return id.Model
+	}
+	return id.Model + "@" + shortDigest(id.Digest)
+}
+
+// Pin is an expected model identity.
+type Pin struct {
+	Model  string `json:"model,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// ParsePin reads "name", "name@digest" or "@digest".
+func ParsePin(s string) Pin {
+	name, digest, _ := strings.Cut(strings.TrimSpace(s), "@")
+	return Pin{Model: strings.TrimSpace(name), Digest: strings.ToLower(strings.TrimPrefix(strings.TrimSpace(digest), "sha256:"))}
+}
+
+func (p Pin) String() string {
+	if p.Digest == "" {
+		return p.Model
+	}
+	return p.Model + "@" + p.Digest
+}
+
+// Matches reports whether id satisfies p, and why not.
+func (p Pin) Matches(id Identity) (bool, string) {
+	if p.Model != "" && modelName(p.Model) != modelName(id.Model) {
+		return false, "model " + id.Model
+	}
+	if p.Digest != "" {
+		got := strings.ToLower(strings.TrimPrefix(id.Digest, "sha256:"))
+		if got == "" {
+			return false, "digest not reported"
+		}
+		if !strings.HasPrefix(got, p.Digest) {
+			return false, "digest " + shortDigest(got)
+		}
+	}
+	return true, ""
+}
+
+// modelName compares Ollama tags with and without the implied ":latest".
+func modelName(s string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ":latest")
+}
+
+func shortDigest(d string) string {
+	d = strings.TrimPrefix(d, "sha256:")
+	if len(d) > 12 {
+		return d[:12]
+	}
+	return d
+}
+
+// PinFor is the pin for purpose: LLM_MODEL_PIN_<PURPOSE>, else LLM_MODEL_PIN.
+func PinFor(purpose string) (Pin, bool) {
+	v := firstNonEmpty(os.Getenv("LLM_MODEL_PIN_"+strings.ToUpper(purpose)), os.Getenv("LLM_MODEL_PIN"))
+	if v == "" {
+		return Pin{}, false
+	}
+	return ParsePin(v), true
+}
+
+// StrictPins reports whether a mismatch discards the answer.
+func StrictPins() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("LLM_MODEL_PIN_STRICT")), "true")
+}
+
+var models = struct {
+	mu         sync.Mutex
+	by         map[string]Identity   // backend -> last identity
+	tags       map[string]tagDigests // backend -> /api/tags answer
+	mismatches map[string]int64      // purpose -> count
+	logged     map[string]bool       // purpose + identity already logged
+}{by: map[string]Identity{}, tags: map[string]tagDigests{}, mismatches: map[string]int64{}, logged: map[string]bool{}}
+
+type tagDigests struct {
+	at      time.Time
+	digests map[string]string // modelName -> digest
+}
+
+// observe records the identity behind an answer and checks it against the
+// pin for ctx's purpose; in strict mode a mismatch is an error.
+func (c *OpenAIClient) observe(ctx context.Context, model, fingerprint string) error {
+	id := Identity{Backend: c.BaseURL, Requested: c.Model, Model: firstNonEmpty(model, c.Model), Version: fingerprint, At: time.Now().UTC()}
+	if c.Ollama {
+		id.Digest = c.tagDigest(ctx, id.Model)
+	}
+	models.mu.Lock()
+	models.by[id.Backend] = id
+	models.mu.Unlock()
+
+	purpose := purposeOf(ctx)
+	pin, ok := PinFor(purpose)
+	if !ok {
+		return nil
+	}
+	match, why := pin.Matches(id)
+	if match {
+		return nil
+	}
+	noteModelMismatch(ctx)
+	key := purpose + "|" + id.String()
+	models.mu.Lock()
+	models.mismatches[purpose]++
+	first := !models.logged[key]
+	models.logged[key] = true
+	models.mu.Unlock()
+	if first {
+		log.Printf("[llm][model] ⚠️ %s pinned to %s but %s answered with %s (%s); strict=%v", purpose, pin, id.Backend, id, why, StrictPins())
+	}
+	if StrictPins() {
+		return fmt.Errorf("%w: %s wants %s, got %s", ErrModelMismatch, purpose, pin, id)
+	}
+	return nil
+}
+
+// tagDigest is the digest Ollama's /api/tags lists for model ("" when the
+// query fails or the model is not listed).
+func (c *OpenAIClient) tagDigest(ctx context.Context, model string) string {
+	ttl := 60 * time.Second
+	if ms, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LLM_MODEL_TAGS_TTL_MS"))); err == nil && ms >= 0 {
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	models.mu.Lock()
+	t, ok := models.tags[c.BaseURL]
+	models.mu.Unlock()
+	if !ok || time.Since(t.at) >= ttl {
+		t = tagDigests{at: time.Now(), digests: c.fetchTags(ctx)}
+		models.mu.Lock()
+		models.tags[c.BaseURL] = t
+		models.mu.Unlock()
+	}
+	return t.digests[modelName(model)]
+}
+
+func (c *OpenAIClient) fetchTags(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(c.BaseURL, "/v1")+"/api/tags", nil)
+	if err != nil {
+		return nil
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		log.Printf("[llm][model] %s/api/tags: %v", c.BaseURL, err)
+		return nil
+	}
+	defer res.Body.Close()
+	var out struct {
+		Models []struct {
+			Name   string `json:"name"`
+			Model  string `json:"model"`
+			Digest string `json:"digest"`
+		} `json:"models"`
+	}
+	if res.StatusCode/100 != 2 || json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&out) != nil {
+		return nil
+	}
+	m := make(map[string]string, len(out.Models))
+	for _, x := range out.Models {
+		m[modelName(x.Name)] = x.Digest
+		if x.Model != "" {
+			m[modelName(x.Model)] = x.Digest
+		}
+	}
+	return m
+}
+
+// ModelStatus is the observed identity per backend, the pins in force and
+// the mismatch counts per purpose, for /status.
+func ModelStatus() map[string]any {
+	pins := map[string]string{}
+	for _, p := range []string{purposeDefault, PurposeRouting, PurposeExtraction, PurposePlanning, PurposeReply} {
+		if pin, ok := PinFor(p); ok {
+			pins[p] = pin.String()
+		}
+	}
+	models.mu.Lock()
+	defer models.mu.Unlock()
+	backends := make([]Identity, 0, len(models.by))
+	for _, id := range models.by {
+		backends = append(backends, id)
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Backend < backends[j].Backend })
+	mm := make(map[string]int64, len(models.mismatches))
+	for p, n := range models.mismatches {
+		mm[p] = n
+	}
+	return map[string]any{"backends": backends, "pins": pins, "strict": StrictPins(), "mismatches": mm}
+}
+
+// WriteModelPrometheus writes the mismatch counts per purpose.
+func WriteModelPrometheus(w io.Writer, agent string) {
+	models.mu.Lock()
+	purposes := make([]string, 0, len(models.mismatches))
+	for p := range models.mismatches {
+		purposes = append(purposes, p)
+	}
+	sort.Strings(purposes)
+	var b strings.Builder
+	b.WriteString("# HELP sage_llm_model_mismatch_total LLM answers from a model other than the pinned one, per purpose.\n# TYPE sage_llm_model_mismatch_total counter\n")
+	for _, p := range purposes {
+		b.WriteString(`sage_llm_model_mismatch_total{agent="` + agent + `",purpose="` + p + `"} ` + strconv.FormatInt(models.mismatches[p], 10) + "\n")
+	}
+	models.mu.Unlock()
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeOllama answers chat completions as model and lists digest for it in
+// /api/tags; the digest can change between calls (a re-pulled tag).
+type fakeOllama struct {
+	model  string
+	digest atomic.Value
+}
+
+func newFakeOllama(t *testing.T, model, digest string) (*fakeOllama, *OpenAIClient) {
+	t.Helper()
+	f := &fakeOllama{model: model}
+	f.digest.Store(digest)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/tags":
+			_ = json.NewEncoder(w).Encode(map[string]any{"models": []map[string]string{{"name": f.model, "digest": f.digest.Load().(string)}}})
+		case "/v1/chat/completions":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id": "c1", "model": f.model, "system_fingerprint": "fp_1",
+				"choices": []map[string]any{{"index": 0, "message": map[string]string{"role": "assistant", "content": "ok"}}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, &OpenAIClient{BaseURL: srv.URL + "/v1", Model: model, HTTP: srv.Client(), Ollama: true}
+}
+
+func mismatches(purpose string) int64 {
+	return ModelStatus()["mismatches"].(map[string]int64)[purpose]
+}
+
+func TestPinMatches(t *testing.T) {
+	id := Identity{Model: "gemma3:4b", Digest: "sha256:a2af6cc3eb7fa8be"}
+	for _, tc := range []struct {
+		pin string
+		ok  bool
+		why string
+	}{
+		{"gemma3:4b", true, ""},
+		{"gemma3:4b@a2af6cc3", true, ""},
+		{"@sha256:A2AF6CC3", true, ""},
+		{"gemma3", false, "model gemma3:4b"},
+		{"gemma3:4b@ffff", false, "digest a2af6cc3eb7f"},
+	} {
+		if ok, why := ParsePin(tc.pin).Matches(id); ok != tc.ok || why != tc.why {
+			t.Errorf("%s: %v %q", tc.pin, ok, why)
+		}
+	}
+	if ok, why := ParsePin("llama3@abc").Matches(Identity{Model: "llama3:latest"}); ok || why != "digest not reported" {
+		t.Errorf("unreported digest: %v %q", ok, why)
+	}
+	if ok, _ := ParsePin("llama3").Matches(Identity{Model: "llama3:latest"}); !ok {
+		t.Error(":latest is implied")
+	}
+}
+
+// A re-pulled tag keeps its name but not its digest: the answer still comes
+// back, the turn is marked and the purpose counted.
+func TestModelDigestChange(t *testing.T) {
+	t.Setenv("LLM_MODEL_TAGS_TTL_MS", "0")
+	t.Setenv("LLM_MODEL_PIN", "")
+	t.Setenv("LLM_MODEL_PIN_EXTRACTION", "gemma3:4b@a2af6cc3")
+	t.Setenv("LLM_MODEL_PIN_STRICT", "")
+	f, c := newFakeOllama(t, "gemma3:4b", "sha256:a2af6cc3eb7fa8be")
+
+	ctx := WithModelNote(WithPurpose(context.Background(), PurposeExtraction))
+	if out, err := c.Chat(ctx, "s", "u"); out != "ok" || err != nil || ModelMismatched(ctx) {
+		t.Fatalf("pinned digest: %q %v mismatch=%v", out, err, ModelMismatched(ctx))
+	}
+
+	f.digest.Store("sha256:0d1e2f3a4b5c")
+	before := mismatches(PurposeExtraction)
+	ctx = WithModelNote(WithPurpose(context.Background(), PurposeExtraction))
+	if out, err := c.Chat(ctx, "s", "u"); out != "ok" || err != nil || !ModelMismatched(ctx) {
+		t.Fatalf("swapped digest: %q %v mismatch=%v", out, err, ModelMismatched(ctx))
+	}
+	if mismatches(PurposeExtraction) != before+1 {
+		t.Fatal("mismatch not counted")
+	}
+	// Routing has no pin of its own and LLM_MODEL_PIN is unset
+	ctx = WithModelNote(WithPurpose(context.Background(), PurposeRouting))
+	if _, err := c.Chat(ctx, "s", "u"); err != nil || ModelMismatched(ctx) {
+		t.Fatalf("unpinned purpose: %v %v", err, ModelMismatched(ctx))
+	}
+
+	var seen bool
+	for _, id := range ModelStatus()["backends"].([]Identity) {
+		seen = seen || (id.Backend == c.BaseURL && id.Digest == "sha256:0d1e2f3a4b5c" && id.Version == "fp_1")
+	}
+	var metrics strings.Builder
+	WriteModelPrometheus(&metrics, "root")
+	if !seen || !strings.Contains(metrics.String(), `sage_llm_model_mismatch_total{agent="root",purpose="extraction"}`) {
+		t.Fatalf("status %v\n%s", ModelStatus(), metrics.String())
+	}
+}
+
+// Strict mode drops the answer so the caller takes its fallback; ChatLang
+// calls are pinned as replies.
+func TestModelPinStrict(t *testing.T) {
+	t.Setenv("LLM_MODEL_PIN", "gemma3:4b")
+	t.Setenv("LLM_MODEL_PIN_REPLY", "")
+	t.Setenv("LLM_MODEL_PIN_STRICT", "true")
+	_, c := newFakeOllama(t, "qwen2.5:7b", "sha256:beef")
+
+	before := mismatches(PurposeReply)
+	ctx := WithModelNote(context.Background())
+	out, err := ChatLang(ctx, c, "", "s", "u")
+	if out != "" || !errors.Is(err, ErrModelMismatch) || !ModelMismatched(ctx) || mismatches(PurposeReply) != before+1 {
+		t.Fatalf("%q %v mismatch=%v", out, err, ModelMismatched(ctx))
+	}
+}
@@ -0,0 +1,252 @@
+package pkg
+
+// The supported surface under pkg/ is guarded against accidental breaking
+// changes:
+//
+//	go test ./pkg -run TestAPISurface            # compare with the golden file
+//	go test ./pkg -run TestAPISurface -update    # record the current surface
+//
+// Every exported identifier of every pkg/ package is rendered as one line —
+// consts with their value, vars, funcs and methods with their parameter and
+// result types, types with their underlying form, exported struct fields with
+// their JSON tags, interface methods — and compared with
+// testdata/api/pkg.golden. A line that disappeared or changed is a breaking
+// change; a new line only needs -update. Parsing is syntactic (go/ast).
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const golden = "../testdata/api/pkg.golden"
+
+var update = flag.Bool("update", false, "rewrite testdata/api/pkg.golden from the current tree")
+
+func TestAPISurface(t *testing.T) {
+	cur, err := surface(".", "pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		body := "# Exported API of pkg/ (go test ./pkg -run TestAPISurface -update)\n" + strings.Join(cur, "\n") + "\n"
+		if err := os.WriteFile(golden, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("recorded %d identifiers in %s", len(cur), golden)
+		return
+	}
+
+	want, err := readGolden(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	have := map[string]bool{}
+	for _, l := range cur {
+		have[l] = true
+	}
+	var removed, added []string
+	for _, l := range want {
+		if !have[l] {
+			removed = append(removed, l)
+		}
+		delete(have, l)
+	}
+	for _, l := range cur {
+		if have[l] {
+			added = append(added, l)
+		}
+	}
+	if len(removed) > 0 {
+		t.Errorf("%d breaking change(s) to the public API. Keep the old identifiers (deprecate them) or, for a major release, record the new surface with -update:\n- %s",
+			len(removed), strings.Join(removed, "\n- "))
+	}
+	if len(added) > 0 {
+		t.Errorf("%d addition(s); record them with -update:\n+ %s", len(added), strings.Join(added, "\n+ "))
+	}
+}
+
+func readGolden(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if l := sc.Text(); l != "" && !strings.HasPrefix(l, "#") {
+			out = append(out, l)
+		}
+	}
+	return out, sc.Err()
+}
+
+// surface renders the exported identifiers of every package below root,
+// labeled by their path under label.
+func surface(root, label string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if name := d.Name(); dir != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "internal") {
+			return filepath.SkipDir
+		}
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			return err
+		}
+		for _, p := range pkgs {
+			if p.Name == "main" {
+				continue
+			}
+			for _, f := range p.Files {
+				out = append(out, fileSurface(fset, path.Join(label, filepath.ToSlash(dir)), f)...)
+			}
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out, err
+}
+
+func fileSurface(fset *token.FileSet, pkg string, f *ast.File) []string {
+	var out []string
+	emit := func(format string, args ...any) { out = append(out, pkg+": "+fmt.Sprintf(format, args...)) }
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil {
+				emit("func %s%s", d.Name.Name, signature(fset, d.Type))
+				continue
+			}
+			recv := render(fset, d.Recv.List[0].Type)
+			if !ast.IsExported(strings.TrimLeft(recv, "*")) {
+				continue
+			}
+			emit("method (%s) %s%s", recv, d.Name.Name, signature(fset, d.Type))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					kind := strings.ToLower(d.Tok.String())
+					for i, n := range s.Names {
+						if !n.IsExported() {
+							continue
+						}
+						line := kind + " " + n.Name
+						if s.Type != nil {
+							line += " " + render(fset, s.Type)
+						}
+						if d.Tok == token.CONST && i < len(s.Values) {
+							line += " = " + render(fset, s.Values[i])
+						}
+						emit("%s", line)
+					}
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					out = append(out, typeSurface(fset, pkg, s)...)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func typeSurface(fset *token.FileSet, pkg string, s *ast.TypeSpec) []string {
+	name := s.Name.Name
+	if s.Assign.IsValid() {
+		return []string{fmt.Sprintf("%s: type %s = %s", pkg, name, render(fset, s.Type))}
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		out := []string{fmt.Sprintf("%s: type %s struct", pkg, name)}
+		for _, fl := range t.Fields.List {
+			typ := render(fset, fl.Type)
+			tag := ""
+			if fl.Tag != nil {
+				tag = " " + fl.Tag.Value
+			}
+			if len(fl.Names) == 0 { // embedded
+				if ast.IsExported(strings.TrimLeft(typ[strings.LastIndex(typ, ".")+1:], "*")) {
+					out = append(out, fmt.Sprintf("%s: field %s.%s%s", pkg, name, typ, tag))
+				}
+				continue
+			}
+			for _, n := range fl.Names {
+				if n.IsExported() {
+					out = append(out, fmt.Sprintf("%s: field %s.%s %s%s", pkg, name, n.Name, typ, tag))
+				}
+			}
+		}
+		return out
+	case *ast.InterfaceType:
+		out := []string{fmt.Sprintf("%s: type %s interface", pkg, name)}
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				out = append(out, fmt.Sprintf("%s: embed %s.%s", pkg, name, render(fset, m.Type)))
+				continue
+			}
+			if ft, ok := m.Type.(*ast.FuncType); ok && m.Names[0].IsExported() {
+				out = append(out, fmt.Sprintf("%s: imethod %s.%s%s", pkg, name, m.Names[0].Name, signature(fset, ft)))
+			}
+		}
+		return out
+	}
+	return []string{fmt.Sprintf("%s: type %s %s", pkg, name, render(fset, s.Type))}
+}
+
+// signature renders a func type without parameter names, which callers
+// cannot depend on.
+func signature(fset *token.FileSet, ft *ast.FuncType) string {
+	types := func(fl *ast.FieldList) []string {
+		if fl == nil {
+			return nil
+		}
+		var out []string
+		for _, f := range fl.List {
+			n := max(1, len(f.Names))
+			for i := 0; i < n; i++ {
+				out = append(out, render(fset, f.Type))
+			}
+		}
+		return out
+	}
+	sig := "(" + strings.Join(types(ft.Params), ", ") + ")"
+	switch res := types(ft.Results); len(res) {
+	case 0:
+	case 1:
+		sig += " " + res[0]
+	default:
+		sig += " (" + strings.Join(res, ", ") + ")"
+	}
+	return sig
+}
+
+func render(fset *token.FileSet, n ast.Node) string {
+	var b bytes.Buffer
+	_ = printer.Fprint(&b, fset, n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
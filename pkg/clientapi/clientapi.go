@@ -0,0 +1,328 @@
+// Package clientapi is the client-facing facade in front of Root: one
+// POST /api/request per user action, forwarded to Root's /process with the
+// per-request SAGE/HPKE toggles, answered as a types.PromptResponse.
+// cmd/client serves it; embedders mount HandleRequest/HandleStatus on their
+// own mux. Part of the supported public surface (see pkg/doc.go).
+package clientapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
+	promptlimit "github.com/sage-x-project/sage-multi-agent/internal/prompt"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// ClientAPI is a thin HTTP facade for frontend -> Root.
+// Frontend sends a single request per user action:
+//   - POST /api/request
+//   - Headers:
+//     X-SAGE-Enabled: true|false  (per-request A2A signature toggle)
+//     X-HPKE-Enabled: true|false  (per-request HPKE toggle; SAGE=false forces HPKE=false)
+//   - Body: {"prompt": "..."}
+//
+// ClientAPI forwards the prompt to Root and passes SAGE/HPKE flags via headers only (no body metadata).
+// Root does in‑proc routing to sub‑agents (planning/medical/payment).
+// NOTE: For backward compatibility, this API also hits Root /toggle-sage to reflect the header
+//
+//	into the legacy global toggle; per‑request behavior is still driven by message metadata.
+type ClientAPI struct {
+	rootBase    string
+	paymentBase string // legacy; unused
+	httpClient  *http.Client
+	a2aClient   *a2aclient.A2AClient
+}
+
+func NewClientAPI(rootBase, paymentBase string, httpClient *http.Client) *ClientAPI {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &ClientAPI{
+		rootBase:    strings.TrimRight(rootBase, "/"),
+		paymentBase: strings.TrimRight(paymentBase, "/"),
+		httpClient:  httpClient,
+	}
+}
+
+func NewClientAPIWithA2A(rootBase, paymentBase string, httpClient *http.Client, a2a *a2aclient.A2AClient) *ClientAPI {
+	api := NewClientAPI(rootBase, paymentBase, httpClient)
+	api.a2aClient = a2a
+	return api
+}
+
+// Single endpoint: /api/request
+// - Headers from frontend (see file header)
+// - Body: {"prompt": "..."}; if JSON decode fails, treat body as plain text prompt
+// - Response: PromptResponse { response, sageVerification, metadata, logs? }
+func (g *ClientAPI) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Per-request security toggles from frontend
+	sageEnabled := strings.EqualFold(r.Header.Get("X-SAGE-Enabled"), "true")
+	hpkeRaw := r.Header.Get("X-HPKE-Enabled")
+	hpkeEnabled := strings.EqualFold(hpkeRaw, "true")
+	scenario := r.Header.Get("X-Scenario")
+
+	// Read raw body once (gzip request bodies are inflated)
+	src, rerr := gzipx.RequestBody(r)
+	if rerr != nil {
+		writeClientError(w, http.StatusBadRequest, "bad_request", "The request body could not be read.", rerr.Error(), false)
+		return
+	}
+	rawIn, _ := io.ReadAll(src)
+	_ = r.Body.Close()
+
+	var prompt string
+	allowTrunc := false
+	if len(rawIn) > 0 {
+		var reqIn types.PromptRequest
+		if err := json.Unmarshal(rawIn, &reqIn); err == nil && strings.TrimSpace(reqIn.Prompt) != "" {
+			prompt = reqIn.Prompt
+			allowTrunc = reqIn.AllowTruncation
+		} else {
+			prompt = strings.TrimSpace(string(rawIn))
+		}
+	}
+
+	// Prompt size limit (bytes): reject with 413, or trim when the caller opted in
+	limit := promptlimit.MaxBytes()
+	truncated := 0
+	if err := promptlimit.Check(prompt, limit); err != nil {
+		if !allowTrunc {
+			promptlimit.WriteTooLarge(w, err)
+			return
+		}
+		prompt, truncated = promptlimit.Truncate(prompt, limit)
+	}
+
+	// Reject invalid combo only if HPKE header explicitly set
+	if hpkeRaw != "" && hpkeEnabled && !sageEnabled {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":   "bad_request",
+			"message": "HPKE requires SAGE to be enabled (X-SAGE-Enabled: true)",
+		})
+		return
+	}
+
+	// Legacy global switch (optional)
+	_ = g.toggleSAGE(r.Context(), g.rootBase+"/toggle-sage", sageEnabled)
+
+	// Build AgentMessage → Root
+	meta := map[string]any{
+		"scenario":    scenario,
+		"sageEnabled": sageEnabled,
+	}
+	if hpkeRaw != "" {
+		meta["hpkeEnabled"] = hpkeEnabled
+	}
+	if truncated > 0 {
+		meta[promptlimit.MetaAllowTruncation] = true
+		meta["truncatedChars"] = truncated
+	}
+
+	msg := types.AgentMessage{
+		ID:        "api-" + time.Now().Format("20060102T150405.000000000"),
+		From:      "client-api",
+		To:        "root",
+		Content:   prompt,
+		Timestamp: time.Now(),
+		Type:      "request",
+		Metadata:  meta,
+	}
+	body, _ := json.Marshal(msg)
+
+	// Proxy request to Root (/process)
+	reqOut, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, g.rootBase+"/process", bytes.NewReader(body))
+	reqOut.Header.Set("Content-Type", "application/json")
+
+    // IMPORTANT: forward per-request toggle headers to Root as-is
+	if sageEnabled {
+		reqOut.Header.Set("X-SAGE-Enabled", "true")
+	} else {
+		reqOut.Header.Set("X-SAGE-Enabled", "false")
+	}
+	if hpkeRaw != "" {
+        // Forward only if explicitly specified (otherwise use server default/session)
+		if hpkeEnabled {
+			reqOut.Header.Set("X-HPKE-Enabled", "true")
+		} else {
+			reqOut.Header.Set("X-HPKE-Enabled", "false")
+		}
+	}
+	if scenario != "" {
+		reqOut.Header.Set("X-Scenario", scenario)
+	}
+	// Operator debug detail is opt-in; pass the switches through to Root
+	debug := strings.EqualFold(strings.TrimSpace(r.Header.Get("X-SAGE-Debug")), "true")
+	if debug {
+		reqOut.Header.Set("X-SAGE-Debug", "true")
+	}
+	if v := r.Header.Get("X-Admin-Token"); v != "" {
+		reqOut.Header.Set("X-Admin-Token", v)
+	}
+	// Conversation identity and time zone, and the (admin-only) payment dry run
+	for _, h := range []string{"X-Conversation-ID", "X-SAGE-Context-ID", "X-SAGE-Timezone", "X-SAGE-Dry-Run"} {
+		if v := strings.TrimSpace(r.Header.Get(h)); v != "" {
+			reqOut.Header.Set(h, v)
+		}
+	}
+
+    // Rewindable body (for signing/middleware)
+	reqOut.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	var resp *http.Response
+	var err error
+	if sageEnabled && g.a2aClient != nil {
+		resp, err = g.a2aClient.Do(r.Context(), reqOut)
+	} else {
+		resp, err = g.httpClient.Do(reqOut)
+	}
+	if err != nil {
+		log.Printf("[client-api] root unreachable: %v", err)
+		writeClientError(w, http.StatusBadGateway, "upstream_unreachable",
+			"The service is unreachable right now. Please try again shortly.", err.Error(), debug)
+		return
+	}
+	defer resp.Body.Close()
+
+	var agentResp types.AgentMessage
+	rawBody, _ := io.ReadAll(resp.Body)
+	if len(rawBody) > 0 {
+		if err := json.Unmarshal(rawBody, &agentResp); err != nil {
+			// Plain-text error bodies carry internal detail; never show them to the user
+			if resp.StatusCode/100 != 2 {
+				log.Printf("[client-api] root error status=%d body=%q", resp.StatusCode, strings.TrimSpace(string(rawBody)))
+				writeClientError(w, resp.StatusCode, "upstream_error",
+					"Something went wrong while processing. Please try again shortly.", strings.TrimSpace(string(rawBody)), debug)
+				return
+			}
+			agentResp = types.AgentMessage{
+				From:    "root",
+				To:      "client-api",
+				Type:    "response",
+				Content: strings.TrimSpace(string(rawBody)),
+			}
+		}
+	}
+
+	// Root derives these from what actually happened upstream
+	verifiedState := verificationState(resp.Header.Get("X-SAGE-Verified"))
+	sigState := verificationState(resp.Header.Get("X-SAGE-Signature-Valid"))
+	verification := &types.SAGEVerificationResult{
+		Verified:            verifiedState == types.VerificationTrue,
+		VerifiedState:       verifiedState,
+		SignatureValid:      sigState == types.VerificationTrue,
+		SignatureValidState: sigState,
+		Timestamp:           time.Now().Unix(),
+		Details:             map[string]string{"scenario": scenario},
+	}
+
+	out := types.PromptResponse{
+		Response:         agentResp.Content,
+		Logs:             nil,
+		SAGEVerification: verification,
+		Metadata: &types.ResponseMetadata{
+			RequestID:      msg.ID,
+			ProcessingTime: 0,
+			AgentPath:      []string{"client-api", "root"},
+			Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	if strings.EqualFold(agentResp.Type, "error") {
+		ed := &types.ErrorDetail{Code: "upstream_error", Message: agentResp.Content, Recoverable: true}
+		if e, ok := agentResp.Metadata["error"].(map[string]any); ok {
+			if c, ok := e["code"].(string); ok && c != "" {
+				ed.Code = c
+			}
+		}
+		if dbg, ok := agentResp.Metadata["debug"]; ok && debug {
+			if b, err := json.Marshal(dbg); err == nil {
+				ed.Details = string(b)
+			}
+		}
+		out.Error = ed
+	}
+
+	// Effective conversation time zone, and why a requested one was not used
+	for _, h := range []string{"X-SAGE-Timezone", "X-SAGE-Timezone-Warning"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// verificationState normalizes a Root verification header; a missing or
+// unknown value makes no claim (not-applicable).
+func verificationState(h string) string {
+	switch v := strings.ToLower(strings.TrimSpace(h)); v {
+	case types.VerificationTrue, types.VerificationFalse:
+		return v
+	}
+	return types.VerificationNotApplicable
+}
+
+// writeClientError answers with a generic message; detail only when debug is set.
+func writeClientError(w http.ResponseWriter, status int, code, msg, detail string, debug bool) {
+	ed := &types.ErrorDetail{Code: code, Message: msg, Recoverable: true}
+	if debug {
+		ed.Details = detail
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(types.PromptResponse{Response: msg, Error: ed})
+}
+
+// HandleStatus exposes client-side limits so frontends can pre-validate.
+func (g *ClientAPI) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok":     true,
+		"root":   g.rootBase,
+		"limits": map[string]any{"promptMaxBytes": promptlimit.MaxBytes(), "promptUnit": "utf8-bytes"},
+	})
+}
+
+func (g *ClientAPI) toggleSAGE(ctx context.Context, url string, enabled bool) error {
+	body, _ := json.Marshal(map[string]bool{"enabled": enabled})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	if g.a2aClient != nil {
+		resp, err := g.a2aClient.Do(ctx, req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil
+	}
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
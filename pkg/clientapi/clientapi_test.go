@@ -0,0 +1,48 @@
+package clientapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// The client API refuses a prompt over the byte limit with 413, or, when the
+// caller opted in, forwards it trimmed with truncatedChars.
+func TestHandleRequestPromptLimit(t *testing.T) {
+	t.Setenv("PROMPT_MAX_BYTES", "10")
+	var got types.AgentMessage
+	root := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/process" {
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(types.AgentMessage{ID: "r1", From: "root", Type: "response", Content: "ok"})
+	}))
+	defer root.Close()
+	api := NewClientAPI(root.URL, "", root.Client())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.HandleRequest(w, httptest.NewRequest(http.MethodPost, "/api/request", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"prompt":"맥북 사줘 카드로"}`)
+	var refused map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&refused)
+	if w.Code != http.StatusRequestEntityTooLarge || refused["sizeBytes"] != float64(len("맥북 사줘 카드로")) || got.ID != "" {
+		t.Fatalf("refusal: %d %v (forwarded %q)", w.Code, refused, got.Content)
+	}
+
+	if w := post(`{"prompt":"맥북 사줘 카드로","allowTruncation":true}`); w.Code != http.StatusOK {
+		t.Fatalf("truncation: %d %s", w.Code, w.Body.String())
+	}
+	if got.Content != "맥북 사" || got.Metadata["truncatedChars"] != float64(5) || got.Metadata["allowTruncation"] != true {
+		t.Fatalf("forwarded %q %v", got.Content, got.Metadata)
+	}
+}
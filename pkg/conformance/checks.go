@@ -0,0 +1,564 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	a2aclient "github.com/sage-x-project/sage-a2a-go/pkg/client"
+	"github.com/sage-x-project/sage-multi-agent/internal/a2autil"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+	prototx "github.com/sage-x-project/sage-multi-agent/protocol"
+
+	sagecrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
+	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
+	sagedid "github.com/sage-x-project/sage/pkg/agent/did"
+	dideth "github.com/sage-x-project/sage/pkg/agent/did/ethereum"
+	"github.com/sage-x-project/sage/pkg/agent/hpke"
+	"github.com/sage-x-project/sage/pkg/agent/session"
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// Check outcomes. Only StatusFail makes a run fail.
+const (
+	StatusPass = "PASS"
+	StatusFail = "FAIL"
+	StatusWarn = "WARN"
+	StatusSkip = "SKIP"
+)
+
+const (
+	probeText    = "conformance-probe: please echo"
+	tamperedText = "conformance-probe: TAMPERED"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// plainDoer adapts http.Client to prototx.A2ADoer (no signing).
+type plainDoer struct{ c *http.Client }
+
+func (d plainDoer) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return d.c.Do(req.WithContext(ctx))
+}
+
+// tamperTransport rewrites the probe text after the a2a client has signed the
+// request, optionally recomputing Content-Digest (the gateway attack modes).
+type tamperTransport struct {
+	next            http.RoundTripper
+	recomputeDigest bool
+}
+
+func (t *tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		body = bytes.ReplaceAll(body, []byte(probeText), []byte(tamperedText))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		if t.recomputeDigest {
+			req.Header.Set("Content-Digest", a2autil.ComputeContentDigest(body))
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// captureTransport records the last request's path and Signature-Input as
+// they leave the client (after signing).
+type captureTransport struct {
+	next     http.RoundTripper
+	path     string
+	sigInput string
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.path, t.sigInput = req.URL.Path, req.Header.Get("Signature-Input")
+	return t.next.RoundTrip(req)
+}
+
+type suite struct {
+	base      string
+	opName    string // optional non-default operation (-op name=/path)
+	opPath    string
+	timeout   time.Duration
+	http      *http.Client
+	kp        sagecrypto.KeyPair
+	did       string
+	serverDID string
+	signer    prototx.A2ADoer // nil without -jwk
+	status    map[string]any
+	results   []Result
+}
+
+func newSuite(base, jwkPath, did, serverDID string, timeout time.Duration) (*suite, error) {
+	s := &suite{base: base, timeout: timeout, serverDID: strings.TrimSpace(serverDID), http: &http.Client{Timeout: timeout}}
+	if strings.TrimSpace(jwkPath) == "" {
+		return s, nil
+	}
+	raw, err := os.ReadFile(jwkPath)
+	if err != nil {
+		return nil, fmt.Errorf("read -jwk: %w", err)
+	}
+	kp, err := formats.NewJWKImporter().Import(raw, sagecrypto.KeyFormatJWK)
+	if err != nil {
+		return nil, fmt.Errorf("import -jwk: %w", err)
+	}
+	did = strings.TrimSpace(did)
+	if did == "" {
+		// Same derivation as Root's initSigning
+		if priv, ok := kp.PrivateKey().(*ecdsa.PrivateKey); ok {
+			did = "did:sage:ethereum:" + ethcrypto.PubkeyToAddress(priv.PublicKey).Hex()
+		} else if id := strings.TrimSpace(kp.ID()); id != "" {
+			did = "did:sage:generated:" + id
+		} else {
+			return nil, fmt.Errorf("-did not set and cannot derive from key")
+		}
+	}
+	s.kp, s.did = kp, did
+	s.signer = s.signingClient(nil)
+	return s, nil
+}
+
+func (s *suite) signingClient(rt http.RoundTripper) *a2aclient.A2AClient {
+	c := &http.Client{Timeout: s.timeout}
+	if rt != nil {
+		c.Transport = rt
+	}
+	return a2aclient.NewA2AClient(sagedid.AgentDID(s.did), s.kp, c)
+}
+
+func (s *suite) add(id, status, detail, hint string) {
+	s.results = append(s.results, Result{ID: id, Status: status, Detail: detail, Hint: hint})
+}
+
+// sageRequired reports whether the agent advertises RFC 9421 enforcement.
+func (s *suite) sageRequired() bool {
+	b, _ := s.status["sage_enabled"].(bool)
+	return b
+}
+
+// doer picks the signing client when available, plain HTTP otherwise.
+func (s *suite) doer() prototx.A2ADoer {
+	if s.signer != nil {
+		return s.signer
+	}
+	return plainDoer{s.http}
+}
+
+func probeMessage() types.AgentMessage {
+	return types.AgentMessage{
+		ID:        "conf-" + uuid.NewString(),
+		From:      "conformance",
+		To:        "agent",
+		Type:      "request",
+		Content:   probeText,
+		Timestamp: time.Now(),
+	}
+}
+
+func (s *suite) run(ctx context.Context, wantHPKE bool) {
+	s.checkStatus(ctx)
+	s.checkUnsignedRejected(ctx)
+	s.checkRoundTrip(ctx)
+	s.checkTampered(ctx, "sig.tampered_body", false,
+		"verify Content-Digest against the received body before the handler runs")
+	s.checkTampered(ctx, "sig.tampered_digest", true,
+		"Content-Digest must be a covered component of the RFC 9421 signature")
+	s.checkBadJSON(ctx)
+	s.checkOperation(ctx)
+	if wantHPKE {
+		s.checkHPKE(ctx)
+	} else {
+		s.add("hpke.handshake", StatusSkip, "not requested (-hpke)", "")
+	}
+}
+
+func (s *suite) checkStatus(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.base+"/status", nil)
+	resp, err := s.http.Do(req)
+	if err != nil {
+		s.add("status.reachable", StatusFail, err.Error(), "serve GET /status without authentication")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.add("status.reachable", StatusFail, fmt.Sprintf("HTTP %d", resp.StatusCode), "GET /status must return 200 without a signature")
+		return
+	}
+	s.add("status.reachable", StatusPass, "HTTP 200", "")
+
+	var st map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		s.add("status.shape", StatusFail, "body is not a JSON object", `return {"name":…,"type":…,"sage_enabled":…}`)
+		return
+	}
+	s.status = st
+	var missing []string
+	for k, kind := range map[string]string{"name": "string", "type": "string", "sage_enabled": "bool"} {
+		v, ok := st[k]
+		switch {
+		case !ok:
+			missing = append(missing, k)
+		case kind == "string":
+			if _, ok := v.(string); !ok {
+				missing = append(missing, k+"(string)")
+			}
+		case kind == "bool":
+			if _, ok := v.(bool); !ok {
+				missing = append(missing, k+"(bool)")
+			}
+		}
+	}
+	if len(missing) > 0 {
+		s.add("status.shape", StatusFail, "missing/mistyped: "+strings.Join(missing, ", "), "operators and the demo UI read name, type and sage_enabled from /status")
+		return
+	}
+	s.add("status.shape", StatusPass, fmt.Sprintf("name=%v type=%v sage_enabled=%v", st["name"], st["type"], st["sage_enabled"]), "")
+	if _, ok := st["hpke_ready"].(bool); !ok {
+		s.add("status.hpke_ready", StatusWarn, "hpke_ready not reported", "report hpke_ready (bool) so operators can see whether HPKE can be enabled")
+	} else {
+		s.add("status.hpke_ready", StatusPass, fmt.Sprintf("hpke_ready=%v", st["hpke_ready"]), "")
+	}
+}
+
+// send posts one probe to path through the same transport Root uses.
+func (s *suite) send(ctx context.Context, doer prototx.A2ADoer, path string, payload []byte) (*transport.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	tx := prototx.NewA2ATransport(doer, s.base, false, true).WithOperation(http.MethodPost, path)
+	return tx.Send(ctx, &transport.SecureMessage{
+		ID:        "conf-" + uuid.NewString(),
+		ContextID: "conformance",
+		Payload:   payload,
+		DID:       s.did,
+		Metadata:  map[string]string{"ctype": "application/json"},
+		Role:      "agent",
+	})
+}
+
+func statusOf(resp *transport.Response) int {
+	var hs prototx.ErrHTTPStatus
+	if resp != nil && errors.As(resp.Error, &hs) {
+		return hs.Code
+	}
+	if resp != nil && resp.Success {
+		return http.StatusOK
+	}
+	return 0
+}
+
+// checkOperation runs the round trip against the -op path (Root's named
+// operations, e.g. payment.simulate → POST /simulate) and, when signing,
+// checks that the signature was made over that path.
+func (s *suite) checkOperation(ctx context.Context) {
+	id := "process.operation"
+	if s.opPath == "" {
+		s.add(id, StatusSkip, "no -op given", "")
+		return
+	}
+	if s.sageRequired() && s.signer == nil {
+		s.add(id, StatusSkip, "agent requires signatures", "pass -jwk/-did to run signed checks")
+		return
+	}
+	var doer prototx.A2ADoer = plainDoer{s.http}
+	var capt *captureTransport
+	if s.signer != nil {
+		capt = &captureTransport{next: http.DefaultTransport}
+		doer = s.signingClient(capt)
+	}
+	b, _ := json.Marshal(probeMessage())
+	resp, err := s.send(ctx, doer, s.opPath, b)
+	if err != nil {
+		s.add(id, StatusFail, prototx.ErrorKind(err)+": "+err.Error(), "")
+		return
+	}
+	var out types.AgentMessage
+	switch code := statusOf(resp); {
+	case code/100 != 2:
+		s.add(id, StatusFail, fmt.Sprintf("%s → HTTP %d: %s", s.opPath, code, trim(resp.Data)),
+			"serve every operation Root is configured to call (configs/upstream_operations.json) with the /process contract")
+		return
+	case json.Unmarshal(resp.Data, &out) != nil || out.Type == "":
+		s.add(id, StatusFail, s.opPath+": response is not an AgentMessage: "+trim(resp.Data), "")
+		return
+	}
+	s.add(id, StatusPass, fmt.Sprintf("%s=%s type=%s", s.opName, s.opPath, out.Type), "")
+
+	if capt == nil {
+		s.add("sig.covers_path", StatusSkip, "no client key", "pass -jwk/-did to run signed checks")
+		return
+	}
+	switch {
+	case capt.path != s.opPath:
+		s.add("sig.covers_path", StatusFail, fmt.Sprintf("request went to %q, want %q", capt.path, s.opPath), "")
+	case !strings.Contains(capt.sigInput, `"@path"`):
+		s.add("sig.covers_path", StatusWarn, "Signature-Input does not cover @path",
+			"without @path a signed request can be replayed against another operation")
+	default:
+		s.add("sig.covers_path", StatusPass, "signature covers "+s.opPath, "")
+	}
+}
+
+func (s *suite) checkUnsignedRejected(ctx context.Context) {
+	if !s.sageRequired() {
+		s.add("sig.unsigned_rejected", StatusSkip, "agent reports sage_enabled=false", "")
+		return
+	}
+	b, _ := json.Marshal(probeMessage())
+	resp, err := s.send(ctx, plainDoer{s.http}, "/process", b)
+	if err != nil {
+		s.add("sig.unsigned_rejected", StatusFail, prototx.ErrorKind(err)+": "+err.Error(), "")
+		return
+	}
+	switch code := statusOf(resp); code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		s.add("sig.unsigned_rejected", StatusPass, fmt.Sprintf("HTTP %d", code), "")
+	default:
+		s.add("sig.unsigned_rejected", StatusFail, fmt.Sprintf("HTTP %d for an unsigned request", code),
+			"with sage_enabled=true, reject requests without a valid RFC 9421 Signature with 401")
+	}
+}
+
+func (s *suite) checkRoundTrip(ctx context.Context) {
+	if s.sageRequired() && s.signer == nil {
+		s.add("process.roundtrip", StatusSkip, "agent requires signatures", "pass -jwk/-did to run signed checks")
+		return
+	}
+	in := probeMessage()
+	b, _ := json.Marshal(in)
+	resp, err := s.send(ctx, s.doer(), "/process", b)
+	if err != nil {
+		s.add("process.roundtrip", StatusFail, prototx.ErrorKind(err)+": "+err.Error(), "POST {base}/process must accept an AgentMessage JSON body")
+		return
+	}
+	if code := statusOf(resp); code/100 != 2 {
+		hint := "answer a valid AgentMessage with 2xx"
+		if code == http.StatusUnauthorized && s.signer != nil {
+			hint = "the agent must resolve the client DID (-did) and verify its signature"
+		}
+		s.add("process.roundtrip", StatusFail, fmt.Sprintf("HTTP %d: %s", code, trim(resp.Data)), hint)
+		return
+	}
+	var out types.AgentMessage
+	if err := json.Unmarshal(resp.Data, &out); err != nil {
+		s.add("process.roundtrip", StatusFail, "response is not an AgentMessage: "+trim(resp.Data), "reply with types.AgentMessage JSON (id, from, to, type, content)")
+		return
+	}
+	var probs []string
+	if out.ID == "" {
+		probs = append(probs, "id empty")
+	}
+	if out.From == "" {
+		probs = append(probs, "from empty")
+	}
+	if out.Type != "response" && out.Type != "error" {
+		probs = append(probs, fmt.Sprintf("type=%q (want response|error)", out.Type))
+	}
+	if strings.TrimSpace(out.Content) == "" {
+		probs = append(probs, "content empty")
+	}
+	if len(probs) > 0 {
+		s.add("process.roundtrip", StatusFail, strings.Join(probs, "; "), "Root renders content and branches on type")
+		return
+	}
+	s.add("process.roundtrip", StatusPass, fmt.Sprintf("from=%s type=%s", out.From, out.Type), "")
+	if out.To != in.From {
+		s.add("process.reply_to", StatusWarn, fmt.Sprintf("to=%q, want %q", out.To, in.From), "address the reply to the request's from")
+	} else {
+		s.add("process.reply_to", StatusPass, "to matches request from", "")
+	}
+}
+
+// rawPost posts body to {base}{path} through doer and returns status, headers and body.
+func (s *suite) rawPost(ctx context.Context, doer prototx.A2ADoer, path string, body []byte, hdr map[string]string) (int, http.Header, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.base+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-SAGE-DID", s.did)
+	req.Header.Set("X-SAGE-Message-ID", "conf-"+uuid.NewString())
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	resp, err := doer.Do(ctx, req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, b, nil
+}
+
+func (s *suite) checkTampered(ctx context.Context, id string, recompute bool, hint string) {
+	if !s.sageRequired() {
+		s.add(id, StatusSkip, "agent reports sage_enabled=false", "")
+		return
+	}
+	if s.signer == nil {
+		s.add(id, StatusSkip, "no client key", "pass -jwk/-did to run signed checks")
+		return
+	}
+	tampering := s.signingClient(&tamperTransport{next: http.DefaultTransport, recomputeDigest: recompute})
+	b, _ := json.Marshal(probeMessage())
+	code, _, body, err := s.rawPost(ctx, tampering, "/process", b, nil)
+	switch {
+	case err != nil:
+		s.add(id, StatusFail, err.Error(), "")
+	case code/100 == 4:
+		s.add(id, StatusPass, fmt.Sprintf("HTTP %d", code), "")
+	default:
+		s.add(id, StatusFail, fmt.Sprintf("HTTP %d accepted a body modified after signing: %s", code, trim(body)), hint)
+	}
+}
+
+func (s *suite) checkBadJSON(ctx context.Context) {
+	if s.sageRequired() && s.signer == nil {
+		s.add("error.bad_json", StatusSkip, "agent requires signatures", "pass -jwk/-did to run signed checks")
+		return
+	}
+	code, hdr, body, err := s.rawPost(ctx, s.doer(), "/process", []byte(`{"id": "conf-bad", "content": `), nil)
+	switch {
+	case err != nil:
+		s.add("error.bad_json", StatusFail, err.Error(), "")
+		return
+	case code/100 == 2:
+		s.add("error.bad_json", StatusFail, fmt.Sprintf("HTTP %d for malformed JSON", code), "reject malformed bodies with 400")
+		return
+	case code/100 != 4:
+		s.add("error.bad_json", StatusFail, fmt.Sprintf("HTTP %d for malformed JSON", code), "malformed input is a client error (400), not a server error")
+		return
+	}
+	var env map[string]any
+	if strings.Contains(hdr.Get("Content-Type"), "json") && json.Unmarshal(body, &env) == nil && env["error"] != nil {
+		s.add("error.bad_json", StatusPass, fmt.Sprintf("HTTP %d with error envelope", code), "")
+		return
+	}
+	s.add("error.bad_json", StatusWarn, fmt.Sprintf("HTTP %d, plain body %q", code, trim(body)),
+		`return a JSON envelope {"error":"bad_request","message":…} so Root can classify it`)
+}
+
+func buildResolver() (sagedid.Resolver, error) {
+	cfg := &sagedid.RegistryConfig{
+		RPCEndpoint:     getenvStr("ETH_RPC_URL", "http://127.0.0.1:8545"),
+		ContractAddress: getenvStr("SAGE_REGISTRY_ADDRESS", "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"),
+		MaxRetries:      24,
+	}
+	return dideth.NewEthereumClient(cfg)
+}
+
+func (s *suite) checkHPKE(ctx context.Context) {
+	if s.signer == nil || s.serverDID == "" {
+		s.add("hpke.handshake", StatusSkip, "needs -jwk/-did and -server-did", "")
+		return
+	}
+	resolver, err := buildResolver()
+	if err != nil {
+		s.add("hpke.handshake", StatusFail, "resolver: "+err.Error(), "set ETH_RPC_URL / SAGE_REGISTRY_ADDRESS")
+		return
+	}
+	sMgr := session.NewManager()
+	cli := hpke.NewClient(prototx.NewA2ATransport(s.signer, s.base, true, true), resolver, s.kp, s.did, hpke.DefaultInfoBuilder{}, sMgr)
+	ictx, cancel := context.WithTimeout(ctx, s.timeout)
+	kid, err := cli.Initialize(ictx, "ctx-"+uuid.NewString(), s.did, s.serverDID)
+	cancel()
+	if err != nil || kid == "" {
+		s.add("hpke.handshake", StatusFail, fmt.Sprintf("kid=%q err=%v", kid, err),
+			"answer X-SAGE-HPKE: v1 requests without X-KID as handshakes (transport.Response JSON)")
+		return
+	}
+	s.add("hpke.handshake", StatusPass, "kid="+kid, "")
+
+	sess, ok := sMgr.GetByKeyID(kid)
+	if !ok {
+		s.add("hpke.data", StatusFail, "session not found after handshake", "")
+		return
+	}
+	pt, _ := json.Marshal(probeMessage())
+	ct, err := sess.Encrypt(pt)
+	if err != nil {
+		s.add("hpke.data", StatusFail, "encrypt: "+err.Error(), "")
+		return
+	}
+	code, hdr, body, err := s.rawPost(ctx, s.signer, "/process", ct, map[string]string{
+		"Content-Type": "application/sage+hpke",
+		"X-SAGE-HPKE":  "v1",
+		"X-KID":        kid,
+	})
+	if err != nil || code != http.StatusOK {
+		s.add("hpke.data", StatusFail, fmt.Sprintf("HTTP %d err=%v", code, err), "decrypt with the session for X-KID and answer 200")
+		return
+	}
+	if got := hdr.Get("Content-Digest"); got != a2autil.ComputeContentDigest(body) {
+		s.add("hpke.content_digest", StatusFail, fmt.Sprintf("Content-Digest %q does not match the ciphertext", got),
+			"emit Content-Digest (sha-256) over the encrypted response body")
+	} else {
+		s.add("hpke.content_digest", StatusPass, "matches ciphertext", "")
+	}
+	out, err := sess.Decrypt(body)
+	if err != nil {
+		s.add("hpke.data", StatusFail, "decrypt response: "+err.Error(), "encrypt the response with the same session")
+		return
+	}
+	var msg types.AgentMessage
+	if err := json.Unmarshal(out, &msg); err != nil || msg.Type == "" {
+		s.add("hpke.data", StatusFail, "decrypted response is not an AgentMessage", "")
+		return
+	}
+	s.add("hpke.data", StatusPass, fmt.Sprintf("type=%s X-KID=%s", msg.Type, hdr.Get("X-KID")), "")
+
+	if s.opPath == "" {
+		return
+	}
+	// Same session, non-default operation: Root wraps whatever path it calls
+	ct, err = sess.Encrypt(pt)
+	if err != nil {
+		s.add("hpke.operation", StatusFail, "encrypt: "+err.Error(), "")
+		return
+	}
+	code, _, body, err = s.rawPost(ctx, s.signer, s.opPath, ct, map[string]string{
+		"Content-Type": "application/sage+hpke",
+		"X-SAGE-HPKE":  "v1",
+		"X-KID":        kid,
+	})
+	if err != nil || code != http.StatusOK {
+		s.add("hpke.operation", StatusFail, fmt.Sprintf("%s → HTTP %d err=%v", s.opPath, code, err), "accept HPKE data mode on every operation path")
+		return
+	}
+	if _, err := sess.Decrypt(body); err != nil {
+		s.add("hpke.operation", StatusFail, "decrypt response: "+err.Error(), "")
+		return
+	}
+	s.add("hpke.operation", StatusPass, s.opPath, "")
+}
+
+func trim(b []byte) string {
+	const max = 160
+	s := strings.TrimSpace(string(b))
+	if len(s) > max {
+		s = s[:max] + "…"
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+// Package conformance checks that an agent honours the contract Root relies
+// on: the /status shape, the plaintext /process AgentMessage round trip,
+// RFC 9421 signature and Content-Digest handling (unsigned and tampered
+// requests rejected), the error envelope on bad JSON, an optional named
+// operation path and optional HPKE handshake + data mode.
+//
+// Requests are framed with the same protocol transport and a2a signing client
+// Root uses. cmd/conformance is the command-line front end; agent authors can
+// call Run from their own tests against an httptest server. Part of the
+// supported public surface (see pkg/doc.go): check IDs may be added, existing
+// ones keep their meaning.
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Config selects the candidate agent and the optional checks.
+type Config struct {
+	// Base is the agent's base URL (POST {Base}/process, GET {Base}/status).
+	Base string
+	// JWKFile is a client signing JWK; without it the RFC 9421 checks are skipped.
+	JWKFile string
+	// DID is the client DID for JWKFile (derived from the key when empty).
+	DID string
+	// ServerDID is the candidate's DID, required for HPKE.
+	ServerDID string
+	// HPKE runs the HPKE handshake and data-mode round trip.
+	HPKE bool
+	// Operation/OperationPath repeat the round trip on a named operation path
+	// (e.g. "refund", "/refund"), as Root calls it.
+	Operation, OperationPath string
+	// Timeout is per request (default 10s).
+	Timeout time.Duration
+}
+
+// Report is the result of one run.
+type Report struct {
+	Base    string   `json:"base"`
+	Results []Result `json:"results"`
+	Failed  int      `json:"failed"`
+}
+
+// Run executes the suite against cfg.Base. The error is only for setup
+// problems (unreadable key); failed checks are in the report.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	base := strings.TrimRight(cfg.Base, "/")
+	s, err := newSuite(base, cfg.JWKFile, cfg.DID, cfg.ServerDID, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OperationPath != "" {
+		if !strings.HasPrefix(cfg.OperationPath, "/") {
+			return nil, fmt.Errorf("operation path %q must start with /", cfg.OperationPath)
+		}
+		s.opName, s.opPath = strings.TrimSpace(cfg.Operation), cfg.OperationPath
+	}
+	s.run(ctx, cfg.HPKE)
+	rep := &Report{Base: base, Results: s.results}
+	for _, r := range rep.Results {
+		if r.Status == StatusFail {
+			rep.Failed++
+		}
+	}
+	return rep, nil
+}
+
+// Print writes the human-readable report.
+func (rep *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "conformance report for %s\n\n", rep.Base)
+	for _, r := range rep.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Status, r.ID, r.Detail)
+		if r.Hint != "" && (r.Status == StatusFail || r.Status == StatusWarn) {
+			fmt.Fprintf(tw, "\t\t→ %s\n", r.Hint)
+		}
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\n%d checks, %d failed\n", len(rep.Results), rep.Failed)
+}
+
+func getenvStr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,64 @@
+package conformance_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/agents/payment"
+	"github.com/sage-x-project/sage-multi-agent/pkg/conformance"
+)
+
+// The self-test: our own payment agent must pass the suite it hands to
+// partners (make conformance-self runs the same thing through cmd/conformance).
+func TestSelfPayment(t *testing.T) {
+	t.Setenv("PAYMENT_JWK_FILE", "")
+	t.Setenv("PAYMENT_KEM_JWK_FILE", "")
+	agent, err := payment.NewPaymentAgentWithMode(payment.ModeFull, false)
+	if err != nil {
+		t.Fatalf("payment agent: %v", err)
+	}
+	srv := httptest.NewServer(agent.Handler())
+	defer srv.Close()
+
+	rep, err := conformance.Run(context.Background(), conformance.Config{
+		Base: srv.URL, Operation: "simulate", OperationPath: "/simulate", Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var out bytes.Buffer
+	rep.Print(&out)
+	if rep.Failed > 0 {
+		t.Fatalf("%d checks failed:\n%s", rep.Failed, out.String())
+	}
+
+	passed := map[string]bool{}
+	for _, r := range rep.Results {
+		if r.Status == conformance.StatusPass {
+			passed[r.ID] = true
+		}
+	}
+	for _, id := range []string{"status.reachable", "status.shape", "process.roundtrip", "process.operation"} {
+		if !passed[id] {
+			t.Errorf("%s did not pass:\n%s", id, out.String())
+		}
+	}
+}
+
+// A bad base URL fails the reachability check instead of erroring out.
+func TestUnreachable(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	base := srv.URL
+	srv.Close()
+
+	rep, err := conformance.Run(context.Background(), conformance.Config{Base: base, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rep.Failed == 0 || rep.Results[0].ID != "status.reachable" || rep.Results[0].Status != conformance.StatusFail {
+		t.Fatalf("report %+v", rep)
+	}
+}
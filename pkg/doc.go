@@ -0,0 +1,23 @@
+// Package pkg holds the supported public surface of sage-multi-agent.
+//
+// Everything under pkg/ may be imported by other modules and follows semver:
+// within a major version identifiers are only added, never removed or changed
+// (TestAPISurface in api_test.go and testdata/api/pkg.golden enforce this,
+// see `make api-check`). Replaced identifiers are kept with a "Deprecated:" note
+// until the next major version.
+//
+//   - pkg/types: the AgentMessage wire types, SAGE errors and WebSocket/log
+//     messages.
+//   - pkg/clientapi: the HTTP facade the frontend talks to (POST /send/prompt),
+//     usable as a client SDK in front of a Root agent.
+//   - pkg/conformance: the upstream agent contract checks behind
+//     cmd/conformance, callable from an agent's own tests.
+//   - pkg/fixtures: deterministic, guarded fake DIDs, keys and messages for
+//     tests and demos; pkg/fixtures/fixturetest wires them into a test.
+//   - pkg/lifecycle: the Agent/Upstream start-stop interfaces the agents
+//     implement.
+//
+// Everything else (agents/*, internal/*, cmd/*, tools/*) is an implementation
+// detail and may change in any release. The old import paths types/ and api/
+// remain as deprecated aliases of pkg/types and pkg/clientapi.
+package pkg
@@ -0,0 +1,235 @@
+// Package fixtures points agents and tests at the deterministic offline key
+// set in testdata/fixtures (generated by tools/fixtures): throwaway signing
+// and KEM JWKs for root/payment/medical/planning/client, the key lists the
+// scripts would produce, KEM ownership proofs and a snapshot of what the
+// AgentCard registry would return for them.
+//
+// Tests call fixturetest.Load(t); binaries take --fixtures (Flag). Either way
+// the key env vars (ROOT_JWK_FILE, PAYMENT_JWK_FILE, HPKE_KEYS_FILE, ...) are
+// set to the fixture files, so nothing is generated per run. The test helper
+// lives in its own package so the agent binaries do not link "testing".
+//
+// The keys are public. Guard refuses to start an agent whose key files carry
+// the fixture Marker unless ALLOW_INSECURE_DEMO is set.
+//
+// Part of the supported public surface (see pkg/doc.go), so tests in other
+// modules can use the same fixtures.
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/bootreport"
+)
+
+// Marker is embedded in every fixture key file (JWK kid, key list rows).
+const Marker = "DO-NOT-USE-IN-PRODUCTION"
+
+// Chain and registry the fixture KEM ownership proofs are issued for
+// (anvil's chain id and the default SAGE_REGISTRY_ADDRESS).
+const (
+	ChainID  = 31337
+	Registry = "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"
+)
+
+// RelDir is the fixture directory relative to the repository root.
+const RelDir = "testdata/fixtures"
+
+// Agent is one registry entry in the snapshot.
+type Agent struct {
+	Name         string `json:"name"`
+	DID          string `json:"did"`
+	Address      string `json:"address"`
+	PublicKey    string `json:"publicKey"`              // 0x04 + X + Y
+	X25519Public string `json:"x25519Public,omitempty"` // HPKE servers only
+	Endpoint     string `json:"endpoint"`
+	Active       bool   `json:"active"`
+}
+
+// Snapshot is registry_snapshot.json: the registered agents of the fixture set.
+type Snapshot struct {
+	Warning  string  `json:"warning"`
+	ChainID  string  `json:"chainId"`
+	Registry string  `json:"registry"`
+	Agents   []Agent `json:"agents"`
+}
+
+// ByDID returns the agent registered under did.
+func (s *Snapshot) ByDID(did string) (Agent, bool) {
+	for _, a := range s.Agents {
+		if strings.EqualFold(a.DID, strings.TrimSpace(did)) {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// ByName returns the agent named name.
+func (s *Snapshot) ByName(name string) (Agent, bool) {
+	for _, a := range s.Agents {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// LoadSnapshot reads a registry snapshot file.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("fixtures: %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Dir locates the fixture directory: SAGE_FIXTURES_DIR, else RelDir under the
+// nearest parent of the working directory that has a go.mod.
+func Dir() (string, error) {
+	if d := strings.TrimSpace(os.Getenv("SAGE_FIXTURES_DIR")); d != "" {
+		return filepath.Abs(d)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for d := wd; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			fd := filepath.Join(d, RelDir)
+			if _, err := os.Stat(fd); err != nil {
+				return "", fmt.Errorf("fixtures: %s: %w", fd, err)
+			}
+			return fd, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", errors.New("fixtures: no go.mod above " + wd + " (set SAGE_FIXTURES_DIR)")
+		}
+	}
+}
+
+// Env returns the env vars that wire the agents to the fixtures in dir.
+func Env(dir string) map[string]string {
+	p := func(parts ...string) string { return filepath.Join(append([]string{dir}, parts...)...) }
+	return map[string]string{
+		"ROOT_JWK_FILE":         p("keys", "root.jwk"),
+		"PAYMENT_JWK_FILE":      p("keys", "payment.jwk"),
+		"PAYMENT_KEM_JWK_FILE":  p("keys", "kem", "payment.jwk"),
+		"MEDICAL_JWK_FILE":      p("keys", "medical.jwk"),
+		"MEDICAL_KEM_JWK_FILE":  p("keys", "kem", "medical.jwk"),
+		"PLANNING_JWK_FILE":     p("keys", "planning.jwk"),
+		"CLIENT_JWK_FILE":       p("keys", "client.jwk"),
+		"HPKE_KEYS_FILE":        p("merged_agent_keys.json"),
+		"ROOT_HPKE_KEYS":        p("merged_agent_keys.json"),
+		"HPKE_KEM_PUBLIC_FILE":  p("keys", "kem", "kem_all_keys.json"),
+		"HPKE_KEM_PROOFS_FILE":  p("keys", "kem", "kem_ownership.json"),
+		"SAGE_REGISTRY_ADDRESS": Registry,
+		"SAGE_FIXTURES_DIR":     dir,
+	}
+}
+
+// Set is a loaded fixture set.
+type Set struct {
+	Dir      string
+	Env      map[string]string
+	Snapshot *Snapshot
+}
+
+// Path joins parts onto the fixture directory.
+func (s *Set) Path(parts ...string) string {
+	return filepath.Join(append([]string{s.Dir}, parts...)...)
+}
+
+// Open locates and reads the fixture set without touching the environment.
+func Open() (*Set, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	snap, err := LoadSnapshot(filepath.Join(dir, "registry_snapshot.json"))
+	if err != nil {
+		return nil, err
+	}
+	env := Env(dir)
+	if c, ok := snap.ByName("client"); ok {
+		env["CLIENT_DID"] = c.DID
+	}
+	return &Set{Dir: dir, Env: env, Snapshot: snap}, nil
+}
+
+// Apply sets the fixture env vars for the rest of the process.
+func Apply() (*Set, error) {
+	s, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Env {
+		if err := os.Setenv(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Flag registers --fixtures on fs and, when it is among args, applies the
+// fixtures right away. Call it before defining flags whose defaults come
+// from the env so those defaults see the fixture paths.
+func Flag(fs *flag.FlagSet, args []string) (*Set, error) {
+	fs.Bool("fixtures", false, "use the offline fixture keys in "+RelDir+" (requires ALLOW_INSECURE_DEMO)")
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		switch strings.TrimLeft(a, "-") {
+		case "fixtures", "fixtures=true", "fixtures=1":
+			return Apply()
+		}
+	}
+	return nil, nil
+}
+
+// InsecureDemoAllowed reports whether ALLOW_INSECURE_DEMO is on.
+func InsecureDemoAllowed() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ALLOW_INSECURE_DEMO"))) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// Guard returns an error when any of paths is a fixture key file and
+// ALLOW_INSECURE_DEMO is not set. Empty and unreadable paths are skipped
+// (the component reports those itself). With ALLOW_INSECURE_DEMO on, it
+// records a boot report warning instead.
+func Guard(component string, paths ...string) error {
+	if InsecureDemoAllowed() {
+		bootreport.Warnf("%s: ALLOW_INSECURE_DEMO is on; fixture keys are accepted", component)
+		return nil
+	}
+	for _, p := range paths {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if bytes.Contains(b, []byte(Marker)) {
+			return fmt.Errorf("%s: refusing to start with fixture keys (%s is marked %s); set ALLOW_INSECURE_DEMO=1 for local demos and tests", component, p, Marker)
+		}
+	}
+	return nil
+}
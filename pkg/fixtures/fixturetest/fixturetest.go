@@ -0,0 +1,24 @@
+// Package fixturetest wires the fixture key set (pkg/fixtures) into a test.
+package fixturetest
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
+)
+
+// Load points the test's environment at the fixtures (restored when t ends)
+// and allows them past fixtures.Guard. Like t.Setenv, it cannot be used in
+// parallel tests.
+func Load(t testing.TB) *fixtures.Set {
+	t.Helper()
+	s, err := fixtures.Open()
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+	for k, v := range s.Env {
+		t.Setenv(k, v)
+	}
+	t.Setenv("ALLOW_INSECURE_DEMO", "1")
+	return s
+}
@@ -0,0 +1,33 @@
+// Package lifecycle names the start/stop contract the agents in this module
+// share, so embedders (cmd/*, in-process test harnesses, the conformance
+// runner) can manage them without depending on a concrete agent type.
+//
+// *root.RootAgent satisfies Agent; *payment.PaymentAgent,
+// *medical.MedicalAgent and *planning.PlanningAgent satisfy Upstream. Part of the supported public
+// surface (see pkg/doc.go).
+package lifecycle
+
+import (
+	"context"
+	"net/http"
+)
+
+// Agent is a running component that can report where it listens and be shut
+// down gracefully.
+type Agent interface {
+	// Addr returns the bound listener address, "" before the agent started.
+	// Useful with port 0.
+	Addr() string
+	// Shutdown stops accepting requests, waits for in-flight ones until ctx
+	// ends and releases background resources.
+	Shutdown(ctx context.Context) error
+}
+
+// Upstream is an agent Root calls over HTTP. Start blocks serving on addr;
+// Handler exposes the same routes for mounting in an existing server or an
+// httptest.Server.
+type Upstream interface {
+	Agent
+	Start(addr string) error
+	Handler() http.Handler
+}
@@ -0,0 +1,7 @@
+// Package types defines the messages exchanged between the client, Root and
+// the upstream agents: AgentMessage (the /process body), the SAGE
+// verification error envelope, and the WebSocket and log messages.
+//
+// Part of the supported public surface (see pkg/doc.go); it was previously
+// imported as github.com/sage-x-project/sage-multi-agent/types.
+package types
@@ -13,6 +13,9 @@ type PromptRequest struct {
 	SAGEEnabled bool             `json:"sageEnabled,omitempty"`
 	Scenario    string           `json:"scenario,omitempty"`
 	Metadata    *RequestMetadata `json:"metadata,omitempty"`
+
+	// AllowTruncation trims an over-limit prompt instead of rejecting it (413)
+	AllowTruncation bool `json:"allowTruncation,omitempty"`
 }
 
 // RequestMetadata contains metadata for the request
@@ -61,14 +64,26 @@ type AgentLog struct {
 	Level          string `json:"level,omitempty"` // "info", "warning", "error", "debug"
 }
 
-// SAGEVerificationResult represents the result of SAGE protocol verification
+// Three-valued verification outcome as reported by Root's X-SAGE-Verified /
+// X-SAGE-Signature-Valid headers.
+const (
+	VerificationTrue          = "true"
+	VerificationFalse         = "false"
+	VerificationNotApplicable = "not-applicable"
+)
+
+// SAGEVerificationResult represents the result of SAGE protocol verification.
+// Verified/SignatureValid are true only when the state is "true"; the
+// *State fields carry the full true/false/not-applicable value.
 type SAGEVerificationResult struct {
-	Verified       bool              `json:"verified"`
-	AgentDID       string            `json:"agentDid,omitempty"`
-	SignatureValid bool              `json:"signatureValid"`
-	Timestamp      int64             `json:"timestamp,omitempty"`
-	Details        map[string]string `json:"details,omitempty"`
-	Error          string            `json:"error,omitempty"`
+	Verified            bool              `json:"verified"`
+	VerifiedState       string            `json:"verifiedState,omitempty"`
+	AgentDID            string            `json:"agentDid,omitempty"`
+	SignatureValid      bool              `json:"signatureValid"`
+	SignatureValidState string            `json:"signatureValidState,omitempty"`
+	Timestamp           int64             `json:"timestamp,omitempty"`
+	Details             map[string]string `json:"details,omitempty"`
+	Error               string            `json:"error,omitempty"`
 }
 
 // ErrorDetail represents detailed error information
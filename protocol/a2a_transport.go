@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/sage-x-project/sage-multi-agent/internal/gzipx"
 	"github.com/sage-x-project/sage/pkg/agent/transport"
 )
 
@@ -18,23 +20,86 @@ type A2ADoer interface {
 }
 
 // A2ATransport:
-//   - Always POST to {baseURL}/process
+//   - POST to {baseURL}/process, or the method/path set by WithOperation
+//     (GET/HEAD/DELETE operations carry no body)
 //   - Never add Signature/Content-Digest directly (A2ADoer handles signing)
 //   - Modes:
 //     - Handshake: SecureMessage(JSON) + X-SAGE-HPKE: v1 (no KID)
 //     - HPKE data: when msg.Metadata["hpke_kid"] exists, send payload as-is with (Content-Type: application/sage+hpke, X-SAGE-HPKE, X-KID)
 //     - Plain data: send payload as-is with (Content-Type: application/json)
+//   - Optional gzip (WithGzip): plain data at or above the threshold is sent with
+//     Content-Encoding: gzip. It is compressed before the doer signs, so
+//     Content-Digest/RFC 9421 cover the compressed bytes. HPKE is never compressed.
 type A2ATransport struct {
     doer            A2ADoer
     baseURL         string
     hpkeHandshake   bool
     emitA2AHeaders  bool // when false, do NOT emit X-SAGE-* id/context/task DID headers
+    authority       string // optional Host (@authority) to sign and send instead of baseURL's host
+    gzipMin         int    // compress plain bodies of at least this many bytes (0 = off)
+    method          string // request method (default POST)
+    path            string // request path under baseURL (default /process)
+    headers         http.Header // extra request headers (WithHeader)
 }
 
 func NewA2ATransport(doer A2ADoer, baseURL string, hpkeHandshake bool, emitHeaders bool) *A2ATransport {
     return &A2ATransport{doer: doer, baseURL: strings.TrimRight(baseURL, "/"), hpkeHandshake: hpkeHandshake, emitA2AHeaders: emitHeaders}
 }
 
+// WithAuthority sets the Host header (and therefore the signed @authority) for
+// every request, e.g. the upstream's own authority when a gateway sits between.
+// Empty keeps the host of baseURL.
+func (t *A2ATransport) WithAuthority(authority string) *A2ATransport {
+	t.authority = strings.TrimSpace(authority)
+	return t
+}
+
+// WithGzip compresses plain (non-HPKE) bodies of at least minBytes; 0 disables.
+func (t *A2ATransport) WithGzip(minBytes int) *A2ATransport {
+	t.gzipMin = minBytes
+	return t
+}
+
+// WithOperation sends to path (relative to baseURL) with method instead of
+// POST /process. The doer signs the request as sent, so @method and @path
+// are the operation's. Empty values keep the defaults.
+func (t *A2ATransport) WithOperation(method, path string) *A2ATransport {
+	t.method = strings.ToUpper(strings.TrimSpace(method))
+	if p := strings.TrimSpace(path); p != "" {
+		t.path = "/" + strings.TrimLeft(p, "/")
+	}
+	return t
+}
+
+// WithHeader adds a request header, e.g. a debug trace. Empty values are ignored.
+func (t *A2ATransport) WithHeader(key, value string) *A2ATransport {
+	if strings.TrimSpace(value) == "" {
+		return t
+	}
+	if t.headers == nil {
+		t.headers = http.Header{}
+	}
+	t.headers.Set(key, value)
+	return t
+}
+
+// Target returns the method and URL requests are sent to.
+func (t *A2ATransport) Target() (method, url string) {
+	method, path := t.method, t.path
+	if method == "" {
+		method = http.MethodPost
+	}
+	if path == "" {
+		path = "/process"
+	}
+	return method, t.baseURL + path
+}
+
+// bodyless reports methods that are sent without a request body.
+func bodyless(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete
+}
+
 func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (*transport.Response, error) {
 	if t.doer == nil || t.baseURL == "" {
 		return nil, fmt.Errorf("transport not initialized")
@@ -50,6 +115,7 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
 		useHPKE     = false
 		kid         string
 	)
+	method, url := t.Target()
 
 	if t.hpkeHandshake {
     // Handshake: send the entire SecureMessage as JSON
@@ -58,6 +124,10 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
 			return nil, fmt.Errorf("marshal secure message: %w", err)
 		}
         useHPKE = true // X-SAGE-HPKE: v1 (no KID)
+	} else if bodyless(method) {
+		if msg.Metadata != nil && msg.Metadata["hpke_kid"] != "" {
+			return nil, fmt.Errorf("hpke: %s %s carries no body to encrypt", method, url)
+		}
 	} else {
 		if len(msg.Payload) == 0 {
 			return nil, fmt.Errorf("empty payload")
@@ -73,12 +143,31 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
 		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/process", bytes.NewReader(body))
+	// Compress before signing: the digest must cover the bytes on the wire
+	compressed := false
+	if t.gzipMin > 0 && !useHPKE && len(body) >= t.gzipMin {
+		body = gzipx.Compress(body)
+		compressed = true
+	}
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, rd)
 	if err != nil {
 		return nil, fmt.Errorf("new request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", contentType)
+	if t.authority != "" {
+		req.Host = t.authority
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	if useHPKE {
 		req.Header.Set("X-SAGE-HPKE", "v1")
 		if kid != "" {
@@ -100,18 +189,33 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
             req.Header.Set("X-SAGE-Task-ID", msg.TaskID)
         }
     }
-	req.ContentLength = int64(len(body))
-	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
 
     // Pass through A2A signing + DID middleware
 	resp, err := t.doer.Do(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("http send (a2a): %w", err)
+		return nil, fmt.Errorf("http send (a2a): %w", classifyDoErr(err))
 	}
 
 	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("http send (a2a): %w", ErrTimeout{Err: err})
+		}
+		return nil, fmt.Errorf("http send (a2a): %w", ErrBodyRead{Code: resp.StatusCode, Err: err})
+	}
+	var statusErr error
+	if resp.StatusCode/100 != 2 {
+		statusErr = ErrHTTPStatus{Code: resp.StatusCode, Body: respBody, Headers: resp.Header.Clone()}
+	}
 
     // Handshake expects a transport.Response JSON
 	if t.hpkeHandshake {
@@ -128,7 +232,7 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
 				MessageID: msg.ID,
 				TaskID:    msg.TaskID,
 				Data:      respBody,
-				Error:     nil,
+				Error:     statusErr,
 			}, nil
 		}
 		out := &transport.Response{
@@ -150,12 +254,12 @@ func (t *A2ATransport) Send(ctx context.Context, msg *transport.SecureMessage) (
 		return out, nil
 	}
 
-    // Data mode: forward raw body as Response.Data
+    // Data mode: forward raw body as Response.Data (any status; non-2xx carries ErrHTTPStatus)
 	return &transport.Response{
 		Success:   resp.StatusCode/100 == 2,
 		MessageID: msg.ID,
 		TaskID:    msg.TaskID,
 		Data:      respBody,
-		Error:     nil,
+		Error:     statusErr,
 	}, nil
 }
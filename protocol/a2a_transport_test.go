@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// recordDoer stands in for the signing doer: whatever it is handed is what
+// gets signed (@method, @path, @authority, Content-Digest over the body).
+type recordDoer struct {
+	req  *http.Request
+	body []byte
+}
+
+func (d *recordDoer) Do(_ context.Context, req *http.Request) (*http.Response, error) {
+	d.req = req
+	if req.Body != nil {
+		d.body, _ = io.ReadAll(req.Body)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+}
+
+// The request handed to the doer carries the operation's method and path, so
+// the signature covers them, and HPKE data is sent the same way off /process.
+func TestOperationRequestAsSigned(t *testing.T) {
+	cases := []struct {
+		name, method, path  string
+		kid                 string
+		wantMethod, wantURL string
+		wantHPKE            bool
+	}{
+		{"default", "", "", "", http.MethodPost, "/process", false},
+		{"post op", "post", "refund", "", http.MethodPost, "/refund", false},
+		{"get op", http.MethodGet, "/lookup/idem-1", "", http.MethodGet, "/lookup/idem-1", false},
+		{"hpke off /process", http.MethodPost, "/simulate", "kid-1", http.MethodPost, "/simulate", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &recordDoer{}
+			tx := NewA2ATransport(d, "http://gw:8080/payment/", false, true).
+				WithAuthority("payment.internal:19083").
+				WithOperation(tc.method, tc.path)
+			msg := &transport.SecureMessage{ID: "m1", Payload: []byte("sealed-or-json"), Metadata: map[string]string{}}
+			if tc.kid != "" {
+				msg.Metadata["hpke_kid"] = tc.kid
+			}
+			if _, err := tx.Send(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+			if d.req.Method != tc.wantMethod || d.req.URL.Path != "/payment"+tc.wantURL {
+				t.Fatalf("signed %s %s, want %s /payment%s", d.req.Method, d.req.URL.Path, tc.wantMethod, tc.wantURL)
+			}
+			if d.req.Host != "payment.internal:19083" {
+				t.Fatalf("@authority %q", d.req.Host)
+			}
+			if tc.wantMethod == http.MethodGet {
+				if len(d.body) != 0 {
+					t.Fatalf("GET carried a body: %q", d.body)
+				}
+				return
+			}
+			if string(d.body) != "sealed-or-json" {
+				t.Fatalf("body %q", d.body)
+			}
+			hpke := d.req.Header.Get("X-SAGE-HPKE") == "v1" && d.req.Header.Get("X-KID") == tc.kid &&
+				d.req.Header.Get("Content-Type") == "application/sage+hpke"
+			if hpke != tc.wantHPKE {
+				t.Fatalf("HPKE headers %v, want %v: %v", hpke, tc.wantHPKE, d.req.Header)
+			}
+		})
+	}
+}
+
+// A bodyless operation cannot carry an HPKE payload and is refused unsent.
+func TestOperationHPKEBodyless(t *testing.T) {
+	d := &recordDoer{}
+	tx := NewA2ATransport(d, "http://payment", false, false).WithOperation(http.MethodGet, "/lookup/idem-1")
+	_, err := tx.Send(context.Background(), &transport.SecureMessage{Metadata: map[string]string{"hpke_kid": "kid-1"}})
+	if err == nil || d.req != nil {
+		t.Fatalf("err=%v sent=%v", err, d.req != nil)
+	}
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Typed transport errors. A2ATransport.Send returns ErrConnect/ErrTimeout/
+// ErrBodyRead (wrapped with %w) when no usable HTTP exchange happened; any
+// completed exchange yields a Response, with Response.Error set to
+// ErrHTTPStatus for non-2xx statuses. Use errors.As to branch on them.
+
+// ErrConnect: the request never reached the upstream (dial/TLS/refused/DNS).
+type ErrConnect struct {
+	Err error
+}
+
+func (e ErrConnect) Error() string { return "connect: " + e.Err.Error() }
+func (e ErrConnect) Unwrap() error { return e.Err }
+
+// ErrTimeout: the exchange did not finish in time (deadline or net timeout).
+type ErrTimeout struct {
+	Err error
+}
+
+func (e ErrTimeout) Error() string { return "timeout: " + e.Err.Error() }
+func (e ErrTimeout) Unwrap() error { return e.Err }
+
+// ErrHTTPStatus: the upstream answered with a non-2xx status.
+type ErrHTTPStatus struct {
+	Code    int
+	Body    []byte
+	Headers http.Header
+}
+
+func (e ErrHTTPStatus) Error() string {
+	const max = 240
+	b := e.Body
+	if len(b) > max {
+		b = b[:max]
+	}
+	return fmt.Sprintf("upstream status %d: %s", e.Code, b)
+}
+
+// ErrBodyRead: the upstream answered but its body could not be read.
+type ErrBodyRead struct {
+	Code int
+	Err  error
+}
+
+func (e ErrBodyRead) Error() string {
+	return fmt.Sprintf("read body (status %d): %v", e.Code, e.Err)
+}
+func (e ErrBodyRead) Unwrap() error { return e.Err }
+
+// classifyDoErr maps an error from A2ADoer.Do to a typed error. Errors raised
+// before the HTTP round-trip (e.g. signing) are returned unchanged.
+func classifyDoErr(err error) error {
+	var ne net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return ErrTimeout{Err: err}
+	}
+	var ue *url.Error
+	var oe *net.OpError
+	if errors.As(err, &ue) || errors.As(err, &oe) {
+		return ErrConnect{Err: err}
+	}
+	return err
+}
+
+// ErrorKind names a typed transport error for logs/metadata ("" if untyped).
+func ErrorKind(err error) string {
+	var (
+		ce ErrConnect
+		te ErrTimeout
+		he ErrHTTPStatus
+		be ErrBodyRead
+	)
+	switch {
+	case errors.As(err, &te):
+		return "timeout"
+	case errors.As(err, &ce):
+		return "connect"
+	case errors.As(err, &he):
+		return "http_status"
+	case errors.As(err, &be):
+		return "body_read"
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage/pkg/agent/transport"
+)
+
+// plainDoer sends without signing.
+type plainDoer struct{}
+
+func (plainDoer) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
+
+func sendTo(ctx context.Context, url string) (*transport.Response, error) {
+	tx := NewA2ATransport(plainDoer{}, url, false, false)
+	return tx.Send(ctx, &transport.SecureMessage{ID: "m1", Payload: []byte(`{}`), Metadata: map[string]string{}})
+}
+
+func TestTypedErrors(t *testing.T) {
+	t.Run("refused", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		_, err = sendTo(context.Background(), "http://"+addr)
+		var ce ErrConnect
+		if !errors.As(err, &ce) || ErrorKind(err) != "connect" {
+			t.Fatalf("err %v (kind %q)", err, ErrorKind(err))
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+		defer srv.Close()
+		defer close(release)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		_, err := sendTo(ctx, srv.URL)
+		var te ErrTimeout
+		if !errors.As(err, &te) || ErrorKind(err) != "timeout" {
+			t.Fatalf("err %v (kind %q)", err, ErrorKind(err))
+		}
+	})
+
+	t.Run("status with body", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("WWW-Authenticate", "Signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+		}))
+		defer srv.Close()
+		resp, err := sendTo(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("a completed exchange returned %v", err)
+		}
+		var he ErrHTTPStatus
+		if resp.Success || !errors.As(resp.Error, &he) || ErrorKind(resp.Error) != "http_status" {
+			t.Fatalf("response %+v", resp)
+		}
+		if he.Code != http.StatusUnauthorized || string(he.Body) != `{"error":"unauthorized"}` || he.Headers.Get("WWW-Authenticate") != "Signature" {
+			t.Fatalf("status error %+v", he)
+		}
+		if string(resp.Data) != `{"error":"unauthorized"}` {
+			t.Fatalf("data %q", resp.Data)
+		}
+	})
+
+	t.Run("unreadable body", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("short"))
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}))
+		defer srv.Close()
+		_, err := sendTo(context.Background(), srv.URL)
+		var be ErrBodyRead
+		if !errors.As(err, &be) || be.Code != http.StatusOK || ErrorKind(err) != "body_read" {
+			t.Fatalf("err %v (kind %q)", err, ErrorKind(err))
+		}
+	})
+}
+
+// Errors raised before the round-trip stay untyped; long bodies are cut in
+// the message but kept whole in the error.
+func TestClassifyAndFormat(t *testing.T) {
+	sign := errors.New("sign: no key")
+	if got := classifyDoErr(sign); got != sign || ErrorKind(got) != "" {
+		t.Fatalf("signing error classified as %v", got)
+	}
+	body := strings.Repeat("x", 500)
+	he := ErrHTTPStatus{Code: 502, Body: []byte(body)}
+	if msg := he.Error(); !strings.HasPrefix(msg, "upstream status 502: ") || len(msg) != len("upstream status 502: ")+240 {
+		t.Fatalf("message %q", msg)
+	}
+	if len(he.Body) != 500 {
+		t.Fatal("body truncated")
+	}
+}
@@ -27,7 +27,7 @@ kill_port "$GATEWAY_PORT"
 
 echo "[start] Gateway (PASS-THROUGH) :${GATEWAY_PORT} -> payment :${EXT_PAYMENT_PORT}"
 
-nohup go run ./cmd/gateway/main.go \
+nohup go run ./cmd/gateway \
   -listen ":${GATEWAY_PORT}" \
   -upstream "http://${HOST}:${EXT_PAYMENT_PORT}" \
   -attack-msg "" \
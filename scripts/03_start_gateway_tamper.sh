@@ -29,7 +29,7 @@ kill_port "$GATEWAY_PORT"
 echo "[start] Gateway (TAMPER) :${GATEWAY_PORT} -> payment :${EXT_PAYMENT_PORT}"
 echo "        attack-msg length: ${#ATTACK_MESSAGE}"
 
-nohup go run ./cmd/gateway/main.go \
+nohup go run ./cmd/gateway \
   -listen ":${GATEWAY_PORT}" \
   -upstream "http://${HOST}:${EXT_PAYMENT_PORT}" \
   -attack-msg "${ATTACK_MESSAGE}" \
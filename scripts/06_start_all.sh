@@ -11,6 +11,11 @@ cd "$ROOT_DIR"
 
 mkdir -p logs pids
 
+# Boot reports: every binary writes $BOOT_REPORT_DIR/<component>.json; merged at the end
+export BOOT_REPORT_DIR="${BOOT_REPORT_DIR:-logs/boot}"
+mkdir -p "$BOOT_REPORT_DIR"
+for n in medical payment gateway root client system; do rm -f "$BOOT_REPORT_DIR/$n.json"; done
+
 # ---------- Helpers ----------
 kill_port() {
   local port="$1"; local pids
@@ -190,7 +195,7 @@ done
 # ---------- (B) Gateway ----------
 if [[ "$GATEWAY_MODE" == "pass" ]]; then
   echo "[mode] Gateway PASS-THROUGH"
-  nohup env -u ATTACK_MESSAGE go run ./cmd/gateway/main.go \
+  nohup env -u ATTACK_MESSAGE go run ./cmd/gateway \
     -listen ":${GATEWAY_PORT}" \
     -pay-upstream "http://${HOST}:${EXT_PAYMENT_PORT}" \
     -med-upstream "http://${HOST}:${EXT_MEDICAL_PORT}" \
@@ -198,7 +203,7 @@ if [[ "$GATEWAY_MODE" == "pass" ]]; then
     >"logs/gateway.log" 2>&1 & echo $! > "pids/gateway.pid"
 else
   echo "[mode] Gateway TAMPER"
-  nohup env ATTACK_MESSAGE="${ATTACK_MESSAGE}" go run ./cmd/gateway/main.go \
+  nohup env ATTACK_MESSAGE="${ATTACK_MESSAGE}" go run ./cmd/gateway \
     -listen ":${GATEWAY_PORT}" \
     -pay-upstream "http://${HOST}:${EXT_PAYMENT_PORT}" \
     -med-upstream "http://${HOST}:${EXT_MEDICAL_PORT}" \
@@ -252,3 +257,11 @@ for n in medical payment gateway root client; do
   [[ -f "pids/${n}.pid" ]] && printf "  %-8s %s\n" "$n" "$(cat "pids/${n}.pid")"
 done
 echo "Logs: ./logs/*.log"
+
+# ---------- (E) System boot summary ----------
+# Prints each component's init warnings; the reports present are the components that came up
+if go run ./cmd/bootreport merge -o "$BOOT_REPORT_DIR/system.json" "$BOOT_REPORT_DIR"/*.json; then
+  echo "Boot summary: $BOOT_REPORT_DIR/system.json (compare: go run ./cmd/bootreport diff <known-good.json> $BOOT_REPORT_DIR/system.json)"
+else
+  echo "[WARN] boot summary not written (missing reports in $BOOT_REPORT_DIR)"
+fi
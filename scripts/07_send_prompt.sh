@@ -175,6 +175,8 @@ send_once() {
   echo "========== Client API Response (summary) =========="
   if command -v jq >/dev/null 2>&1; then
     jq '{response:.response, verification:(.SAGEVerification // .sageVerification // null), metadata:.metadata, logs:(.logs // null)}' "$RESP_PAYLOAD" 2>/dev/null || cat "$RESP_PAYLOAD"
+    # true / false / not-applicable, as derived by Root from the actual upstream hops
+    jq -r '(.SAGEVerification // .sageVerification // {}) | "[SAGE] verified=\(.verifiedState // "n/a")  signatureValid=\(.signatureValidState // "n/a")"' "$RESP_PAYLOAD" 2>/dev/null || true
   else
     cat "$RESP_PAYLOAD"
   fi
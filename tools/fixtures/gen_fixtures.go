@@ -0,0 +1,165 @@
+//go:build fixtures
+// +build fixtures
+
+// Deterministic offline fixture set (testdata/fixtures).
+//
+// Every key is derived from a fixed seed string, so re-running this tool
+// reproduces the committed files byte for byte. The keys are public by
+// construction: DO NOT USE THEM IN PRODUCTION. Agents refuse to start with
+// them unless ALLOW_INSECURE_DEMO is set (pkg/fixtures.Guard).
+//
+//	go run -tags fixtures ./tools/fixtures [-out testdata/fixtures]
+//
+// Writes, in the layout the key tools and scripts produce:
+//   - keys/<name>.jwk                secp256k1 private JWK (signing)
+//   - keys/kem/<name>.jwk            X25519 private JWK (HPKE servers)
+//   - generated_agent_keys.json      signing summary
+//   - keys/all_keys.json             inbound verifier list
+//   - keys/kem/generated_kem_keys.json, keys/kem/kem_all_keys.json
+//   - keys/kem/kem_ownership.json    KEM ownership proofs (chain 31337, default registry)
+//   - merged_agent_keys.json         signing + KEM rows (HPKE_KEYS_FILE)
+//   - registry_snapshot.json         what the AgentCard registry would return
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+	"github.com/sage-x-project/sage-multi-agent/pkg/fixtures"
+)
+
+const seed = "sage-multi-agent/fixtures/v1"
+
+// agents: name, default endpoint, whether it serves HPKE (has a KEM key).
+var agents = []struct {
+	Name     string
+	Endpoint string
+	KEM      bool
+}{
+	{"root", "http://localhost:18080", false},
+	{"payment", "http://localhost:19083", true},
+	{"medical", "http://localhost:19082", true},
+	{"planning", "http://localhost:18081", false},
+	{"client", "http://localhost:8086", false},
+}
+
+func b64u(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func writeJSON(path string, v any, mode os.FileMode) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("encode %s: %v", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("mkdir %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), mode); err != nil {
+		log.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func main() {
+	out := flag.String("out", "testdata/fixtures", "output directory")
+	flag.Parse()
+
+	chainID := big.NewInt(fixtures.ChainID)
+	registry := common.HexToAddress(fixtures.Registry)
+
+	var (
+		summary, merged []map[string]any
+		verifiers       []map[string]any
+		kemPriv, kemPub []map[string]any
+		proofs          []kemproof.Proof
+		snapshot        []fixtures.Agent
+	)
+	for _, a := range agents {
+		// secp256k1 signing key
+		d := sha256.Sum256([]byte(seed + "/" + a.Name + "/secp256k1"))
+		priv, err := gethcrypto.ToECDSA(d[:])
+		if err != nil {
+			log.Fatalf("%s: %v", a.Name, err)
+		}
+		pub := gethcrypto.FromECDSAPub(&priv.PublicKey)
+		addr := gethcrypto.PubkeyToAddress(priv.PublicKey)
+		did := "did:sage:ethereum:" + addr.Hex()
+		kid := "fixture:" + a.Name + ":" + fixtures.Marker
+		writeJSON(filepath.Join(*out, "keys", a.Name+".jwk"), map[string]string{
+			"kty": "EC", "crv": "secp256k1", "kid": kid,
+			"x": b64u(pub[1:33]), "y": b64u(pub[33:65]), "d": b64u(gethcrypto.FromECDSA(priv)),
+		}, 0o600)
+
+		row := map[string]any{
+			"name": a.Name, "did": did, "address": addr.Hex(),
+			"publicKey":  "0x" + hex.EncodeToString(pub),
+			"privateKey": "0x" + hex.EncodeToString(gethcrypto.FromECDSA(priv)),
+			"fixture":    fixtures.Marker,
+		}
+		summary = append(summary, row)
+		verifiers = append(verifiers, map[string]any{"DID": did, "PublicKey": "0x" + hex.EncodeToString(pub), "Type": "secp256k1"})
+
+		m := map[string]any{"x25519Public": "", "x25519Private": ""}
+		for k, v := range row {
+			m[k] = v
+		}
+		snap := fixtures.Agent{
+			Name: a.Name, DID: did, Address: addr.Hex(), PublicKey: "0x" + hex.EncodeToString(pub),
+			Endpoint: a.Endpoint, Active: true,
+		}
+
+		if a.KEM {
+			xd := sha256.Sum256([]byte(seed + "/" + a.Name + "/x25519"))
+			xpriv := xd[:]
+			xpub, err := curve25519.X25519(xpriv, curve25519.Basepoint)
+			if err != nil {
+				log.Fatalf("%s x25519: %v", a.Name, err)
+			}
+			writeJSON(filepath.Join(*out, "keys", "kem", a.Name+".jwk"), map[string]string{
+				"kty": "OKP", "crv": "X25519", "x": b64u(xpub), "d": b64u(xpriv),
+				"kid": did, "use": "enc", "alg": "X25519",
+			}, 0o600)
+			xpubHex, xprivHex := "0x"+hex.EncodeToString(xpub), "0x"+hex.EncodeToString(xpriv)
+			m["x25519Public"], m["x25519Private"] = xpubHex, xprivHex
+			snap.X25519Public = xpubHex
+			kemPriv = append(kemPriv, map[string]any{"name": a.Name, "did": did, "address": addr.Hex(), "x25519Private": xprivHex, "x25519Public": xpubHex})
+			kemPub = append(kemPub, map[string]any{"name": a.Name, "did": did, "address": addr.Hex(), "x25519Public": xpubHex})
+
+			sig, err := gethcrypto.Sign(kemproof.Digest(xpub, chainID, registry, addr), priv)
+			if err != nil {
+				log.Fatalf("%s proof: %v", a.Name, err)
+			}
+			sig[64] += 27
+			proofs = append(proofs, kemproof.Proof{
+				Name: a.Name, DID: did, X25519Public: xpubHex, Owner: addr.Hex(),
+				ChainID: chainID.String(), Registry: registry.Hex(), Signature: "0x" + hex.EncodeToString(sig),
+			})
+		}
+		merged = append(merged, m)
+		snapshot = append(snapshot, snap)
+	}
+
+	writeJSON(filepath.Join(*out, "generated_agent_keys.json"), summary, 0o600)
+	writeJSON(filepath.Join(*out, "merged_agent_keys.json"), merged, 0o600)
+	writeJSON(filepath.Join(*out, "keys", "all_keys.json"), map[string]any{"agents": verifiers}, 0o644)
+	writeJSON(filepath.Join(*out, "keys", "kem", "generated_kem_keys.json"), map[string]any{"agents": kemPriv}, 0o600)
+	writeJSON(filepath.Join(*out, "keys", "kem", "kem_all_keys.json"), map[string]any{"agents": kemPub}, 0o644)
+	writeJSON(filepath.Join(*out, "keys", "kem", "kem_ownership.json"), map[string]any{"proofs": proofs}, 0o644)
+	writeJSON(filepath.Join(*out, "registry_snapshot.json"), fixtures.Snapshot{
+		Warning: "fixture keys: " + fixtures.Marker, ChainID: chainID.String(), Registry: registry.Hex(), Agents: snapshot,
+	}, 0o644)
+
+	fmt.Printf("fixtures written to %s (%d agents). %s\n", *out, len(agents), fixtures.Marker)
+}
@@ -0,0 +1,552 @@
+//go:build keytool
+// +build keytool
+
+// tools/keytool/keytool.go
+//
+// Orchestrated signing-key rotation for one agent:
+//
+//	go run -tags keytool ./tools/keytool rotate --agent payment [--funding-key 0x..]
+//
+// Steps (state in keys/.rotate-<agent>.json, saved after each one):
+//  1. keygen  — new secp256k1 key, parked in keys/.rotate-<agent>.key (0600)
+//  2. onchain — re-register under did:sage:ethereum:<new address> with the
+//     agent's X25519 KEM key (tools/registration/regflow)
+//  3. files   — JWK, signing keys, merged keys, inbound verifier list, KEM
+//     public list and KEM ownership proof replaced all-or-nothing, with
+//     .bak-<stamp> backups (internal/keyrotate)
+//  4. reload  — POST <agent>/admin/keys/reload; agents without it need a restart
+//  5. root    — POST <root>/admin/keys/invalidate drops Root's HPKE session + pin
+//
+// A failed run prints the resume plan. --resume continues from the saved
+// state, --agent-restarted marks the manual restart as done, --rollback puts
+// the previous files back (the new registration stays on-chain, unused).
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/keyrotate"
+	"github.com/sage-x-project/sage-multi-agent/tools/registration/regflow"
+
+	agentcrypto "github.com/sage-x-project/sage/pkg/agent/crypto"
+	"github.com/sage-x-project/sage/pkg/agent/crypto/formats"
+	"github.com/sage-x-project/sage/pkg/agent/crypto/keys"
+)
+
+type options struct {
+	agent       string
+	rpc         string
+	contract    string
+	signingKeys string
+	merged      string
+	allKeys     string
+	kemPublic   string
+	kemPrivate  string
+	kemProofs   string
+	jwk         string
+	fundingKey  string
+	fundingWei  string
+	wait        time.Duration
+	agentURL    string
+	rootURL     string
+	adminToken  string
+	statePath   string
+	resume      bool
+	restarted   bool
+	rollback    bool
+}
+
+// pendingKey is the parked new key (keys/.rotate-<agent>.key).
+type pendingKey struct {
+	JWK        json.RawMessage `json:"jwk"`
+	PrivateKey string          `json:"privateKey"`
+	PublicKey  string          `json:"publicKey"`
+	Address    string          `json:"address"`
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "rotate" {
+		fmt.Fprintln(os.Stderr, "usage: keytool rotate --agent <name> [--resume|--rollback] [flags]")
+		os.Exit(2)
+	}
+	o := parseRotateFlags(os.Args[2:])
+	if err := run(context.Background(), o); err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseRotateFlags(args []string) *options {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	o := &options{}
+	fs.StringVar(&o.agent, "agent", "", "Agent to rotate (e.g. payment)")
+	fs.StringVar(&o.rpc, "rpc", firstNonEmpty(os.Getenv("ETH_RPC_URL"), "http://127.0.0.1:8545"), "RPC URL")
+	fs.StringVar(&o.contract, "contract", firstNonEmpty(os.Getenv("SAGE_REGISTRY_V4_ADDRESS"), "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"), "AgentCardRegistry address")
+	fs.StringVar(&o.signingKeys, "signing-keys", "generated_agent_keys.json", "Signing keys JSON")
+	fs.StringVar(&o.merged, "merged", firstNonEmpty(os.Getenv("HPKE_KEYS_FILE"), "merged_agent_keys.json"), "Merged keys JSON (skipped when missing)")
+	fs.StringVar(&o.allKeys, "all-keys", "keys/all_keys.json", "Inbound verifier list (skipped when missing)")
+	fs.StringVar(&o.kemPublic, "kem-public", firstNonEmpty(os.Getenv("HPKE_KEM_PUBLIC_FILE"), "keys/kem/kem_all_keys.json"), "Public KEM list (skipped when missing)")
+	fs.StringVar(&o.kemPrivate, "kem-keys", "keys/kem/generated_kem_keys.json", "KEM keys JSON (skipped when missing)")
+	fs.StringVar(&o.kemProofs, "kem-proofs", firstNonEmpty(os.Getenv("HPKE_KEM_PROOFS_FILE"), "keys/kem/kem_ownership.json"), "KEM ownership proofs (skipped when missing)")
+	fs.StringVar(&o.jwk, "jwk", "", "Agent signing JWK (default $<AGENT>_JWK_FILE or keys/<agent>.jwk)")
+	fs.StringVar(&o.fundingKey, "funding-key", "", "Funder private key for the new address (optional)")
+	fs.StringVar(&o.fundingWei, "funding-amount-wei", "10000000000000000", "Wei to fund the new address (default 0.01 ETH)")
+	waitSeconds := fs.Int("wait-seconds", 65, "Seconds between commit and reveal (>=60)")
+	fs.StringVar(&o.agentURL, "agent-url", "", "Agent base URL for hot reload (default $SAGE_AGENT_<NAME>_ENDPOINT)")
+	fs.StringVar(&o.rootURL, "root-url", firstNonEmpty(os.Getenv("ROOT_URL"), "http://localhost:18080"), "Root base URL")
+	fs.StringVar(&o.adminToken, "admin-token", os.Getenv("ROOT_ADMIN_TOKEN"), "Admin token for Root and the agent")
+	fs.StringVar(&o.statePath, "state", "", "Rotation state file (default keys/.rotate-<agent>.json)")
+	fs.BoolVar(&o.resume, "resume", false, "Continue a saved rotation")
+	fs.BoolVar(&o.restarted, "agent-restarted", false, "The agent was restarted with the new key (finishes the reload step)")
+	fs.BoolVar(&o.rollback, "rollback", false, "Restore the key files replaced by a saved rotation")
+	_ = fs.Parse(args)
+
+	o.agent = strings.ToLower(strings.TrimSpace(o.agent))
+	if o.agent == "" {
+		fmt.Fprintln(os.Stderr, "--agent is required")
+		os.Exit(2)
+	}
+	o.wait = time.Duration(*waitSeconds) * time.Second
+	if o.jwk == "" {
+		o.jwk = firstNonEmpty(os.Getenv(toEnvKey(o.agent)+"_JWK_FILE"), filepath.Join("keys", o.agent+".jwk"))
+	}
+	if o.agentURL == "" {
+		o.agentURL = os.Getenv("SAGE_AGENT_" + toEnvKey(o.agent) + "_ENDPOINT")
+	}
+	if o.statePath == "" {
+		o.statePath = filepath.Join("keys", ".rotate-"+o.agent+".json")
+	}
+	return o
+}
+
+func (o *options) pendingPath() string {
+	return filepath.Join(filepath.Dir(o.statePath), ".rotate-"+o.agent+".key")
+}
+
+func (o *options) resumeCmd() string {
+	return "go run -tags keytool ./tools/keytool rotate --agent " + o.agent + " --resume"
+}
+
+func run(ctx context.Context, o *options) error {
+	st, err := keyrotate.Load(o.statePath)
+	switch {
+	case err == nil && !o.resume && !o.rollback:
+		printPlan(st)
+		return fmt.Errorf("a rotation for %s is in progress (%s); use --resume or --rollback", o.agent, o.statePath)
+	case errors.Is(err, os.ErrNotExist) && (o.resume || o.rollback):
+		return fmt.Errorf("no rotation in progress for %s (%s)", o.agent, o.statePath)
+	case errors.Is(err, os.ErrNotExist):
+		row, rerr := findRow(o.signingKeys, o.agent)
+		if rerr != nil {
+			return rerr
+		}
+		addr := str(row, "address")
+		st = keyrotate.New(o.agent, firstNonEmpty(str(row, "did"), "did:sage:ethereum:"+addr), addr)
+		if err := st.Save(o.statePath); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+
+	if o.rollback {
+		return rollback(o, st)
+	}
+
+	fmt.Println("======================================")
+	fmt.Printf(" Key rotation: %s\n", o.agent)
+	fmt.Println("======================================")
+	fmt.Printf(" Old DID:  %s\n", st.OldDID)
+	fmt.Printf(" State:    %s\n", o.statePath)
+
+	steps := []struct {
+		name string
+		fn   func(context.Context, *options, *keyrotate.State) error
+	}{
+		{keyrotate.StepKeygen, stepKeygen},
+		{keyrotate.StepOnchain, stepOnchain},
+		{keyrotate.StepFiles, stepFiles},
+		{keyrotate.StepReload, stepReload},
+		{keyrotate.StepRoot, stepRoot},
+	}
+	for _, s := range steps {
+		if st.IsDone(s.name) {
+			continue
+		}
+		fmt.Printf("\n[%s]\n", s.name)
+		err := s.fn(ctx, o, st)
+		if serr := st.Save(o.statePath); serr != nil && err == nil {
+			err = serr
+		}
+		if err != nil {
+			printPlan(st)
+			return err
+		}
+		if !st.IsDone(s.name) { // manual step: later steps wait for it
+			printPlan(st)
+			return nil
+		}
+	}
+
+	_ = os.Remove(o.pendingPath())
+	_ = keyrotate.Remove(o.statePath)
+	fmt.Printf("\nRotation complete: %s -> %s\n", st.OldDID, st.NewDID)
+	for _, b := range st.Backups {
+		if b.From != "" {
+			fmt.Printf("  backup: %s\n", b.From)
+		}
+	}
+	return nil
+}
+
+func printPlan(st *keyrotate.State) {
+	lines := st.Plan()
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Println("\nResume plan:")
+	for _, l := range lines {
+		fmt.Println("  " + l)
+	}
+}
+
+// ---- steps ----
+
+func stepKeygen(_ context.Context, o *options, st *keyrotate.State) error {
+	if pk, err := loadPending(o.pendingPath()); err == nil {
+		fmt.Printf(" reusing parked key %s (%s)\n", o.pendingPath(), pk.Address)
+		return markKeygen(st, o, pk)
+	}
+	kp, err := keys.GenerateSecp256k1KeyPair()
+	if err != nil {
+		st.Mark(keyrotate.StepKeygen, keyrotate.Failed, err.Error(), o.resumeCmd())
+		return err
+	}
+	jwk, err := formats.NewJWKExporter().Export(kp, agentcrypto.KeyFormatJWK)
+	if err != nil {
+		st.Mark(keyrotate.StepKeygen, keyrotate.Failed, err.Error(), o.resumeCmd())
+		return err
+	}
+	priv := kp.PrivateKey().(*ecdsa.PrivateKey)
+	pk := pendingKey{
+		JWK:        jwk,
+		PrivateKey: "0x" + hex.EncodeToString(gethcrypto.FromECDSA(priv)),
+		PublicKey:  "0x" + hex.EncodeToString(gethcrypto.FromECDSAPub(&priv.PublicKey)),
+		Address:    gethcrypto.PubkeyToAddress(priv.PublicKey).Hex(),
+	}
+	b, _ := json.MarshalIndent(pk, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(o.pendingPath()), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.pendingPath(), b, 0o600); err != nil {
+		st.Mark(keyrotate.StepKeygen, keyrotate.Failed, err.Error(), o.resumeCmd())
+		return err
+	}
+	fmt.Printf(" new key parked in %s (%s)\n", o.pendingPath(), pk.Address)
+	return markKeygen(st, o, &pk)
+}
+
+func markKeygen(st *keyrotate.State, o *options, pk *pendingKey) error {
+	st.NewAddress, st.NewPublic = pk.Address, pk.PublicKey
+	st.NewDID = "did:sage:ethereum:" + pk.Address
+	st.PendingKey = o.pendingPath()
+	st.Mark(keyrotate.StepKeygen, keyrotate.Done, st.NewDID, "")
+	return nil
+}
+
+func stepOnchain(ctx context.Context, o *options, st *keyrotate.State) error {
+	pk, err := loadPending(o.pendingPath())
+	if err != nil {
+		st.Mark(keyrotate.StepOnchain, keyrotate.Failed, "parked key missing: "+err.Error(), "restore "+o.pendingPath()+" or --rollback and start over")
+		return err
+	}
+	priv, err := gethcrypto.HexToECDSA(strings.TrimPrefix(pk.PrivateKey, "0x"))
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		st.Mark(keyrotate.StepOnchain, keyrotate.Failed, err.Error(), o.resumeCmd()+"  (registration is idempotent per DID)")
+		return err
+	}
+	if fk := strings.TrimSpace(o.fundingKey); fk != "" {
+		amt, ok := new(big.Int).SetString(o.fundingWei, 10)
+		if !ok || amt.Sign() <= 0 {
+			return fail(errors.New("invalid funding amount"))
+		}
+		if err := regflow.Fund(ctx, o.rpc, fk, common.HexToAddress(pk.Address), amt); err != nil {
+			return fail(fmt.Errorf("funding: %w", err))
+		}
+		fmt.Printf(" funded %s\n", pk.Address)
+	}
+
+	card := regflow.Card{
+		Name:         o.agent,
+		DID:          st.NewDID,
+		Description:  firstNonEmpty(os.Getenv("SAGE_AGENT_"+toEnvKey(o.agent)+"_DESC"), "SAGE Agent "+o.agent),
+		Endpoint:     os.Getenv("SAGE_AGENT_" + toEnvKey(o.agent) + "_ENDPOINT"),
+		PrivateKey:   priv,
+		Capabilities: map[string]any{},
+	}
+	if row, err := findRow(o.merged, o.agent); err == nil {
+		if x := str(row, "x25519Public"); x != "" {
+			if card.X25519Public, err = hex.DecodeString(strings.TrimPrefix(x, "0x")); err != nil || len(card.X25519Public) != 32 {
+				return fail(fmt.Errorf("merged keys: bad x25519Public for %s", o.agent))
+			}
+		}
+	}
+	fmt.Printf(" re-registering %s as %s (commit→reveal, %s)…\n", o.agent, st.NewDID, o.wait)
+	res, err := regflow.ReRegister(ctx, regflow.Registry{RPC: o.rpc, Contract: o.contract, Wait: o.wait, Activate: true}, st.OldDID, card)
+	if err != nil {
+		return fail(err)
+	}
+	if res.Existing {
+		fmt.Println(" already registered (resumed)")
+	} else {
+		fmt.Printf(" registered AgentID 0x%x activated=%v\n", res.AgentID, res.Activated)
+	}
+	st.KEMProof = res.KEMProof
+	st.Mark(keyrotate.StepOnchain, keyrotate.Done, "", "")
+	return nil
+}
+
+func stepFiles(_ context.Context, o *options, st *keyrotate.State) error {
+	pk, err := loadPending(o.pendingPath())
+	if err != nil {
+		st.Mark(keyrotate.StepFiles, keyrotate.Failed, "parked key missing: "+err.Error(), "restore "+o.pendingPath())
+		return err
+	}
+	fail := func(err error) error {
+		st.Mark(keyrotate.StepFiles, keyrotate.Failed, err.Error(), "fix the file and run: "+o.resumeCmd()+"  (nothing was replaced)")
+		return err
+	}
+	row := map[string]any{"did": st.NewDID, "publicKey": pk.PublicKey, "privateKey": pk.PrivateKey, "address": pk.Address}
+	ident := map[string]any{"did": st.NewDID, "address": pk.Address}
+
+	changes := []keyrotate.Change{{Path: o.jwk, Data: append([]byte(pk.JWK), '\n'), Mode: 0o600}}
+	edit := func(path string, required bool, fn func([]byte) ([]byte, error)) error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			if !required && errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		nb, err := fn(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		changes = append(changes, keyrotate.Change{Path: path, Data: nb})
+		return nil
+	}
+	for _, e := range []struct {
+		path     string
+		required bool
+		fn       func([]byte) ([]byte, error)
+	}{
+		{o.signingKeys, true, func(b []byte) ([]byte, error) { return keyrotate.UpsertByName(b, o.agent, row) }},
+		{o.merged, false, func(b []byte) ([]byte, error) { return keyrotate.UpsertByName(b, o.agent, row) }},
+		{o.allKeys, false, func(b []byte) ([]byte, error) {
+			return keyrotate.ReplaceVerifierKey(b, st.OldDID, st.NewDID, pk.PublicKey)
+		}},
+		{o.kemPublic, false, func(b []byte) ([]byte, error) { return keyrotate.UpsertByName(b, o.agent, ident) }},
+		{o.kemPrivate, false, func(b []byte) ([]byte, error) { return keyrotate.UpsertByName(b, o.agent, ident) }},
+	} {
+		if err := edit(e.path, e.required, e.fn); err != nil {
+			return fail(err)
+		}
+	}
+	if p := st.KEMProof; p != nil {
+		if err := edit(o.kemProofs, false, func(b []byte) ([]byte, error) { return keyrotate.UpsertProof(b, *p) }); err != nil {
+			return fail(err)
+		}
+	}
+
+	backups, err := keyrotate.Apply(changes, st.Stamp)
+	if err != nil {
+		return fail(err)
+	}
+	st.Backups = backups
+	for _, b := range backups {
+		fmt.Printf(" updated %s\n", b.Path)
+	}
+	st.Mark(keyrotate.StepFiles, keyrotate.Done, fmt.Sprintf("%d files", len(backups)), "")
+	return nil
+}
+
+func stepReload(ctx context.Context, o *options, st *keyrotate.State) error {
+	restart := fmt.Sprintf("restart the %s agent (it loads %s at startup), then: %s --agent-restarted", o.agent, o.jwk, o.resumeCmd())
+	if o.restarted {
+		st.Mark(keyrotate.StepReload, keyrotate.Done, "restarted by operator", "")
+		return nil
+	}
+	if strings.TrimSpace(o.agentURL) == "" {
+		st.Mark(keyrotate.StepReload, keyrotate.Manual, "no agent URL (--agent-url)", restart)
+		return nil
+	}
+	code, body, err := post(ctx, strings.TrimRight(o.agentURL, "/")+"/admin/keys/reload", o.adminToken, map[string]any{"jwkFile": o.jwk})
+	switch {
+	case err != nil:
+		st.Mark(keyrotate.StepReload, keyrotate.Failed, err.Error(), o.resumeCmd()+"  (or: "+restart+")")
+		return err
+	case code == http.StatusNotFound || code == http.StatusMethodNotAllowed:
+		fmt.Printf(" %s has no key hot-reload endpoint: restart needed\n", o.agent)
+		st.Mark(keyrotate.StepReload, keyrotate.Manual, "no hot-reload endpoint", restart)
+	case code/100 != 2:
+		st.Mark(keyrotate.StepReload, keyrotate.Failed, fmt.Sprintf("HTTP %d: %s", code, body), restart)
+		return fmt.Errorf("agent reload: HTTP %d", code)
+	default:
+		fmt.Printf(" %s reloaded its key\n", o.agent)
+		st.Mark(keyrotate.StepReload, keyrotate.Done, "hot reload", "")
+	}
+	return nil
+}
+
+func stepRoot(ctx context.Context, o *options, st *keyrotate.State) error {
+	url := strings.TrimRight(o.rootURL, "/") + "/admin/keys/invalidate"
+	manual := fmt.Sprintf(`curl -X POST %s -H "X-Admin-Token: $ROOT_ADMIN_TOKEN" -d '{"target":"%s"}'`, url, o.agent)
+	code, body, err := post(ctx, url, o.adminToken, map[string]any{"target": o.agent, "reason": "key rotation"})
+	if err != nil {
+		st.Mark(keyrotate.StepRoot, keyrotate.Failed, err.Error(), o.resumeCmd()+"  (or: "+manual+")")
+		return err
+	}
+	if code/100 != 2 {
+		st.Mark(keyrotate.StepRoot, keyrotate.Failed, fmt.Sprintf("HTTP %d: %s", code, body), manual)
+		return fmt.Errorf("root invalidate: HTTP %d", code)
+	}
+	var res map[string]any
+	_ = json.Unmarshal([]byte(body), &res)
+	fmt.Printf(" Root dropped HPKE session + pin for %s (re-enabled=%v)\n", o.agent, res["hpkeReenabled"])
+	if e, _ := res["hpkeError"].(string); e != "" {
+		fmt.Printf(" Root re-handshake pending: %s\n", e)
+	}
+	st.Mark(keyrotate.StepRoot, keyrotate.Done, "", "")
+	return nil
+}
+
+func rollback(o *options, st *keyrotate.State) error {
+	if len(st.Backups) == 0 {
+		fmt.Println("No files were replaced; nothing to restore.")
+	} else {
+		if err := keyrotate.Restore(st.Backups); err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+		for _, b := range st.Backups {
+			fmt.Printf(" restored %s\n", b.Path)
+		}
+		st.Backups = nil
+	}
+	for _, s := range []string{keyrotate.StepFiles, keyrotate.StepReload, keyrotate.StepRoot} {
+		st.Mark(s, keyrotate.Pending, "rolled back", o.resumeCmd())
+	}
+	if err := st.Save(o.statePath); err != nil {
+		return err
+	}
+	if st.IsDone(keyrotate.StepOnchain) {
+		fmt.Printf("Note: %s stays registered on-chain; nothing refers to it after the restore.\n", st.NewDID)
+	}
+	fmt.Printf("If the agent or Root already use the new key, restart the agent and call /admin/keys/invalidate again.\n")
+	fmt.Printf("The parked key is kept in %s; --resume re-applies it, or delete it and %s to abandon.\n", o.pendingPath(), o.statePath)
+	return nil
+}
+
+// ---- helpers ----
+
+func loadPending(path string) (*pendingKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pk pendingKey
+	if err := json.Unmarshal(b, &pk); err != nil || pk.PrivateKey == "" {
+		return nil, fmt.Errorf("parked key %s unreadable", path)
+	}
+	return &pk, nil
+}
+
+func findRow(path, name string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(b, &rows); err != nil {
+		var w struct {
+			Agents []map[string]any `json:"agents"`
+		}
+		if err := json.Unmarshal(b, &w); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rows = w.Agents
+	}
+	for _, r := range rows {
+		if str(r, "name") == name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no entry for %q", path, name)
+}
+
+func post(ctx context.Context, url, token string, body any) (int, string, error) {
+	b, _ := json.Marshal(body)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	rb, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, strings.TrimSpace(string(rb)), nil
+}
+
+func str(m map[string]any, k string) string {
+	s, _ := m[k].(string)
+	return strings.TrimSpace(s)
+}
+
+func firstNonEmpty(vs ...string) string {
+	for _, v := range vs {
+		if s := strings.TrimSpace(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func toEnvKey(name string) string {
+	up := strings.ToUpper(name)
+	b := make([]rune, 0, len(up))
+	for _, r := range up {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b = append(b, r)
+		} else {
+			b = append(b, '_')
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,257 @@
+// Package regflow is the AgentCardRegistry registration flow shared by the
+// key tools: ownership signatures, commit→reveal→activate, and the
+// re-register path used when an agent's signing key is rotated.
+//
+// The registry binds a registration to the owner address derived from the
+// agent's secp256k1 key, so a rotated key cannot be attached to the existing
+// DID. ReRegister registers the new key under a new DID
+// (did:sage:ethereum:<new address>); the caller passes the same name, card
+// fields and X25519 KEM key as before. The old card is left as is: peers stop
+// resolving it once their key lists name the new DID.
+package regflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
+	"github.com/sage-x-project/sage/pkg/agent/did"
+	agentcard "github.com/sage-x-project/sage/pkg/agent/did/ethereum"
+)
+
+// ErrSameDID is returned when a re-registration would reuse the current DID.
+var ErrSameDID = errors.New("regflow: rotated key must register under a new DID")
+
+// Registry is the chain/contract pair to register against.
+type Registry struct {
+	RPC      string
+	Contract string
+	Wait     time.Duration // commit→reveal delay (contract minimum is 60s)
+	Activate bool
+}
+
+// Card is what gets registered for one agent.
+type Card struct {
+	Name         string
+	DID          string
+	Description  string
+	Endpoint     string
+	Capabilities map[string]any
+	PrivateKey   *ecdsa.PrivateKey
+	X25519Public []byte // optional KEM key (32 bytes)
+}
+
+// Result describes a finished registration.
+type Result struct {
+	DID       string
+	AgentID   [32]byte
+	Existing  bool // the DID was already registered (resumed run)
+	Activated bool
+	KEMProof  *kemproof.Proof // set when an X25519 key was registered
+}
+
+// DIDFor is the default DID of a key: did:sage:ethereum:<address>.
+func DIDFor(priv *ecdsa.PrivateKey) string {
+	return "did:sage:ethereum:" + gethcrypto.PubkeyToAddress(priv.PublicKey).Hex()
+}
+
+func pad32BigEndian(n *big.Int) []byte {
+	var out [32]byte
+	b := n.Bytes()
+	copy(out[32-len(b):], b)
+	return out[:]
+}
+
+func ethSign(priv *ecdsa.PrivateKey, msg []byte) ([]byte, error) {
+	msgHash := gethcrypto.Keccak256Hash(msg)
+	prefix := []byte("\x19Ethereum Signed Message:\n32")
+	ethSigned := gethcrypto.Keccak256Hash(append(prefix, msgHash.Bytes()...))
+	sig, err := gethcrypto.Sign(ethSigned.Bytes(), priv)
+	if err != nil {
+		return nil, err
+	}
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig, nil
+}
+
+// SignECDSAOwnership signs what the contract expects for the ECDSA key.
+func SignECDSAOwnership(priv *ecdsa.PrivateKey, chainID *big.Int, registry, owner common.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("SAGE Agent Registration:")
+	buf.Write(pad32BigEndian(chainID))
+	buf.Write(registry.Bytes())
+	buf.Write(owner.Bytes())
+	return ethSign(priv, buf.Bytes())
+}
+
+// SignX25519Ownership signs what the contract expects for the X25519 key.
+func SignX25519Ownership(priv *ecdsa.PrivateKey, x25519Pub32 []byte, chainID *big.Int, registry, owner common.Address) ([]byte, error) {
+	if len(x25519Pub32) != 32 {
+		return nil, fmt.Errorf("x25519 pub must be 32 bytes")
+	}
+	var buf bytes.Buffer
+	buf.WriteString("SAGE X25519 Ownership:")
+	buf.Write(x25519Pub32)
+	buf.Write(pad32BigEndian(chainID))
+	buf.Write(registry.Bytes())
+	buf.Write(owner.Bytes())
+	return ethSign(priv, buf.Bytes())
+}
+
+// Fund sends amountWei from funderHex to addr unless it already holds that much.
+func Fund(ctx context.Context, rpcURL, funderHex string, addr common.Address, amountWei *big.Int) error {
+	cli, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	if bal, err := cli.BalanceAt(ctx, addr, nil); err == nil && bal.Cmp(amountWei) >= 0 {
+		return nil
+	}
+	funder, err := gethcrypto.HexToECDSA(trim0x(funderHex))
+	if err != nil {
+		return fmt.Errorf("funding key parse: %w", err)
+	}
+	chainID, err := cli.NetworkID(ctx)
+	if err != nil {
+		return err
+	}
+	nonce, err := cli.PendingNonceAt(ctx, gethcrypto.PubkeyToAddress(funder.PublicKey))
+	if err != nil {
+		return err
+	}
+	gasPrice, err := cli.SuggestGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	tx := gethtypes.NewTransaction(nonce, addr, amountWei, 21000, gasPrice, nil)
+	signed, err := gethtypes.SignTx(tx, gethtypes.NewEIP155Signer(chainID), funder)
+	if err != nil {
+		return err
+	}
+	return cli.SendTransaction(ctx, signed)
+}
+
+func trim0x(s string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+}
+
+// Register runs commit→reveal(→activate) for c. A DID that is already
+// registered is reported as Existing instead of failing, so an interrupted
+// run can be repeated.
+func Register(ctx context.Context, reg Registry, c Card) (*Result, error) {
+	view, err := agentcard.NewAgentCardClient(&did.RegistryConfig{RPCEndpoint: reg.RPC, ContractAddress: reg.Contract})
+	if err != nil {
+		return nil, fmt.Errorf("init view client: %w", err)
+	}
+	cli, err := ethclient.DialContext(ctx, reg.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("rpc dial: %w", err)
+	}
+	chainID, err := cli.NetworkID(ctx)
+	cli.Close()
+	if err != nil {
+		return nil, fmt.Errorf("network id: %w", err)
+	}
+	registryAddr := common.HexToAddress(reg.Contract)
+	owner := gethcrypto.PubkeyToAddress(c.PrivateKey.PublicKey)
+
+	res := &Result{DID: c.DID}
+	keys := [][]byte{gethcrypto.FromECDSAPub(&c.PrivateKey.PublicKey)}
+	keyTypes := []did.KeyType{did.KeyTypeECDSA}
+	ecdsaSig, err := SignECDSAOwnership(c.PrivateKey, chainID, registryAddr, owner)
+	if err != nil {
+		return nil, fmt.Errorf("sign ECDSA: %w", err)
+	}
+	sigs := [][]byte{ecdsaSig}
+	if len(c.X25519Public) > 0 {
+		xSig, err := SignX25519Ownership(c.PrivateKey, c.X25519Public, chainID, registryAddr, owner)
+		if err != nil {
+			return nil, fmt.Errorf("sign X25519: %w", err)
+		}
+		keys, keyTypes, sigs = append(keys, c.X25519Public), append(keyTypes, did.KeyTypeX25519), append(sigs, xSig)
+		res.KEMProof = &kemproof.Proof{
+			Name: c.Name, DID: c.DID, X25519Public: fmt.Sprintf("0x%x", c.X25519Public),
+			Owner: owner.Hex(), ChainID: chainID.String(), Registry: registryAddr.Hex(),
+			Signature: fmt.Sprintf("0x%x", xSig),
+		}
+	}
+
+	if ag, err := view.GetAgentByDID(ctx, c.DID); err == nil {
+		res.Existing, res.Activated = true, ag.IsActive
+		return res, nil
+	}
+
+	caps := "{}"
+	if c.Capabilities != nil {
+		if b, err := json.Marshal(c.Capabilities); err == nil {
+			caps = string(b)
+		}
+	}
+	params := &did.RegistrationParams{
+		DID:          c.DID,
+		Name:         c.Name,
+		Description:  c.Description,
+		Endpoint:     c.Endpoint,
+		Capabilities: caps,
+		Keys:         keys,
+		KeyTypes:     keyTypes,
+		Signatures:   sigs,
+	}
+	client, err := agentcard.NewAgentCardClient(&did.RegistryConfig{
+		RPCEndpoint: reg.RPC, ContractAddress: reg.Contract, PrivateKey: hex.EncodeToString(gethcrypto.FromECDSA(c.PrivateKey)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("init client: %w", err)
+	}
+	status, err := client.CommitRegistration(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	select {
+	case <-time.After(reg.Wait):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	status, err = client.RegisterAgent(ctx, status)
+	if err != nil {
+		return nil, fmt.Errorf("reveal: %w", err)
+	}
+	res.AgentID = status.AgentID
+	if reg.Activate {
+		if wait := time.Until(status.CanActivateAt); wait > 0 {
+			time.Sleep(wait + 800*time.Millisecond)
+		}
+		if err := client.ActivateAgent(ctx, status); err == nil {
+			res.Activated = true
+		}
+	}
+	return res, nil
+}
+
+// ReRegister registers next (the rotated key) under its own DID, which
+// defaults to DIDFor(next.PrivateKey) and must differ from oldDID.
+func ReRegister(ctx context.Context, reg Registry, oldDID string, next Card) (*Result, error) {
+	if next.DID == "" {
+		next.DID = DIDFor(next.PrivateKey)
+	}
+	if strings.EqualFold(next.DID, oldDID) {
+		return nil, ErrSameDID
+	}
+	return Register(ctx, reg, next)
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 
+	"github.com/sage-x-project/sage-multi-agent/internal/kemproof"
 	"github.com/sage-x-project/sage/pkg/agent/did"
 	agentcard "github.com/sage-x-project/sage/pkg/agent/did/ethereum"
 )
@@ -57,6 +58,7 @@ func main() {
 	agentsFilter := flag.String("agents", "", "Comma-separated agent names")
 	waitSeconds := flag.Int("wait-seconds", 65, "Seconds between commit and reveal (>=60)")
 	tryActivate := flag.Bool("try-activate", true, "Try activation if allowed")
+	proofsPath := flag.String("kem-proofs", "keys/kem/kem_ownership.json", "Output: X25519 ownership proofs (read by Root HPKE)")
 	flag.Parse()
 
 	if strings.TrimSpace(*contract) == "" {
@@ -143,6 +145,7 @@ func main() {
 	if d, err := viewClient.GetActivationDelay(ctx); err == nil {
 		fmt.Printf(" Current activationDelay = %s\n", d)
 	}
+	var proofs []kemproof.Proof
 	for _, a := range agents {
 		sk := findSigning(signingRows, a.Name)
 		if sk == nil || strings.TrimSpace(sk.PrivateKey) == "" {
@@ -183,6 +186,11 @@ func main() {
 			fmt.Printf("   sign X25519 failed: %v\n", err)
 			continue
 		}
+		proofs = append(proofs, kemproof.Proof{
+			Name: a.Name, DID: strings.TrimSpace(kr.DID), X25519Public: "0x" + hex.EncodeToString(xpub),
+			Owner: ownerAddr.Hex(), ChainID: chainID.String(), Registry: registryAddr.Hex(),
+			Signature: "0x" + hex.EncodeToString(xSig),
+		})
 
 		perAgentCfg := &did.RegistryConfig{RPCEndpoint: *rpcURL, ContractAddress: *contract, PrivateKey: normHex(sk.PrivateKey)}
 		client, err := agentcard.NewAgentCardClient(perAgentCfg)
@@ -232,6 +240,14 @@ func main() {
 
 	}
 
+	if len(proofs) > 0 {
+		if err := writeProofs(*proofsPath, proofs); err != nil {
+			fmt.Printf("write KEM ownership proofs failed: %v\n", err)
+		} else {
+			fmt.Printf("Wrote %d KEM ownership proof(s) to %s\n", len(proofs), *proofsPath)
+		}
+	}
+
 	fmt.Println("\nVerification:")
 	for _, a := range agents {
 		kr := findKEM(kemRows, a.Name)
@@ -253,6 +269,17 @@ func main() {
 
 /* === io & small helpers === */
 
+func writeProofs(path string, proofs []kemproof.Proof) error {
+	b, err := json.MarshalIndent(map[string]any{"proofs": proofs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
 func loadSigning(path string) ([]signingRow, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,138 @@
+// Package types is the old import path of pkg/types.
+//
+// Deprecated: import github.com/sage-x-project/sage-multi-agent/pkg/types
+// instead. This package only forwards to it (type aliases, constants and
+// wrapper functions) and will be removed in the next release.
+package types
+
+import pkgtypes "github.com/sage-x-project/sage-multi-agent/pkg/types"
+
+type (
+	// Deprecated: use pkg/types.AgentConversation.
+	AgentConversation = pkgtypes.AgentConversation
+	// Deprecated: use pkg/types.AgentLog.
+	AgentLog = pkgtypes.AgentLog
+	// Deprecated: use pkg/types.AgentMessage.
+	AgentMessage = pkgtypes.AgentMessage
+	// Deprecated: use pkg/types.AgentMessageRequest.
+	AgentMessageRequest = pkgtypes.AgentMessageRequest
+	// Deprecated: use pkg/types.AgentMessageResponse.
+	AgentMessageResponse = pkgtypes.AgentMessageResponse
+	// Deprecated: use pkg/types.ConnectionStatus.
+	ConnectionStatus = pkgtypes.ConnectionStatus
+	// Deprecated: use pkg/types.ErrorDetail.
+	ErrorDetail = pkgtypes.ErrorDetail
+	// Deprecated: use pkg/types.HealthCheckResponse.
+	HealthCheckResponse = pkgtypes.HealthCheckResponse
+	// Deprecated: use pkg/types.PromptRequest.
+	PromptRequest = pkgtypes.PromptRequest
+	// Deprecated: use pkg/types.PromptResponse.
+	PromptResponse = pkgtypes.PromptResponse
+	// Deprecated: use pkg/types.RequestContext.
+	RequestContext = pkgtypes.RequestContext
+	// Deprecated: use pkg/types.RequestMetadata.
+	RequestMetadata = pkgtypes.RequestMetadata
+	// Deprecated: use pkg/types.ResponseContext.
+	ResponseContext = pkgtypes.ResponseContext
+	// Deprecated: use pkg/types.ResponseMetadata.
+	ResponseMetadata = pkgtypes.ResponseMetadata
+	// Deprecated: use pkg/types.SAGEConfigRequest.
+	SAGEConfigRequest = pkgtypes.SAGEConfigRequest
+	// Deprecated: use pkg/types.SAGEErrorResponse.
+	SAGEErrorResponse = pkgtypes.SAGEErrorResponse
+	// Deprecated: use pkg/types.SAGEStatus.
+	SAGEStatus = pkgtypes.SAGEStatus
+	// Deprecated: use pkg/types.SAGETestResult.
+	SAGETestResult = pkgtypes.SAGETestResult
+	// Deprecated: use pkg/types.SAGEVerificationError.
+	SAGEVerificationError = pkgtypes.SAGEVerificationError
+	// Deprecated: use pkg/types.SAGEVerificationResult.
+	SAGEVerificationResult = pkgtypes.SAGEVerificationResult
+	// Deprecated: use pkg/types.ServiceStatus.
+	ServiceStatus = pkgtypes.ServiceStatus
+	// Deprecated: use pkg/types.WebSocketMessage.
+	WebSocketMessage = pkgtypes.WebSocketMessage
+)
+
+const (
+	// Deprecated: use pkg/types.LogLevelDebug.
+	LogLevelDebug = pkgtypes.LogLevelDebug
+	// Deprecated: use pkg/types.LogLevelError.
+	LogLevelError = pkgtypes.LogLevelError
+	// Deprecated: use pkg/types.LogLevelInfo.
+	LogLevelInfo = pkgtypes.LogLevelInfo
+	// Deprecated: use pkg/types.LogLevelWarning.
+	LogLevelWarning = pkgtypes.LogLevelWarning
+	// Deprecated: use pkg/types.LogTypeError.
+	LogTypeError = pkgtypes.LogTypeError
+	// Deprecated: use pkg/types.LogTypeGateway.
+	LogTypeGateway = pkgtypes.LogTypeGateway
+	// Deprecated: use pkg/types.LogTypeMEDICAL.
+	LogTypeMEDICAL = pkgtypes.LogTypeMEDICAL
+	// Deprecated: use pkg/types.LogTypePlanning.
+	LogTypePlanning = pkgtypes.LogTypePlanning
+	// Deprecated: use pkg/types.LogTypeRouting.
+	LogTypeRouting = pkgtypes.LogTypeRouting
+	// Deprecated: use pkg/types.LogTypeSAGE.
+	LogTypeSAGE = pkgtypes.LogTypeSAGE
+	// Deprecated: use pkg/types.SAGEErrorCodeAgentNotRegistered.
+	SAGEErrorCodeAgentNotRegistered = pkgtypes.SAGEErrorCodeAgentNotRegistered
+	// Deprecated: use pkg/types.SAGEErrorCodeBlockchainError.
+	SAGEErrorCodeBlockchainError = pkgtypes.SAGEErrorCodeBlockchainError
+	// Deprecated: use pkg/types.SAGEErrorCodeExpiredMessage.
+	SAGEErrorCodeExpiredMessage = pkgtypes.SAGEErrorCodeExpiredMessage
+	// Deprecated: use pkg/types.SAGEErrorCodeInvalidDID.
+	SAGEErrorCodeInvalidDID = pkgtypes.SAGEErrorCodeInvalidDID
+	// Deprecated: use pkg/types.SAGEErrorCodeInvalidSignature.
+	SAGEErrorCodeInvalidSignature = pkgtypes.SAGEErrorCodeInvalidSignature
+	// Deprecated: use pkg/types.SAGEErrorCodePublicKeyNotFound.
+	SAGEErrorCodePublicKeyNotFound = pkgtypes.SAGEErrorCodePublicKeyNotFound
+	// Deprecated: use pkg/types.SAGEErrorCodeVerificationDisabled.
+	SAGEErrorCodeVerificationDisabled = pkgtypes.SAGEErrorCodeVerificationDisabled
+	// Deprecated: use pkg/types.StatusDegraded.
+	StatusDegraded = pkgtypes.StatusDegraded
+	// Deprecated: use pkg/types.StatusDown.
+	StatusDown = pkgtypes.StatusDown
+	// Deprecated: use pkg/types.StatusHealthy.
+	StatusHealthy = pkgtypes.StatusHealthy
+	// Deprecated: use pkg/types.StatusUnhealthy.
+	StatusUnhealthy = pkgtypes.StatusUnhealthy
+	// Deprecated: use pkg/types.StatusUp.
+	StatusUp = pkgtypes.StatusUp
+	// Deprecated: use pkg/types.VerificationFalse.
+	VerificationFalse = pkgtypes.VerificationFalse
+	// Deprecated: use pkg/types.VerificationNotApplicable.
+	VerificationNotApplicable = pkgtypes.VerificationNotApplicable
+	// Deprecated: use pkg/types.VerificationTrue.
+	VerificationTrue = pkgtypes.VerificationTrue
+	// Deprecated: use pkg/types.WSTypeConnection.
+	WSTypeConnection = pkgtypes.WSTypeConnection
+	// Deprecated: use pkg/types.WSTypeError.
+	WSTypeError = pkgtypes.WSTypeError
+	// Deprecated: use pkg/types.WSTypeHeartbeat.
+	WSTypeHeartbeat = pkgtypes.WSTypeHeartbeat
+	// Deprecated: use pkg/types.WSTypeLog.
+	WSTypeLog = pkgtypes.WSTypeLog
+	// Deprecated: use pkg/types.WSTypeStatus.
+	WSTypeStatus = pkgtypes.WSTypeStatus
+)
+
+// Deprecated: use pkg/types.NewWebSocketMessage.
+func NewWebSocketMessage(msgType string, payload interface{}) *WebSocketMessage {
+	return pkgtypes.NewWebSocketMessage(msgType, payload)
+}
+
+// Deprecated: use pkg/types.NewAgentLog.
+func NewAgentLog(logType, from, content string) *AgentLog {
+	return pkgtypes.NewAgentLog(logType, from, content)
+}
+
+// Deprecated: use pkg/types.NewSAGEVerificationError.
+func NewSAGEVerificationError(code, message, agentDID, messageID string) *SAGEVerificationError {
+	return pkgtypes.NewSAGEVerificationError(code, message, agentDID, messageID)
+}
+
+// Deprecated: use pkg/types.NewSAGEErrorResponse.
+func NewSAGEErrorResponse(from, to string, err *SAGEVerificationError) *SAGEErrorResponse {
+	return pkgtypes.NewSAGEErrorResponse(from, to, err)
+}
@@ -0,0 +1,185 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+// LogBroadcaster is what log-emitting components depend on. LogServer and
+// EnhancedLogServer deliver over WebSocket; InProcLogServer delivers to
+// in-process subscribers without binding a port.
+type LogBroadcaster interface {
+	Start() error
+	Stop() error
+	BroadcastLog(message string)
+	BroadcastAgentLog(log *types.AgentLog)
+	BroadcastError(from, errorMsg string)
+	BroadcastStatus(status interface{})
+}
+
+var (
+	_ LogBroadcaster = (*LogServer)(nil)
+	_ LogBroadcaster = (*EnhancedLogServer)(nil)
+	_ LogBroadcaster = (*InProcLogServer)(nil)
+)
+
+// LogEvent is one broadcast as seen by an in-process subscriber.
+type LogEvent struct {
+	Type   string          // types.WSTypeLog | types.WSTypeStatus (errors are logs with Log.Type "error")
+	Raw    string          // BroadcastLog message, verbatim
+	Log    *types.AgentLog // BroadcastAgentLog / BroadcastError
+	Status interface{}     // BroadcastStatus payload
+	Time   time.Time
+}
+
+// InProcLogServer implements LogBroadcaster with channels instead of a socket.
+type InProcLogServer struct {
+	mu      sync.Mutex
+	subs    map[int]chan LogEvent
+	nextID  int
+	bufSize int
+	dropped atomic.Int64
+	stopped chan struct{} // closed by Stop; ends the Subscribe watchers
+}
+
+// NewInProcLogServer creates an in-memory log server. Each subscriber channel
+// buffers 256 events; events for a full subscriber are dropped and counted.
+func NewInProcLogServer() *InProcLogServer {
+	return &InProcLogServer{subs: make(map[int]chan LogEvent), bufSize: 256, stopped: make(chan struct{})}
+}
+
+// Start is a no-op; there is nothing to bind.
+func (s *InProcLogServer) Start() error { return nil }
+
+// Stop closes every subscriber channel. Later subscribers get a closed channel.
+func (s *InProcLogServer) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stopped:
+	default:
+		close(s.stopped)
+	}
+	for id, ch := range s.subs {
+		close(ch)
+		delete(s.subs, id)
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving every event broadcast after the call.
+// The channel is closed when ctx is done or the server stops.
+func (s *InProcLogServer) Subscribe(ctx context.Context) <-chan LogEvent {
+	ch := make(chan LogEvent, s.bufSize)
+	s.mu.Lock()
+	select {
+	case <-s.stopped:
+		s.mu.Unlock()
+		close(ch)
+		return ch
+	default:
+	}
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = ch
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.stopped:
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if c, ok := s.subs[id]; ok {
+			close(c)
+			delete(s.subs, id)
+		}
+	}()
+	return ch
+}
+
+// Dropped reports events lost to full subscriber buffers.
+func (s *InProcLogServer) Dropped() int64 { return s.dropped.Load() }
+
+func (s *InProcLogServer) publish(ev LogEvent) {
+	ev.Time = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// BroadcastLog delivers a raw log message
+func (s *InProcLogServer) BroadcastLog(message string) {
+	s.publish(LogEvent{Type: types.WSTypeLog, Raw: message})
+}
+
+// BroadcastAgentLog delivers an agent log
+func (s *InProcLogServer) BroadcastAgentLog(log *types.AgentLog) {
+	s.publish(LogEvent{Type: types.WSTypeLog, Log: log})
+}
+
+// BroadcastError delivers an error log from the given component
+func (s *InProcLogServer) BroadcastError(from, errorMsg string) {
+	log := types.NewAgentLog(types.LogTypeError, from, errorMsg)
+	log.Level = types.LogLevelError
+	s.BroadcastAgentLog(log)
+}
+
+// BroadcastStatus delivers a status update
+func (s *InProcLogServer) BroadcastStatus(status interface{}) {
+	s.publish(LogEvent{Type: types.WSTypeStatus, Status: status})
+}
+
+// Collect reads exactly n events from ch. It returns what it has and an error
+// when timeout elapses or ch closes first.
+func Collect(ch <-chan LogEvent, n int, timeout time.Duration) ([]LogEvent, error) {
+	out := make([]LogEvent, 0, n)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for len(out) < n {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return out, fmt.Errorf("websocket: subscriber closed after %d of %d events", len(out), n)
+			}
+			out = append(out, ev)
+		case <-deadline.C:
+			return out, fmt.Errorf("websocket: got %d of %d events within %s", len(out), n, timeout)
+		}
+	}
+	return out, nil
+}
+
+// Drain reads events until ch has been quiet for idle (or closes) and returns them.
+func Drain(ch <-chan LogEvent, idle time.Duration) []LogEvent {
+	var out []LogEvent
+	t := time.NewTimer(idle)
+	defer t.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return out
+			}
+			out = append(out, ev)
+			if !t.Stop() {
+				<-t.C
+			}
+			t.Reset(idle)
+		case <-t.C:
+			return out
+		}
+	}
+}
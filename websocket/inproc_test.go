@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
+)
+
+func TestInProcSubscribeCollect(t *testing.T) {
+	s := NewInProcLogServer()
+	defer s.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := s.Subscribe(ctx), s.Subscribe(ctx)
+
+	s.BroadcastLog("raw")
+	s.BroadcastError("payment", "boom")
+	s.BroadcastStatus(map[string]any{"event": "x"})
+
+	for _, ch := range []<-chan LogEvent{a, b} {
+		evs, err := Collect(ch, 3, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evs[0].Type != types.WSTypeLog || evs[0].Raw != "raw" {
+			t.Fatalf("log: %+v", evs[0])
+		}
+		if evs[1].Log == nil || evs[1].Log.Level != types.LogLevelError || evs[1].Log.Content != "boom" {
+			t.Fatalf("error: %+v", evs[1])
+		}
+		if evs[2].Type != types.WSTypeStatus || evs[2].Status == nil || evs[2].Time.IsZero() {
+			t.Fatalf("status: %+v", evs[2])
+		}
+	}
+}
+
+func TestInProcCollectTimeoutAndClose(t *testing.T) {
+	s := NewInProcLogServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := s.Subscribe(ctx)
+	s.BroadcastLog("one")
+
+	evs, err := Collect(ch, 2, 20*time.Millisecond)
+	if len(evs) != 1 || err == nil || !strings.Contains(err.Error(), "1 of 2 events within") {
+		t.Fatalf("timeout: %d %v", len(evs), err)
+	}
+
+	cancel()
+	evs, err = Collect(ch, 1, time.Second)
+	if len(evs) != 0 || err == nil || !strings.Contains(err.Error(), "closed after 0 of 1") {
+		t.Fatalf("close: %d %v", len(evs), err)
+	}
+	s.BroadcastLog("after cancel") // must not panic on the closed channel
+}
+
+func TestInProcDrain(t *testing.T) {
+	s := NewInProcLogServer()
+	ch := s.Subscribe(context.Background())
+	for i := 0; i < 5; i++ {
+		s.BroadcastLog("x")
+	}
+	if evs := Drain(ch, 20*time.Millisecond); len(evs) != 5 {
+		t.Fatalf("drained %d", len(evs))
+	}
+	if evs := Drain(ch, 10*time.Millisecond); len(evs) != 0 {
+		t.Fatalf("quiet drain returned %d", len(evs))
+	}
+	s.BroadcastLog("last")
+	s.Stop()
+	if evs := Drain(ch, time.Second); len(evs) != 1 {
+		t.Fatalf("drain to close: %d", len(evs))
+	}
+}
+
+func TestInProcFullSubscriberDrops(t *testing.T) {
+	s := NewInProcLogServer()
+	defer s.Stop()
+	s.Subscribe(context.Background())
+	for i := 0; i < s.bufSize+3; i++ {
+		s.BroadcastLog("x")
+	}
+	if s.Dropped() != 3 {
+		t.Fatalf("dropped %d", s.Dropped())
+	}
+}
+
+// Stop closes every subscriber and ends their watcher goroutines even when
+// the subscribers' contexts never finish; later subscribers get a closed channel.
+func TestInProcStopReleasesSubscribers(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s := NewInProcLogServer()
+	chs := make([]<-chan LogEvent, 20)
+	for i := range chs {
+		chs[i] = s.Subscribe(context.Background())
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Stop()
+	for _, ch := range chs {
+		if _, ok := <-ch; ok {
+			t.Fatal("subscriber not closed")
+		}
+	}
+	select {
+	case _, ok := <-s.Subscribe(context.Background()):
+		if ok {
+			t.Fatal("subscribe after stop delivered an event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscribe after stop returned an open channel")
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines left after Stop (had %d)", n, before)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/sage-x-project/sage-multi-agent/pkg/types"
 )
 
 var upgrader = websocket.Upgrader{
@@ -75,6 +76,27 @@ func (s *LogServer) BroadcastLog(message string) {
 	s.hub.Broadcast([]byte(message))
 }
 
+// BroadcastAgentLog sends an agent log as a JSON "log" message
+func (s *LogServer) BroadcastAgentLog(log *types.AgentLog) {
+	if data, err := types.NewWebSocketMessage(types.WSTypeLog, log).ToJSON(); err == nil {
+		s.hub.Broadcast(data)
+	}
+}
+
+// BroadcastError sends an error log from the given component
+func (s *LogServer) BroadcastError(from, errorMsg string) {
+	log := types.NewAgentLog(types.LogTypeError, from, errorMsg)
+	log.Level = types.LogLevelError
+	s.BroadcastAgentLog(log)
+}
+
+// BroadcastStatus sends a status update to all connected clients
+func (s *LogServer) BroadcastStatus(status interface{}) {
+	if data, err := types.NewWebSocketMessage(types.WSTypeStatus, status).ToJSON(); err == nil {
+		s.hub.Broadcast(data)
+	}
+}
+
 // handleWebSocket handles WebSocket connections
 func (s *LogServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)